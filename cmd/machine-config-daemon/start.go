@@ -223,10 +223,12 @@ func runStartCmd(_ *cobra.Command, _ []string) {
 	pinnedImageSetManager := daemon.NewPinnedImageSetManager(
 		startOpts.nodeName,
 		criClient,
+		kubeClient,
 		ctrlctx.ClientBuilder.MachineConfigClientOrDie(componentName),
 		ctrlctx.InformerFactory.Machineconfiguration().V1().PinnedImageSets(),
 		nodeScopedInformer,
 		ctrlctx.InformerFactory.Machineconfiguration().V1().MachineConfigPools(),
+		ctrlctx.InformerFactory.Machineconfiguration().V1().MachineConfigNodes(),
 		resource.MustParse(constants.MinFreeStorageAfterPrefetch),
 		constants.DefaultCRIOSocketPath,
 		constants.KubeletAuthFile,
@@ -242,6 +244,7 @@ func runStartCmd(_ *cobra.Command, _ []string) {
 		mcnScopedInformerStartFunc = startFunc
 		internalReleaseImageManager := internalreleaseimage.New(
 			startOpts.nodeName,
+			kubeClient,
 			ctrlctx.ClientBuilder.MachineConfigClientOrDie(componentName),
 			ctrlctx.InformerFactory.Machineconfiguration().V1().InternalReleaseImages(),
 			mcnScopedInformer,
@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 
+	configv1 "github.com/openshift/api/config/v1"
 	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	mcopclientset "github.com/openshift/client-go/operator/clientset/versioned"
 
 	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	"github.com/openshift/machine-config-operator/test/framework"
 	"github.com/spf13/cobra"
@@ -21,39 +27,145 @@ import (
 
 var (
 	diffCmd = &cobra.Command{
-		Use:   "diff",
+		Use:   "diff OLD NEW",
 		Short: "Diffs MachineConfigs",
-		Long:  "",
-		RunE: func(_ *cobra.Command, args []string) error {
-			return diffMCs(args)
+		Long: "Diffs two MachineConfigs, given either as paths to YAML or JSON files or as names of MachineConfigs of the cluster. " +
+			"The Ignition configs are compared semantically, and the node disruption actions of each change are reported.",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return diffMCs(cmd, args)
 		},
 	}
 
-	convertToYAML bool
-	keepFiles     bool
+	convertToYAML            bool
+	keepFiles                bool
+	outputFormat             string
+	machineConfigurationPath string
+	osPolicyFeatureGate      bool
 )
 
 func init() {
 	rootCmd.AddCommand(diffCmd)
 	diffCmd.PersistentFlags().BoolVar(&convertToYAML, "convert-to-yaml", false, "Converts any JSON payloads that are found into YAML before diffing")
-	diffCmd.PersistentFlags().BoolVar(&keepFiles, "keep-files", false, "Keeps the files used for diffing")
+	diffCmd.PersistentFlags().BoolVar(&keepFiles, "keep-files", false, "Keeps the files used for diffing with dyff")
+	diffCmd.PersistentFlags().StringVar(&outputFormat, "output-format", outputFormatText,
+		fmt.Sprintf("Output format: %s, %s, or %s to run dyff on the decoded MachineConfigs", outputFormatText, outputFormatJSON, outputFormatDyff))
+	diffCmd.PersistentFlags().StringVar(&machineConfigurationPath, "machine-configuration", "",
+		"Path to the MachineConfiguration holding the node disruption policies. Defaults to the one of the cluster if a MachineConfig is read from the cluster. Otherwise the default policies are used, without live kernel arguments or OS node disruption policy, and the actions are an upper bound.")
+	diffCmd.PersistentFlags().BoolVar(&osPolicyFeatureGate, "os-node-disruption-policy", false,
+		fmt.Sprintf("Applies the TechPreview live kernel arguments and OS node disruption policy annotations of the MachineConfiguration, as the daemon does when the %s feature gate is enabled", apihelpers.OSNodeDisruptionPolicyFeatureGate))
 }
 
-func diffMCs(args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("no MachineConfigs given")
+// machineConfigLoader loads MachineConfigs from files or from the cluster.
+// The cluster client is only created when needed, so files can be diffed
+// offline.
+type machineConfigLoader struct {
+	cs *framework.ClientSet
+}
+
+func (l *machineConfigLoader) clientSet() (*framework.ClientSet, error) {
+	if l.cs == nil {
+		cs, err := framework.NewClientSetOrError("")
+		if err != nil {
+			return nil, err
+		}
+		l.cs = cs
 	}
+	return l.cs, nil
+}
 
-	if len(args) == 1 {
-		return fmt.Errorf("only one MachineConfig given")
+// load returns the MachineConfig at path if it is an existing file, or the
+// MachineConfig of the cluster with that name otherwise.
+func (l *machineConfigLoader) load(nameOrPath string) (*mcfgv1.MachineConfig, error) {
+	content, err := os.ReadFile(nameOrPath)
+	if err == nil {
+		mc := &mcfgv1.MachineConfig{}
+		if err := yaml.Unmarshal(content, mc); err != nil {
+			return nil, fmt.Errorf("could not decode MachineConfig %s: %w", nameOrPath, err)
+		}
+		if mc.Name == "" {
+			mc.Name = filepath.Base(nameOrPath)
+		}
+		return mc, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
 	}
 
-	cs := framework.NewClientSet("")
+	cs, err := l.clientSet()
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a file and the cluster is not reachable: %w", nameOrPath, err)
+	}
+	return cs.MachineConfigs().Get(context.TODO(), nameOrPath, metav1.GetOptions{})
+}
 
-	eg := errgroup.Group{}
+// machineConfiguration returns the MachineConfiguration holding the node
+// disruption policies and the live kernel arguments and OS node disruption
+// policy settings to calculate the actions with, or nil if there is none.
+func (l *machineConfigLoader) machineConfiguration() (*opv1.MachineConfiguration, error) {
+	switch {
+	case machineConfigurationPath != "":
+		content, err := os.ReadFile(machineConfigurationPath)
+		if err != nil {
+			return nil, err
+		}
+		mcop := &opv1.MachineConfiguration{}
+		if err := yaml.Unmarshal(content, mcop); err != nil {
+			return nil, fmt.Errorf("could not decode MachineConfiguration %s: %w", machineConfigurationPath, err)
+		}
+		return mcop, nil
+	case l.cs != nil:
+		client, err := mcopclientset.NewForConfig(l.cs.GetRestConfig())
+		if err != nil {
+			return nil, err
+		}
+		return client.OperatorV1().MachineConfigurations().Get(context.TODO(), ctrlcommon.MCOOperatorKnobsObjectName, metav1.GetOptions{})
+	default:
+		klog.Infof("No MachineConfiguration, using the default node disruption policies; the actions are an upper bound")
+		return nil, nil
+	}
+}
 
-	mc1 := args[0]
-	mc2 := args[1]
+func diffMCs(cmd *cobra.Command, args []string) error {
+	switch outputFormat {
+	case outputFormatText, outputFormatJSON:
+	case outputFormatDyff:
+		return dyffMCs(args)
+	default:
+		return fmt.Errorf("unknown output format %q, must be one of %s, %s, %s", outputFormat, outputFormatText, outputFormatJSON, outputFormatDyff)
+	}
+
+	loader := &machineConfigLoader{}
+	oldMC, err := loader.load(args[0])
+	if err != nil {
+		return err
+	}
+	newMC, err := loader.load(args[1])
+	if err != nil {
+		return err
+	}
+
+	mcop, err := loader.machineConfiguration()
+	if err != nil {
+		return fmt.Errorf("could not get the MachineConfiguration: %w", err)
+	}
+
+	enabled, disabled := []configv1.FeatureGateName{}, []configv1.FeatureGateName{apihelpers.OSNodeDisruptionPolicyFeatureGate}
+	if osPolicyFeatureGate {
+		enabled, disabled = disabled, enabled
+	}
+
+	diff, err := diffMachineConfigs(oldMC, newMC, mcop, ctrlcommon.NewFeatureGatesHardcodedHandler(enabled, disabled))
+	if err != nil {
+		return err
+	}
+	return writeDiff(cmd.OutOrStdout(), diff, outputFormat)
+}
+
+// dyffMCs writes the MachineConfigs with their decoded Ignition configs to
+// files, and runs dyff on them.
+func dyffMCs(args []string) error {
+	loader := &machineConfigLoader{}
 
 	dirname := ""
 	if keepFiles {
@@ -75,32 +187,36 @@ func diffMCs(args []string) error {
 		dirname = tempdir
 	}
 
-	eg.Go(func() error {
-		return getMCAndWriteToFile(cs, dirname, mc1)
-	})
+	mcs := make([]*mcfgv1.MachineConfig, len(args))
+	for i, arg := range args {
+		mc, err := loader.load(arg)
+		if err != nil {
+			return err
+		}
+		mcs[i] = mc
+	}
 
-	eg.Go(func() error {
-		return getMCAndWriteToFile(cs, dirname, mc2)
-	})
+	eg := errgroup.Group{}
+
+	for i := range mcs {
+		eg.Go(func() error {
+			return writeMCToFile(dirname, fmt.Sprintf("%d-%s", i, mcs[i].Name), mcs[i])
+		})
+	}
 
 	if err := eg.Wait(); err != nil {
 		return err
 	}
 
 	klog.Infof("Running dyff command")
-	out := exec.Command("dyff", "between", getMCFilename(dirname, mc1), getMCFilename(dirname, mc2))
+	out := exec.Command("dyff", "between", getMCFilename(dirname, "0-"+mcs[0].Name), getMCFilename(dirname, "1-"+mcs[1].Name))
 	out.Stdout = os.Stdout
 	out.Stderr = os.Stderr
 
 	return out.Run()
 }
 
-func getMCAndWriteToFile(cs *framework.ClientSet, dirname, name string) error {
-	mc, err := cs.MachineConfigs().Get(context.TODO(), name, metav1.GetOptions{})
-	if err != nil {
-		return err
-	}
-
+func writeMCToFile(dirname, name string, mc *mcfgv1.MachineConfig) error {
 	outBytes, err := yaml.Marshal(mc)
 	if err != nil {
 		return err
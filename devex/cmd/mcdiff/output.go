@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	opv1 "github.com/openshift/api/operator/v1"
+)
+
+// Output formats of the diff.
+const (
+	outputFormatText = "text"
+	outputFormatJSON = "json"
+	outputFormatDyff = "dyff"
+)
+
+// writeDiff writes the semantic diff to w in the given format.
+func writeDiff(w io.Writer, diff *MachineConfigDiff, format string) error {
+	if format == outputFormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	}
+	writeTextDiff(w, diff)
+	return nil
+}
+
+func writeTextDiff(w io.Writer, diff *MachineConfigDiff) {
+	fmt.Fprintf(w, "MachineConfig %s -> %s\n", diff.Old, diff.New)
+	if diff.IsEmpty() {
+		fmt.Fprintln(w, "\nNo differences found")
+		return
+	}
+
+	if len(diff.Files) > 0 {
+		fmt.Fprintln(w, "\nFILES")
+		for _, f := range diff.Files {
+			fmt.Fprintf(w, "%s %s%s\n", f.Change, f.Path, formatActionsSuffix(f.Actions))
+			writeDetails(w, f.Details)
+			writeIndented(w, f.Contents)
+		}
+	}
+
+	if len(diff.Units) > 0 {
+		fmt.Fprintln(w, "\nUNITS")
+		for _, u := range diff.Units {
+			fmt.Fprintf(w, "%s %s%s\n", u.Change, u.Name, formatActionsSuffix(u.Actions))
+			writeDetails(w, u.Details)
+			writeIndented(w, u.Contents)
+			for _, d := range u.Dropins {
+				fmt.Fprintf(w, "  %s dropin %s\n", d.Change, d.Name)
+				writeIndented(w, d.Contents)
+			}
+		}
+	}
+
+	rebootSuffix := formatActionsSuffix([]string{string(opv1.RebootStatusAction)})
+	kargsActions := diff.Disruption.KernelArgumentActions
+	if diff.Disruption.LiveKernelArguments {
+		kargsActions = []string{"applied live"}
+	}
+	kargsSuffix := formatActionsSuffix(kargsActions)
+	writeListDiff(w, "KERNEL ARGUMENTS", diff.KernelArguments, kargsSuffix)
+	writeListDiff(w, "KERNEL ARGUMENTS SHOULD EXIST", diff.KernelArgumentsShouldExist, kargsSuffix)
+	writeListDiff(w, "KERNEL ARGUMENTS SHOULD NOT EXIST", diff.KernelArgumentsShouldNotExist, kargsSuffix)
+	writeListDiff(w, "EXTENSIONS", diff.Extensions, formatActionsSuffix(diff.Disruption.ExtensionActions))
+	writeListDiff(w, "SSH KEYS", diff.SSHKeys, formatActionsSuffix(diff.Disruption.SSHKeyActions))
+	writeValueDiff(w, "OS IMAGE", diff.OSImageURL, rebootSuffix)
+	writeValueDiff(w, "KERNEL TYPE", diff.KernelType, rebootSuffix)
+	writeValueDiff(w, "FIPS", diff.FIPS, rebootSuffix)
+
+	fmt.Fprintln(w, "\nNODE DISRUPTION")
+	fmt.Fprintf(w, "actions: %s\n", strings.Join(diff.Disruption.Actions, ", "))
+	fmt.Fprintf(w, "drain required: %t\n", diff.Disruption.DrainRequired)
+	fmt.Fprintf(w, "actions without node disruption policies (firstboot): %s\n", strings.Join(diff.Disruption.LegacyActions, ", "))
+	if diff.Disruption.UpperBound {
+		fmt.Fprintln(w, "no MachineConfiguration: default policies, no live kernel arguments and no OS policy assumed; these actions are an upper bound")
+	}
+	if diff.Unreconcilable != "" {
+		fmt.Fprintf(w, "\nUNRECONCILABLE: %s\n", diff.Unreconcilable)
+	}
+}
+
+func writeListDiff(w io.Writer, title string, ld *ListDiff, actionsSuffix string) {
+	if ld == nil {
+		return
+	}
+	fmt.Fprintf(w, "\n%s%s\n", title, actionsSuffix)
+	for _, v := range ld.Removed {
+		fmt.Fprintf(w, "- %s\n", v)
+	}
+	for _, v := range ld.Added {
+		fmt.Fprintf(w, "+ %s\n", v)
+	}
+}
+
+func writeValueDiff(w io.Writer, title string, vd *ValueDiff, actionsSuffix string) {
+	if vd == nil {
+		return
+	}
+	fmt.Fprintf(w, "\n%s%s\n", title, actionsSuffix)
+	fmt.Fprintf(w, "- %s\n+ %s\n", vd.Old, vd.New)
+}
+
+func writeDetails(w io.Writer, details []string) {
+	for _, d := range details {
+		fmt.Fprintf(w, "  %s\n", d)
+	}
+}
+
+func writeIndented(w io.Writer, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		fmt.Fprintf(w, "    %s\n", line)
+	}
+}
+
+func formatActionsSuffix(actions []string) string {
+	if len(actions) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" [%s]", strings.Join(actions, ", "))
+}
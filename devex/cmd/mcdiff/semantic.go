@@ -0,0 +1,506 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon"
+	"github.com/openshift/machine-config-operator/pkg/helpers"
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/yaml"
+)
+
+// Kinds of change of a file, a unit or a dropin.
+const (
+	changeAdded   = "added"
+	changeRemoved = "removed"
+	changeUpdated = "updated"
+)
+
+// MachineConfigDiff is the semantic difference between two MachineConfigs.
+type MachineConfigDiff struct {
+	Old             string     `json:"old"`
+	New             string     `json:"new"`
+	Files           []FileDiff `json:"files,omitempty"`
+	Units           []UnitDiff `json:"units,omitempty"`
+	KernelArguments *ListDiff  `json:"kernelArguments,omitempty"`
+	// KernelArgumentsShouldExist and KernelArgumentsShouldNotExist are the
+	// changes of the Ignition kernelArguments section.
+	KernelArgumentsShouldExist    *ListDiff        `json:"kernelArgumentsShouldExist,omitempty"`
+	KernelArgumentsShouldNotExist *ListDiff        `json:"kernelArgumentsShouldNotExist,omitempty"`
+	Extensions                    *ListDiff        `json:"extensions,omitempty"`
+	SSHKeys                       *ListDiff        `json:"sshKeys,omitempty"`
+	OSImageURL                    *ValueDiff       `json:"osImageURL,omitempty"`
+	KernelType                    *ValueDiff       `json:"kernelType,omitempty"`
+	FIPS                          *ValueDiff       `json:"fips,omitempty"`
+	Disruption                    DisruptionReport `json:"disruption"`
+	// Unreconcilable is the reason why the MCD refuses to apply the new
+	// MachineConfig over the old one, if it does.
+	Unreconcilable string `json:"unreconcilable,omitempty"`
+}
+
+// IsEmpty returns true if the MachineConfigs are equivalent.
+func (d *MachineConfigDiff) IsEmpty() bool {
+	return len(d.Files) == 0 && len(d.Units) == 0 && d.KernelArguments == nil &&
+		d.KernelArgumentsShouldExist == nil && d.KernelArgumentsShouldNotExist == nil && d.Extensions == nil &&
+		d.SSHKeys == nil && d.OSImageURL == nil && d.KernelType == nil && d.FIPS == nil
+}
+
+// FileDiff is the change of an Ignition file.
+type FileDiff struct {
+	Path   string `json:"path"`
+	Change string `json:"change"`
+	// Details are the changes of the file attributes, e.g. its mode.
+	Details []string `json:"details,omitempty"`
+	// Contents is the unified diff of the decoded contents.
+	Contents string   `json:"contents,omitempty"`
+	Actions  []string `json:"actions,omitempty"`
+}
+
+// UnitDiff is the change of a systemd unit.
+type UnitDiff struct {
+	Name     string       `json:"name"`
+	Change   string       `json:"change"`
+	Details  []string     `json:"details,omitempty"`
+	Contents string       `json:"contents,omitempty"`
+	Dropins  []DropinDiff `json:"dropins,omitempty"`
+	Actions  []string     `json:"actions,omitempty"`
+}
+
+// DropinDiff is the change of a systemd unit dropin.
+type DropinDiff struct {
+	Name     string `json:"name"`
+	Change   string `json:"change"`
+	Contents string `json:"contents,omitempty"`
+}
+
+// ListDiff is the change of a list of values, such as kernel arguments.
+type ListDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// ValueDiff is the change of a single value.
+type ValueDiff struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// DisruptionReport are the actions the MCD takes to apply the changes.
+type DisruptionReport struct {
+	Actions []string `json:"actions"`
+	// LegacyActions are the actions taken when the node disruption policies
+	// cannot be used, e.g. on firstboot.
+	LegacyActions    []string `json:"legacyActions"`
+	DrainRequired    bool     `json:"drainRequired"`
+	RebootRequiredBy []string `json:"rebootRequiredBy,omitempty"`
+	SSHKeyActions    []string `json:"sshKeyActions,omitempty"`
+	// KernelArgumentActions and ExtensionActions are the actions of the
+	// kernel arguments and extensions changes.
+	KernelArgumentActions []string `json:"kernelArgumentActions,omitempty"`
+	LiveKernelArguments   bool     `json:"liveKernelArguments,omitempty"`
+	ExtensionActions      []string `json:"extensionActions,omitempty"`
+	// UpperBound is set if no MachineConfiguration was available, so the
+	// default node disruption policies were used without live kernel
+	// arguments or OS node disruption policy. The node may be disrupted less.
+	UpperBound bool `json:"upperBound,omitempty"`
+}
+
+// diffMachineConfigs computes the semantic difference between two
+// MachineConfigs, and the actions the MCD takes to apply it with the given
+// MachineConfiguration and feature gates, which may be nil.
+func diffMachineConfigs(oldMC, newMC *mcfgv1.MachineConfig, mcop *opv1.MachineConfiguration, fgHandler ctrlcommon.FeatureGatesHandler) (*MachineConfigDiff, error) {
+	oldIgn, err := ctrlcommon.ParseAndConvertConfig(oldMC.Spec.Config.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the Ignition config of %s: %w", oldMC.Name, err)
+	}
+	newIgn, err := ctrlcommon.ParseAndConvertConfig(newMC.Spec.Config.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the Ignition config of %s: %w", newMC.Name, err)
+	}
+
+	actions, err := daemon.CalculateConfigChangeActions(oldMC, newMC, mcop, fgHandler)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &MachineConfigDiff{
+		Old:             oldMC.Name,
+		New:             newMC.Name,
+		KernelArguments: diffLists(oldMC.Spec.KernelArguments, newMC.Spec.KernelArguments),
+		KernelArgumentsShouldExist: diffLists(kernelArguments(oldIgn.KernelArguments.ShouldExist),
+			kernelArguments(newIgn.KernelArguments.ShouldExist)),
+		KernelArgumentsShouldNotExist: diffLists(kernelArguments(oldIgn.KernelArguments.ShouldNotExist),
+			kernelArguments(newIgn.KernelArguments.ShouldNotExist)),
+		Extensions: diffLists(oldMC.Spec.Extensions, newMC.Spec.Extensions),
+		SSHKeys:    diffLists(sshKeys(oldIgn), sshKeys(newIgn)),
+		OSImageURL: diffValues(oldMC.Spec.OSImageURL, newMC.Spec.OSImageURL),
+		KernelType: diffValues(helpers.CanonicalizeKernelType(oldMC.Spec.KernelType), helpers.CanonicalizeKernelType(newMC.Spec.KernelType)),
+		FIPS:       diffValues(fmt.Sprint(oldMC.Spec.FIPS), fmt.Sprint(newMC.Spec.FIPS)),
+		Disruption: DisruptionReport{
+			Actions:               formatActions(actions.Actions),
+			LegacyActions:         actions.LegacyActions,
+			DrainRequired:         actions.DrainRequired,
+			RebootRequiredBy:      actions.RebootRequiredBy,
+			SSHKeyActions:         formatActions(actions.SSHKeyActions),
+			KernelArgumentActions: formatActions(actions.KernelArgumentActions),
+			LiveKernelArguments:   actions.LiveKernelArguments,
+			ExtensionActions:      formatActions(actions.ExtensionActions),
+			UpperBound:            actions.UpperBound,
+		},
+	}
+
+	if diff.Files, err = diffFiles(oldIgn.Storage.Files, newIgn.Storage.Files, actions.FileActions); err != nil {
+		return nil, err
+	}
+	diff.Units = diffUnits(oldIgn.Systemd.Units, newIgn.Systemd.Units, actions.UnitActions)
+
+	if err := ctrlcommon.IsRenderedConfigReconcilable(oldMC, newMC, nil); err != nil {
+		diff.Unreconcilable = err.Error()
+	}
+
+	return diff, nil
+}
+
+func diffFiles(oldFiles, newFiles []ign3types.File, fileActions map[string][]opv1.NodeDisruptionPolicyStatusAction) ([]FileDiff, error) {
+	oldByPath := map[string]ign3types.File{}
+	for _, f := range oldFiles {
+		oldByPath[f.Path] = f
+	}
+	newByPath := map[string]ign3types.File{}
+	for _, f := range newFiles {
+		newByPath[f.Path] = f
+	}
+
+	var diffs []FileDiff
+	for _, path := range sortedKeys(oldByPath, newByPath) {
+		oldFile, inOld := oldByPath[path]
+		newFile, inNew := newByPath[path]
+
+		if _, changed := fileActions[path]; !changed {
+			continue
+		}
+
+		fd := FileDiff{Path: path, Actions: formatActions(fileActions[path])}
+		switch {
+		case !inNew:
+			fd.Change = changeRemoved
+		case !inOld:
+			fd.Change = changeAdded
+		default:
+			fd.Change = changeUpdated
+			fd.Details = diffFileAttributes(oldFile, newFile)
+		}
+
+		oldContents, err := fileContents(oldFile, inOld)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode the old contents of %s: %w", path, err)
+		}
+		newContents, err := fileContents(newFile, inNew)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode the new contents of %s: %w", path, err)
+		}
+
+		switch {
+		case !isText(oldContents) || !isText(newContents):
+			if string(oldContents) != string(newContents) {
+				fd.Details = append(fd.Details, fmt.Sprintf("binary contents: %d bytes -> %d bytes", len(oldContents), len(newContents)))
+			}
+		case string(oldContents) != string(newContents):
+			fd.Contents = unifiedDiff(path, string(oldContents), string(newContents))
+		case fd.Change == changeUpdated && !fileSourcesEqual(oldFile, newFile):
+			fd.Details = append(fd.Details, "contents encoding changed")
+		}
+
+		diffs = append(diffs, fd)
+	}
+	return diffs, nil
+}
+
+// diffFileAttributes describes the changes of the attributes of a file,
+// other than its contents.
+func diffFileAttributes(oldFile, newFile ign3types.File) []string {
+	var details []string
+	if oldMode, newMode := fileMode(oldFile), fileMode(newFile); oldMode != newMode {
+		details = append(details, fmt.Sprintf("mode: %s -> %s", oldMode, newMode))
+	}
+	if oldOwner, newOwner := fileOwner(oldFile.Node), fileOwner(newFile.Node); oldOwner != newOwner {
+		details = append(details, fmt.Sprintf("owner: %s -> %s", oldOwner, newOwner))
+	}
+	if oldOverwrite, newOverwrite := boolValue(oldFile.Overwrite), boolValue(newFile.Overwrite); oldOverwrite != newOverwrite {
+		details = append(details, fmt.Sprintf("overwrite: %t -> %t", oldOverwrite, newOverwrite))
+	}
+	if len(oldFile.Append) != len(newFile.Append) {
+		details = append(details, fmt.Sprintf("appended contents: %d -> %d", len(oldFile.Append), len(newFile.Append)))
+	}
+	return details
+}
+
+func diffUnits(oldUnits, newUnits []ign3types.Unit, unitActions map[string][]opv1.NodeDisruptionPolicyStatusAction) []UnitDiff {
+	oldByName := map[string]ign3types.Unit{}
+	for _, u := range oldUnits {
+		oldByName[u.Name] = u
+	}
+	newByName := map[string]ign3types.Unit{}
+	for _, u := range newUnits {
+		newByName[u.Name] = u
+	}
+
+	var diffs []UnitDiff
+	for _, name := range sortedKeys(oldByName, newByName) {
+		oldUnit, inOld := oldByName[name]
+		newUnit, inNew := newByName[name]
+		if _, changed := unitActions[name]; !changed {
+			continue
+		}
+
+		ud := UnitDiff{Name: name, Actions: formatActions(unitActions[name])}
+		switch {
+		case !inNew:
+			ud.Change = changeRemoved
+		case !inOld:
+			ud.Change = changeAdded
+		default:
+			ud.Change = changeUpdated
+			if oldEnabled, newEnabled := unitEnabled(oldUnit), unitEnabled(newUnit); oldEnabled != newEnabled {
+				ud.Details = append(ud.Details, fmt.Sprintf("enabled: %s -> %s", oldEnabled, newEnabled))
+			}
+			if oldMask, newMask := boolValue(oldUnit.Mask), boolValue(newUnit.Mask); oldMask != newMask {
+				ud.Details = append(ud.Details, fmt.Sprintf("mask: %t -> %t", oldMask, newMask))
+			}
+		}
+
+		if oldContents, newContents := stringValue(oldUnit.Contents), stringValue(newUnit.Contents); oldContents != newContents {
+			ud.Contents = unifiedDiff(name, oldContents, newContents)
+		}
+		ud.Dropins = diffDropins(name, oldUnit.Dropins, newUnit.Dropins)
+
+		diffs = append(diffs, ud)
+	}
+	return diffs
+}
+
+func diffDropins(unitName string, oldDropins, newDropins []ign3types.Dropin) []DropinDiff {
+	oldByName := map[string]ign3types.Dropin{}
+	for _, d := range oldDropins {
+		oldByName[d.Name] = d
+	}
+	newByName := map[string]ign3types.Dropin{}
+	for _, d := range newDropins {
+		newByName[d.Name] = d
+	}
+
+	var diffs []DropinDiff
+	for _, name := range sortedKeys(oldByName, newByName) {
+		oldDropin, inOld := oldByName[name]
+		newDropin, inNew := newByName[name]
+		oldContents, newContents := stringValue(oldDropin.Contents), stringValue(newDropin.Contents)
+
+		dd := DropinDiff{Name: name}
+		switch {
+		case !inNew:
+			dd.Change = changeRemoved
+		case !inOld:
+			dd.Change = changeAdded
+		case oldContents != newContents:
+			dd.Change = changeUpdated
+		default:
+			continue
+		}
+		if oldContents != newContents {
+			dd.Contents = unifiedDiff(unitName+".d/"+name, oldContents, newContents)
+		}
+		diffs = append(diffs, dd)
+	}
+	return diffs
+}
+
+// diffLists returns the values added and removed between two lists, nil if
+// they hold the same values.
+func diffLists(oldValues, newValues []string) *ListDiff {
+	remaining := map[string]int{}
+	for _, v := range oldValues {
+		remaining[v]++
+	}
+
+	ld := &ListDiff{}
+	for _, v := range newValues {
+		if remaining[v] > 0 {
+			remaining[v]--
+			continue
+		}
+		ld.Added = append(ld.Added, v)
+	}
+	for _, v := range oldValues {
+		if remaining[v] > 0 {
+			remaining[v]--
+			ld.Removed = append(ld.Removed, v)
+		}
+	}
+
+	if len(ld.Added) == 0 && len(ld.Removed) == 0 {
+		return nil
+	}
+	return ld
+}
+
+func diffValues(oldValue, newValue string) *ValueDiff {
+	if oldValue == newValue {
+		return nil
+	}
+	return &ValueDiff{Old: oldValue, New: newValue}
+}
+
+// sshKeys returns the SSH keys of the Ignition users, prefixed by the user name.
+func sshKeys(ignConfig ign3types.Config) []string {
+	var keys []string
+	for _, user := range ignConfig.Passwd.Users {
+		for _, key := range user.SSHAuthorizedKeys {
+			keys = append(keys, fmt.Sprintf("%s: %s", user.Name, key))
+		}
+	}
+	return keys
+}
+
+func kernelArguments(kargs []ign3types.KernelArgument) []string {
+	var args []string
+	for _, karg := range kargs {
+		args = append(args, string(karg))
+	}
+	return args
+}
+
+// fileContents returns the decoded contents of a file, converted to YAML if
+// requested.
+func fileContents(file ign3types.File, exists bool) ([]byte, error) {
+	if !exists {
+		return nil, nil
+	}
+	contents, err := ctrlcommon.DecodeIgnitionFileContents(file.Contents.Source, file.Contents.Compression)
+	if err != nil {
+		return nil, err
+	}
+	// Only JSON objects are converted, as any YAML document is valid input
+	if convertToYAML && strings.HasPrefix(strings.TrimSpace(string(contents)), "{") {
+		if converted, err := yaml.JSONToYAML(contents); err == nil {
+			return converted, nil
+		}
+	}
+	return contents, nil
+}
+
+func fileSourcesEqual(oldFile, newFile ign3types.File) bool {
+	return stringValue(oldFile.Contents.Source) == stringValue(newFile.Contents.Source) &&
+		stringValue(oldFile.Contents.Compression) == stringValue(newFile.Contents.Compression)
+}
+
+func unifiedDiff(name, oldText, newText string) string {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(oldText),
+		B:        splitLines(newText),
+		FromFile: "a/" + strings.TrimPrefix(name, "/"),
+		ToFile:   "b/" + strings.TrimPrefix(name, "/"),
+		Context:  3,
+	})
+	if err != nil {
+		return fmt.Sprintf("could not compute the diff: %v", err)
+	}
+	return diff
+}
+
+// splitLines splits text into lines keeping their newlines. Unlike
+// difflib.SplitLines, no empty line is added after a trailing newline.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// isText returns true if data is UTF-8 text without NUL bytes.
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !slices.Contains(data, 0)
+}
+
+// formatActions returns the human readable node disruption actions.
+func formatActions(actions []opv1.NodeDisruptionPolicyStatusAction) []string {
+	var formatted []string
+	for _, action := range actions {
+		switch {
+		case action.Type == opv1.ReloadStatusAction && action.Reload != nil:
+			formatted = append(formatted, fmt.Sprintf("%s %s", action.Type, action.Reload.ServiceName))
+		case action.Type == opv1.RestartStatusAction && action.Restart != nil:
+			formatted = append(formatted, fmt.Sprintf("%s %s", action.Type, action.Restart.ServiceName))
+		default:
+			formatted = append(formatted, string(action.Type))
+		}
+	}
+	return formatted
+}
+
+func fileMode(file ign3types.File) string {
+	if file.Mode == nil {
+		return "default"
+	}
+	return fmt.Sprintf("%#o", *file.Mode)
+}
+
+func fileOwner(node ign3types.Node) string {
+	user, group := "root", "root"
+	if node.User.Name != nil {
+		user = *node.User.Name
+	} else if node.User.ID != nil {
+		user = fmt.Sprint(*node.User.ID)
+	}
+	if node.Group.Name != nil {
+		group = *node.Group.Name
+	} else if node.Group.ID != nil {
+		group = fmt.Sprint(*node.Group.ID)
+	}
+	return user + ":" + group
+}
+
+func unitEnabled(unit ign3types.Unit) string {
+	if unit.Enabled == nil {
+		return "unset"
+	}
+	return fmt.Sprint(*unit.Enabled)
+}
+
+func boolValue(b *bool) bool {
+	return b != nil && *b
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// sortedKeys returns the sorted union of the keys of the maps.
+func sortedKeys[T any](maps ...map[string]T) []string {
+	set := map[string]struct{}{}
+	for _, m := range maps {
+		for k := range m {
+			set[k] = struct{}{}
+		}
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	opv1 "github.com/openshift/api/operator/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/test/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+)
+
+func gzipIgnFile(t *testing.T, path, contents string) ign3types.File {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	_, err := gz.Write([]byte(contents))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	file := ctrlcommon.NewIgnFile(path, "")
+	file.Contents.Source = ptr.To("data:;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()))
+	file.Contents.Compression = ptr.To("gzip")
+	return file
+}
+
+func TestDiffMachineConfigs(t *testing.T) {
+	mcop := &opv1.MachineConfiguration{
+		Spec: opv1.MachineConfigurationSpec{
+			NodeDisruptionPolicy: opv1.NodeDisruptionPolicyConfig{
+				Units: []opv1.NodeDisruptionPolicySpecUnit{{
+					Name:    "chronyd.service",
+					Actions: []opv1.NodeDisruptionPolicySpecAction{{Type: opv1.RestartSpecAction, Restart: &opv1.RestartService{ServiceName: "chronyd.service"}}},
+				}},
+			},
+		},
+	}
+
+	oldMC := helpers.NewMachineConfigExtended("rendered-worker-old", nil, nil,
+		[]ign3types.File{
+			gzipIgnFile(t, "/etc/test.conf", "line 1\nline 2\n"),
+			ctrlcommon.NewIgnFile("/var/lib/kubelet/config.json", "pull secret\n"),
+		},
+		[]ign3types.Unit{{Name: "chronyd.service", Contents: ptr.To("[Unit]\n")}},
+		nil, nil, false, []string{"nosmt"}, "", "dummy://")
+
+	t.Run("no changes", func(t *testing.T) {
+		diff, err := diffMachineConfigs(oldMC, oldMC, mcop, nil)
+		require.NoError(t, err)
+
+		assert.True(t, diff.IsEmpty())
+		assert.Equal(t, []string{string(opv1.NoneStatusAction)}, diff.Disruption.Actions)
+		assert.False(t, diff.Disruption.DrainRequired)
+	})
+
+	t.Run("files and units", func(t *testing.T) {
+		// the old file is gzipped in a data URL, the new one is not
+		newMC := helpers.NewMachineConfigExtended("rendered-worker-new", nil, nil,
+			[]ign3types.File{
+				ctrlcommon.NewIgnFile("/etc/test.conf", "line 1\nline 2 changed\n"),
+				ctrlcommon.NewIgnFile("/var/lib/kubelet/config.json", "pull secret\n"),
+			},
+			[]ign3types.Unit{{
+				Name:     "chronyd.service",
+				Contents: ptr.To("[Unit]\n"),
+				Enabled:  ptr.To(true),
+				Dropins:  []ign3types.Dropin{{Name: "10-override.conf", Contents: ptr.To("[Service]\n")}},
+			}},
+			nil, nil, false, []string{"nosmt"}, "", "dummy://")
+
+		diff, err := diffMachineConfigs(oldMC, newMC, mcop, nil)
+		require.NoError(t, err)
+
+		require.Len(t, diff.Files, 1)
+		assert.Equal(t, "/etc/test.conf", diff.Files[0].Path)
+		assert.Equal(t, changeUpdated, diff.Files[0].Change)
+		assert.Contains(t, diff.Files[0].Contents, "-line 2\n+line 2 changed\n")
+		assert.Equal(t, []string{"Reboot"}, diff.Files[0].Actions)
+
+		require.Len(t, diff.Units, 1)
+		assert.Equal(t, []string{"enabled: unset -> true"}, diff.Units[0].Details)
+		assert.Equal(t, []DropinDiff{{Name: "10-override.conf", Change: changeAdded, Contents: unifiedDiff("chronyd.service.d/10-override.conf", "", "[Service]\n")}}, diff.Units[0].Dropins)
+		assert.Equal(t, []string{"Restart chronyd.service"}, diff.Units[0].Actions)
+
+		assert.Nil(t, diff.KernelArguments)
+		assert.Equal(t, []string{string(opv1.RebootStatusAction)}, diff.Disruption.Actions)
+		assert.True(t, diff.Disruption.DrainRequired)
+		assert.Empty(t, diff.Unreconcilable)
+	})
+
+	t.Run("re-encoded contents only", func(t *testing.T) {
+		newMC := helpers.NewMachineConfigExtended("rendered-worker-new", nil, nil,
+			[]ign3types.File{
+				ctrlcommon.NewIgnFile("/etc/test.conf", "line 1\nline 2\n"),
+				ctrlcommon.NewIgnFile("/var/lib/kubelet/config.json", "pull secret\n"),
+			},
+			[]ign3types.Unit{{Name: "chronyd.service", Contents: ptr.To("[Unit]\n")}},
+			nil, nil, false, []string{"nosmt"}, "", "dummy://")
+
+		diff, err := diffMachineConfigs(oldMC, newMC, mcop, nil)
+		require.NoError(t, err)
+
+		require.Len(t, diff.Files, 1)
+		assert.Empty(t, diff.Files[0].Contents)
+		assert.Equal(t, []string{"contents encoding changed"}, diff.Files[0].Details)
+	})
+
+	t.Run("os changes", func(t *testing.T) {
+		newMC := helpers.NewMachineConfigExtended("rendered-worker-new", nil, nil,
+			[]ign3types.File{
+				gzipIgnFile(t, "/etc/test.conf", "line 1\nline 2\n"),
+				ctrlcommon.NewIgnFile("/var/lib/kubelet/config.json", "pull secret\n"),
+			},
+			[]ign3types.Unit{{Name: "chronyd.service", Contents: ptr.To("[Unit]\n")}},
+			nil, []string{"usbguard"}, false, []string{"nosmt", "nosmt", "debug"}, "realtime", "dummy://new")
+
+		diff, err := diffMachineConfigs(oldMC, newMC, mcop, nil)
+		require.NoError(t, err)
+
+		assert.Empty(t, diff.Files)
+		assert.Equal(t, &ListDiff{Added: []string{"nosmt", "debug"}}, diff.KernelArguments)
+		assert.Nil(t, diff.KernelArgumentsShouldExist)
+		assert.Equal(t, &ListDiff{Added: []string{"usbguard"}}, diff.Extensions)
+		assert.Equal(t, &ValueDiff{Old: "dummy://", New: "dummy://new"}, diff.OSImageURL)
+		assert.Equal(t, &ValueDiff{Old: "default", New: "realtime"}, diff.KernelType)
+		assert.Equal(t, []string{string(opv1.RebootStatusAction)}, diff.Disruption.Actions)
+		assert.Equal(t, []string{"extensions", "kernelArguments", "kernelType", "osImageURL"}, diff.Disruption.RebootRequiredBy)
+		assert.Equal(t, []string{string(opv1.RebootStatusAction)}, diff.Disruption.KernelArgumentActions)
+		assert.True(t, diff.Disruption.DrainRequired)
+		assert.False(t, diff.Disruption.UpperBound)
+	})
+
+	t.Run("without MachineConfiguration", func(t *testing.T) {
+		newMC := oldMC.DeepCopy()
+		newMC.Spec.KernelArguments = append(newMC.Spec.KernelArguments, "debug")
+
+		diff, err := diffMachineConfigs(oldMC, newMC, nil, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{string(opv1.RebootStatusAction)}, diff.Disruption.Actions)
+		assert.True(t, diff.Disruption.UpperBound)
+	})
+}
+
+func TestDiffLists(t *testing.T) {
+	assert.Nil(t, diffLists(nil, nil))
+	assert.Nil(t, diffLists([]string{"a", "b"}, []string{"b", "a"}))
+	assert.Equal(t, &ListDiff{Added: []string{"c"}, Removed: []string{"a"}}, diffLists([]string{"a", "b"}, []string{"b", "c"}))
+	assert.Equal(t, &ListDiff{Removed: []string{"a"}}, diffLists([]string{"a", "a"}, []string{"a"}))
+}
+
+func TestWriteDiff(t *testing.T) {
+	diff := &MachineConfigDiff{
+		Old:             "old",
+		New:             "new",
+		Files:           []FileDiff{{Path: "/etc/foo", Change: changeAdded, Contents: unifiedDiff("/etc/foo", "", "foo\n"), Actions: []string{"Reboot"}}},
+		KernelArguments: &ListDiff{Added: []string{"nosmt"}},
+		Disruption: DisruptionReport{
+			Actions:               []string{"Reboot"},
+			LegacyActions:         []string{"reboot"},
+			DrainRequired:         true,
+			KernelArgumentActions: []string{"Reboot"},
+			UpperBound:            true,
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, writeDiff(buf, diff, outputFormatText))
+	assert.Contains(t, buf.String(), "FILES\nadded /etc/foo [Reboot]\n    --- a/etc/foo\n")
+	assert.Contains(t, buf.String(), "KERNEL ARGUMENTS [Reboot]\n+ nosmt\n")
+	assert.Contains(t, buf.String(), "drain required: true\n")
+	assert.Contains(t, buf.String(), "these actions are an upper bound\n")
+
+	buf.Reset()
+	require.NoError(t, writeDiff(buf, diff, outputFormatJSON))
+	decoded := &MachineConfigDiff{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), decoded))
+	assert.Equal(t, diff, decoded)
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	mcfglistersv1 "github.com/openshift/client-go/machineconfiguration/listers/machineconfiguration/v1"
+	"github.com/openshift/library-go/pkg/config/clusteroperator/v1helpers"
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	buildconstants "github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/helpers"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// Signatures of the known failure modes flagged by the analyzer.
+const (
+	SignatureDrainStuck         = "DrainStuck"
+	SignatureIrreconcilableDiff = "IrreconcilableDiff"
+	SignatureConfigDrift        = "ConfigDrift"
+	SignatureRpmOstreeFailure   = "RpmOstreeFailure"
+	SignatureBootImageSkew      = "BootImageSkew"
+)
+
+// clusterBootImageSkewReason is the reason of the Upgradeable condition of
+// the machine-config ClusterOperator when the boot image skew limit is exceeded.
+const clusterBootImageSkewReason = "ClusterBootImageSkewError"
+
+// maxEvidence is the maximum number of log lines kept as evidence of a finding.
+const maxEvidence = 5
+
+// Log lines matching the failure signatures, as logged by the MCD and the MCC.
+var (
+	drainFailureRe    = regexp.MustCompile(`(?i)failed to drain node|drain failed|drain exceeded timeout|error when evicting`)
+	irreconcilableRe  = regexp.MustCompile(`can't reconcile config|not reconcilable`)
+	configDriftRe     = regexp.MustCompile(`unexpected on-disk state|content mismatch for file|mode mismatch for file`)
+	rpmOstreeFailedRe = regexp.MustCompile(`(?i)error running rpm-ostree|rpm-ostree[^:]*: .*(?:error|failed)|ostree-finalize-staged.*fail`)
+)
+
+// Report is the reconstructed MCO state of a must-gather.
+type Report struct {
+	Pools    []PoolReport `json:"pools"`
+	Nodes    []NodeReport `json:"nodes"`
+	Findings []Finding    `json:"findings"`
+}
+
+// PoolReport is the update state of a MachineConfigPool.
+type PoolReport struct {
+	Name          string `json:"name"`
+	DesiredConfig string `json:"desiredConfig"`
+	Layered       bool   `json:"layered"`
+	Paused        bool   `json:"paused"`
+	// Status summarizes the pool conditions: Updated, Updating, Degraded or Unknown.
+	Status           string   `json:"status"`
+	MachineCount     int      `json:"machineCount"`
+	UpdatedCount     int      `json:"updatedCount"`
+	ReadyCount       int      `json:"readyCount"`
+	DegradedCount    int      `json:"degradedCount"`
+	UnavailableCount int      `json:"unavailableCount"`
+	Nodes            []string `json:"nodes,omitempty"`
+}
+
+// NodeReport is the update state of a node, as reported by its annotations
+// and its MachineConfigNode.
+type NodeReport struct {
+	Name          string `json:"name"`
+	Pool          string `json:"pool,omitempty"`
+	CurrentConfig string `json:"currentConfig,omitempty"`
+	DesiredConfig string `json:"desiredConfig,omitempty"`
+	CurrentImage  string `json:"currentImage,omitempty"`
+	DesiredImage  string `json:"desiredImage,omitempty"`
+	State         string `json:"state,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	// Updated is true if the node runs the configuration targeted by its pool.
+	Updated       bool `json:"updated"`
+	Ready         bool `json:"ready"`
+	Unavailable   bool `json:"unavailable"`
+	Unschedulable bool `json:"unschedulable"`
+	// PendingDrain is the drain or uncordon request of the MCD which was not
+	// applied yet by the controller.
+	PendingDrain string `json:"pendingDrain,omitempty"`
+	// MachineConfigNodeConditions are the true conditions of the MachineConfigNode.
+	MachineConfigNodeConditions []string `json:"machineConfigNodeConditions,omitempty"`
+}
+
+// Finding is a known failure signature found in the must-gather.
+type Finding struct {
+	Signature string `json:"signature"`
+	// Object is the object affected by the failure, e.g. node/worker-0.
+	Object   string   `json:"object"`
+	Message  string   `json:"message"`
+	Evidence []string `json:"evidence,omitempty"`
+}
+
+// Analyze reconstructs the per-pool and per-node update state of the
+// must-gather and flags the known failure signatures.
+func Analyze(mg *MustGather) (*Report, error) {
+	poolIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, pool := range mg.Pools {
+		if err := poolIndexer.Add(pool); err != nil {
+			return nil, err
+		}
+	}
+	poolLister := mcfglistersv1.NewMachineConfigPoolLister(poolIndexer)
+
+	mcns := map[string]*mcfgv1.MachineConfigNode{}
+	for _, mcn := range mg.MachineConfigNodes {
+		mcns[mcn.Name] = mcn
+	}
+
+	report := &Report{Pools: []PoolReport{}, Nodes: []NodeReport{}, Findings: []Finding{}}
+	nodesByPool := map[string][]*corev1.Node{}
+
+	nodes := append([]*corev1.Node{}, mg.Nodes...)
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	for _, node := range nodes {
+		pool, err := helpers.GetPrimaryPoolForNode(poolLister, node)
+		if err != nil {
+			klog.Warningf("Could not get the pool of node %s: %v", node.Name, err)
+		}
+
+		var mosc *mcfgv1.MachineOSConfig
+		var mosb *mcfgv1.MachineOSBuild
+		if pool != nil {
+			nodesByPool[pool.Name] = append(nodesByPool[pool.Name], node)
+			mosc, mosb = mg.layeredBuildForPool(pool)
+		}
+
+		report.Nodes = append(report.Nodes, newNodeReport(node, pool, mosc, mosb, mcns[node.Name]))
+	}
+
+	pools := append([]*mcfgv1.MachineConfigPool{}, mg.Pools...)
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Name < pools[j].Name })
+	for _, pool := range pools {
+		report.Pools = append(report.Pools, mg.newPoolReport(pool, nodesByPool[pool.Name]))
+	}
+
+	report.Findings = append(report.Findings, findBootImageSkew(mg.ClusterOperator, mg.MachineConfiguration)...)
+	for _, node := range nodes {
+		report.Findings = append(report.Findings, findNodeFailures(node, mg.DaemonLogs[node.Name], mg.ControllerLogs)...)
+	}
+
+	return report, nil
+}
+
+// layeredBuildForPool returns the MachineOSConfig of the pool and its
+// current MachineOSBuild, if the pool is layered.
+func (mg *MustGather) layeredBuildForPool(pool *mcfgv1.MachineConfigPool) (*mcfgv1.MachineOSConfig, *mcfgv1.MachineOSBuild) {
+	for _, mosc := range mg.MachineOSConfigs {
+		if mosc.Spec.MachineConfigPool.Name != pool.Name {
+			continue
+		}
+		mosbName := mosc.Annotations[buildconstants.CurrentMachineOSBuildAnnotationKey]
+		for _, mosb := range mg.MachineOSBuilds {
+			if mosb.Name == mosbName {
+				return mosc, mosb
+			}
+		}
+		return mosc, nil
+	}
+	return nil, nil
+}
+
+func (mg *MustGather) newPoolReport(pool *mcfgv1.MachineConfigPool, nodes []*corev1.Node) PoolReport {
+	mosc, mosb := mg.layeredBuildForPool(pool)
+	layered := mosc != nil
+	machines := ctrlcommon.GetMachinesByState(pool, nodes, mosc, mosb, layered)
+
+	pr := PoolReport{
+		Name:             pool.Name,
+		DesiredConfig:    pool.Spec.Configuration.Name,
+		Layered:          layered,
+		Paused:           pool.Spec.Paused,
+		Status:           poolStatus(pool),
+		MachineCount:     len(nodes),
+		UpdatedCount:     len(machines.Updated),
+		ReadyCount:       len(machines.Ready),
+		DegradedCount:    len(machines.Degraded),
+		UnavailableCount: len(machines.Unavailable),
+	}
+	for _, node := range nodes {
+		pr.Nodes = append(pr.Nodes, node.Name)
+	}
+	return pr
+}
+
+// poolStatus summarizes the conditions of a pool.
+func poolStatus(pool *mcfgv1.MachineConfigPool) string {
+	switch {
+	case apihelpers.IsMachineConfigPoolConditionTrue(pool.Status.Conditions, mcfgv1.MachineConfigPoolDegraded):
+		return "Degraded"
+	case apihelpers.IsMachineConfigPoolConditionTrue(pool.Status.Conditions, mcfgv1.MachineConfigPoolUpdating):
+		return "Updating"
+	case apihelpers.IsMachineConfigPoolConditionTrue(pool.Status.Conditions, mcfgv1.MachineConfigPoolUpdated):
+		return "Updated"
+	}
+	return "Unknown"
+}
+
+func newNodeReport(node *corev1.Node, pool *mcfgv1.MachineConfigPool, mosc *mcfgv1.MachineOSConfig, mosb *mcfgv1.MachineOSBuild, mcn *mcfgv1.MachineConfigNode) NodeReport {
+	lns := ctrlcommon.NewLayeredNodeState(node)
+	annos := node.Annotations
+
+	nr := NodeReport{
+		Name:          node.Name,
+		CurrentConfig: annos[daemonconsts.CurrentMachineConfigAnnotationKey],
+		DesiredConfig: annos[daemonconsts.DesiredMachineConfigAnnotationKey],
+		CurrentImage:  annos[daemonconsts.CurrentImageAnnotationKey],
+		DesiredImage:  annos[daemonconsts.DesiredImageAnnotationKey],
+		State:         annos[daemonconsts.MachineConfigDaemonStateAnnotationKey],
+		Reason:        annos[daemonconsts.MachineConfigDaemonReasonAnnotationKey],
+		Ready:         lns.IsNodeReady(),
+		Unavailable:   lns.IsUnavailableForUpdate(),
+		Unschedulable: node.Spec.Unschedulable,
+		PendingDrain:  pendingDrain(node),
+	}
+	if pool != nil {
+		nr.Pool = pool.Name
+		nr.Updated = lns.IsDone(pool, mosc != nil, mosc, mosb)
+	}
+	if mcn != nil {
+		for _, cond := range mcn.Status.Conditions {
+			if cond.Status == metav1.ConditionTrue {
+				nr.MachineConfigNodeConditions = append(nr.MachineConfigNodeConditions, cond.Type)
+			}
+		}
+	}
+	return nr
+}
+
+// pendingDrain returns the drain or uncordon request of the MCD which was not
+// applied yet by the controller.
+func pendingDrain(node *corev1.Node) string {
+	desired := node.Annotations[daemonconsts.DesiredDrainerAnnotationKey]
+	if desired == "" || desired == node.Annotations[daemonconsts.LastAppliedDrainerAnnotationKey] {
+		return ""
+	}
+	return desired
+}
+
+// findNodeFailures flags the failure signatures of a node, given the logs of
+// its MCD and the logs of the MCC.
+func findNodeFailures(node *corev1.Node, daemonLogs, controllerLogs []string) []Finding {
+	var findings []Finding
+	lns := ctrlcommon.NewLayeredNodeState(node)
+	object := "node/" + node.Name
+	reason := node.Annotations[daemonconsts.MachineConfigDaemonReasonAnnotationKey]
+
+	if lns.IsNodeUnreconcilable() {
+		findings = append(findings, Finding{
+			Signature: SignatureIrreconcilableDiff,
+			Object:    object,
+			Message:   fmt.Sprintf("the MCD cannot apply %s: %s", node.Annotations[daemonconsts.DesiredMachineConfigAnnotationKey], reason),
+			Evidence:  grepLines(daemonLogs, irreconcilableRe, ""),
+		})
+	}
+
+	if desired := pendingDrain(node); strings.HasPrefix(desired, daemonconsts.DrainerStateDrain) {
+		evidence := append(grepLines(daemonLogs, drainFailureRe, ""), grepLines(controllerLogs, drainFailureRe, node.Name)...)
+		if len(evidence) > 0 || drainFailureRe.MatchString(reason) {
+			findings = append(findings, Finding{
+				Signature: SignatureDrainStuck,
+				Object:    object,
+				Message:   fmt.Sprintf("the drain request %s was not completed", desired),
+				Evidence:  lastLines(evidence),
+			})
+		}
+	}
+
+	if finding := findLoggedFailure(SignatureConfigDrift, object, reason, daemonLogs, configDriftRe); finding != nil {
+		findings = append(findings, *finding)
+	}
+
+	if finalizeFailure := node.Annotations[daemonconsts.MachineConfigDaemonFinalizeFailureAnnotationKey]; finalizeFailure != "" {
+		findings = append(findings, Finding{
+			Signature: SignatureRpmOstreeFailure,
+			Object:    object,
+			Message:   fmt.Sprintf("ostree-finalize-staged failed: %s", finalizeFailure),
+			Evidence:  grepLines(daemonLogs, rpmOstreeFailedRe, ""),
+		})
+	} else if finding := findLoggedFailure(SignatureRpmOstreeFailure, object, reason, daemonLogs, rpmOstreeFailedRe); finding != nil {
+		findings = append(findings, *finding)
+	}
+
+	return findings
+}
+
+// findLoggedFailure flags a failure signature matching the reason of the MCD
+// state, or found in its logs only.
+func findLoggedFailure(signature, object, reason string, daemonLogs []string, re *regexp.Regexp) *Finding {
+	evidence := grepLines(daemonLogs, re, "")
+	switch {
+	case re.MatchString(reason):
+		return &Finding{Signature: signature, Object: object, Message: reason, Evidence: evidence}
+	case len(evidence) > 0:
+		return &Finding{Signature: signature, Object: object, Message: "found in the MCD logs", Evidence: evidence}
+	}
+	return nil
+}
+
+// findBootImageSkew flags the boot image skew reported by the operator, and
+// the failures to update the boot images which lead to it.
+func findBootImageSkew(co *configv1.ClusterOperator, mcop *opv1.MachineConfiguration) []Finding {
+	var findings []Finding
+	var evidence []string
+	if mcop != nil {
+		if version := bootImageVersion(mcop.Status.BootImageSkewEnforcementStatus); version != "" {
+			evidence = append(evidence, version)
+		}
+	}
+
+	if co != nil {
+		cond := v1helpers.FindStatusCondition(co.Status.Conditions, configv1.OperatorUpgradeable)
+		if cond != nil && cond.Status == configv1.ConditionFalse && cond.Reason == clusterBootImageSkewReason {
+			findings = append(findings, Finding{
+				Signature: SignatureBootImageSkew,
+				Object:    "clusteroperator/" + co.Name,
+				Message:   cond.Message,
+				Evidence:  evidence,
+			})
+		}
+	}
+
+	if mcop != nil {
+		cond := apimeta.FindStatusCondition(mcop.Status.Conditions, opv1.MachineConfigurationBootImageUpdateDegraded)
+		if cond != nil && cond.Status == metav1.ConditionTrue {
+			findings = append(findings, Finding{
+				Signature: SignatureBootImageSkew,
+				Object:    "machineconfiguration/" + mcop.Name,
+				Message:   fmt.Sprintf("boot image updates are failing: %s", cond.Message),
+				Evidence:  evidence,
+			})
+		}
+	}
+
+	return findings
+}
+
+// bootImageVersion describes the boot image version tracked for the skew enforcement.
+func bootImageVersion(status opv1.BootImageSkewEnforcementStatus) string {
+	var ocpVersion, rhcosVersion string
+	switch status.Mode {
+	case opv1.BootImageSkewEnforcementModeStatusAutomatic:
+		ocpVersion, rhcosVersion = status.Automatic.OCPVersion, status.Automatic.RHCOSVersion
+	case opv1.BootImageSkewEnforcementModeStatusManual:
+		ocpVersion, rhcosVersion = status.Manual.OCPVersion, status.Manual.RHCOSVersion
+	default:
+		return ""
+	}
+	if ocpVersion != "" {
+		return fmt.Sprintf("%s boot image: OCP %s (limit %s)", status.Mode, ocpVersion, ctrlcommon.OCPVersionBootImageSkewLimit)
+	}
+	return fmt.Sprintf("%s boot image: RHCOS %s (limit %s)", status.Mode, rhcosVersion, ctrlcommon.RHCOSVersionBootImageSkewLimit)
+}
+
+// grepLines returns the last lines matching re and containing substr.
+func grepLines(lines []string, re *regexp.Regexp, substr string) []string {
+	var matches []string
+	for _, line := range lines {
+		if strings.Contains(line, substr) && re.MatchString(line) {
+			matches = append(matches, strings.TrimSpace(line))
+		}
+	}
+	return lastLines(matches)
+}
+
+func lastLines(lines []string) []string {
+	if len(lines) > maxEvidence {
+		return lines[len(lines)-maxEvidence:]
+	}
+	return lines
+}
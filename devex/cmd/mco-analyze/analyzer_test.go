@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/test/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyze(t *testing.T) {
+	mg, err := LoadMustGather(testMustGatherPath)
+	require.NoError(t, err)
+
+	report, err := Analyze(mg)
+	require.NoError(t, err)
+
+	assert.Equal(t, []PoolReport{
+		{
+			Name:          "master",
+			DesiredConfig: "rendered-master-1",
+			Status:        "Updated",
+			MachineCount:  1,
+			UpdatedCount:  1,
+			ReadyCount:    1,
+			Nodes:         []string{"master-0"},
+		},
+		{
+			Name:             "worker",
+			DesiredConfig:    "rendered-worker-2",
+			Status:           "Degraded",
+			MachineCount:     4,
+			DegradedCount:    3,
+			UnavailableCount: 2,
+			Nodes:            []string{"worker-0", "worker-1", "worker-2", "worker-3"},
+		},
+	}, report.Pools)
+
+	require.Len(t, report.Nodes, 5)
+	worker1 := report.Nodes[2]
+	assert.Equal(t, "worker-1", worker1.Name)
+	assert.Equal(t, "worker", worker1.Pool)
+	assert.Equal(t, "Working", worker1.State)
+	assert.Equal(t, "drain-rendered-worker-2", worker1.PendingDrain)
+	assert.True(t, worker1.Unschedulable)
+	assert.False(t, worker1.Updated)
+	assert.Equal(t, []string{"UpdatePrepared", "Cordoned"}, worker1.MachineConfigNodeConditions)
+
+	type signatureObject struct{ signature, object string }
+	found := []signatureObject{}
+	for _, f := range report.Findings {
+		found = append(found, signatureObject{f.Signature, f.Object})
+	}
+	assert.Equal(t, []signatureObject{
+		{SignatureBootImageSkew, "clusteroperator/machine-config"},
+		{SignatureIrreconcilableDiff, "node/worker-0"},
+		{SignatureDrainStuck, "node/worker-1"},
+		{SignatureConfigDrift, "node/worker-2"},
+		{SignatureRpmOstreeFailure, "node/worker-3"},
+	}, found)
+
+	// only the controller logs about the node are kept as evidence
+	assert.Equal(t, []string{
+		"I1001 11:00:00.000000       1 drain_controller.go:419] node worker-1: drain exceeded timeout: 1h0m0s. Will continue to retry.",
+	}, report.Findings[2].Evidence)
+	assert.Equal(t, []string{"Manual boot image: OCP 4.12.0 (limit 4.13.0)"}, report.Findings[0].Evidence)
+}
+
+func TestFindNodeFailures(t *testing.T) {
+	testCases := []struct {
+		name       string
+		annos      map[string]string
+		daemonLogs []string
+		expected   []string
+	}{
+		{
+			name:     "healthy node",
+			annos:    map[string]string{},
+			expected: nil,
+		},
+		{
+			name: "pending drain without failure",
+			annos: map[string]string{
+				daemonconsts.DesiredDrainerAnnotationKey:     "drain-rendered-worker-2",
+				daemonconsts.LastAppliedDrainerAnnotationKey: "uncordon-rendered-worker-1",
+			},
+			expected: nil,
+		},
+		{
+			name: "drain failure reported by the MCD",
+			annos: map[string]string{
+				daemonconsts.DesiredDrainerAnnotationKey:            "drain-rendered-worker-2",
+				daemonconsts.MachineConfigDaemonStateAnnotationKey:  daemonconsts.MachineConfigDaemonStateDegraded,
+				daemonconsts.MachineConfigDaemonReasonAnnotationKey: "failed to drain node: worker-0 after 1 hour",
+			},
+			expected: []string{SignatureDrainStuck},
+		},
+		{
+			name: "drift in the logs only",
+			daemonLogs: []string{
+				`E1001 10:00:01.000000    2112 on_disk_validation.go:273] mode mismatch for file: "/etc/foo"`,
+			},
+			expected: []string{SignatureConfigDrift},
+		},
+		{
+			name: "ostree finalization failure",
+			annos: map[string]string{
+				daemonconsts.MachineConfigDaemonFinalizeFailureAnnotationKey: "error: Installing bootloader: exit status 1",
+			},
+			expected: []string{SignatureRpmOstreeFailure},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			node := helpers.NewNodeBuilder("worker-0").
+				WithEqualConfigs("rendered-worker-1").
+				WithMCDState(daemonconsts.MachineConfigDaemonStateDone).
+				WithAnnotations(tc.annos).
+				Node()
+
+			var signatures []string
+			for _, f := range findNodeFailures(node, tc.daemonLogs, nil) {
+				signatures = append(signatures, f.Signature)
+			}
+			assert.Equal(t, tc.expected, signatures)
+		})
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	mg, err := LoadMustGather(testMustGatherPath)
+	require.NoError(t, err)
+	report, err := Analyze(mg)
+	require.NoError(t, err)
+
+	var text bytes.Buffer
+	require.NoError(t, WriteReport(&text, report, outputFormatText))
+	assert.Contains(t, text.String(), "[DrainStuck] node/worker-1: the drain request drain-rendered-worker-2 was not completed")
+	assert.Regexp(t, `worker\s+Degraded\s+false\s+false\s+4\s+0\s+0\s+3\s+2\s+rendered-worker-2`, text.String())
+
+	var jsonOut bytes.Buffer
+	require.NoError(t, WriteReport(&jsonOut, report, outputFormatJSON))
+	decoded := &Report{}
+	require.NoError(t, json.Unmarshal(jsonOut.Bytes(), decoded))
+	assert.Equal(t, report, decoded)
+
+	assert.ErrorContains(t, WriteReport(&text, report, "yaml"), `unknown output format "yaml"`)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/component-base/cli"
+)
+
+func main() {
+	var inputPath string
+	var outputFormat string
+
+	rootCmd := &cobra.Command{
+		Use:   "mco-analyze",
+		Short: "Reconstructs the MCO update state of a must-gather and flags known failures",
+		Long:  "",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := validateOutputFormat(outputFormat); err != nil {
+				return err
+			}
+			mg, err := LoadMustGather(inputPath)
+			if err != nil {
+				return err
+			}
+			report, err := Analyze(mg)
+			if err != nil {
+				return err
+			}
+			return WriteReport(cmd.OutOrStdout(), report, outputFormat)
+		},
+	}
+
+	rootCmd.Flags().StringVar(&inputPath, "input", "", "Path to the must-gather directory.")
+	rootCmd.Flags().StringVar(&outputFormat, "output-format", outputFormatText, "Output format of the report: text or json.")
+	_ = rootCmd.MarkFlagRequired("input")
+
+	os.Exit(cli.Run(rootCmd))
+}
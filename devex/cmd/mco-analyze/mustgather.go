@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Paths of the resources read from a must-gather, relative to its root.
+const (
+	clusterScopedResourcesDir = "cluster-scoped-resources"
+	mcfgResourcesDir          = "cluster-scoped-resources/machineconfiguration.openshift.io"
+	nodesDir                  = "cluster-scoped-resources/core/nodes"
+	machineConfigurationPath  = "cluster-scoped-resources/operator.openshift.io/machineconfigurations/" + ctrlcommon.MCOOperatorKnobsObjectName + ".yaml"
+	clusterOperatorPath       = "cluster-scoped-resources/config.openshift.io/clusteroperators/machine-config.yaml"
+	mcoPodsDir                = "namespaces/" + ctrlcommon.MCONamespace + "/pods"
+
+	daemonContainerName     = "machine-config-daemon"
+	controllerContainerName = "machine-config-controller"
+)
+
+// MustGather holds the MCO state read from a must-gather.
+type MustGather struct {
+	Pools                []*mcfgv1.MachineConfigPool
+	Nodes                []*corev1.Node
+	MachineConfigNodes   []*mcfgv1.MachineConfigNode
+	MachineOSConfigs     []*mcfgv1.MachineOSConfig
+	MachineOSBuilds      []*mcfgv1.MachineOSBuild
+	MachineConfiguration *opv1.MachineConfiguration
+	ClusterOperator      *configv1.ClusterOperator
+	// DaemonLogs holds the lines of the machine-config-daemon logs, by node name.
+	DaemonLogs map[string][]string
+	// ControllerLogs holds the lines of the machine-config-controller logs.
+	ControllerLogs []string
+}
+
+// LoadMustGather reads the MCO state from the must-gather at path. path may
+// either be the must-gather root or the directory created by oc adm
+// must-gather, which holds the root in a subdirectory named after the image.
+func LoadMustGather(path string) (*MustGather, error) {
+	root, err := findMustGatherRoot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mg := &MustGather{}
+	if mg.Pools, err = readObjects[mcfgv1.MachineConfigPool](filepath.Join(root, mcfgResourcesDir, "machineconfigpools")); err != nil {
+		return nil, err
+	}
+	if mg.MachineConfigNodes, err = readObjects[mcfgv1.MachineConfigNode](filepath.Join(root, mcfgResourcesDir, "machineconfignodes")); err != nil {
+		return nil, err
+	}
+	if mg.MachineOSConfigs, err = readObjects[mcfgv1.MachineOSConfig](filepath.Join(root, mcfgResourcesDir, "machineosconfigs")); err != nil {
+		return nil, err
+	}
+	if mg.MachineOSBuilds, err = readObjects[mcfgv1.MachineOSBuild](filepath.Join(root, mcfgResourcesDir, "machineosbuilds")); err != nil {
+		return nil, err
+	}
+	if mg.Nodes, err = readObjects[corev1.Node](filepath.Join(root, nodesDir)); err != nil {
+		return nil, err
+	}
+	if mg.MachineConfiguration, err = readOptionalObject[opv1.MachineConfiguration](filepath.Join(root, machineConfigurationPath)); err != nil {
+		return nil, err
+	}
+	if mg.ClusterOperator, err = readOptionalObject[configv1.ClusterOperator](filepath.Join(root, clusterOperatorPath)); err != nil {
+		return nil, err
+	}
+	if mg.DaemonLogs, err = readPodLogs(filepath.Join(root, mcoPodsDir), daemonContainerName); err != nil {
+		return nil, err
+	}
+
+	controllerLogs, err := readPodLogs(filepath.Join(root, mcoPodsDir), controllerContainerName)
+	if err != nil {
+		return nil, err
+	}
+	for _, lines := range controllerLogs {
+		mg.ControllerLogs = append(mg.ControllerLogs, lines...)
+	}
+
+	return mg, nil
+}
+
+// findMustGatherRoot returns the directory holding the resources of the
+// must-gather at path.
+func findMustGatherRoot(path string) (string, error) {
+	for _, pattern := range []string{"", "*", filepath.Join("*", "*")} {
+		matches, err := filepath.Glob(filepath.Join(path, pattern, clusterScopedResourcesDir))
+		if err != nil {
+			return "", err
+		}
+		switch len(matches) {
+		case 0:
+			continue
+		case 1:
+			return filepath.Dir(matches[0]), nil
+		default:
+			return "", fmt.Errorf("found %d must-gathers in %s, please select one of them", len(matches), path)
+		}
+	}
+	return "", fmt.Errorf("no must-gather found in %s", path)
+}
+
+// readObjects decodes the YAML or JSON files of dir, which must each hold a
+// single object. Returns no objects if dir does not exist.
+func readObjects[T any](dir string) ([]*T, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*T
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		obj, err := readObject[T](filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// readOptionalObject decodes the object at path, returning nil if the file
+// does not exist.
+func readOptionalObject[T any](path string) (*T, error) {
+	obj, err := readObject[T](path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return obj, err
+}
+
+func readObject[T any](path string) (*T, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	obj := new(T)
+	if err := yaml.Unmarshal(content, obj); err != nil {
+		return nil, fmt.Errorf("could not decode %s: %w", path, err)
+	}
+	return obj, nil
+}
+
+// readPodLogs returns the lines logged by the given container of the pods
+// of podsDir, by the name of the node the pods ran on. The logs of the
+// previous instance of a container come first.
+func readPodLogs(podsDir, container string) (map[string][]string, error) {
+	entries, err := os.ReadDir(podsDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string][]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logs := map[string][]string{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), container+"-") {
+			continue
+		}
+		podDir := filepath.Join(podsDir, entry.Name())
+
+		nodeName := entry.Name()
+		pod, err := readOptionalObject[corev1.Pod](filepath.Join(podDir, entry.Name()+".yaml"))
+		if err != nil {
+			return nil, err
+		}
+		if pod != nil && pod.Spec.NodeName != "" {
+			nodeName = pod.Spec.NodeName
+		}
+
+		for _, logFile := range []string{"previous.log", "current.log"} {
+			lines, err := readLines(filepath.Join(podDir, container, container, "logs", logFile))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			logs[nodeName] = append(logs[nodeName], lines...)
+		}
+	}
+	return logs, nil
+}
+
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	// MCD logs may hold long lines, such as rpm-ostree outputs
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	return lines, nil
+}
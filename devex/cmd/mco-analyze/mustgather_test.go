@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMustGatherPath = "testdata/must-gather"
+
+func TestLoadMustGather(t *testing.T) {
+	mg, err := LoadMustGather(testMustGatherPath)
+	require.NoError(t, err)
+
+	assert.Len(t, mg.Pools, 2)
+	assert.Len(t, mg.Nodes, 5)
+	assert.Len(t, mg.MachineConfigNodes, 1)
+	require.NotNil(t, mg.ClusterOperator)
+	assert.Equal(t, "machine-config", mg.ClusterOperator.Name)
+	require.NotNil(t, mg.MachineConfiguration)
+	assert.Equal(t, "4.12.0", mg.MachineConfiguration.Status.BootImageSkewEnforcementStatus.Manual.OCPVersion)
+
+	// logs are keyed by the node of the pod, previous logs first
+	assert.Len(t, mg.DaemonLogs, 3)
+	require.Len(t, mg.DaemonLogs["worker-3"], 3)
+	assert.Contains(t, mg.DaemonLogs["worker-3"][0], "Version: 4.20.0")
+	assert.Len(t, mg.ControllerLogs, 4)
+}
+
+func TestFindMustGatherRoot(t *testing.T) {
+	root, err := findMustGatherRoot(testMustGatherPath)
+	require.NoError(t, err)
+	assert.Equal(t, "quay-io-openshift-release-dev-ocp-v4-0-art-dev-sha256-0123abcd", filepath.Base(root))
+
+	// the root itself can be given
+	sameRoot, err := findMustGatherRoot(root)
+	require.NoError(t, err)
+	assert.Equal(t, root, sameRoot)
+
+	_, err = findMustGatherRoot(t.TempDir())
+	assert.ErrorContains(t, err, "no must-gather found")
+}
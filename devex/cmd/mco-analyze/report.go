@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats of the report.
+const (
+	outputFormatText = "text"
+	outputFormatJSON = "json"
+)
+
+// validateOutputFormat returns an error if the report cannot be written in format.
+func validateOutputFormat(format string) error {
+	if format != outputFormatText && format != outputFormatJSON {
+		return fmt.Errorf("unknown output format %q, must be one of %s, %s", format, outputFormatText, outputFormatJSON)
+	}
+	return nil
+}
+
+// WriteReport writes the report to w in the given format.
+func WriteReport(w io.Writer, report *Report, format string) error {
+	if err := validateOutputFormat(format); err != nil {
+		return err
+	}
+	if format == outputFormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	return writeTextReport(w, report)
+}
+
+func writeTextReport(w io.Writer, report *Report) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "POOLS")
+	fmt.Fprintln(tw, "NAME\tSTATUS\tPAUSED\tLAYERED\tMACHINES\tUPDATED\tREADY\tDEGRADED\tUNAVAILABLE\tDESIRED CONFIG")
+	for _, pool := range report.Pools {
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%t\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			pool.Name, pool.Status, pool.Paused, pool.Layered, pool.MachineCount, pool.UpdatedCount,
+			pool.ReadyCount, pool.DegradedCount, pool.UnavailableCount, pool.DesiredConfig)
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "NODES")
+	fmt.Fprintln(tw, "NAME\tPOOL\tSTATE\tUPDATED\tREADY\tUNSCHEDULABLE\tPENDING DRAIN\tCURRENT CONFIG\tDESIRED CONFIG")
+	for _, node := range report.Nodes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%t\t%t\t%s\t%s\t%s\n",
+			node.Name, orNone(node.Pool), orNone(node.State), node.Updated, node.Ready, node.Unschedulable,
+			orNone(node.PendingDrain), orNone(node.CurrentConfig), orNone(node.DesiredConfig))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "FINDINGS")
+	if len(report.Findings) == 0 {
+		fmt.Fprintln(w, "No known failure signature found")
+	}
+	for _, finding := range report.Findings {
+		fmt.Fprintf(w, "[%s] %s: %s\n", finding.Signature, finding.Object, finding.Message)
+		for _, line := range finding.Evidence {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+
+	// Reasons and images are too long for the node table, so they are listed apart
+	var details []string
+	for _, node := range report.Nodes {
+		if node.Reason != "" {
+			details = append(details, fmt.Sprintf("%s: reason: %s", node.Name, node.Reason))
+		}
+		if node.CurrentImage != "" || node.DesiredImage != "" {
+			details = append(details, fmt.Sprintf("%s: current image %s, desired image %s", node.Name, orNone(node.CurrentImage), orNone(node.DesiredImage)))
+		}
+	}
+	if len(details) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "NODE DETAILS")
+		fmt.Fprintln(w, strings.Join(details, "\n"))
+	}
+
+	return nil
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
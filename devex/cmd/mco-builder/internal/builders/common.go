@@ -16,6 +16,7 @@ type BuilderType string
 const (
 	BuilderTypePodman    BuilderType = "podman"
 	BuilderTypeDocker    BuilderType = "docker"
+	BuilderTypeBuildah   BuilderType = "buildah"
 	BuilderTypeOpenshift BuilderType = "openshift"
 	BuilderTypeUnknown   BuilderType = "unknown-builder-type"
 )
@@ -54,6 +55,25 @@ func GetLocalBuilderTypes() sets.Set[BuilderType] {
 	return sets.New[BuilderType](BuilderTypePodman, BuilderTypeDocker)
 }
 
+// Gets the builders which may be used to build layered OS images locally.
+// These must support the RUN --mount options used by the on-cluster build
+// Containerfile.
+func GetLayeredBuilderTypes() sets.Set[BuilderType] {
+	return sets.New[BuilderType](BuilderTypePodman, BuilderTypeBuildah)
+}
+
+func GetDefaultLayeredBuilderType() BuilderType {
+	if _, err := exec.LookPath("podman"); err == nil {
+		return BuilderTypePodman
+	}
+
+	if _, err := exec.LookPath("buildah"); err == nil {
+		return BuilderTypeBuildah
+	}
+
+	return BuilderTypeUnknown
+}
+
 func GetDefaultBuilderTypeForPlatform() BuilderType {
 	if _, err := exec.LookPath("podman"); err == nil {
 		return BuilderTypePodman
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/openshift/machine-config-operator/devex/cmd/mco-builder/internal/builders"
+	"github.com/openshift/machine-config-operator/devex/internal/pkg/containers"
+	"github.com/openshift/machine-config-operator/devex/internal/pkg/utils"
+	"github.com/openshift/machine-config-operator/lib/resourceread"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/buildrequest"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultLayeredImageTag string = "localhost/layered-os-image:latest"
+)
+
+type layeredBuildOpts struct {
+	builderKind              string // builders.BuilderType
+	machineConfigPath        string
+	machineOSConfigPath      string
+	containerfilePath        string
+	baseImage                string
+	extensionsImage          string
+	extensions               []string
+	poolName                 string
+	arch                     string
+	tag                      string
+	pullSecretPath           string
+	finalImagePushSecretPath string
+	finalImagePullspec       string
+	exportDir                string
+	renderOnly               bool
+}
+
+func (l *layeredBuildOpts) getBuilderType() builders.BuilderType {
+	return builders.BuilderType(l.builderKind)
+}
+
+func (l *layeredBuildOpts) validate() error {
+	if l.machineConfigPath == "" {
+		return fmt.Errorf("--machineconfig must be provided")
+	}
+
+	if _, err := os.Stat(l.machineConfigPath); err != nil {
+		return err
+	}
+
+	for _, path := range []string{l.machineOSConfigPath, l.containerfilePath, l.pullSecretPath} {
+		if path == "" {
+			continue
+		}
+
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+	}
+
+	if l.renderOnly {
+		if l.exportDir == "" {
+			return fmt.Errorf("--export-dir must be provided when using --render-only")
+		}
+
+		if l.finalImagePullspec != "" {
+			return fmt.Errorf("--final-image-pullspec may not be used with --render-only")
+		}
+
+		return nil
+	}
+
+	layeredBuilderTypes := builders.GetLayeredBuilderTypes()
+	if !layeredBuilderTypes.Has(l.getBuilderType()) {
+		return fmt.Errorf("invalid builder type %s, valid builder types: %v", l.getBuilderType(), sets.List(layeredBuilderTypes))
+	}
+
+	if err := utils.CheckForBinaries([]string{l.builderKind}); err != nil {
+		return err
+	}
+
+	if l.finalImagePullspec == "" {
+		if l.finalImagePushSecretPath != "" {
+			return fmt.Errorf("--push-secret may only be used with --final-image-pullspec")
+		}
+
+		return nil
+	}
+
+	if l.finalImagePushSecretPath == "" {
+		return fmt.Errorf("--push-secret must be provided when using --final-image-pullspec")
+	}
+
+	if _, err := os.Stat(l.finalImagePushSecretPath); err != nil {
+		return err
+	}
+
+	parsedPullspec, err := containers.AddLatestTagIfMissing(l.finalImagePullspec)
+	if err != nil {
+		return fmt.Errorf("could not parse final image pullspec %q: %w", l.finalImagePullspec, err)
+	}
+
+	l.finalImagePullspec = parsedPullspec
+
+	return nil
+}
+
+func init() {
+	opts := layeredBuildOpts{}
+
+	layeredCmd := &cobra.Command{
+		Use:   "layered",
+		Short: "Builds a layered OS image locally from a MachineConfig without a cluster.",
+		Long:  "Renders the same Containerfile and build context that on-cluster layering uses from a MachineConfig and an optional MachineOSConfig, then builds it locally using Buildah or Podman. The rendered build context may be exported for inspection.",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if err := opts.validate(); err != nil {
+				return err
+			}
+			return runLayeredCmd(opts)
+		},
+	}
+
+	layeredCmd.PersistentFlags().StringVar(&opts.machineConfigPath, "machineconfig", "", "Path to a (rendered) MachineConfig YAML or JSON file")
+	layeredCmd.PersistentFlags().StringVar(&opts.machineOSConfigPath, "machineosconfig", "", "Path to a MachineOSConfig YAML or JSON file to take the pool name and Containerfile(s) from")
+	layeredCmd.PersistentFlags().StringVar(&opts.containerfilePath, "containerfile", "", "Path to a Containerfile to layer on top; replaces any Containerfile(s) from the MachineOSConfig")
+	layeredCmd.PersistentFlags().StringVar(&opts.baseImage, "base-image", "", "Base OS image pullspec; overrides the osImageURL from the MachineConfig")
+	layeredCmd.PersistentFlags().StringVar(&opts.extensionsImage, "extensions-image", "", "Base OS extensions image pullspec; overrides the baseOSExtensionsContainerImage from the MachineConfig")
+	layeredCmd.PersistentFlags().StringSliceVar(&opts.extensions, "extensions", nil, "Additional extensions to install, in addition to those in the MachineConfig")
+	layeredCmd.PersistentFlags().StringVar(&opts.poolName, "pool", "worker", "MachineConfigPool name to label the image with when no MachineOSConfig is provided")
+	layeredCmd.PersistentFlags().StringVar(&opts.arch, "arch", runtime.GOARCH, "Architecture to build the image for")
+	layeredCmd.PersistentFlags().StringVar(&opts.tag, "tag", defaultLayeredImageTag, "Local tag for the built image")
+	layeredCmd.PersistentFlags().StringVar(&opts.pullSecretPath, "pull-secret", "", "Path to the pull secret needed to pull the base OS and extensions images")
+	layeredCmd.PersistentFlags().StringVar(&opts.finalImagePushSecretPath, "push-secret", "", "Path to the push secret needed to push to the provided pullspec")
+	layeredCmd.PersistentFlags().StringVar(&opts.finalImagePullspec, "final-image-pullspec", "", "Where to push the built image; the image is only pushed when provided")
+	layeredCmd.PersistentFlags().StringVar(&opts.exportDir, "export-dir", "", "Directory to write the rendered build context into; it is kept after the build")
+	layeredCmd.PersistentFlags().BoolVar(&opts.renderOnly, "render-only", false, "Renders the build context into --export-dir without building it")
+	layeredCmd.PersistentFlags().StringVar(&opts.builderKind, "builder", string(builders.GetDefaultLayeredBuilderType()), fmt.Sprintf("What image builder to use: %v", sets.List(builders.GetLayeredBuilderTypes())))
+
+	rootCmd.AddCommand(layeredCmd)
+}
+
+func runLayeredCmd(opts layeredBuildOpts) error {
+	buildOpts, err := getLayeredBuildRequestOpts(opts)
+	if err != nil {
+		return err
+	}
+
+	bc, err := buildrequest.NewBuildContext(*buildOpts)
+	if err != nil {
+		return fmt.Errorf("could not render build context: %w", err)
+	}
+
+	contextDir := opts.exportDir
+	if contextDir == "" {
+		tmpDir, err := os.MkdirTemp("", "mco-layered-build-context")
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if err := os.RemoveAll(tmpDir); err != nil {
+				klog.Warningf("Could not remove build context %s: %s", tmpDir, err)
+			}
+		}()
+
+		contextDir = tmpDir
+	}
+
+	if err := bc.WriteToDir(contextDir); err != nil {
+		return err
+	}
+
+	klog.Infof("Build context written to %s", contextDir)
+
+	if opts.renderOnly {
+		klog.Infof("Skipping build since --render-only was used")
+		return nil
+	}
+
+	if err := buildLayeredImage(opts, contextDir, bc.ContainerfilePath(contextDir)); err != nil {
+		return fmt.Errorf("could not build layered image: %w", err)
+	}
+
+	klog.Infof("Built layered image %s", opts.tag)
+
+	if opts.finalImagePullspec == "" {
+		return nil
+	}
+
+	if err := pushLayeredImage(opts); err != nil {
+		return fmt.Errorf("could not push layered image: %w", err)
+	}
+
+	digestedPullspec, err := containers.ResolveToDigestedPullspec(opts.finalImagePullspec, opts.finalImagePushSecretPath)
+	if err != nil {
+		return fmt.Errorf("could not resolve %s to digested image pullspec: %w", opts.finalImagePullspec, err)
+	}
+
+	klog.Infof("Pushed image has digested pullspec %s", digestedPullspec)
+
+	return nil
+}
+
+// Assembles the options needed to render the build context from the files and
+// overrides provided on the command line. The MachineOSBuild is synthesized
+// since it only supplies names and labels to the rendered Containerfile.
+func getLayeredBuildRequestOpts(opts layeredBuildOpts) (*buildrequest.BuildRequestOpts, error) {
+	mcBytes, err := os.ReadFile(opts.machineConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	mc, err := resourceread.ReadMachineConfigV1(mcBytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not read MachineConfig from %s: %w", opts.machineConfigPath, err)
+	}
+
+	if opts.baseImage != "" {
+		mc.Spec.OSImageURL = opts.baseImage
+	}
+
+	if opts.extensionsImage != "" {
+		mc.Spec.BaseOSExtensionsContainerImage = opts.extensionsImage
+	}
+
+	mc.Spec.Extensions = sets.List(sets.New[string](mc.Spec.Extensions...).Insert(opts.extensions...))
+
+	if mc.Spec.OSImageURL == "" {
+		return nil, fmt.Errorf("MachineConfig %s has no osImageURL, --base-image must be provided", mc.Name)
+	}
+
+	if len(mc.Spec.Extensions) != 0 && mc.Spec.BaseOSExtensionsContainerImage == "" {
+		return nil, fmt.Errorf("MachineConfig %s has extensions but no baseOSExtensionsContainerImage, --extensions-image must be provided", mc.Name)
+	}
+
+	mosc, err := getLayeredMachineOSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	mosb := &mcfgv1.MachineOSBuild{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("%s-local", mosc.Name),
+		},
+		Spec: mcfgv1.MachineOSBuildSpec{
+			MachineConfig: mcfgv1.MachineConfigReference{
+				Name: mc.Name,
+			},
+			MachineOSConfig: mcfgv1.MachineOSConfigReference{
+				Name: mosc.Name,
+			},
+			RenderedImagePushSpec: mcfgv1.ImageTagFormat(opts.tag),
+		},
+	}
+
+	return &buildrequest.BuildRequestOpts{
+		MachineConfig:   mc,
+		MachineOSConfig: mosc,
+		MachineOSBuild:  mosb,
+		Architectures:   []string{opts.arch},
+	}, nil
+}
+
+// Reads the MachineOSConfig if one was provided, otherwise constructs one for
+// the pool given on the command line. A Containerfile provided on the command
+// line replaces any from the MachineOSConfig.
+func getLayeredMachineOSConfig(opts layeredBuildOpts) (*mcfgv1.MachineOSConfig, error) {
+	mosc := &mcfgv1.MachineOSConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: opts.poolName,
+		},
+		Spec: mcfgv1.MachineOSConfigSpec{
+			MachineConfigPool: mcfgv1.MachineConfigPoolReference{
+				Name: opts.poolName,
+			},
+		},
+	}
+
+	if opts.machineOSConfigPath != "" {
+		moscBytes, err := os.ReadFile(opts.machineOSConfigPath)
+		if err != nil {
+			return nil, err
+		}
+
+		mosc = &mcfgv1.MachineOSConfig{}
+		if err := yaml.Unmarshal(moscBytes, mosc); err != nil {
+			return nil, fmt.Errorf("could not read MachineOSConfig from %s: %w", opts.machineOSConfigPath, err)
+		}
+	}
+
+	if opts.containerfilePath != "" {
+		containerfile, err := os.ReadFile(opts.containerfilePath)
+		if err != nil {
+			return nil, err
+		}
+
+		mosc.Spec.Containerfile = []mcfgv1.MachineOSContainerfile{
+			{
+				ContainerfileArch: mcfgv1.NoArch,
+				Content:           string(containerfile),
+			},
+		}
+	}
+
+	return mosc, nil
+}
+
+func buildLayeredImage(opts layeredBuildOpts, contextDir, containerfilePath string) error {
+	buildOpts := []string{"build", "--tag", opts.tag, "--platform", "linux/" + opts.arch, "--file", containerfilePath}
+	if opts.pullSecretPath != "" {
+		buildOpts = append(buildOpts, "--authfile", opts.pullSecretPath)
+	}
+
+	buildOpts = append(buildOpts, contextDir)
+
+	cmd := exec.Command(opts.builderKind, buildOpts...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	klog.Infof("Running %s", cmd)
+	return cmd.Run()
+}
+
+func pushLayeredImage(opts layeredBuildOpts) error {
+	cmd := exec.Command(opts.builderKind, "push", "--authfile", opts.finalImagePushSecretPath, opts.tag, "docker://"+opts.finalImagePullspec)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	klog.Infof("Running %s", cmd)
+	return cmd.Run()
+}
@@ -33,6 +33,12 @@ type ConfigRedact struct {
 	// For example: "spec.containers.0.env.0.value" or "data.password".
 	// Array elements can be referenced by index or all elements will be processed if * is given.
 	Paths []string `yaml:"paths"`
+
+	// Detectors lists the built-in detectors ("certificate", "hostname", "ip", "pull-secret") used to find
+	// the sensitive values inside the fields selected by Paths. When set, only the values found are replaced,
+	// instead of the whole fields. Data URLs, like the ones of Ignition file contents, and base64 strings are
+	// decoded before looking for sensitive values.
+	Detectors []string `yaml:"detectors,omitempty"`
 }
 
 // Config represents the complete sanitizer configuration containing redaction rules.
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/vincent-petithory/dataurl"
+)
+
+// Detector finds sensitive values inside the text of a field, such as the
+// contents of an Ignition file.
+type Detector interface {
+	// Kind names the values found by the detector, e.g. "ip".
+	Kind() string
+
+	// Replace returns the text with each sensitive value replaced by the
+	// result of replace.
+	Replace(text string, replace func(value string) string) string
+}
+
+// builtinDetectors are the detectors that can be referenced by name in the
+// redact configuration, by decreasing priority: a PEM block is replaced as a
+// whole before the hostnames it may contain are looked for.
+var builtinDetectors = []Detector{
+	newCertificateDetector(),
+	newPullSecretDetector(),
+	newIPDetector(),
+	newHostnameDetector(),
+}
+
+// NewDetectors returns the built-in detectors with the given names, ordered
+// by priority. Returns an error if a name is unknown.
+func NewDetectors(names []string) ([]Detector, error) {
+	var detectors []Detector
+	for _, name := range names {
+		found := false
+		for _, d := range builtinDetectors {
+			if d.Kind() == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown detector %q, must be one of %s", name, strings.Join(detectorNames(), ", "))
+		}
+	}
+	for _, d := range builtinDetectors {
+		for _, name := range names {
+			if d.Kind() == name {
+				detectors = append(detectors, d)
+				break
+			}
+		}
+	}
+	return detectors, nil
+}
+
+// regexDetector finds the sensitive values matching a regular expression.
+type regexDetector struct {
+	kind string
+	re   *regexp.Regexp
+	// group is the index of the submatch holding the sensitive value, 0 for
+	// the whole match.
+	group int
+	// accept filters out false positives, if set.
+	accept func(value string) bool
+}
+
+func (d *regexDetector) Kind() string {
+	return d.kind
+}
+
+func (d *regexDetector) Replace(text string, replace func(value string) string) string {
+	matches := d.re.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[2*d.group], m[2*d.group+1]
+		if start < 0 {
+			continue
+		}
+		value := text[start:end]
+		if d.accept != nil && !d.accept(value) {
+			continue
+		}
+		sb.WriteString(text[last:start])
+		sb.WriteString(replace(value))
+		last = end
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// newCertificateDetector finds PEM blocks, such as certificates and private keys.
+func newCertificateDetector() Detector {
+	return &regexDetector{
+		kind: "certificate",
+		re:   regexp.MustCompile(`-----BEGIN [A-Z0-9 ]+-----[\s\S]*?-----END [A-Z0-9 ]+-----`),
+	}
+}
+
+// newPullSecretDetector finds the credentials of the registries in a pull
+// secret, or any other docker config.
+func newPullSecretDetector() Detector {
+	return &regexDetector{
+		kind:  "pull-secret",
+		re:    regexp.MustCompile(`"(?:auth|password|identitytoken|registrytoken)"\s*:\s*"([^"\\]+)"`),
+		group: 1,
+	}
+}
+
+// newIPDetector finds IPv4 and IPv6 addresses, except loopback and unspecified ones.
+func newIPDetector() Detector {
+	return &regexDetector{
+		kind: "ip",
+		re:   regexp.MustCompile(`(?i)\b(?:\d{1,3}\.){3}\d{1,3}\b|(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4}`),
+		accept: func(value string) bool {
+			ip := net.ParseIP(value)
+			if ip == nil || ip.IsLoopback() || ip.IsUnspecified() {
+				return false
+			}
+			// Ignore things like scope operators ("std::max") that happen to
+			// be valid IPv6 addresses.
+			if strings.Contains(value, ":") {
+				groups := 0
+				for _, g := range strings.Split(value, ":") {
+					if g != "" {
+						groups++
+					}
+				}
+				return groups >= 2
+			}
+			return true
+		},
+	}
+}
+
+// publicDomains are the domains of well-known services, which are not
+// specific to a cluster.
+var publicDomains = []string{
+	"cluster.local",
+	"docker.io",
+	"example.com",
+	"fedoraproject.org",
+	"github.com",
+	"k8s.io",
+	"kubernetes.io",
+	"openshift.com",
+	"openshift.io",
+	"quay.io",
+	"redhat.com",
+	"redhat.io",
+}
+
+// fileExtensions are suffixes that make file names look like hostnames.
+var fileExtensions = map[string]struct{}{
+	"cfg": {}, "conf": {}, "crt": {}, "service": {}, "json": {}, "key": {}, "log": {}, "mount": {},
+	"pem": {}, "path": {}, "sh": {}, "socket": {}, "target": {}, "timer": {}, "txt": {}, "yaml": {}, "yml": {},
+}
+
+// newHostnameDetector finds fully qualified domain names with at least three
+// labels, except the ones of well-known public services.
+func newHostnameDetector() Detector {
+	return &regexDetector{
+		kind: "hostname",
+		re:   regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.){2,}[a-z]{2,63}\b`),
+		accept: func(value string) bool {
+			value = strings.ToLower(value)
+			if _, ok := fileExtensions[value[strings.LastIndex(value, ".")+1:]]; ok {
+				return false
+			}
+			for _, domain := range publicDomains {
+				if value == domain || strings.HasSuffix(value, "."+domain) {
+					return false
+				}
+			}
+			return true
+		},
+	}
+}
+
+// scrubValue replaces the sensitive values found by the detectors in every
+// string of value, which can be a string, a map or an array.
+// Returns the scrubbed value and true if anything was replaced.
+func scrubValue(value interface{}, detectors []Detector, replacer Replacer) (interface{}, bool) {
+	switch val := value.(type) {
+	case string:
+		scrubbed := scrubString(val, detectors, replacer)
+		return scrubbed, scrubbed != val
+	case map[string]interface{}:
+		changed := false
+		for k, v := range val {
+			scrubbed, c := scrubValue(v, detectors, replacer)
+			if c {
+				val[k] = scrubbed
+				changed = true
+			}
+		}
+		return val, changed
+	case []interface{}:
+		changed := false
+		for i, v := range val {
+			scrubbed, c := scrubValue(v, detectors, replacer)
+			if c {
+				val[i] = scrubbed
+				changed = true
+			}
+		}
+		return val, changed
+	}
+	return value, false
+}
+
+// scrubString replaces the sensitive values of a string. Data URLs, like the
+// ones of Ignition file contents, and base64 strings are decoded first, and
+// encoded back the same way once scrubbed.
+func scrubString(s string, detectors []Detector, replacer Replacer) string {
+	if strings.HasPrefix(s, "data:") {
+		if du, err := dataurl.DecodeString(s); err == nil {
+			scrubbed, changed := scrubBytes(du.Data, detectors, replacer)
+			if !changed {
+				return s
+			}
+			header := s[:strings.Index(s, ",")+1]
+			if du.Encoding == dataurl.EncodingBase64 {
+				return header + base64.StdEncoding.EncodeToString(scrubbed)
+			}
+			return header + dataurl.EscapeString(string(scrubbed))
+		}
+	}
+
+	if decoded, ok := decodeBase64(s); ok {
+		scrubbed, changed := scrubBytes(decoded, detectors, replacer)
+		if !changed {
+			return s
+		}
+		return base64.StdEncoding.EncodeToString(scrubbed)
+	}
+
+	return scrubText(s, detectors, replacer)
+}
+
+// scrubBytes replaces the sensitive values of decoded content, decompressing
+// it first if it is gzipped. Binary content is left untouched.
+func scrubBytes(data []byte, detectors []Detector, replacer Replacer) ([]byte, bool) {
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return data, false
+		}
+		decompressed, err := io.ReadAll(zr)
+		if err != nil {
+			return data, false
+		}
+		scrubbed, changed := scrubBytes(decompressed, detectors, replacer)
+		if !changed {
+			return data, false
+		}
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(scrubbed); err != nil {
+			return data, false
+		}
+		if err := zw.Close(); err != nil {
+			return data, false
+		}
+		return buf.Bytes(), true
+	}
+
+	if !isText(data) {
+		return data, false
+	}
+	scrubbed := scrubText(string(data), detectors, replacer)
+	return []byte(scrubbed), scrubbed != string(data)
+}
+
+func scrubText(text string, detectors []Detector, replacer Replacer) string {
+	for _, d := range detectors {
+		kind := d.Kind()
+		text = d.Replace(text, func(value string) string {
+			return replacer.ReplaceMatch(kind, value)
+		})
+	}
+	return text
+}
+
+// base64Re matches strings that may be standard base64 encoded content.
+// Short strings are ignored as they are likely plain words.
+var base64Re = regexp.MustCompile(`^(?:[A-Za-z0-9+/]{4}){2,}(?:[A-Za-z0-9+/]{2}==|[A-Za-z0-9+/]{3}=)?$`)
+
+// decodeBase64 returns the decoded content of s if it is base64 encoded text
+// or gzipped content.
+func decodeBase64(s string) ([]byte, bool) {
+	if !base64Re.MatchString(s) {
+		return nil, false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, false
+	}
+	if len(decoded) > 2 && decoded[0] == 0x1f && decoded[1] == 0x8b {
+		return decoded, true
+	}
+	return decoded, isText(decoded)
+}
+
+// isText returns true if data is printable UTF-8 text.
+func isText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// detectorNames returns the names of the built-in detectors.
+func detectorNames() []string {
+	names := make([]string, 0, len(builtinDetectors))
+	for _, d := range builtinDetectors {
+		names = append(names, d.Kind())
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vincent-petithory/dataurl"
+)
+
+const testCertificate = `-----BEGIN CERTIFICATE-----
+MIIBszCCAVmgAwIBAgIUW8zmcBm0fL3fHhZ6ybmSRBD3F0YwCgYIKoZIzj0EAwIw
+-----END CERTIFICATE-----`
+
+// kindReplacer replaces the detected values with their kind, to make the
+// expectations readable.
+type kindReplacer struct{}
+
+func (kindReplacer) ReplaceField(value string) interface{} { return "FIELD" }
+
+func (kindReplacer) ReplaceMatch(kind, _ string) string { return fmt.Sprintf("<%s>", kind) }
+
+func TestNewDetectors(t *testing.T) {
+	detectors, err := NewDetectors([]string{"hostname", "ip", "certificate"})
+	require.NoError(t, err)
+
+	kinds := []string{}
+	for _, d := range detectors {
+		kinds = append(kinds, d.Kind())
+	}
+	// ordered by priority
+	assert.Equal(t, []string{"certificate", "ip", "hostname"}, kinds)
+
+	_, err = NewDetectors([]string{"ip", "email"})
+	assert.ErrorContains(t, err, `unknown detector "email"`)
+}
+
+func TestDetectors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		detector string
+		input    string
+		expected string
+	}{
+		{
+			name:     "ipv4",
+			detector: "ip",
+			input:    "server 10.0.12.5 iburst\nallow 192.168.1.0/24",
+			expected: "server <ip> iburst\nallow <ip>/24",
+		},
+		{
+			name:     "ipv6",
+			detector: "ip",
+			input:    "nameserver fd00:10:128::a\nnameserver 2001:db8::1",
+			expected: "nameserver <ip>\nnameserver <ip>",
+		},
+		{
+			name:     "ip false positives",
+			detector: "ip",
+			input:    "listen 127.0.0.1 and 0.0.0.0 and ::1, version 999.1.2.3 at 10:20:30, std::max, aa:bb:cc:dd:ee:ff",
+			expected: "listen 127.0.0.1 and 0.0.0.0 and ::1, version 999.1.2.3 at 10:20:30, std::max, aa:bb:cc:dd:ee:ff",
+		},
+		{
+			name:     "hostnames",
+			detector: "hostname",
+			input:    "server: https://api-int.mycluster.corp.acme.net:6443\nhost master-0.mycluster.corp.acme.net",
+			expected: "server: https://<hostname>:6443\nhost <hostname>",
+		},
+		{
+			name:     "hostname false positives",
+			detector: "hostname",
+			input:    "quay.io/openshift-release-dev/ocp-release machineconfiguration.openshift.io/role /etc/kubernetes/kubelet.conf 99-master.generated.yaml kubernetes.default.svc.cluster.local www.example.com",
+			expected: "quay.io/openshift-release-dev/ocp-release machineconfiguration.openshift.io/role /etc/kubernetes/kubelet.conf 99-master.generated.yaml kubernetes.default.svc.cluster.local www.example.com",
+		},
+		{
+			name:     "pull secret",
+			detector: "pull-secret",
+			input:    `{"auths":{"quay.io":{"auth":"dXNlcjpwYXNz","email":"me@acme.net"},"registry.acme.net":{"username":"user","password":"s3cr3t"}}}`,
+			expected: `{"auths":{"quay.io":{"auth":"<pull-secret>","email":"me@acme.net"},"registry.acme.net":{"username":"user","password":"<pull-secret>"}}}`,
+		},
+		{
+			name:     "certificate",
+			detector: "certificate",
+			input:    "ca: |\n" + testCertificate + "\nend",
+			expected: "ca: |\n<certificate>\nend",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			detectors, err := NewDetectors([]string{tc.detector})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, scrubText(tc.input, detectors, kindReplacer{}))
+		})
+	}
+}
+
+func TestScrubString(t *testing.T) {
+	detectors, err := NewDetectors([]string{"certificate", "pull-secret", "ip", "hostname"})
+	require.NoError(t, err)
+
+	gzipped := func(s string) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, err := zw.Write([]byte(s))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		return buf.Bytes()
+	}
+	gunzipped := func(data []byte) string {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(zr)
+		require.NoError(t, err)
+		return buf.String()
+	}
+
+	t.Run("plain text", func(t *testing.T) {
+		assert.Equal(t, "host <ip>", scrubString("host 10.0.0.1", detectors, kindReplacer{}))
+	})
+
+	t.Run("data url", func(t *testing.T) {
+		input := "data:," + dataurl.EscapeString("nameserver 10.0.0.1\n")
+		assert.Equal(t, "data:,"+dataurl.EscapeString("nameserver <ip>\n"), scrubString(input, detectors, kindReplacer{}))
+	})
+
+	t.Run("base64 data url", func(t *testing.T) {
+		input := "data:text/plain;charset=utf-8;base64," + base64.StdEncoding.EncodeToString([]byte(testCertificate))
+		expected := "data:text/plain;charset=utf-8;base64," + base64.StdEncoding.EncodeToString([]byte("<certificate>"))
+		assert.Equal(t, expected, scrubString(input, detectors, kindReplacer{}))
+	})
+
+	t.Run("gzipped base64 data url", func(t *testing.T) {
+		input := "data:;base64," + base64.StdEncoding.EncodeToString(gzipped("server api.mycluster.acme.net"))
+
+		output := scrubString(input, detectors, kindReplacer{})
+		du, err := dataurl.DecodeString(output)
+		require.NoError(t, err)
+		assert.Equal(t, "server <hostname>", gunzipped(du.Data))
+	})
+
+	t.Run("base64", func(t *testing.T) {
+		input := base64.StdEncoding.EncodeToString([]byte(`{"auths":{"quay.io":{"auth":"dXNlcjpwYXNz"}}}`))
+		expected := base64.StdEncoding.EncodeToString([]byte(`{"auths":{"quay.io":{"auth":"<pull-secret>"}}}`))
+		assert.Equal(t, expected, scrubString(input, detectors, kindReplacer{}))
+	})
+
+	t.Run("nothing to replace", func(t *testing.T) {
+		input := "data:;base64," + base64.StdEncoding.EncodeToString([]byte("nothing to see"))
+		assert.Equal(t, input, scrubString(input, detectors, kindReplacer{}))
+	})
+
+	t.Run("binary content", func(t *testing.T) {
+		input := "data:;base64," + base64.StdEncoding.EncodeToString([]byte{0x00, 0x01, 0x0a, 0x00, 0x00, 0x01})
+		assert.Equal(t, input, scrubString(input, detectors, kindReplacer{}))
+	})
+}
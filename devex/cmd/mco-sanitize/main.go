@@ -2,19 +2,25 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
 
 	"github.com/spf13/cobra"
 	"k8s.io/component-base/cli"
+	"k8s.io/klog/v2"
 )
 
 func main() {
 	var inputPath string
 	var outputPath string
 	var workerCount int
+	var tokenizeKeyPath string
+	var tokenMapPath string
 
 	rootCmd := &cobra.Command{
 		Use:   "mco-sanitize",
@@ -31,9 +37,28 @@ func main() {
 			if err != nil {
 				return err
 			}
-			if err := sanitize(ctx, inputPath, workerCount, config); err != nil {
+
+			var replacer Replacer
+			var tokenizer *Tokenizer
+			if tokenizeKeyPath != "" {
+				tokenizer, err = newTokenizerFromFlags(inputPath, tokenizeKeyPath, tokenMapPath)
+				if err != nil {
+					return err
+				}
+				replacer = tokenizer
+			} else if tokenMapPath != "" {
+				return fmt.Errorf("--token-map requires --tokenize-key")
+			}
+
+			if err := sanitize(ctx, inputPath, workerCount, config, replacer); err != nil {
 				return err
 			}
+			if tokenizer != nil && tokenMapPath != "" {
+				if err := tokenizer.WriteTokenMap(tokenMapPath); err != nil {
+					return fmt.Errorf("could not write the token map: %w", err)
+				}
+				klog.Infof("Token map written to %s. Keep it private: it maps the tokens back to the original values", tokenMapPath)
+			}
 			// Encrypted archiving cannot be disabled for now
 			// When we validate that the tool works in CI this feature will be disabled by default
 			if outputPath != "" {
@@ -45,10 +70,88 @@ func main() {
 		},
 	}
 
-	rootCmd.PersistentFlags().StringVar(&inputPath, "input", "", "Path to the must-gather directory.")
-	rootCmd.PersistentFlags().StringVar(&outputPath, "output", "", "Path to where the tar.gz output should be saved.")
-	rootCmd.PersistentFlags().IntVar(&workerCount, "workers", runtime.NumCPU(), "Worker count. Defaults to CPU core count.")
-	_ = rootCmd.MarkPersistentFlagRequired("input")
+	rootCmd.Flags().StringVar(&inputPath, "input", "", "Path to the must-gather directory.")
+	rootCmd.Flags().StringVar(&outputPath, "output", "", "Path to where the tar.gz output should be saved.")
+	rootCmd.Flags().IntVar(&workerCount, "workers", runtime.NumCPU(), "Worker count. Defaults to CPU core count.")
+	rootCmd.Flags().StringVar(&tokenizeKeyPath, "tokenize-key", "",
+		"Replace sensitive values with deterministic tokens keyed by the key at this path, generated if missing. Must be outside of the input directory.")
+	rootCmd.Flags().StringVar(&tokenMapPath, "token-map", "",
+		"Path to write the map of the tokens to their original values. Must be outside of the input directory.")
+	_ = rootCmd.MarkFlagRequired("input")
+
+	rootCmd.AddCommand(newTokenCommand())
 
 	os.Exit(cli.Run(rootCmd))
 }
+
+// newTokenCommand creates the command printing the tokens of the given values,
+// to map the tokens found in a sanitized must-gather back to known values.
+func newTokenCommand() *cobra.Command {
+	var tokenizeKeyPath string
+	var kind string
+
+	cmd := &cobra.Command{
+		Use:   "token VALUE...",
+		Short: "Prints the tokens of the given values",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := LoadTokenizationKey(tokenizeKeyPath)
+			if err != nil {
+				return fmt.Errorf("could not read the tokenization key: %w", err)
+			}
+			tokenizer, err := NewTokenizer(key)
+			if err != nil {
+				return err
+			}
+			for _, value := range args {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\n", tokenizer.Token(kind, value), value)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&tokenizeKeyPath, "tokenize-key", "", "Path to the tokenization key used to sanitize the must-gather.")
+	cmd.Flags().StringVar(&kind, "kind", "value", "Kind label of the tokens, e.g. ip or hostname.")
+	_ = cmd.MarkFlagRequired("tokenize-key")
+	return cmd
+}
+
+// newTokenizerFromFlags creates the tokenizer used to sanitize the must-gather.
+// The key and the token map must not be written to the must-gather, which is
+// shared once sanitized.
+func newTokenizerFromFlags(inputPath, tokenizeKeyPath, tokenMapPath string) (*Tokenizer, error) {
+	for _, path := range []string{tokenizeKeyPath, tokenMapPath} {
+		if path == "" {
+			continue
+		}
+		inside, err := isInside(path, inputPath)
+		if err != nil {
+			return nil, err
+		}
+		if inside {
+			return nil, fmt.Errorf("%s must be outside of the input directory %s", path, inputPath)
+		}
+	}
+
+	key, err := LoadOrCreateTokenizationKey(tokenizeKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewTokenizer(key)
+}
+
+// isInside returns true if path is dir or one of its descendants.
+func isInside(path, dir string) (bool, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return false, err
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))), nil
+}
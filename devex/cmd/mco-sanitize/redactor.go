@@ -25,33 +25,47 @@ type redactTargetConfig struct {
 	namespaces []string
 	apiVersion string
 	paths      []string
+	detectors  []Detector
 }
 
 // KubernetesRedactor sanitizes Kubernetes resources by redacting sensitive fields configured
 // by a given ConfigRedact
 type KubernetesRedactor struct {
-	targetConfigs map[string]redactTargetConfig
+	// targetConfigs holds the redaction rules by Kind. A Kind can have several rules,
+	// e.g. one redacting whole fields and another one looking for sensitive values.
+	targetConfigs map[string][]redactTargetConfig
+	replacer      Replacer
 }
 
 // NewKubernetesRedactor creates a new KubernetesRedactor based on a given
-// set of ConfigRedact. Returns an error if configs is nil or if any config
-// has an empty Kind field.
-func NewKubernetesRedactor(configs []ConfigRedact) (*KubernetesRedactor, error) {
+// set of ConfigRedact. The replacer computes the replacement of the sensitive
+// values; if nil, they are replaced with redaction markers.
+// Returns an error if configs is nil, if any config has an empty Kind field
+// or references an unknown detector.
+func NewKubernetesRedactor(configs []ConfigRedact, replacer Replacer) (*KubernetesRedactor, error) {
 	if configs == nil {
 		return nil, errors.New("invalid redact configuration")
 	}
-	targetConfigs := make(map[string]redactTargetConfig)
+	if replacer == nil {
+		replacer = RedactionMarker{}
+	}
+	targetConfigs := make(map[string][]redactTargetConfig)
 	for _, cfg := range configs {
 		if cfg.Kind == "" {
 			return nil, errors.New("invalid redact configuration. Empty kind")
 		}
-		targetConfigs[cfg.Kind] = redactTargetConfig{
+		detectors, err := NewDetectors(cfg.Detectors)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact configuration for kind %s: %w", cfg.Kind, err)
+		}
+		targetConfigs[cfg.Kind] = append(targetConfigs[cfg.Kind], redactTargetConfig{
 			paths:      cfg.Paths,
 			apiVersion: cfg.APIVersion,
 			namespaces: cfg.Namespaces,
-		}
+			detectors:  detectors,
+		})
 	}
-	return &KubernetesRedactor{targetConfigs: targetConfigs}, nil
+	return &KubernetesRedactor{targetConfigs: targetConfigs, replacer: replacer}, nil
 }
 
 // Redact sanitizes the given inputData, that corresponds to the unmarshalled representation of the given
@@ -96,38 +110,43 @@ func (r *KubernetesRedactor) Redact(inputData interface{}, k8sResources []Kubern
 // It checks if the resource matches any configured redaction rules and applies redaction to all matching paths.
 // Returns true if any changes were made to the input data, false otherwise.
 func (r *KubernetesRedactor) redactResource(inputData interface{}, k8sResource *KubernetesMetaResource, marshaler contentMarshaler) (bool, error) {
-	targetConfig := r.getRedactConfig(k8sResource)
-	if targetConfig == nil {
-		return false, nil
-	}
 	changed := false
-	for _, path := range targetConfig.paths {
-		iterChanged, err := recursiveWalk(&visitorNode{value: inputData, marshaler: marshaler}, strings.Split(path, "."))
-		if err != nil {
-			return changed, fmt.Errorf("error visiting resource %#v with path %s: %w", k8sResource, path, err)
-		}
-		if iterChanged {
-			changed = true
+	for _, targetConfig := range r.getRedactConfigs(k8sResource) {
+		for _, path := range targetConfig.paths {
+			root := &visitorNode{
+				value:     inputData,
+				marshaler: marshaler,
+				replacer:  r.replacer,
+				detectors: targetConfig.detectors,
+			}
+			iterChanged, err := recursiveWalk(root, strings.Split(path, "."))
+			if err != nil {
+				return changed, fmt.Errorf("error visiting resource %#v with path %s: %w", k8sResource, path, err)
+			}
+			if iterChanged {
+				changed = true
+			}
 		}
 	}
 	return changed, nil
 }
 
-// getRedactConfig retrieves the redaction configuration for a given Kubernetes resource.
+// getRedactConfigs retrieves the redaction configurations for a given Kubernetes resource.
 // It matches the resource against configured rules based on Kind, APIVersion, and Namespace.
 // Returns nil if no matching configuration is found or if the resource doesn't meet the criteria.
-func (r *KubernetesRedactor) getRedactConfig(k8sResource *KubernetesMetaResource) *redactTargetConfig {
-	targetConfig, ok := r.targetConfigs[k8sResource.Kind]
-	if !ok {
-		return nil
-	}
-	if targetConfig.apiVersion != "" && targetConfig.apiVersion != k8sResource.APIVersion {
-		return nil
-	}
-	if targetConfig.namespaces != nil && len(targetConfig.namespaces) > 0 && !slices.Contains(targetConfig.namespaces, k8sResource.Metadata.Namespace) {
-		return nil
+func (r *KubernetesRedactor) getRedactConfigs(k8sResource *KubernetesMetaResource) []*redactTargetConfig {
+	var matching []*redactTargetConfig
+	for i := range r.targetConfigs[k8sResource.Kind] {
+		targetConfig := &r.targetConfigs[k8sResource.Kind][i]
+		if targetConfig.apiVersion != "" && targetConfig.apiVersion != k8sResource.APIVersion {
+			continue
+		}
+		if len(targetConfig.namespaces) > 0 && !slices.Contains(targetConfig.namespaces, k8sResource.Metadata.Namespace) {
+			continue
+		}
+		matching = append(matching, targetConfig)
 	}
-	return &targetConfig
+	return matching
 }
 
 // recursiveWalk traverses the data structure following the specified path and performs redaction.
@@ -135,6 +154,9 @@ func (r *KubernetesRedactor) getRedactConfig(k8sResource *KubernetesMetaResource
 // Returns true if any redaction was performed, false otherwise.
 func recursiveWalk(node *visitorNode, path []string) (bool, error) {
 	if len(path) == 0 {
+		if len(node.detectors) > 0 {
+			return node.scrub()
+		}
 		return true, node.redact()
 	}
 	key := path[0]
@@ -189,6 +211,8 @@ type visitorNode struct {
 	index     int              // The array index if this node is an array element
 	parent    *visitorNode     // Reference to the parent node
 	marshaler contentMarshaler // Marshaler function
+	replacer  Replacer         // Computes the replacement of sensitive values
+	detectors []Detector       // Detectors of sensitive values, if only those must be replaced
 }
 
 // newArrayChild creates a new visitorNode for an array element at the specified index.
@@ -205,6 +229,8 @@ func (n *visitorNode) newArrayChild(index int) *visitorNode {
 		index:     index,
 		parent:    n,
 		marshaler: n.marshaler,
+		replacer:  n.replacer,
+		detectors: n.detectors,
 	}
 }
 
@@ -223,6 +249,8 @@ func (n *visitorNode) newObjectChild(key string) *visitorNode {
 		key:       key,
 		parent:    n,
 		marshaler: n.marshaler,
+		replacer:  n.replacer,
+		detectors: n.detectors,
 	}
 }
 
@@ -255,8 +283,8 @@ func (n *visitorNode) replace(value interface{}) error {
 // redact replaces the current node's value with redaction information.
 // For complex types (arrays/maps), it marshals the value to determine its length.
 // For strings, it uses the string directly. For other primitives, it converts to string.
-// The redacted value is replaced with a map containing a redaction message and the
-// original data length for audit purposes.
+// The redacted value is replaced with the replacement computed by the replacer, by
+// default a map containing a redaction message and the original data length for audit purposes.
 func (n *visitorNode) redact() error {
 	if n.value == nil {
 		return nil
@@ -275,9 +303,16 @@ func (n *visitorNode) redact() error {
 	default:
 		redactSource = fmt.Sprintf("%v", val)
 	}
-	redactInfo := map[string]interface{}{
-		"_REDACTED": "This field has been redacted",
-		"length":    len(redactSource),
+	return n.replace(n.replacer.ReplaceField(redactSource))
+}
+
+// scrub replaces the sensitive values found by the node's detectors in the
+// strings of the current node's value, leaving the rest of the value untouched.
+// Returns true if any value was replaced.
+func (n *visitorNode) scrub() (bool, error) {
+	scrubbed, changed := scrubValue(n.value, n.detectors, n.replacer)
+	if !changed {
+		return false, nil
 	}
-	return n.replace(redactInfo)
+	return true, n.replace(scrubbed)
 }
@@ -27,14 +27,14 @@ func TestNewKubernetesRedactor_ValidConfig(t *testing.T) {
 		},
 	}
 
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, redactor)
 }
 
 func TestNewKubernetesRedactor_NilConfig(t *testing.T) {
-	redactor, err := NewKubernetesRedactor(nil)
+	redactor, err := NewKubernetesRedactor(nil, nil)
 
 	assert.Error(t, err)
 	assert.Nil(t, redactor)
@@ -50,7 +50,7 @@ func TestNewKubernetesRedactor_EmptyKind(t *testing.T) {
 		},
 	}
 
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 
 	assert.Error(t, err)
 	assert.Nil(t, redactor)
@@ -60,7 +60,7 @@ func TestNewKubernetesRedactor_EmptyKind(t *testing.T) {
 func TestNewKubernetesRedactor_EmptySlice(t *testing.T) {
 	configs := []ConfigRedact{}
 
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, redactor)
@@ -70,7 +70,7 @@ func TestKubernetesRedactor_Redact_EmptyResources(t *testing.T) {
 	configs := []ConfigRedact{
 		{Kind: "MachineConfig", Paths: []string{"spec.config"}},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	inputData := map[string]interface{}{
@@ -92,7 +92,7 @@ func TestKubernetesRedactor_Redact_SingleResource_Match(t *testing.T) {
 			Paths: []string{"spec.config"},
 		},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	inputData := map[string]interface{}{
@@ -134,7 +134,7 @@ func TestKubernetesRedactor_Redact_SingleResource_NoMatch(t *testing.T) {
 			Paths: []string{"data.password"},
 		},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	inputData := map[string]interface{}{
@@ -167,7 +167,7 @@ func TestKubernetesRedactor_Redact_ApiVersionMismatch(t *testing.T) {
 			Paths:      []string{"data.secret"},
 		},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	inputData := map[string]interface{}{
@@ -200,7 +200,7 @@ func TestKubernetesRedactor_Redact_NamespaceFilter(t *testing.T) {
 			Paths:      []string{"data.token"},
 		},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	inputData := map[string]interface{}{
@@ -238,7 +238,7 @@ func TestKubernetesRedactor_Redact_ListResources(t *testing.T) {
 			Paths: []string{"data"},
 		},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	inputData := []map[string]interface{}{
@@ -288,7 +288,7 @@ func TestKubernetesRedactor_Redact_ListResources_LengthMismatch(t *testing.T) {
 	configs := []ConfigRedact{
 		{Kind: "ConfigMap", Paths: []string{"data.config"}},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	inputData := []map[string]interface{}{
@@ -315,7 +315,7 @@ func TestKubernetesRedactor_Redact_ListResources_PartialMatch(t *testing.T) {
 			Paths: []string{"data"},
 		},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	inputData := []map[string]interface{}{
@@ -358,7 +358,7 @@ func TestKubernetesRedactor_Redact_UnsupportedInputType(t *testing.T) {
 	configs := []ConfigRedact{
 		{Kind: "ConfigMap", Paths: []string{"data.config"}},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	// Unsupported input type (string instead of map or slice)
@@ -381,7 +381,7 @@ func TestKubernetesRedactor_Redact_ArrayTraversal(t *testing.T) {
 			Paths: []string{"spec.config.storage.files.*.contents"}, // Path that goes through an array
 		},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	inputData := map[string]interface{}{
@@ -449,7 +449,7 @@ func TestKubernetesRedactor_Redact_ArrayIndexPaths(t *testing.T) {
 			Paths: []string{"data.items.0", "data.items.2"}, // Target specific array elements by index
 		},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	inputData := map[string]interface{}{
@@ -509,7 +509,7 @@ func TestKubernetesRedactor_Redact_UnsupportedArrayIndexInPath(t *testing.T) {
 			Paths: []string{"data.my-array.1.element"}, // Path with array index in middle
 		},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	inputData := map[string]interface{}{
@@ -571,7 +571,7 @@ func TestKubernetesRedactor_Redact_ArrayIndexOutOfRange(t *testing.T) {
 			Paths: []string{"data.my-array.5.element"}, // Index 5 is out of range (array has only 3 elements)
 		},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	inputData := map[string]interface{}{
@@ -623,7 +623,7 @@ func TestKubernetesRedactor_Redact_InvalidArrayPathElement(t *testing.T) {
 			Paths: []string{"data.my-array.invalid-key.element"}, // "invalid-key" is not "*" or a number
 		},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	inputData := map[string]interface{}{
@@ -669,7 +669,7 @@ func TestKubernetesRedactor_Redact_NonExistentIntermediateKeys(t *testing.T) {
 			},
 		},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	inputData := map[string]interface{}{
@@ -728,7 +728,7 @@ func TestKubernetesRedactor_Redact_RootPath(t *testing.T) {
 			Paths: []string{"data"}, // Root-level path
 		},
 	}
-	redactor, err := NewKubernetesRedactor(configs)
+	redactor, err := NewKubernetesRedactor(configs, nil)
 	require.NoError(t, err)
 
 	inputData := map[string]interface{}{
@@ -888,7 +888,7 @@ func TestKubernetesRedactor_Redact_LengthMetadata(t *testing.T) {
 					Paths: []string{tc.pathToRedact},
 				},
 			}
-			redactor, err := NewKubernetesRedactor(configs)
+			redactor, err := NewKubernetesRedactor(configs, nil)
 			require.NoError(t, err)
 
 			k8sResources := []KubernetesMetaResource{
@@ -953,3 +953,84 @@ func TestKubernetesRedactor_Redact_LengthMetadata(t *testing.T) {
 		})
 	}
 }
+
+func TestKubernetesRedactor_Redact_Detectors(t *testing.T) {
+	configs := []ConfigRedact{
+		{
+			Kind:  "MachineConfig",
+			Paths: []string{"spec.config.passwd"},
+		},
+		{
+			Kind:      "MachineConfig",
+			Paths:     []string{"spec.config.storage.files.*.contents"},
+			Detectors: []string{"ip", "hostname"},
+		},
+	}
+	tokenizer, err := NewTokenizer([]byte(strings.Repeat("k", tokenizationKeySize)))
+	require.NoError(t, err)
+	redactor, err := NewKubernetesRedactor(configs, tokenizer)
+	require.NoError(t, err)
+
+	newMachineConfig := func(contents string) map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": "machineconfiguration.openshift.io/v1",
+			"kind":       "MachineConfig",
+			"spec": map[string]interface{}{
+				"config": map[string]interface{}{
+					"passwd": "sensitive",
+					"storage": map[string]interface{}{
+						"files": []interface{}{
+							map[string]interface{}{
+								"path":     "/etc/chrony.conf",
+								"contents": map[string]interface{}{"source": contents},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	contentsOf := func(mc map[string]interface{}) string {
+		files := mc["spec"].(map[string]interface{})["config"].(map[string]interface{})["storage"].(map[string]interface{})["files"].([]interface{})
+		return files[0].(map[string]interface{})["contents"].(map[string]interface{})["source"].(string)
+	}
+	k8sResources := []KubernetesMetaResource{{APIVersion: "machineconfiguration.openshift.io/v1", Kind: "MachineConfig"}}
+
+	first := newMachineConfig("data:,server%2010.0.0.1%20iburst%0Aserver%20ntp.corp.acme.net")
+	changed, err := redactor.Redact(first, k8sResources, yaml.Marshal)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	ipToken := tokenizer.Token("ip", "10.0.0.1")
+	hostToken := tokenizer.Token("hostname", "ntp.corp.acme.net")
+	assert.Equal(t, "data:,server%20"+ipToken+"%20iburst%0Aserver%20"+hostToken, contentsOf(first))
+
+	// both rules apply to the same resource
+	passwd := first["spec"].(map[string]interface{})["config"].(map[string]interface{})["passwd"].(map[string]interface{})
+	assert.Equal(t, tokenizer.Token("value", "sensitive"), passwd["token"])
+
+	// the same value gets the same token in another resource
+	second := newMachineConfig("data:,pool%2010.0.0.1")
+	_, err = redactor.Redact(second, k8sResources, yaml.Marshal)
+	require.NoError(t, err)
+	assert.Equal(t, "data:,pool%20"+ipToken, contentsOf(second))
+
+	// nothing detected
+	third := newMachineConfig("data:,nothing")
+	third["spec"].(map[string]interface{})["config"].(map[string]interface{})["passwd"] = nil
+	changed, err = redactor.Redact(third, k8sResources, yaml.Marshal)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, "data:,nothing", contentsOf(third))
+}
+
+func TestNewKubernetesRedactor_UnknownDetector(t *testing.T) {
+	configs := []ConfigRedact{
+		{Kind: "MachineConfig", Paths: []string{"spec.config"}, Detectors: []string{"email"}},
+	}
+
+	redactor, err := NewKubernetesRedactor(configs, nil)
+
+	assert.Nil(t, redactor)
+	assert.ErrorContains(t, err, `invalid redact configuration for kind MachineConfig: unknown detector "email"`)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tokenizationKeySize is the size in bytes of the generated tokenization keys.
+const tokenizationKeySize = 32
+
+// Replacer computes the replacement of the sensitive values found in a must-gather.
+// Implementations must be safe for concurrent use, as files are processed in parallel.
+type Replacer interface {
+	// ReplaceField returns the replacement of a whole redacted field, given
+	// its textual representation.
+	ReplaceField(value string) interface{}
+
+	// ReplaceMatch returns the replacement of a sensitive value of the given kind
+	// found by a detector inside a field.
+	ReplaceMatch(kind, value string) string
+}
+
+// RedactionMarker replaces sensitive values with markers that only keep their length.
+type RedactionMarker struct{}
+
+// ReplaceField returns a marker with the length of the redacted field.
+func (RedactionMarker) ReplaceField(value string) interface{} {
+	return map[string]interface{}{
+		"_REDACTED": "This field has been redacted",
+		"length":    len(value),
+	}
+}
+
+// ReplaceMatch returns a marker with the kind and length of the redacted value.
+func (RedactionMarker) ReplaceMatch(kind, value string) string {
+	return fmt.Sprintf("<REDACTED-%s:%d>", kind, len(value))
+}
+
+// tokenMapping is an entry of the token map.
+type tokenMapping struct {
+	Token string `json:"token"`
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Tokenizer replaces sensitive values with deterministic pseudonyms: the same
+// value gets the same token across the whole must-gather, so the values can be
+// cross-referenced without being disclosed. Tokens are keyed with HMAC-SHA256,
+// so they can only be mapped back to the original values with the key.
+type Tokenizer struct {
+	key []byte
+
+	mu sync.Mutex
+	// tokens maps each issued token to its original value
+	tokens map[string]tokenMapping
+}
+
+// NewTokenizer creates a Tokenizer with the given key.
+func NewTokenizer(key []byte) (*Tokenizer, error) {
+	if len(key) < tokenizationKeySize {
+		return nil, fmt.Errorf("the tokenization key must be at least %d bytes long", tokenizationKeySize)
+	}
+	return &Tokenizer{key: key, tokens: map[string]tokenMapping{}}, nil
+}
+
+// Token returns the token of a value. The kind only labels the token: the
+// same value gets the same digest whatever detector found it.
+func (t *Tokenizer) Token(kind, value string) string {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(value))
+	return fmt.Sprintf("TOKEN-%s-%s", kind, hex.EncodeToString(mac.Sum(nil))[:16])
+}
+
+// ReplaceField returns a marker with the token and length of the field.
+func (t *Tokenizer) ReplaceField(value string) interface{} {
+	return map[string]interface{}{
+		"_REDACTED": "This field has been tokenized",
+		"token":     t.issue("value", value),
+		"length":    len(value),
+	}
+}
+
+// ReplaceMatch returns the token of the value.
+func (t *Tokenizer) ReplaceMatch(kind, value string) string {
+	return t.issue(kind, value)
+}
+
+func (t *Tokenizer) issue(kind, value string) string {
+	token := t.Token(kind, value)
+	t.mu.Lock()
+	t.tokens[token] = tokenMapping{Token: token, Kind: kind, Value: value}
+	t.mu.Unlock()
+	return token
+}
+
+// WriteTokenMap writes the issued tokens and their original values to path.
+// The map discloses the original values, so it must never be shared.
+func (t *Tokenizer) WriteTokenMap(path string) error {
+	t.mu.Lock()
+	mappings := make([]tokenMapping, 0, len(t.tokens))
+	for _, m := range t.tokens {
+		mappings = append(mappings, m)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(mappings, func(i, j int) bool { return mappings[i].Token < mappings[j].Token })
+	content, err := json.MarshalIndent(mappings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0o600)
+}
+
+// LoadTokenizationKey reads the hex-encoded tokenization key at path.
+func LoadTokenizationKey(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid tokenization key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// LoadOrCreateTokenizationKey reads the hex-encoded tokenization key at path,
+// generating it if the file does not exist. Reusing the key keeps the tokens
+// stable across several must-gathers of the same cluster.
+func LoadOrCreateTokenizationKey(path string) ([]byte, error) {
+	key, err := LoadTokenizationKey(path)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return key, err
+	}
+
+	key = make([]byte, tokenizationKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("could not create the tokenization key %s: %w", path, err)
+	}
+	if _, err := fmt.Fprintln(f, hex.EncodeToString(key)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return key, f.Close()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenizer(t *testing.T) {
+	key := []byte(strings.Repeat("k", tokenizationKeySize))
+	tokenizer, err := NewTokenizer(key)
+	require.NoError(t, err)
+
+	token := tokenizer.ReplaceMatch("ip", "10.0.0.1")
+	assert.Regexp(t, `^TOKEN-ip-[0-9a-f]{16}$`, token)
+
+	// deterministic, whatever the kind
+	assert.Equal(t, token, tokenizer.ReplaceMatch("ip", "10.0.0.1"))
+	assert.Equal(t, strings.TrimPrefix(token, "TOKEN-ip-"), strings.TrimPrefix(tokenizer.Token("hostname", "10.0.0.1"), "TOKEN-hostname-"))
+	assert.NotEqual(t, token, tokenizer.ReplaceMatch("ip", "10.0.0.2"))
+
+	// keyed
+	otherTokenizer, err := NewTokenizer([]byte(strings.Repeat("o", tokenizationKeySize)))
+	require.NoError(t, err)
+	assert.NotEqual(t, token, otherTokenizer.Token("ip", "10.0.0.1"))
+
+	field := tokenizer.ReplaceField("secret").(map[string]interface{})
+	assert.Equal(t, tokenizer.Token("value", "secret"), field["token"])
+	assert.Equal(t, 6, field["length"])
+
+	tokenMapPath := filepath.Join(t.TempDir(), "tokens.json")
+	require.NoError(t, tokenizer.WriteTokenMap(tokenMapPath))
+	content, err := os.ReadFile(tokenMapPath)
+	require.NoError(t, err)
+	var mappings []tokenMapping
+	require.NoError(t, json.Unmarshal(content, &mappings))
+	assert.Len(t, mappings, 3)
+	assert.Contains(t, mappings, tokenMapping{Token: token, Kind: "ip", Value: "10.0.0.1"})
+
+	_, err = NewTokenizer([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestLoadOrCreateTokenizationKey(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "key")
+
+	key, err := LoadOrCreateTokenizationKey(keyPath)
+	require.NoError(t, err)
+	assert.Len(t, key, tokenizationKeySize)
+
+	info, err := os.Stat(keyPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// the key is reused
+	reloaded, err := LoadOrCreateTokenizationKey(keyPath)
+	require.NoError(t, err)
+	assert.Equal(t, key, reloaded)
+
+	require.NoError(t, os.WriteFile(keyPath, []byte("not hex"), 0o600))
+	_, err = LoadOrCreateTokenizationKey(keyPath)
+	assert.ErrorContains(t, err, "invalid tokenization key")
+}
+
+func TestIsInside(t *testing.T) {
+	dir := t.TempDir()
+
+	for path, expected := range map[string]bool{
+		dir:                                    true,
+		filepath.Join(dir, "key"):              true,
+		filepath.Join(dir, "sub", "..", "key"): true,
+		filepath.Dir(dir):                      false,
+		filepath.Join(dir+"-other", "key"):     false,
+	} {
+		inside, err := isInside(path, dir)
+		require.NoError(t, err)
+		assert.Equal(t, expected, inside, path)
+	}
+}
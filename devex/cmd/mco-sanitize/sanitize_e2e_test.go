@@ -143,7 +143,7 @@ func TestSanitize_E2E_GoldenFiles(t *testing.T) {
 
 			// Run sanitize
 			ctx := context.Background()
-			err := sanitize(ctx, tempDir, 2, config)
+			err := sanitize(ctx, tempDir, 2, config, nil)
 			require.NoError(t, err, "Sanitize failed for test case %s", tc.name)
 
 			// Compare outputs with golden files or update them if UPDATE_GOLDEN=true
@@ -231,7 +231,7 @@ data:
 			inputDir, config := tc.setupFunc(t)
 
 			ctx := context.Background()
-			err := sanitize(ctx, inputDir, 1, config)
+			err := sanitize(ctx, inputDir, 1, config, nil)
 
 			if tc.expectError {
 				assert.Error(t, err, "Expected error for test case %s", tc.name)
@@ -8,13 +8,15 @@ import (
 
 // sanitize walks the filesystem starting from inputPath and redacts sensitive data
 // from Kubernetes resource files based on the provided configuration.
-func sanitize(ctx context.Context, inputPath string, workerCount int, config *Config) error {
+// The replacer computes the replacement of the sensitive data; if nil, it is
+// replaced with redaction markers.
+func sanitize(ctx context.Context, inputPath string, workerCount int, config *Config, replacer Replacer) error {
 	if config == nil || config.Redact == nil {
 		klog.Warning("Empty redact list")
 		return nil
 	}
 
-	redactor, err := NewKubernetesRedactor(config.Redact)
+	redactor, err := NewKubernetesRedactor(config.Redact, replacer)
 	if err != nil {
 		return err
 	}
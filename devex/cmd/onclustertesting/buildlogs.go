@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/openshift/machine-config-operator/devex/internal/pkg/utils"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	buildutils "github.com/openshift/machine-config-operator/pkg/controller/build/utils"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/test/framework"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+type buildLogsOpts struct {
+	poolName string
+	output   string
+}
+
+func init() {
+	buildLogsOpts := buildLogsOpts{}
+
+	buildLogsCmd := &cobra.Command{
+		Use:   "build-logs [MachineOSBuild name]",
+		Short: "Retrieves the archived build logs for a MachineOSBuild",
+		Long:  "Retrieves the archived build logs for the given MachineOSBuild or, if --pool is provided, for the current MachineOSBuild of that pool.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			mosbName := ""
+			if len(args) == 1 {
+				mosbName = args[0]
+			}
+
+			return runBuildLogsCmd(buildLogsOpts, mosbName)
+		},
+	}
+
+	buildLogsCmd.PersistentFlags().StringVar(&buildLogsOpts.poolName, "pool", "", "Pool name to retrieve the current build logs for")
+	buildLogsCmd.PersistentFlags().StringVar(&buildLogsOpts.output, "output", "", "File to write the build logs to; defaults to stdout")
+
+	rootCmd.AddCommand(buildLogsCmd)
+}
+
+func runBuildLogsCmd(opts buildLogsOpts, mosbName string) error {
+	utils.ParseFlags()
+
+	if isNoneSet(mosbName, opts.poolName) {
+		return fmt.Errorf("either a MachineOSBuild name or --pool must be provided")
+	}
+
+	if !isOnlyOneSet(mosbName, opts.poolName) {
+		return fmt.Errorf("a MachineOSBuild name and --pool are mutually exclusive")
+	}
+
+	cs := framework.NewClientSet("")
+
+	if opts.poolName != "" {
+		name, err := getCurrentMachineOSBuildNameForPool(cs, opts.poolName)
+		if err != nil {
+			return err
+		}
+
+		mosbName = name
+	}
+
+	logs, err := getBuildLogs(cs, mosbName)
+	if err != nil {
+		return err
+	}
+
+	if opts.output == "" {
+		_, err := os.Stdout.Write(logs)
+		return err
+	}
+
+	if err := os.WriteFile(opts.output, logs, 0o644); err != nil {
+		return err
+	}
+
+	klog.Infof("Wrote build logs for MachineOSBuild %q to %s", mosbName, opts.output)
+
+	return nil
+}
+
+func getCurrentMachineOSBuildNameForPool(cs *framework.ClientSet, poolName string) (string, error) {
+	mcp, err := cs.MachineConfigPools().Get(context.TODO(), poolName, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	mosc, err := getMachineOSConfigForPool(cs, mcp)
+	if err != nil {
+		return "", err
+	}
+
+	name, ok := mosc.Annotations[constants.CurrentMachineOSBuildAnnotationKey]
+	if !ok || name == "" {
+		return "", fmt.Errorf("MachineOSConfig %q has no current MachineOSBuild", mosc.Name)
+	}
+
+	return name, nil
+}
+
+func getBuildLogs(cs *framework.ClientSet, mosbName string) ([]byte, error) {
+	mosb, err := cs.MachineOSBuilds().Get(context.TODO(), mosbName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	cmName, ok := mosb.Annotations[constants.BuildLogsConfigMapAnnotationKey]
+	if !ok {
+		cmName = buildutils.GetBuildLogsConfigMapName(mosb)
+	}
+
+	cm, err := cs.ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), cmName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not get build logs ConfigMap %q for MachineOSBuild %q: %w", cmName, mosbName, err)
+	}
+
+	compressed, ok := cm.BinaryData[constants.BuildLogsConfigMapKey]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %q does not contain key %q", cmName, constants.BuildLogsConfigMapKey)
+	}
+
+	gr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("could not decompress build logs: %w", err)
+	}
+
+	defer gr.Close()
+
+	return io.ReadAll(gr)
+}
@@ -0,0 +1,238 @@
+package apihelpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// OSNodeDisruptionPolicyFeatureGate gates the TechPreview settings of the
+// MachineConfiguration which are set through annotations until they are part
+// of the MachineConfiguration API: the OS node disruption policy and live
+// kernel arguments. The annotations are ignored while the gate is disabled.
+const OSNodeDisruptionPolicyFeatureGate configv1.FeatureGateName = "OSNodeDisruptionPolicy"
+
+// OSNodeDisruptionPolicyValidConditionType is the MachineConfiguration
+// condition reporting whether the OS node disruption policy is in effect.
+const OSNodeDisruptionPolicyValidConditionType = "OSNodeDisruptionPolicyValid"
+
+// KernelArgumentsPendingRebootConditionType is the MachineConfiguration
+// condition listing the nodes whose kernel argument changes were applied
+// without a reboot and are only staged for their next boot.
+const KernelArgumentsPendingRebootConditionType = "KernelArgumentsPendingReboot"
+
+// RebootMethod is a faster alternative to a full reboot into a staged OS
+// deployment, skipping the firmware and bootloader.
+type RebootMethod string
+
+const (
+	// SoftRebootMethod restarts the userspace into the new deployment with
+	// systemd soft-reboot. It is only used if the kernel and kernel arguments
+	// are unchanged.
+	SoftRebootMethod RebootMethod = "SoftReboot"
+	// KexecRebootMethod boots the kernel of the new deployment with kexec.
+	KexecRebootMethod RebootMethod = "Kexec"
+)
+
+// UnitHealthCheckFailureAction is what the daemon does when units changed by
+// an update applied without a reboot fail.
+type UnitHealthCheckFailureAction string
+
+const (
+	// UnitHealthCheckReport reports the failed units in the MachineConfigNode,
+	// the update completes.
+	UnitHealthCheckReport UnitHealthCheckFailureAction = "Report"
+	// UnitHealthCheckDegrade fails the update: its changes on disk are rolled
+	// back and the node is degraded until it is retried successfully.
+	UnitHealthCheckDegrade UnitHealthCheckFailureAction = "Degrade"
+	// UnitHealthCheckRollback restores the files of the previous configuration
+	// and restarts the services so they run it, then fails the update as
+	// UnitHealthCheckDegrade.
+	UnitHealthCheckRollback UnitHealthCheckFailureAction = "Rollback"
+)
+
+// MaxUnitHealthCheckWindowSeconds bounds the unit health check window, as the
+// update holds the node for its duration.
+const MaxUnitHealthCheckWindowSeconds = 600
+
+// Mirrors the API validation of NodeDisruptionPolicyServiceName
+var serviceNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9:._\\-]+\.(service|socket|device|mount|automount|swap|target|path|timer|snapshot|slice|scope)$`)
+
+// OSNodeDisruptionPolicy contains the node disruption policies for changes to
+// the kernel arguments and extensions of MachineConfigs, and how updates
+// applied without a reboot are checked. It is set as JSON in the
+// OSNodeDisruptionPolicyAnnotationKey annotation of the MachineConfiguration,
+// as the NodeDisruptionPolicy API only covers files, units and SSH keys. It is
+// TechPreview, behind OSNodeDisruptionPolicyFeatureGate, and its fields are
+// meant to move to the NodeDisruptionPolicy API.
+type OSNodeDisruptionPolicy struct {
+	// KernelArguments are matched by the argument name, i.e. the part before "=".
+	KernelArguments []OSNodeDisruptionPolicyEntry `json:"kernelArguments,omitempty"`
+	// Extensions are only matched when added, removing an extension always
+	// requires a reboot.
+	Extensions []OSNodeDisruptionPolicyEntry `json:"extensions,omitempty"`
+	// RebootMethods are the alternatives to a full reboot the daemon may use
+	// when a change requires a reboot into a new deployment. A full reboot is
+	// done if none applies or if it can't be prepared.
+	RebootMethods []RebootMethod `json:"rebootMethods,omitempty"`
+	// UnitHealthCheck is how the units changed by an update applied without a
+	// reboot are checked. The units are not checked if it is unset.
+	UnitHealthCheck *UnitHealthCheck `json:"unitHealthCheck,omitempty"`
+}
+
+// UnitHealthCheck watches the units changed by an update applied without a
+// reboot, to catch units failing some time after being restarted.
+type UnitHealthCheck struct {
+	// WindowSeconds is how long the units are watched after the update. With
+	// 0, they are checked once.
+	WindowSeconds int32 `json:"windowSeconds,omitempty"`
+	// OnFailure is the action taken if any of the units fails, Report if
+	// unset.
+	OnFailure UnitHealthCheckFailureAction `json:"onFailure,omitempty"`
+}
+
+// OSNodeDisruptionPolicyEntry are the actions taken when the named kernel
+// argument or extension changes.
+type OSNodeDisruptionPolicyEntry struct {
+	Name    string                                `json:"name"`
+	Actions []opv1.NodeDisruptionPolicySpecAction `json:"actions"`
+}
+
+// GetOSNodeDisruptionPolicy parses and validates the OS node disruption policy
+// of the MachineConfiguration. It returns nil if none is set. Callers only
+// apply it if OSNodeDisruptionPolicyFeatureGate is enabled.
+func GetOSNodeDisruptionPolicy(mcop *opv1.MachineConfiguration) (*OSNodeDisruptionPolicy, error) {
+	if mcop == nil {
+		return nil, nil
+	}
+	raw, ok := mcop.Annotations[constants.OSNodeDisruptionPolicyAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	policy := &OSNodeDisruptionPolicy{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(policy); err != nil {
+		return nil, fmt.Errorf("invalid OS node disruption policy: %w", err)
+	}
+	if err := validateOSNodeDisruptionPolicyEntries("kernelArguments", policy.KernelArguments); err != nil {
+		return nil, err
+	}
+	if err := validateOSNodeDisruptionPolicyEntries("extensions", policy.Extensions); err != nil {
+		return nil, err
+	}
+	methods := sets.New[RebootMethod]()
+	for _, method := range policy.RebootMethods {
+		if method != SoftRebootMethod && method != KexecRebootMethod {
+			return nil, fmt.Errorf("invalid OS node disruption policy: unsupported reboot method %q", method)
+		}
+		if methods.Has(method) {
+			return nil, fmt.Errorf("invalid OS node disruption policy: duplicate reboot method %q", method)
+		}
+		methods.Insert(method)
+	}
+	if check := policy.UnitHealthCheck; check != nil {
+		if check.WindowSeconds < 0 || check.WindowSeconds > MaxUnitHealthCheckWindowSeconds {
+			return nil, fmt.Errorf("invalid OS node disruption policy: unit health check window must be between 0 and %d seconds", MaxUnitHealthCheckWindowSeconds)
+		}
+		switch check.OnFailure {
+		case "", UnitHealthCheckReport, UnitHealthCheckDegrade, UnitHealthCheckRollback:
+		default:
+			return nil, fmt.Errorf("invalid OS node disruption policy: unsupported unit health check failure action %q", check.OnFailure)
+		}
+	}
+	return policy, nil
+}
+
+// GetUnitHealthCheck returns the unit health check of the policy, with its
+// defaults set, or nil if the units are not checked. The policy may be nil.
+func (p *OSNodeDisruptionPolicy) GetUnitHealthCheck() *UnitHealthCheck {
+	if p == nil || p.UnitHealthCheck == nil {
+		return nil
+	}
+	check := *p.UnitHealthCheck
+	if check.OnFailure == "" {
+		check.OnFailure = UnitHealthCheckReport
+	}
+	return &check
+}
+
+func validateOSNodeDisruptionPolicyEntries(field string, entries []OSNodeDisruptionPolicyEntry) error {
+	names := sets.New[string]()
+	for _, entry := range entries {
+		if entry.Name == "" {
+			return fmt.Errorf("invalid OS node disruption policy: %s entry without a name", field)
+		}
+		if names.Has(entry.Name) {
+			return fmt.Errorf("invalid OS node disruption policy: duplicate %s entry %q", field, entry.Name)
+		}
+		names.Insert(entry.Name)
+		if len(entry.Actions) == 0 {
+			return fmt.Errorf("invalid OS node disruption policy: %s entry %q has no actions", field, entry.Name)
+		}
+		for _, action := range entry.Actions {
+			if err := validateNodeDisruptionPolicySpecAction(action); err != nil {
+				return fmt.Errorf("invalid OS node disruption policy: %s entry %q: %w", field, entry.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateNodeDisruptionPolicySpecAction mirrors the API validation of the
+// NodeDisruptionPolicySpecAction.
+func validateNodeDisruptionPolicySpecAction(action opv1.NodeDisruptionPolicySpecAction) error {
+	var service *opv1.NodeDisruptionPolicyServiceName
+	switch action.Type {
+	case opv1.RebootSpecAction, opv1.DrainSpecAction, opv1.DaemonReloadSpecAction, opv1.NoneSpecAction:
+	case opv1.ReloadSpecAction:
+		if action.Reload == nil {
+			return fmt.Errorf("reload is required for the Reload action")
+		}
+		service = &action.Reload.ServiceName
+	case opv1.RestartSpecAction:
+		if action.Restart == nil {
+			return fmt.Errorf("restart is required for the Restart action")
+		}
+		service = &action.Restart.ServiceName
+	default:
+		return fmt.Errorf("unsupported action type %q", action.Type)
+	}
+	if service != nil && !serviceNameRegexp.MatchString(string(*service)) {
+		return fmt.Errorf("invalid service name %q", *service)
+	}
+	return nil
+}
+
+// KernelArgumentActions returns the actions for a change to the named kernel
+// argument, and whether the policy has any.
+func (p *OSNodeDisruptionPolicy) KernelArgumentActions(name string) ([]opv1.NodeDisruptionPolicyStatusAction, bool) {
+	return findOSNodeDisruptionPolicyActions(p.KernelArguments, name)
+}
+
+// ExtensionActions returns the actions for adding the named extension, and
+// whether the policy has any.
+func (p *OSNodeDisruptionPolicy) ExtensionActions(name string) ([]opv1.NodeDisruptionPolicyStatusAction, bool) {
+	return findOSNodeDisruptionPolicyActions(p.Extensions, name)
+}
+
+func findOSNodeDisruptionPolicyActions(entries []OSNodeDisruptionPolicyEntry, name string) ([]opv1.NodeDisruptionPolicyStatusAction, bool) {
+	for _, entry := range entries {
+		if entry.Name != name {
+			continue
+		}
+		actions := []opv1.NodeDisruptionPolicyStatusAction{}
+		for _, action := range entry.Actions {
+			actions = append(actions, convertSpecActiontoStatusAction(action))
+		}
+		return actions, true
+	}
+	return nil, false
+}
@@ -0,0 +1,346 @@
+package build
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/utils"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// How long archived build logs are retained by default.
+	defaultBuildLogRetention time.Duration = time.Hour * 24 * 7
+
+	// ConfigMaps are limited to 1 MiB in total, so we leave some headroom for
+	// the object metadata.
+	maxCompressedBuildLogSize int = 900 * 1024
+)
+
+// Captures the logs from all of the build pods for the given MachineOSBuild,
+// compresses them, and stores them in a ConfigMap which outlives the build
+// Job. The ConfigMap is then referenced by an annotation on the
+// MachineOSBuild.
+func (b *buildReconciler) archiveBuildLogs(ctx context.Context, mosb *mcfgv1.MachineOSBuild, mosc *mcfgv1.MachineOSConfig) error {
+	// MachineOSBuilds which did not use a build Job (e.g., those seeded from a
+	// pre-built image) have no logs to archive.
+	if !metav1.HasAnnotation(mosb.ObjectMeta, constants.JobUIDAnnotationKey) {
+		return nil
+	}
+
+	logs, err := b.collectBuildLogs(ctx, mosb)
+	if err != nil {
+		return fmt.Errorf("could not collect build logs for MachineOSBuild %q: %w", mosb.Name, err)
+	}
+
+	compressed, err := compressBuildLogs(logs, maxCompressedBuildLogSize)
+	if err != nil {
+		return fmt.Errorf("could not compress build logs for MachineOSBuild %q: %w", mosb.Name, err)
+	}
+
+	cm := newBuildLogsConfigMap(mosb, mosc, compressed, time.Now().Add(getBuildLogRetention(mosc)))
+
+	if err := b.applyBuildLogsConfigMap(ctx, cm); err != nil {
+		return fmt.Errorf("could not store build logs for MachineOSBuild %q: %w", mosb.Name, err)
+	}
+
+	klog.Infof("Archived %d bytes of build logs (%d compressed) for MachineOSBuild %q in ConfigMap %q", len(logs), len(compressed), mosb.Name, cm.Name)
+
+	if err := b.setBuildLogsAnnotationOnMachineOSBuild(ctx, mosb, cm); err != nil {
+		return fmt.Errorf("could not reference build logs ConfigMap %q on MachineOSBuild %q: %w", cm.Name, mosb.Name, err)
+	}
+
+	return nil
+}
+
+// Gets the logs from every container within every pod the build Job and any
+// architecture-specific build Jobs created (including retries) in the order
+// that the pods were created.
+func (b *buildReconciler) collectBuildLogs(ctx context.Context, mosb *mcfgv1.MachineOSBuild) ([]byte, error) {
+	jobUIDs, err := b.getBuildJobUIDs(ctx, mosb)
+	if err != nil {
+		return nil, err
+	}
+
+	pods := []corev1.Pod{}
+
+	for _, jobUID := range jobUIDs {
+		// Select by the Job UID instead of the Job name since a Job of the same
+		// name may have been recreated for a rebuild.
+		selector := labels.SelectorFromSet(map[string]string{
+			batchv1.ControllerUidLabel: jobUID,
+		})
+
+		podList, err := b.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).List(ctx, metav1.ListOptions{
+			LabelSelector: selector.String(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not list build pods: %w", err)
+		}
+
+		pods = append(pods, podList.Items...)
+	}
+
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("no build pods found for build job(s) %v", jobUIDs)
+	}
+
+	sort.SliceStable(pods, func(i, j int) bool {
+		return pods[i].CreationTimestamp.Before(&pods[j].CreationTimestamp)
+	})
+
+	out := &bytes.Buffer{}
+
+	for _, pod := range pods {
+		containers := append([]corev1.Container{}, pod.Spec.InitContainers...)
+		containers = append(containers, pod.Spec.Containers...)
+
+		for _, container := range containers {
+			fmt.Fprintf(out, "==> pod/%s container/%s <==\n", pod.Name, container.Name)
+
+			// A container may never have started, e.g., if the build container
+			// failed before the digest container ran. Note the error inline
+			// instead of failing so that we keep whatever logs we can get.
+			containerLogs, err := b.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+				Container: container.Name,
+			}).DoRaw(ctx)
+			if err != nil {
+				fmt.Fprintf(out, "<could not get logs: %s>\n", err)
+				continue
+			}
+
+			out.Write(containerLogs)
+			if len(containerLogs) > 0 && containerLogs[len(containerLogs)-1] != '\n' {
+				out.WriteString("\n")
+			}
+		}
+	}
+
+	return out.Bytes(), nil
+}
+
+// Gets the UIDs of the build Job for the given MachineOSBuild along with those
+// of the architecture-specific build Jobs of a multi-architecture build. The
+// architecture-specific Jobs are owned by the build Job, which distinguishes
+// them from those left over from a previous build of the same name.
+func (b *buildReconciler) getBuildJobUIDs(ctx context.Context, mosb *mcfgv1.MachineOSBuild) ([]string, error) {
+	jobUID := mosb.Annotations[constants.JobUIDAnnotationKey]
+
+	sel, err := utils.ArchBuildObjectSelectorForMachineOSBuild(mosb.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	archJobs, err := b.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: sel.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list architecture-specific build jobs: %w", err)
+	}
+
+	jobUIDs := []string{jobUID}
+
+	for _, archJob := range archJobs.Items {
+		for _, oref := range archJob.OwnerReferences {
+			if string(oref.UID) == jobUID {
+				jobUIDs = append(jobUIDs, string(archJob.UID))
+				break
+			}
+		}
+	}
+
+	return jobUIDs, nil
+}
+
+// Stores the build logs ConfigMap, replacing any preexisting one. This can
+// occur when a MachineOSBuild with the same name is rebuilt.
+func (b *buildReconciler) applyBuildLogsConfigMap(ctx context.Context, cm *corev1.ConfigMap) error {
+	_, err := b.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Create(ctx, cm, metav1.CreateOptions{})
+	if err == nil || !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := b.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(ctx, cm.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		cm.ResourceVersion = existing.ResourceVersion
+		_, err = b.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// Adds an annotation referencing the build logs ConfigMap to the
+// MachineOSBuild, if not already present. The status RelatedObjects field
+// cannot be used for this since its group must be non-empty, which excludes
+// ConfigMaps, and it is meant for the ephemeral build objects.
+func (b *buildReconciler) setBuildLogsAnnotationOnMachineOSBuild(ctx context.Context, mosb *mcfgv1.MachineOSBuild, cm *corev1.ConfigMap) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := b.mcfgclient.MachineconfigurationV1().MachineOSBuilds().Get(ctx, mosb.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if current.Annotations[constants.BuildLogsConfigMapAnnotationKey] == cm.Name {
+			return nil
+		}
+
+		metav1.SetMetaDataAnnotation(&current.ObjectMeta, constants.BuildLogsConfigMapAnnotationKey, cm.Name)
+
+		_, err = b.mcfgclient.MachineconfigurationV1().MachineOSBuilds().Update(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// Deletes any archived build logs for the given MachineOSConfig whose
+// retention period has expired.
+func (b *buildReconciler) PruneBuildLogs(ctx context.Context, mosc *mcfgv1.MachineOSConfig) error {
+	return b.timeObjectOperation(mosc, syncingVerb, func() error {
+		return b.pruneBuildLogs(ctx, mosc, time.Now())
+	})
+}
+
+func (b *buildReconciler) pruneBuildLogs(ctx context.Context, mosc *mcfgv1.MachineOSConfig, now time.Time) error {
+	selector := labels.SelectorFromSet(map[string]string{
+		constants.BuildLogsLabelKey:           "",
+		constants.MachineOSConfigNameLabelKey: mosc.Name,
+	})
+
+	cmList, err := b.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("could not list build logs ConfigMaps for MachineOSConfig %q: %w", mosc.Name, err)
+	}
+
+	for _, cm := range cmList.Items {
+		if !isBuildLogsConfigMapExpired(&cm, now) {
+			continue
+		}
+
+		err := b.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Delete(ctx, cm.Name, metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("could not delete expired build logs ConfigMap %q: %w", cm.Name, err)
+		}
+
+		klog.Infof("Deleted expired build logs ConfigMap %q for MachineOSConfig %q", cm.Name, mosc.Name)
+	}
+
+	return nil
+}
+
+// Constructs the ConfigMap used to store the compressed build logs. The
+// ConfigMap is owned by the MachineOSConfig so that it is garbage-collected
+// whenever the MachineOSConfig is deleted, regardless of its expiration.
+func newBuildLogsConfigMap(mosb *mcfgv1.MachineOSBuild, mosc *mcfgv1.MachineOSConfig, compressed []byte, expiration time.Time) *corev1.ConfigMap {
+	oref := metav1.NewControllerRef(mosc, mcfgv1.SchemeGroupVersion.WithKind("MachineOSConfig"))
+	falseBool := false
+	oref.Controller = &falseBool
+	oref.BlockOwnerDeletion = &falseBool
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      utils.GetBuildLogsConfigMapName(mosb),
+			Namespace: ctrlcommon.MCONamespace,
+			Labels: map[string]string{
+				constants.OnClusterLayeringLabelKey:       "",
+				constants.BuildLogsLabelKey:               "",
+				constants.MachineOSBuildNameLabelKey:      mosb.Name,
+				constants.MachineOSConfigNameLabelKey:     mosc.Name,
+				constants.TargetMachineConfigPoolLabelKey: mosc.Spec.MachineConfigPool.Name,
+			},
+			Annotations: map[string]string{
+				constants.BuildLogsExpirationAnnotationKey: expiration.UTC().Format(time.RFC3339),
+			},
+			OwnerReferences: []metav1.OwnerReference{*oref},
+		},
+		BinaryData: map[string][]byte{
+			constants.BuildLogsConfigMapKey: compressed,
+		},
+	}
+}
+
+// Gzips the provided logs. If the compressed result exceeds the maximum size,
+// the oldest portion of the logs is discarded until it fits since the end of
+// the logs is usually the most useful part for determining why a build failed.
+func compressBuildLogs(logs []byte, maxSize int) ([]byte, error) {
+	toCompress := logs
+
+	for {
+		compressed, err := gzipBytes(toCompress)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(compressed) <= maxSize {
+			return compressed, nil
+		}
+
+		if len(toCompress) == 0 {
+			return nil, fmt.Errorf("compressed size %d exceeds maximum %d", len(compressed), maxSize)
+		}
+
+		keep := len(toCompress) / 2
+		truncated := len(logs) - keep
+		marker := fmt.Sprintf("<%d bytes truncated>\n", truncated)
+		toCompress = append([]byte(marker), logs[truncated:]...)
+	}
+}
+
+func gzipBytes(in []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+
+	if _, err := gw.Write(in); err != nil {
+		return nil, err
+	}
+
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Gets the build log retention period for the given MachineOSConfig, falling
+// back to the default if the annotation is absent or invalid.
+func getBuildLogRetention(mosc *mcfgv1.MachineOSConfig) time.Duration {
+	val, ok := mosc.Annotations[constants.BuildLogRetentionAnnotationKey]
+	if !ok || val == "" {
+		return defaultBuildLogRetention
+	}
+
+	retention, err := time.ParseDuration(val)
+	if err != nil || retention <= 0 {
+		klog.Warningf("Invalid %s annotation value %q on MachineOSConfig %q, using default of %s", constants.BuildLogRetentionAnnotationKey, val, mosc.Name, defaultBuildLogRetention)
+		return defaultBuildLogRetention
+	}
+
+	return retention
+}
+
+// Determines whether the given build logs ConfigMap has expired. ConfigMaps
+// with a missing or unparseable expiration are considered expired.
+func isBuildLogsConfigMapExpired(cm *corev1.ConfigMap, now time.Time) bool {
+	expiration, err := time.Parse(time.RFC3339, cm.Annotations[constants.BuildLogsExpirationAnnotationKey])
+	if err != nil {
+		return true
+	}
+
+	return now.After(expiration)
+}
@@ -0,0 +1,242 @@
+package build
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakecorev1client "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCompressBuildLogs(t *testing.T) {
+	t.Parallel()
+
+	decompress := func(t *testing.T, in []byte) string {
+		t.Helper()
+
+		gr, err := gzip.NewReader(bytes.NewReader(in))
+		require.NoError(t, err)
+
+		out, err := io.ReadAll(gr)
+		require.NoError(t, err)
+
+		return string(out)
+	}
+
+	t.Run("Fits within limit", func(t *testing.T) {
+		t.Parallel()
+
+		logs := []byte(strings.Repeat("building layer\n", 1000))
+
+		compressed, err := compressBuildLogs(logs, maxCompressedBuildLogSize)
+		require.NoError(t, err)
+		assert.Equal(t, string(logs), decompress(t, compressed))
+	})
+
+	t.Run("Truncates oldest logs when over limit", func(t *testing.T) {
+		t.Parallel()
+
+		// Random data does not compress well, so this is guaranteed to exceed
+		// the limit.
+		logs := make([]byte, 64*1024)
+		_, err := rand.Read(logs)
+		require.NoError(t, err)
+
+		logs = append(logs, []byte("build failed\n")...)
+
+		maxSize := 16 * 1024
+
+		compressed, err := compressBuildLogs(logs, maxSize)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(compressed), maxSize)
+
+		out := decompress(t, compressed)
+		assert.Contains(t, out, "bytes truncated>")
+		assert.True(t, strings.HasSuffix(out, "build failed\n"))
+	})
+}
+
+func TestGetBuildLogRetention(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    time.Duration
+	}{
+		{
+			name:     "no annotation",
+			expected: defaultBuildLogRetention,
+		},
+		{
+			name:        "valid retention",
+			annotations: map[string]string{constants.BuildLogRetentionAnnotationKey: "72h"},
+			expected:    time.Hour * 72,
+		},
+		{
+			name:        "unparseable retention",
+			annotations: map[string]string{constants.BuildLogRetentionAnnotationKey: "forever"},
+			expected:    defaultBuildLogRetention,
+		},
+		{
+			name:        "negative retention",
+			annotations: map[string]string{constants.BuildLogRetentionAnnotationKey: "-1h"},
+			expected:    defaultBuildLogRetention,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			mosc := &mcfgv1.MachineOSConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "worker",
+					Annotations: testCase.annotations,
+				},
+			}
+
+			assert.Equal(t, testCase.expected, getBuildLogRetention(mosc))
+		})
+	}
+}
+
+func TestPruneBuildLogs(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, time.January, 8, 0, 0, 0, 0, time.UTC)
+
+	newCM := func(name, moscName string, annotations map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: ctrlcommon.MCONamespace,
+				Labels: map[string]string{
+					constants.BuildLogsLabelKey:           "",
+					constants.MachineOSConfigNameLabelKey: moscName,
+				},
+				Annotations: annotations,
+			},
+		}
+	}
+
+	expiration := func(t time.Time) map[string]string {
+		return map[string]string{constants.BuildLogsExpirationAnnotationKey: t.Format(time.RFC3339)}
+	}
+
+	kubeclient := fakecorev1client.NewSimpleClientset(
+		newCM("build-logs-expired", "worker", expiration(now.Add(-time.Hour))),
+		newCM("build-logs-current", "worker", expiration(now.Add(time.Hour))),
+		newCM("build-logs-no-expiration", "worker", nil),
+		newCM("build-logs-other-mosc", "infra", expiration(now.Add(-time.Hour))),
+	)
+
+	b := &buildReconciler{kubeclient: kubeclient}
+
+	mosc := &mcfgv1.MachineOSConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "worker",
+		},
+	}
+
+	require.NoError(t, b.pruneBuildLogs(context.TODO(), mosc, now))
+
+	cmList, err := kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+
+	remaining := []string{}
+	for _, cm := range cmList.Items {
+		remaining = append(remaining, cm.Name)
+	}
+
+	assert.ElementsMatch(t, []string{"build-logs-current", "build-logs-other-mosc"}, remaining)
+}
+
+// Tests that the logs from the architecture-specific build Jobs of a
+// multi-architecture build are collected along with those of the build Job.
+func TestCollectBuildLogs(t *testing.T) {
+	t.Parallel()
+
+	mosb := &mcfgv1.MachineOSBuild{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "worker-1234",
+			Annotations: map[string]string{
+				constants.JobUIDAnnotationKey: "build-uid",
+			},
+		},
+	}
+
+	newArchJob := func(name, uid, ownerUID string) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: ctrlcommon.MCONamespace,
+				UID:       types.UID(uid),
+				Labels: map[string]string{
+					constants.MachineOSBuildNameLabelKey: mosb.Name,
+					constants.BuildArchitectureLabelKey:  "arm64",
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						Kind: "Job",
+						Name: "build-worker-1234",
+						UID:  types.UID(ownerUID),
+					},
+				},
+			},
+		}
+	}
+
+	newPod := func(name, jobUID string, created time.Time) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         ctrlcommon.MCONamespace,
+				CreationTimestamp: metav1.NewTime(created),
+				Labels: map[string]string{
+					batchv1.ControllerUidLabel: jobUID,
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "image-build"}},
+			},
+		}
+	}
+
+	now := time.Now()
+
+	kubeclient := fakecorev1client.NewSimpleClientset(
+		newArchJob("build-worker-1234-arm64", "arm64-uid", "build-uid"),
+		// Left over from a previous build of the same name.
+		newArchJob("build-worker-1234-amd64", "stale-uid", "old-build-uid"),
+		newPod("build-pod", "build-uid", now.Add(time.Minute)),
+		newPod("arm64-pod", "arm64-uid", now),
+		newPod("stale-pod", "stale-uid", now),
+	)
+
+	b := &buildReconciler{kubeclient: kubeclient}
+
+	logs, err := b.collectBuildLogs(context.TODO(), mosb)
+	require.NoError(t, err)
+
+	out := string(logs)
+	assert.Contains(t, out, "==> pod/arm64-pod container/image-build <==")
+	assert.Contains(t, out, "==> pod/build-pod container/image-build <==")
+	assert.NotContains(t, out, "stale-pod")
+	assert.Less(t, strings.Index(out, "arm64-pod"), strings.Index(out, "build-pod"))
+}
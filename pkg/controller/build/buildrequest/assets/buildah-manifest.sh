@@ -0,0 +1,54 @@
+#!/usr/bin/env bash
+#
+# This script is not meant to be directly executed. Instead, it is embedded
+# within the Build Controller binary (see //go:embed) and injected into a
+# custom build pod.
+#
+# It assembles the images built for each architecture of a multi-architecture
+# build into a manifest list and pushes it to the final image pullspec.
+set -xeuo
+
+DEST=/etc/pki/ca-trust/extracted
+
+# Prevent p11-kit from reading user configuration files.
+export P11_KIT_NO_USER_CONFIG=1
+
+# OpenSSL PEM bundle that includes trust flags
+/usr/bin/p11-kit extract --format=openssl-bundle --filter=certificates --overwrite --comment $DEST/openssl/ca-bundle.trust.crt
+/usr/bin/p11-kit extract --format=pem-bundle --filter=ca-anchors --overwrite --comment --purpose server-auth $DEST/pem/tls-ca-bundle.pem
+
+su -m build << 'EOF'
+set -xeuo
+
+export HTTP_PROXY="${HTTP_PROXY:-}"
+export HTTPS_PROXY="${HTTPS_PROXY:-}"
+export NO_PROXY="${NO_PROXY:-}"
+
+# The final image pullspec is always tagged, so stripping the tag yields the
+# repository that each of the architecture-specific images was pushed to.
+image_repo="${TAG%:*}"
+
+buildah manifest create --storage-driver vfs "$TAG"
+
+# Each architecture-specific digest is provided by the digest ConfigMap that
+# the build job for that architecture created.
+for arch in $ARCHITECTURES; do
+	digest="$(cat "/tmp/arch-digests/$arch/digest")"
+
+	buildah manifest add \
+		--storage-driver vfs \
+		--authfile="$FINAL_IMAGE_PUSH_CREDS" \
+		--cert-dir /var/run/secrets/kubernetes.io/serviceaccount \
+		--os linux \
+		--arch "$arch" \
+		"$TAG" "docker://$image_repo@$digest"
+done
+
+# Push our manifest list.
+buildah manifest push \
+	--storage-driver vfs \
+	--authfile="$FINAL_IMAGE_PUSH_CREDS" \
+	--digestfile="/tmp/done/digestfile" \
+	--cert-dir /var/run/secrets/kubernetes.io/serviceaccount \
+	"$TAG" "docker://$TAG"
+EOF
@@ -0,0 +1,110 @@
+package buildrequest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// The names of the files within the build context. These must match the
+	// paths referenced by the Containerfile template and the build scripts.
+	containerfileFilename         string = "Containerfile"
+	machineConfigDirname          string = "machineconfig"
+	additionalTrustBundleFilename string = "openshift-config-user-ca-bundle.crt"
+)
+
+// BuildContext holds the rendered files which make up the context for a
+// layered OS image build. This is the same content which the build pod
+// assembles from its ConfigMaps, which allows an image to be built outside of
+// the cluster.
+type BuildContext struct {
+	// The rendered Containerfile.
+	Containerfile string
+	// The gzipped and base64-encoded MachineConfig.
+	MachineConfig string
+	// The additional trust bundle, which may be empty.
+	AdditionalTrustBundle []byte
+}
+
+// Renders the build context for the given options without contacting the
+// Kube API server. The MachineOSConfig, MachineOSBuild, and MachineConfig must
+// be populated. Since the build context is meant for a single local build, at
+// most one architecture may be requested.
+func NewBuildContext(opts BuildRequestOpts) (*BuildContext, error) {
+	if opts.MachineOSConfig == nil {
+		return nil, fmt.Errorf("expected MachineOSConfig to not be nil")
+	}
+
+	if opts.MachineOSBuild == nil {
+		return nil, fmt.Errorf("expected MachineOSBuild to not be nil")
+	}
+
+	if opts.MachineConfig == nil {
+		return nil, fmt.Errorf("expected MachineConfig to not be nil")
+	}
+
+	if opts.isMultiArch() {
+		return nil, fmt.Errorf("multi-architecture builds are not supported for build contexts, got: %v", opts.Architectures)
+	}
+
+	if arch := opts.getSingleArch(); arch != "" && !supportedBuildArchitectures.Has(arch) {
+		return nil, fmt.Errorf("unsupported build architecture %q, supported architectures: %v", arch, sets.List(supportedBuildArchitectures))
+	}
+
+	br := newBuildRequest(opts).(*buildRequestImpl)
+
+	containerfile, err := br.renderContainerfile()
+	if err != nil {
+		return nil, fmt.Errorf("could not render containerfile: %w", err)
+	}
+
+	out, err := json.Marshal(opts.MachineConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode MachineConfig %s: %w", opts.MachineConfig.Name, err)
+	}
+
+	compressed, err := compressAndEncode(out)
+	if err != nil {
+		return nil, fmt.Errorf("could not compress or encode MachineConfig %s: %w", opts.MachineConfig.Name, err)
+	}
+
+	return &BuildContext{
+		Containerfile:         containerfile,
+		MachineConfig:         compressed.String(),
+		AdditionalTrustBundle: opts.AdditionalTrustBundle,
+	}, nil
+}
+
+// Writes the build context into the given directory, creating it if needed.
+// The directory may then be passed directly to Buildah or Podman.
+func (b *BuildContext) WriteToDir(dir string) error {
+	if err := os.MkdirAll(filepath.Join(dir, machineConfigDirname), 0o755); err != nil {
+		return fmt.Errorf("could not create build context directory %s: %w", dir, err)
+	}
+
+	files := map[string][]byte{
+		b.ContainerfilePath(dir): []byte(b.Containerfile),
+		filepath.Join(dir, machineConfigDirname, machineConfigJSONFilename): []byte(b.MachineConfig),
+		// The Containerfile unconditionally copies this file, so it must exist
+		// even when there is no additional trust bundle.
+		filepath.Join(dir, additionalTrustBundleFilename): b.AdditionalTrustBundle,
+	}
+
+	for path, contents := range files {
+		if err := os.WriteFile(path, contents, 0o644); err != nil {
+			return fmt.Errorf("could not write build context file %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// Gets the path to the Containerfile within a build context written to the
+// given directory.
+func (b *BuildContext) ContainerfilePath(dir string) string {
+	return filepath.Join(dir, containerfileFilename)
+}
@@ -0,0 +1,76 @@
+package buildrequest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests that the build context can be rendered and written without the Kube
+// API server and that it contains every file the Containerfile references.
+func TestBuildContext(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Writes build context", func(t *testing.T) {
+		t.Parallel()
+
+		opts := getBuildRequestOpts()
+
+		bc, err := NewBuildContext(opts)
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		require.NoError(t, bc.WriteToDir(dir))
+
+		containerfile, err := os.ReadFile(bc.ContainerfilePath(dir))
+		require.NoError(t, err)
+		assert.Contains(t, string(containerfile), "COPY ./machineconfig/machineconfig.json.gz")
+		assert.Contains(t, string(containerfile), "FROM configs AS final")
+
+		mc, err := os.ReadFile(filepath.Join(dir, "machineconfig", "machineconfig.json.gz"))
+		require.NoError(t, err)
+		assert.NotEmpty(t, mc)
+
+		assert.FileExists(t, filepath.Join(dir, "openshift-config-user-ca-bundle.crt"))
+	})
+
+	t.Run("Uses architecture-specific Containerfile", func(t *testing.T) {
+		t.Parallel()
+
+		opts := getBuildRequestOpts()
+		opts.Architectures = []string{"arm64"}
+		opts.MachineOSConfig.Spec.Containerfile = append(opts.MachineOSConfig.Spec.Containerfile, mcfgv1.MachineOSContainerfile{
+			ContainerfileArch: mcfgv1.Arm64,
+			Content:           "FROM configs AS final\nRUN echo arm64",
+		})
+
+		bc, err := NewBuildContext(opts)
+		require.NoError(t, err)
+		assert.Contains(t, bc.Containerfile, "RUN echo arm64")
+		assert.NotContains(t, bc.Containerfile, "RUN echo 'hi'")
+	})
+
+	t.Run("Rejects multiple architectures", func(t *testing.T) {
+		t.Parallel()
+
+		opts := getBuildRequestOpts()
+		opts.Architectures = []string{"amd64", "arm64"}
+
+		_, err := NewBuildContext(opts)
+		assert.Error(t, err)
+	})
+
+	t.Run("Requires MachineConfig", func(t *testing.T) {
+		t.Parallel()
+
+		opts := getBuildRequestOpts()
+		opts.MachineConfig = nil
+
+		_, err := NewBuildContext(opts)
+		assert.Error(t, err)
+	})
+}
@@ -37,6 +37,9 @@ var buildahBuildScript string
 //go:embed assets/podman-build.sh
 var podmanBuildScript string
 
+//go:embed assets/buildah-manifest.sh
+var buildahManifestScript string
+
 const (
 	// Filename for the machineconfig JSON tarball expected by the build job
 	machineConfigJSONFilename string = "machineconfig.json.gz"
@@ -83,6 +86,12 @@ var instructionRequirements = map[string]string{
 type buildRequestImpl struct {
 	opts              BuildRequestOpts
 	userContainerfile string
+	// The architecture this request builds an image for within a
+	// multi-architecture build. Empty for single-architecture builds and for
+	// the manifest list job.
+	arch string
+	// The pullspec the architecture-specific image is pushed to.
+	archImagePushSpec string
 }
 
 // Constructs an imageBuildRequest from the Kube API server.
@@ -98,15 +107,8 @@ func NewBuildRequestFromAPI(ctx context.Context, kubeclient clientset.Interface,
 // Constructs an imageBuildRequest from the provided options.
 func newBuildRequest(opts BuildRequestOpts) BuildRequest {
 	br := &buildRequestImpl{
-		opts: opts,
-	}
-
-	// only support noArch for now
-	for _, file := range opts.MachineOSConfig.Spec.Containerfile {
-		if file.ContainerfileArch == mcfgv1.NoArch {
-			br.userContainerfile = file.Content
-			break
-		}
+		opts:              opts,
+		userContainerfile: getUserContainerfile(opts.MachineOSConfig, opts.getSingleArch()),
 	}
 
 	// Validate user's Containerfile if provided
@@ -119,16 +121,105 @@ func newBuildRequest(opts BuildRequestOpts) BuildRequest {
 	return br
 }
 
+// Gets the user-provided Containerfile for the given architecture, falling
+// back to the architecture-independent Containerfile if there is not one
+// specific to that architecture. An empty arch only considers the
+// architecture-independent Containerfile.
+func getUserContainerfile(mosc *mcfgv1.MachineOSConfig, arch string) string {
+	// ContainerfileArch values are the uppercased GOARCH values.
+	archContainerfile := mcfgv1.ContainerfileArch(strings.ToUpper(arch))
+
+	noArch := ""
+
+	for _, file := range mosc.Spec.Containerfile {
+		if arch != "" && file.ContainerfileArch == archContainerfile {
+			return file.Content
+		}
+
+		if file.ContainerfileArch == mcfgv1.NoArch && noArch == "" {
+			noArch = file.Content
+		}
+	}
+
+	return noArch
+}
+
+// Constructs a copy of the build request which builds the image for a single
+// architecture within a multi-architecture build.
+func (br buildRequestImpl) forArch(arch string) (buildRequestImpl, error) {
+	pushspec, err := utils.GetArchImagePushSpec(string(br.opts.MachineOSBuild.Spec.RenderedImagePushSpec), arch)
+	if err != nil {
+		return buildRequestImpl{}, fmt.Errorf("could not get image pullspec for architecture %s: %w", arch, err)
+	}
+
+	archBr := br
+	archBr.arch = arch
+	archBr.archImagePushSpec = pushspec
+	archBr.userContainerfile = getUserContainerfile(br.opts.MachineOSConfig, arch)
+
+	if archBr.userContainerfile != "" {
+		if err := archBr.validateContainerfileSyntax(archBr.userContainerfile); err != nil {
+			klog.Warningf("User Containerfile validation failed for architecture %s", arch)
+		}
+	}
+
+	return archBr, nil
+}
+
+// Constructs the build requests for each architecture within a
+// multi-architecture build.
+func (br buildRequestImpl) archBuildRequests() ([]buildRequestImpl, error) {
+	if !br.opts.isMultiArch() {
+		return nil, nil
+	}
+
+	out := []buildRequestImpl{}
+
+	for _, arch := range br.opts.Architectures {
+		archBr, err := br.forArch(arch)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, archBr)
+	}
+
+	return out, nil
+}
+
 // Returns the options used within the imageBuildRequest.
 func (br buildRequestImpl) Opts() BuildRequestOpts {
 	return br.opts
 }
 
-// Creates the Build Job object.
+// Creates the Build Job object. For multi-architecture builds, this Job
+// assembles the images built by the Jobs returned by ArchBuilders() into a
+// manifest list.
 func (br buildRequestImpl) Builder() Builder {
+	if br.opts.isMultiArch() {
+		return newBuilder(br.podToJob(br.toManifestListPod()))
+	}
+
 	return newBuilder(br.podToJob(br.toBuildahPod()))
 }
 
+// Creates the Build Job objects for each architecture within a
+// multi-architecture build. Returns nothing for single-architecture builds.
+func (br buildRequestImpl) ArchBuilders() ([]Builder, error) {
+	archBrs, err := br.archBuildRequests()
+	if err != nil {
+		return nil, err
+	}
+
+	builders := []Builder{}
+
+	for _, archBr := range archBrs {
+		builders = append(builders, newBuilder(archBr.podToJob(archBr.toBuildahPod())))
+	}
+
+	return builders, nil
+}
+
 // Takes the configured secrets and creates an ephemeral clone of them, canonicalizing them, if needed.
 func (br buildRequestImpl) Secrets() ([]*corev1.Secret, error) {
 	baseImagePullSecret, err := br.canonicalizeSecret(br.getBasePullSecretName(), br.opts.BaseImagePullSecret)
@@ -150,7 +241,7 @@ func (br buildRequestImpl) Secrets() ([]*corev1.Secret, error) {
 // Creates all of the ConfigMap objects needed for the build such as the
 // Containerfile, MachineConfig and AdditionalTrustBundle ConfigMaps.
 func (br buildRequestImpl) ConfigMaps() ([]*corev1.ConfigMap, error) {
-	containerfile, err := br.containerfileToConfigMap()
+	containerfiles, err := br.containerfilesToConfigMaps()
 	if err != nil {
 		return nil, err
 	}
@@ -175,7 +266,7 @@ func (br buildRequestImpl) ConfigMaps() ([]*corev1.ConfigMap, error) {
 		return nil, fmt.Errorf("could not convert registries.conf files into ConfigMap %q: %w", br.getEtcRegistriesConfigMapName(), err)
 	}
 
-	configMaps := []*corev1.ConfigMap{containerfile, machineconfig, additionaltrustbundle}
+	configMaps := append(containerfiles, machineconfig, additionaltrustbundle)
 	if etcPolicy != nil {
 		configMaps = append(configMaps, etcPolicy)
 	} else {
@@ -206,6 +297,37 @@ func (br buildRequestImpl) canonicalizeSecret(name string, secret *corev1.Secret
 	return canonicalized, nil
 }
 
+// Renders the Containerfile for each architecture being built. The manifest
+// list job for a multi-architecture build does not need a Containerfile.
+func (br buildRequestImpl) containerfilesToConfigMaps() ([]*corev1.ConfigMap, error) {
+	if !br.opts.isMultiArch() {
+		containerfile, err := br.containerfileToConfigMap()
+		if err != nil {
+			return nil, err
+		}
+
+		return []*corev1.ConfigMap{containerfile}, nil
+	}
+
+	archBrs, err := br.archBuildRequests()
+	if err != nil {
+		return nil, err
+	}
+
+	configMaps := []*corev1.ConfigMap{}
+
+	for _, archBr := range archBrs {
+		containerfile, err := archBr.containerfileToConfigMap()
+		if err != nil {
+			return nil, fmt.Errorf("could not render Containerfile for architecture %s: %w", archBr.arch, err)
+		}
+
+		configMaps = append(configMaps, containerfile)
+	}
+
+	return configMaps, nil
+}
+
 // Renders our Containerfile and injects it into a ConfigMap for consumption by the image builder.
 func (br buildRequestImpl) containerfileToConfigMap() (*corev1.ConfigMap, error) {
 	containerfile, err := br.renderContainerfile()
@@ -358,7 +480,7 @@ func (br buildRequestImpl) renderContainerfile() (string, error) {
 		return "", err
 	}
 
-	kernelType, kernelPackages, err := br.opts.getKernelPackages()
+	kernelType, kernelPackages, err := br.opts.getKernelPackages(br.getTargetArch())
 	if err != nil {
 		return "", err
 	}
@@ -647,7 +769,7 @@ func (br buildRequestImpl) toBuildahPod() *corev1.Pod {
 		},
 		{
 			Name:  "TAG",
-			Value: string(br.opts.MachineOSBuild.Spec.RenderedImagePushSpec),
+			Value: br.getImagePushSpec(),
 		},
 		{
 			Name:  "BASE_IMAGE_PULL_CREDS",
@@ -862,6 +984,15 @@ func (br buildRequestImpl) toBuildahPod() *corev1.Pod {
 
 	var terminationGracePeriodSeconds int64 = 10
 
+	// Ensure that the image for a given architecture is built on a node of
+	// that architecture.
+	var nodeSelector map[string]string
+	if arch := br.getTargetArch(); arch != "" {
+		nodeSelector = map[string]string{
+			corev1.LabelArchStable: arch,
+		}
+	}
+
 	return &corev1.Pod{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
@@ -870,6 +1001,7 @@ func (br buildRequestImpl) toBuildahPod() *corev1.Pod {
 		ObjectMeta: br.getObjectMeta(br.getBuildName()),
 		Spec: corev1.PodSpec{
 			RestartPolicy: corev1.RestartPolicyNever,
+			NodeSelector:  nodeSelector,
 			// Run the build process in an init container so that we can report accurate
 			// status if the build process is successful but the configmap creation container fails
 			InitContainers: []corev1.Container{
@@ -914,9 +1046,198 @@ func (br buildRequestImpl) toBuildahPod() *corev1.Pod {
 	}
 }
 
+// Constructs the pod which assembles the images built for each architecture
+// into a manifest list and pushes it. Since the digest ConfigMaps for each
+// architecture are not optional volumes, this pod will not start until all of
+// the architecture-specific images have been built and pushed.
+func (br buildRequestImpl) toManifestListPod() *corev1.Pod {
+	var httpProxy, httpsProxy, noProxy string
+	if br.opts.Proxy != nil {
+		httpProxy = br.opts.Proxy.HTTPProxy
+		httpsProxy = br.opts.Proxy.HTTPSProxy
+		noProxy = br.opts.Proxy.NoProxy
+	}
+
+	env := []corev1.EnvVar{
+		{
+			Name:  "DIGEST_CONFIGMAP_NAME",
+			Value: br.getDigestConfigMapName(),
+		},
+		{
+			Name:  "DIGEST_CONFIGMAP_LABELS",
+			Value: labels.Set(br.getLabelsForObjectMeta()).String(),
+		},
+		{
+			Name:  "HOME",
+			Value: "/home/build",
+		},
+		{
+			Name:  "TAG",
+			Value: br.getImagePushSpec(),
+		},
+		{
+			Name:  "ARCHITECTURES",
+			Value: strings.Join(br.opts.Architectures, " "),
+		},
+		{
+			Name:  "FINAL_IMAGE_PUSH_CREDS",
+			Value: "/tmp/final-image-push-creds/config.json",
+		},
+		{
+			Name:  "HTTP_PROXY",
+			Value: httpProxy,
+		},
+		{
+			Name:  "HTTPS_PROXY",
+			Value: httpsProxy,
+		},
+		{
+			Name:  "NO_PROXY",
+			Value: noProxy,
+		},
+	}
+
+	securityContext := &corev1.SecurityContext{}
+
+	command := []string{"/bin/bash", "-c"}
+
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      "additional-trust-bundle",
+			MountPath: "/etc/pki/ca-trust/source/anchors",
+		},
+		{
+			Name:      "final-image-push-creds",
+			MountPath: "/tmp/final-image-push-creds",
+		},
+		{
+			Name:      "done",
+			MountPath: "/tmp/done",
+		},
+	}
+
+	volumes := []corev1.Volume{
+		{
+			// Provides the user defined Additional Trust Bundle
+			Name: "additional-trust-bundle",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: br.getAdditionalTrustBundleConfigMapName(),
+					},
+				},
+			},
+		},
+		{
+			// Provides the credentials needed to push the manifest list.
+			Name: "final-image-push-creds",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: br.getFinalPushSecretName(),
+					Items: []corev1.KeyToPath{
+						{
+							Key:  corev1.DockerConfigJsonKey,
+							Path: "config.json",
+						},
+					},
+				},
+			},
+		},
+		{
+			// Provides a way for the "image-build" container to signal that it
+			// finished so that the "create-digest-configmap" container can
+			// retrieve the manifest list SHA.
+			Name: "done",
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{
+					Medium: corev1.StorageMediumMemory,
+				},
+			},
+		},
+		{
+			// Provides a place for Buildah to store the manifest list.
+			Name: "buildah-cache",
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		},
+	}
+
+	// Only the image-build container needs the architecture-specific digests.
+	buildVolumeMounts := append([]corev1.VolumeMount{}, volumeMounts...)
+	buildVolumeMounts = append(buildVolumeMounts, corev1.VolumeMount{
+		Name:      "buildah-cache",
+		MountPath: "/home/build/.local/share/containers",
+	})
+
+	for _, arch := range br.opts.Architectures {
+		volumeName := fmt.Sprintf("digest-%s", arch)
+
+		volumes = append(volumes, corev1.Volume{
+			// Provides the digest of the image built for this architecture.
+			Name: volumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: utils.GetArchDigestConfigMapName(br.opts.MachineOSBuild, arch),
+					},
+				},
+			},
+		})
+
+		buildVolumeMounts = append(buildVolumeMounts, corev1.VolumeMount{
+			Name:      volumeName,
+			MountPath: fmt.Sprintf("/tmp/arch-digests/%s", arch),
+		})
+	}
+
+	var terminationGracePeriodSeconds int64 = 10
+
+	return &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: br.getObjectMeta(br.getBuildName()),
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			InitContainers: []corev1.Container{
+				{
+					// This container assembles and pushes the manifest list.
+					Name:                     "image-build",
+					Image:                    br.opts.Images.MachineConfigOperator,
+					Env:                      env,
+					Command:                  append(command, buildahManifestScript),
+					ImagePullPolicy:          corev1.PullAlways,
+					SecurityContext:          securityContext,
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+					VolumeMounts:             buildVolumeMounts,
+				},
+			},
+			Containers: []corev1.Container{
+				{
+					// This container creates the digest ConfigMap for the manifest
+					// list in the same way as for single-architecture builds.
+					Name:                     "create-digest-configmap",
+					Command:                  append(command, digestCMScript),
+					Image:                    br.opts.Images.MachineConfigOperator,
+					Env:                      env,
+					ImagePullPolicy:          corev1.PullAlways,
+					SecurityContext:          securityContext,
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+					VolumeMounts:             volumeMounts,
+				},
+			},
+			ServiceAccountName:            "machine-os-builder",
+			Volumes:                       volumes,
+			TerminationGracePeriodSeconds: &terminationGracePeriodSeconds,
+		},
+	}
+}
+
 // Populates the labels map for all objects created by imageBuildRequest
 func (br buildRequestImpl) getLabelsForObjectMeta() map[string]string {
-	return map[string]string{
+	objLabels := map[string]string{
 		constants.EphemeralBuildObjectLabelKey:    "",
 		constants.OnClusterLayeringLabelKey:       "",
 		constants.RenderedMachineConfigLabelKey:   br.opts.MachineOSBuild.Spec.MachineConfig.Name,
@@ -924,6 +1245,12 @@ func (br buildRequestImpl) getLabelsForObjectMeta() map[string]string {
 		constants.MachineOSConfigNameLabelKey:     br.opts.MachineOSConfig.Name,
 		constants.MachineOSBuildNameLabelKey:      br.opts.MachineOSBuild.Name,
 	}
+
+	if br.arch != "" {
+		objLabels[constants.BuildArchitectureLabelKey] = br.arch
+	}
+
+	return objLabels
 }
 
 // Populates the annotations map for all objects created by imageBuildRequest.
@@ -967,6 +1294,10 @@ func (br buildRequestImpl) getAdditionalTrustBundleConfigMapName() string {
 
 // Computes the Containerfile ConfigMap name based upon the MachineConfigPool name.
 func (br buildRequestImpl) getContainerfileConfigMapName() string {
+	if br.arch != "" {
+		return utils.GetArchContainerfileConfigMapName(br.opts.MachineOSBuild, br.arch)
+	}
+
 	return utils.GetContainerfileConfigMapName(br.opts.MachineOSBuild)
 }
 
@@ -985,13 +1316,42 @@ func (br buildRequestImpl) getEtcRegistriesConfigMapName() string {
 
 // Computes the build name based upon the MachineConfigPool name.
 func (br buildRequestImpl) getBuildName() string {
+	if br.arch != "" {
+		return utils.GetArchBuildJobName(br.opts.MachineOSBuild, br.arch)
+	}
+
 	return utils.GetBuildJobName(br.opts.MachineOSBuild)
 }
 
 func (br buildRequestImpl) getDigestConfigMapName() string {
+	if br.arch != "" {
+		return utils.GetArchDigestConfigMapName(br.opts.MachineOSBuild, br.arch)
+	}
+
 	return utils.GetDigestConfigMapName(br.opts.MachineOSBuild)
 }
 
+// Gets the architecture that the image is built for. This is either the
+// architecture of an architecture-specific build within a multi-architecture
+// build or the only architecture requested. An empty string means the
+// architecture of whichever node the build pod is scheduled to.
+func (br buildRequestImpl) getTargetArch() string {
+	if br.arch != "" {
+		return br.arch
+	}
+
+	return br.opts.getSingleArch()
+}
+
+// Gets the pullspec that the built image is pushed to.
+func (br buildRequestImpl) getImagePushSpec() string {
+	if br.arch != "" {
+		return br.archImagePushSpec
+	}
+
+	return string(br.opts.MachineOSBuild.Spec.RenderedImagePushSpec)
+}
+
 func (br buildRequestImpl) getBasePullSecretName() string {
 	return utils.GetBasePullSecretName(br.opts.MachineOSBuild)
 }
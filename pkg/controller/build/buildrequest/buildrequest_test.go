@@ -197,6 +197,125 @@ func TestBuildRequest(t *testing.T) {
 	}
 }
 
+// Tests that a BuildRequest for more than one architecture creates a build job
+// for each architecture along with a job which assembles the manifest list.
+func TestMultiArchBuildRequest(t *testing.T) {
+	t.Parallel()
+
+	opts := getBuildRequestOpts()
+	opts.Architectures = []string{"amd64", "arm64"}
+	opts.MachineOSConfig.Spec.Containerfile = append(opts.MachineOSConfig.Spec.Containerfile, mcfgv1.MachineOSContainerfile{
+		ContainerfileArch: mcfgv1.Arm64,
+		Content:           "FROM configs AS final\nRUN echo arm64",
+	})
+
+	br := newBuildRequest(opts)
+
+	configmaps, err := br.ConfigMaps()
+	assert.NoError(t, err)
+
+	containerfiles := map[string]string{}
+	for _, cm := range configmaps {
+		if contents, ok := cm.Data["Containerfile"]; ok {
+			containerfiles[cm.Name] = contents
+		}
+	}
+
+	assert.NotContains(t, containerfiles, utils.GetContainerfileConfigMapName(opts.MachineOSBuild))
+	assert.Contains(t, containerfiles[utils.GetArchContainerfileConfigMapName(opts.MachineOSBuild, "amd64")], "ostree container commit")
+	assert.Contains(t, containerfiles[utils.GetArchContainerfileConfigMapName(opts.MachineOSBuild, "arm64")], "RUN echo arm64")
+
+	archBuilders, err := br.ArchBuilders()
+	assert.NoError(t, err)
+	assert.Len(t, archBuilders, 2)
+
+	for i, arch := range opts.Architectures {
+		archJob := archBuilders[i].GetObject().(*batchv1.Job)
+		podSpec := archJob.Spec.Template.Spec
+
+		assert.Equal(t, utils.GetArchBuildJobName(opts.MachineOSBuild, arch), archJob.Name)
+		assert.Equal(t, arch, archJob.Labels[constants.BuildArchitectureLabelKey])
+		assert.True(t, utils.IsArchBuildObject(archJob))
+		assert.Equal(t, map[string]string{corev1.LabelArchStable: arch}, podSpec.NodeSelector)
+		assert.Contains(t, podSpec.InitContainers[0].Env, corev1.EnvVar{
+			Name:  "TAG",
+			Value: "registry.hostname.com/org/repo:worker-afc35db0f874c9bfdc586e6ba39f1504-" + arch,
+		})
+		assert.Contains(t, podSpec.InitContainers[0].Env, corev1.EnvVar{
+			Name:  "DIGEST_CONFIGMAP_NAME",
+			Value: utils.GetArchDigestConfigMapName(opts.MachineOSBuild, arch),
+		})
+	}
+
+	buildJob := br.Builder().GetObject().(*batchv1.Job)
+	podSpec := buildJob.Spec.Template.Spec
+
+	assert.Equal(t, utils.GetBuildJobName(opts.MachineOSBuild), buildJob.Name)
+	assert.False(t, utils.IsArchBuildObject(buildJob))
+	assert.Empty(t, podSpec.NodeSelector)
+	assert.Contains(t, podSpec.InitContainers[0].Env, corev1.EnvVar{Name: "ARCHITECTURES", Value: "amd64 arm64"})
+	assert.Contains(t, podSpec.Containers[0].Env, corev1.EnvVar{
+		Name:  "TAG",
+		Value: "registry.hostname.com/org/repo:worker-afc35db0f874c9bfdc586e6ba39f1504",
+	})
+
+	for _, arch := range opts.Architectures {
+		assertPodHasVolume(t, podSpec, corev1.Volume{
+			Name: "digest-" + arch,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: utils.GetArchDigestConfigMapName(opts.MachineOSBuild, arch),
+					},
+				},
+			},
+		})
+	}
+}
+
+// Tests that a BuildRequest for a single architecture builds the image on a
+// node of that architecture without a manifest list.
+func TestSingleArchBuildRequest(t *testing.T) {
+	t.Parallel()
+
+	opts := getBuildRequestOpts()
+	opts.Architectures = []string{"arm64"}
+	opts.MachineOSConfig.Spec.Containerfile = append(opts.MachineOSConfig.Spec.Containerfile, mcfgv1.MachineOSContainerfile{
+		ContainerfileArch: mcfgv1.Arm64,
+		Content:           "FROM configs AS final\nRUN echo arm64",
+	})
+
+	br := newBuildRequest(opts)
+
+	configmaps, err := br.ConfigMaps()
+	assert.NoError(t, err)
+
+	containerfiles := map[string]string{}
+	for _, cm := range configmaps {
+		if contents, ok := cm.Data["Containerfile"]; ok {
+			containerfiles[cm.Name] = contents
+		}
+	}
+
+	assert.Len(t, containerfiles, 1)
+	assert.Contains(t, containerfiles[utils.GetContainerfileConfigMapName(opts.MachineOSBuild)], "RUN echo arm64")
+
+	archBuilders, err := br.ArchBuilders()
+	assert.NoError(t, err)
+	assert.Empty(t, archBuilders)
+
+	buildJob := br.Builder().GetObject().(*batchv1.Job)
+	podSpec := buildJob.Spec.Template.Spec
+
+	assert.Equal(t, utils.GetBuildJobName(opts.MachineOSBuild), buildJob.Name)
+	assert.False(t, utils.IsArchBuildObject(buildJob))
+	assert.Equal(t, map[string]string{corev1.LabelArchStable: "arm64"}, podSpec.NodeSelector)
+	assert.Contains(t, podSpec.InitContainers[0].Env, corev1.EnvVar{
+		Name:  "TAG",
+		Value: "registry.hostname.com/org/repo:worker-afc35db0f874c9bfdc586e6ba39f1504",
+	})
+}
+
 func assertSecretInCorrectFormat(t *testing.T, secret *corev1.Secret) {
 	t.Helper()
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	goruntime "runtime"
+	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -17,10 +18,21 @@ import (
 	"github.com/openshift/machine-config-operator/pkg/secrets"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 )
 
+// The architectures which may be requested via the build architectures
+// annotation. These are the GOARCH values for the architectures that RHCOS
+// supports.
+var supportedBuildArchitectures = sets.New[string](
+	ctrlcommon.GoArchAMD64,
+	ctrlcommon.GoArchARM64,
+	"ppc64le",
+	"s390x",
+)
+
 // Holds all of the options used to produce a BuildRequest.
 type BuildRequestOpts struct { //nolint:revive // This name is fine.
 	MachineOSConfig *mcfgv1.MachineOSConfig
@@ -44,10 +56,33 @@ type BuildRequestOpts struct { //nolint:revive // This name is fine.
 	Proxy *configv1.ProxyStatus
 	// Additional trust bundles for proxy (user defined)
 	AdditionalTrustBundle []byte
+
+	// The architectures to build images for. When more than one is provided,
+	// an image is built for each and published as a manifest list.
+	Architectures []string
+}
+
+// Determines whether images should be built for more than one architecture.
+func (b BuildRequestOpts) isMultiArch() bool {
+	return len(b.Architectures) > 1
+}
+
+// Gets the architecture to build the image for when exactly one was
+// requested. Returns an empty string otherwise.
+func (b BuildRequestOpts) getSingleArch() string {
+	if len(b.Architectures) == 1 {
+		return b.Architectures[0]
+	}
+
+	return ""
 }
 
-// Gets the packages for the kernel from the MachineConfig, if available.
-func (b BuildRequestOpts) getKernelPackages() (string, map[string][]string, error) {
+// Gets the packages for the kernel from the MachineConfig, if available. An
+// empty arch means the architecture of the build controller.
+func (b BuildRequestOpts) getKernelPackages(arch string) (string, map[string][]string, error) {
+	if arch == "" {
+		arch = goruntime.GOARCH
+	}
 
 	newKtype := helpers.CanonicalizeKernelType(b.MachineConfig.Spec.KernelType)
 	if newKtype == ctrlcommon.KernelTypeDefault {
@@ -55,7 +90,7 @@ func (b BuildRequestOpts) getKernelPackages() (string, map[string][]string, erro
 	}
 
 	// 64K memory pages kernel is only supported for aarch64
-	if newKtype == ctrlcommon.KernelType64kPages && goruntime.GOARCH != ctrlcommon.GoArchARM64 {
+	if newKtype == ctrlcommon.KernelType64kPages && arch != ctrlcommon.GoArchARM64 {
 		return "", nil, fmt.Errorf("64k-pages is only supported for aarch64 architecture")
 	}
 
@@ -161,6 +196,18 @@ func (o *optsGetter) getOpts(ctx context.Context, mosb *mcfgv1.MachineOSBuild, m
 		return nil, fmt.Errorf("could not validate MachineOSBuild: %w", err)
 	}
 
+	// Prefer the architectures recorded on the MachineOSBuild when it was
+	// created so that they match the per-architecture images it references.
+	var archSource metav1.Object = mosc
+	if _, ok := mosb.Annotations[constants.BuildArchitecturesAnnotationKey]; ok {
+		archSource = mosb
+	}
+
+	architectures, err := getBuildArchitectures(archSource)
+	if err != nil {
+		return nil, fmt.Errorf("could not validate MachineOSConfig: %w", err)
+	}
+
 	opts, err := o.resolveEntitlements(ctx, mosc)
 	if err != nil {
 		return nil, fmt.Errorf("unable to resolve entitlements for MachineOSBuild %s: %w", mosb.Name, err)
@@ -210,10 +257,43 @@ func (o *optsGetter) getOpts(ctx context.Context, mosb *mcfgv1.MachineOSBuild, m
 	opts.MachineOSBuild = mosb.DeepCopy()
 	opts.Proxy = cc.Spec.Proxy
 	opts.AdditionalTrustBundle = cc.Spec.AdditionalTrustBundle
+	opts.Architectures = architectures
 
 	return opts, nil
 }
 
+// Parses the build architectures annotation on a MachineOSConfig or
+// MachineOSBuild, if present. Architectures are deduplicated and returned in
+// sorted order so that the objects created for them are stable across
+// reconciliations.
+func getBuildArchitectures(obj metav1.Object) ([]string, error) {
+	val, ok := obj.GetAnnotations()[constants.BuildArchitecturesAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	archs := sets.New[string]()
+
+	for _, arch := range strings.Split(val, ",") {
+		arch = strings.TrimSpace(arch)
+		if arch == "" {
+			continue
+		}
+
+		if !supportedBuildArchitectures.Has(arch) {
+			return nil, fmt.Errorf("invalid %s annotation value %q: unsupported architecture %q, expected one of %v", constants.BuildArchitecturesAnnotationKey, val, arch, sets.List(supportedBuildArchitectures))
+		}
+
+		archs.Insert(arch)
+	}
+
+	if archs.Len() == 0 {
+		return nil, fmt.Errorf("invalid %s annotation value %q: no architectures specified", constants.BuildArchitecturesAnnotationKey, val)
+	}
+
+	return sets.List(archs), nil
+}
+
 // Gets an image pull secret and validates that it is usable.
 func (o *optsGetter) getValidatedSecret(ctx context.Context, name string) (*corev1.Secret, error) {
 	secret, err := o.kubeclient.CoreV1().Secrets(ctrlcommon.MCONamespace).Get(ctx, name, metav1.GetOptions{})
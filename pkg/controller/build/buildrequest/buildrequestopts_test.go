@@ -153,3 +153,61 @@ func TestBuildRequestOpts(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBuildArchitectures(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    []string
+		errExpected bool
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name:        "single architecture",
+			annotations: map[string]string{constants.BuildArchitecturesAnnotationKey: "arm64"},
+			expected:    []string{"arm64"},
+		},
+		{
+			name:        "multiple architectures are sorted and deduplicated",
+			annotations: map[string]string{constants.BuildArchitecturesAnnotationKey: "arm64, amd64,arm64"},
+			expected:    []string{"amd64", "arm64"},
+		},
+		{
+			name:        "unsupported architecture",
+			annotations: map[string]string{constants.BuildArchitecturesAnnotationKey: "amd64,riscv64"},
+			errExpected: true,
+		},
+		{
+			name:        "empty value",
+			annotations: map[string]string{constants.BuildArchitecturesAnnotationKey: " , "},
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			mosc := &mcfgv1.MachineOSConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "worker",
+					Annotations: testCase.annotations,
+				},
+			}
+
+			archs, err := getBuildArchitectures(mosc)
+			if testCase.errExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, archs)
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 type BuildRequest interface {
 	Opts() BuildRequestOpts
 	Builder() Builder
+	ArchBuilders() ([]Builder, error)
 	Secrets() ([]*corev1.Secret, error)
 	ConfigMaps() ([]*corev1.ConfigMap, error)
 }
@@ -227,5 +227,12 @@ func NewMachineOSBuild(opts MachineOSBuildOpts) (*mcfgv1.MachineOSBuild, error)
 		},
 	}
 
+	// Record the architectures being built so that the per-architecture images
+	// can be found and pruned later, even if the MachineOSConfig changes. An
+	// invalid value is not recorded so that the build reports the error.
+	if archs, err := getBuildArchitectures(opts.MachineOSConfig); err == nil && len(archs) != 0 {
+		mosb.Annotations[constants.BuildArchitecturesAnnotationKey] = strings.Join(archs, ",")
+	}
+
 	return mosb, nil
 }
@@ -8,6 +8,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 
 	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
 	"github.com/openshift/machine-config-operator/pkg/controller/build/fixtures"
 	"github.com/openshift/machine-config-operator/pkg/controller/build/utils"
 	testhelpers "github.com/openshift/machine-config-operator/test/helpers"
@@ -158,3 +159,37 @@ func TestMachineOSBuildLabelConsistency(t *testing.T) {
 	assert.Equal(t, mosb.Labels, utils.GetMachineOSBuildLabels(obj.MachineOSConfig, obj.MachineConfigPool))
 	assert.Equal(t, obj.MachineOSBuild.Labels, mosb.Labels)
 }
+
+// Tests that the architectures requested by a MachineOSConfig are recorded on
+// the MachineOSBuild in their normalized form.
+func TestMachineOSBuildRecordsBuildArchitectures(t *testing.T) {
+	t.Parallel()
+
+	poolName := "worker"
+
+	obj := fixtures.NewObjectsForTest(poolName)
+	obj.MachineOSConfig.Annotations = map[string]string{
+		constants.BuildArchitecturesAnnotationKey: "arm64, amd64",
+	}
+
+	mosb, err := NewMachineOSBuild(MachineOSBuildOpts{
+		MachineConfig:     obj.RenderedMachineConfig,
+		MachineConfigPool: obj.MachineConfigPool,
+		MachineOSConfig:   obj.MachineOSConfig,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "amd64,arm64", mosb.Annotations[constants.BuildArchitecturesAnnotationKey])
+
+	// An invalid value is not recorded.
+	obj.MachineOSConfig.Annotations[constants.BuildArchitecturesAnnotationKey] = "riscv64"
+
+	mosb, err = NewMachineOSBuild(MachineOSBuildOpts{
+		MachineConfig:     obj.RenderedMachineConfig,
+		MachineConfigPool: obj.MachineConfigPool,
+		MachineOSConfig:   obj.MachineOSConfig,
+	})
+
+	assert.NoError(t, err)
+	assert.NotContains(t, mosb.Annotations, constants.BuildArchitecturesAnnotationKey)
+}
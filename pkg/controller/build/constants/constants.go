@@ -48,6 +48,94 @@ const (
 	RebuildMachineOSConfigAnnotationKey string = "machineconfiguration.openshift.io/rebuild"
 )
 
+// When this annotation is added to a MachineOSConfig, its current
+// MachineOSBuild will be rebuilt once the given interval (expressed as a Go
+// duration, e.g., "168h") has elapsed since that build finished. This allows
+// content pulled in by a custom Containerfile (such as RPM updates from its
+// repositories) to be picked up without anyone having to manually request a
+// rebuild.
+const (
+	RebuildIntervalAnnotationKey string = "machineconfiguration.openshift.io/rebuild-interval"
+)
+
+// When this annotation is set to "true" on a MachineOSConfig, the base OS
+// image and extensions image digests used by the current MachineOSBuild are
+// periodically resolved and a rebuild is requested whenever either changes.
+// This only applies to base images referenced by tag, e.g. through an
+// overridden osImageURL. The images of the release payload, used by default,
+// are pinned by digest and only change with a new rendered MachineConfig,
+// which rebuilds the image anyway; they are reported with a warning event on
+// the MachineOSConfig. The RPM repositories used by the Containerfile,
+// including the extensions repository, are not watched: the rebuild interval
+// picks up their updates.
+const (
+	RebuildOnBaseImageChangeAnnotationKey string = "machineconfiguration.openshift.io/rebuild-on-base-image-change"
+)
+
+// Annotations added to MachineOSBuilds to record the digests of the base OS
+// image and extensions image which were last observed for them. These are
+// only set when the MachineOSConfig is watching for base image changes.
+const (
+	BaseOSImageDigestAnnotationKey     string = "machineconfiguration.openshift.io/base-os-image-digest"
+	ExtensionsImageDigestAnnotationKey string = "machineconfiguration.openshift.io/extensions-image-digest"
+)
+
+// Labels, annotations, and keys used for the ConfigMaps which hold the
+// archived build logs for a given MachineOSBuild. These ConfigMaps are
+// intentionally not labeled as ephemeral build objects so that they outlive
+// the build Job and are retained until they expire. The MachineOSBuild
+// references its ConfigMap by name using the BuildLogsConfigMapAnnotationKey.
+const (
+	BuildLogsLabelKey                string = "machineconfiguration.openshift.io/build-logs"
+	BuildLogsExpirationAnnotationKey string = "machineconfiguration.openshift.io/build-logs-expiration"
+	BuildLogsConfigMapAnnotationKey  string = "machineconfiguration.openshift.io/build-logs-configmap"
+	BuildLogsConfigMapKey            string = "build.log.gz"
+)
+
+// When this annotation is added to a MachineOSConfig, its value (expressed as
+// a Go duration, e.g., "72h") overrides how long the archived build logs for
+// its MachineOSBuilds are retained.
+const (
+	BuildLogRetentionAnnotationKey string = "machineconfiguration.openshift.io/build-log-retention"
+)
+
+// Annotations which configure the image retention policy for a
+// MachineOSConfig. When the retention count annotation is present, all but the
+// given number of most recent successful MachineOSBuilds (and their images)
+// are pruned, except for those whose images are applied to or desired by a
+// node. When the dry-run annotation is set to "true", the MachineOSBuilds
+// which would be pruned are only reported.
+const (
+	ImageRetentionCountAnnotationKey  string = "machineconfiguration.openshift.io/image-retention-count"
+	ImageRetentionDryRunAnnotationKey string = "machineconfiguration.openshift.io/image-retention-dry-run"
+)
+
+// When this annotation is added to a MachineOSConfig, its value (a
+// comma-separated list of GOARCH values, e.g., "amd64,arm64") causes a
+// separate image to be built for each architecture on an architecture-matched
+// node. The per-architecture images are then published as a manifest list. A
+// single architecture builds the image on a node of that architecture. The
+// parsed value is also recorded on each MachineOSBuild.
+const (
+	BuildArchitecturesAnnotationKey string = "machineconfiguration.openshift.io/build-architectures"
+)
+
+// Label added to the ephemeral build objects (Jobs, Containerfile ConfigMaps,
+// and digest ConfigMaps) that are specific to a single architecture within a
+// multi-architecture build.
+const (
+	BuildArchitectureLabelKey string = "machineconfiguration.openshift.io/build-architecture"
+)
+
+// Annotation added to a successful multi-architecture MachineOSBuild. Its
+// value is a comma-separated list of architecture and image digest pairs,
+// e.g., "amd64=sha256:...,arm64=sha256:...", for the per-architecture images
+// within the manifest list. It allows the image for a node's architecture to
+// be determined without inspecting the manifest list.
+const (
+	ArchImageDigestsAnnotationKey string = "machineconfiguration.openshift.io/architecture-image-digests"
+)
+
 // New annotations for pre-built image support
 const (
 	// PreBuiltImageAnnotationKey indicates a MachineOSConfig should be seeded with a pre-built image
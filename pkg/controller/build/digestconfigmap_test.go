@@ -6,6 +6,9 @@ import (
 	"path/filepath"
 	"testing"
 
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	fakemcfgclientset "github.com/openshift/client-go/machineconfiguration/clientset/versioned/fake"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
 	"github.com/openshift/machine-config-operator/pkg/controller/build/imagebuilder"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	"github.com/stretchr/testify/assert"
@@ -367,3 +370,53 @@ func TestApplyDigestConfigMapFromFile(t *testing.T) {
 		assert.NotContains(t, cm.Labels, "old-label")
 	})
 }
+
+func TestRecordArchImageDigests(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	mosb := &mcfgv1.MachineOSBuild{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-build"},
+	}
+
+	newArchConfigMap := func(name, arch string, data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: ctrlcommon.MCONamespace,
+				Labels: map[string]string{
+					constants.MachineOSBuildNameLabelKey: mosb.Name,
+					constants.BuildArchitectureLabelKey:  arch,
+				},
+			},
+			Data: data,
+		}
+	}
+
+	kubeclient := fake.NewSimpleClientset(
+		newArchConfigMap("digest-arm64", "arm64", map[string]string{imagebuilder.DigestConfigMapKey: "sha256:2222\n"}),
+		newArchConfigMap("digest-amd64", "amd64", map[string]string{imagebuilder.DigestConfigMapKey: "sha256:1111"}),
+		newArchConfigMap("containerfile-amd64", "amd64", map[string]string{"Containerfile": "FROM scratch"}),
+	)
+	mcfgclient := fakemcfgclientset.NewSimpleClientset(mosb)
+
+	b := &buildReconciler{kubeclient: kubeclient, mcfgclient: mcfgclient}
+	require.NoError(t, b.recordArchImageDigests(ctx, mosb))
+
+	current, err := mcfgclient.MachineconfigurationV1().MachineOSBuilds().Get(ctx, mosb.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "amd64=sha256:1111,arm64=sha256:2222", current.Annotations[constants.ArchImageDigestsAnnotationKey])
+
+	// Single-architecture builds have no architecture-specific configmaps
+	single := &mcfgv1.MachineOSBuild{
+		ObjectMeta: metav1.ObjectMeta{Name: "single-build"},
+	}
+	mcfgclient = fakemcfgclientset.NewSimpleClientset(single)
+	b = &buildReconciler{kubeclient: kubeclient, mcfgclient: mcfgclient}
+	require.NoError(t, b.recordArchImageDigests(ctx, single))
+
+	current, err = mcfgclient.MachineconfigurationV1().MachineOSBuilds().Get(ctx, single.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, current.Annotations, constants.ArchImageDigestsAnnotationKey)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/openshift/machine-config-operator/pkg/apihelpers"
 	"github.com/openshift/machine-config-operator/pkg/controller/build/buildrequest"
 	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/utils"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -143,6 +144,11 @@ func (j *jobImageBuilder) start(ctx context.Context) (*batchv1.Job, error) {
 				return nil, err
 			}
 		}
+
+		if err := j.startArchBuildJobs(ctx, mosbName, oref); err != nil {
+			return nil, err
+		}
+
 		return bj, nil
 	}
 
@@ -153,6 +159,30 @@ func (j *jobImageBuilder) start(ctx context.Context) (*batchv1.Job, error) {
 	return nil, fmt.Errorf("could not create build job: %w", err)
 }
 
+// Creates the build jobs for each architecture within a multi-architecture
+// build. In addition to the MachineOSBuild, these jobs are owned by the main
+// build job so that they are removed whenever it is deleted.
+func (j *jobImageBuilder) startArchBuildJobs(ctx context.Context, mosbName string, oref *metav1.OwnerReference) error {
+	builders, err := j.buildrequest.ArchBuilders()
+	if err != nil {
+		return err
+	}
+
+	for _, builder := range builders {
+		archJob := builder.GetObject().(*batchv1.Job)
+		archJob.OwnerReferences = append(archJob.OwnerReferences, *oref)
+
+		_, err := j.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).Create(ctx, archJob, metav1.CreateOptions{})
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			return fmt.Errorf("could not create build job %q: %w", archJob.Name, err)
+		}
+
+		klog.Infof("Build job %q created for MachineOSBuild %q", archJob.Name, mosbName)
+	}
+
+	return nil
+}
+
 // Gets the build job, returning any errors in the process.
 func (j *jobImageBuilder) getBuildJobStrict(ctx context.Context) (*batchv1.Job, error) {
 	if j.getBuilderName() == "" {
@@ -236,9 +266,53 @@ func (j *jobImageBuilder) getStatus(ctx context.Context) (*batchv1.Job, mcfgv1.B
 
 	klog.Infof("Build job %q status %+v mapped to MachineOSBuild progress %q", job.Name, job.Status, status)
 
+	// The main build job for a multi-architecture build waits for the
+	// architecture-specific images, so it will never complete if one of them
+	// fails to build.
+	if status == mcfgv1.MachineOSBuilding || status == mcfgv1.MachineOSBuildPrepared {
+		failedJob, err := j.getFailedArchBuildJob(ctx, job)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		if failedJob != "" {
+			klog.Infof("Build job %q failed, marking MachineOSBuild as failed", failedJob)
+			status, conditions = mcfgv1.MachineOSBuildFailed, apihelpers.MachineOSBuildFailedConditions()
+		}
+	}
+
 	return job, status, conditions, nil
 }
 
+// Gets the name of the first architecture-specific build job for the given
+// build job which has failed, if any.
+func (j *jobImageBuilder) getFailedArchBuildJob(ctx context.Context, job *batchv1.Job) (string, error) {
+	mosbName, err := utils.GetRequiredLabelValueFromObject(job, constants.MachineOSBuildNameLabelKey)
+	if err != nil {
+		return "", err
+	}
+
+	sel, err := utils.ArchBuildObjectSelectorForMachineOSBuild(mosbName)
+	if err != nil {
+		return "", err
+	}
+
+	archJobs, err := j.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: sel.String(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not list architecture-specific build jobs for MachineOSBuild %q: %w", mosbName, err)
+	}
+
+	for _, archJob := range archJobs.Items {
+		if archStatus, _ := MapJobStatusToBuildStatus(&archJob); archStatus == mcfgv1.MachineOSBuildFailed {
+			return archJob.Name, nil
+		}
+	}
+
+	return "", nil
+}
+
 func (j *jobImageBuilder) machineOSBuildStatus(ctx context.Context) (mcfgv1.MachineOSBuildStatus, error) {
 	job, status, conditions, err := j.getStatus(ctx)
 	if err != nil {
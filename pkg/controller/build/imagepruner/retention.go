@@ -0,0 +1,130 @@
+package imagepruner
+
+import (
+	"sort"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// RetentionPolicy describes which MachineOSBuilds (and their images) should be
+// kept for a given MachineOSConfig.
+type RetentionPolicy struct {
+	// The number of most recent successful MachineOSBuilds to keep.
+	KeepSuccessful int
+	// Image pullspecs which must be kept regardless of age, such as those
+	// currently applied to or desired by a node.
+	InUseImages sets.Set[string]
+	// MachineOSBuild names which must be kept regardless of age, such as the
+	// current MachineOSBuild for a MachineOSConfig.
+	InUseBuilds sets.Set[string]
+}
+
+// SelectMachineOSBuildsToPrune applies the retention policy to the given
+// MachineOSBuilds and returns those which should be retained and those which
+// should be pruned. MachineOSBuilds which have not yet reached a terminal
+// state are always retained.
+func SelectMachineOSBuildsToPrune(mosbs []*mcfgv1.MachineOSBuild, policy RetentionPolicy) (retained, pruned []*mcfgv1.MachineOSBuild) {
+	sorted := append([]*mcfgv1.MachineOSBuild{}, mosbs...)
+
+	// Sort newest first so that the first N successful builds are the ones
+	// which are kept.
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return getCompletionTime(sorted[j]).Before(getCompletionTime(sorted[i]))
+	})
+
+	keptSuccessful := 0
+
+	for _, mosb := range sorted {
+		state := ctrlcommon.NewMachineOSBuildState(mosb)
+
+		if policy.InUseBuilds.Has(mosb.Name) || isImageInUse(mosb, policy.InUseImages) {
+			retained = append(retained, mosb)
+			continue
+		}
+
+		if state.IsBuildSuccess() {
+			if keptSuccessful < policy.KeepSuccessful {
+				keptSuccessful++
+				retained = append(retained, mosb)
+			} else {
+				pruned = append(pruned, mosb)
+			}
+
+			continue
+		}
+
+		if state.IsBuildFailure() || state.IsBuildInterrupted() {
+			pruned = append(pruned, mosb)
+			continue
+		}
+
+		retained = append(retained, mosb)
+	}
+
+	return retained, pruned
+}
+
+// ReclaimableBytes estimates how many bytes will be reclaimed by deleting the
+// pruned images. Since layered images share most of their layers with the base
+// OS image and with one another, only layers which are not referenced by any
+// retained image are counted, and each layer is counted once.
+func ReclaimableBytes(pruned, retained []*types.ImageInspectInfo) int64 {
+	retainedLayers := sets.New[digest.Digest]()
+	for _, info := range retained {
+		if info == nil {
+			continue
+		}
+
+		for _, layer := range info.LayersData {
+			retainedLayers.Insert(layer.Digest)
+		}
+	}
+
+	counted := sets.New[digest.Digest]()
+
+	var total int64
+
+	for _, info := range pruned {
+		if info == nil {
+			continue
+		}
+
+		for _, layer := range info.LayersData {
+			if retainedLayers.Has(layer.Digest) || counted.Has(layer.Digest) {
+				continue
+			}
+
+			counted.Insert(layer.Digest)
+
+			// A size of -1 means the size is unknown.
+			if layer.Size > 0 {
+				total += layer.Size
+			}
+		}
+	}
+
+	return total
+}
+
+func isImageInUse(mosb *mcfgv1.MachineOSBuild, inUse sets.Set[string]) bool {
+	if mosb.Status.DigestedImagePushSpec != "" && inUse.Has(string(mosb.Status.DigestedImagePushSpec)) {
+		return true
+	}
+
+	return mosb.Spec.RenderedImagePushSpec != "" && inUse.Has(string(mosb.Spec.RenderedImagePushSpec))
+}
+
+// Gets the time the MachineOSBuild completed, falling back to its creation
+// timestamp if it has no build end time.
+func getCompletionTime(mosb *mcfgv1.MachineOSBuild) time.Time {
+	if mosb.Status.BuildEnd != nil {
+		return mosb.Status.BuildEnd.Time
+	}
+
+	return mosb.CreationTimestamp.Time
+}
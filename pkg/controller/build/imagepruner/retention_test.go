@@ -0,0 +1,144 @@
+package imagepruner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestSelectMachineOSBuildsToPrune(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, time.January, 8, 0, 0, 0, 0, time.UTC)
+
+	newMOSB := func(name string, age time.Duration, conditions []metav1.Condition) *mcfgv1.MachineOSBuild {
+		end := metav1.NewTime(now.Add(-age))
+
+		mosb := &mcfgv1.MachineOSBuild{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(now.Add(-age - time.Hour)),
+			},
+			Spec: mcfgv1.MachineOSBuildSpec{
+				RenderedImagePushSpec: mcfgv1.ImageTagFormat("registry.host/org/repo:" + name),
+			},
+			Status: mcfgv1.MachineOSBuildStatus{
+				BuildEnd:              &end,
+				DigestedImagePushSpec: mcfgv1.ImageDigestFormat("registry.host/org/repo@sha256:" + name),
+			},
+		}
+
+		ctrlcommon.NewMachineOSBuildState(mosb).SetBuildConditions(conditions)
+
+		return mosb
+	}
+
+	succeeded := apihelpers.MachineOSBuildSucceededConditions()
+	failed := apihelpers.MachineOSBuildFailedConditions()
+	running := apihelpers.MachineOSBuildRunningConditions()
+
+	mosbs := []*mcfgv1.MachineOSBuild{
+		newMOSB("oldest", time.Hour*96, succeeded),
+		newMOSB("old", time.Hour*72, succeeded),
+		newMOSB("failed", time.Hour*60, failed),
+		newMOSB("older", time.Hour*48, succeeded),
+		newMOSB("newest", time.Hour*24, succeeded),
+		newMOSB("running", time.Hour, running),
+	}
+
+	testCases := []struct {
+		name            string
+		policy          RetentionPolicy
+		expectedRetains []string
+		expectedPrunes  []string
+	}{
+		{
+			name:            "keeps last successful build",
+			policy:          RetentionPolicy{KeepSuccessful: 1},
+			expectedRetains: []string{"newest", "running"},
+			expectedPrunes:  []string{"older", "old", "oldest", "failed"},
+		},
+		{
+			name:            "keeps last two successful builds",
+			policy:          RetentionPolicy{KeepSuccessful: 2},
+			expectedRetains: []string{"newest", "older", "running"},
+			expectedPrunes:  []string{"old", "oldest", "failed"},
+		},
+		{
+			name: "keeps images in use by nodes",
+			policy: RetentionPolicy{
+				KeepSuccessful: 1,
+				InUseImages:    sets.New[string]("registry.host/org/repo@sha256:oldest"),
+			},
+			expectedRetains: []string{"newest", "oldest", "running"},
+			expectedPrunes:  []string{"older", "old", "failed"},
+		},
+		{
+			name: "keeps in-use builds",
+			policy: RetentionPolicy{
+				KeepSuccessful: 1,
+				InUseBuilds:    sets.New[string]("old"),
+			},
+			expectedRetains: []string{"newest", "old", "running"},
+			expectedPrunes:  []string{"older", "oldest", "failed"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			retained, pruned := SelectMachineOSBuildsToPrune(mosbs, testCase.policy)
+
+			assert.ElementsMatch(t, testCase.expectedRetains, getMachineOSBuildNames(retained))
+			assert.ElementsMatch(t, testCase.expectedPrunes, getMachineOSBuildNames(pruned))
+		})
+	}
+}
+
+func TestReclaimableBytes(t *testing.T) {
+	t.Parallel()
+
+	newInfo := func(layers map[string]int64) *types.ImageInspectInfo {
+		info := &types.ImageInspectInfo{}
+		for d, size := range layers {
+			info.LayersData = append(info.LayersData, types.ImageInspectLayer{
+				Digest: digest.Digest(d),
+				Size:   size,
+			})
+		}
+
+		return info
+	}
+
+	pruned := []*types.ImageInspectInfo{
+		newInfo(map[string]int64{"sha256:base": 1000, "sha256:layer1": 10}),
+		newInfo(map[string]int64{"sha256:base": 1000, "sha256:layer1": 10, "sha256:layer2": 20, "sha256:unknown": -1}),
+		nil,
+	}
+
+	retained := []*types.ImageInspectInfo{
+		newInfo(map[string]int64{"sha256:base": 1000, "sha256:layer3": 30}),
+	}
+
+	assert.Equal(t, int64(30), ReclaimableBytes(pruned, retained))
+	assert.Equal(t, int64(1030), ReclaimableBytes(pruned, nil))
+}
+
+func getMachineOSBuildNames(mosbs []*mcfgv1.MachineOSBuild) []string {
+	names := []string{}
+	for _, mosb := range mosbs {
+		names = append(names, mosb.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,195 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/containers/image/v5/types"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/imagepruner"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/utils"
+	daemonconstants "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+// Applies the image retention policy for the given MachineOSConfig, pruning
+// any MachineOSBuilds and images which fall outside of it.
+func (b *buildReconciler) PruneImages(ctx context.Context, mosc *mcfgv1.MachineOSConfig) error {
+	return b.timeObjectOperation(mosc, syncingVerb, func() error {
+		return b.pruneImages(ctx, mosc)
+	})
+}
+
+func (b *buildReconciler) pruneImages(ctx context.Context, mosc *mcfgv1.MachineOSConfig) error {
+	if !hasImageRetentionPolicy(mosc) {
+		return nil
+	}
+
+	keep, err := getImageRetentionCount(mosc)
+	if err != nil {
+		// An invalid annotation value will not fix itself on retry.
+		klog.Warningf("Ignoring image retention policy for MachineOSConfig %q: %s", mosc.Name, err)
+		return nil
+	}
+
+	mosbs, err := b.getMachineOSBuildsForMachineOSConfig(mosc)
+	if err != nil {
+		return err
+	}
+
+	inUseImages, err := b.getImagesInUseByNodes()
+	if err != nil {
+		return err
+	}
+
+	inUseBuilds := sets.New[string]()
+	if hasCurrentBuildAnnotation(mosc) {
+		inUseBuilds.Insert(mosc.Annotations[constants.CurrentMachineOSBuildAnnotationKey])
+	}
+
+	retained, pruned := imagepruner.SelectMachineOSBuildsToPrune(mosbs, imagepruner.RetentionPolicy{
+		KeepSuccessful: keep,
+		InUseImages:    inUseImages,
+		InUseBuilds:    inUseBuilds,
+	})
+
+	if len(pruned) == 0 {
+		klog.V(4).Infof("No MachineOSBuilds to prune for MachineOSConfig %q", mosc.Name)
+		return nil
+	}
+
+	reclaimable := b.estimateReclaimableBytes(ctx, pruned, retained)
+
+	names := []string{}
+	for _, mosb := range pruned {
+		names = append(names, mosb.Name)
+	}
+
+	poolName := mosc.Spec.MachineConfigPool.Name
+
+	if isImageRetentionDryRun(mosc) {
+		for _, mosb := range pruned {
+			images, err := getImagesForMachineOSBuild(mosb)
+			if err != nil {
+				return err
+			}
+
+			klog.Infof("Dry-run: would prune MachineOSBuild %q and image(s) %v for MachineOSConfig %q", mosb.Name, images, mosc.Name)
+		}
+
+		klog.Infof("Dry-run: would prune %d MachineOSBuild(s) for MachineOSConfig %q, reclaiming an estimated %d bytes", len(pruned), mosc.Name, reclaimable)
+
+		b.eventRecorder.RecordImagePruneDryRun(mosc, names, reclaimable)
+		RecordImagePruneDryRun(poolName, reclaimable)
+		return nil
+	}
+
+	for _, mosb := range pruned {
+		klog.Infof("Pruning MachineOSBuild %q and its images for MachineOSConfig %q", mosb.Name, mosc.Name)
+
+		if err := b.deleteMachineOSBuild(ctx, mosb); err != nil {
+			return fmt.Errorf("could not prune MachineOSBuild %q for MachineOSConfig %q: %w", mosb.Name, mosc.Name, err)
+		}
+	}
+
+	b.eventRecorder.RecordImagesPruned(mosc, names, reclaimable)
+	RecordImagesPruned(poolName, len(pruned), reclaimable)
+
+	return nil
+}
+
+// Gets the set of images which are either currently applied to or desired by
+// any node in the cluster. Every node is considered instead of only those in
+// the MachineConfigPool since a node may have recently moved between pools.
+func (b *buildReconciler) getImagesInUseByNodes() (sets.Set[string], error) {
+	nodes, err := b.listers.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("could not list nodes: %w", err)
+	}
+
+	inUse := sets.New[string]()
+
+	for _, node := range nodes {
+		for _, key := range []string{daemonconstants.CurrentImageAnnotationKey, daemonconstants.DesiredImageAnnotationKey} {
+			if image := node.Annotations[key]; image != "" {
+				inUse.Insert(image)
+			}
+		}
+	}
+
+	return inUse, nil
+}
+
+// Estimates the number of bytes which will be reclaimed by pruning the given
+// MachineOSBuilds. Images which cannot be inspected are not counted since this
+// is only used for reporting.
+func (b *buildReconciler) estimateReclaimableBytes(ctx context.Context, pruned, retained []*mcfgv1.MachineOSBuild) int64 {
+	return imagepruner.ReclaimableBytes(b.inspectBuiltImages(ctx, pruned), b.inspectBuiltImages(ctx, retained))
+}
+
+func (b *buildReconciler) inspectBuiltImages(ctx context.Context, mosbs []*mcfgv1.MachineOSBuild) []*types.ImageInspectInfo {
+	infos := []*types.ImageInspectInfo{}
+
+	for _, mosb := range mosbs {
+		if mosb.Status.DigestedImagePushSpec == "" {
+			continue
+		}
+
+		// Inspecting a manifest list only yields the layers for a single
+		// architecture, so inspect each per-architecture image instead.
+		images, err := utils.GetArchImagePushSpecs(mosb)
+		if err != nil {
+			klog.Warningf("Could not get images for MachineOSBuild %q: %s", mosb.Name, err)
+			continue
+		}
+
+		if len(images) == 0 {
+			images = []string{string(mosb.Status.DigestedImagePushSpec)}
+		}
+
+		for _, image := range images {
+			info, err := b.inspectImage(ctx, image, mosb)
+			if err != nil {
+				klog.Warningf("Could not inspect image %q for MachineOSBuild %q: %s", image, mosb.Name, err)
+				continue
+			}
+
+			infos = append(infos, info)
+		}
+	}
+
+	return infos
+}
+
+// Determines if a MachineOSConfig has opted into the image retention policy.
+func hasImageRetentionPolicy(mosc *mcfgv1.MachineOSConfig) bool {
+	_, ok := mosc.Annotations[constants.ImageRetentionCountAnnotationKey]
+	return ok
+}
+
+// Determines if the image retention policy for a MachineOSConfig should only
+// report what would be pruned.
+func isImageRetentionDryRun(mosc *mcfgv1.MachineOSConfig) bool {
+	return mosc.Annotations[constants.ImageRetentionDryRunAnnotationKey] == constants.TrueValue
+}
+
+// Parses the image retention count annotation on a MachineOSConfig. At least
+// one successful MachineOSBuild is always retained.
+func getImageRetentionCount(mosc *mcfgv1.MachineOSConfig) (int, error) {
+	val := mosc.Annotations[constants.ImageRetentionCountAnnotationKey]
+
+	keep, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation value %q: %w", constants.ImageRetentionCountAnnotationKey, val, err)
+	}
+
+	if keep < 1 {
+		return 0, fmt.Errorf("invalid %s annotation value %q: must be at least 1", constants.ImageRetentionCountAnnotationKey, val)
+	}
+
+	return keep, nil
+}
@@ -0,0 +1,100 @@
+package build
+
+import (
+	"testing"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetImageRetentionCount(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		value       string
+		expected    int
+		errExpected bool
+	}{
+		{
+			name:     "valid count",
+			value:    "3",
+			expected: 3,
+		},
+		{
+			name:        "zero count",
+			value:       "0",
+			errExpected: true,
+		},
+		{
+			name:        "negative count",
+			value:       "-1",
+			errExpected: true,
+		},
+		{
+			name:        "unparseable count",
+			value:       "all",
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			mosc := &mcfgv1.MachineOSConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "worker",
+					Annotations: map[string]string{
+						constants.ImageRetentionCountAnnotationKey: testCase.value,
+					},
+				},
+			}
+
+			assert.True(t, hasImageRetentionPolicy(mosc))
+
+			keep, err := getImageRetentionCount(mosc)
+			if testCase.errExpected {
+				assert.Error(t, err)
+				assert.Zero(t, keep)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expected, keep)
+			}
+		})
+	}
+}
+
+// Tests that the per-architecture images of a multi-architecture build are
+// pruned along with its manifest list.
+func TestGetImagesForMachineOSBuild(t *testing.T) {
+	t.Parallel()
+
+	mosb := &mcfgv1.MachineOSBuild{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "worker-1234",
+		},
+		Spec: mcfgv1.MachineOSBuildSpec{
+			RenderedImagePushSpec: "registry.hostname.com/org/repo:worker-1234",
+		},
+	}
+
+	images, err := getImagesForMachineOSBuild(mosb)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"registry.hostname.com/org/repo:worker-1234"}, images)
+
+	mosb.Annotations = map[string]string{
+		constants.BuildArchitecturesAnnotationKey: "amd64,arm64",
+	}
+
+	images, err = getImagesForMachineOSBuild(mosb)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"registry.hostname.com/org/repo:worker-1234",
+		"registry.hostname.com/org/repo:worker-1234-amd64",
+		"registry.hostname.com/org/repo:worker-1234-arm64",
+	}, images)
+}
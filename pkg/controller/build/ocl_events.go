@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/record"
@@ -31,6 +32,11 @@ const (
 	EventConfigReconciled      = "ConfigReconciled"
 	EventConfigReconcileFailed = "ConfigReconcileFailed"
 	EventRebuildRequested      = "RebuildRequested"
+	EventBaseImagesPinned      = "BaseImagesPinned"
+
+	// Image retention events
+	EventImagesPruned     = "ImagesPruned"
+	EventImagePruneDryRun = "ImagePruneDryRun"
 
 	// Config deletion events
 	EventConfigDeleted = "ConfigDeleted"
@@ -152,6 +158,25 @@ func (r *OCLEventRecorder) RecordRebuildRequested(mosc *mcfgv1.MachineOSConfig,
 		fmt.Sprintf("Rebuild requested: %s", reason))
 }
 
+// RecordBaseImagesPinned records when base image change detection has no
+// effect, as the base images are pinned by digest
+func (r *OCLEventRecorder) RecordBaseImagesPinned(mosc *mcfgv1.MachineOSConfig, pullspecs []string) {
+	r.recorder.Event(mosc, corev1.EventTypeWarning, EventBaseImagesPinned,
+		fmt.Sprintf("Base images %v are pinned by digest and only change with a new rendered MachineConfig, rebuilding on base image changes has no effect. Set the %s annotation to pick up updates of the RPM repositories", pullspecs, constants.RebuildIntervalAnnotationKey))
+}
+
+// RecordImagesPruned records when MachineOSBuilds and their images are pruned by the retention policy
+func (r *OCLEventRecorder) RecordImagesPruned(mosc *mcfgv1.MachineOSConfig, mosbNames []string, reclaimedBytes int64) {
+	r.recorder.Event(mosc, corev1.EventTypeNormal, EventImagesPruned,
+		fmt.Sprintf("Pruned %d MachineOSBuild(s) %v, reclaiming an estimated %d bytes", len(mosbNames), mosbNames, reclaimedBytes))
+}
+
+// RecordImagePruneDryRun records which MachineOSBuilds and images would be pruned by the retention policy
+func (r *OCLEventRecorder) RecordImagePruneDryRun(mosc *mcfgv1.MachineOSConfig, mosbNames []string, reclaimableBytes int64) {
+	r.recorder.Event(mosc, corev1.EventTypeNormal, EventImagePruneDryRun,
+		fmt.Sprintf("Dry-run: would prune %d MachineOSBuild(s) %v, reclaiming an estimated %d bytes", len(mosbNames), mosbNames, reclaimableBytes))
+}
+
 // RecordConfigDeleted records when a MachineOSConfig is deleted
 func (r *OCLEventRecorder) RecordConfigDeleted(mosc *mcfgv1.MachineOSConfig) {
 	r.recorder.Event(mosc, corev1.EventTypeNormal, EventConfigDeleted,
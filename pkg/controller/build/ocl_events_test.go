@@ -148,6 +148,9 @@ func TestConfigEvents(t *testing.T) {
 			r.RecordConfigReconcileFailed(mosc, fmt.Errorf("could not create MachineOSBuild"))
 		}, "Warning", EventConfigReconcileFailed},
 		{"RebuildRequested", func(r *OCLEventRecorder) { r.RecordRebuildRequested(mosc, "annotation applied") }, "Normal", EventRebuildRequested},
+		{"BaseImagesPinned", func(r *OCLEventRecorder) {
+			r.RecordBaseImagesPinned(mosc, []string{"quay.io/openshift/os@sha256:abc"})
+		}, "Warning", EventBaseImagesPinned},
 		{"ConfigDeleted", func(r *OCLEventRecorder) { r.RecordConfigDeleted(mosc) }, "Normal", EventConfigDeleted},
 	}
 
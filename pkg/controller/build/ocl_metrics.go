@@ -130,6 +130,34 @@ var (
 			Help: "Number of OCL builds currently in progress per pool",
 		}, []string{"pool"})
 
+	// oclScheduledRebuildTotal counts rebuilds requested by the scheduled rebuild checks
+	oclScheduledRebuildTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ocl_scheduled_rebuild_total",
+			Help: "Total number of OCL rebuilds requested by a rebuild interval or base image change",
+		}, []string{"pool", "trigger"})
+
+	// oclImagePrunerReclaimedBytesTotal counts the bytes reclaimed by the image retention policy
+	oclImagePrunerReclaimedBytesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ocl_image_pruner_reclaimed_bytes_total",
+			Help: "Estimated total number of bytes reclaimed from the registry by the OCL image retention policy",
+		}, []string{"pool"})
+
+	// oclImagePrunerPrunedTotal counts the MachineOSBuilds pruned by the image retention policy
+	oclImagePrunerPrunedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ocl_image_pruner_pruned_total",
+			Help: "Total number of MachineOSBuilds and their images pruned by the OCL image retention policy",
+		}, []string{"pool"})
+
+	// oclImagePrunerReclaimableBytes tracks the bytes which would be reclaimed by a dry-run of the image retention policy
+	oclImagePrunerReclaimableBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ocl_image_pruner_reclaimable_bytes",
+			Help: "Estimated number of bytes which would be reclaimed from the registry by the last OCL image retention dry-run",
+		}, []string{"pool"})
+
 	// oclMOSCCount is the number of MachineOSConfig objects in the cluster, used to determine OCL adoption
 	oclMOSCCount = prometheus.NewGauge(
 		prometheus.GaugeOpts{
@@ -168,6 +196,10 @@ func RegisterOCLMetrics() error {
 		oclBuildQueueDuration,
 		oclImagePushDuration,
 		oclActiveBuilds,
+		oclScheduledRebuildTotal,
+		oclImagePrunerReclaimedBytesTotal,
+		oclImagePrunerPrunedTotal,
+		oclImagePrunerReclaimableBytes,
 		oclMOSCCount,
 	})
 
@@ -301,6 +333,25 @@ func RecordBuildQueueDuration(pool string, queuedAt time.Time) {
 	oclBuildQueueDuration.WithLabelValues(pool).Observe(time.Since(queuedAt).Seconds())
 }
 
+// RecordScheduledRebuild records when a rebuild is requested by a rebuild
+// interval or a base image change rather than by the user.
+func RecordScheduledRebuild(pool, trigger string) {
+	oclScheduledRebuildTotal.WithLabelValues(pool, trigger).Inc()
+}
+
+// RecordImagesPruned records the number of MachineOSBuilds pruned by the image
+// retention policy and the estimated number of bytes reclaimed
+func RecordImagesPruned(pool string, count int, reclaimedBytes int64) {
+	oclImagePrunerPrunedTotal.WithLabelValues(pool).Add(float64(count))
+	oclImagePrunerReclaimedBytesTotal.WithLabelValues(pool).Add(float64(reclaimedBytes))
+}
+
+// RecordImagePruneDryRun records the estimated number of bytes which would be
+// reclaimed by the image retention policy
+func RecordImagePruneDryRun(pool string, reclaimableBytes int64) {
+	oclImagePrunerReclaimableBytes.WithLabelValues(pool).Set(float64(reclaimableBytes))
+}
+
 // UpdateOCLRolloutCounts updates the OCL rollout node counts from MachineConfigPool status.
 func UpdateOCLRolloutCounts(pool string, updatedNodes, totalNodes int32) {
 	oclRolloutUpdatedNodes.WithLabelValues(pool).Set(float64(updatedNodes))
@@ -19,8 +19,10 @@ import (
 	"github.com/openshift/machine-config-operator/pkg/controller/build/utils"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	clientset "k8s.io/client-go/kubernetes"
 	coreclientsetv1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
@@ -69,15 +71,35 @@ type Config struct {
 
 	MaxShutdownDelay     time.Duration
 	ShutdownPollInterval time.Duration
+
+	// RebuildCheckInterval is how often MachineOSConfigs which have opted into
+	// scheduled rebuilds or base image change detection are checked to see
+	// whether a rebuild is needed. A zero value disables these checks.
+	// Default: 10 minutes
+	RebuildCheckInterval time.Duration
+
+	// BuildLogPruneInterval is how often archived build logs are checked for
+	// expiration. A zero value disables pruning.
+	// Default: 1 hour
+	BuildLogPruneInterval time.Duration
+
+	// ImagePruneInterval is how often the image retention policy is applied to
+	// MachineOSConfigs which have opted into it. A zero value disables
+	// periodic pruning; pruning still occurs after each successful build.
+	// Default: 1 hour
+	ImagePruneInterval time.Duration
 }
 
 // Creates a Config with sensible production defaults.
 func defaultConfig() Config {
 	return Config{
-		MaxRetries:           5,
-		UpdateDelay:          time.Second * 5,
-		MaxShutdownDelay:     time.Second * 10,
-		ShutdownPollInterval: time.Millisecond * 100,
+		MaxRetries:            5,
+		UpdateDelay:           time.Second * 5,
+		MaxShutdownDelay:      time.Second * 10,
+		ShutdownPollInterval:  time.Millisecond * 100,
+		RebuildCheckInterval:  time.Minute * 10,
+		BuildLogPruneInterval: time.Hour,
+		ImagePruneInterval:    time.Hour,
 	}
 }
 
@@ -207,9 +229,79 @@ func (ctrl *OSBuildController) Run(parentCtx context.Context, workers int) {
 
 	ctrl.execQueue.Start(ctrlCtx, workers)
 
+	if ctrl.config.RebuildCheckInterval > 0 {
+		go wait.UntilWithContext(ctrlCtx, ctrl.enqueueScheduledRebuildChecks, ctrl.config.RebuildCheckInterval)
+	}
+
+	if ctrl.config.BuildLogPruneInterval > 0 {
+		go wait.UntilWithContext(ctrlCtx, ctrl.enqueueBuildLogPruning, ctrl.config.BuildLogPruneInterval)
+	}
+
+	if ctrl.config.ImagePruneInterval > 0 {
+		go wait.UntilWithContext(ctrlCtx, ctrl.enqueueImagePruning, ctrl.config.ImagePruneInterval)
+	}
+
 	<-parentCtx.Done()
 }
 
+// Enqueues a scheduled rebuild check for each MachineOSConfig that has opted
+// into scheduled rebuilds or base image change detection.
+func (ctrl *OSBuildController) enqueueScheduledRebuildChecks(_ context.Context) {
+	moscList, err := ctrl.machineOSConfigLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("could not list MachineOSConfigs for scheduled rebuild checks: %w", err))
+		return
+	}
+
+	for _, mosc := range moscList {
+		if !hasScheduledRebuildAnnotations(mosc) {
+			continue
+		}
+
+		m := mosc.DeepCopy()
+		ctrl.enqueueFuncForObject(m, func(ctx context.Context) error {
+			return ctrl.buildReconciler.SyncScheduledRebuild(ctx, m)
+		})
+	}
+}
+
+// Enqueues pruning of expired build logs for each MachineOSConfig.
+func (ctrl *OSBuildController) enqueueBuildLogPruning(_ context.Context) {
+	moscList, err := ctrl.machineOSConfigLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("could not list MachineOSConfigs for build log pruning: %w", err))
+		return
+	}
+
+	for _, mosc := range moscList {
+		m := mosc.DeepCopy()
+		ctrl.enqueueFuncForObject(m, func(ctx context.Context) error {
+			return ctrl.buildReconciler.PruneBuildLogs(ctx, m)
+		})
+	}
+}
+
+// Enqueues the image retention policy for each MachineOSConfig that has opted
+// into it.
+func (ctrl *OSBuildController) enqueueImagePruning(_ context.Context) {
+	moscList, err := ctrl.machineOSConfigLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("could not list MachineOSConfigs for image pruning: %w", err))
+		return
+	}
+
+	for _, mosc := range moscList {
+		if !hasImageRetentionPolicy(mosc) {
+			continue
+		}
+
+		m := mosc.DeepCopy()
+		ctrl.enqueueFuncForObject(m, func(ctx context.Context) error {
+			return ctrl.buildReconciler.PruneImages(ctx, m)
+		})
+	}
+}
+
 type kubeObject interface {
 	k8sruntime.Object
 	GetName() string
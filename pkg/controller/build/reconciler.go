@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -57,6 +58,10 @@ type reconciler interface {
 
 	AddMachineConfigPool(context.Context, *mcfgv1.MachineConfigPool) error
 	UpdateMachineConfigPool(context.Context, *mcfgv1.MachineConfigPool, *mcfgv1.MachineConfigPool) error
+
+	SyncScheduledRebuild(context.Context, *mcfgv1.MachineOSConfig) error
+	PruneBuildLogs(context.Context, *mcfgv1.MachineOSConfig) error
+	PruneImages(context.Context, *mcfgv1.MachineOSConfig) error
 }
 
 // Holds the implementation of the buildReconciler. The buildReconciler's job
@@ -140,7 +145,12 @@ func (b *buildReconciler) updateMachineOSConfig(ctx context.Context, old, cur *m
 func (b *buildReconciler) rebuildMachineOSConfig(ctx context.Context, mosc *mcfgv1.MachineOSConfig) error {
 	klog.Infof("MachineOSConfig %q has rebuild annotation (%q)", mosc.Name, constants.RebuildMachineOSConfigAnnotationKey)
 
-	b.eventRecorder.RecordRebuildRequested(mosc, "rebuild annotation applied")
+	// Scheduled rebuilds set the annotation to their reason
+	reason := "rebuild annotation applied"
+	if value := mosc.Annotations[constants.RebuildMachineOSConfigAnnotationKey]; value != "" {
+		reason = fmt.Sprintf("%s: %s", reason, value)
+	}
+	b.eventRecorder.RecordRebuildRequested(mosc, reason)
 
 	if !hasCurrentBuildAnnotation(mosc) {
 		klog.Infof("MachineOSConfig %q does not have current build annotation (%q) set, skipping rebuild", mosc.Name, constants.CurrentMachineOSBuildAnnotationKey)
@@ -231,7 +241,9 @@ func (b *buildReconciler) AddJob(ctx context.Context, job *batchv1.Job) error {
 		klog.Infof("Adding build job %q", job.Name)
 
 		mosb, err := b.getMachineOSBuildForJob(job)
-		if err == nil && mosb != nil {
+		// Events and metrics are only recorded for the main build job of a
+		// multi-architecture build.
+		if err == nil && mosb != nil && !utils.IsArchBuildObject(job) {
 			b.eventRecorder.RecordJobCreated(mosb, job)
 			mosc, err := utils.GetMachineOSConfigForMachineOSBuild(mosb, b.utilListers())
 			if err == nil {
@@ -253,6 +265,13 @@ func (b *buildReconciler) AddJob(ctx context.Context, job *batchv1.Job) error {
 // Executes whenever a build Job is updated
 func (b *buildReconciler) UpdateJob(ctx context.Context, oldJob, curJob *batchv1.Job) error {
 	return b.timeObjectOperation(curJob, updatingVerb, func() error {
+		// An architecture-specific build job does not change the status of the
+		// main build job. So instead of comparing the old and current statuses,
+		// compare against the MachineOSBuild to detect a failed architecture.
+		if utils.IsArchBuildObject(curJob) {
+			return b.updateMachineOSBuildWithStatus(ctx, curJob)
+		}
+
 		mosb, err := b.getMachineOSBuildForJob(curJob)
 		if err == nil && mosb != nil {
 			if curJob.Status.Succeeded > 0 && (oldJob.Status.Succeeded == 0) {
@@ -296,7 +315,7 @@ func (b *buildReconciler) UpdateJob(ctx context.Context, oldJob, curJob *batchv1
 func (b *buildReconciler) DeleteJob(ctx context.Context, job *batchv1.Job) error {
 	return b.timeObjectOperation(job, deletingVerb, func() error {
 		mosb, err := b.getMachineOSBuildForJob(job)
-		if err == nil && mosb != nil {
+		if err == nil && mosb != nil && !utils.IsArchBuildObject(job) {
 			b.eventRecorder.RecordJobDeleted(mosb, job.Name)
 			if !ctrlcommon.NewMachineOSBuildState(mosb).IsBuildSuccess() {
 				b.eventRecorder.RecordBuildInterrupted(mosb, "build job was deleted")
@@ -377,6 +396,11 @@ func (b *buildReconciler) updateMachineOSBuild(ctx context.Context, old, current
 		b.eventRecorder.RecordBuildFailed(current)
 		b.eventRecorder.RecordBuildDegraded(mosc)
 
+		// Archiving the logs is best-effort and should not block reconciliation.
+		if err := b.archiveBuildLogs(ctx, current, mosc); err != nil {
+			klog.Warningf("Could not archive build logs for failed MachineOSBuild %q: %v", current.Name, err)
+		}
+
 		return nil
 	}
 
@@ -414,6 +438,18 @@ func (b *buildReconciler) updateMachineOSBuild(ctx context.Context, old, current
 			klog.Errorf("Failed to update ImageBuildDegraded condition for pool %s: %v", mcp.Name, err)
 		}
 
+		// The build logs must be archived before the ephemeral objects are
+		// cleaned up since deleting the Job also deletes its pods.
+		if err := b.archiveBuildLogs(ctx, current, mosc); err != nil {
+			klog.Warningf("Could not archive build logs for MachineOSBuild %q: %v", current.Name, err)
+		}
+
+		// The nodes use the recorded digests to know which image they boot
+		// without inspecting the manifest list.
+		if err := b.recordArchImageDigests(ctx, current); err != nil {
+			return err
+		}
+
 		// Clean up ephemeral objects
 		if err := imagebuilder.NewJobImageBuilder(b.kubeclient, b.mcfgclient, current, mosc).Clean(ctx); err != nil {
 			return err
@@ -422,6 +458,11 @@ func (b *buildReconciler) updateMachineOSBuild(ctx context.Context, old, current
 		if err := b.updateMachineOSConfigStatus(ctx, mosc, current); err != nil {
 			return fmt.Errorf("could not update MachineOSConfig %q status for successful MachineOSBuild %q: %w", mosc.Name, current.Name, err)
 		}
+
+		// Pruning is best-effort and should not block reconciliation.
+		if err := b.pruneImages(ctx, mosc); err != nil {
+			klog.Warningf("Could not apply image retention policy for MachineOSConfig %q: %v", mosc.Name, err)
+		}
 	}
 
 	if !oldState.IsBuilding() && curState.IsBuilding() {
@@ -696,6 +737,10 @@ func (b *buildReconciler) createNewMachineOSBuildForRebuild(ctx context.Context,
 		return fmt.Errorf("could not delete digest configmap for MachineOSBuild %s: %w", mosb.Name, err)
 	}
 
+	if err := b.deleteArchDigestConfigMaps(ctx, mosb); err != nil {
+		return err
+	}
+
 	// Now create the new MOSB
 	_, err = b.mcfgclient.MachineconfigurationV1().MachineOSBuilds().Create(ctx, mosb, metav1.CreateOptions{})
 	if err != nil {
@@ -1039,6 +1084,95 @@ func (b *buildReconciler) deleteBuilderForMachineOSBuild(ctx context.Context, mo
 	if err != nil && !k8serrors.IsNotFound(err) {
 		return fmt.Errorf("could not delete digest configmap for MachineOSBuild %s for MachineOSConfig %s: %w", mosb.Name, moscName, err)
 	}
+
+	if err := b.deleteArchDigestConfigMaps(ctx, mosb); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Records the digests of the per-architecture images of a successful
+// multi-architecture build on the MachineOSBuild. They are read from the
+// architecture-specific digest configmaps, which are kept until the
+// MachineOSBuild is deleted.
+func (b *buildReconciler) recordArchImageDigests(ctx context.Context, mosb *mcfgv1.MachineOSBuild) error {
+	sel, err := utils.ArchBuildObjectSelectorForMachineOSBuild(mosb.Name)
+	if err != nil {
+		return err
+	}
+
+	cmList, err := b.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: sel.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("could not list architecture-specific configmaps for MachineOSBuild %s: %w", mosb.Name, err)
+	}
+
+	archDigests := []string{}
+	for _, cm := range cmList.Items {
+		imageDigest, ok := cm.Data[imagebuilder.DigestConfigMapKey]
+		if !ok {
+			// Only the digest configmaps have the digest key
+			continue
+		}
+
+		archDigests = append(archDigests, fmt.Sprintf("%s=%s", cm.Labels[constants.BuildArchitectureLabelKey], strings.TrimSpace(imageDigest)))
+	}
+
+	if len(archDigests) == 0 {
+		return nil
+	}
+
+	sort.Strings(archDigests)
+	value := strings.Join(archDigests, ",")
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := b.mcfgclient.MachineconfigurationV1().MachineOSBuilds().Get(ctx, mosb.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if current.Annotations[constants.ArchImageDigestsAnnotationKey] == value {
+			return nil
+		}
+
+		metav1.SetMetaDataAnnotation(&current.ObjectMeta, constants.ArchImageDigestsAnnotationKey, value)
+
+		_, err = b.mcfgclient.MachineconfigurationV1().MachineOSBuilds().Update(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not record architecture-specific image digests on MachineOSBuild %s: %w", mosb.Name, err)
+	}
+
+	return nil
+}
+
+// Deletes the architecture-specific configmaps of a multi-architecture build.
+// Like the digest configmap for the manifest list, the digest configmaps
+// created by the architecture-specific build jobs have no owner and must be
+// removed explicitly.
+func (b *buildReconciler) deleteArchDigestConfigMaps(ctx context.Context, mosb *mcfgv1.MachineOSBuild) error {
+	sel, err := utils.ArchBuildObjectSelectorForMachineOSBuild(mosb.Name)
+	if err != nil {
+		return err
+	}
+
+	cmList, err := b.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: sel.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("could not list architecture-specific configmaps for MachineOSBuild %s: %w", mosb.Name, err)
+	}
+
+	for _, cm := range cmList.Items {
+		err := b.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Delete(ctx, cm.Name, metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("could not delete architecture-specific configmap %s for MachineOSBuild %s: %w", cm.Name, mosb.Name, err)
+		}
+	}
+
 	return nil
 }
 
@@ -1061,6 +1195,10 @@ func (b *buildReconciler) deleteMachineOSBuild(ctx context.Context, mosb *mcfgv1
 		return fmt.Errorf("could not delete digest configmap for MachineOSBuild %s for MachineOSConfig %s: %w", mosb.Name, moscName, err)
 	}
 
+	if err := b.deleteArchDigestConfigMaps(ctx, mosb); err != nil {
+		return err
+	}
+
 	err = b.mcfgclient.MachineconfigurationV1().MachineOSBuilds().Delete(ctx, mosb.Name, metav1.DeleteOptions{})
 	if err == nil {
 		klog.Infof("Deleted MachineOSBuild %s for MachineOSConfig %s", mosb.Name, moscName)
@@ -1107,24 +1245,43 @@ func (b *buildReconciler) deleteMOSBImage(ctx context.Context, mosb *mcfgv1.Mach
 		}
 	}
 
-	image := string(mosb.Spec.RenderedImagePushSpec)
-	if err := b.deleteImage(ctx, image, mosb); err != nil {
-		wrappedErr := fmt.Errorf("could not delete image for MachineOSBuild %s for MachineOSConfig %s: %w", mosb.Name, moscName, err)
-		// If the image cannot be deleted because it either does not exist or one's
-		// creds do not have the necessary permissions, then we should ignore the
-		// error and continue.
-		if imagepruner.IsTolerableDeleteErr(err) || k8serrors.IsNotFound(err) {
-			klog.Warning(wrappedErr.Error())
+	images, err := getImagesForMachineOSBuild(mosb)
+	if err != nil {
+		return err
+	}
+
+	for _, image := range images {
+		if err := b.deleteImage(ctx, image, mosb); err != nil {
+			wrappedErr := fmt.Errorf("could not delete image %s for MachineOSBuild %s for MachineOSConfig %s: %w", image, mosb.Name, moscName, err)
+			// If the image cannot be deleted because it either does not exist or one's
+			// creds do not have the necessary permissions, then we should ignore the
+			// error and continue.
+			if imagepruner.IsTolerableDeleteErr(err) || k8serrors.IsNotFound(err) {
+				klog.Warning(wrappedErr.Error())
+			} else {
+				return wrappedErr
+			}
 		} else {
-			return wrappedErr
+			klog.Infof("Deleted image %s from registry for MachineOSBuild %s", image, mosb.Name)
 		}
-	} else {
-		klog.Infof("Deleted image %s from registry for MachineOSBuild %s", image, mosb.Name)
 	}
 
 	return nil
 }
 
+// Gets every image tag pushed by a MachineOSBuild. The per-architecture images
+// of a multi-architecture build are tagged separately from the manifest list,
+// so they are included after it. Deleting the manifest list first ensures that
+// nothing references them by the time they are deleted.
+func getImagesForMachineOSBuild(mosb *mcfgv1.MachineOSBuild) ([]string, error) {
+	archImages, err := utils.GetArchImagePushSpecs(mosb)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string{string(mosb.Spec.RenderedImagePushSpec)}, archImages...), nil
+}
+
 // Finds and deletes any other running builds for a given MachineOSConfig.
 func (b *buildReconciler) deleteOtherBuildsForMachineOSConfig(ctx context.Context, newMosb *mcfgv1.MachineOSBuild, mosc *mcfgv1.MachineOSConfig) error {
 	mosbList, err := b.getMachineOSBuildsForMachineOSConfig(mosc)
@@ -1728,16 +1885,26 @@ func (b *buildReconciler) getObjectsForImagePruner(mosb *mcfgv1.MachineOSBuild)
 		return nil, nil, fmt.Errorf("could not get rendered push secret %s: %w", secretName, err)
 	}
 
+	cc, err := b.getControllerConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return secret.DeepCopy(), cc, nil
+}
+
+// getControllerConfig retrieves a deep-copy of the ControllerConfig from the lister.
+func (b *buildReconciler) getControllerConfig() (*mcfgv1.ControllerConfig, error) {
 	controllerConfigs, err := b.listers.controllerConfigLister.List(labels.Everything())
 	if err != nil {
-		return nil, nil, fmt.Errorf("could not list ControllerConfigs: %w", err)
+		return nil, fmt.Errorf("could not list ControllerConfigs: %w", err)
 	}
 
 	if len(controllerConfigs) == 0 {
-		return nil, nil, fmt.Errorf("no ControllerConfigs found")
+		return nil, fmt.Errorf("no ControllerConfigs found")
 	}
 
-	return secret.DeepCopy(), controllerConfigs[0].DeepCopy(), nil
+	return controllerConfigs[0].DeepCopy(), nil
 }
 
 // inspectImage retrieves the necessary objects and calls InspectImage on the imagepruner.
@@ -1820,7 +1987,7 @@ func (b *buildReconciler) reconcileImageRebuild(oldMCP, curMCP *mcfgv1.MachineCo
 		return false, err
 	}
 
-	return ctrlcommon.RequiresRebuild(curr, des), nil
+	return ctrlcommon.RequiresRebuild(curr, des)
 }
 
 // Clears BuildDegraded condition when a new build starts (allowing retry after failure)
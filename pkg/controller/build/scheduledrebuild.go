@@ -0,0 +1,327 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/containers/image/v5/docker/reference"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+// Scheduled rebuild trigger labels used for metrics.
+const (
+	rebuildTriggerInterval        string = "interval"
+	rebuildTriggerBaseImageChange string = "base-image-change"
+)
+
+// Executes periodically for each MachineOSConfig that has opted into
+// scheduled rebuilds or base image change detection. If a rebuild is needed,
+// the rebuild annotation is applied to the MachineOSConfig so that the rebuild
+// follows the same path as a user-requested rebuild.
+func (b *buildReconciler) SyncScheduledRebuild(ctx context.Context, mosc *mcfgv1.MachineOSConfig) error {
+	return b.timeObjectOperation(mosc, syncingVerb, func() error {
+		return b.syncScheduledRebuild(ctx, mosc, time.Now())
+	})
+}
+
+// Determines whether the current MachineOSBuild for the given MachineOSConfig
+// should be rebuilt, either because the rebuild interval has elapsed or
+// because the base OS image or extensions image referenced by tag has changed
+// upstream. Changes to RPM repositories are only picked up by the interval.
+func (b *buildReconciler) syncScheduledRebuild(ctx context.Context, mosc *mcfgv1.MachineOSConfig, now time.Time) error {
+	if !hasScheduledRebuildAnnotations(mosc) {
+		return nil
+	}
+
+	// If a rebuild is already pending, there is nothing left to do.
+	if hasRebuildAnnotation(mosc) {
+		klog.V(4).Infof("MachineOSConfig %q already has rebuild annotation, skipping scheduled rebuild check", mosc.Name)
+		return nil
+	}
+
+	if !hasCurrentBuildAnnotation(mosc) {
+		klog.V(4).Infof("MachineOSConfig %q has no current build, skipping scheduled rebuild check", mosc.Name)
+		return nil
+	}
+
+	mosb, err := b.machineOSBuildLister.Get(mosc.Annotations[constants.CurrentMachineOSBuildAnnotationKey])
+	if err != nil {
+		return ignoreErrIsNotFound(fmt.Errorf("could not get current MachineOSBuild for MachineOSConfig %q: %w", mosc.Name, err))
+	}
+
+	// Only successful builds are considered. In-progress builds will be
+	// evaluated once they complete and failed builds already have a path to be
+	// retried.
+	if !ctrlcommon.NewMachineOSBuildState(mosb).IsBuildSuccess() {
+		return nil
+	}
+
+	interval, err := getRebuildInterval(mosc)
+	if err != nil {
+		// An invalid annotation value will not fix itself on retry, so just warn
+		// and continue on to the base image checks.
+		klog.Warningf("Ignoring rebuild interval for MachineOSConfig %q: %s", mosc.Name, err)
+	}
+
+	if isScheduledRebuildDue(mosb, interval, now) {
+		reason := fmt.Sprintf("rebuild interval of %s elapsed since MachineOSBuild %q finished", interval, mosb.Name)
+		return b.requestScheduledRebuild(ctx, mosc, rebuildTriggerInterval, reason)
+	}
+
+	if !isRebuildOnBaseImageChangeEnabled(mosc) {
+		return nil
+	}
+
+	changed, reason, err := b.checkForBaseImageChange(ctx, mosc, mosb)
+	if err != nil {
+		return fmt.Errorf("could not check for base image changes for MachineOSConfig %q: %w", mosc.Name, err)
+	}
+
+	if changed {
+		return b.requestScheduledRebuild(ctx, mosc, rebuildTriggerBaseImageChange, reason)
+	}
+
+	return nil
+}
+
+// Resolves the current digests of the base OS image and extensions image
+// referenced by the rendered MachineConfig for the given MachineOSBuild and
+// compares them to the digests previously recorded on the MachineOSBuild. If
+// no digests were previously recorded, the current digests are recorded and
+// no change is reported. Only images referenced by tag can change: when all of
+// them are pinned by digest, a warning event tells the user to rely on the
+// rebuild interval instead.
+func (b *buildReconciler) checkForBaseImageChange(ctx context.Context, mosc *mcfgv1.MachineOSConfig, mosb *mcfgv1.MachineOSBuild) (bool, string, error) {
+	mc, err := b.machineConfigLister.Get(mosb.Spec.MachineConfig.Name)
+	if err != nil {
+		return false, "", fmt.Errorf("could not get MachineConfig %q for MachineOSBuild %q: %w", mosb.Spec.MachineConfig.Name, mosb.Name, err)
+	}
+
+	baseOSDigest, err := b.resolveBaseImageDigest(ctx, mosc, mc.Spec.OSImageURL)
+	if err != nil {
+		return false, "", err
+	}
+
+	extensionsDigest, err := b.resolveBaseImageDigest(ctx, mosc, mc.Spec.BaseOSExtensionsContainerImage)
+	if err != nil {
+		return false, "", err
+	}
+
+	recordedBaseOSDigest := mosb.Annotations[constants.BaseOSImageDigestAnnotationKey]
+	recordedExtensionsDigest := mosb.Annotations[constants.ExtensionsImageDigestAnnotationKey]
+
+	// If we have not recorded anything yet, this is the first time we've
+	// checked this MachineOSBuild. Record what we found so that future checks
+	// have something to compare against.
+	if recordedBaseOSDigest == "" && recordedExtensionsDigest == "" {
+		// Images pinned by digest, as in the release payload, never change
+		// under the same rendered MachineConfig.
+		if pinned := getDigestPinnedPullspecs(mc.Spec.OSImageURL, mc.Spec.BaseOSExtensionsContainerImage); pinned != nil {
+			klog.Warningf("Base images of MachineOSConfig %q are pinned by digest, rebuilding on base image changes has no effect, use %s instead", mosc.Name, constants.RebuildIntervalAnnotationKey)
+			b.eventRecorder.RecordBaseImagesPinned(mosc, pinned)
+		}
+		return false, "", b.recordBaseImageDigests(ctx, mosb, baseOSDigest, extensionsDigest)
+	}
+
+	if recordedBaseOSDigest != baseOSDigest {
+		return true, fmt.Sprintf("base OS image %q digest changed from %s to %s", mc.Spec.OSImageURL, recordedBaseOSDigest, baseOSDigest), nil
+	}
+
+	if recordedExtensionsDigest != extensionsDigest {
+		return true, fmt.Sprintf("extensions image %q digest changed from %s to %s", mc.Spec.BaseOSExtensionsContainerImage, recordedExtensionsDigest, extensionsDigest), nil
+	}
+
+	return false, "", nil
+}
+
+// Resolves the digest of the given image pullspec using the base image pull
+// secret for the MachineOSConfig. Returns an empty string if no pullspec was
+// provided.
+func (b *buildReconciler) resolveBaseImageDigest(ctx context.Context, mosc *mcfgv1.MachineOSConfig, pullspec string) (string, error) {
+	if pullspec == "" {
+		return "", nil
+	}
+
+	// There is nothing to resolve for images pinned by digest
+	if imageDigest := getPinnedDigest(pullspec); imageDigest != "" {
+		return imageDigest, nil
+	}
+
+	secretName := ctrlcommon.GlobalPullSecretCopyName
+	if mosc.Spec.BaseImagePullSecret != nil {
+		secretName = mosc.Spec.BaseImagePullSecret.Name
+	}
+
+	secret, err := b.kubeclient.CoreV1().Secrets(ctrlcommon.MCONamespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("could not get base image pull secret %q: %w", secretName, err)
+	}
+
+	cc, err := b.getControllerConfig()
+	if err != nil {
+		return "", err
+	}
+
+	_, digest, err := b.imagepruner.InspectImage(ctx, pullspec, secret, cc)
+	if err != nil {
+		return "", fmt.Errorf("could not resolve digest for image %q: %w", pullspec, err)
+	}
+
+	if digest == nil {
+		return "", fmt.Errorf("no digest returned for image %q", pullspec)
+	}
+
+	return digest.String(), nil
+}
+
+// Records the given base OS image and extensions image digests on the MachineOSBuild.
+func (b *buildReconciler) recordBaseImageDigests(ctx context.Context, mosb *mcfgv1.MachineOSBuild, baseOSDigest, extensionsDigest string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := b.mcfgclient.MachineconfigurationV1().MachineOSBuilds().Get(ctx, mosb.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		metav1.SetMetaDataAnnotation(&current.ObjectMeta, constants.BaseOSImageDigestAnnotationKey, baseOSDigest)
+		metav1.SetMetaDataAnnotation(&current.ObjectMeta, constants.ExtensionsImageDigestAnnotationKey, extensionsDigest)
+
+		_, err = b.mcfgclient.MachineconfigurationV1().MachineOSBuilds().Update(ctx, current, metav1.UpdateOptions{})
+		if err == nil {
+			klog.Infof("Recorded base image digests on MachineOSBuild %q", mosb.Name)
+		}
+
+		return err
+	})
+}
+
+// Applies the rebuild annotation to the MachineOSConfig. The value of the
+// annotation is set to the reason for the rebuild so that it is visible to
+// anyone inspecting the MachineOSConfig while the rebuild is in progress.
+func (b *buildReconciler) requestScheduledRebuild(ctx context.Context, mosc *mcfgv1.MachineOSConfig, trigger, reason string) error {
+	klog.Infof("Requesting rebuild of MachineOSConfig %q: %s", mosc.Name, reason)
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := b.mcfgclient.MachineconfigurationV1().MachineOSConfigs().Get(ctx, mosc.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if hasRebuildAnnotation(current) {
+			return nil
+		}
+
+		metav1.SetMetaDataAnnotation(&current.ObjectMeta, constants.RebuildMachineOSConfigAnnotationKey, reason)
+
+		_, err = b.mcfgclient.MachineconfigurationV1().MachineOSConfigs().Update(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+
+	if err != nil {
+		return ignoreErrIsNotFound(fmt.Errorf("could not apply rebuild annotation to MachineOSConfig %q: %w", mosc.Name, err))
+	}
+
+	// The RebuildRequested event is recorded when the annotation is handled
+	RecordScheduledRebuild(mosc.Spec.MachineConfigPool.Name, trigger)
+
+	return nil
+}
+
+// Returns the digest the given image pullspec is pinned to, or an empty string
+// if it is referenced by tag.
+func getPinnedDigest(pullspec string) string {
+	named, err := reference.ParseNamed(pullspec)
+	if err != nil {
+		return ""
+	}
+
+	digested, ok := named.(reference.Digested)
+	if !ok {
+		return ""
+	}
+
+	return digested.Digest().String()
+}
+
+// Returns the given base image pullspecs if all of them are pinned by digest,
+// and nil otherwise. Empty pullspecs are skipped.
+func getDigestPinnedPullspecs(pullspecs ...string) []string {
+	pinned := []string{}
+	for _, pullspec := range pullspecs {
+		if pullspec == "" {
+			continue
+		}
+
+		if getPinnedDigest(pullspec) == "" {
+			return nil
+		}
+
+		pinned = append(pinned, pullspec)
+	}
+
+	if len(pinned) == 0 {
+		return nil
+	}
+
+	return pinned
+}
+
+// Determines if a MachineOSConfig has opted into either scheduled rebuilds or
+// base image change detection.
+func hasScheduledRebuildAnnotations(mosc *mcfgv1.MachineOSConfig) bool {
+	return metav1.HasAnnotation(mosc.ObjectMeta, constants.RebuildIntervalAnnotationKey) || isRebuildOnBaseImageChangeEnabled(mosc)
+}
+
+// Determines if a MachineOSConfig has opted into base image change detection.
+func isRebuildOnBaseImageChangeEnabled(mosc *mcfgv1.MachineOSConfig) bool {
+	return mosc.Annotations[constants.RebuildOnBaseImageChangeAnnotationKey] == constants.TrueValue
+}
+
+// Parses the rebuild interval annotation on a MachineOSConfig. Returns zero if
+// the annotation is not present.
+func getRebuildInterval(mosc *mcfgv1.MachineOSConfig) (time.Duration, error) {
+	val, ok := mosc.Annotations[constants.RebuildIntervalAnnotationKey]
+	if !ok || val == "" {
+		return 0, nil
+	}
+
+	interval, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation value %q: %w", constants.RebuildIntervalAnnotationKey, val, err)
+	}
+
+	if interval < minRebuildInterval {
+		return 0, fmt.Errorf("invalid %s annotation value %q: must be at least %s", constants.RebuildIntervalAnnotationKey, val, minRebuildInterval)
+	}
+
+	return interval, nil
+}
+
+// The shortest rebuild interval that may be requested. This prevents a
+// misconfigured MachineOSConfig from continuously rebuilding.
+const minRebuildInterval time.Duration = time.Hour
+
+// Determines whether the given interval has elapsed since the MachineOSBuild
+// finished. Falls back to the creation timestamp for MachineOSBuilds which do
+// not have a build end time, such as those which reuse a previous image.
+func isScheduledRebuildDue(mosb *mcfgv1.MachineOSBuild, interval time.Duration, now time.Time) bool {
+	if interval == 0 {
+		return false
+	}
+
+	builtAt := mosb.CreationTimestamp.Time
+	if mosb.Status.BuildEnd != nil {
+		builtAt = mosb.Status.BuildEnd.Time
+	}
+
+	if builtAt.IsZero() {
+		return false
+	}
+
+	return !now.Before(builtAt.Add(interval))
+}
@@ -0,0 +1,545 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	fakemcfgclientset "github.com/openshift/client-go/machineconfiguration/clientset/versioned/fake"
+	mcfglistersv1 "github.com/openshift/client-go/machineconfiguration/listers/machineconfiguration/v1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakecorev1client "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestGetRebuildInterval(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    time.Duration
+		errExpected bool
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name:        "empty annotation",
+			annotations: map[string]string{constants.RebuildIntervalAnnotationKey: ""},
+		},
+		{
+			name:        "valid interval",
+			annotations: map[string]string{constants.RebuildIntervalAnnotationKey: "168h"},
+			expected:    time.Hour * 168,
+		},
+		{
+			name:        "unparseable interval",
+			annotations: map[string]string{constants.RebuildIntervalAnnotationKey: "weekly"},
+			errExpected: true,
+		},
+		{
+			name:        "interval too short",
+			annotations: map[string]string{constants.RebuildIntervalAnnotationKey: "5m"},
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			mosc := &mcfgv1.MachineOSConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "worker",
+					Annotations: testCase.annotations,
+				},
+			}
+
+			interval, err := getRebuildInterval(mosc)
+			if testCase.errExpected {
+				assert.Error(t, err)
+				assert.Zero(t, interval)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expected, interval)
+			}
+		})
+	}
+}
+
+func TestIsScheduledRebuildDue(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, time.January, 8, 0, 0, 0, 0, time.UTC)
+	weekAgo := now.Add(-time.Hour * 168)
+	dayAgo := now.Add(-time.Hour * 24)
+
+	newMOSB := func(created time.Time, buildEnd *time.Time) *mcfgv1.MachineOSBuild {
+		mosb := &mcfgv1.MachineOSBuild{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "worker-build",
+				CreationTimestamp: metav1.NewTime(created),
+			},
+		}
+
+		if buildEnd != nil {
+			end := metav1.NewTime(*buildEnd)
+			mosb.Status.BuildEnd = &end
+		}
+
+		return mosb
+	}
+
+	testCases := []struct {
+		name     string
+		mosb     *mcfgv1.MachineOSBuild
+		interval time.Duration
+		expected bool
+	}{
+		{
+			name:     "no interval",
+			mosb:     newMOSB(weekAgo, &weekAgo),
+			expected: false,
+		},
+		{
+			name:     "interval elapsed since build end",
+			mosb:     newMOSB(weekAgo, &weekAgo),
+			interval: time.Hour * 168,
+			expected: true,
+		},
+		{
+			name:     "interval not elapsed since build end",
+			mosb:     newMOSB(weekAgo, &dayAgo),
+			interval: time.Hour * 48,
+			expected: false,
+		},
+		{
+			name:     "falls back to creation timestamp without build end",
+			mosb:     newMOSB(weekAgo, nil),
+			interval: time.Hour * 48,
+			expected: true,
+		},
+		{
+			name:     "no timestamps",
+			mosb:     newMOSB(time.Time{}, nil),
+			interval: time.Hour,
+			expected: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testCase.expected, isScheduledRebuildDue(testCase.mosb, testCase.interval, now))
+		})
+	}
+}
+
+func TestHasScheduledRebuildAnnotations(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    bool
+	}{
+		{
+			name: "no annotations",
+		},
+		{
+			name:        "rebuild interval",
+			annotations: map[string]string{constants.RebuildIntervalAnnotationKey: "24h"},
+			expected:    true,
+		},
+		{
+			name:        "rebuild on base image change",
+			annotations: map[string]string{constants.RebuildOnBaseImageChangeAnnotationKey: constants.TrueValue},
+			expected:    true,
+		},
+		{
+			name:        "rebuild on base image change disabled",
+			annotations: map[string]string{constants.RebuildOnBaseImageChangeAnnotationKey: "false"},
+			expected:    false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			mosc := &mcfgv1.MachineOSConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "worker",
+					Annotations: testCase.annotations,
+				},
+			}
+
+			assert.Equal(t, testCase.expected, hasScheduledRebuildAnnotations(mosc))
+		})
+	}
+}
+
+func TestGetPinnedDigest(t *testing.T) {
+	t.Parallel()
+
+	pinnedDigest := digest.FromString("base-os").String()
+
+	assert.Equal(t, pinnedDigest, getPinnedDigest("quay.io/openshift/os@"+pinnedDigest))
+	assert.Equal(t, "", getPinnedDigest("quay.io/openshift/os:latest"))
+	assert.Equal(t, "", getPinnedDigest("quay.io/openshift/os"))
+	assert.Equal(t, "", getPinnedDigest("not a pullspec"))
+
+	assert.Equal(t, []string{"quay.io/openshift/os@" + pinnedDigest}, getDigestPinnedPullspecs("quay.io/openshift/os@"+pinnedDigest, ""))
+	assert.Nil(t, getDigestPinnedPullspecs("quay.io/openshift/os@"+pinnedDigest, "quay.io/openshift/extensions:latest"))
+	assert.Nil(t, getDigestPinnedPullspecs("", ""))
+}
+
+// Provides a fake imagepruner implementation which resolves image digests from
+// a map and records which images were inspected.
+type fakeDigestImagePruner struct {
+	digests   map[string]digest.Digest
+	inspected []string
+}
+
+func (f *fakeDigestImagePruner) InspectImage(_ context.Context, pullspec string, _ *corev1.Secret, _ *mcfgv1.ControllerConfig) (*types.ImageInspectInfo, *digest.Digest, error) {
+	f.inspected = append(f.inspected, pullspec)
+
+	imageDigest, ok := f.digests[pullspec]
+	if !ok {
+		return nil, nil, fmt.Errorf("image %q not found", pullspec)
+	}
+
+	return &types.ImageInspectInfo{}, &imageDigest, nil
+}
+
+func (f *fakeDigestImagePruner) DeleteImage(_ context.Context, _ string, _ *corev1.Secret, _ *mcfgv1.ControllerConfig) error {
+	return nil
+}
+
+const (
+	testBaseOSImage     string = "quay.io/openshift/os:latest"
+	testExtensionsImage string = "quay.io/openshift/extensions:latest"
+)
+
+func newScheduledRebuildMachineOSConfig(annotations map[string]string) *mcfgv1.MachineOSConfig {
+	annotations[constants.CurrentMachineOSBuildAnnotationKey] = "worker-build"
+
+	return &mcfgv1.MachineOSConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "worker",
+			Annotations: annotations,
+		},
+		Spec: mcfgv1.MachineOSConfigSpec{
+			MachineConfigPool: mcfgv1.MachineConfigPoolReference{Name: "worker"},
+		},
+	}
+}
+
+func newScheduledRebuildMachineOSBuild(annotations map[string]string, buildEnd time.Time) *mcfgv1.MachineOSBuild {
+	return &mcfgv1.MachineOSBuild{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "worker-build",
+			Annotations: annotations,
+		},
+		Spec: mcfgv1.MachineOSBuildSpec{
+			MachineConfig: mcfgv1.MachineConfigReference{Name: "rendered-worker-1"},
+		},
+		Status: mcfgv1.MachineOSBuildStatus{
+			BuildEnd: &metav1.Time{Time: buildEnd},
+			Conditions: []metav1.Condition{
+				{
+					Type:   string(mcfgv1.MachineOSBuildSucceeded),
+					Status: metav1.ConditionTrue,
+				},
+			},
+		},
+	}
+}
+
+// Sets up a buildReconciler with just enough listers and clients for the
+// scheduled rebuild checks.
+func newScheduledRebuildReconcilerForTest(t *testing.T, mosc *mcfgv1.MachineOSConfig, mosb *mcfgv1.MachineOSBuild, osImageURL, extensionsImage string, pruner *fakeDigestImagePruner) (*buildReconciler, chan string) {
+	t.Helper()
+
+	mc := &mcfgv1.MachineConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "rendered-worker-1"},
+		Spec: mcfgv1.MachineConfigSpec{
+			OSImageURL:                     osImageURL,
+			BaseOSExtensionsContainerImage: extensionsImage,
+		},
+	}
+
+	cc := &mcfgv1.ControllerConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: ctrlcommon.ControllerConfigName},
+	}
+
+	newIndexer := func(objs ...runtime.Object) cache.Indexer {
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		for _, obj := range objs {
+			require.NoError(t, indexer.Add(obj))
+		}
+		return indexer
+	}
+
+	pullSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ctrlcommon.GlobalPullSecretCopyName,
+			Namespace: ctrlcommon.MCONamespace,
+		},
+	}
+
+	recorder, fake := newTestOCLRecorder(10)
+
+	b := &buildReconciler{
+		mcfgclient:    fakemcfgclientset.NewSimpleClientset(mosc, mosb),
+		kubeclient:    fakecorev1client.NewSimpleClientset(pullSecret),
+		imagepruner:   pruner,
+		eventRecorder: recorder,
+		listers: &listers{
+			machineOSBuildLister:   mcfglistersv1.NewMachineOSBuildLister(newIndexer(mosb)),
+			machineConfigLister:    mcfglistersv1.NewMachineConfigLister(newIndexer(mc)),
+			controllerConfigLister: mcfglistersv1.NewControllerConfigLister(newIndexer(cc)),
+		},
+	}
+
+	return b, fake.Events
+}
+
+func TestSyncScheduledRebuild(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	baseOSDigest := digest.FromString("base-os")
+	newBaseOSDigest := digest.FromString("new-base-os")
+	extensionsDigest := digest.FromString("extensions")
+
+	recordedDigests := map[string]string{
+		constants.BaseOSImageDigestAnnotationKey:     baseOSDigest.String(),
+		constants.ExtensionsImageDigestAnnotationKey: extensionsDigest.String(),
+	}
+
+	testCases := []struct {
+		name            string
+		moscAnnotations map[string]string
+		mosbAnnotations map[string]string
+		builtAgo        time.Duration
+		digests         map[string]digest.Digest
+		rebuildExpected bool
+		reasonContains  string
+	}{
+		{
+			name:            "rebuild interval elapsed",
+			moscAnnotations: map[string]string{constants.RebuildIntervalAnnotationKey: "24h"},
+			builtAgo:        time.Hour * 25,
+			rebuildExpected: true,
+			reasonContains:  "rebuild interval of 24h0m0s elapsed",
+		},
+		{
+			name:            "rebuild interval not elapsed",
+			moscAnnotations: map[string]string{constants.RebuildIntervalAnnotationKey: "24h"},
+			builtAgo:        time.Hour,
+		},
+		{
+			name: "rebuild already pending",
+			moscAnnotations: map[string]string{
+				constants.RebuildIntervalAnnotationKey:        "24h",
+				constants.RebuildMachineOSConfigAnnotationKey: "",
+			},
+			builtAgo: time.Hour * 25,
+		},
+		{
+			name:            "base image changed",
+			moscAnnotations: map[string]string{constants.RebuildOnBaseImageChangeAnnotationKey: constants.TrueValue},
+			mosbAnnotations: recordedDigests,
+			builtAgo:        time.Hour,
+			digests: map[string]digest.Digest{
+				testBaseOSImage:     newBaseOSDigest,
+				testExtensionsImage: extensionsDigest,
+			},
+			rebuildExpected: true,
+			reasonContains:  fmt.Sprintf("digest changed from %s to %s", baseOSDigest, newBaseOSDigest),
+		},
+		{
+			name:            "base image unchanged",
+			moscAnnotations: map[string]string{constants.RebuildOnBaseImageChangeAnnotationKey: constants.TrueValue},
+			mosbAnnotations: recordedDigests,
+			builtAgo:        time.Hour,
+			digests: map[string]digest.Digest{
+				testBaseOSImage:     baseOSDigest,
+				testExtensionsImage: extensionsDigest,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			mosc := newScheduledRebuildMachineOSConfig(testCase.moscAnnotations)
+			mosb := newScheduledRebuildMachineOSBuild(testCase.mosbAnnotations, now.Add(-testCase.builtAgo))
+			pruner := &fakeDigestImagePruner{digests: testCase.digests}
+
+			b, events := newScheduledRebuildReconcilerForTest(t, mosc, mosb, testBaseOSImage, testExtensionsImage, pruner)
+
+			require.NoError(t, b.syncScheduledRebuild(ctx, mosc, now))
+
+			current, err := b.mcfgclient.MachineconfigurationV1().MachineOSConfigs().Get(ctx, mosc.Name, metav1.GetOptions{})
+			require.NoError(t, err)
+
+			reason, hasRebuild := current.Annotations[constants.RebuildMachineOSConfigAnnotationKey]
+			if !testCase.rebuildExpected {
+				assert.Equal(t, hasRebuildAnnotation(mosc), hasRebuild)
+				assertNoEvent(t, events)
+				return
+			}
+
+			assert.True(t, hasRebuild)
+			assert.Contains(t, reason, testCase.reasonContains)
+
+			// The RebuildRequested event is recorded once, when the rebuild
+			// annotation is handled, rather than when it is applied.
+			assertNoEvent(t, events)
+		})
+	}
+}
+
+func TestCheckForBaseImageChange(t *testing.T) {
+	t.Parallel()
+
+	baseOSDigest := digest.FromString("base-os")
+	newExtensionsDigest := digest.FromString("new-extensions")
+	extensionsDigest := digest.FromString("extensions")
+
+	pinnedBaseOSImage := "quay.io/openshift/os@" + baseOSDigest.String()
+	pinnedExtensionsImage := "quay.io/openshift/extensions@" + extensionsDigest.String()
+
+	testCases := []struct {
+		name                   string
+		osImageURL             string
+		extensionsImage        string
+		mosbAnnotations        map[string]string
+		digests                map[string]digest.Digest
+		expectedChanged        bool
+		reasonContains         string
+		expectedRecordedBaseOS string
+		expectedRecordedExt    string
+		expectedInspected      []string
+		expectBaseImagesPinned bool
+		errExpected            bool
+	}{
+		{
+			name:            "first check records digests",
+			osImageURL:      testBaseOSImage,
+			extensionsImage: testExtensionsImage,
+			digests: map[string]digest.Digest{
+				testBaseOSImage:     baseOSDigest,
+				testExtensionsImage: extensionsDigest,
+			},
+			expectedRecordedBaseOS: baseOSDigest.String(),
+			expectedRecordedExt:    extensionsDigest.String(),
+			expectedInspected:      []string{testBaseOSImage, testExtensionsImage},
+		},
+		{
+			name:            "extensions image changed",
+			osImageURL:      testBaseOSImage,
+			extensionsImage: testExtensionsImage,
+			mosbAnnotations: map[string]string{
+				constants.BaseOSImageDigestAnnotationKey:     baseOSDigest.String(),
+				constants.ExtensionsImageDigestAnnotationKey: extensionsDigest.String(),
+			},
+			digests: map[string]digest.Digest{
+				testBaseOSImage:     baseOSDigest,
+				testExtensionsImage: newExtensionsDigest,
+			},
+			expectedChanged:        true,
+			reasonContains:         fmt.Sprintf("extensions image %q digest changed", testExtensionsImage),
+			expectedRecordedBaseOS: baseOSDigest.String(),
+			expectedRecordedExt:    extensionsDigest.String(),
+			expectedInspected:      []string{testBaseOSImage, testExtensionsImage},
+		},
+		{
+			name:                   "digest-pinned images are not inspected and are reported",
+			osImageURL:             pinnedBaseOSImage,
+			extensionsImage:        pinnedExtensionsImage,
+			expectedRecordedBaseOS: baseOSDigest.String(),
+			expectedRecordedExt:    extensionsDigest.String(),
+			expectBaseImagesPinned: true,
+		},
+		{
+			name:            "digest-pinned base OS image with tagged extensions image",
+			osImageURL:      pinnedBaseOSImage,
+			extensionsImage: testExtensionsImage,
+			digests: map[string]digest.Digest{
+				testExtensionsImage: extensionsDigest,
+			},
+			expectedRecordedBaseOS: baseOSDigest.String(),
+			expectedRecordedExt:    extensionsDigest.String(),
+			expectedInspected:      []string{testExtensionsImage},
+		},
+		{
+			name:              "image cannot be inspected",
+			osImageURL:        testBaseOSImage,
+			extensionsImage:   testExtensionsImage,
+			expectedInspected: []string{testBaseOSImage},
+			errExpected:       true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			mosc := newScheduledRebuildMachineOSConfig(map[string]string{
+				constants.RebuildOnBaseImageChangeAnnotationKey: constants.TrueValue,
+			})
+			mosb := newScheduledRebuildMachineOSBuild(testCase.mosbAnnotations, time.Now())
+			pruner := &fakeDigestImagePruner{digests: testCase.digests}
+
+			b, events := newScheduledRebuildReconcilerForTest(t, mosc, mosb, testCase.osImageURL, testCase.extensionsImage, pruner)
+
+			changed, reason, err := b.checkForBaseImageChange(ctx, mosc, mosb)
+			assert.Equal(t, testCase.expectedInspected, pruner.inspected)
+
+			if testCase.errExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectedChanged, changed)
+			assert.Contains(t, reason, testCase.reasonContains)
+
+			current, err := b.mcfgclient.MachineconfigurationV1().MachineOSBuilds().Get(ctx, mosb.Name, metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectedRecordedBaseOS, current.Annotations[constants.BaseOSImageDigestAnnotationKey])
+			assert.Equal(t, testCase.expectedRecordedExt, current.Annotations[constants.ExtensionsImageDigestAnnotationKey])
+
+			if testCase.expectBaseImagesPinned {
+				assertEvent(t, events, "Warning", EventBaseImagesPinned)
+			} else {
+				assertNoEvent(t, events)
+			}
+		})
+	}
+}
@@ -2,10 +2,12 @@ package utils
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/distribution/reference"
 	"github.com/opencontainers/go-digest"
 	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -87,6 +89,75 @@ func GetDigestConfigMapName(mosb *mcfgv1.MachineOSBuild) string {
 	return fmt.Sprintf("digest-%s", getFieldFromMachineOSBuild(mosb))
 }
 
+// Computes the build job name for a single architecture within a
+// multi-architecture build.
+func GetArchBuildJobName(mosb *mcfgv1.MachineOSBuild, arch string) string {
+	return fmt.Sprintf("%s-%s", GetBuildJobName(mosb), arch)
+}
+
+// Computes the digest configmap name for a single architecture within a
+// multi-architecture build.
+func GetArchDigestConfigMapName(mosb *mcfgv1.MachineOSBuild, arch string) string {
+	return fmt.Sprintf("%s-%s", GetDigestConfigMapName(mosb), arch)
+}
+
+// Computes the Containerfile configmap name for a single architecture within a
+// multi-architecture build.
+func GetArchContainerfileConfigMapName(mosb *mcfgv1.MachineOSBuild, arch string) string {
+	return fmt.Sprintf("%s-%s", GetContainerfileConfigMapName(mosb), arch)
+}
+
+// Computes the image pullspec that the image for a single architecture within a
+// multi-architecture build is pushed to by appending the architecture to the
+// tag of the provided pullspec.
+func GetArchImagePushSpec(pullspec, arch string) (string, error) {
+	named, err := reference.ParseNamed(pullspec)
+	if err != nil {
+		return "", err
+	}
+
+	tag := "latest"
+	if tagged, ok := named.(reference.Tagged); ok {
+		tag = tagged.Tag()
+	}
+
+	archTagged, err := reference.WithTag(reference.TrimNamed(named), fmt.Sprintf("%s-%s", tag, arch))
+	if err != nil {
+		return "", err
+	}
+
+	return archTagged.String(), nil
+}
+
+// Computes the pullspecs of the per-architecture images pushed by a
+// multi-architecture build from the architectures recorded on the
+// MachineOSBuild. Returns nothing for single-architecture builds.
+func GetArchImagePushSpecs(mosb *mcfgv1.MachineOSBuild) ([]string, error) {
+	val := mosb.Annotations[constants.BuildArchitecturesAnnotationKey]
+	archs := strings.Split(val, ",")
+	if val == "" || len(archs) < 2 {
+		return nil, nil
+	}
+
+	pushspecs := []string{}
+
+	for _, arch := range archs {
+		pushspec, err := GetArchImagePushSpec(string(mosb.Spec.RenderedImagePushSpec), arch)
+		if err != nil {
+			return nil, fmt.Errorf("could not get image pullspec for architecture %s for MachineOSBuild %s: %w", arch, mosb.Name, err)
+		}
+
+		pushspecs = append(pushspecs, pushspec)
+	}
+
+	return pushspecs, nil
+}
+
+// Computes the archived build logs configmap name.
+func GetBuildLogsConfigMapName(mosb *mcfgv1.MachineOSBuild) string {
+	return fmt.Sprintf("build-logs-%s", getFieldFromMachineOSBuild(mosb))
+}
+
 // Computes the base image pull secret name.
 func GetBasePullSecretName(mosb *mcfgv1.MachineOSBuild) string {
 	return fmt.Sprintf("base-%s", getFieldFromMachineOSBuild(mosb))
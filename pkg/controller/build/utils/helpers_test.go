@@ -3,7 +3,10 @@ package utils
 import (
 	"testing"
 
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // Tests that a given image pullspec with a tag and SHA is correctly substituted.
@@ -14,3 +17,53 @@ func TestParseImagePullspec(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "registry.hostname.com/org/repo@sha256:628e4e8f0a78d91015c6cebeee95931ae2e8defe5dfb4ced4a82830e08937573", out)
 }
+
+// Tests that the architecture is appended to the tag of a given image pullspec.
+func TestGetArchImagePushSpec(t *testing.T) {
+	t.Parallel()
+
+	out, err := GetArchImagePushSpec("registry.hostname.com:5000/org/repo:worker-1234", "arm64")
+	assert.NoError(t, err)
+	assert.Equal(t, "registry.hostname.com:5000/org/repo:worker-1234-arm64", out)
+
+	out, err = GetArchImagePushSpec("registry.hostname.com/org/repo", "amd64")
+	assert.NoError(t, err)
+	assert.Equal(t, "registry.hostname.com/org/repo:latest-amd64", out)
+}
+
+// Tests that the per-architecture image pullspecs are only computed for
+// multi-architecture builds.
+func TestGetArchImagePushSpecs(t *testing.T) {
+	t.Parallel()
+
+	mosb := &mcfgv1.MachineOSBuild{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "worker-1234",
+			Annotations: map[string]string{
+				constants.BuildArchitecturesAnnotationKey: "amd64,arm64",
+			},
+		},
+		Spec: mcfgv1.MachineOSBuildSpec{
+			RenderedImagePushSpec: "registry.hostname.com/org/repo:worker-1234",
+		},
+	}
+
+	out, err := GetArchImagePushSpecs(mosb)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"registry.hostname.com/org/repo:worker-1234-amd64",
+		"registry.hostname.com/org/repo:worker-1234-arm64",
+	}, out)
+
+	mosb.Annotations[constants.BuildArchitecturesAnnotationKey] = "arm64"
+
+	out, err = GetArchImagePushSpecs(mosb)
+	assert.NoError(t, err)
+	assert.Empty(t, out)
+
+	delete(mosb.Annotations, constants.BuildArchitecturesAnnotationKey)
+
+	out, err = GetArchImagePushSpecs(mosb)
+	assert.NoError(t, err)
+	assert.Empty(t, out)
+}
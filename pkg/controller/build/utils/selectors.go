@@ -93,6 +93,25 @@ func EphemeralBuildObjectSelectorForSpecificBuild(mosb *mcfgv1.MachineOSBuild, m
 	return selector.Add(*renderedMCReq, *mcpReq, *mosbNameReq), nil
 }
 
+// Creates a selector for the architecture-specific build objects (Jobs,
+// Containerfile ConfigMaps, and digest ConfigMaps) for a given
+// multi-architecture MachineOSBuild.
+func ArchBuildObjectSelectorForMachineOSBuild(mosbName string) (labels.Selector, error) {
+	mosbNameReq, err := labels.NewRequirement(constants.MachineOSBuildNameLabelKey, selection.Equals, []string{mosbName})
+	if err != nil {
+		return nil, err
+	}
+
+	return labelsToSelector([]string{constants.BuildArchitectureLabelKey}).Add(*mosbNameReq), nil
+}
+
+// Determines if an object was created for a single architecture within a
+// multi-architecture build.
+func IsArchBuildObject(obj metav1.Object) bool {
+	_, ok := obj.GetLabels()[constants.BuildArchitectureLabelKey]
+	return ok
+}
+
 // Fetches the MachineConfigPool name from either the MachineOSBuild or the
 // MachineOSConfig. For MachineOSBuilds, this value is found as a label.
 func getMachineConfigPoolNameFromMachineOSConfigOrMachineOSBuild(mosb *mcfgv1.MachineOSBuild, mosc *mcfgv1.MachineOSConfig) (string, error) {
@@ -87,6 +87,21 @@ const (
 	// InternalReleaseImageAuthSecretName is the name of the secret containing IRI registry htpasswd auth credentials.
 	InternalReleaseImageAuthSecretName = "internal-release-image-registry-auth"
 
+	// InternalReleaseImageAdditionalImagesAnnotationKey lists additional images, such as operator catalogs,
+	// hosted in the IRI registry. The value is a JSON list of {"name", "image", "source"} objects, where image is
+	// a digest reference relative to the registry, e.g. "olm/redhat-operator-index@sha256:...", and source is the
+	// pullspec each control plane node mirrors the image from into its registry.
+	InternalReleaseImageAdditionalImagesAnnotationKey = "machineconfiguration.openshift.io/iri-additional-images"
+
+	// InternalReleaseImageIntegrityScanIntervalAnnotationKey enables the periodic verification of the digests of the
+	// content stored in the IRI registry of each control plane node. The value is a duration of at least "1h", e.g.
+	// "24h"; the scan is disabled when the annotation is not set or "0".
+	InternalReleaseImageIntegrityScanIntervalAnnotationKey = "machineconfiguration.openshift.io/iri-integrity-scan-interval"
+
+	// InternalReleaseImageIntegrityResyncAnnotationKey, when set to "true" on the InternalReleaseImage, makes the
+	// nodes replace the corrupted content found by the integrity scan with a copy fetched from a peer node.
+	InternalReleaseImageIntegrityResyncAnnotationKey = "machineconfiguration.openshift.io/iri-integrity-resync"
+
 	// IRIRegistryPort is the port on which the IRI registry listens on master nodes.
 	IRIRegistryPort = 22625
 
@@ -15,7 +15,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
-
+	"slices"
 	"sort"
 	"strings"
 	"text/template"
@@ -977,40 +977,48 @@ func dedupePasswdUserSSHKeys(passwdUser ign2types.PasswdUser) ign2types.PasswdUs
 	return passwdUser
 }
 
-// CalculateConfigFileDiffs compares the files present in two ignition configurations and returns the list of files
-// that are different between them
-//
-//nolint:dupl
+// CalculateConfigFileDiffs compares the files, directories and links present in two ignition configurations and
+// returns the list of paths that are different between them
 func CalculateConfigFileDiffs(oldIgnConfig, newIgnConfig *ign3types.Config) []string {
-	// Go through the files and see what is new or different
-	oldFileSet := make(map[string]ign3types.File)
-	for _, f := range oldIgnConfig.Storage.Files {
-		oldFileSet[f.Path] = f
+	diffFileSet := []string{}
+	diffFileSet = append(diffFileSet, calculateStoragePathDiffs(oldIgnConfig.Storage.Files, newIgnConfig.Storage.Files, func(f ign3types.File) string { return f.Path })...)
+	diffFileSet = append(diffFileSet, calculateStoragePathDiffs(oldIgnConfig.Storage.Directories, newIgnConfig.Storage.Directories, func(d ign3types.Directory) string { return d.Path })...)
+	diffFileSet = append(diffFileSet, calculateStoragePathDiffs(oldIgnConfig.Storage.Links, newIgnConfig.Storage.Links, func(l ign3types.Link) string { return l.Path })...)
+	return diffFileSet
+}
+
+// calculateStoragePathDiffs returns the paths of the storage entries that were
+// added, removed or changed
+func calculateStoragePathDiffs[T any](oldEntries, newEntries []T, pathOf func(T) string) []string {
+	// Go through the entries and see what is new or different
+	oldEntrySet := make(map[string]T)
+	for _, e := range oldEntries {
+		oldEntrySet[pathOf(e)] = e
 	}
-	newFileSet := make(map[string]ign3types.File)
-	for _, f := range newIgnConfig.Storage.Files {
-		newFileSet[f.Path] = f
+	newEntrySet := make(map[string]T)
+	for _, e := range newEntries {
+		newEntrySet[pathOf(e)] = e
 	}
-	diffFileSet := []string{}
+	diffPathSet := []string{}
 
-	// First check if any files were removed
-	for path := range oldFileSet {
-		_, ok := newFileSet[path]
+	// First check if any entries were removed
+	for path := range oldEntrySet {
+		_, ok := newEntrySet[path]
 		if !ok {
-			diffFileSet = append(diffFileSet, path)
+			diffPathSet = append(diffPathSet, path)
 		}
 	}
 
-	// Now check if any files were added/changed
-	for path, newFile := range newFileSet {
-		oldFile, ok := oldFileSet[path]
+	// Now check if any entries were added/changed
+	for path, newEntry := range newEntrySet {
+		oldEntry, ok := oldEntrySet[path]
 		if !ok {
-			diffFileSet = append(diffFileSet, path)
-		} else if !reflect.DeepEqual(oldFile, newFile) {
-			diffFileSet = append(diffFileSet, path)
+			diffPathSet = append(diffPathSet, path)
+		} else if !reflect.DeepEqual(oldEntry, newEntry) {
+			diffPathSet = append(diffPathSet, path)
 		}
 	}
-	return diffFileSet
+	return diffPathSet
 }
 
 type UnitDiff struct {
@@ -1362,11 +1370,43 @@ func GetCAsFromConfigMap(cm *corev1.ConfigMap, key string) ([]byte, error) {
 }
 
 // Determines if an on-cluster layering image rollout and rebuild is required for the changes applied on the new MC
-func RequiresRebuild(oldMC, newMC *mcfgv1.MachineConfig) bool {
-	return oldMC.Spec.OSImageURL != newMC.Spec.OSImageURL ||
+func RequiresRebuild(oldMC, newMC *mcfgv1.MachineConfig) (bool, error) {
+	if oldMC.Spec.OSImageURL != newMC.Spec.OSImageURL ||
 		oldMC.Spec.KernelType != newMC.Spec.KernelType ||
 		!reflect.DeepEqual(oldMC.Spec.Extensions, newMC.Spec.Extensions) ||
-		!reflect.DeepEqual(oldMC.Spec.KernelArguments, newMC.Spec.KernelArguments)
+		!reflect.DeepEqual(oldMC.Spec.KernelArguments, newMC.Spec.KernelArguments) {
+		return true, nil
+	}
+	return ignitionKernelArgumentsChanged(oldMC, newMC)
+}
+
+// ignitionKernelArgumentsChanged determines if the kernelArguments sections of
+// the Ignition configs differ.
+func ignitionKernelArgumentsChanged(oldMC, newMC *mcfgv1.MachineConfig) (bool, error) {
+	oldKargs, err := ignitionKernelArguments(oldMC)
+	if err != nil {
+		return false, err
+	}
+	newKargs, err := ignitionKernelArguments(newMC)
+	if err != nil {
+		return false, err
+	}
+	return !slices.Equal(oldKargs.ShouldExist, newKargs.ShouldExist) ||
+		!slices.Equal(oldKargs.ShouldNotExist, newKargs.ShouldNotExist), nil
+}
+
+// ignitionKernelArguments returns the kernelArguments section of the Ignition
+// config of a MachineConfig. MachineConfigs without an Ignition config have
+// no kernel arguments.
+func ignitionKernelArguments(mc *mcfgv1.MachineConfig) (ign3types.KernelArguments, error) {
+	if len(mc.Spec.Config.Raw) == 0 {
+		return ign3types.KernelArguments{}, nil
+	}
+	ignConfig, err := ParseAndConvertConfig(mc.Spec.Config.Raw)
+	if err != nil {
+		return ign3types.KernelArguments{}, fmt.Errorf("could not parse the Ignition config of MachineConfig %s: %w", mc.Name, err)
+	}
+	return ignConfig.KernelArguments, nil
 }
 
 type MachinesByStatus struct {
@@ -865,6 +865,12 @@ func TestCalculateConfigFileDiffs(t *testing.T) {
 	if !reflect.DeepEqual(unchangedDiffFileset, []string{}) {
 		t.Errorf("File changes detected where there should have been none: %s", unchangedDiffFileset)
 	}
+
+	// Directories and links are diffed by path too
+	testIgn3ConfigNew.Storage.Files = testIgn3ConfigOld.Storage.Files
+	testIgn3ConfigNew.Storage.Directories = []ign3types.Directory{{Node: ign3types.Node{Path: "/etc/foo"}}}
+	testIgn3ConfigNew.Storage.Links = []ign3types.Link{{Node: ign3types.Node{Path: "/etc/foo/bar"}, LinkEmbedded1: ign3types.LinkEmbedded1{Target: helpers.StrToPtr("/etc/bar")}}}
+	assert.Equal(t, []string{"/etc/foo", "/etc/foo/bar"}, CalculateConfigFileDiffs(&testIgn3ConfigOld, &testIgn3ConfigNew))
 }
 
 func TestParseAndConvertGzippedConfig(t *testing.T) {
@@ -2072,3 +2078,28 @@ func TestGetAllValidPackageSetsForExtension(t *testing.T) {
 	}
 }
 
+
+func TestRequiresRebuild(t *testing.T) {
+	withKargs := func(kargs ...ign3types.KernelArgument) *mcfgv1.MachineConfig {
+		ignConfig := NewIgnConfig()
+		ignConfig.KernelArguments.ShouldExist = kargs
+		return &mcfgv1.MachineConfig{Spec: mcfgv1.MachineConfigSpec{Config: runtime.RawExtension{Raw: helpers.MarshalOrDie(ignConfig)}}}
+	}
+
+	requiresRebuild, err := RequiresRebuild(withKargs("nosmt"), withKargs("nosmt"))
+	require.NoError(t, err)
+	assert.False(t, requiresRebuild)
+
+	requiresRebuild, err = RequiresRebuild(withKargs("nosmt"), withKargs("nosmt", "debug"))
+	require.NoError(t, err)
+	assert.True(t, requiresRebuild)
+
+	// MachineConfigs without an Ignition config have no kernel arguments
+	requiresRebuild, err = RequiresRebuild(&mcfgv1.MachineConfig{}, withKargs())
+	require.NoError(t, err)
+	assert.False(t, requiresRebuild)
+
+	invalid := &mcfgv1.MachineConfig{ObjectMeta: metav1.ObjectMeta{Name: "invalid"}, Spec: mcfgv1.MachineConfigSpec{Config: runtime.RawExtension{Raw: []byte("{")}}}
+	_, err = RequiresRebuild(withKargs("nosmt"), invalid)
+	assert.ErrorContains(t, err, "could not parse the Ignition config of MachineConfig invalid")
+}
@@ -0,0 +1,182 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/distribution/reference"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+)
+
+const (
+	// MachineConfigNodeIRIAdditionalImagesDegraded is the MachineConfigNode condition reporting the
+	// availability of each additional image in the IRI registry of the node.
+	MachineConfigNodeIRIAdditionalImagesDegraded = "InternalReleaseImageAdditionalImagesDegraded"
+
+	// InternalReleaseImageConditionTypeAdditionalImagesAvailable is the InternalReleaseImage condition reporting
+	// whether the additional images are available in the IRI registry of every control plane node.
+	InternalReleaseImageConditionTypeAdditionalImagesAvailable = "AdditionalImagesAvailable"
+
+	// maxIRIAdditionalImages caps the number of additional images, so that
+	// their availability fits in a condition message.
+	maxIRIAdditionalImages = 32
+
+	// maxIRIAdditionalImageMessageLength caps the length of the message
+	// explaining why a single additional image is not available.
+	maxIRIAdditionalImageMessageLength = 512
+
+	// iriAdditionalImageAvailable is reported in place of a message for the
+	// images available in the registry of a node.
+	iriAdditionalImageAvailable = "Available"
+)
+
+// iriAdditionalImageRe matches a digest reference relative to the IRI registry,
+// e.g. "olm/redhat-operator-index@sha256:<digest>".
+var iriAdditionalImageRe = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*(?:/[a-z0-9]+(?:[._-][a-z0-9]+)*)*@sha256:[a-f0-9]{64}$`)
+
+// iriAdditionalImageNameRe matches the name of an additional image.
+var iriAdditionalImageNameRe = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// IRIAdditionalImage is an additional image, such as an operator catalog,
+// hosted in the IRI registry next to the OCP release images.
+type IRIAdditionalImage struct {
+	// Name identifies the image in the status.
+	Name string `json:"name"`
+	// Image is the digest reference of the image relative to the IRI registry.
+	Image string `json:"image"`
+	// Source is the digest pullspec the image is mirrored from, e.g.
+	// "registry.redhat.io/redhat/redhat-operator-index@sha256:<digest>". If
+	// empty, the image must be loaded in the registry by other means.
+	Source string `json:"source,omitempty"`
+}
+
+// IRIAdditionalImageNodeStatus is the availability of an additional image in
+// the IRI registry of a single node.
+type IRIAdditionalImageNodeStatus struct {
+	Name string
+	// Image is the digest reference of the image relative to the registry.
+	Image     string
+	Available bool
+	// Message explains why the image is not available.
+	Message string
+}
+
+// IRIAdditionalImageStatus is the cluster-wide availability of an additional
+// image across the control plane nodes.
+type IRIAdditionalImageStatus struct {
+	Name string
+	// Image is the pullspec of the image through api-int.
+	Image            string
+	AvailableNodes   []string
+	UnavailableNodes []IRIAdditionalImageUnavailableNode
+}
+
+// IRIAdditionalImageUnavailableNode is a node whose IRI registry cannot serve
+// an additional image.
+type IRIAdditionalImageUnavailableNode struct {
+	Node    string
+	Message string
+}
+
+// GetIRIAdditionalImages returns the additional images listed on the
+// InternalReleaseImage, or nil if there are none.
+func GetIRIAdditionalImages(iri *mcfgv1.InternalReleaseImage) ([]IRIAdditionalImage, error) {
+	value, ok := iri.Annotations[InternalReleaseImageAdditionalImagesAnnotationKey]
+	if !ok || strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var images []IRIAdditionalImage
+	if err := json.Unmarshal([]byte(value), &images); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", InternalReleaseImageAdditionalImagesAnnotationKey, err)
+	}
+
+	if len(images) > maxIRIAdditionalImages {
+		return nil, fmt.Errorf("invalid %s annotation: at most %d images are supported", InternalReleaseImageAdditionalImagesAnnotationKey, maxIRIAdditionalImages)
+	}
+
+	names := map[string]struct{}{}
+	for _, image := range images {
+		if image.Name == "" {
+			return nil, fmt.Errorf("invalid %s annotation: image %q has no name", InternalReleaseImageAdditionalImagesAnnotationKey, image.Image)
+		}
+		if !iriAdditionalImageNameRe.MatchString(image.Name) {
+			return nil, fmt.Errorf("invalid %s annotation: name %q must consist of lower case alphanumeric characters or '-'", InternalReleaseImageAdditionalImagesAnnotationKey, image.Name)
+		}
+		if _, ok := names[image.Name]; ok {
+			return nil, fmt.Errorf("invalid %s annotation: duplicate name %q", InternalReleaseImageAdditionalImagesAnnotationKey, image.Name)
+		}
+		names[image.Name] = struct{}{}
+
+		if !iriAdditionalImageRe.MatchString(image.Image) {
+			return nil, fmt.Errorf("invalid %s annotation: image %q of %q must be a digest reference relative to the registry, e.g. olm/catalog@sha256:<digest>",
+				InternalReleaseImageAdditionalImagesAnnotationKey, image.Image, image.Name)
+		}
+
+		if image.Source != "" {
+			if err := validateIRIAdditionalImageSource(image); err != nil {
+				return nil, fmt.Errorf("invalid %s annotation: %w", InternalReleaseImageAdditionalImagesAnnotationKey, err)
+			}
+		}
+	}
+
+	return images, nil
+}
+
+// validateIRIAdditionalImageSource checks that the image is mirrored from a
+// digest pullspec of the same content.
+func validateIRIAdditionalImageSource(image IRIAdditionalImage) error {
+	named, err := reference.ParseNormalizedNamed(image.Source)
+	if err != nil {
+		return fmt.Errorf("source %q of %q is not a valid pullspec: %w", image.Source, image.Name, err)
+	}
+	canonical, ok := named.(reference.Canonical)
+	if !ok {
+		return fmt.Errorf("source %q of %q must be a digest pullspec", image.Source, image.Name)
+	}
+	if !strings.HasSuffix(image.Image, "@"+canonical.Digest().String()) {
+		return fmt.Errorf("source %q of %q must have the same digest as the image %q", image.Source, image.Name, image.Image)
+	}
+	return nil
+}
+
+// FormatIRIAdditionalImagesNodeStatus formats the availability of the
+// additional images in the registry of a node as the message of the
+// MachineConfigNodeIRIAdditionalImagesDegraded condition, one image per line
+// in the "<name> <image>: <Available|message>" format.
+func FormatIRIAdditionalImagesNodeStatus(statuses []IRIAdditionalImageNodeStatus) string {
+	lines := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		message := iriAdditionalImageAvailable
+		if !status.Available {
+			message = strings.Join(strings.Fields(status.Message), " ")
+			if len(message) > maxIRIAdditionalImageMessageLength {
+				message = message[:maxIRIAdditionalImageMessageLength-3] + "..."
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %s", status.Name, status.Image, message))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ParseIRIAdditionalImagesNodeStatus parses the message of the
+// MachineConfigNodeIRIAdditionalImagesDegraded condition of a node, returning
+// the availability of each image by name.
+func ParseIRIAdditionalImagesNodeStatus(message string) map[string]IRIAdditionalImageNodeStatus {
+	statuses := map[string]IRIAdditionalImageNodeStatus{}
+	for _, line := range strings.Split(message, "\n") {
+		name, rest, _ := strings.Cut(line, " ")
+		image, message, ok := strings.Cut(rest, ": ")
+		if !ok || !iriAdditionalImageNameRe.MatchString(name) || !iriAdditionalImageRe.MatchString(image) {
+			continue
+		}
+		status := IRIAdditionalImageNodeStatus{Name: name, Image: image, Available: message == iriAdditionalImageAvailable}
+		if !status.Available {
+			status.Message = message
+		}
+		statuses[name] = status
+	}
+	return statuses
+}
@@ -0,0 +1,132 @@
+package common
+
+import (
+	"testing"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetIRIAdditionalImages(t *testing.T) {
+	const digest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		expected    []IRIAdditionalImage
+		expectedErr string
+	}{
+		{
+			name: "no additional images",
+		},
+		{
+			name: "valid additional images",
+			annotations: map[string]string{
+				InternalReleaseImageAdditionalImagesAnnotationKey: `[{"name":"catalog","image":"olm/redhat-operator-index@` + digest + `"},{"name":"app","image":"app@` + digest + `"}]`,
+			},
+			expected: []IRIAdditionalImage{
+				{Name: "catalog", Image: "olm/redhat-operator-index@" + digest},
+				{Name: "app", Image: "app@" + digest},
+			},
+		},
+		{
+			name:        "invalid json",
+			annotations: map[string]string{InternalReleaseImageAdditionalImagesAnnotationKey: `{`},
+			expectedErr: "invalid",
+		},
+		{
+			name: "missing name",
+			annotations: map[string]string{
+				InternalReleaseImageAdditionalImagesAnnotationKey: `[{"image":"app@` + digest + `"}]`,
+			},
+			expectedErr: "has no name",
+		},
+		{
+			name: "duplicate name",
+			annotations: map[string]string{
+				InternalReleaseImageAdditionalImagesAnnotationKey: `[{"name":"app","image":"app@` + digest + `"},{"name":"app","image":"other@` + digest + `"}]`,
+			},
+			expectedErr: "duplicate name",
+		},
+		{
+			name: "tag reference",
+			annotations: map[string]string{
+				InternalReleaseImageAdditionalImagesAnnotationKey: `[{"name":"app","image":"app:latest"}]`,
+			},
+			expectedErr: "digest reference",
+		},
+		{
+			name: "mirrored from source",
+			annotations: map[string]string{
+				InternalReleaseImageAdditionalImagesAnnotationKey: `[{"name":"catalog","image":"olm/redhat-operator-index@` + digest + `","source":"registry.redhat.io/redhat/redhat-operator-index@` + digest + `"}]`,
+			},
+			expected: []IRIAdditionalImage{
+				{Name: "catalog", Image: "olm/redhat-operator-index@" + digest, Source: "registry.redhat.io/redhat/redhat-operator-index@" + digest},
+			},
+		},
+		{
+			name: "source with a tag",
+			annotations: map[string]string{
+				InternalReleaseImageAdditionalImagesAnnotationKey: `[{"name":"app","image":"app@` + digest + `","source":"quay.io/org/app:latest"}]`,
+			},
+			expectedErr: "must be a digest pullspec",
+		},
+		{
+			name: "source with another digest",
+			annotations: map[string]string{
+				InternalReleaseImageAdditionalImagesAnnotationKey: `[{"name":"app","image":"app@` + digest + `","source":"quay.io/org/app@sha256:2222222222222222222222222222222222222222222222222222222222222222"}]`,
+			},
+			expectedErr: "same digest",
+		},
+		{
+			name: "invalid name",
+			annotations: map[string]string{
+				InternalReleaseImageAdditionalImagesAnnotationKey: `[{"name":"My App","image":"app@` + digest + `"}]`,
+			},
+			expectedErr: "lower case alphanumeric",
+		},
+		{
+			name: "reference with registry host",
+			annotations: map[string]string{
+				InternalReleaseImageAdditionalImagesAnnotationKey: `[{"name":"app","image":"quay.io:443/app@` + digest + `"}]`,
+			},
+			expectedErr: "digest reference",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			iri := &mcfgv1.InternalReleaseImage{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+
+			images, err := GetIRIAdditionalImages(iri)
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, images)
+		})
+	}
+}
+
+func TestIRIAdditionalImagesNodeStatus(t *testing.T) {
+	const digest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+
+	statuses := []IRIAdditionalImageNodeStatus{
+		{Name: "catalog", Image: "olm/catalog@" + digest, Available: true},
+		{Name: "app", Image: "apps/app@" + digest, Message: "skopeo copy failed: exit status 1:\nreading manifest: manifest unknown"},
+	}
+
+	message := FormatIRIAdditionalImagesNodeStatus(statuses)
+	assert.Equal(t, "catalog olm/catalog@"+digest+": Available\napp apps/app@"+digest+": skopeo copy failed: exit status 1: reading manifest: manifest unknown", message)
+
+	parsed := ParseIRIAdditionalImagesNodeStatus(message)
+	assert.Equal(t, map[string]IRIAdditionalImageNodeStatus{
+		"catalog": statuses[0],
+		"app":     {Name: "app", Image: "apps/app@" + digest, Message: "skopeo copy failed: exit status 1: reading manifest: manifest unknown"},
+	}, parsed)
+
+	assert.Empty(t, ParseIRIAdditionalImagesNodeStatus("not a status"))
+}
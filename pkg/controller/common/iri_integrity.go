@@ -0,0 +1,77 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IRIIntegrityStatus is the result of the last integrity scan of the IRI
+// registry storage of a node.
+type IRIIntegrityStatus struct {
+	LastScanTime metav1.Time
+	// VerifiedBlobs is the number of distinct manifests and blobs verified.
+	VerifiedBlobs int
+	// Error is set if the scan could not complete.
+	Error      string
+	Mismatches []IRIIntegrityMismatch
+}
+
+// IRIIntegrityMismatch is a manifest or blob whose content is missing or does
+// not match its digest.
+type IRIIntegrityMismatch struct {
+	Repository string
+	Digest     string
+	// Kind is either "manifest" or "blob".
+	Kind    string
+	Message string
+	// Repaired is set once the content was replaced with a verified copy from
+	// the peer node in RepairedFrom.
+	Repaired     bool
+	RepairedFrom string
+}
+
+// Unrepaired returns the mismatches that were not repaired.
+func (s *IRIIntegrityStatus) Unrepaired() []IRIIntegrityMismatch {
+	if s == nil {
+		return nil
+	}
+	var mismatches []IRIIntegrityMismatch
+	for _, m := range s.Mismatches {
+		if !m.Repaired {
+			mismatches = append(mismatches, m)
+		}
+	}
+	return mismatches
+}
+
+// GetIRIIntegrityScanInterval returns how often the IRI registry content must
+// be verified, or 0 if the scan is disabled. The scan reads the whole registry
+// storage, so it only runs when the InternalReleaseImage opts in.
+func GetIRIIntegrityScanInterval(iri *mcfgv1.InternalReleaseImage) (time.Duration, error) {
+	value, ok := iri.Annotations[InternalReleaseImageIntegrityScanIntervalAnnotationKey]
+	if !ok || strings.TrimSpace(value) == "" {
+		return 0, nil
+	}
+
+	interval, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation: %w", InternalReleaseImageIntegrityScanIntervalAnnotationKey, err)
+	}
+	if interval < 0 {
+		return 0, fmt.Errorf("invalid %s annotation: %q must not be negative", InternalReleaseImageIntegrityScanIntervalAnnotationKey, value)
+	}
+	if interval > 0 && interval < time.Hour {
+		return 0, fmt.Errorf("invalid %s annotation: %q must be at least 1h", InternalReleaseImageIntegrityScanIntervalAnnotationKey, value)
+	}
+	return interval, nil
+}
+
+// IsIRIIntegrityResyncEnabled returns true if corrupted IRI registry content
+// must be re-synced from a peer node.
+func IsIRIIntegrityResyncEnabled(iri *mcfgv1.InternalReleaseImage) bool {
+	return iri.Annotations[InternalReleaseImageIntegrityResyncAnnotationKey] == "true"
+}
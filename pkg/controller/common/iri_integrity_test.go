@@ -0,0 +1,77 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetIRIIntegrityScanInterval(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		expected    time.Duration
+		expectedErr string
+	}{
+		{
+			name:     "disabled by default",
+			expected: 0,
+		},
+		{
+			name:        "custom interval",
+			annotations: map[string]string{InternalReleaseImageIntegrityScanIntervalAnnotationKey: "6h"},
+			expected:    6 * time.Hour,
+		},
+		{
+			name:        "disabled",
+			annotations: map[string]string{InternalReleaseImageIntegrityScanIntervalAnnotationKey: "0"},
+			expected:    0,
+		},
+		{
+			name:        "not a duration",
+			annotations: map[string]string{InternalReleaseImageIntegrityScanIntervalAnnotationKey: "daily"},
+			expectedErr: "invalid",
+		},
+		{
+			name:        "negative",
+			annotations: map[string]string{InternalReleaseImageIntegrityScanIntervalAnnotationKey: "-1h"},
+			expectedErr: "must not be negative",
+		},
+		{
+			name:        "too frequent",
+			annotations: map[string]string{InternalReleaseImageIntegrityScanIntervalAnnotationKey: "5m"},
+			expectedErr: "at least 1h",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			iri := &mcfgv1.InternalReleaseImage{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+
+			interval, err := GetIRIIntegrityScanInterval(iri)
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, interval)
+		})
+	}
+}
+
+func TestIRIIntegrityStatusUnrepaired(t *testing.T) {
+	status := &IRIIntegrityStatus{
+		Mismatches: []IRIIntegrityMismatch{
+			{Digest: "sha256:1111", Repaired: true},
+			{Digest: "sha256:2222"},
+		},
+	}
+	assert.Equal(t, []IRIIntegrityMismatch{{Digest: "sha256:2222"}}, status.Unrepaired())
+
+	var nilStatus *IRIIntegrityStatus
+	assert.Empty(t, nilStatus.Unrepaired())
+}
@@ -2,8 +2,12 @@ package common
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
 	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	buildconstants "github.com/openshift/machine-config-operator/pkg/controller/build/constants"
 	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -149,6 +153,7 @@ func (l *LayeredNodeState) SetDesiredStateFromPool(mcp *mcfgv1.MachineConfigPool
 
 	metav1.SetMetaDataAnnotation(&l.node.ObjectMeta, daemonconsts.DesiredMachineConfigAnnotationKey, mcp.Spec.Configuration.Name)
 	delete(l.node.Annotations, daemonconsts.DesiredImageAnnotationKey)
+	delete(l.node.Annotations, daemonconsts.DesiredArchImageAnnotationKey)
 }
 
 // Sets the desired MachineConfig & image annotations from the MachineOSBuild and
@@ -164,6 +169,57 @@ func (l *LayeredNodeState) SetDesiredStateFromMachineOSConfig(mosc *mcfgv1.Machi
 	} else {
 		delete(l.node.Annotations, daemonconsts.DesiredImageAnnotationKey)
 	}
+
+	// The image for the node's architecture within a multi-arch image is
+	// recorded so that the MCD can check which image it booted without
+	// inspecting the manifest list.
+	archImage := ""
+	if moscs.HasOSImage() && string(mosb.Status.DigestedImagePushSpec) == moscs.GetOSImage() {
+		archImage = GetArchImagePullspec(mosb, l.node.Status.NodeInfo.Architecture)
+	}
+
+	if archImage != "" {
+		metav1.SetMetaDataAnnotation(&l.node.ObjectMeta, daemonconsts.DesiredArchImageAnnotationKey, archImage)
+	} else {
+		delete(l.node.Annotations, daemonconsts.DesiredArchImageAnnotationKey)
+	}
+}
+
+// GetArchImagePullspec returns the digested pullspec of the image for the
+// given architecture within the multi-arch image built by a MachineOSBuild.
+// Returns an empty string if the MachineOSBuild has no image for the
+// architecture.
+func GetArchImagePullspec(mosb *mcfgv1.MachineOSBuild, arch string) string {
+	val := mosb.Annotations[buildconstants.ArchImageDigestsAnnotationKey]
+	if val == "" || arch == "" {
+		return ""
+	}
+
+	named, err := reference.ParseNamed(string(mosb.Status.DigestedImagePushSpec))
+	if err != nil {
+		return ""
+	}
+
+	for _, archDigest := range strings.Split(val, ",") {
+		imageArch, imageDigest, ok := strings.Cut(archDigest, "=")
+		if !ok || imageArch != arch {
+			continue
+		}
+
+		parsed, err := digest.Parse(imageDigest)
+		if err != nil {
+			return ""
+		}
+
+		archNamed, err := reference.WithDigest(reference.TrimNamed(named), parsed)
+		if err != nil {
+			return ""
+		}
+
+		return archNamed.String()
+	}
+
+	return ""
 }
 
 // Node returns the node object for read-only access. Callers must not mutate
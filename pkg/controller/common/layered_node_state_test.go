@@ -1,9 +1,11 @@
 package common
 
 import (
+	"fmt"
 	"testing"
 
 	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	buildconstants "github.com/openshift/machine-config-operator/pkg/controller/build/constants"
 	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	"github.com/openshift/machine-config-operator/test/helpers"
 	"github.com/stretchr/testify/assert"
@@ -428,3 +430,80 @@ func TestLayeredNodeStateIsMutated(t *testing.T) {
 		})
 	}
 }
+
+func TestLayeredNodeStateDesiredArchImage(t *testing.T) {
+	t.Parallel()
+
+	manifestList := "registry.host.com/org/repo@sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	arm64Digest := "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	amd64Digest := "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+
+	newArchMachineOSBuild := func(archDigests string) *mcfgv1.MachineOSBuild {
+		mosb := newMachineOSBuild(machineConfigV0)
+		mosb.Status.DigestedImagePushSpec = mcfgv1.ImageDigestFormat(manifestList)
+		if archDigests != "" {
+			mosb.Annotations = map[string]string{buildconstants.ArchImageDigestsAnnotationKey: archDigests}
+		}
+		return mosb
+	}
+
+	newArchNode := func(arch string) *corev1.Node {
+		node := newLayeredNode(machineConfigV0, machineConfigV0, imageV0, imageV0)
+		node.Annotations[daemonconsts.DesiredArchImageAnnotationKey] = "stale"
+		node.Status.NodeInfo.Architecture = arch
+		return node
+	}
+
+	tests := []struct {
+		name          string
+		node          *corev1.Node
+		mosc          *mcfgv1.MachineOSConfig
+		mosb          *mcfgv1.MachineOSBuild
+		expectedImage string
+	}{
+		{
+			name:          "node architecture in multi-arch image",
+			node:          newArchNode("arm64"),
+			mosc:          newMachineOSConfig(manifestList),
+			mosb:          newArchMachineOSBuild(fmt.Sprintf("amd64=%s,arm64=%s", amd64Digest, arm64Digest)),
+			expectedImage: "registry.host.com/org/repo@" + arm64Digest,
+		},
+		{
+			name: "node architecture not in multi-arch image",
+			node: newArchNode("s390x"),
+			mosc: newMachineOSConfig(manifestList),
+			mosb: newArchMachineOSBuild(fmt.Sprintf("amd64=%s,arm64=%s", amd64Digest, arm64Digest)),
+		},
+		{
+			name: "single-arch image",
+			node: newArchNode("amd64"),
+			mosc: newMachineOSConfig(manifestList),
+			mosb: newArchMachineOSBuild(""),
+		},
+		{
+			name: "MachineOSBuild is not for the MachineOSConfig image",
+			node: newArchNode("arm64"),
+			mosc: newMachineOSConfig(imageV1),
+			mosb: newArchMachineOSBuild(fmt.Sprintf("amd64=%s,arm64=%s", amd64Digest, arm64Digest)),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			lns := NewLayeredNodeState(test.node)
+			lns.SetDesiredStateFromMachineOSConfig(test.mosc, test.mosb)
+
+			if test.expectedImage == "" {
+				assert.NotContains(t, lns.Node().Annotations, daemonconsts.DesiredArchImageAnnotationKey)
+			} else {
+				assert.Equal(t, test.expectedImage, lns.Node().Annotations[daemonconsts.DesiredArchImageAnnotationKey])
+			}
+
+			lns.SetDesiredStateFromPool(newMachineConfigPool(machineConfigV0))
+			assert.NotContains(t, lns.Node().Annotations, daemonconsts.DesiredArchImageAnnotationKey)
+		})
+	}
+}
@@ -3,7 +3,10 @@ package common
 import (
 	"fmt"
 	"reflect"
+	"regexp"
 	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
@@ -11,6 +14,7 @@ import (
 	opv1 "github.com/openshift/api/operator/v1"
 	mcoplistersv1 "github.com/openshift/client-go/operator/listers/operator/v1"
 	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
 )
@@ -22,8 +26,8 @@ import (
 // rationale.
 //
 // We can only update machine configs that have changes to the files,
-// directories, links, and systemd units sections of the included ignition
-// config currently.
+// directories, links, passwd users and groups, and systemd units sections of
+// the included ignition config currently.
 func IsRenderedConfigReconcilable(oldConfig, newConfig *mcfgv1.MachineConfig,
 	overrides *opv1.IrreconcilableValidationOverrides) error {
 	return IsComponentConfigsReconcilable(oldConfig, []*mcfgv1.MachineConfig{newConfig}, overrides)
@@ -100,9 +104,8 @@ func isReconcilable(oldIgn ign3types.Config, oldConfig, newConfig *mcfgv1.Machin
 func isConfigReconcilable(oldIgn, newIgn ign3types.Config, oldConfig, newConfig *mcfgv1.MachineConfig, overrides *opv1.IrreconcilableValidationOverrides) error {
 	// Passwd section
 
-	// we don't currently configure Groups in place. we don't configure Users except
-	// for setting/updating SSHAuthorizedKeys for the only allowed user "core".
-	// otherwise we can't fix it if something changed here.
+	// users and groups are managed in place, except for user "core" for which
+	// only SSHAuthorizedKeys and PasswordHash can be set/updated.
 	if !reflect.DeepEqual(oldIgn.Passwd, newIgn.Passwd) {
 		if err := validatePasswdChanges(oldIgn, newIgn); err != nil {
 			return fmt.Errorf("invalid passwd change(s): %w", err)
@@ -110,10 +113,10 @@ func isConfigReconcilable(oldIgn, newIgn ign3types.Config, oldConfig, newConfig
 	}
 
 	// Kernel args
-
-	// ignition now supports kernel args, but the MCO doesn't implement them yet
 	if !reflect.DeepEqual(oldIgn.KernelArguments, newIgn.KernelArguments) {
-		return fmt.Errorf("ignition kargs section contains changes")
+		if err := validateKernelArgumentsChanges(newIgn, newConfig); err != nil {
+			return fmt.Errorf("invalid kernel arguments change(s): %w", err)
+		}
 	}
 
 	// Storage section
@@ -134,25 +137,20 @@ func isConfigReconcilable(oldIgn, newIgn ign3types.Config, oldConfig, newConfig
 		!reflect.DeepEqual(oldIgn.Storage.Raid, newIgn.Storage.Raid) {
 		return fmt.Errorf("ignition raid section contains changes")
 	}
-	if !reflect.DeepEqual(oldIgn.Storage.Directories, newIgn.Storage.Directories) {
-		return fmt.Errorf("ignition directories section contains changes")
-	}
-	if !reflect.DeepEqual(oldIgn.Storage.Links, newIgn.Storage.Links) {
-		// This means links have been added, as opposed as being removed as it happened with
-		// https://bugzilla.redhat.com/show_bug.cgi?id=1677198. This doesn't really change behavior
-		// since we still don't support links but we allow old MC to remove links when upgrading.
-		if len(newIgn.Storage.Links) != 0 {
-			return fmt.Errorf("ignition links section contains changes")
+	// Directories and symbolic links are reconciled in place, but hard links
+	// are not: the MCD can't tell them apart from regular files on disk.
+	for _, link := range newIgn.Storage.Links {
+		if link.Hard == nil || !*link.Hard {
+			continue
+		}
+		if !slices.ContainsFunc(oldIgn.Storage.Links, func(oldLink ign3types.Link) bool { return reflect.DeepEqual(oldLink, link) }) {
+			return fmt.Errorf("ignition links section contains changes to hard link %q", link.Path)
 		}
 	}
 
-	// Special case files append: if the new config wants us to append, then we
-	// have to force a reprovision since it's not idempotent
+	// Files with appends are reconciled by the MCD re-applying the appends to
+	// the contents the file had before, but some special files can't be written
 	for _, f := range newIgn.Storage.Files {
-		if len(f.Append) > 0 {
-			return fmt.Errorf("ignition file %v includes append", f.Path)
-		}
-		// We also disallow writing some special files
 		if f.Path == constants.MachineConfigDaemonForceFile {
 			return fmt.Errorf("cannot create %s via Ignition", f.Path)
 		}
@@ -191,26 +189,116 @@ func verifyUserFields(pwdUser ign3types.PasswdUser) error {
 	return nil
 }
 
+// Validates that the Ignition kernelArguments section does not contradict
+// itself or the kernelArguments of the MachineConfig, as the daemon could not
+// satisfy both.
+func validateKernelArgumentsChanges(newIgn ign3types.Config, newConfig *mcfgv1.MachineConfig) error {
+	specKargs := sets.New[string]()
+	for _, karg := range newConfig.Spec.KernelArguments {
+		specKargs.Insert(strings.Fields(karg)...)
+	}
+
+	shouldExist := sets.New[ign3types.KernelArgument](newIgn.KernelArguments.ShouldExist...)
+	for _, karg := range newIgn.KernelArguments.ShouldNotExist {
+		if shouldExist.Has(karg) {
+			return fmt.Errorf("kernel argument %q is listed in both shouldExist and shouldNotExist", karg)
+		}
+		if specKargs.Has(string(karg)) {
+			return fmt.Errorf("kernel argument %q is listed in shouldNotExist and in the MachineConfig kernelArguments", karg)
+		}
+	}
+
+	return nil
+}
+
+// passwdNameRegexp matches the user and group names accepted by useradd and
+// groupadd on RHEL, see NAME_REGEX in useradd(8).
+var passwdNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.][a-zA-Z0-9_.-]{0,30}[$]?$`)
+
+// reservedPasswdNames are the system users and groups of the base OS. The MCD
+// refuses to delete or modify the system users and groups it did not create,
+// including by adding members to the groups, apart from supplementary members
+// of the wheel group. These well-known ones are rejected before any node is
+// updated.
+var reservedPasswdNames = sets.New(
+	"root", "bin", "daemon", "adm", "lp", "sync", "shutdown", "halt", "mail",
+	"operator", "games", "ftp", "nobody", "dbus", "polkitd", "sshd", "chrony",
+	"tss", "rpc", "rpcuser", "sssd", "dnsmasq", "unbound", "openvswitch",
+	"systemd-coredump", "systemd-journal", "systemd-network", "systemd-oom",
+	"systemd-resolve", "systemd-timesync", "wheel", "sys", "tty", "disk", "kmem",
+	"utmp", "audio", "video", "users", "input", "kvm", "render", "sgx",
+	"ssh_keys", "hugetlbfs", "dialout", "floppy", "cdrom", "tape", "lock", "man",
+	"utempter", "printadmin", "clevis", "sudo",
+)
+
+// validatePasswdName verifies that name can be used for a local user or group
+// managed by the MCD.
+func validatePasswdName(name string) error {
+	if !passwdNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid name %q", name)
+	}
+	if _, err := strconv.Atoi(name); err == nil {
+		return fmt.Errorf("invalid name %q: names must not be fully numeric", name)
+	}
+	if reservedPasswdNames.Has(name) {
+		return fmt.Errorf("the %s system user or group of the OS cannot be managed", name)
+	}
+	return nil
+}
+
 // Validates that changes to the Passwd section of the Ignition config are
 // reconcilable.
 func validatePasswdChanges(oldIgn, newIgn ign3types.Config) error {
 	if !reflect.DeepEqual(oldIgn.Passwd.Groups, newIgn.Passwd.Groups) {
-		return fmt.Errorf("ignition Passwd Groups section contains changes")
+		groups := sets.New[string]()
+		for _, group := range newIgn.Passwd.Groups {
+			if err := validatePasswdName(group.Name); err != nil {
+				return fmt.Errorf("ignition passwd group section contains unsupported changes: %w", err)
+			}
+			if group.Name == constants.CoreGroupName {
+				return fmt.Errorf("ignition passwd group section contains unsupported changes: the %s group cannot be managed", constants.CoreGroupName)
+			}
+			if groups.Has(group.Name) {
+				return fmt.Errorf("ignition passwd group section contains duplicate group %q", group.Name)
+			}
+			groups.Insert(group.Name)
+		}
 	}
 
 	if !reflect.DeepEqual(oldIgn.Passwd.Users, newIgn.Passwd.Users) {
-		// there is an update to Users, we must verify that it is ONLY making an acceptable
-		// change to the SSHAuthorizedKeys for the user "core"
+		// The core user is managed by the OS, only its SSH keys and password
+		// hash can be changed. Other users are created, modified and removed
+		// by the MCD.
+		users := sets.New[string]()
 		for _, user := range newIgn.Passwd.Users {
-			if user.Name != constants.CoreUserName {
-				return fmt.Errorf("ignition passwd user section contains unsupported changes: non-core user")
+			if users.Has(user.Name) {
+				return fmt.Errorf("ignition passwd user section contains duplicate user %q", user.Name)
 			}
-		}
-		// We don't want to panic if the "new" users is empty, and it's still reconcilable because the absence of a user here does not mean "remove the user from the system"
-		if len(newIgn.Passwd.Users) != 0 {
-			klog.Infof("user data to be verified before ssh update: %v", newIgn.Passwd.Users[len(newIgn.Passwd.Users)-1])
-			if err := verifyUserFields(newIgn.Passwd.Users[len(newIgn.Passwd.Users)-1]); err != nil {
-				return err
+			users.Insert(user.Name)
+
+			if user.Name == constants.CoreUserName {
+				klog.Infof("user data to be verified before ssh update: %v", user)
+				if err := verifyUserFields(user); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := validatePasswdName(user.Name); err != nil {
+				return fmt.Errorf("ignition passwd user section contains unsupported changes: %w", err)
+			}
+			// Users may be granted administrative access as supplementary
+			// members of the wheel group
+			groups := slices.DeleteFunc(slices.Clone(user.Groups), func(group ign3types.Group) bool {
+				return string(group) == constants.WheelGroupName
+			})
+			if user.PrimaryGroup != nil {
+				groups = append(groups, ign3types.Group(*user.PrimaryGroup))
+			}
+			for _, group := range groups {
+				if reservedPasswdNames.Has(string(group)) || string(group) == constants.CoreGroupName {
+					return fmt.Errorf("ignition passwd user section contains unsupported changes: user %q cannot join the %s system group of the OS", user.Name, group)
+				}
 			}
 		}
 	}
@@ -120,7 +120,33 @@ func TestIsRenderedConfigReconcilable(t *testing.T) {
 	isReconcilable = IsRenderedConfigReconcilable(oldConfig, newConfig, nil)
 	checkReconcilableResults(t, "Raid", isReconcilable)
 
-	// Verify Passwd Groups changes unsupported
+	// Verify Directories and symbolic Links changes are supported
+	newIgnCfg.Storage.Directories = []ign3types.Directory{
+		{
+			Node:               ign3types.Node{Path: "/etc/foo"},
+			DirectoryEmbedded1: ign3types.DirectoryEmbedded1{Mode: helpers.IntToPtr(0o700)},
+		},
+	}
+	newIgnCfg.Storage.Links = []ign3types.Link{
+		{
+			Node:          ign3types.Node{Path: "/etc/foo/bar"},
+			LinkEmbedded1: ign3types.LinkEmbedded1{Target: helpers.StrToPtr("/etc/bar")},
+		},
+	}
+	newConfig = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+	isReconcilable = IsRenderedConfigReconcilable(oldConfig, newConfig, nil)
+	checkReconcilableResults(t, "DirectoriesAndLinks", isReconcilable)
+
+	// Verify hard Links changes unsupported
+	newIgnCfg.Storage.Links[0].Hard = helpers.BoolToPtr(true)
+	newConfig = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+	isReconcilable = IsRenderedConfigReconcilable(oldConfig, newConfig, nil)
+	checkIrreconcilableResults(t, "HardLinks", isReconcilable)
+	newIgnCfg.Storage.Directories = nil
+	newIgnCfg.Storage.Links = nil
+	newConfig = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+
+	// Verify Passwd Groups changes supported
 	oldIgnCfg = NewIgnConfig()
 	oldConfig = helpers.CreateMachineConfigFromIgnition(oldIgnCfg)
 	newIgnCfg = NewIgnConfig()
@@ -134,9 +160,24 @@ func TestIsRenderedConfigReconcilable(t *testing.T) {
 	newIgnCfg.Passwd.Groups = []ign3types.PasswdGroup{tempGroup}
 	newConfig = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
 	isReconcilable = IsRenderedConfigReconcilable(oldConfig, newConfig, nil)
-	checkIrreconcilableResults(t, "PasswdGroups", isReconcilable)
+	checkReconcilableResults(t, "PasswdGroups", isReconcilable)
+
+	// Verify invalid, duplicate and OS managed Passwd Groups unsupported
+	for _, groups := range [][]ign3types.PasswdGroup{
+		{{Name: "test group"}},
+		{{Name: "1000"}},
+		{{Name: "root"}},
+		{{Name: "wheel", ShouldExist: helpers.BoolToPtr(false)}},
+		{{Name: constants.CoreGroupName}},
+		{{Name: "testGroup"}, {Name: "testGroup", Gid: helpers.IntToPtr(1001)}},
+	} {
+		newIgnCfg.Passwd.Groups = groups
+		newConfig = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+		isReconcilable = IsRenderedConfigReconcilable(oldConfig, newConfig, nil)
+		checkIrreconcilableResults(t, "PasswdGroups", isReconcilable)
+	}
 
-	// Verify Ignition kernelArguments changes unsupported
+	// Verify Ignition kernelArguments changes supported
 	oldIgnCfg = NewIgnConfig()
 	oldConfig = helpers.CreateMachineConfigFromIgnition(oldIgnCfg)
 	newIgnCfg = NewIgnConfig()
@@ -145,9 +186,27 @@ func TestIsRenderedConfigReconcilable(t *testing.T) {
 
 	newConfig = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
 
+	isReconcilable = IsRenderedConfigReconcilable(oldConfig, newConfig, nil)
+	checkReconcilableResults(t, "KernelArguments", isReconcilable)
+
+	// Verify Ignition kernelArguments contradicting the MachineConfig kernelArguments unsupported
+	newConfig.Spec.KernelArguments = []string{"nosmt baz=foo"}
 	isReconcilable = IsRenderedConfigReconcilable(oldConfig, newConfig, nil)
 	checkIrreconcilableResults(t, "KernelArguments", isReconcilable)
 
+	// Verify file appends supported
+	oldIgnCfg = NewIgnConfig()
+	oldConfig = helpers.CreateMachineConfigFromIgnition(oldIgnCfg)
+	newIgnCfg = NewIgnConfig()
+	newIgnCfg.Storage.Files = []ign3types.File{{
+		Node:          ign3types.Node{Path: "/etc/hosts"},
+		FileEmbedded1: ign3types.FileEmbedded1{Append: []ign3types.Resource{{Source: helpers.StrToPtr("data:,10.0.0.1%20foo%0A")}}},
+	}}
+	newConfig = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+
+	isReconcilable = IsRenderedConfigReconcilable(oldConfig, newConfig, nil)
+	checkReconcilableResults(t, "FilesAppend", isReconcilable)
+
 	// Verify Tang changes are supported (even though we don't do anything with them yet)
 	oldIgnCfg = NewIgnConfig()
 	oldIgnCfg.Storage.Luks = []ign3types.Luks{
@@ -203,7 +262,54 @@ func TestReconcilableSSH(t *testing.T) {
 	errMsg := IsRenderedConfigReconcilable(oldMcfg, newMcfg, nil)
 	checkReconcilableResults(t, "SSH", errMsg)
 
-	// 	Check that updating User with User that is not core is not supported
+	// Check that adding a user other than core is supported
+	builder := ign3types.PasswdUser{
+		Name:              "builder",
+		SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"9012"},
+		HomeDir:           helpers.StrToPtr("/var/home/builder"),
+		Groups:            []ign3types.Group{"builders"},
+		UID:               helpers.IntToPtr(1001),
+	}
+	newIgnCfg.Passwd.Users = []ign3types.PasswdUser{tempUser1, builder}
+	newMcfg = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+	errMsg = IsRenderedConfigReconcilable(oldMcfg, newMcfg, nil)
+	checkReconcilableResults(t, "Users", errMsg)
+
+	// Check that users may join the wheel group as a supplementary group
+	admin := builder
+	admin.Groups = []ign3types.Group{"builders", constants.WheelGroupName}
+	newIgnCfg.Passwd.Users = []ign3types.PasswdUser{tempUser1, admin}
+	newMcfg = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+	errMsg = IsRenderedConfigReconcilable(oldMcfg, newMcfg, nil)
+	checkReconcilableResults(t, "Users", errMsg)
+
+	// Check that duplicate users and the root user are not supported
+	newIgnCfg.Passwd.Users = []ign3types.PasswdUser{tempUser1, builder, builder}
+	newMcfg = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+	errMsg = IsRenderedConfigReconcilable(oldMcfg, newMcfg, nil)
+	checkIrreconcilableResults(t, "Users", errMsg)
+
+	newIgnCfg.Passwd.Users = []ign3types.PasswdUser{tempUser1, {Name: "root"}}
+	newMcfg = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+	errMsg = IsRenderedConfigReconcilable(oldMcfg, newMcfg, nil)
+	checkIrreconcilableResults(t, "Users", errMsg)
+
+	// Check that system users and groups of the OS can't be managed
+	for _, users := range [][]ign3types.PasswdUser{
+		{tempUser1, {Name: "sshd", Shell: helpers.StrToPtr("/bin/bash")}},
+		{tempUser1, {Name: "nobody", ShouldExist: helpers.BoolToPtr(false)}},
+		{tempUser1, {Name: "builder", Groups: []ign3types.Group{"wheel", "sshd"}}},
+		{tempUser1, {Name: "builder", PrimaryGroup: helpers.StrToPtr("wheel")}},
+		{tempUser1, {Name: "builder", PrimaryGroup: helpers.StrToPtr("core")}},
+	} {
+		newIgnCfg.Passwd.Users = users
+		newMcfg = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+		errMsg = IsRenderedConfigReconcilable(oldMcfg, newMcfg, nil)
+		checkIrreconcilableResults(t, "Users", errMsg)
+	}
+	newIgnCfg.Passwd.Users = []ign3types.PasswdUser{tempUser1}
+
+	// Check that updating User with a User with an invalid name is not supported
 	tempUser2 := ign3types.PasswdUser{Name: "core", SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"1234"}}
 	oldIgnCfg.Passwd.Users = append(oldIgnCfg.Passwd.Users, tempUser2)
 	oldMcfg = helpers.CreateMachineConfigFromIgnition(oldIgnCfg)
@@ -213,14 +319,14 @@ func TestReconcilableSSH(t *testing.T) {
 	errMsg = IsRenderedConfigReconcilable(oldMcfg, newMcfg, nil)
 	checkIrreconcilableResults(t, "SSH", errMsg)
 
-	// check that we cannot make updates if any other Passwd.User field is changed.
+	// check that we cannot make updates if any other Passwd.User field of core is changed.
 	tempUser4 := ign3types.PasswdUser{Name: "core", SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"5678"}, HomeDir: helpers.StrToPtr("somedir")}
 	newIgnCfg.Passwd.Users[0] = tempUser4
 	newMcfg = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
 	errMsg = IsRenderedConfigReconcilable(oldMcfg, newMcfg, nil)
 	checkIrreconcilableResults(t, "SSH", errMsg)
 
-	// check that we cannot add a user or have len(Passwd.Users)> 1
+	// check that we cannot add a user with an invalid name
 	tempUser5 := ign3types.PasswdUser{Name: "some user", SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"5678"}}
 	newIgnCfg.Passwd.Users = append(newIgnCfg.Passwd.Users, tempUser5)
 	newMcfg = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
@@ -0,0 +1,312 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/coreos/go-systemd/v22/unit"
+	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+)
+
+// Ignition only checks that unit contents parse. The directives below are
+// checked so that typos are reported when rendering, rather than silently
+// ignored by systemd on the nodes. The lists can't follow new systemd
+// releases, so findings are only warnings for MachineConfigs.
+
+var (
+	unitDirectives = sets.New(
+		"Description", "Documentation", "Wants", "Requires", "Requisite", "BindsTo", "BindTo", "PartOf",
+		"Upholds", "Conflicts", "Before", "After", "OnFailure", "OnSuccess", "PropagatesReloadTo",
+		"ReloadPropagatedFrom", "PropagatesStopTo", "StopPropagatedFrom", "JoinsNamespaceOf",
+		"RequiresMountsFor", "WantsMountsFor", "OnFailureJobMode", "OnFailureIsolate", "OnSuccessJobMode",
+		"IgnoreOnIsolate", "StopWhenUnneeded", "RefuseManualStart", "RefuseManualStop", "AllowIsolate",
+		"DefaultDependencies", "SurviveFinalKillSignal", "CollectMode", "FailureAction", "SuccessAction",
+		"FailureActionExitStatus", "SuccessActionExitStatus", "JobTimeoutSec", "JobRunningTimeoutSec",
+		"JobTimeoutAction", "JobTimeoutRebootArgument", "StartLimitIntervalSec", "StartLimitInterval",
+		"StartLimitBurst", "StartLimitAction", "RebootArgument", "SourcePath",
+	)
+
+	// unitConditions are the Condition*= and Assert*= directives of [Unit]
+	unitConditions = sets.New(
+		"Architecture", "Firmware", "Virtualization", "Host", "KernelCommandLine", "KernelVersion",
+		"Credential", "Environment", "Security", "Capability", "ACPower", "NeedsUpdate", "FirstBoot",
+		"PathExists", "PathExistsGlob", "PathIsDirectory", "PathIsSymbolicLink", "PathIsMountPoint",
+		"PathIsReadWrite", "PathIsEncrypted", "DirectoryNotEmpty", "FileNotEmpty", "FileIsExecutable",
+		"User", "Group", "ControlGroupController", "Memory", "CPUs", "CPUFeature", "OSRelease",
+		"MemoryPressure", "CPUPressure", "IOPressure",
+	)
+
+	installDirectives = sets.New("Alias", "WantedBy", "RequiredBy", "UpheldBy", "Also", "DefaultInstance")
+
+	// execDirectives are shared by the units spawning processes, see systemd.exec(5)
+	execDirectives = sets.New(
+		"WorkingDirectory", "RootDirectory", "RootImage", "RootImageOptions", "RootEphemeral", "RootHash",
+		"RootHashSignature", "RootVerity", "RootImagePolicy", "MountImagePolicy", "ExtensionImagePolicy",
+		"MountAPIVFS", "ProtectProc", "ProcSubset", "BindPaths", "BindReadOnlyPaths", "MountImages",
+		"ExtensionImages", "ExtensionDirectories", "User", "Group", "DynamicUser", "SupplementaryGroups",
+		"SetLoginEnvironment", "PAMName", "CapabilityBoundingSet", "AmbientCapabilities", "NoNewPrivileges",
+		"SecureBits", "SELinuxContext", "AppArmorProfile", "SmackProcessLabel", "LimitCPU", "LimitFSIZE",
+		"LimitDATA", "LimitSTACK", "LimitCORE", "LimitRSS", "LimitNOFILE", "LimitAS", "LimitNPROC",
+		"LimitMEMLOCK", "LimitLOCKS", "LimitSIGPENDING", "LimitMSGQUEUE", "LimitNICE", "LimitRTPRIO",
+		"LimitRTTIME", "UMask", "CoredumpFilter", "KeyringMode", "OOMScoreAdjust", "TimerSlackNSec",
+		"Personality", "IgnoreSIGPIPE", "Nice", "CPUSchedulingPolicy", "CPUSchedulingPriority",
+		"CPUSchedulingResetOnFork", "CPUAffinity", "NUMAPolicy", "NUMAMask", "IOSchedulingClass",
+		"IOSchedulingPriority", "ProtectSystem", "ProtectHome", "RuntimeDirectory", "StateDirectory",
+		"CacheDirectory", "LogsDirectory", "ConfigurationDirectory", "RuntimeDirectoryMode",
+		"StateDirectoryMode", "CacheDirectoryMode", "LogsDirectoryMode", "ConfigurationDirectoryMode",
+		"RuntimeDirectoryPreserve", "TimeoutCleanSec", "ReadWritePaths", "ReadOnlyPaths", "InaccessiblePaths",
+		"ReadWriteDirectories", "ReadOnlyDirectories", "InaccessibleDirectories", "ExecPaths", "NoExecPaths",
+		"TemporaryFileSystem", "PrivateTmp", "PrivateDevices", "PrivateNetwork", "NetworkNamespacePath",
+		"PrivateIPC", "IPCNamespacePath", "MemoryKSM", "PrivateUsers", "ProtectHostname", "ProtectClock",
+		"ProtectKernelTunables", "ProtectKernelModules", "ProtectKernelLogs", "ProtectControlGroups",
+		"RestrictAddressFamilies", "RestrictFileSystems", "RestrictNamespaces", "LockPersonality",
+		"MemoryDenyWriteExecute", "RestrictRealtime", "RestrictSUIDSGID", "RemoveIPC", "PrivateMounts",
+		"MountFlags", "SystemCallFilter", "SystemCallErrorNumber", "SystemCallArchitectures", "SystemCallLog",
+		"Environment", "EnvironmentFile", "PassEnvironment", "UnsetEnvironment", "StandardInput",
+		"StandardOutput", "StandardError", "StandardInputText", "StandardInputData", "LogLevelMax",
+		"LogExtraFields", "LogRateLimitIntervalSec", "LogRateLimitBurst", "LogFilterPatterns", "LogNamespace",
+		"SyslogIdentifier", "SyslogFacility", "SyslogLevel", "SyslogLevelPrefix", "TTYPath", "TTYReset",
+		"TTYVHangup", "TTYRows", "TTYColumns", "TTYVTDisallocate", "LoadCredential", "LoadCredentialEncrypted",
+		"ImportCredential", "SetCredential", "SetCredentialEncrypted", "UtmpIdentifier", "UtmpMode",
+	)
+
+	// killDirectives are shared by the units spawning processes, see systemd.kill(5)
+	killDirectives = sets.New(
+		"KillMode", "KillSignal", "RestartKillSignal", "SendSIGHUP", "SendSIGKILL", "FinalKillSignal",
+		"WatchdogSignal",
+	)
+
+	// resourceControlDirectives are shared by the units with a cgroup, see systemd.resource-control(5)
+	resourceControlDirectives = sets.New(
+		"CPUAccounting", "CPUWeight", "StartupCPUWeight", "CPUQuota", "CPUQuotaPeriodSec", "AllowedCPUs",
+		"StartupAllowedCPUs", "AllowedMemoryNodes", "StartupAllowedMemoryNodes", "MemoryAccounting",
+		"MemoryMin", "MemoryLow", "StartupMemoryLow", "DefaultStartupMemoryLow", "MemoryHigh",
+		"StartupMemoryHigh", "MemoryMax", "StartupMemoryMax", "MemorySwapMax", "StartupMemorySwapMax",
+		"MemoryZSwapMax", "StartupMemoryZSwapMax", "MemoryZSwapWriteback", "MemoryLimit", "DefaultMemoryMin",
+		"DefaultMemoryLow", "TasksAccounting", "TasksMax", "IOAccounting", "IOWeight", "StartupIOWeight",
+		"IODeviceWeight", "IOReadBandwidthMax", "IOWriteBandwidthMax", "IOReadIOPSMax", "IOWriteIOPSMax",
+		"IODeviceLatencyTargetSec", "IPAccounting", "IPAddressAllow", "IPAddressDeny", "SocketBindAllow",
+		"SocketBindDeny", "RestrictNetworkInterfaces", "NFTSet", "IPIngressFilterPath", "IPEgressFilterPath",
+		"BPFProgram", "DeviceAllow", "DevicePolicy", "Slice", "Delegate", "DelegateSubgroup",
+		"DisableControllers", "ManagedOOMSwap", "ManagedOOMMemoryPressure", "ManagedOOMMemoryPressureLimit",
+		"ManagedOOMMemoryPressureDurationSec", "ManagedOOMPreference", "MemoryPressureWatch",
+		"MemoryPressureThresholdSec", "CoredumpReceive", "CPUShares", "StartupCPUShares", "BlockIOAccounting",
+		"BlockIOWeight", "StartupBlockIOWeight", "BlockIODeviceWeight", "BlockIOReadBandwidth",
+		"BlockIOWriteBandwidth",
+	)
+
+	processDirectives = execDirectives.Union(killDirectives).Union(resourceControlDirectives)
+
+	// unitTypeSections maps the unit types to their type-specific section and
+	// its directives. Units without one only have [Unit] and [Install].
+	unitTypeSections = map[string]struct {
+		section    string
+		directives sets.Set[string]
+	}{
+		".service": {"Service", processDirectives.Union(sets.New(
+			"Type", "ExitType", "RemainAfterExit", "GuessMainPID", "PIDFile", "BusName", "ExecStart",
+			"ExecStartPre", "ExecStartPost", "ExecCondition", "ExecReload", "ExecStop", "ExecStopPost",
+			"RestartSec", "RestartSteps", "RestartMaxDelaySec", "TimeoutStartSec", "TimeoutStopSec",
+			"TimeoutAbortSec", "TimeoutSec", "TimeoutStartFailureMode", "TimeoutStopFailureMode",
+			"RuntimeMaxSec", "RuntimeRandomizedExtraSec", "WatchdogSec", "Restart", "RestartMode",
+			"SuccessExitStatus", "RestartPreventExitStatus", "RestartForceExitStatus", "RootDirectoryStartOnly",
+			"NonBlocking", "NotifyAccess", "Sockets", "FileDescriptorStoreMax", "FileDescriptorStorePreserve",
+			"USBFunctionDescriptors", "USBFunctionStrings", "OOMPolicy", "OpenFile", "ReloadSignal",
+			"PermissionsStartOnly", "StartLimitInterval", "StartLimitBurst", "StartLimitAction",
+			"FailureAction", "RebootArgument",
+		))},
+		".socket": {"Socket", processDirectives.Union(sets.New(
+			"ListenStream", "ListenDatagram", "ListenSequentialPacket", "ListenFIFO", "ListenSpecial",
+			"ListenNetlink", "ListenMessageQueue", "ListenUSBFunction", "SocketProtocol", "BindIPv6Only",
+			"Backlog", "BindToDevice", "SocketUser", "SocketGroup", "SocketMode", "DirectoryMode", "Accept",
+			"Writable", "FlushPending", "MaxConnections", "MaxConnectionsPerSource", "KeepAlive",
+			"KeepAliveTimeSec", "KeepAliveIntervalSec", "KeepAliveProbes", "NoDelay", "Priority",
+			"DeferAcceptSec", "ReceiveBuffer", "SendBuffer", "IPTOS", "IPTTL", "Mark", "ReusePort",
+			"SmackLabel", "SmackLabelIPIn", "SmackLabelIPOut", "SELinuxContextFromNet", "PipeSize",
+			"MessageQueueMaxMessages", "MessageQueueMessageSize", "FreeBind", "Transparent", "Broadcast",
+			"PassCredentials", "PassSecurity", "PassPacketInfo", "Timestamping", "TCPCongestion",
+			"ExecStartPre", "ExecStartPost", "ExecStopPre", "ExecStopPost", "TimeoutSec", "Service",
+			"RemoveOnStop", "Symlinks", "FileDescriptorName", "TriggerLimitIntervalSec", "TriggerLimitBurst",
+			"PollLimitIntervalSec", "PollLimitBurst", "PassFileDescriptorsToExec",
+		))},
+		".mount": {"Mount", processDirectives.Union(sets.New(
+			"What", "Where", "Type", "Options", "SloppyOptions", "LazyUnmount", "ReadWriteOnly",
+			"ForceUnmount", "DirectoryMode", "TimeoutSec",
+		))},
+		".automount": {"Automount", sets.New("Where", "ExtraOptions", "DirectoryMode", "TimeoutIdleSec")},
+		".swap": {"Swap", processDirectives.Union(sets.New(
+			"What", "Priority", "Options", "TimeoutSec",
+		))},
+		".timer": {"Timer", sets.New(
+			"OnActiveSec", "OnBootSec", "OnStartupSec", "OnUnitActiveSec", "OnUnitInactiveSec", "OnCalendar",
+			"AccuracySec", "RandomizedDelaySec", "FixedRandomDelay", "OnClockChange", "OnTimezoneChange",
+			"Unit", "Persistent", "WakeSystem", "RemainAfterElapse",
+		)},
+		".path": {"Path", sets.New(
+			"PathExists", "PathExistsGlob", "PathChanged", "PathModified", "DirectoryNotEmpty", "Unit",
+			"MakeDirectory", "DirectoryMode", "TriggerLimitIntervalSec", "TriggerLimitBurst",
+		)},
+		".slice": {"Slice", resourceControlDirectives},
+		".scope": {"Scope", resourceControlDirectives.Union(killDirectives).Union(sets.New(
+			"RuntimeMaxSec", "RuntimeRandomizedExtraSec", "TimeoutStopSec", "OOMPolicy",
+		))},
+	}
+
+	serviceTypes    = []string{"simple", "exec", "forking", "oneshot", "dbus", "notify", "notify-reload", "idle"}
+	serviceRestarts = []string{"no", "on-success", "on-failure", "on-abnormal", "on-watchdog", "on-abort", "always"}
+	timerTriggers   = []string{"OnActiveSec", "OnBootSec", "OnStartupSec", "OnUnitActiveSec", "OnUnitInactiveSec", "OnCalendar"}
+)
+
+// ValidateSystemdUnits checks the contents and dropins of the units against
+// the known systemd sections and directives. Complete unit files are also
+// checked for the settings systemd refuses to load them without. Masked
+// units are skipped, as their contents are not written.
+func ValidateSystemdUnits(units []ign3types.Unit) error {
+	var errs []error
+	for _, u := range units {
+		if u.Mask != nil && *u.Mask {
+			continue
+		}
+		if err := validateSystemdUnit(u); err != nil {
+			errs = append(errs, fmt.Errorf("invalid systemd unit %q: %w", u.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateMachineConfigSystemdUnits runs ValidateSystemdUnits on the units of
+// a MachineConfig. Ignition configs which don't parse are left to
+// ValidateMachineConfig.
+func ValidateMachineConfigSystemdUnits(cfg mcfgv1.MachineConfigSpec) error {
+	if cfg.Config.Raw == nil {
+		return nil
+	}
+	ignCfg, err := ParseAndConvertConfig(cfg.Config.Raw)
+	if err != nil {
+		return nil
+	}
+	return ValidateSystemdUnits(ignCfg.Systemd.Units)
+}
+
+func validateSystemdUnit(u ign3types.Unit) error {
+	var errs []error
+	var options []*unit.UnitOption
+	if u.Contents != nil && *u.Contents != "" {
+		opts, err := unit.Deserialize(strings.NewReader(*u.Contents))
+		if err != nil {
+			return err
+		}
+		errs = append(errs, validateUnitOptions(u.Name, opts)...)
+		options = opts
+	}
+	for _, dropin := range u.Dropins {
+		if dropin.Contents == nil || *dropin.Contents == "" {
+			continue
+		}
+		opts, err := unit.Deserialize(strings.NewReader(*dropin.Contents))
+		if err != nil {
+			return fmt.Errorf("dropin %q: %w", dropin.Name, err)
+		}
+		for _, err := range validateUnitOptions(u.Name, opts) {
+			errs = append(errs, fmt.Errorf("dropin %q: %w", dropin.Name, err))
+		}
+		options = append(options, opts...)
+	}
+
+	// Dropins may only extend a unit shipped by the OS
+	if u.Contents != nil && *u.Contents != "" {
+		errs = append(errs, validateUnitCompleteness(u.Name, options)...)
+	}
+	return errors.Join(errs...)
+}
+
+// validateUnitOptions checks the options are known for the unit type.
+func validateUnitOptions(name string, opts []*unit.UnitOption) []error {
+	var errs []error
+	typeSection, hasTypeSection := unitTypeSections[path.Ext(name)]
+	for _, opt := range opts {
+		// Extension sections and directives are ignored by systemd
+		if strings.HasPrefix(opt.Section, "X-") || strings.HasPrefix(opt.Name, "X-") {
+			continue
+		}
+
+		var known bool
+		switch {
+		case opt.Section == "Unit":
+			condition, isCondition := strings.CutPrefix(opt.Name, "Condition")
+			if !isCondition {
+				condition, isCondition = strings.CutPrefix(opt.Name, "Assert")
+			}
+			known = unitDirectives.Has(opt.Name) || (isCondition && unitConditions.Has(condition))
+		case opt.Section == "Install":
+			known = installDirectives.Has(opt.Name)
+		case hasTypeSection && opt.Section == typeSection.section:
+			known = typeSection.directives.Has(opt.Name)
+		default:
+			errs = append(errs, fmt.Errorf("unknown section [%s]", opt.Section))
+			continue
+		}
+		if !known {
+			errs = append(errs, fmt.Errorf("unknown directive %q in section [%s]", opt.Name, opt.Section))
+			continue
+		}
+
+		switch {
+		case opt.Section == "Service" && opt.Name == "Type" && opt.Value != "" && !slices.Contains(serviceTypes, opt.Value):
+			errs = append(errs, fmt.Errorf("invalid Type=%s in section [Service], must be one of %s", opt.Value, strings.Join(serviceTypes, ", ")))
+		case opt.Section == "Service" && opt.Name == "Restart" && opt.Value != "" && !slices.Contains(serviceRestarts, opt.Value):
+			errs = append(errs, fmt.Errorf("invalid Restart=%s in section [Service], must be one of %s", opt.Value, strings.Join(serviceRestarts, ", ")))
+		}
+	}
+	// Unknown sections are reported once per option otherwise
+	return slices.CompactFunc(errs, func(a, b error) bool { return a.Error() == b.Error() })
+}
+
+// validateUnitCompleteness mirrors the checks systemd refuses to load a
+// service or timer unit for.
+func validateUnitCompleteness(name string, opts []*unit.UnitOption) []error {
+	values := func(section, name string) []string {
+		var result []string
+		for _, opt := range opts {
+			if opt.Section != section || opt.Name != name {
+				continue
+			}
+			// An empty value resets the list
+			if opt.Value == "" {
+				result = nil
+				continue
+			}
+			result = append(result, opt.Value)
+		}
+		return result
+	}
+
+	var errs []error
+	switch path.Ext(name) {
+	case ".service":
+		serviceType := "simple"
+		if types := values("Service", "Type"); len(types) > 0 {
+			serviceType = types[len(types)-1]
+		}
+		execStart := values("Service", "ExecStart")
+		switch {
+		case len(execStart) == 0 && len(values("Service", "ExecStop")) == 0 && len(values("Unit", "SuccessAction")) == 0:
+			errs = append(errs, fmt.Errorf("service has no ExecStart=, ExecStop=, or SuccessAction="))
+		case serviceType != "oneshot" && len(execStart) == 0:
+			errs = append(errs, fmt.Errorf("service has no ExecStart= setting, which is only allowed for Type=oneshot services"))
+		case serviceType != "oneshot" && len(execStart) > 1:
+			errs = append(errs, fmt.Errorf("service has more than one ExecStart= setting, which is only allowed for Type=oneshot services"))
+		}
+	case ".timer":
+		if !slices.ContainsFunc(timerTriggers, func(trigger string) bool { return len(values("Timer", trigger)) > 0 }) {
+			errs = append(errs, fmt.Errorf("timer has none of the %s= settings", strings.Join(timerTriggers, "=, ")))
+		}
+	}
+	return errs
+}
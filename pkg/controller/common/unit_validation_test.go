@@ -0,0 +1,138 @@
+package common
+
+import (
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestValidateSystemdUnits(t *testing.T) {
+	testCases := []struct {
+		name        string
+		unit        ign3types.Unit
+		expectedErr string
+	}{
+		{
+			name: "valid service",
+			unit: ign3types.Unit{
+				Name:     "foo.service",
+				Contents: helpers.StrToPtr("[Unit]\nDescription=Foo\nConditionPathExists=/etc/foo\nX-Foo=bar\n\n[Service]\nType=oneshot\nExecStart=/bin/true\nExecStart=/bin/false\nRestart=on-failure\nMemoryMax=1G\nKillMode=process\n\n[Install]\nWantedBy=multi-user.target\n"),
+			},
+		},
+		{
+			name: "valid timer",
+			unit: ign3types.Unit{
+				Name:     "foo.timer",
+				Contents: helpers.StrToPtr("[Timer]\nOnCalendar=daily\nPersistent=true\n"),
+			},
+		},
+		{
+			name: "dropin for an OS unit",
+			unit: ign3types.Unit{
+				Name:    "kubelet.service",
+				Dropins: []ign3types.Dropin{{Name: "10-foo.conf", Contents: helpers.StrToPtr("[Service]\nEnvironment=FOO=bar\n")}},
+			},
+		},
+		{
+			name: "masked unit is skipped",
+			unit: ign3types.Unit{
+				Name:     "foo.service",
+				Contents: helpers.StrToPtr("[Service]\nExecStrat=/bin/true\n"),
+				Mask:     helpers.BoolToPtr(true),
+			},
+		},
+		{
+			name: "misspelled directive",
+			unit: ign3types.Unit{
+				Name:     "foo.service",
+				Contents: helpers.StrToPtr("[Service]\nExecStrat=/bin/true\nExecStart=/bin/true\n"),
+			},
+			expectedErr: `invalid systemd unit "foo.service": unknown directive "ExecStrat" in section [Service]`,
+		},
+		{
+			name: "directive in the wrong section",
+			unit: ign3types.Unit{
+				Name:     "foo.service",
+				Contents: helpers.StrToPtr("[Service]\nExecStart=/bin/true\nStartLimitIntervalSec=0\n"),
+			},
+			expectedErr: `unknown directive "StartLimitIntervalSec" in section [Service]`,
+		},
+		{
+			name: "section of another unit type",
+			unit: ign3types.Unit{
+				Name:     "foo.timer",
+				Contents: helpers.StrToPtr("[Timer]\nOnBootSec=5min\n\n[Service]\nExecStart=/bin/true\n"),
+			},
+			expectedErr: "unknown section [Service]",
+		},
+		{
+			name: "invalid service type",
+			unit: ign3types.Unit{
+				Name:     "foo.service",
+				Contents: helpers.StrToPtr("[Service]\nType=daemon\nExecStart=/bin/true\n"),
+			},
+			expectedErr: "invalid Type=daemon in section [Service]",
+		},
+		{
+			name: "invalid dropin",
+			unit: ign3types.Unit{
+				Name:    "kubelet.service",
+				Dropins: []ign3types.Dropin{{Name: "10-foo.conf", Contents: helpers.StrToPtr("[Service]\nRestart=sometimes\n")}},
+			},
+			expectedErr: `dropin "10-foo.conf": invalid Restart=sometimes in section [Service]`,
+		},
+		{
+			name: "service without ExecStart",
+			unit: ign3types.Unit{
+				Name:     "foo.service",
+				Contents: helpers.StrToPtr("[Service]\nExecStop=/bin/true\n"),
+			},
+			expectedErr: "service has no ExecStart= setting, which is only allowed for Type=oneshot services",
+		},
+		{
+			name: "ExecStart from a dropin",
+			unit: ign3types.Unit{
+				Name:     "foo.service",
+				Contents: helpers.StrToPtr("[Unit]\nDescription=Foo\n"),
+				Dropins:  []ign3types.Dropin{{Name: "10-foo.conf", Contents: helpers.StrToPtr("[Service]\nExecStart=/bin/true\n")}},
+			},
+		},
+		{
+			name: "simple service with two ExecStart",
+			unit: ign3types.Unit{
+				Name:     "foo.service",
+				Contents: helpers.StrToPtr("[Service]\nExecStart=/bin/true\nExecStart=/bin/false\n"),
+			},
+			expectedErr: "service has more than one ExecStart= setting",
+		},
+		{
+			name: "ExecStart reset",
+			unit: ign3types.Unit{
+				Name:     "foo.service",
+				Contents: helpers.StrToPtr("[Service]\nExecStart=/bin/true\nExecStart=\nExecStart=/bin/false\n"),
+			},
+		},
+		{
+			name: "timer without trigger",
+			unit: ign3types.Unit{
+				Name:     "foo.timer",
+				Contents: helpers.StrToPtr("[Timer]\nPersistent=true\n"),
+			},
+			expectedErr: "timer has none of the OnActiveSec=",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := ValidateSystemdUnits([]ign3types.Unit{testCase.unit})
+			if testCase.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, testCase.expectedErr)
+			}
+		})
+	}
+}
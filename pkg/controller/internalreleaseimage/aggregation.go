@@ -14,6 +14,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
 )
 
@@ -28,13 +29,24 @@ const (
 	apiServerInternalURLPort = ":6443"
 )
 
+// iriAggregatedStatus is the cluster-wide IRI status aggregated from the
+// control plane MachineConfigNodes.
+type iriAggregatedStatus struct {
+	releases      []mcfgv1.InternalReleaseImageBundleStatus
+	iriStatus     string
+	degradedNodes []string
+	notReadyNodes []string
+	// availability of each additional image, nil if it could not be determined
+	additionalImages []ctrlcommon.IRIAdditionalImageStatus
+}
+
 // aggregateMCNIRIStatus aggregates the IRI status from all control plane MachineConfigNodes
-// and returns the cluster-wide status for each release bundle, the overall IRI status,
-// and lists of degraded/not ready nodes.
-func (ctrl *Controller) aggregateMCNIRIStatus(iri *mcfgv1.InternalReleaseImage) ([]mcfgv1.InternalReleaseImageBundleStatus, string, []string, []string, error) {
+// and returns the cluster-wide status for each release bundle and additional image, the
+// overall IRI status, and lists of degraded/not ready nodes.
+func (ctrl *Controller) aggregateMCNIRIStatus(iri *mcfgv1.InternalReleaseImage) (*iriAggregatedStatus, error) {
 	mcns, err := ctrl.mcnLister.List(labels.Everything())
 	if err != nil {
-		return nil, "", nil, nil, fmt.Errorf("failed to list MachineConfigNodes: %w", err)
+		return &iriAggregatedStatus{}, fmt.Errorf("failed to list MachineConfigNodes: %w", err)
 	}
 
 	// Filter to only control plane nodes (IRI only runs on control plane)
@@ -42,7 +54,7 @@ func (ctrl *Controller) aggregateMCNIRIStatus(iri *mcfgv1.InternalReleaseImage)
 
 	if len(controlPlaneMCNs) == 0 {
 		klog.V(2).Info("No control plane MachineConfigNodes found, skipping IRI status aggregation")
-		return nil, IRIStatusAllReleasesAvailable, nil, nil, nil
+		return &iriAggregatedStatus{iriStatus: IRIStatusAllReleasesAvailable}, nil
 	}
 
 	sort.Slice(controlPlaneMCNs, func(i, j int) bool {
@@ -54,12 +66,18 @@ func (ctrl *Controller) aggregateMCNIRIStatus(iri *mcfgv1.InternalReleaseImage)
 	// Check if api-int registry is available
 	clusterDomain, apiIntRegistryHost, apiIntAvailable, err := ctrl.checkAPIIntRegistryAvailability()
 	if err != nil {
-		return buildAPIIntUnavailableReleases(iri.Spec.Releases, ""), IRIStatusAPIIntNotAvailable, nil, nil, nil
+		return &iriAggregatedStatus{
+			releases:  buildAPIIntUnavailableReleases(iri.Spec.Releases, ""),
+			iriStatus: IRIStatusAPIIntNotAvailable,
+		}, nil
 	}
 
 	if !apiIntAvailable {
 		klog.V(2).Info("api-int registry is not available, marking all releases as unavailable")
-		return buildAPIIntUnavailableReleases(iri.Spec.Releases, apiIntRegistryHost), IRIStatusAPIIntNotAvailable, nil, nil, nil
+		return &iriAggregatedStatus{
+			releases:  buildAPIIntUnavailableReleases(iri.Spec.Releases, apiIntRegistryHost),
+			iriStatus: IRIStatusAPIIntNotAvailable,
+		}, nil
 	}
 
 	// Process MCN releases and build release map
@@ -82,7 +100,104 @@ func (ctrl *Controller) aggregateMCNIRIStatus(iri *mcfgv1.InternalReleaseImage)
 	klog.V(4).Infof("Aggregation complete. IRIStatus: %s, Not ready nodes: %v, Degraded nodes: %v",
 		result.iriStatus, result.notReadyNodes, result.degradedNodes)
 
-	return aggregatedReleases, result.iriStatus, result.degradedNodes, result.notReadyNodes, nil
+	aggregated := &iriAggregatedStatus{
+		releases:      aggregatedReleases,
+		iriStatus:     result.iriStatus,
+		degradedNodes: result.degradedNodes,
+		notReadyNodes: result.notReadyNodes,
+	}
+
+	// An invalid list of additional images is reported by the caller.
+	additionalImages, err := ctrlcommon.GetIRIAdditionalImages(iri)
+	if err != nil {
+		return aggregated, nil
+	}
+	aggregated.additionalImages = aggregateAdditionalImages(additionalImages, controlPlaneMCNs, result.notReadyNodes, apiIntRegistryHost)
+
+	return aggregated, nil
+}
+
+// aggregateAdditionalImages builds the availability of each additional image
+// from the status reported by each control plane node. An image is only
+// available on a node if the node is ready and reported the image in its
+// registry.
+func aggregateAdditionalImages(images []ctrlcommon.IRIAdditionalImage, controlPlaneMCNs []*mcfgv1.MachineConfigNode, notReadyNodes []string, apiIntRegistryHost string) []ctrlcommon.IRIAdditionalImageStatus {
+	aggregated := []ctrlcommon.IRIAdditionalImageStatus{}
+	if len(images) == 0 {
+		return aggregated
+	}
+
+	notReady := sets.New(notReadyNodes...)
+	nodeStatuses := map[string]map[string]ctrlcommon.IRIAdditionalImageNodeStatus{}
+	for _, mcn := range controlPlaneMCNs {
+		statuses := map[string]ctrlcommon.IRIAdditionalImageNodeStatus{}
+		if c := meta.FindStatusCondition(mcn.Status.Conditions, ctrlcommon.MachineConfigNodeIRIAdditionalImagesDegraded); c != nil {
+			statuses = ctrlcommon.ParseIRIAdditionalImagesNodeStatus(c.Message)
+		}
+		nodeStatuses[mcn.Name] = statuses
+	}
+
+	for _, image := range images {
+		status := ctrlcommon.IRIAdditionalImageStatus{
+			Name:  image.Name,
+			Image: fmt.Sprintf("%s/%s", apiIntRegistryHost, image.Image),
+		}
+
+		for _, mcn := range controlPlaneMCNs {
+			nodeStatus, reported := nodeStatuses[mcn.Name][image.Name]
+			switch {
+			case notReady.Has(mcn.Name):
+				status.UnavailableNodes = append(status.UnavailableNodes, ctrlcommon.IRIAdditionalImageUnavailableNode{Node: mcn.Name, Message: "Node is not ready"})
+			case !reported || nodeStatus.Image != image.Image:
+				status.UnavailableNodes = append(status.UnavailableNodes, ctrlcommon.IRIAdditionalImageUnavailableNode{Node: mcn.Name, Message: "Image availability not yet reported by the node"})
+			case !nodeStatus.Available:
+				status.UnavailableNodes = append(status.UnavailableNodes, ctrlcommon.IRIAdditionalImageUnavailableNode{Node: mcn.Name, Message: nodeStatus.Message})
+			default:
+				status.AvailableNodes = append(status.AvailableNodes, mcn.Name)
+			}
+		}
+
+		if len(status.UnavailableNodes) > 0 {
+			klog.V(4).Infof("Additional image %s is not available on %d node(s)", image.Name, len(status.UnavailableNodes))
+		}
+		aggregated = append(aggregated, status)
+	}
+
+	return aggregated
+}
+
+// additionalImagesCondition reports whether the additional images are
+// available on every control plane node, detailing the nodes missing them.
+func additionalImagesCondition(images []ctrlcommon.IRIAdditionalImageStatus, generation int64) metav1.Condition {
+	var available, unavailable []string
+	for _, image := range images {
+		if len(image.UnavailableNodes) == 0 {
+			available = append(available, fmt.Sprintf("%s (%s)", image.Name, image.Image))
+			continue
+		}
+		nodes := make([]string, 0, len(image.UnavailableNodes))
+		for _, node := range image.UnavailableNodes {
+			nodes = append(nodes, fmt.Sprintf("%s: %s", node.Node, node.Message))
+		}
+		unavailable = append(unavailable, fmt.Sprintf("%s (%s) is not available on %s", image.Name, image.Image, strings.Join(nodes, "; ")))
+	}
+
+	if len(unavailable) > 0 {
+		return metav1.Condition{
+			Type:               ctrlcommon.InternalReleaseImageConditionTypeAdditionalImagesAvailable,
+			Status:             metav1.ConditionFalse,
+			Reason:             "AdditionalImagesNotAvailable",
+			Message:            strings.Join(unavailable, "\n"),
+			ObservedGeneration: generation,
+		}
+	}
+	return metav1.Condition{
+		Type:               ctrlcommon.InternalReleaseImageConditionTypeAdditionalImagesAvailable,
+		Status:             metav1.ConditionTrue,
+		Reason:             "AllAdditionalImagesAvailable",
+		Message:            fmt.Sprintf("All the additional images are available: %s", strings.Join(available, ", ")),
+		ObservedGeneration: generation,
+	}
 }
 
 // filterControlPlaneMCNs returns only MachineConfigNodes that are control plane nodes.
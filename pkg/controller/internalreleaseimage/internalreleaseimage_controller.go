@@ -251,6 +251,9 @@ func (ctrl *Controller) internalReleaseImageChanged(old, newIRI *mcfgv1.Internal
 	if !equality.Semantic.DeepEqual(old.Spec, newIRI.Spec) {
 		return true
 	}
+	if old.Annotations[ctrlcommon.InternalReleaseImageAdditionalImagesAnnotationKey] != newIRI.Annotations[ctrlcommon.InternalReleaseImageAdditionalImagesAnnotationKey] {
+		return true
+	}
 	return false
 }
 
@@ -620,10 +623,15 @@ func (ctrl *Controller) updateInternalReleaseImageStatusWithReleases(
 	err error,
 ) error {
 	// Aggregate MCN IRI status before entering retry loop
-	aggregatedReleases, iriStatus, degradedNodes, notReadyNodes, aggErr := ctrl.aggregateMCNIRIStatus(iri)
+	aggregated, aggErr := ctrl.aggregateMCNIRIStatus(iri)
 	if aggErr != nil {
 		klog.Warningf("Failed to aggregate MCN IRI status: %v", aggErr)
 	}
+	// an invalid list of additional images must be fixed by the user
+	if _, parseErr := ctrlcommon.GetIRIAdditionalImages(iri); err == nil && parseErr != nil {
+		err = parseErr
+	}
+	aggregatedReleases, iriStatus, degradedNodes, notReadyNodes := aggregated.releases, aggregated.iriStatus, aggregated.degradedNodes, aggregated.notReadyNodes
 
 	return retry.RetryOnConflict(updateBackoff, func() error {
 		// Get the latest version of the IRI directly from the API server to avoid conflicts
@@ -703,6 +711,15 @@ func (ctrl *Controller) updateInternalReleaseImageStatusWithReleases(
 		// Update the condition and check if it actually changed
 		conditionChanged := meta.SetStatusCondition(&newIRI.Status.Conditions, condition)
 
+		// Report the availability of the additional images, if it could be determined
+		switch {
+		case aggregated.additionalImages == nil:
+		case len(aggregated.additionalImages) == 0:
+			conditionChanged = meta.RemoveStatusCondition(&newIRI.Status.Conditions, ctrlcommon.InternalReleaseImageConditionTypeAdditionalImagesAvailable) || conditionChanged
+		default:
+			conditionChanged = meta.SetStatusCondition(&newIRI.Status.Conditions, additionalImagesCondition(aggregated.additionalImages, newIRI.Generation)) || conditionChanged
+		}
+
 		// Check if releases changed
 		releasesChanged := aggregatedReleases != nil && !equality.Semantic.DeepEqual(newIRI.Status.Releases, aggregatedReleases)
 
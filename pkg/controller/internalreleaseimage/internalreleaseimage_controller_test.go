@@ -438,6 +438,29 @@ func TestAggregateIRIStatus(t *testing.T) {
 				assert.Equal(t, "ocp-release-bundle-4.21.5-x86_64", actualIRI.Status.Releases[0].Name)
 			},
 		},
+		{
+			name: "invalid additional images produce SyncError",
+			initialObjects: objs(
+				iri().finalizer(iriFinalizerName).additionalImages(`[{"name":"catalog","image":"olm/catalog:latest"}]`),
+				clusterVersion(),
+				cconfig().withDNS("example.com"),
+				iriCertSecret(),
+				iriRegistryCredentialsSecret(),
+				pullSecret(),
+				machineconfigmaster(),
+				machineconfigworker(),
+				mcn("master-0"),
+				node("master-0"),
+				infrastructure(),
+			),
+			verify: func(t *testing.T, actualIRI *mcfgv1.InternalReleaseImage) {
+				assert.NotNil(t, actualIRI)
+				assert.Len(t, actualIRI.Status.Conditions, 1)
+				assert.Equal(t, metav1.ConditionTrue, actualIRI.Status.Conditions[0].Status)
+				assert.Equal(t, "SyncError", actualIRI.Status.Conditions[0].Reason)
+				assert.Contains(t, actualIRI.Status.Conditions[0].Message, ctrlcommon.InternalReleaseImageAdditionalImagesAnnotationKey)
+			},
+		},
 	}
 
 	for _, tc := range cases {
@@ -461,6 +484,63 @@ func TestAggregateIRIStatus(t *testing.T) {
 	}
 }
 
+func TestAggregateAdditionalImages(t *testing.T) {
+	const digest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	images := []ctrlcommon.IRIAdditionalImage{
+		{Name: "catalog", Image: "olm/catalog@" + digest},
+		{Name: "app", Image: "apps/app@" + digest},
+	}
+
+	mcns := []*mcfgv1.MachineConfigNode{
+		mcn("master-0").additionalImagesStatus(
+			ctrlcommon.IRIAdditionalImageNodeStatus{Name: "catalog", Image: "olm/catalog@" + digest, Available: true},
+			ctrlcommon.IRIAdditionalImageNodeStatus{Name: "app", Image: "apps/app@" + digest, Message: "failed to mirror quay.io/org/app@" + digest + ": manifest unknown"},
+		).obj,
+		// the node has not reported the current image of the catalog yet
+		mcn("master-1").additionalImagesStatus(
+			ctrlcommon.IRIAdditionalImageNodeStatus{Name: "catalog", Image: "olm/old-catalog@" + digest, Available: true},
+		).obj,
+		mcn("master-2").additionalImagesStatus(
+			ctrlcommon.IRIAdditionalImageNodeStatus{Name: "catalog", Image: "olm/catalog@" + digest, Available: true},
+		).obj,
+	}
+
+	aggregated := aggregateAdditionalImages(images, mcns, []string{"master-2"}, "api-int.example.com:22625")
+
+	assert.Equal(t, []ctrlcommon.IRIAdditionalImageStatus{
+		{
+			Name:           "catalog",
+			Image:          "api-int.example.com:22625/olm/catalog@" + digest,
+			AvailableNodes: []string{"master-0"},
+			UnavailableNodes: []ctrlcommon.IRIAdditionalImageUnavailableNode{
+				{Node: "master-1", Message: "Image availability not yet reported by the node"},
+				{Node: "master-2", Message: "Node is not ready"},
+			},
+		},
+		{
+			Name:  "app",
+			Image: "api-int.example.com:22625/apps/app@" + digest,
+			UnavailableNodes: []ctrlcommon.IRIAdditionalImageUnavailableNode{
+				{Node: "master-0", Message: "failed to mirror quay.io/org/app@" + digest + ": manifest unknown"},
+				{Node: "master-1", Message: "Image availability not yet reported by the node"},
+				{Node: "master-2", Message: "Node is not ready"},
+			},
+		},
+	}, aggregated)
+
+	assert.Empty(t, aggregateAdditionalImages(nil, mcns, nil, "api-int.example.com:22625"))
+
+	condition := additionalImagesCondition(aggregated, 1)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "AdditionalImagesNotAvailable", condition.Reason)
+	assert.Contains(t, condition.Message, "catalog (api-int.example.com:22625/olm/catalog@"+digest+") is not available on master-1: Image availability not yet reported by the node; master-2: Node is not ready")
+
+	aggregated = aggregateAdditionalImages(images[:1], mcns[:1], nil, "api-int.example.com:22625")
+	condition = additionalImagesCondition(aggregated, 1)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, "All the additional images are available: catalog (api-int.example.com:22625/olm/catalog@"+digest+")", condition.Message)
+}
+
 func TestTransformToAPIIntURL(t *testing.T) {
 	cases := []struct {
 		name          string
@@ -14,6 +14,7 @@ import (
 	templatectrl "github.com/openshift/machine-config-operator/pkg/controller/template"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -121,6 +122,11 @@ func (ib *iriBuilder) finalizer(f ...string) *iriBuilder {
 	return ib
 }
 
+func (ib *iriBuilder) additionalImages(value string) *iriBuilder {
+	ib.obj.SetAnnotations(map[string]string{ctrlcommon.InternalReleaseImageAdditionalImagesAnnotationKey: value})
+	return ib
+}
+
 func (ib *iriBuilder) setDeletionTimestamp() *iriBuilder {
 	now := v1.Now()
 	ib.obj.SetDeletionTimestamp(&now)
@@ -357,6 +363,16 @@ func (mb *machineConfigNodeBuilder) degraded() *machineConfigNodeBuilder {
 	return mb
 }
 
+func (mb *machineConfigNodeBuilder) additionalImagesStatus(statuses ...ctrlcommon.IRIAdditionalImageNodeStatus) *machineConfigNodeBuilder {
+	meta.SetStatusCondition(&mb.obj.Status.Conditions, v1.Condition{
+		Type:    ctrlcommon.MachineConfigNodeIRIAdditionalImagesDegraded,
+		Status:  v1.ConditionFalse,
+		Reason:  "AllAdditionalImagesAvailable",
+		Message: ctrlcommon.FormatIRIAdditionalImagesNodeStatus(statuses),
+	})
+	return mb
+}
+
 func (mb *machineConfigNodeBuilder) build() runtime.Object {
 	return mb.obj
 }
@@ -778,6 +778,7 @@ func (ctrl *Controller) syncGeneratedMachineConfig(ctx context.Context, pool *mc
 		if isOSImageURLOverridden {
 			ctrl.eventRecorder.Eventf(generated, corev1.EventTypeNormal, "OSImageURLOverridden", "OSImageURL was overridden via machineconfig in %s (was: %s is: %s)", generated.Name, cc.Spec.OSImageURL, generated.Spec.OSImageURL)
 		}
+		ctrl.warnInvalidSystemdUnits(configs)
 		klog.V(2).Infof("Generated machineconfig %s from %d configs: %s", generated.Name, len(source), source)
 		ctrl.eventRecorder.Eventf(pool, corev1.EventTypeNormal, "RenderedConfigGenerated", "%s successfully generated (release version: %s, controller version: %s)",
 			generated.Name, generated.Annotations[ctrlcommon.ReleaseImageVersionAnnotationKey], generated.Annotations[ctrlcommon.GeneratedByControllerVersionAnnotationKey])
@@ -809,6 +810,19 @@ func (ctrl *Controller) syncGeneratedMachineConfig(ctx context.Context, pool *mc
 	return ctrl.garbageCollectRenderedConfigs(pool)
 }
 
+// warnInvalidSystemdUnits reports the systemd units of the MachineConfigs with
+// unknown sections or directives, or which systemd would refuse to load. They
+// don't block rendering, as the known directives can't follow new systemd
+// releases and existing MachineConfigs must keep rendering after an upgrade.
+func (ctrl *Controller) warnInvalidSystemdUnits(configs []*mcfgv1.MachineConfig) {
+	for _, config := range configs {
+		if err := ctrlcommon.ValidateMachineConfigSystemdUnits(config.Spec); err != nil {
+			klog.Warningf("MachineConfig %s has invalid systemd units: %v", config.Name, err)
+			ctrl.eventRecorder.Eventf(config, corev1.EventTypeWarning, "InvalidSystemdUnits", "MachineConfig %s has invalid systemd units: %v", config.Name, err)
+		}
+	}
+}
+
 // generateRenderedMachineConfig takes all MCs for a given pool and returns a single rendered MC. For ex master-XXXX or worker-XXXX
 func generateRenderedMachineConfig(pool *mcfgv1.MachineConfigPool, configs []*mcfgv1.MachineConfig, cconfig *mcfgv1.ControllerConfig, osImageStreamSet *mcfgv1.OSImageStreamSet) (*mcfgv1.MachineConfig, error) {
 	// Suppress rendered config generation until a corresponding new controller can roll out too.
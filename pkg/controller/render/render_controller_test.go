@@ -369,6 +369,36 @@ func TestIgnValidationGenerateRenderedMachineConfig(t *testing.T) {
 
 }
 
+// Invalid systemd units are reported, but never block rendering, so that
+// existing MachineConfigs keep rendering after an upgrade.
+func TestInvalidSystemdUnitsDoNotBlockRendering(t *testing.T) {
+	mcp := helpers.NewMachineConfigPool("test-cluster-master", helpers.MasterSelector, nil, "")
+	mcs := []*mcfgv1.MachineConfig{
+		helpers.NewMachineConfig("00-test-cluster-master", map[string]string{"node-role/master": ""}, "dummy://", nil),
+		helpers.NewMachineConfig("05-extra-master", map[string]string{"node-role/master": ""}, "dummy://1", nil),
+	}
+	ignCfg := ctrlcommon.NewIgnConfig()
+	ignCfg.Systemd.Units = []ign3types.Unit{{
+		Name:     "foo.service",
+		Contents: helpers.StrToPtr("[Service]\nExecStart=/bin/true\nStartLimitIntervalSec=0\n"),
+	}}
+	rawIgnCfg, err := json.Marshal(ignCfg)
+	require.NoError(t, err)
+	mcs[1].Spec.Config.Raw = rawIgnCfg
+	cc := newControllerConfig(ctrlcommon.ControllerConfigName)
+
+	_, err = generateRenderedMachineConfig(mcp, mcs, cc, nil)
+	require.NoError(t, err)
+
+	recorder := record.NewFakeRecorder(10)
+	ctrl := &Controller{eventRecorder: recorder}
+	ctrl.warnInvalidSystemdUnits(mcs)
+	require.Len(t, recorder.Events, 1)
+	event := <-recorder.Events
+	assert.Contains(t, event, "InvalidSystemdUnits")
+	assert.Contains(t, event, `unknown directive "StartLimitIntervalSec" in section [Service]`)
+}
+
 func TestUpdatesGeneratedMachineConfig(t *testing.T) {
 	f := newFixture(t)
 	mcp := helpers.NewMachineConfigPool("test-cluster-master", helpers.MasterSelector, nil, "")
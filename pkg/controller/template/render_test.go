@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes/scheme"
 
 	configv1 "github.com/openshift/api/config/v1"
@@ -249,6 +250,24 @@ func TestInvalidPlatform(t *testing.T) {
 	expectErr(err, "failed to create MachineConfig for role master: platform _base unsupported")
 }
 
+// knownUnitWarnings are the shipped units which are known to produce
+// render-time warnings. Fixing them changes the rendered MachineConfigs, so
+// each one is fixed on its own.
+var knownUnitWarnings = sets.New(
+	// StartLimitIntervalSec is set in [Service] instead of [Unit]
+	"vsphere-hostname.service",
+)
+
+func unitsWithoutKnownWarnings(units []ign3types.Unit) []ign3types.Unit {
+	filtered := []ign3types.Unit{}
+	for _, u := range units {
+		if !knownUnitWarnings.Has(u.Name) {
+			filtered = append(filtered, u)
+		}
+	}
+	return filtered
+}
+
 func TestGenerateMachineConfigs(t *testing.T) {
 	for test, config := range configs {
 		controllerConfig, err := controllerConfigFromFile(config)
@@ -282,6 +301,13 @@ func TestGenerateMachineConfigs(t *testing.T) {
 			if err != nil {
 				t.Errorf("Failed to parse Ignition config for %s, %s, error: %v", config, cfg.Name, err)
 			}
+			if err := ctrlcommon.ValidateMachineConfig(cfg.Spec); err != nil {
+				t.Errorf("Invalid MachineConfig for %s, %s, error: %v", config, cfg.Name, err)
+			}
+			// The units shipped by the MCO are held to the render-time warnings
+			if err := ctrlcommon.ValidateSystemdUnits(unitsWithoutKnownWarnings(ign.Systemd.Units)); err != nil {
+				t.Errorf("Invalid systemd units for %s, %s, error: %v", config, cfg.Name, err)
+			}
 			if role == masterRole {
 				if !foundPullSecretMaster {
 					foundPullSecretMaster = findIgnFile(ign.Storage.Files, "/var/lib/kubelet/config.json", t)
@@ -0,0 +1,170 @@
+package daemon
+
+import (
+	"fmt"
+	"slices"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+// ConfigChangeActions are the actions the MCD takes to update a node from a
+// MachineConfig to another.
+type ConfigChangeActions struct {
+	// Actions are the node disruption actions of the whole update.
+	Actions []opv1.NodeDisruptionPolicyStatusAction
+	// LegacyActions are the actions taken when the node disruption policies
+	// cannot be used, e.g. on firstboot.
+	LegacyActions []string
+	// DrainRequired is true if the node is drained before applying the Actions.
+	DrainRequired bool
+	// RebootRequiredBy are the MachineConfig fields whose changes require a
+	// reboot, because they always do or because no policy covers them.
+	RebootRequiredBy []string
+	// KernelArgumentActions are the node disruption actions of the kernel
+	// arguments changes, empty if the kernel arguments did not change.
+	KernelArgumentActions []opv1.NodeDisruptionPolicyStatusAction
+	// LiveKernelArguments is true if the kernel arguments changes are applied
+	// without a reboot.
+	LiveKernelArguments bool
+	// ExtensionActions are the node disruption actions of the extensions
+	// changes, empty if the extensions did not change.
+	ExtensionActions []opv1.NodeDisruptionPolicyStatusAction
+	// UpperBound is true if no MachineConfiguration was given. The default
+	// node disruption policies are then used, kernel arguments are never
+	// applied live and there is no OS node disruption policy, so the node may
+	// be disrupted less than reported.
+	UpperBound bool
+	// FileActions are the node disruption actions of each changed file, by path.
+	FileActions map[string][]opv1.NodeDisruptionPolicyStatusAction
+	// UnitActions are the node disruption actions of each changed unit, by name.
+	UnitActions map[string][]opv1.NodeDisruptionPolicyStatusAction
+	// SSHKeyActions are the node disruption actions of the SSH keys changes,
+	// empty if the SSH keys did not change.
+	SSHKeyActions []opv1.NodeDisruptionPolicyStatusAction
+}
+
+// CalculateConfigChangeActions calculates the actions the MCD takes to update
+// a node from oldConfig to newConfig with the node disruption policies, live
+// kernel arguments and OS node disruption policy of the given
+// MachineConfiguration, the same way it does during an update. The live kernel
+// arguments and OS node disruption policy are only applied if the
+// OSNodeDisruptionPolicy feature gate is enabled in fgHandler, which may be
+// nil. The MachineConfiguration may be nil, in which case the actions are an
+// upper bound. The force file of the local host is ignored, so this can be
+// used offline.
+func CalculateConfigChangeActions(oldConfig, newConfig *mcfgv1.MachineConfig, mcop *opv1.MachineConfiguration, fgHandler ctrlcommon.FeatureGatesHandler) (*ConfigChangeActions, error) {
+	diff, err := diffMachineConfigs(oldConfig, newConfig, false)
+	if err != nil {
+		return nil, err
+	}
+	osPolicy := applyMachineConfigurationToDiff(diff, mcop, fgHandler)
+	clusterPolicies := getClusterPolicies(mcop)
+
+	oldIgnConfig, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing old Ignition config failed: %w", err)
+	}
+	newIgnConfig, err := ctrlcommon.ParseAndConvertConfig(newConfig.Spec.Config.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing new Ignition config failed: %w", err)
+	}
+
+	diffFileSet := ctrlcommon.CalculateConfigFileDiffs(&oldIgnConfig, &newIgnConfig)
+	unitDiff := ctrlcommon.GetChangedConfigUnitsByType(&oldIgnConfig, &newIgnConfig)
+	var diffUnitSet []string
+	for _, unit := range slices.Concat(unitDiff.Added, unitDiff.Updated, unitDiff.Removed) {
+		diffUnitSet = append(diffUnitSet, unit.Name)
+	}
+
+	actions := &ConfigChangeActions{
+		Actions:             calculatePostConfigChangeNodeDisruptionActionFromDiff(diff, diffFileSet, diffUnitSet, clusterPolicies, osPolicy),
+		LegacyActions:       calculatePostConfigChangeActionFromDiff(diff, diffFileSet),
+		FileActions:         map[string][]opv1.NodeDisruptionPolicyStatusAction{},
+		UnitActions:         map[string][]opv1.NodeDisruptionPolicyStatusAction{},
+		LiveKernelArguments: diff.kargs && diff.liveKargs,
+		UpperBound:          mcop == nil,
+	}
+	if actions.DrainRequired, err = isDrainRequiredForNodeDisruptionActions(actions.Actions, oldIgnConfig, newIgnConfig); err != nil {
+		return nil, err
+	}
+
+	// The OS actions of the kernel arguments and extensions are calculated
+	// separately, as if each was the only change
+	if diff.kargs {
+		actions.KernelArgumentActions = calculateOSNodeDisruptionActionsOf(&machineConfigDiff{
+			kargs:        true,
+			liveKargs:    diff.liveKargs,
+			changedKargs: diff.changedKargs,
+		}, osPolicy)
+	}
+	if diff.extensions {
+		actions.ExtensionActions = calculateOSNodeDisruptionActionsOf(&machineConfigDiff{
+			extensions:        true,
+			addedExtensions:   diff.addedExtensions,
+			removedExtensions: diff.removedExtensions,
+		}, osPolicy)
+	}
+
+	for field, changed := range map[string]bool{
+		"osImageURL":      diff.osUpdate,
+		"kernelArguments": isRebootAction(actions.KernelArgumentActions),
+		"fips":            diff.fips,
+		"kernelType":      diff.kernelType,
+		"extensions":      isRebootAction(actions.ExtensionActions),
+	} {
+		if changed {
+			actions.RebootRequiredBy = append(actions.RebootRequiredBy, field)
+		}
+	}
+	slices.Sort(actions.RebootRequiredBy)
+
+	// The actions of each change are calculated as if it was the only one
+	for _, path := range diffFileSet {
+		actions.FileActions[path] = calculatePostConfigChangeNodeDisruptionActionFromMCDiffs(false, []string{path}, nil, clusterPolicies)
+	}
+	for _, unit := range diffUnitSet {
+		actions.UnitActions[unit] = calculatePostConfigChangeNodeDisruptionActionFromMCDiffs(false, nil, []string{unit}, clusterPolicies)
+	}
+	if diff.passwd {
+		actions.SSHKeyActions = calculatePostConfigChangeNodeDisruptionActionFromMCDiffs(true, nil, nil, clusterPolicies)
+	}
+
+	return actions, nil
+}
+
+// getClusterPolicies returns the node disruption policies of the
+// MachineConfiguration, merged with the default ones. The status holds them
+// unless it was not populated yet. The MachineConfiguration may be nil.
+func getClusterPolicies(mcop *opv1.MachineConfiguration) opv1.NodeDisruptionPolicyClusterStatus {
+	if mcop == nil {
+		return apihelpers.MergeClusterPolicies(opv1.NodeDisruptionPolicyConfig{})
+	}
+	if mcop.Status.ObservedGeneration != 0 {
+		return mcop.Status.NodeDisruptionPolicyStatus.ClusterPolicies
+	}
+	return apihelpers.MergeClusterPolicies(mcop.Spec.NodeDisruptionPolicy)
+}
+
+// calculateOSNodeDisruptionActionsOf returns the OS node disruption actions of
+// a diff of kernel arguments or extensions only: a reboot if a change is not
+// covered by the policy, or none if the changes need no action.
+func calculateOSNodeDisruptionActionsOf(diff *machineConfigDiff, osPolicy *apihelpers.OSNodeDisruptionPolicy) []opv1.NodeDisruptionPolicyStatusAction {
+	actions, found := calculateOSNodeDisruptionActions(diff, osPolicy)
+	switch {
+	case !found:
+		return []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RebootStatusAction}}
+	case len(actions) == 0:
+		return []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.NoneStatusAction}}
+	default:
+		return actions
+	}
+}
+
+func isRebootAction(actions []opv1.NodeDisruptionPolicyStatusAction) bool {
+	return slices.ContainsFunc(actions, func(action opv1.NodeDisruptionPolicyStatusAction) bool {
+		return action.Type == opv1.RebootStatusAction
+	})
+}
@@ -0,0 +1,191 @@
+package daemon
+
+import (
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	configv1 "github.com/openshift/api/config/v1"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/test/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculateConfigChangeActions(t *testing.T) {
+	osPolicyEnabled := ctrlcommon.NewFeatureGatesHardcodedHandler([]configv1.FeatureGateName{apihelpers.OSNodeDisruptionPolicyFeatureGate}, nil)
+	osPolicyDisabled := ctrlcommon.NewFeatureGatesHardcodedHandler(nil, []configv1.FeatureGateName{apihelpers.OSNodeDisruptionPolicyFeatureGate})
+
+	mcop := &opv1.MachineConfiguration{
+		Spec: opv1.MachineConfigurationSpec{
+			NodeDisruptionPolicy: opv1.NodeDisruptionPolicyConfig{
+				Units: []opv1.NodeDisruptionPolicySpecUnit{{
+					Name:    "chronyd.service",
+					Actions: []opv1.NodeDisruptionPolicySpecAction{{Type: opv1.RestartSpecAction, Restart: &opv1.RestartService{ServiceName: "chronyd.service"}}},
+				}},
+			},
+		},
+	}
+
+	unit := func(contents string) ign3types.Unit {
+		return ign3types.Unit{Name: "chronyd.service", Contents: &contents}
+	}
+
+	oldConfig := helpers.NewMachineConfigExtended("00-test", nil, nil,
+		[]ign3types.File{
+			ctrlcommon.NewIgnFile("/var/lib/kubelet/config.json", "pull secret 1\n"),
+			ctrlcommon.NewIgnFile("/etc/containers/registries.conf", "registries 1\n"),
+		},
+		[]ign3types.Unit{unit("[Unit]\n")},
+		[]ign3types.SSHAuthorizedKey{"key1"}, nil, false, nil, "default", "dummy://")
+
+	t.Run("files, units and ssh keys", func(t *testing.T) {
+		newConfig := helpers.NewMachineConfigExtended("01-test", nil, nil,
+			[]ign3types.File{
+				ctrlcommon.NewIgnFile("/var/lib/kubelet/config.json", "pull secret 2\n"),
+				ctrlcommon.NewIgnFile("/etc/containers/registries.conf", "registries 1\n"),
+				ctrlcommon.NewIgnFile("/etc/random-file", "random\n"),
+			},
+			[]ign3types.Unit{unit("[Unit]\nDescription=chrony\n")},
+			[]ign3types.SSHAuthorizedKey{"key2"}, nil, false, nil, "default", "dummy://")
+
+		actions, err := CalculateConfigChangeActions(oldConfig, newConfig, mcop, osPolicyEnabled)
+		require.NoError(t, err)
+
+		// the unknown file requires a reboot, whatever the other changes
+		assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RebootStatusAction}}, actions.Actions)
+		assert.Equal(t, []string{postConfigChangeActionReboot}, actions.LegacyActions)
+		assert.True(t, actions.DrainRequired)
+		assert.Empty(t, actions.RebootRequiredBy)
+
+		assert.Equal(t, map[string][]opv1.NodeDisruptionPolicyStatusAction{
+			"/var/lib/kubelet/config.json": {{Type: opv1.NoneStatusAction}},
+			"/etc/random-file":             {{Type: opv1.RebootStatusAction}},
+		}, actions.FileActions)
+		assert.Equal(t, map[string][]opv1.NodeDisruptionPolicyStatusAction{
+			"chronyd.service": {{Type: opv1.RestartStatusAction, Restart: &opv1.RestartService{ServiceName: "chronyd.service"}}},
+		}, actions.UnitActions)
+		assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.NoneStatusAction}}, actions.SSHKeyActions)
+	})
+
+	t.Run("policies without reboot", func(t *testing.T) {
+		newConfig := helpers.NewMachineConfigExtended("01-test", nil, nil,
+			[]ign3types.File{
+				ctrlcommon.NewIgnFile("/var/lib/kubelet/config.json", "pull secret 2\n"),
+				ctrlcommon.NewIgnFile("/etc/containers/registries.conf", "registries 1\n"),
+			},
+			[]ign3types.Unit{unit("[Unit]\nDescription=chrony\n")},
+			[]ign3types.SSHAuthorizedKey{"key1"}, nil, false, nil, "default", "dummy://")
+
+		actions, err := CalculateConfigChangeActions(oldConfig, newConfig, mcop, osPolicyEnabled)
+		require.NoError(t, err)
+
+		assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{
+			{Type: opv1.RestartStatusAction, Restart: &opv1.RestartService{ServiceName: "chronyd.service"}},
+		}, actions.Actions)
+		// without policies, unit changes always require a reboot
+		assert.Equal(t, []string{postConfigChangeActionReboot}, actions.LegacyActions)
+		assert.False(t, actions.DrainRequired)
+		assert.Empty(t, actions.SSHKeyActions)
+	})
+
+	t.Run("os changes", func(t *testing.T) {
+		newConfig := helpers.NewMachineConfigExtended("01-test", nil, nil,
+			[]ign3types.File{
+				ctrlcommon.NewIgnFile("/var/lib/kubelet/config.json", "pull secret 1\n"),
+				ctrlcommon.NewIgnFile("/etc/containers/registries.conf", "registries 1\n"),
+			},
+			[]ign3types.Unit{unit("[Unit]\n")},
+			[]ign3types.SSHAuthorizedKey{"key1"}, []string{"usbguard"}, false, []string{"nosmt"}, "realtime", "dummy://new")
+
+		actions, err := CalculateConfigChangeActions(oldConfig, newConfig, mcop, osPolicyEnabled)
+		require.NoError(t, err)
+
+		assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RebootStatusAction}}, actions.Actions)
+		assert.Equal(t, []string{"extensions", "kernelArguments", "kernelType", "osImageURL"}, actions.RebootRequiredBy)
+		assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RebootStatusAction}}, actions.KernelArgumentActions)
+		assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RebootStatusAction}}, actions.ExtensionActions)
+		assert.Empty(t, actions.FileActions)
+		assert.Empty(t, actions.UnitActions)
+		assert.False(t, actions.UpperBound)
+	})
+
+	kargsConfig := func(kargs ...string) *mcfgv1.MachineConfig {
+		return helpers.NewMachineConfigExtended("01-test", nil, nil,
+			[]ign3types.File{
+				ctrlcommon.NewIgnFile("/var/lib/kubelet/config.json", "pull secret 1\n"),
+				ctrlcommon.NewIgnFile("/etc/containers/registries.conf", "registries 1\n"),
+			},
+			[]ign3types.Unit{unit("[Unit]\n")},
+			[]ign3types.SSHAuthorizedKey{"key1"}, nil, false, kargs, "default", "dummy://")
+	}
+
+	t.Run("live kernel arguments", func(t *testing.T) {
+		liveMcop := mcop.DeepCopy()
+		liveMcop.Annotations = map[string]string{constants.LiveApplyKernelArgumentsAnnotationKey: "true"}
+
+		actions, err := CalculateConfigChangeActions(oldConfig, kargsConfig("nosmt"), liveMcop, osPolicyEnabled)
+		require.NoError(t, err)
+
+		assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.NoneStatusAction}}, actions.Actions)
+		assert.True(t, actions.LiveKernelArguments)
+		assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.NoneStatusAction}}, actions.KernelArgumentActions)
+		assert.Empty(t, actions.RebootRequiredBy)
+
+		// the same change requires a reboot if the cluster did not opt in
+		actions, err = CalculateConfigChangeActions(oldConfig, kargsConfig("nosmt"), mcop, osPolicyEnabled)
+		require.NoError(t, err)
+
+		assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RebootStatusAction}}, actions.Actions)
+		assert.False(t, actions.LiveKernelArguments)
+		assert.Equal(t, []string{"kernelArguments"}, actions.RebootRequiredBy)
+	})
+
+	t.Run("os node disruption policy", func(t *testing.T) {
+		policyMcop := mcop.DeepCopy()
+		policyMcop.Annotations = map[string]string{
+			constants.OSNodeDisruptionPolicyAnnotationKey: `{"kernelArguments": [{"name": "debug", "actions": [{"type": "Restart", "restart": {"serviceName": "crio.service"}}]}]}`,
+		}
+
+		actions, err := CalculateConfigChangeActions(oldConfig, kargsConfig("debug"), policyMcop, osPolicyEnabled)
+		require.NoError(t, err)
+
+		restart := []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RestartStatusAction, Restart: &opv1.RestartService{ServiceName: "crio.service"}}}
+		assert.Equal(t, restart, actions.Actions)
+		assert.Equal(t, restart, actions.KernelArgumentActions)
+		assert.Empty(t, actions.RebootRequiredBy)
+	})
+
+	t.Run("feature gate disabled", func(t *testing.T) {
+		// the annotations are ignored, so kernel argument changes reboot
+		for _, annotations := range []map[string]string{
+			{constants.LiveApplyKernelArgumentsAnnotationKey: "true"},
+			{constants.OSNodeDisruptionPolicyAnnotationKey: `{"kernelArguments": [{"name": "nosmt", "actions": [{"type": "None"}]}]}`},
+		} {
+			gatedMcop := mcop.DeepCopy()
+			gatedMcop.Annotations = annotations
+
+			actions, err := CalculateConfigChangeActions(oldConfig, kargsConfig("nosmt"), gatedMcop, osPolicyDisabled)
+			require.NoError(t, err)
+
+			assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RebootStatusAction}}, actions.Actions)
+			assert.False(t, actions.LiveKernelArguments)
+			assert.Equal(t, []string{"kernelArguments"}, actions.RebootRequiredBy)
+
+			actions, err = CalculateConfigChangeActions(oldConfig, kargsConfig("nosmt"), gatedMcop, nil)
+			require.NoError(t, err)
+			assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RebootStatusAction}}, actions.Actions)
+		}
+	})
+
+	t.Run("without MachineConfiguration", func(t *testing.T) {
+		actions, err := CalculateConfigChangeActions(oldConfig, kargsConfig("nosmt"), nil, osPolicyEnabled)
+		require.NoError(t, err)
+
+		assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RebootStatusAction}}, actions.Actions)
+		assert.True(t, actions.UpperBound)
+	})
+}
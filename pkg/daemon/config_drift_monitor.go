@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/user"
 	"path/filepath"
 	"sync"
 
@@ -31,6 +32,10 @@ type unitConfigDriftErr struct {
 	error
 }
 
+// The passwd databases watched when users or groups other than core are
+// managed by the MCD
+var passwdDatabasePaths = []string{"/etc/passwd", "/etc/group", "/etc/shadow", "/etc/gshadow"}
+
 type ConfigDriftMonitor interface {
 	Start(ConfigDriftMonitorOpts) error
 	Done() <-chan struct{}
@@ -318,6 +323,19 @@ func getFilePathsFromIgn3Config(ignConfig ign3types.Config, systemdPath string)
 		}
 	}
 
+	// Directories and links are watched through their parent directories,
+	// just like files; links must not be followed
+	for _, ignDir := range ignConfig.Storage.Directories {
+		if _, err := os.Lstat(ignDir.Path); err == nil {
+			files.Insert(ignDir.Path)
+		}
+	}
+	for _, ignLink := range ignConfig.Storage.Links {
+		if _, err := os.Lstat(ignLink.Path); err == nil {
+			files.Insert(ignLink.Path)
+		}
+	}
+
 	// Get all the file paths for systemd dropins from the ignition config
 	for _, unit := range ignConfig.Systemd.Units {
 		unitPath := getIgn3SystemdUnitPath(systemdPath, unit)
@@ -333,6 +351,31 @@ func getFilePathsFromIgn3Config(ignConfig ign3types.Config, systemdPath string)
 		}
 	}
 
+	// Changes to the users and groups managed by the MCD show up in the
+	// passwd databases, and in the SSH keys files of the users
+	if hasManagedPasswd(ignConfig.Passwd) {
+		for _, path := range passwdDatabasePaths {
+			if _, err := os.Stat(path); err == nil {
+				files.Insert(path)
+			}
+		}
+		for _, u := range ignConfig.Passwd.Users {
+			if !isManagedUser(u) || len(u.SSHAuthorizedKeys) == 0 {
+				continue
+			}
+			osUser, err := user.Lookup(u.Name)
+			if err != nil {
+				continue
+			}
+			for _, useNewSSHKeyPath := range []bool{true, false} {
+				keyPath := userSSHKeyPath(osUser.HomeDir, useNewSSHKeyPath)
+				if _, err := os.Stat(keyPath); err == nil {
+					files.Insert(keyPath)
+				}
+			}
+		}
+	}
+
 	return files
 }
 
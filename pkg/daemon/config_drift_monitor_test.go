@@ -53,6 +53,17 @@ func TestConfigDriftMonitor(t *testing.T) {
 		return os.Chmod(path, 0755)
 	}
 
+	chmodDir := func(path string) error {
+		return os.Chmod(path, 0700)
+	}
+
+	retargetLink := func(path string) error {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		return os.Symlink("another-file", path)
+	}
+
 	// The general idea for this test is as follows:
 	// 1. We create a temporary directory.
 	// 2. For each test case, we create an Ignition config as usual.
@@ -197,6 +208,46 @@ func TestConfigDriftMonitor(t *testing.T) {
 			expectedErr:  unitErr,
 			mutateDropin: chmodFile,
 		},
+		// Ignition Directory
+		// These target the directory called /etc/a-config-dir defined by the
+		// test fixture.
+		{
+			name:            "ign directory touch",
+			mutateDirectory: touchFile,
+		},
+		{
+			name:            "ign directory rename",
+			expectedErr:     fileErr,
+			mutateDirectory: renameFile,
+		},
+		{
+			name:            "ign directory delete",
+			expectedErr:     fileErr,
+			mutateDirectory: os.Remove,
+		},
+		{
+			name:            "ign directory chmod",
+			expectedErr:     fileErr,
+			mutateDirectory: chmodDir,
+		},
+		// Ignition Link
+		// These target the link called /etc/a-config-link defined by the test
+		// fixture.
+		{
+			name:        "ign link retarget",
+			expectedErr: fileErr,
+			mutateLink:  retargetLink,
+		},
+		{
+			name:        "ign link delete",
+			expectedErr: fileErr,
+			mutateLink:  os.Remove,
+		},
+		{
+			name:        "ign link overwrite",
+			expectedErr: fileErr,
+			mutateLink:  overwriteFile,
+		},
 	}
 
 	// Create a mutex for our test cases The mutex is needed because we now
@@ -245,6 +296,10 @@ type configDriftMonitorTestCase struct {
 	mutateUnit func(string) error
 	// The mutation to apply to the systemd dropin file
 	mutateDropin func(string) error
+	// The mutation to apply to the Ignition directory
+	mutateDirectory func(string) error
+	// The mutation to apply to the Ignition link
+	mutateLink func(string) error
 	// Mutex to ensure that parallel tests do not stomp on one another
 	testMutex *sync.Mutex
 }
@@ -389,6 +444,26 @@ func (tc configDriftMonitorTestCase) getIgnConfig(t *testing.T) ign3types.Config
 				setDefaultUIDandGID(helpers.CreateEncodedIgn3File("/etc/a-config-file", "thefilecontents", int(defaultFilePermissions))),
 				setDefaultUIDandGID(compressedFile),
 			},
+			Directories: []ign3types.Directory{
+				{
+					Node: ign3types.Node{
+						Path:  "/etc/a-config-dir",
+						User:  ign3types.NodeUser{ID: helpers.IntToPtr(-1)},
+						Group: ign3types.NodeGroup{ID: helpers.IntToPtr(-1)},
+					},
+					DirectoryEmbedded1: ign3types.DirectoryEmbedded1{Mode: helpers.IntToPtr(int(defaultDirectoryPermissions))},
+				},
+			},
+			Links: []ign3types.Link{
+				{
+					Node: ign3types.Node{
+						Path:  "/etc/a-config-link",
+						User:  ign3types.NodeUser{ID: helpers.IntToPtr(-1)},
+						Group: ign3types.NodeGroup{ID: helpers.IntToPtr(-1)},
+					},
+					LinkEmbedded1: ign3types.LinkEmbedded1{Target: helpers.StrToPtr("a-config-file")},
+				},
+			},
 		},
 		Systemd: ign3types.Systemd{
 			Units: []ign3types.Unit{
@@ -427,6 +502,14 @@ func (tc configDriftMonitorTestCase) mutate(ignConfig ign3types.Config) error {
 		return tc.mutateCompressedFile(ignConfig.Storage.Files[1].Path)
 	}
 
+	if tc.mutateDirectory != nil {
+		return tc.mutateDirectory(ignConfig.Storage.Directories[0].Path)
+	}
+
+	if tc.mutateLink != nil {
+		return tc.mutateLink(ignConfig.Storage.Links[0].Path)
+	}
+
 	if tc.mutateDropin != nil {
 		dropinPath := getIgn3SystemdDropinPath(tc.systemdPath, ignConfig.Systemd.Units[0], ignConfig.Systemd.Units[0].Dropins[0])
 		return tc.mutateDropin(dropinPath)
@@ -452,6 +535,12 @@ func (tc configDriftMonitorTestCase) getFixtures(t *testing.T) (ign3types.Config
 		file.Path = filepath.Join(tc.tmpDir, file.Path)
 		ignConfig.Storage.Files[i] = file
 	}
+	for i := range ignConfig.Storage.Directories {
+		ignConfig.Storage.Directories[i].Path = filepath.Join(tc.tmpDir, ignConfig.Storage.Directories[i].Path)
+	}
+	for i := range ignConfig.Storage.Links {
+		ignConfig.Storage.Links[i].Path = filepath.Join(tc.tmpDir, ignConfig.Storage.Links[i].Path)
+	}
 
 	// Separate the disk write process so that we can be sure that the deferred
 	// functions are run even when we encounter an error.
@@ -492,10 +581,18 @@ func (tc *configDriftMonitorTestCase) writeIgnitionConfig(t *testing.T, ignConfi
 	// Write files the same way the MCD does.
 	// NOTE: We manually handle the errors here because using require.Nil or
 	// require.NoError will skip the deferred functions, which is undesirable.
-	if err := writeFiles(ignConfig.Storage.Files, true); err != nil {
+	if err := writeDirectories(ignConfig.Storage.Directories); err != nil {
+		return fmt.Errorf("could not write ignition config directories: %w", err)
+	}
+
+	if err := writeFiles(ignConfig.Storage.Files, true, false); err != nil {
 		return fmt.Errorf("could not write ignition config files: %w", err)
 	}
 
+	if err := writeLinks(ignConfig.Storage.Links); err != nil {
+		return fmt.Errorf("could not write ignition config links: %w", err)
+	}
+
 	// Write systemd units the same way the MCD does.
 	if err := writeUnits(ignConfig.Systemd.Units, tc.systemdPath, true); err != nil {
 		return fmt.Errorf("could not write systemd units: %w", err)
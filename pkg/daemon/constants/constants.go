@@ -12,6 +12,8 @@ const (
 	CurrentImageAnnotationKey = "machineconfiguration.openshift.io/currentImage"
 	// DesiredImageAnnotationKey is used to specify the desired OS image pullspec for a machine
 	DesiredImageAnnotationKey = "machineconfiguration.openshift.io/desiredImage"
+	// DesiredArchImageAnnotationKey is used to specify the image for a machine's architecture within a multi-arch desired image
+	DesiredArchImageAnnotationKey = "machineconfiguration.openshift.io/desiredArchImage"
 
 	// CurrentMachineConfigAnnotationKey is used to fetch current MachineConfig for a machine
 	CurrentMachineConfigAnnotationKey = "machineconfiguration.openshift.io/currentConfig"
@@ -91,6 +93,10 @@ const (
 	CoreUserName  = "core"
 	CoreGroupName = "core"
 
+	// WheelGroupName is the administrative group of the OS. It can't be
+	// managed, but users other than core may join it as a supplementary group.
+	WheelGroupName = "wheel"
+
 	// changes to registries.conf will cause a crio reload and require extra logic about whether to drain
 	ContainerRegistryConfPath = "/etc/containers/registries.conf"
 
@@ -141,6 +147,50 @@ const (
 	// available on the root filesystem after prefetching images.
 	MinFreeStorageAfterPrefetch = "16Gi"
 
+	// PinnedImagePeerDistributionAnnotationKey is set to "true" on a MachineConfigPool to opt the nodes within it
+	// into fetching pinned images from one another before falling back to the upstream registry.
+	PinnedImagePeerDistributionAnnotationKey = "machineconfiguration.openshift.io/pinned-image-peer-distribution"
+
+	// LiveApplyKernelArgumentsAnnotationKey is set to "true" on the cluster MachineConfiguration to apply kernel
+	// argument changes with runtime equivalents immediately, without a reboot. The arguments are still staged for the
+	// next boot. It is TechPreview and ignored unless the OSNodeDisruptionPolicy feature gate is enabled.
+	LiveApplyKernelArgumentsAnnotationKey = "machineconfiguration.openshift.io/live-apply-kernel-arguments"
+
+	// OSNodeDisruptionPolicyAnnotationKey is set on the cluster MachineConfiguration to the node disruption policies,
+	// as JSON, of kernel argument and extension changes, which otherwise always require a reboot. It is TechPreview
+	// and ignored unless the OSNodeDisruptionPolicy feature gate is enabled.
+	OSNodeDisruptionPolicyAnnotationKey = "machineconfiguration.openshift.io/os-node-disruption-policy"
+
+	// PinnedImagePeerCacheDir holds the OCI layout of pinned images which the daemon serves to its peers.
+	PinnedImagePeerCacheDir = "/var/lib/machine-config-daemon/pinned-image-peer-cache"
+
+	// PinnedImageMaxPullsPerNodeAnnotationKey is set on a MachineConfigPool to limit the number of pinned images
+	// each node within the pool pulls at the same time.
+	PinnedImageMaxPullsPerNodeAnnotationKey = "machineconfiguration.openshift.io/pinned-image-max-pulls-per-node"
+
+	// PinnedImageMaxPullsPerPoolAnnotationKey is set on a MachineConfigPool to limit the number of pinned images
+	// pulled at the same time across all nodes within the pool.
+	PinnedImageMaxPullsPerPoolAnnotationKey = "machineconfiguration.openshift.io/pinned-image-max-pulls-per-pool"
+
+	// PinnedImagePrefetchWindowAnnotationKey is set on a MachineConfigPool to only pull pinned images within a daily
+	// UTC time window, e.g. "22:00-06:00".
+	PinnedImagePrefetchWindowAnnotationKey = "machineconfiguration.openshift.io/pinned-image-prefetch-window"
+
+	// PinnedImageGCPolicyAnnotationKey is set on a MachineConfigPool to control whether images which are no longer
+	// pinned are removed from its nodes. One of "Never" (the default) or "RemoveUnused".
+	PinnedImageGCPolicyAnnotationKey = "machineconfiguration.openshift.io/pinned-image-gc-policy"
+
+	// PinnedImageGCGracePeriodAnnotationKey is set on a MachineConfigPool to keep images for a duration, e.g. "24h",
+	// after they are unpinned before removing them.
+	PinnedImageGCGracePeriodAnnotationKey = "machineconfiguration.openshift.io/pinned-image-gc-grace-period"
+
+	// PinnedImageGCStateFile tracks the images which were unpinned on the node and are waiting to be removed.
+	PinnedImageGCStateFile = "/var/lib/machine-config-daemon/pinned-image-gc-state.json"
+
+	// FastRebootStateFile records the deployment a soft reboot or kexec boots into, so that the daemon can check it
+	// was booted on its next start.
+	FastRebootStateFile = "/var/lib/machine-config-daemon/fast-reboot.json"
+
 	// GPGNoRebootPath is the path MCO expects will contain GPG key updates. MCO will attempt to only reload crio for
 	// changes to this path. Note that other files added to the parent directory will not be handled specially
 	GPGNoRebootPath = "/etc/machine-config-daemon/no-reboot/containers-gpg.pub"
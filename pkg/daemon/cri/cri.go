@@ -32,14 +32,16 @@ func NewClient(ctx context.Context, target string) (*Client, error) {
 		return nil, err
 	}
 	return &Client{
-		conn:  conn,
-		image: runtimeapi.NewImageServiceClient(conn),
+		conn:    conn,
+		image:   runtimeapi.NewImageServiceClient(conn),
+		runtime: runtimeapi.NewRuntimeServiceClient(conn),
 	}, nil
 }
 
 type Client struct {
-	conn  *grpc.ClientConn
-	image runtimeapi.ImageServiceClient
+	conn    *grpc.ClientConn
+	image   runtimeapi.ImageServiceClient
+	runtime runtimeapi.RuntimeServiceClient
 }
 
 // PullImage pulls the image from the container runtime. The auth parameter can
@@ -108,6 +110,18 @@ func (c *Client) ListImages(ctx context.Context, filter string) ([]*runtimeapi.I
 	return resp.Images, nil
 }
 
+// ListContainers returns the containers matching the filter. The filter can
+// be nil to list all containers.
+func (c *Client) ListContainers(ctx context.Context, filter *runtimeapi.ContainerFilter) ([]*runtimeapi.Container, error) {
+	resp, err := c.runtime.ListContainers(ctx, &runtimeapi.ListContainersRequest{
+		Filter: filter,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Containers, nil
+}
+
 // ImageFsInfo returns information about the filesystem that is used to store images.
 func (c *Client) ImageFsInfo(ctx context.Context) (*runtimeapi.ImageFsInfoResponse, error) {
 	return c.image.ImageFsInfo(ctx, &runtimeapi.ImageFsInfoRequest{})
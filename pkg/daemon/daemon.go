@@ -20,6 +20,7 @@ import (
 	"time"
 
 	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	"github.com/distribution/reference"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/renameio"
 	"github.com/openshift/api/features"
@@ -206,6 +207,10 @@ const (
 	// against annotation changes.
 	currentImagePath = "/etc/machine-config-daemon/currentimage"
 
+	// appendedKargsPath is where we record the Ignition shouldExist kernel
+	// arguments the MCD appended, as opposed to the ones the base OS ships.
+	appendedKargsPath = "/etc/machine-config-daemon/appended-kargs"
+
 	// originalContainerBin is the path at which we've stashed the MCD container's /usr/bin
 	// in the host namespace.  We use this for executing any extra binaries we have in our
 	// container image.
@@ -266,14 +271,15 @@ var (
 // pods when `GracefulNodeShutdown` feature gate is enabled.
 // kubelet uses systemd inhibitor locks to delay node shutdown to terminate pods.
 // https://kubernetes.io/docs/concepts/architecture/nodes/#graceful-node-shutdown
-func rebootCommand(rationale string, workaroundOCPBUGS51150 bool) *exec.Cmd {
+// The systemctl verb is "reboot", or "soft-reboot" or "kexec" once prepared.
+func rebootCommand(rationale, verb string, workaroundOCPBUGS51150 bool) *exec.Cmd {
 	systemdRunArgs := []string{"--unit", "machine-config-daemon-reboot",
 		"--description", fmt.Sprintf("machine-config-daemon: %s", rationale)}
 	// we need this until we have https://github.com/ostreedev/ostree/pull/3389
 	if workaroundOCPBUGS51150 {
 		systemdRunArgs = append(systemdRunArgs, "-p", "Requires=ostree-finalize-staged.service", "-p", "After=ostree-finalize-staged.service")
 	}
-	systemdRunArgs = append(systemdRunArgs, "/bin/sh", "-c", "systemctl reboot")
+	systemdRunArgs = append(systemdRunArgs, "/bin/sh", "-c", "systemctl "+verb)
 	return exec.Command("systemd-run", systemdRunArgs...)
 }
 
@@ -1243,7 +1249,7 @@ func (dn *Daemon) RunFirstbootCompleteMachineconfig(machineConfigFile string) er
 		if err := dn.InplaceUpdateViaNewContainer(mc.Spec.OSImageURL); err != nil {
 			return err
 		}
-		rebootCmd := rebootCommand("extra reboot for in-place update", dn.os.IsCoreOSVariant())
+		rebootCmd := rebootCommand("extra reboot for in-place update", "reboot", dn.os.IsCoreOSVariant())
 		if err := rebootCmd.Run(); err != nil {
 			logSystem("failed to run reboot: %v", err)
 			return err
@@ -2196,6 +2202,12 @@ func (dn *Daemon) checkStateOnFirstRun() error {
 		return fmt.Errorf("failed to remove rollback: %w", err)
 	}
 
+	// A soft reboot or kexec which booted the wrong deployment is completed
+	// with a full reboot
+	if rebooting, err := dn.verifyFastReboot(); err != nil || rebooting {
+		return err
+	}
+
 	// Bootstrapping state is when we have the node annotations file
 	if state.bootstrapping {
 		// During bootstrap, prefer the image from node annotations (if set) over the MC from cluster lister
@@ -2356,6 +2368,17 @@ func (dn *Daemon) updateConfigAndState(state *stateAndConfigs) (bool, bool, erro
 			}
 		}
 
+		// Report units systemd did not pick up as configured
+		dn.reportUnitStates(state.currentConfig)
+
+		// Kernel argument changes applied without a reboot are only booted
+		// with the next reboot
+		if dn.os.IsCoreOSVariant() {
+			if err := dn.reportPendingKernelArgs(state.currentConfig); err != nil {
+				klog.Warningf("Failed to report pending kernel arguments: %v", err)
+			}
+		}
+
 		// Get MCP associated with node
 		pool, err := helpers.GetPrimaryPoolNameForMCN(dn.mcpLister, dn.node)
 		if err != nil {
@@ -2459,7 +2482,7 @@ func (dn *Daemon) runOnceFromMachineConfig(machineConfig mcfgv1.MachineConfig, c
 // runOnceFromIgnition executes MCD's subset of Ignition functionality in onceFrom mode
 func (dn *Daemon) runOnceFromIgnition(ignConfig ign3types.Config) error {
 	// Execute update without hitting the cluster
-	if err := dn.writeFiles(ignConfig.Storage.Files, false); err != nil {
+	if err := dn.writeFiles(ignConfig.Storage.Files, false, false); err != nil {
 		return err
 	}
 	if err := dn.writeUnits(ignConfig.Systemd.Units); err != nil {
@@ -2685,8 +2708,14 @@ func (dn *Daemon) getMachineConfigFromClusterIfNotSet(mc *mcfgv1.MachineConfig,
 }
 
 // validateKernelArguments checks that the current boot has all arguments specified
-// in the target machineconfig.
+// in the target machineconfig, and none of the arguments which its Ignition
+// config lists as shouldNotExist.
 func (dn *CoreOSDaemon) validateKernelArguments(currentConfig *mcfgv1.MachineConfig) error {
+	ignConfig, err := ctrlcommon.ParseAndConvertConfig(currentConfig.Spec.Config.Raw)
+	if err != nil {
+		return fmt.Errorf("parsing Ignition config failed with error: %w", err)
+	}
+
 	rpmostreeKargsBytes, err := dn.cmdRunner.RunGetOut("rpm-ostree", "kargs")
 	if err != nil {
 		return err
@@ -2698,13 +2727,22 @@ func (dn *CoreOSDaemon) validateKernelArguments(currentConfig *mcfgv1.MachineCon
 		foundArgs[arg] = true
 	}
 	expected := parseKernelArguments(currentConfig.Spec.KernelArguments)
+	for _, karg := range ignConfig.KernelArguments.ShouldExist {
+		expected = append(expected, string(karg))
+	}
 	missing := []string{}
 	for _, karg := range expected {
 		if _, ok := foundArgs[karg]; !ok {
 			missing = append(missing, karg)
 		}
 	}
-	if len(missing) > 0 {
+	unexpected := []string{}
+	for _, karg := range ignConfig.KernelArguments.ShouldNotExist {
+		if _, ok := foundArgs[string(karg)]; ok {
+			unexpected = append(unexpected, string(karg))
+		}
+	}
+	if len(missing) > 0 || len(unexpected) > 0 {
 		cmdlinebytes, err := os.ReadFile(CmdLineFile)
 		if err != nil {
 			klog.Warningf("Failed to read %s: %v", CmdLineFile, err)
@@ -2713,7 +2751,13 @@ func (dn *CoreOSDaemon) validateKernelArguments(currentConfig *mcfgv1.MachineCon
 		}
 		klog.Infof("Current ostree kargs: %s", rpmostreeKargs)
 		klog.Infof("Expected MachineConfig kargs: %v", expected)
-		return fmt.Errorf("missing expected kernel arguments: %v", missing)
+		if len(unexpected) > 0 {
+			klog.Infof("Unexpected MachineConfig kargs: %v", ignConfig.KernelArguments.ShouldNotExist)
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("missing expected kernel arguments: %v", missing)
+		}
+		return fmt.Errorf("found unexpected kernel arguments: %v", unexpected)
 	}
 	return nil
 }
@@ -2806,7 +2850,54 @@ func (dn *Daemon) checkOS(osImageURL string) bool {
 		klog.Warningf("osImageURL %q is not a digest; using a digest is recommended", osImageURL)
 	}
 
-	return dn.bootedOSImageURL == osImageURL
+	return dn.isBootedIntoImage(osImageURL)
+}
+
+// isBootedIntoImage determines whether the booted OS image is the given image.
+// A multi-arch image is applied using the image for this node's architecture
+// (see updateLayeredOS), so booting into that image is also considered a match.
+// The image for this node's architecture is recorded by the controller, so no
+// registry lookup is needed.
+func (dn *Daemon) isBootedIntoImage(osImageURL string) bool {
+	if dn.bootedOSImageURL == osImageURL {
+		return true
+	}
+
+	archURL := dn.getDesiredArchImage(osImageURL)
+	return archURL != "" && dn.bootedOSImageURL == archURL
+}
+
+// getDesiredArchImage returns the image for this node's architecture within
+// the given multi-arch image, as recorded on the node by the controller.
+// Returns an empty string if the given image is not the desired image or no
+// image was recorded for it.
+func (dn *Daemon) getDesiredArchImage(osImageURL string) string {
+	if dn.node == nil || osImageURL == "" || dn.node.Annotations[constants.DesiredImageAnnotationKey] != osImageURL {
+		return ""
+	}
+
+	archURL := dn.node.Annotations[constants.DesiredArchImageAnnotationKey]
+	if !isSameImageRepository(archURL, osImageURL) {
+		return ""
+	}
+
+	return archURL
+}
+
+// isSameImageRepository determines whether both image pullspecs refer to the
+// same repository
+func isSameImageRepository(a, b string) bool {
+	namedA, err := reference.ParseNamed(a)
+	if err != nil {
+		return false
+	}
+
+	namedB, err := reference.ParseNamed(b)
+	if err != nil {
+		return false
+	}
+
+	return namedA.Name() == namedB.Name()
 }
 
 // Close closes all the connections the node agent has open for it's lifetime
@@ -0,0 +1,203 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	rpmostreeclient "github.com/coreos/rpmostree-client-go/pkg/client"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+)
+
+// fastRebootTimeout is how long the daemon waits for a queued soft reboot or
+// kexec to stop it before falling back to a full reboot.
+var fastRebootTimeout = 10 * time.Minute
+
+// fastRebootPlan is a soft reboot or kexec into the staged deployment.
+type fastRebootPlan struct {
+	method apihelpers.RebootMethod
+	// deploymentRoot is the path of the staged deployment, relative to the sysroot
+	deploymentRoot string
+	kernelVersion  string
+}
+
+// fastRebootState is written to the FastRebootStateFile before a soft reboot
+// or kexec, so that the daemon fully reboots if it starts in another
+// deployment.
+type fastRebootState struct {
+	Method         apihelpers.RebootMethod `json:"method"`
+	DeploymentRoot string                  `json:"deploymentRoot"`
+}
+
+// deploymentRoot returns the path of an ostree deployment.
+func deploymentRoot(deployment *rpmostreeclient.Deployment) string {
+	return filepath.Join("/ostree/deploy", deployment.OSName, "deploy", fmt.Sprintf("%s.%d", deployment.Checksum, deployment.Serial))
+}
+
+// deploymentKernelVersion returns the version of the kernel shipped in a
+// deployment, i.e. the modules directory holding a vmlinuz.
+func deploymentKernelVersion(root string) (string, error) {
+	modulesDir := filepath.Join(root, "usr/lib/modules")
+	entries, err := os.ReadDir(modulesDir)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(modulesDir, entry.Name(), "vmlinuz")); err == nil {
+			return entry.Name(), nil
+		}
+	}
+	return "", fmt.Errorf("no kernel found in %s", modulesDir)
+}
+
+// selectRebootMethod returns the fastest allowed method to boot into a staged
+// deployment, or "" for a full reboot. A soft reboot keeps the running kernel,
+// so it requires the kernel and kernel arguments to be unchanged.
+func selectRebootMethod(diff *machineConfigDiff, allowed []apihelpers.RebootMethod, bootedKernel, stagedKernel string) apihelpers.RebootMethod {
+	// FIPS mode is set up by the initramfs and firmware, always fully reboot
+	if diff.fips || bootedKernel == "" || stagedKernel == "" {
+		return ""
+	}
+	if !diff.kargs && !diff.kernelType && bootedKernel == stagedKernel && slices.Contains(allowed, apihelpers.SoftRebootMethod) {
+		return apihelpers.SoftRebootMethod
+	}
+	if slices.Contains(allowed, apihelpers.KexecRebootMethod) {
+		return apihelpers.KexecRebootMethod
+	}
+	return ""
+}
+
+// planFastReboot returns how to boot into the deployment staged by the update,
+// or nil if the node has to be fully rebooted.
+func (dn *Daemon) planFastReboot(diff *machineConfigDiff) *fastRebootPlan {
+	if len(diff.rebootMethods) == 0 || dn.NodeUpdaterClient == nil {
+		return nil
+	}
+	_, staged, err := dn.NodeUpdaterClient.GetBootedAndStagedDeployment()
+	if err != nil || staged == nil {
+		klog.Infof("No staged deployment to soft reboot or kexec into (error: %v), a full reboot is required", err)
+		return nil
+	}
+	root := deploymentRoot(staged)
+	stagedKernel, err := deploymentKernelVersion(root)
+	if err != nil {
+		klog.Warningf("Failed to find the kernel of the staged deployment: %v", err)
+		return nil
+	}
+	bootedKernel, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		klog.Warningf("Failed to find the booted kernel: %v", err)
+		return nil
+	}
+
+	method := selectRebootMethod(diff, diff.rebootMethods, strings.TrimSpace(string(bootedKernel)), stagedKernel)
+	if method == "" {
+		return nil
+	}
+	return &fastRebootPlan{method: method, deploymentRoot: root, kernelVersion: stagedKernel}
+}
+
+// kexecCommandLine returns the kernel command line booting into a deployment,
+// from the kernel arguments of the staged deployment. The bootloader entries
+// point ostree= to the /ostree/boot.N symlink of the deployment, which only
+// exists once the staged deployment is finalized on shutdown. The deployment
+// directory is passed instead, which ostree-prepare-root accepts the same way
+// as it resolves the symlink to it. If another deployment is booted anyway,
+// verifyFastReboot falls back to a full reboot.
+func kexecCommandLine(kargs, root string) string {
+	args := []string{}
+	for _, arg := range strings.Fields(kargs) {
+		if strings.HasPrefix(arg, "ostree=") || strings.HasPrefix(arg, "BOOT_IMAGE=") {
+			continue
+		}
+		args = append(args, arg)
+	}
+	return strings.Join(append(args, "ostree="+root), " ")
+}
+
+// prepare sets up the staged deployment to be booted, and returns the
+// systemctl verb booting into it.
+func (p *fastRebootPlan) prepare(runner CommandRunner) (string, error) {
+	switch p.method {
+	case apihelpers.SoftRebootMethod:
+		// The staged deployment is always the first one
+		if _, err := runner.RunGetOut("ostree", "admin", "prepare-soft-reboot", "0"); err != nil {
+			return "", fmt.Errorf("failed to prepare soft reboot: %w", err)
+		}
+		return "soft-reboot", nil
+	case apihelpers.KexecRebootMethod:
+		kargs, err := runner.RunGetOut("rpm-ostree", "kargs")
+		if err != nil {
+			return "", fmt.Errorf("failed to read kernel arguments of the staged deployment: %w", err)
+		}
+		kernelDir := filepath.Join(p.deploymentRoot, "usr/lib/modules", p.kernelVersion)
+		if _, err := runner.RunGetOut("kexec", "-l", filepath.Join(kernelDir, "vmlinuz"),
+			"--initrd="+filepath.Join(kernelDir, "initramfs.img"),
+			"--append="+kexecCommandLine(string(kargs), p.deploymentRoot)); err != nil {
+			return "", fmt.Errorf("failed to load kernel %s: %w", p.kernelVersion, err)
+		}
+		return "kexec", nil
+	}
+	return "", fmt.Errorf("unsupported reboot method %q", p.method)
+}
+
+// writeFastRebootState records the deployment a plan boots into.
+func writeFastRebootState(path string, plan *fastRebootPlan) error {
+	data, err := json.Marshal(fastRebootState{Method: plan.method, DeploymentRoot: plan.deploymentRoot})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomicallyWithDefaults(path, data)
+}
+
+// consumeFastRebootState reads and removes the state of the last soft reboot
+// or kexec. It returns nil if there is none.
+func consumeFastRebootState(path string) (*fastRebootState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+	state := &fastRebootState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid fast reboot state %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// verifyFastReboot checks that the last soft reboot or kexec booted the
+// deployment it was prepared for, and starts a full reboot otherwise. It
+// returns true if it started a full reboot.
+func (dn *Daemon) verifyFastReboot() (bool, error) {
+	state, err := consumeFastRebootState(constants.FastRebootStateFile)
+	if err != nil || state == nil || dn.NodeUpdaterClient == nil {
+		return false, err
+	}
+	booted, _, err := dn.NodeUpdaterClient.GetBootedAndStagedDeployment()
+	if err != nil {
+		return false, fmt.Errorf("failed to get the booted deployment after %s: %w", state.Method, err)
+	}
+	if bootedRoot := deploymentRoot(booted); bootedRoot != state.DeploymentRoot {
+		logSystem("%s booted deployment %s instead of %s, falling back to a full reboot", state.Method, bootedRoot, state.DeploymentRoot)
+		mcdRebootErr.Inc()
+		return true, dn.reboot(fmt.Sprintf("%s did not boot deployment %s", state.Method, state.DeploymentRoot))
+	}
+	klog.Infof("%s booted deployment %s", state.Method, state.DeploymentRoot)
+	return false, nil
+}
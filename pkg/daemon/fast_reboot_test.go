@@ -0,0 +1,129 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+)
+
+func TestSelectRebootMethod(t *testing.T) {
+	allMethods := []apihelpers.RebootMethod{apihelpers.SoftRebootMethod, apihelpers.KexecRebootMethod}
+	testCases := []struct {
+		name         string
+		diff         *machineConfigDiff
+		allowed      []apihelpers.RebootMethod
+		stagedKernel string
+		expected     apihelpers.RebootMethod
+	}{
+		{
+			name:         "OS update with the same kernel",
+			diff:         &machineConfigDiff{osUpdate: true},
+			allowed:      allMethods,
+			stagedKernel: "5.14.0-1.el9.x86_64",
+			expected:     apihelpers.SoftRebootMethod,
+		},
+		{
+			name:         "OS update with a new kernel",
+			diff:         &machineConfigDiff{osUpdate: true},
+			allowed:      allMethods,
+			stagedKernel: "5.14.0-2.el9.x86_64",
+			expected:     apihelpers.KexecRebootMethod,
+		},
+		{
+			name:         "kernel arguments change",
+			diff:         &machineConfigDiff{kargs: true},
+			allowed:      allMethods,
+			stagedKernel: "5.14.0-1.el9.x86_64",
+			expected:     apihelpers.KexecRebootMethod,
+		},
+		{
+			name:         "new kernel with only soft reboot allowed",
+			diff:         &machineConfigDiff{osUpdate: true},
+			allowed:      []apihelpers.RebootMethod{apihelpers.SoftRebootMethod},
+			stagedKernel: "5.14.0-2.el9.x86_64",
+			expected:     "",
+		},
+		{
+			name:         "FIPS change",
+			diff:         &machineConfigDiff{fips: true},
+			allowed:      allMethods,
+			stagedKernel: "5.14.0-1.el9.x86_64",
+			expected:     "",
+		},
+		{
+			name:     "unknown staged kernel",
+			diff:     &machineConfigDiff{osUpdate: true},
+			allowed:  allMethods,
+			expected: "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, selectRebootMethod(testCase.diff, testCase.allowed, "5.14.0-1.el9.x86_64", testCase.stagedKernel))
+		})
+	}
+}
+
+func TestDeploymentKernelVersion(t *testing.T) {
+	root := t.TempDir()
+	_, err := deploymentKernelVersion(root)
+	assert.Error(t, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "usr/lib/modules/extra"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "usr/lib/modules/5.14.0-1.el9.x86_64"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "usr/lib/modules/5.14.0-1.el9.x86_64/vmlinuz"), nil, 0o644))
+	kernelVersion, err := deploymentKernelVersion(root)
+	require.NoError(t, err)
+	assert.Equal(t, "5.14.0-1.el9.x86_64", kernelVersion)
+}
+
+func TestFastRebootPlanPrepare(t *testing.T) {
+	root := "/ostree/deploy/rhcos/deploy/abc.0"
+	kernelDir := root + "/usr/lib/modules/5.14.0-2.el9.x86_64"
+	assert.Equal(t, "rw nosmt ostree="+root, kexecCommandLine("BOOT_IMAGE=(hd0,gpt3)/vmlinuz rw ostree=/ostree/boot.1/rhcos/1234/0 nosmt\n", root))
+
+	runner := &MockCommandRunner{
+		outputs: map[string][]byte{
+			"ostree admin prepare-soft-reboot 0": nil,
+			"rpm-ostree kargs":                   []byte("rw nosmt\n"),
+			fmt.Sprintf("kexec -l %s/vmlinuz --initrd=%s/initramfs.img --append=rw nosmt ostree=%s", kernelDir, kernelDir, root): nil,
+		},
+	}
+
+	verb, err := (&fastRebootPlan{method: apihelpers.SoftRebootMethod, deploymentRoot: root, kernelVersion: "5.14.0-1.el9.x86_64"}).prepare(runner)
+	require.NoError(t, err)
+	assert.Equal(t, "soft-reboot", verb)
+
+	verb, err = (&fastRebootPlan{method: apihelpers.KexecRebootMethod, deploymentRoot: root, kernelVersion: "5.14.0-2.el9.x86_64"}).prepare(runner)
+	require.NoError(t, err)
+	assert.Equal(t, "kexec", verb)
+
+	// A kernel which can't be loaded is reported to fall back to a full reboot
+	_, err = (&fastRebootPlan{method: apihelpers.KexecRebootMethod, deploymentRoot: root, kernelVersion: "5.14.0-3.el9.x86_64"}).prepare(runner)
+	assert.ErrorContains(t, err, "failed to load kernel 5.14.0-3.el9.x86_64")
+}
+
+func TestFastRebootState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "machine-config-daemon/fast-reboot.json")
+
+	state, err := consumeFastRebootState(path)
+	require.NoError(t, err)
+	assert.Nil(t, state)
+
+	plan := &fastRebootPlan{method: apihelpers.KexecRebootMethod, deploymentRoot: "/ostree/deploy/rhcos/deploy/abc.0", kernelVersion: "5.14.0-2.el9.x86_64"}
+	require.NoError(t, writeFastRebootState(path, plan))
+
+	state, err = consumeFastRebootState(path)
+	require.NoError(t, err)
+	assert.Equal(t, &fastRebootState{Method: apihelpers.KexecRebootMethod, DeploymentRoot: "/ostree/deploy/rhcos/deploy/abc.0"}, state)
+
+	// The state is only checked on the first start after the reboot
+	assert.NoFileExists(t, path)
+}
@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"os/user"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -46,6 +47,19 @@ func noOrigFileStampName(fpath string) string {
 	return filepath.Join(noOrigParentDir(), fpath+".mcdnoorig")
 }
 
+// appendBaseFileName is where the MCD records the contents a file had before
+// it first appended to it, so the appends can be re-applied on every update
+// instead of accumulating.
+func appendBaseFileName(fpath string) string {
+	return filepath.Join(origParentDir(), fpath+".mcdappendbase")
+}
+
+// noAppendBaseStampName is the appendBaseFileName counterpart for files that
+// did not exist on disk before the MCD appended to them.
+func noAppendBaseStampName(fpath string) string {
+	return filepath.Join(noOrigParentDir(), fpath+".mcdnoappendbase")
+}
+
 func isFileOwnedByRPMPkg(fpath string) (bool, bool, error) {
 	// The first bool returns false if rpm exists, and true otherwise (indicating other Linux distros or Mac).
 	// We would like to skip orig file creation and preservation for [first bool = true] cases.
@@ -151,6 +165,170 @@ func createOrigFile(fromPath, fpath string) error {
 	return nil
 }
 
+// createAppendBaseFile records the contents of a file that is appended to
+// without replacing its contents. The record is only created once, so it
+// keeps the contents the file had before the MCD took over. For a file whose
+// full contents were written by a previous MachineConfig, these are the
+// contents recorded in its orig file or noorig stamp. On firstboot, Ignition
+// already appended to the file, so the record is only seeded from the orig
+// file of the OS default in /usr/etc.
+func createAppendBaseFile(file ign3types.File, firstBoot bool) error {
+	for _, record := range []string{appendBaseFileName(file.Path), noAppendBaseStampName(file.Path)} {
+		exists, err := fileExists(record)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+	}
+
+	noOrigExists, err := fileExists(noOrigFileStampName(file.Path))
+	if err != nil {
+		return err
+	}
+	if noOrigExists {
+		return createNoAppendBaseStamp(file.Path)
+	}
+
+	if firstBoot {
+		if _, err := os.Stat(withUsrPath(file.Path)); err == nil {
+			if err := createOrigFile(withUsrPath(file.Path), file.Path); err != nil {
+				return err
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	origExists, err := fileExists(origFileName(file.Path))
+	if err != nil {
+		return err
+	}
+	if origExists {
+		return copyAppendBaseFile(origFileName(file.Path), file.Path)
+	}
+	if firstBoot {
+		// The file did not ship with the OS, Ignition created it
+		return createNoAppendBaseStamp(file.Path)
+	}
+
+	if _, err := os.Stat(file.Path); os.IsNotExist(err) {
+		return createNoAppendBaseStamp(file.Path)
+	} else if err != nil {
+		return err
+	}
+	return copyAppendBaseFile(file.Path, file.Path)
+}
+
+// removeAppendBaseFile deletes the records of createAppendBaseFile once a
+// file is no longer appended to. Its base is recorded again from its orig
+// file or noorig stamp if it is appended to later on.
+func removeAppendBaseFile(fpath string) error {
+	for _, record := range []string{appendBaseFileName(fpath), noAppendBaseStampName(fpath)} {
+		if err := os.Remove(record); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("deleting append base record %q: %w", record, err)
+		}
+	}
+	return nil
+}
+
+// createNoAppendBaseStamp records that a file did not exist before the MCD
+// appended to it.
+func createNoAppendBaseStamp(fpath string) error {
+	if err := os.MkdirAll(filepath.Dir(noAppendBaseStampName(fpath)), 0o755); err != nil {
+		return fmt.Errorf("creating no append base parent dir: %w", err)
+	}
+	return writeFileAtomicallyWithDefaults(noAppendBaseStampName(fpath), nil)
+}
+
+// copyAppendBaseFile records the contents of from as the append base of fpath.
+func copyAppendBaseFile(from, fpath string) error {
+	if err := os.MkdirAll(filepath.Dir(appendBaseFileName(fpath)), 0o755); err != nil {
+		return fmt.Errorf("creating append base parent dir: %w", err)
+	}
+	if out, err := exec.Command("cp", "-a", "--reflink=auto", from, appendBaseFileName(fpath)).CombinedOutput(); err != nil {
+		return fmt.Errorf("creating append base file for %q: %s: %w", fpath, string(out), err)
+	}
+	return nil
+}
+
+// decodeFileAppends returns the concatenated contents of the file's appends.
+func decodeFileAppends(file ign3types.File) ([]byte, error) {
+	var appends []byte
+	for _, a := range file.Append {
+		decoded, err := ctrlcommon.DecodeIgnitionFileContents(a.Source, a.Compression)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode append to file %q: %w", file.Path, err)
+		}
+		appends = append(appends, decoded...)
+	}
+	return appends, nil
+}
+
+// fileContentsWithAppends returns the contents the file should have on disk.
+// Appends are added to the file's contents if set, otherwise to the contents
+// recorded by createAppendBaseFile.
+func fileContentsWithAppends(file ign3types.File) ([]byte, error) {
+	contents, err := ctrlcommon.DecodeIgnitionFileContents(file.Contents.Source, file.Contents.Compression)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode file %q: %w", file.Path, err)
+	}
+	if len(file.Append) == 0 {
+		return contents, nil
+	}
+
+	if file.Contents.Source == nil {
+		contents, err = os.ReadFile(appendBaseFileName(file.Path))
+		if os.IsNotExist(err) {
+			exists, stampErr := fileExists(noAppendBaseStampName(file.Path))
+			if stampErr != nil {
+				return nil, stampErr
+			}
+			if !exists {
+				return nil, fmt.Errorf("no base contents recorded for appended file %q", file.Path)
+			}
+			contents, err = nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	appends, err := decodeFileAppends(file)
+	if err != nil {
+		return nil, err
+	}
+	return append(contents, appends...), nil
+}
+
+// restoreAppendBaseFile puts back the contents recorded by
+// createAppendBaseFile, or removes the file if it did not exist before the
+// MCD appended to it. It returns false if no record exists for the path.
+func restoreAppendBaseFile(fpath string) (bool, error) {
+	noBaseExists, err := fileExists(noAppendBaseStampName(fpath))
+	if err != nil {
+		return false, err
+	}
+	if noBaseExists {
+		klog.V(2).Infof("Deleting stale appended file: %s", fpath)
+		if err := os.Remove(fpath); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		return true, os.Remove(noAppendBaseStampName(fpath))
+	}
+
+	baseExists, err := fileExists(appendBaseFileName(fpath))
+	if err != nil || !baseExists {
+		return false, err
+	}
+	klog.V(2).Infof("Restoring appended file %q to its base contents", fpath)
+	if out, err := exec.Command("cp", "-a", "--reflink=auto", appendBaseFileName(fpath), fpath).CombinedOutput(); err != nil {
+		return false, fmt.Errorf("restoring %q from append base file: %s: %w", fpath, string(out), err)
+	}
+	return true, os.Remove(appendBaseFileName(fpath))
+}
+
 func writeFileAtomicallyWithDefaults(fpath string, b []byte) error {
 	return writeFileAtomically(fpath, b, defaultDirectoryPermissions, defaultFilePermissions, -1, -1)
 }
@@ -224,7 +402,8 @@ func writeDropins(u ign3types.Unit, systemdRoot string, isCoreOSVariant bool) er
 
 // writeFiles writes the given files to disk.
 // it doesn't fetch remote files and expects a flattened config file.
-func writeFiles(files []ign3types.File, skipCertificateWrite bool) error {
+// firstBoot is set when Ignition already wrote the files.
+func writeFiles(files []ign3types.File, skipCertificateWrite, firstBoot bool) error {
 	for _, file := range files {
 		if skipCertificateWrite && file.Path == caBundleFilePath {
 			// TODO remove this special case once we have a better way to do this
@@ -233,15 +412,17 @@ func writeFiles(files []ign3types.File, skipCertificateWrite bool) error {
 		}
 		klog.Infof("Writing file %q", file.Path)
 
-		// We don't support appends in the file section, so instead of waiting to fail validation,
-		// let's explicitly fail here.
-		if len(file.Append) > 0 {
-			return fmt.Errorf("found an append section when writing files. Append is not supported")
+		if len(file.Append) > 0 && file.Contents.Source == nil {
+			if err := createAppendBaseFile(file, firstBoot); err != nil {
+				return err
+			}
+		} else if err := removeAppendBaseFile(file.Path); err != nil {
+			return err
 		}
 
-		decodedContents, err := ctrlcommon.DecodeIgnitionFileContents(file.Contents.Source, file.Contents.Compression)
+		decodedContents, err := fileContentsWithAppends(file)
 		if err != nil {
-			return fmt.Errorf("could not decode file %q: %w", file.Path, err)
+			return err
 		}
 
 		mode := defaultFilePermissions
@@ -250,7 +431,7 @@ func writeFiles(files []ign3types.File, skipCertificateWrite bool) error {
 		}
 
 		// set chown if file information is provided
-		uid, gid, err := getFileOwnership(file)
+		uid, gid, err := getNodeOwnership(file.Node)
 		if err != nil {
 			return fmt.Errorf("failed to retrieve file ownership for file %q: %w", file.Path, err)
 		}
@@ -264,6 +445,111 @@ func writeFiles(files []ign3types.File, skipCertificateWrite bool) error {
 	return nil
 }
 
+// writeDirectories creates the given directories and sets their mode and
+// ownership. Existing directories are updated in place.
+func writeDirectories(dirs []ign3types.Directory) error {
+	// Sort the directories so that parents are created first
+	dirs = slices.Clone(dirs)
+	slices.SortFunc(dirs, func(a, b ign3types.Directory) int { return strings.Compare(a.Path, b.Path) })
+
+	for _, dir := range dirs {
+		klog.Infof("Writing directory %q", dir.Path)
+
+		mode := defaultDirectoryPermissions
+		if dir.Mode != nil {
+			mode = os.FileMode(*dir.Mode) //nolint:gosec
+		}
+
+		uid, gid, err := getNodeOwnership(dir.Node)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve directory ownership for directory %q: %w", dir.Path, err)
+		}
+
+		fi, err := os.Lstat(dir.Path)
+		switch {
+		case err == nil && fi.IsDir():
+			// Directories that were on disk before the MCD took over are left
+			// in place when they are removed from the config.
+		case err == nil:
+			if dir.Overwrite == nil || !*dir.Overwrite {
+				return fmt.Errorf("%q exists and is not a directory, overwrite must be set to replace it", dir.Path)
+			}
+			if err := createOrigFile(dir.Path, dir.Path); err != nil {
+				return err
+			}
+			if err := os.Remove(dir.Path); err != nil {
+				return fmt.Errorf("failed to remove %q to replace it with a directory: %w", dir.Path, err)
+			}
+		case os.IsNotExist(err):
+			if err := createOrigFile(dir.Path, dir.Path); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		if err := os.MkdirAll(dir.Path, mode); err != nil {
+			return fmt.Errorf("failed to create directory %q: %w", dir.Path, err)
+		}
+		// MkdirAll is subject to the umask and does not update existing directories
+		if err := os.Chmod(dir.Path, mode); err != nil {
+			return fmt.Errorf("failed to set mode of directory %q: %w", dir.Path, err)
+		}
+		if err := os.Chown(dir.Path, uid, gid); err != nil {
+			return fmt.Errorf("failed to set ownership of directory %q: %w", dir.Path, err)
+		}
+	}
+	return nil
+}
+
+// writeLinks creates the given symbolic links, atomically replacing existing
+// symbolic links. Other existing files are only replaced if overwrite is set.
+// Hard links are not supported.
+func writeLinks(links []ign3types.Link) error {
+	for _, link := range links {
+		klog.Infof("Writing link %q", link.Path)
+
+		if link.Hard != nil && *link.Hard {
+			return fmt.Errorf("found a hard link %q when writing links. Hard links are not supported", link.Path)
+		}
+		if link.Target == nil || *link.Target == "" {
+			return fmt.Errorf("link %q has no target", link.Path)
+		}
+
+		uid, gid, err := getNodeOwnership(link.Node)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve link ownership for link %q: %w", link.Path, err)
+		}
+
+		fi, err := os.Lstat(link.Path)
+		switch {
+		case err == nil && fi.Mode()&os.ModeSymlink != 0:
+		case err == nil && fi.IsDir():
+			return fmt.Errorf("%q is a directory and cannot be replaced with a link", link.Path)
+		case err == nil:
+			if link.Overwrite == nil || !*link.Overwrite {
+				return fmt.Errorf("%q exists and is not a link, overwrite must be set to replace it", link.Path)
+			}
+		case !os.IsNotExist(err):
+			return err
+		}
+
+		if err := createOrigFile(link.Path, link.Path); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(link.Path), defaultDirectoryPermissions); err != nil {
+			return fmt.Errorf("failed to create directory %q: %w", filepath.Dir(link.Path), err)
+		}
+		if err := renameio.Symlink(*link.Target, link.Path); err != nil {
+			return fmt.Errorf("failed to symlink %q to %q: %w", link.Path, *link.Target, err)
+		}
+		if err := os.Lchown(link.Path, uid, gid); err != nil {
+			return fmt.Errorf("failed to set ownership of link %q: %w", link.Path, err)
+		}
+	}
+	return nil
+}
+
 // writeUnit writes a systemd unit and its dropins to disk
 func writeUnit(u ign3types.Unit, systemdRoot string, isCoreOSVariant bool) error {
 	if err := writeDropins(u, systemdRoot, isCoreOSVariant); err != nil {
@@ -372,22 +658,22 @@ func lookupGID(group string) (int, error) {
 }
 
 // This is essentially ResolveNodeUidAndGid() from Ignition; XXX should dedupe
-func getFileOwnership(file ign3types.File) (int, int, error) {
+func getNodeOwnership(node ign3types.Node) (int, int, error) {
 	uid, gid := 0, 0 // default to root
 	var err error    // create default error var
-	if file.User.ID != nil {
-		uid = *file.User.ID
-	} else if file.User.Name != nil && *file.User.Name != "" {
-		uid, err = lookupUID(*file.User.Name)
+	if node.User.ID != nil {
+		uid = *node.User.ID
+	} else if node.User.Name != nil && *node.User.Name != "" {
+		uid, err = lookupUID(*node.User.Name)
 		if err != nil {
 			return uid, gid, err
 		}
 	}
 
-	if file.Group.ID != nil {
-		gid = *file.Group.ID
-	} else if file.Group.Name != nil && *file.Group.Name != "" {
-		gid, err = lookupGID(*file.Group.Name)
+	if node.Group.ID != nil {
+		gid = *node.Group.ID
+	} else if node.Group.Name != nil && *node.Group.Name != "" {
+		gid, err = lookupGID(*node.Group.Name)
 		if err != nil {
 			return uid, gid, err
 		}
@@ -30,6 +30,19 @@ func iri() *iriBuilder {
 	}
 }
 
+func (ib *iriBuilder) withAdditionalImages(value string) *iriBuilder {
+	ib.obj.Annotations = map[string]string{common.InternalReleaseImageAdditionalImagesAnnotationKey: value}
+	return ib
+}
+
+func (ib *iriBuilder) withIntegrityScanInterval(value string) *iriBuilder {
+	if ib.obj.Annotations == nil {
+		ib.obj.Annotations = map[string]string{}
+	}
+	ib.obj.Annotations[common.InternalReleaseImageIntegrityScanIntervalAnnotationKey] = value
+	return ib
+}
+
 func (ib *iriBuilder) withDeletionTimestamp() *iriBuilder {
 	now := metav1.Now()
 	ib.obj.DeletionTimestamp = &now
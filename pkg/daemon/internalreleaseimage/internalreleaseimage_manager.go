@@ -2,6 +2,7 @@ package internalreleaseimage
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
@@ -9,19 +10,23 @@ import (
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"k8s.io/apimachinery/pkg/api/equality"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 
+	"github.com/opencontainers/go-digest"
 	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
 	mcfgclientset "github.com/openshift/client-go/machineconfiguration/clientset/versioned"
 	mcfginformersv1 "github.com/openshift/client-go/machineconfiguration/informers/externalversions/machineconfiguration/v1"
@@ -34,19 +39,46 @@ const (
 	// controller configuration
 	maxRetriesController = 15
 	syncRetryInterval    = 60 * time.Second
+
+	// integrityScanTimeout bounds a scan of the registry storage, including
+	// the re-sync of the corrupted content from the peer nodes.
+	integrityScanTimeout = 6 * time.Hour
+	// peerFetchTimeout bounds the fetch of a single blob from a peer node.
+	peerFetchTimeout = 30 * time.Minute
+
+	// mirrorTimeout bounds the mirroring of a single additional image.
+	mirrorTimeout = time.Hour
+	// mirrorRetryInterval is how long to wait before mirroring an additional
+	// image again after a failure.
+	mirrorRetryInterval = 10 * time.Minute
 )
 
+// mirrorFailure is the last failure to mirror an additional image.
+type mirrorFailure struct {
+	time time.Time
+	err  error
+}
+
 // Manager manages the IRI registry data on disk
 // and takes care of updating the MCN status IRI fields for the current node.
 type Manager struct {
 	nodeName string
 
+	kubeClient     kubernetes.Interface
 	mcfgClient     mcfgclientset.Interface
 	registryClient *http.Client
+	// peerRegistryClient fetches content from the IRI registry of the peer
+	// nodes when re-syncing corrupted content.
+	peerRegistryClient *http.Client
 	// authToken overrides the token read from the kubelet auth file; used in tests.
 	authToken string
 	// registryDataPath overrides the default registry data path; used in tests.
 	registryDataPath string
+	// copyImage runs "skopeo copy" with the given arguments.
+	copyImage func(ctx context.Context, args ...string) error
+	// mirrorFailures are the last failures to mirror the additional images,
+	// by source. Only accessed by the sync, which never runs concurrently.
+	mirrorFailures map[string]mirrorFailure
 
 	syncHandler                 func(iri string) error
 	enqueueInternalReleaseImage func(*mcfgv1.InternalReleaseImage)
@@ -57,22 +89,34 @@ type Manager struct {
 
 	mcnLister       mcfglistersv1.MachineConfigNodeLister
 	mcnListerSynced cache.InformerSynced
+
+	// integrityMu protects the fields below
+	integrityMu       sync.Mutex
+	integrityScanning bool
+	lastIntegrityScan time.Time
+	// integrityStatus is the result of the last integrity scan, nil if the
+	// scan is disabled or did not run yet.
+	integrityStatus *common.IRIIntegrityStatus
 }
 
 // NewInternalReleaseImageManager creates a new internal release image manager.
 func New(
 	nodeName string,
+	kubeClient kubernetes.Interface,
 	mcfgClient mcfgclientset.Interface,
 	iriInformer mcfginformersv1.InternalReleaseImageInformer,
 	mcnInformer mcfginformersv1.MachineConfigNodeInformer,
 ) *Manager {
 	i := &Manager{
-		nodeName: nodeName,
+		nodeName:       nodeName,
+		copyImage:      skopeoCopy,
+		mirrorFailures: map[string]mirrorFailure{},
 		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
 			workqueue.DefaultTypedControllerRateLimiter[string](),
 			workqueue.TypedRateLimitingQueueConfig[string]{Name: "internal-release-image-manager"}),
 	}
 
+	i.kubeClient = kubeClient
 	i.mcfgClient = mcfgClient
 
 	i.syncHandler = i.syncInternalReleaseImage
@@ -113,6 +157,17 @@ func (i *Manager) Run(workers int, stopCh <-chan struct{}) {
 	if i.registryClient == nil {
 		i.registryClient = &http.Client{Timeout: 3 * time.Second}
 	}
+	if i.peerRegistryClient == nil {
+		// All the control plane nodes serve the same certificate, issued for
+		// localhost, so the peers are verified against that name rather than
+		// their address.
+		i.peerRegistryClient = &http.Client{
+			Timeout: peerFetchTimeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{ServerName: iriRegistryHost},
+			},
+		}
+	}
 
 	klog.Infof("Starting InternalReleaseImage Manager")
 	defer klog.Infof("Shutting down InternalReleaseImage Manager")
@@ -193,6 +248,15 @@ func (i *Manager) internalReleaseImageChanged(old, newIRI *mcfgv1.InternalReleas
 	if !reflect.DeepEqual(old.Spec, newIRI.Spec) {
 		return true
 	}
+	for _, key := range []string{
+		common.InternalReleaseImageAdditionalImagesAnnotationKey,
+		common.InternalReleaseImageIntegrityScanIntervalAnnotationKey,
+		common.InternalReleaseImageIntegrityResyncAnnotationKey,
+	} {
+		if old.Annotations[key] != newIRI.Annotations[key] {
+			return true
+		}
+	}
 	return false
 }
 
@@ -236,7 +300,7 @@ func (i *Manager) updateMCNStatus(mcnOld, mcn *mcfgv1.MachineConfigNode) error {
 	return nil
 }
 
-func (i *Manager) refreshMachineConfigNodeStatus(mcn *mcfgv1.MachineConfigNode, iriReg *iriRegistry) error {
+func (i *Manager) refreshMachineConfigNodeStatus(mcn *mcfgv1.MachineConfigNode, iriReg *iriRegistry, additionalImages []common.IRIAdditionalImageNodeStatus) error {
 	// Get the current OCP releases bundles stored in the local IRI registry.
 	registryBundles, err := iriReg.GetOCPBundlesTags()
 	if err != nil {
@@ -316,26 +380,318 @@ func (i *Manager) refreshMachineConfigNodeStatus(mcn *mcfgv1.MachineConfigNode,
 			})
 		}
 	}
-	if !mcnDegraded {
+	// Corrupted content does not make the release images unavailable until
+	// it is pulled, but it degrades the node so that it gets repaired first.
+	integrity := i.getIntegrityStatus()
+	corrupted := integrity.Unrepaired()
+	switch {
+	case mcnDegraded:
 		meta.SetStatusCondition(&mcnUpdated.Status.Conditions, metav1.Condition{
 			Type:    string(mcfgv1.MachineConfigNodeInternalReleaseImageDegraded),
-			Status:  metav1.ConditionFalse,
-			Reason:  "AllReleasesAvailable",
-			Message: "All the release images are available",
+			Status:  metav1.ConditionTrue,
+			Reason:  "ReleaseImageNotFound",
+			Message: "One or more release bundle are not available",
 		})
-	} else {
+	case len(corrupted) > 0:
 		meta.SetStatusCondition(&mcnUpdated.Status.Conditions, metav1.Condition{
 			Type:    string(mcfgv1.MachineConfigNodeInternalReleaseImageDegraded),
 			Status:  metav1.ConditionTrue,
-			Reason:  "ReleaseImageNotFound",
-			Message: "One or more release bundle are not available",
+			Reason:  "RegistryContentCorrupted",
+			Message: corruptedContentMessage(integrity, corrupted),
+		})
+	default:
+		message := "All the release images are available"
+		if integrity != nil {
+			message += ". " + integrityScanSummary(integrity)
+		}
+		meta.SetStatusCondition(&mcnUpdated.Status.Conditions, metav1.Condition{
+			Type:    string(mcfgv1.MachineConfigNodeInternalReleaseImageDegraded),
+			Status:  metav1.ConditionFalse,
+			Reason:  "AllReleasesAvailable",
+			Message: message,
 		})
 	}
+	setAdditionalImagesCondition(&mcnUpdated.Status.Conditions, additionalImages)
 
 	return i.updateMCNStatus(mcn, mcnUpdated)
 }
 
-func (i *Manager) setMachineConfigNodeAsDegraded(mcn *mcfgv1.MachineConfigNode, registryErr error) error {
+// checkAdditionalImages checks the availability of the additional images
+// listed on the InternalReleaseImage in the local registry, mirroring the
+// missing images from their source first. Returns nil if there are no
+// additional images.
+func (i *Manager) checkAdditionalImages(iri *mcfgv1.InternalReleaseImage, iriReg *iriRegistry, registryErr error) []common.IRIAdditionalImageNodeStatus {
+	images, err := common.GetIRIAdditionalImages(iri)
+	if err != nil {
+		// The controller reports the invalid list on the InternalReleaseImage.
+		klog.Warningf("Skipping additional images availability check: %v", err)
+		return nil
+	}
+
+	var statuses []common.IRIAdditionalImageNodeStatus
+	for _, image := range images {
+		pullspec := fmt.Sprintf("%s/%s", net.JoinHostPort(iriRegistryHost, fmt.Sprintf("%d", iriRegistryPort)), image.Image)
+
+		err := registryErr
+		if err == nil {
+			err = iriReg.CheckImageAvailability(pullspec)
+			if err != nil && image.Source != "" {
+				err = i.mirrorAdditionalImage(image)
+				if err == nil {
+					err = iriReg.CheckImageAvailability(pullspec)
+				}
+			}
+		}
+
+		status := common.IRIAdditionalImageNodeStatus{Name: image.Name, Image: image.Image, Available: err == nil}
+		if err != nil {
+			klog.Errorf("Additional image %s not available for %s. Error: %v", pullspec, image.Name, err)
+			status.Message = err.Error()
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// mirrorAdditionalImage copies the additional image from its source into the
+// local registry, keeping its digest. The image is tagged with its digest,
+// like the release images. A failed mirror is only retried after
+// mirrorRetryInterval, returning the last error meanwhile.
+func (i *Manager) mirrorAdditionalImage(image common.IRIAdditionalImage) error {
+	if failure, ok := i.mirrorFailures[image.Source]; ok && time.Since(failure.time) < mirrorRetryInterval {
+		return failure.err
+	}
+
+	repo, ref, _ := strings.Cut(image.Image, "@")
+	dgst, err := digest.Parse(ref)
+	if err != nil {
+		return err
+	}
+	dest := fmt.Sprintf("docker://%s/%s:%s", net.JoinHostPort(iriRegistryHost, fmt.Sprintf("%d", iriRegistryPort)), repo, dgst.Encoded())
+
+	klog.Infof("Mirroring additional image %s from %s", image.Name, image.Source)
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+	defer cancel()
+	if err := i.copyImage(ctx, "--all", "--preserve-digests", "--authfile", constants.KubeletAuthFile, "docker://"+image.Source, dest); err != nil {
+		err = fmt.Errorf("failed to mirror %s: %w", image.Source, err)
+		i.mirrorFailures[image.Source] = mirrorFailure{time: time.Now(), err: err}
+		return err
+	}
+	delete(i.mirrorFailures, image.Source)
+	klog.Infof("Mirrored additional image %s to %s", image.Name, dest)
+	return nil
+}
+
+// setAdditionalImagesCondition reports the availability of the additional
+// images in the node registry. Unlike release images, missing additional
+// images do not degrade the node. The condition is removed if there are no
+// additional images.
+func setAdditionalImagesCondition(conditions *[]metav1.Condition, statuses []common.IRIAdditionalImageNodeStatus) {
+	if len(statuses) == 0 {
+		meta.RemoveStatusCondition(conditions, common.MachineConfigNodeIRIAdditionalImagesDegraded)
+		return
+	}
+
+	condition := metav1.Condition{
+		Type:    common.MachineConfigNodeIRIAdditionalImagesDegraded,
+		Status:  metav1.ConditionFalse,
+		Reason:  "AllAdditionalImagesAvailable",
+		Message: common.FormatIRIAdditionalImagesNodeStatus(statuses),
+	}
+	for _, status := range statuses {
+		if !status.Available {
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = "AdditionalImagesNotAvailable"
+			break
+		}
+	}
+	meta.SetStatusCondition(conditions, condition)
+}
+
+// getIntegrityStatus returns the result of the last integrity scan.
+func (i *Manager) getIntegrityStatus() *common.IRIIntegrityStatus {
+	i.integrityMu.Lock()
+	defer i.integrityMu.Unlock()
+	return i.integrityStatus
+}
+
+// maxReportedMismatches is the number of unrepaired mismatches detailed in the
+// MachineConfigNode condition, which the controller reports on the
+// InternalReleaseImage status.
+const maxReportedMismatches = 3
+
+// corruptedContentMessage describes the unrepaired content found by the last
+// integrity scan.
+func corruptedContentMessage(status *common.IRIIntegrityStatus, corrupted []common.IRIIntegrityMismatch) string {
+	details := make([]string, 0, maxReportedMismatches)
+	for _, m := range corrupted {
+		if len(details) == maxReportedMismatches {
+			details = append(details, fmt.Sprintf("and %d more", len(corrupted)-maxReportedMismatches))
+			break
+		}
+		details = append(details, fmt.Sprintf("%s %s@%s: %s", m.Kind, m.Repository, m.Digest, m.Message))
+	}
+	return fmt.Sprintf("%d manifest(s) or blob(s) in the registry failed the integrity scan at %s: %s",
+		len(corrupted), status.LastScanTime.UTC().Format(time.RFC3339), strings.Join(details, "; "))
+}
+
+// integrityScanSummary describes the result of the last integrity scan.
+func integrityScanSummary(status *common.IRIIntegrityStatus) string {
+	scanTime := status.LastScanTime.UTC().Format(time.RFC3339)
+	if status.Error != "" {
+		return fmt.Sprintf("The integrity scan at %s failed: %s", scanTime, status.Error)
+	}
+	return fmt.Sprintf("The integrity scan at %s verified %d manifest(s) and blob(s)", scanTime, status.VerifiedBlobs)
+}
+
+// startIntegrityScan starts a scan of the registry storage in the background if
+// the last one is older than the scan interval. The InternalReleaseImage is
+// enqueued again once the scan completes, to report its result.
+func (i *Manager) startIntegrityScan(iri *mcfgv1.InternalReleaseImage) {
+	interval, err := common.GetIRIIntegrityScanInterval(iri)
+	if err != nil {
+		klog.Warningf("Skipping registry integrity scan: %v", err)
+		return
+	}
+
+	i.integrityMu.Lock()
+	defer i.integrityMu.Unlock()
+
+	if interval == 0 {
+		i.integrityStatus = nil
+		i.lastIntegrityScan = time.Time{}
+		return
+	}
+	if i.integrityScanning || time.Since(i.lastIntegrityScan) < interval {
+		return
+	}
+
+	i.integrityScanning = true
+	resync := common.IsIRIIntegrityResyncEnabled(iri)
+	go func() {
+		status := i.scanRegistryIntegrity(resync)
+
+		i.integrityMu.Lock()
+		i.integrityStatus = status
+		i.lastIntegrityScan = time.Now()
+		i.integrityScanning = false
+		i.integrityMu.Unlock()
+
+		i.queue.Add(common.InternalReleaseImageInstanceName)
+	}()
+}
+
+// scanRegistryIntegrity verifies the content of the registry storage and, if
+// resync is set, replaces the corrupted content with a copy from a peer node.
+func (i *Manager) scanRegistryIntegrity(resync bool) *common.IRIIntegrityStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), integrityScanTimeout)
+	defer cancel()
+
+	klog.Infof("Starting InternalReleaseImage registry integrity scan")
+	scanner := newIRIIntegrityScanner(i.getRegistryDataPath())
+	status, err := scanner.scan(ctx)
+	if err != nil {
+		klog.Errorf("InternalReleaseImage registry integrity scan failed: %v", err)
+		return &common.IRIIntegrityStatus{
+			LastScanTime: metav1.Now(),
+			Error:        err.Error(),
+		}
+	}
+	status.LastScanTime = metav1.Now()
+	klog.Infof("InternalReleaseImage registry integrity scan verified %d manifests and blobs, %d mismatches found", status.VerifiedBlobs, len(status.Mismatches))
+
+	if resync && len(status.Mismatches) > 0 {
+		i.resyncFromPeers(ctx, scanner, status)
+	}
+	return status
+}
+
+// resyncFromPeers replaces each mismatched manifest or blob with a copy fetched
+// from the first peer node able to serve it. The fetched content is verified
+// against its digest before replacing the local one.
+func (i *Manager) resyncFromPeers(ctx context.Context, scanner *iriIntegrityScanner, status *common.IRIIntegrityStatus) {
+	peers, err := i.getPeerRegistries(ctx)
+	if err != nil {
+		klog.Errorf("Cannot re-sync the corrupted registry content: %v", err)
+		return
+	}
+	if len(peers) == 0 {
+		klog.Warningf("Cannot re-sync the corrupted registry content: no peer node available")
+		return
+	}
+
+	for n := range status.Mismatches {
+		m := &status.Mismatches[n]
+		for _, peer := range peers {
+			if err := i.resyncFromPeer(scanner, peer, m); err != nil {
+				klog.Warningf("Failed to re-sync %s %s of %s from node %s: %v", m.Kind, m.Digest, m.Repository, peer.nodeName, err)
+				continue
+			}
+			klog.Infof("Re-synced %s %s of %s from node %s", m.Kind, m.Digest, m.Repository, peer.nodeName)
+			m.Repaired = true
+			m.RepairedFrom = peer.nodeName
+			break
+		}
+	}
+}
+
+func (i *Manager) resyncFromPeer(scanner *iriIntegrityScanner, peer *iriRegistry, m *common.IRIIntegrityMismatch) error {
+	content, err := peer.FetchContent(m.Repository, m.Digest, m.Kind)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	return scanner.replaceBlob(digest.Digest(m.Digest), content)
+}
+
+// getPeerRegistries returns a client for the IRI registry of each other
+// control plane node running it.
+func (i *Manager) getPeerRegistries(ctx context.Context) ([]*iriRegistry, error) {
+	authToken, err := i.getAuthToken()
+	if err != nil {
+		return nil, err
+	}
+
+	mcns, err := i.mcfgClient.MachineconfigurationV1().MachineConfigNodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MachineConfigNodes: %w", err)
+	}
+
+	peers := []*iriRegistry{}
+	for _, mcn := range mcns.Items {
+		if mcn.Name == i.nodeName || len(mcn.Status.InternalReleaseImage.Releases) == 0 {
+			continue
+		}
+		node, err := i.kubeClient.CoreV1().Nodes().Get(ctx, mcn.Name, metav1.GetOptions{})
+		if err != nil {
+			klog.Warningf("Skipping peer node %s: %v", mcn.Name, err)
+			continue
+		}
+		address := getNodeInternalIP(node)
+		if address == "" {
+			klog.Warningf("Skipping peer node %s: no internal IP", mcn.Name)
+			continue
+		}
+
+		peer := newIRIRegistry(mcn.Name, i.peerRegistryClient, authToken)
+		peer.registryHostPort = net.JoinHostPort(address, fmt.Sprintf("%d", iriRegistryPort))
+		peers = append(peers, peer)
+	}
+	return peers, nil
+}
+
+func getNodeInternalIP(node *corev1.Node) string {
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP {
+			return addr.Address
+		}
+	}
+	return ""
+}
+
+func (i *Manager) setMachineConfigNodeAsDegraded(mcn *mcfgv1.MachineConfigNode, registryErr error, additionalImages []common.IRIAdditionalImageNodeStatus) error {
 	reason := "RegistryUnreachable"
 
 	mcnUpdated := mcn.DeepCopy()
@@ -363,20 +719,22 @@ func (i *Manager) setMachineConfigNodeAsDegraded(mcn *mcfgv1.MachineConfigNode,
 			Message: "Release bundle is unavailable: failed to reach the registry",
 		})
 	}
+	setAdditionalImagesCondition(&mcnUpdated.Status.Conditions, additionalImages)
 
 	return i.updateMCNStatus(mcn, mcnUpdated)
 }
 
 func (i *Manager) cleanupMachineConfigNodeStatus(mcn *mcfgv1.MachineConfigNode) error {
-	if len(mcn.Status.InternalReleaseImage.Releases) == 0 {
+	if len(mcn.Status.InternalReleaseImage.Releases) == 0 &&
+		meta.FindStatusCondition(mcn.Status.Conditions, common.MachineConfigNodeIRIAdditionalImagesDegraded) == nil {
 		return nil
 	}
 
-	// Remove the IRI condition.
+	// Remove the IRI conditions.
 	mcnUpdated := mcn.DeepCopy()
 	var filtered []metav1.Condition
 	for _, c := range mcnUpdated.Status.Conditions {
-		if c.Type != string(mcfgv1.MachineConfigNodeInternalReleaseImageDegraded) {
+		if c.Type != string(mcfgv1.MachineConfigNodeInternalReleaseImageDegraded) && c.Type != common.MachineConfigNodeIRIAdditionalImagesDegraded {
 			filtered = append(filtered, c)
 		}
 	}
@@ -392,10 +750,7 @@ func (i *Manager) cleanupMachineConfigNodeStatus(mcn *mcfgv1.MachineConfigNode)
 // Returns an error if the directory cannot be removed, or nil if removal succeeded
 // or if the directory doesn't exist.
 func (i *Manager) reclaimRegistryStorage() error {
-	registryDataPath := i.registryDataPath
-	if registryDataPath == "" {
-		registryDataPath = constants.IRIRegistryDataPath
-	}
+	registryDataPath := i.getRegistryDataPath()
 
 	// Check if directory exists
 	info, err := os.Stat(registryDataPath)
@@ -450,20 +805,34 @@ func (i *Manager) reclaimRegistryStorage() error {
 	return nil
 }
 
+func (i *Manager) getRegistryDataPath() string {
+	if i.registryDataPath != "" {
+		return i.registryDataPath
+	}
+	return constants.IRIRegistryDataPath
+}
+
+func (i *Manager) getAuthToken() (string, error) {
+	if i.authToken != "" {
+		return i.authToken, nil
+	}
+	authToken, err := readIRIAuthToken(net.JoinHostPort(iriRegistryHost, fmt.Sprintf("%d", iriRegistryPort)))
+	if err != nil {
+		return "", fmt.Errorf("could not read IRI auth token: %w", err)
+	}
+	return authToken, nil
+}
+
 // getIRIRegistry creates and returns an IRI registry client.
 // Returns the registry and an error indicating whether the registry is reachable.
 func (i *Manager) getIRIRegistry() (*iriRegistry, error) {
-	authToken := i.authToken
-	if authToken == "" {
-		var err error
-		authToken, err = readIRIAuthToken(net.JoinHostPort(iriRegistryHost, fmt.Sprintf("%d", iriRegistryPort)))
-		if err != nil {
-			return nil, fmt.Errorf("could not read IRI auth token: %w", err)
-		}
+	authToken, err := i.getAuthToken()
+	if err != nil {
+		return nil, err
 	}
 
 	iriReg := newIRIRegistry(i.nodeName, i.registryClient, authToken)
-	err := iriReg.CheckLocalRegistry()
+	err = iriReg.CheckLocalRegistry()
 	return iriReg, err
 }
 
@@ -472,10 +841,7 @@ func (i *Manager) getIRIRegistry() (*iriRegistry, error) {
 // false if the directory doesn't exist (feature never used),
 // or an error if the check failed (permission denied, I/O error, etc.).
 func (i *Manager) wasFeatureActivated() (bool, error) {
-	registryDataPath := i.registryDataPath
-	if registryDataPath == "" {
-		registryDataPath = constants.IRIRegistryDataPath
-	}
+	registryDataPath := i.getRegistryDataPath()
 	_, err := os.Stat(registryDataPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -520,6 +886,9 @@ func (i *Manager) handleIRIDeletion(mcn *mcfgv1.MachineConfigNode) error {
 	if err := i.cleanupMachineConfigNodeStatus(mcn); err != nil {
 		return fmt.Errorf("failed to cleanup MCN: %w", err)
 	}
+	i.integrityMu.Lock()
+	i.integrityStatus = nil
+	i.integrityMu.Unlock()
 
 	// Reclaim storage
 	if err := i.reclaimRegistryStorage(); err != nil {
@@ -567,12 +936,17 @@ func (i *Manager) syncInternalReleaseImage(key string) error {
 		return i.handleIRIDeletion(mcn)
 	}
 
+	// Verify the registry storage periodically; the result is reported by the
+	// sync following the end of the scan.
+	i.startIntegrityScan(iri)
+
 	// Update MCN status based on registry availability
 	iriReg, registryErr := i.getIRIRegistry()
+	additionalImages := i.checkAdditionalImages(iri, iriReg, registryErr)
 	if registryErr != nil {
-		err = i.setMachineConfigNodeAsDegraded(mcn, registryErr)
+		err = i.setMachineConfigNodeAsDegraded(mcn, registryErr, additionalImages)
 	} else {
-		err = i.refreshMachineConfigNodeStatus(mcn, iriReg)
+		err = i.refreshMachineConfigNodeStatus(mcn, iriReg, additionalImages)
 	}
 	if err != nil {
 		klog.Errorf("failed to update MachineConfigNode status: %v", err)
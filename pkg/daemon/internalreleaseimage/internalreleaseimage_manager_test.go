@@ -3,16 +3,22 @@ package internalreleaseimage
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 
+	"github.com/opencontainers/go-digest"
 	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
 	"github.com/openshift/client-go/machineconfiguration/clientset/versioned/fake"
 	mcfginformers "github.com/openshift/client-go/machineconfiguration/informers/externalversions"
@@ -20,6 +26,9 @@ import (
 )
 
 func TestInternalReleaseImageManager(t *testing.T) {
+	// arguments of the "skopeo copy" runs mirroring additional images
+	var mirrored [][]string
+
 	cases := []struct {
 		name string
 
@@ -27,7 +36,11 @@ func TestInternalReleaseImageManager(t *testing.T) {
 		nodeName      string
 		mcn           *mcnBuilder
 		setupRegistry func(r *FakeIRIRegistry)
-		verify        func(t *testing.T, actualMCN *mcfgv1.MachineConfigNode, registryDataPath string)
+		// integrityStatus is the result of the last integrity scan
+		integrityStatus *common.IRIIntegrityStatus
+		// copyImage replaces "skopeo copy" to mirror the additional images
+		copyImage func(ctx context.Context, args ...string) error
+		verify    func(t *testing.T, actualMCN *mcfgv1.MachineConfigNode, registryDataPath string)
 
 		registryDisabled     bool
 		skipRegistryDirSetup bool
@@ -68,6 +81,98 @@ func TestInternalReleaseImageManager(t *testing.T) {
 				verifyCondition(t, r.Conditions, string(mcfgv1.InternalReleaseImageConditionTypeDegraded), metav1.ConditionFalse)
 			},
 		},
+		{
+			name: "additional images",
+			iri: iri().withAdditionalImages(`[
+				{"name":"catalog","image":"olm/catalog@sha256:1111111111111111111111111111111111111111111111111111111111111111"},
+				{"name":"app","image":"apps/app@sha256:2222222222222222222222222222222222222222222222222222222222222222"},
+				{"name":"tool","image":"apps/tool@sha256:3333333333333333333333333333333333333333333333333333333333333333","source":"quay.io/org/tool@sha256:3333333333333333333333333333333333333333333333333333333333333333"},
+				{"name":"broken","image":"apps/broken@sha256:4444444444444444444444444444444444444444444444444444444444444444","source":"quay.io/org/broken@sha256:4444444444444444444444444444444444444444444444444444444444444444"}
+			]`),
+			nodeName: "master-0",
+			mcn:      machineConfigNode("master-0"),
+			copyImage: func(_ context.Context, args ...string) error {
+				mirrored = append(mirrored, args)
+				if strings.Contains(args[len(args)-2], "broken") {
+					return fmt.Errorf("manifest unknown")
+				}
+				return nil
+			},
+
+			setupRegistry: func(r *FakeIRIRegistry) {
+				r.AddResponse("/v2", http.StatusOK, "{}").
+					AddResponse("/v2/openshift/release-bundles/tags/list", http.StatusOK, `{"name":"openshift/release-bundles","tags":["ocp-release-bundle-4.22.0-0.ci-2026-04-01-050515"]}`).
+					AddResponse("/v2/openshift/release-images/tags/list", http.StatusOK, `{"name":"openshift/release-images","tags":["68bdf24405449be5c78a1f27a7b64fc9ee980e4bc3c9b169e8b3da08e50e0389"]}`).
+					AddResponse("/v2/openshift/release-images/manifests/sha256:68bdf24405449be5c78a1f27a7b64fc9ee980e4bc3c9b169e8b3da08e50e0389", http.StatusOK, "{}").
+					AddResponse("/v2/olm/catalog/manifests/sha256:1111111111111111111111111111111111111111111111111111111111111111", http.StatusOK, "{}").
+					AddResponse("/v2/apps/app/manifests/sha256:2222222222222222222222222222222222222222222222222222222222222222", http.StatusNotFound, `{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown"}]}`).
+					// the tool is available once mirrored
+					AddResponse("/v2/apps/tool/manifests/sha256:3333333333333333333333333333333333333333333333333333333333333333", http.StatusNotFound, `{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown"}]}`).
+					AddResponse("/v2/apps/tool/manifests/sha256:3333333333333333333333333333333333333333333333333333333333333333", http.StatusOK, "{}").
+					AddResponse("/v2/apps/broken/manifests/sha256:4444444444444444444444444444444444444444444444444444444444444444", http.StatusNotFound, `{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown"}]}`)
+			},
+
+			verify: func(t *testing.T, mcn *mcfgv1.MachineConfigNode, registryDataPath string) {
+				// missing additional images do not degrade the node
+				verifyCondition(t, mcn.Status.Conditions, string(mcfgv1.MachineConfigNodeInternalReleaseImageDegraded), metav1.ConditionFalse)
+
+				// only the images with a source are mirrored
+				require.Len(t, mirrored, 2)
+				assert.Equal(t, []string{"--all", "--preserve-digests", "--authfile", "/var/lib/kubelet/config.json",
+					"docker://quay.io/org/tool@sha256:3333333333333333333333333333333333333333333333333333333333333333",
+					"docker://localhost:22625/apps/tool:3333333333333333333333333333333333333333333333333333333333333333"}, mirrored[0])
+
+				c := meta.FindStatusCondition(mcn.Status.Conditions, common.MachineConfigNodeIRIAdditionalImagesDegraded)
+				require.NotNil(t, c)
+				assert.Equal(t, metav1.ConditionTrue, c.Status)
+				statuses := common.ParseIRIAdditionalImagesNodeStatus(c.Message)
+				require.Len(t, statuses, 4)
+				assert.Equal(t, common.IRIAdditionalImageNodeStatus{
+					Name:      "catalog",
+					Image:     "olm/catalog@sha256:1111111111111111111111111111111111111111111111111111111111111111",
+					Available: true,
+				}, statuses["catalog"])
+				assert.False(t, statuses["app"].Available)
+				assert.Contains(t, statuses["app"].Message, "manifest unknown")
+				assert.True(t, statuses["tool"].Available)
+				assert.False(t, statuses["broken"].Available)
+				assert.Contains(t, statuses["broken"].Message, "failed to mirror quay.io/org/broken")
+			},
+		},
+		{
+			name:     "corrupted registry content",
+			iri:      iri().withIntegrityScanInterval("24h"),
+			nodeName: "master-0",
+			mcn:      machineConfigNode("master-0"),
+			integrityStatus: &common.IRIIntegrityStatus{
+				VerifiedBlobs: 3,
+				Mismatches: []common.IRIIntegrityMismatch{
+					{Repository: "openshift/release-images", Digest: "sha256:1111111111111111111111111111111111111111111111111111111111111111", Kind: "blob", Message: "content does not match its digest"},
+					{Repository: "openshift/release-images", Digest: "sha256:2222222222222222222222222222222222222222222222222222222222222222", Kind: "blob", Message: "content is missing", Repaired: true, RepairedFrom: "master-1"},
+				},
+			},
+
+			setupRegistry: func(r *FakeIRIRegistry) {
+				r.AddResponse("/v2", http.StatusOK, "{}").
+					AddResponse("/v2/openshift/release-bundles/tags/list", http.StatusOK, `{"name":"openshift/release-bundles","tags":["ocp-release-bundle-4.22.0-0.ci-2026-04-01-050515"]}`).
+					AddResponse("/v2/openshift/release-images/tags/list", http.StatusOK, `{"name":"openshift/release-images","tags":["68bdf24405449be5c78a1f27a7b64fc9ee980e4bc3c9b169e8b3da08e50e0389"]}`).
+					AddResponse("/v2/openshift/release-images/manifests/sha256:68bdf24405449be5c78a1f27a7b64fc9ee980e4bc3c9b169e8b3da08e50e0389", http.StatusOK, "{}")
+			},
+
+			verify: func(t *testing.T, mcn *mcfgv1.MachineConfigNode, registryDataPath string) {
+				verifyCondition(t, mcn.Status.Conditions, string(mcfgv1.MachineConfigNodeInternalReleaseImageDegraded), metav1.ConditionTrue)
+				c := meta.FindStatusCondition(mcn.Status.Conditions, string(mcfgv1.MachineConfigNodeInternalReleaseImageDegraded))
+				assert.Equal(t, "RegistryContentCorrupted", c.Reason)
+				assert.Contains(t, c.Message, "1 manifest(s) or blob(s)")
+				// only the unrepaired content is reported
+				assert.Contains(t, c.Message, "blob openshift/release-images@sha256:1111111111111111111111111111111111111111111111111111111111111111: content does not match its digest")
+				assert.NotContains(t, c.Message, "sha256:2222222222222222222222222222222222222222222222222222222222222222")
+
+				// the release image itself is still available
+				require.Len(t, mcn.Status.InternalReleaseImage.Releases, 1)
+				verifyCondition(t, mcn.Status.InternalReleaseImage.Releases[0].Conditions, string(mcfgv1.InternalReleaseImageConditionTypeAvailable), metav1.ConditionTrue)
+			},
+		},
 		{
 			name:             "registry down",
 			iri:              iri(),
@@ -189,7 +294,10 @@ func TestInternalReleaseImageManager(t *testing.T) {
 				defer fakeRegistry.Close()
 			}
 
-			iriManager := New(tc.nodeName, fakeMCClient, iriInformer, mcnInformer)
+			iriManager := New(tc.nodeName, k8sfake.NewSimpleClientset(), fakeMCClient, iriInformer, mcnInformer)
+			if tc.copyImage != nil {
+				iriManager.copyImage = tc.copyImage
+			}
 			iriManager.registryClient = &http.Client{
 				Transport: &http.Transport{
 					TLSClientConfig: &tls.Config{
@@ -201,6 +309,9 @@ func TestInternalReleaseImageManager(t *testing.T) {
 			// /var/lib/kubelet/config.json (which doesn't exist in unit tests).
 			iriManager.authToken = "dGVzdDp0ZXN0"          // base64("test:test")
 			iriManager.registryDataPath = registryDataPath // Use test directory (may not exist)
+			// Do not start an integrity scan in the background.
+			iriManager.integrityStatus = tc.integrityStatus
+			iriManager.lastIntegrityScan = time.Now()
 			require.NoError(t, iriManager.syncHandler(common.InternalReleaseImageInstanceName))
 
 			if tc.mcn != nil {
@@ -212,6 +323,73 @@ func TestInternalReleaseImageManager(t *testing.T) {
 	}
 }
 
+func TestRegistryIntegrityResync(t *testing.T) {
+	dataPath := t.TempDir()
+	rs := newRegistryStorage(t, dataPath)
+
+	config := rs.addBlob("config")
+	corruptedLayer := digest.FromString("layer")
+	rs.writeBlob(corruptedLayer, "bit rot")
+	missingLayer := digest.FromString("missing layer")
+	unavailableLayer := digest.FromString("unavailable layer")
+	rs.addManifest("openshift/release-images", config, corruptedLayer, missingLayer, unavailableLayer)
+
+	// master-1 is the only peer running the registry, and is reached through
+	// the fake registry.
+	fakeRegistry := NewFakeIRIRegistry()
+	fakeRegistry.AddResponse("/v2/openshift/release-images/blobs/"+corruptedLayer.String(), http.StatusOK, "layer").
+		AddResponse("/v2/openshift/release-images/blobs/"+missingLayer.String(), http.StatusOK, "missing layer").
+		AddResponse("/v2/openshift/release-images/blobs/"+unavailableLayer.String(), http.StatusOK, "corrupted on the peer too")
+	require.NoError(t, fakeRegistry.Start())
+	defer fakeRegistry.Close()
+
+	peerMCN := machineConfigNode("master-1").withIRIBundle("ocp-release-bundle-4.22.0-0.ci-2026-04-01-050515", "localhost:22625/openshift/release-images@sha256:68bdf24405449be5c78a1f27a7b64fc9ee980e4bc3c9b169e8b3da08e50e0389").build()
+	workerMCN := machineConfigNode("worker-0").build()
+	peerNode := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "master-1"},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "127.0.0.1"}},
+		},
+	}
+
+	fakeMCClient := fake.NewClientset(machineConfigNode("master-0").build(), peerMCN, workerMCN)
+	mcInformerFactory := mcfginformers.NewSharedInformerFactory(fakeMCClient, 0)
+	iriManager := New("master-0", k8sfake.NewSimpleClientset(peerNode), fakeMCClient,
+		mcInformerFactory.Machineconfiguration().V1().InternalReleaseImages(),
+		mcInformerFactory.Machineconfiguration().V1().MachineConfigNodes())
+	iriManager.peerRegistryClient = &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+	iriManager.authToken = "dGVzdDp0ZXN0"
+	iriManager.registryDataPath = dataPath
+
+	status := iriManager.scanRegistryIntegrity(true)
+	require.Empty(t, status.Error)
+	require.Len(t, status.Mismatches, 3)
+
+	repaired := map[string]common.IRIIntegrityMismatch{}
+	for _, m := range status.Mismatches {
+		repaired[m.Digest] = m
+	}
+	assert.True(t, repaired[corruptedLayer.String()].Repaired)
+	assert.Equal(t, "master-1", repaired[corruptedLayer.String()].RepairedFrom)
+	assert.True(t, repaired[missingLayer.String()].Repaired)
+	assert.False(t, repaired[unavailableLayer.String()].Repaired)
+	assert.Len(t, status.Unrepaired(), 1)
+
+	assert.Equal(t, "layer", rs.readBlob(corruptedLayer))
+	assert.Equal(t, "missing layer", rs.readBlob(missingLayer))
+
+	// the next scan only reports the content that could not be repaired
+	status = iriManager.scanRegistryIntegrity(false)
+	require.Len(t, status.Mismatches, 1)
+	assert.Equal(t, unavailableLayer.String(), status.Mismatches[0].Digest)
+}
+
 func verifyCondition(t *testing.T, conditions []metav1.Condition, eCondType string, eCondStatus metav1.ConditionStatus) {
 	t.Helper()
 	for _, c := range conditions {
@@ -0,0 +1,317 @@
+package internalreleaseimage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/opencontainers/go-digest"
+	"golang.org/x/time/rate"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+const (
+	// registryStoragePath is the location of the registry content relative to
+	// the registry data path, as laid out by the distribution filesystem driver.
+	registryStoragePath = "docker/registry/v2"
+
+	integrityKindManifest = "manifest"
+	integrityKindBlob     = "blob"
+
+	// integrityScanReadRate limits how fast the scan reads blobs from disk,
+	// in bytes per second, so that it does not starve the registry and the
+	// other workloads of the control plane node.
+	integrityScanReadRate = 64 << 20
+	// integrityScanReadBurst is the largest read of the scan.
+	integrityScanReadBurst = 1 << 20
+)
+
+var (
+	errBlobMissing        = errors.New("content is missing")
+	errBlobDigestMismatch = errors.New("content does not match its digest")
+)
+
+// registryManifest holds the fields of an image manifest or index needed to
+// find the content it references.
+type registryManifest struct {
+	Config *registryDescriptor  `json:"config,omitempty"`
+	Layers []registryDescriptor `json:"layers,omitempty"`
+}
+
+type registryDescriptor struct {
+	Digest digest.Digest `json:"digest"`
+	// URLs is set for foreign layers, which are not stored in the registry.
+	URLs []string `json:"urls,omitempty"`
+}
+
+// iriIntegrityScanner verifies the content stored in the IRI registry by
+// walking the manifests of each repository on disk and checking the digest of
+// every manifest and blob they reference. Index children are manifests of the
+// same repository, so they are verified when their revision is walked.
+type iriIntegrityScanner struct {
+	root string
+	// limiter limits the rate at which blobs are read
+	limiter *rate.Limiter
+}
+
+func newIRIIntegrityScanner(registryDataPath string) *iriIntegrityScanner {
+	return &iriIntegrityScanner{
+		root:    filepath.Join(registryDataPath, registryStoragePath),
+		limiter: rate.NewLimiter(integrityScanReadRate, integrityScanReadBurst),
+	}
+}
+
+// scan returns the result of the verification of the whole registry storage.
+// Content shared across repositories is verified once, and reported for the
+// first repository referencing it.
+func (s *iriIntegrityScanner) scan(ctx context.Context) (*common.IRIIntegrityStatus, error) {
+	status := &common.IRIIntegrityStatus{}
+	// results of the verified content, by digest
+	verified := map[digest.Digest]error{}
+
+	check := func(repo string, dgst digest.Digest, kind string) ([]byte, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// Manifests are small and their content is needed, so they are read
+		// again; blobs are only hashed once per scan.
+		var content []byte
+		err, ok := verified[dgst]
+		if kind == integrityKindManifest {
+			content, err = s.readBlob(dgst)
+		} else if !ok {
+			err = s.verifyBlob(ctx, dgst)
+		}
+		if ok {
+			return content, err
+		}
+
+		verified[dgst] = err
+		if errors.Is(err, errBlobMissing) || errors.Is(err, errBlobDigestMismatch) {
+			klog.Errorf("IRI registry %s %s of %s failed verification: %v", kind, dgst, repo, err)
+			status.Mismatches = append(status.Mismatches, common.IRIIntegrityMismatch{
+				Repository: repo,
+				Digest:     dgst.String(),
+				Kind:       kind,
+				Message:    err.Error(),
+			})
+		} else if err != nil {
+			klog.Warningf("Could not verify IRI registry %s %s of %s: %v", kind, dgst, repo, err)
+		}
+		return content, err
+	}
+
+	repositoriesDir := filepath.Join(s.root, "repositories")
+	err := filepath.WalkDir(repositoriesDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == repositoriesDir {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		switch d.Name() {
+		case "_layers", "_uploads":
+			return fs.SkipDir
+		case "_manifests":
+		default:
+			return nil
+		}
+
+		repo, err := filepath.Rel(repositoriesDir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		repo = filepath.ToSlash(repo)
+
+		revisions, err := s.listRevisions(path)
+		if err != nil {
+			return fmt.Errorf("failed to list manifests of %s: %w", repo, err)
+		}
+		for _, revision := range revisions {
+			content, err := check(repo, revision, integrityKindManifest)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				continue
+			}
+
+			var m registryManifest
+			if err := json.Unmarshal(content, &m); err != nil {
+				klog.Warningf("Skipping blobs of IRI registry manifest %s of %s: %v", revision, repo, err)
+				continue
+			}
+			descriptors := m.Layers
+			if m.Config != nil {
+				descriptors = append(descriptors, *m.Config)
+			}
+			for _, desc := range descriptors {
+				if len(desc.URLs) > 0 || desc.Digest == "" {
+					continue
+				}
+				if _, err := check(repo, desc.Digest, integrityKindBlob); err != nil && ctx.Err() != nil {
+					return ctx.Err()
+				}
+			}
+		}
+		return fs.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	status.VerifiedBlobs = len(verified)
+	sort.Slice(status.Mismatches, func(a, b int) bool {
+		if status.Mismatches[a].Repository != status.Mismatches[b].Repository {
+			return status.Mismatches[a].Repository < status.Mismatches[b].Repository
+		}
+		return status.Mismatches[a].Digest < status.Mismatches[b].Digest
+	})
+	return status, nil
+}
+
+// listRevisions returns the digests of the manifests stored in a repository.
+func (s *iriIntegrityScanner) listRevisions(manifestsDir string) ([]digest.Digest, error) {
+	algDir := filepath.Join(manifestsDir, "revisions", string(digest.SHA256))
+	entries, err := os.ReadDir(algDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []digest.Digest{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dgst := digest.NewDigestFromEncoded(digest.SHA256, entry.Name())
+		if dgst.Validate() != nil {
+			continue
+		}
+		// Deleted manifests keep their revision directory without a link.
+		if _, err := os.Stat(filepath.Join(algDir, entry.Name(), "link")); err != nil {
+			continue
+		}
+		revisions = append(revisions, dgst)
+	}
+	return revisions, nil
+}
+
+func (s *iriIntegrityScanner) blobPath(dgst digest.Digest) string {
+	encoded := dgst.Encoded()
+	return filepath.Join(s.root, "blobs", string(dgst.Algorithm()), encoded[:2], encoded, "data")
+}
+
+// verifyBlob streams the blob from disk, at a limited rate, and checks it
+// against its digest.
+func (s *iriIntegrityScanner) verifyBlob(ctx context.Context, dgst digest.Digest) error {
+	if err := dgst.Validate(); err != nil {
+		return fmt.Errorf("invalid digest %q: %w", dgst, err)
+	}
+	f, err := os.Open(s.blobPath(dgst))
+	if errors.Is(err, fs.ErrNotExist) {
+		return errBlobMissing
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	verifier := dgst.Verifier()
+	if _, err := io.Copy(verifier, &rateLimitedReader{ctx: ctx, r: f, limiter: s.limiter}); err != nil {
+		return fmt.Errorf("failed to read %s: %w", dgst, err)
+	}
+	if !verifier.Verified() {
+		return errBlobDigestMismatch
+	}
+	return nil
+}
+
+// rateLimitedReader limits the rate at which it reads from r.
+type rateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > r.limiter.Burst() {
+		p = p[:r.limiter.Burst()]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// readBlob returns the content of a small blob, such as a manifest, after
+// checking it against its digest.
+func (s *iriIntegrityScanner) readBlob(dgst digest.Digest) ([]byte, error) {
+	if err := dgst.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid digest %q: %w", dgst, err)
+	}
+	content, err := os.ReadFile(s.blobPath(dgst))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errBlobMissing
+	}
+	if err != nil {
+		return nil, err
+	}
+	if digest.FromBytes(content) != dgst {
+		return nil, errBlobDigestMismatch
+	}
+	return content, nil
+}
+
+// replaceBlob atomically replaces the content of a blob with the content read
+// from r, which must match the digest.
+func (s *iriIntegrityScanner) replaceBlob(dgst digest.Digest, r io.Reader) error {
+	if err := dgst.Validate(); err != nil {
+		return fmt.Errorf("invalid digest %q: %w", dgst, err)
+	}
+	path := s.blobPath(dgst)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".data-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	verifier := dgst.Verifier()
+	if _, err := io.Copy(io.MultiWriter(tmp, verifier), r); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dgst, err)
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("fetched %w", errBlobDigestMismatch)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
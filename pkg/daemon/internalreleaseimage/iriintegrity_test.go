@@ -0,0 +1,142 @@
+package internalreleaseimage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+// registryStorage simplifies the creation of the on-disk content of a registry
+// in the test.
+type registryStorage struct {
+	t    *testing.T
+	root string
+}
+
+func newRegistryStorage(t *testing.T, registryDataPath string) *registryStorage {
+	return &registryStorage{t: t, root: filepath.Join(registryDataPath, registryStoragePath)}
+}
+
+// addBlob stores the content as a blob and returns its digest.
+func (rs *registryStorage) addBlob(content string) digest.Digest {
+	dgst := digest.FromString(content)
+	rs.writeBlob(dgst, content)
+	return dgst
+}
+
+// writeBlob stores the content under the given digest, even if it does not match.
+func (rs *registryStorage) writeBlob(dgst digest.Digest, content string) {
+	path := filepath.Join(rs.root, "blobs", "sha256", dgst.Encoded()[:2], dgst.Encoded(), "data")
+	require.NoError(rs.t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(rs.t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+// addManifest stores an image manifest referencing the given blobs in the
+// repository and returns the manifest content and digest.
+func (rs *registryStorage) addManifest(repo string, config digest.Digest, layers ...digest.Digest) (string, digest.Digest) {
+	layersJSON := []string{}
+	for _, l := range layers {
+		layersJSON = append(layersJSON, fmt.Sprintf(`{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","digest":%q,"size":1}`, l))
+	}
+	content := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":%q,"size":1},"layers":[%s]}`,
+		config, strings.Join(layersJSON, ","))
+	dgst := rs.addBlob(content)
+	rs.linkManifest(repo, dgst)
+	return content, dgst
+}
+
+// linkManifest adds the manifest revision to the repository.
+func (rs *registryStorage) linkManifest(repo string, dgst digest.Digest) {
+	path := filepath.Join(rs.root, "repositories", repo, "_manifests", "revisions", "sha256", dgst.Encoded(), "link")
+	require.NoError(rs.t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(rs.t, os.WriteFile(path, []byte(dgst.String()), 0o644))
+}
+
+func (rs *registryStorage) readBlob(dgst digest.Digest) string {
+	content, err := os.ReadFile(filepath.Join(rs.root, "blobs", "sha256", dgst.Encoded()[:2], dgst.Encoded(), "data"))
+	require.NoError(rs.t, err)
+	return string(content)
+}
+
+func TestIRIIntegrityScanner(t *testing.T) {
+	t.Run("empty registry", func(t *testing.T) {
+		scanner := newIRIIntegrityScanner(t.TempDir())
+
+		status, err := scanner.scan(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0, status.VerifiedBlobs)
+		assert.Empty(t, status.Mismatches)
+	})
+
+	t.Run("mismatches", func(t *testing.T) {
+		dataPath := t.TempDir()
+		rs := newRegistryStorage(t, dataPath)
+
+		config := rs.addBlob("config")
+		sharedLayer := rs.addBlob("shared layer")
+		corruptedLayer := digest.FromString("layer")
+		rs.writeBlob(corruptedLayer, "bit rot")
+		missingLayer := digest.FromString("missing layer")
+		rs.addManifest("openshift/release-images", config, sharedLayer, corruptedLayer)
+		rs.addManifest("olm/catalog", config, sharedLayer, missingLayer)
+
+		// the manifest is corrupted, so its blobs cannot be walked
+		corruptedManifest := digest.FromString("manifest")
+		rs.writeBlob(corruptedManifest, "{}")
+		rs.linkManifest("openshift/release-bundles", corruptedManifest)
+
+		// deleted manifests are skipped
+		require.NoError(t, os.MkdirAll(filepath.Join(dataPath, registryStoragePath, "repositories", "openshift/release-bundles", "_manifests", "revisions", "sha256", digest.FromString("deleted").Encoded()), 0o755))
+
+		status, err := newIRIIntegrityScanner(dataPath).scan(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 7, status.VerifiedBlobs)
+		assert.Equal(t, []common.IRIIntegrityMismatch{
+			{Repository: "olm/catalog", Digest: missingLayer.String(), Kind: integrityKindBlob, Message: errBlobMissing.Error()},
+			{Repository: "openshift/release-bundles", Digest: corruptedManifest.String(), Kind: integrityKindManifest, Message: errBlobDigestMismatch.Error()},
+			{Repository: "openshift/release-images", Digest: corruptedLayer.String(), Kind: integrityKindBlob, Message: errBlobDigestMismatch.Error()},
+		}, status.Mismatches)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		dataPath := t.TempDir()
+		rs := newRegistryStorage(t, dataPath)
+		rs.addManifest("openshift/release-images", rs.addBlob("config"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := newIRIIntegrityScanner(dataPath).scan(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestIRIIntegrityScannerReplaceBlob(t *testing.T) {
+	dataPath := t.TempDir()
+	rs := newRegistryStorage(t, dataPath)
+	scanner := newIRIIntegrityScanner(dataPath)
+
+	layer := digest.FromString("layer")
+	rs.writeBlob(layer, "bit rot")
+
+	err := scanner.replaceBlob(layer, strings.NewReader("still bit rot"))
+	assert.ErrorIs(t, err, errBlobDigestMismatch)
+	assert.Equal(t, "bit rot", rs.readBlob(layer))
+
+	require.NoError(t, scanner.replaceBlob(layer, strings.NewReader("layer")))
+	assert.Equal(t, "layer", rs.readBlob(layer))
+	assert.NoError(t, scanner.verifyBlob(context.Background(), layer))
+
+	// no temporary file is left behind
+	entries, err := os.ReadDir(filepath.Dir(scanner.blobPath(layer)))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
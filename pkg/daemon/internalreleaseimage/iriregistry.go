@@ -8,7 +8,9 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/exec"
 	"regexp"
+	"strings"
 
 	"k8s.io/klog/v2"
 
@@ -21,6 +23,11 @@ const (
 
 	ocpReleasesRepo = "/openshift/release-images"
 	ocpBundlesRepo  = "/openshift/release-bundles"
+
+	manifestAcceptHeader = "application/vnd.oci.image.index.v1+json, " +
+		"application/vnd.oci.image.manifest.v1+json, " +
+		"application/vnd.docker.distribution.manifest.list.v2+json, " +
+		"application/vnd.docker.distribution.manifest.v2+json"
 )
 
 type iriRegistry struct {
@@ -202,11 +209,7 @@ func (r *iriRegistry) CheckImageAvailability(pullspec string) error {
 	}
 
 	endpoint := fmt.Sprintf("/%s/manifests/%s", repo, digest)
-	resp, err := r.query(endpoint, map[string]string{
-		"Accept": "application/vnd.oci.image.index.v1+json, " +
-			"application/vnd.oci.image.manifest.v1+json, " +
-			"application/vnd.docker.distribution.manifest.list.v2+json, " +
-			"application/vnd.docker.distribution.manifest.v2+json"})
+	resp, err := r.query(endpoint, map[string]string{"Accept": manifestAcceptHeader})
 	if err != nil {
 		return fmt.Errorf("error while checking image availability for %s: %w", endpoint, err)
 	}
@@ -214,3 +217,30 @@ func (r *iriRegistry) CheckImageAvailability(pullspec string) error {
 
 	return nil
 }
+
+// FetchContent returns the raw content of a manifest or blob of a repository.
+// The caller must close the returned reader and verify the content.
+func (r *iriRegistry) FetchContent(repo, digest, kind string) (io.ReadCloser, error) {
+	var endpoint string
+	headers := map[string]string{}
+	if kind == integrityKindManifest {
+		endpoint = fmt.Sprintf("/%s/manifests/%s", repo, digest)
+		headers["Accept"] = manifestAcceptHeader
+	} else {
+		endpoint = fmt.Sprintf("/%s/blobs/%s", repo, digest)
+	}
+
+	resp, err := r.query(endpoint, headers)
+	if err != nil {
+		return nil, fmt.Errorf("error while fetching %s %s from %s: %w", kind, digest, r.registryHostPort, err)
+	}
+	return resp.Body, nil
+}
+
+func skopeoCopy(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "skopeo", append([]string{"copy", "--quiet"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("skopeo copy failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -2,178 +2,498 @@ package daemon
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
+	"regexp"
+	"slices"
 	"strings"
 
 	// Enable sha256 in container image references
 	_ "crypto/sha256"
 
+	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	"github.com/openshift/machine-config-operator/pkg/daemon/osrelease"
 	"github.com/openshift/machine-config-operator/pkg/daemon/pivot/types"
-	"k8s.io/klog/v2"
+	"github.com/openshift/machine-config-operator/pkg/helpers"
+	"github.com/openshift/machine-config-operator/pkg/upgrademonitor"
 )
 
 const (
 	// KernelTuningFile is a path to the file containing kernel arg changes for tuning
 	KernelTuningFile = "/etc/pivot/kernel-args"
+	// TunableKernelArgsFile is a path to the file extending the kernel
+	// arguments which may be tuned. It holds whitespace separated entries:
+	// "key" allows the bare argument, "key=*" allows any value and "key=a|b"
+	// allows the listed values.
+	TunableKernelArgsFile = "/etc/pivot/tunable-kernel-args"
 	// CmdLineFile is a path to file with kernel cmdline
 	CmdLineFile = "/proc/cmdline"
+
+	// anyKernelArgValue allows any value for a tunable kernel argument
+	anyKernelArgValue = "*"
 )
 
-// TODO: fill out the allowlists
-// tuneableRHCOSArgsAllowlist contains allowed keys for tunable kernel arguments on RHCOS
-var tuneableRHCOSArgsAllowlist = map[string]bool{
-	"nosmt": true,
+var (
+	kernelArgKeyRegexp   = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	kernelArgValueRegexp = regexp.MustCompile(`^[^\s"'\\]+$`)
+)
+
+// tunableKernelArg is a kernel argument which may be tuned through the
+// kernel tuning file. An argument without values is a bare argument.
+type tunableKernelArg struct {
+	key    string
+	values []string
 }
 
-// isArgTuneable returns if the argument provided is allowed to be modified
-func isArgTunable(arg string) (bool, error) {
-	os, err := osrelease.GetHostRunningOS()
-	if err != nil {
-		return false, fmt.Errorf("failed to get OS for determining whether kernel arg is tuneable: %w", err)
+// tuneableRHCOSArgsAllowlist contains the kernel arguments which can be tuned
+// on RHCOS by default. Cluster admins extend it with the
+// TunableKernelArgsFile, written through a MachineConfig.
+var tuneableRHCOSArgsAllowlist = []tunableKernelArg{
+	{key: "nosmt"},
+	{key: "mitigations", values: []string{"off", "auto", "auto,nosmt"}},
+	{key: "audit", values: []string{"0", "1"}},
+	{key: "nohz", values: []string{"on", "off"}},
+	{key: "skew_tick", values: []string{"0", "1"}},
+	{key: "tsx", values: []string{"on", "off", "auto"}},
+}
+
+// parseTuneArgument validates the syntax of a kernel argument.
+func parseTuneArgument(arg string) (types.TuneArgument, error) {
+	key, value, hasValue := strings.Cut(arg, "=")
+	if !kernelArgKeyRegexp.MatchString(key) {
+		return types.TuneArgument{}, fmt.Errorf("invalid kernel argument name %q", key)
+	}
+	if !hasValue {
+		return types.TuneArgument{Key: key, Bare: true}, nil
+	}
+	if !kernelArgValueRegexp.MatchString(value) {
+		return types.TuneArgument{}, fmt.Errorf("invalid value %q for kernel argument %q", value, key)
+	}
+	return types.TuneArgument{Key: key, Value: value}, nil
+}
+
+// tuneArgumentString returns the kernel argument as passed to rpm-ostree.
+func tuneArgumentString(arg types.TuneArgument) string {
+	if arg.Bare {
+		return arg.Key
+	}
+	return arg.Key + "=" + arg.Value
+}
+
+// parseTunableKernelArgs parses the entries of the TunableKernelArgsFile.
+// Invalid entries are skipped so that a typo doesn't block updates.
+func parseTunableKernelArgs(entries string) []tunableKernelArg {
+	args := []tunableKernelArg{}
+	for _, entry := range strings.Fields(entries) {
+		key, values, hasValues := strings.Cut(entry, "=")
+		if !kernelArgKeyRegexp.MatchString(key) {
+			klog.Warningf("Skipping tunable kernel argument %q: invalid name", entry)
+			continue
+		}
+		arg := tunableKernelArg{key: key}
+		if hasValues {
+			arg.values = strings.Split(values, "|")
+			if slices.ContainsFunc(arg.values, func(v string) bool { return !kernelArgValueRegexp.MatchString(v) }) {
+				klog.Warningf("Skipping tunable kernel argument %q: invalid value", entry)
+				continue
+			}
+		}
+		args = append(args, arg)
+	}
+	return args
+}
+
+// kernelTuningPolicy determines which kernel arguments can be tuned.
+type kernelTuningPolicy struct {
+	// allowAll is set on FCOS, which allows any argument
+	allowAll  bool
+	allowlist []tunableKernelArg
+}
+
+// newKernelTuningPolicy returns the policy for the given OS, extended with
+// the entries of the TunableKernelArgsFile.
+func newKernelTuningPolicy(hostOS osrelease.OperatingSystem, extraArgs string) *kernelTuningPolicy {
+	policy := &kernelTuningPolicy{allowAll: hostOS.IsFCOS()}
+	if hostOS.IsEL() {
+		policy.allowlist = append(policy.allowlist, tuneableRHCOSArgsAllowlist...)
 	}
+	policy.allowlist = append(policy.allowlist, parseTunableKernelArgs(extraArgs)...)
+	return policy
+}
 
-	if os.IsEL() {
-		return tuneableRHCOSArgsAllowlist[arg], nil
-	} else if os.IsFCOS() {
-		return true, nil
+// check returns an error if the argument is not allowed to be modified.
+func (p *kernelTuningPolicy) check(arg types.TuneArgument) error {
+	if p.allowAll {
+		return nil
+	}
+	for _, allowed := range p.allowlist {
+		if allowed.key != arg.Key {
+			continue
+		}
+		if arg.Bare && len(allowed.values) == 0 {
+			return nil
+		}
+		if !arg.Bare && (slices.Contains(allowed.values, arg.Value) || slices.Contains(allowed.values, anyKernelArgValue)) {
+			return nil
+		}
 	}
-	return false, nil
+	return fmt.Errorf("%s is not an allowlisted kernel argument", tuneArgumentString(arg))
+}
+
+// kernelTuningArg is a kernel argument requested in the kernel tuning file.
+type kernelTuningArg struct {
+	Operation string
+	Argument  string
+	Reason    string
 }
 
-// isArgInUse checks to see if the argument is already in use by the system currently
-func isArgInUse(arg, cmdLinePath string) (bool, error) {
+func (a kernelTuningArg) String() string {
+	if a.Reason == "" {
+		return a.Operation + " " + a.Argument
+	}
+	return fmt.Sprintf("%s %s (%s)", a.Operation, a.Argument, a.Reason)
+}
+
+// kernelTuningStatus is the result of the kernel tuning reported by the
+// daemon on its MachineConfigNode.
+type kernelTuningStatus struct {
+	// arguments which are in effect, including those which already were
+	Applied []kernelTuningArg
+	// arguments refused by the kernel tuning policy
+	Rejected []kernelTuningArg
+	// allowed arguments which could not be applied to the current arguments
+	NotApplied []kernelTuningArg
+}
+
+// requested returns whether the tuning file requested any argument.
+func (s *kernelTuningStatus) requested() bool {
+	return len(s.Applied) > 0 || len(s.Rejected) > 0 || len(s.NotApplied) > 0
+}
+
+// kernelTuningChanges are the kernel argument changes applying the tuning
+// file.
+type kernelTuningChanges struct {
+	additions    []types.TuneArgument
+	replacements []types.TuneArgument
+	deletions    []types.TuneArgument
+}
+
+// readCmdLineArgs returns the kernel arguments the system booted with.
+func readCmdLineArgs(cmdLinePath string) ([]types.TuneArgument, error) {
 	if cmdLinePath == "" {
 		cmdLinePath = CmdLineFile
 	}
 	content, err := os.ReadFile(cmdLinePath)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	checkable := strings.TrimSpace(string(content))
-	if strings.Contains(" "+checkable+" ", " "+arg+" ") {
-		return true, nil
+	args := []types.TuneArgument{}
+	for _, field := range strings.Fields(string(content)) {
+		key, value, hasValue := strings.Cut(field, "=")
+		args = append(args, types.TuneArgument{Key: key, Value: value, Bare: !hasValue})
 	}
-	return false, nil
+	return args, nil
 }
 
-func parseTuningFile(tuningFilePath, cmdLinePath string) ([]types.TuneArgument, []types.TuneArgument, error) {
-	addArguments := []types.TuneArgument{}
-	deleteArguments := []types.TuneArgument{}
+// planTuneArgument determines the change applying a requested argument to
+// the current arguments. It returns a reason if the argument cannot be
+// applied.
+func planTuneArgument(operation string, arg types.TuneArgument, current []types.TuneArgument, changes *kernelTuningChanges) string {
+	sameKey := []types.TuneArgument{}
+	for _, c := range current {
+		if c.Key == arg.Key {
+			sameKey = append(sameKey, c)
+		}
+	}
+	inUse := slices.Contains(sameKey, arg)
+
+	switch {
+	case operation == "ADD" && inUse:
+		klog.Infof(`skipping "%s" as it is already in use`, tuneArgumentString(arg))
+	case operation == "ADD" && arg.Bare, operation == "ADD" && len(sameKey) == 0:
+		changes.additions = append(changes.additions, arg)
+	case operation == "ADD" && len(sameKey) == 1 && !sameKey[0].Bare:
+		// an argument set to another value is replaced rather than
+		// appended again
+		changes.replacements = append(changes.replacements, arg)
+	case operation == "ADD":
+		return fmt.Sprintf("%s is set more than once", arg.Key)
+	case inUse:
+		changes.deletions = append(changes.deletions, arg)
+	case len(sameKey) > 0:
+		// deleting an argument set to another value would leave the other
+		// value in effect
+		others := []string{}
+		for _, c := range sameKey {
+			others = append(others, tuneArgumentString(c))
+		}
+		return fmt.Sprintf("the current argument is %s", strings.Join(others, " "))
+	default:
+		klog.Infof(`skipping "%s" as it is not present in the current argument list`, tuneArgumentString(arg))
+	}
+	return ""
+}
+
+func parseTuningFile(tuningFilePath, cmdLinePath string, policy *kernelTuningPolicy) (*kernelTuningChanges, *kernelTuningStatus, error) {
+	changes := &kernelTuningChanges{}
+	status := &kernelTuningStatus{}
 	if tuningFilePath == "" {
 		tuningFilePath = KernelTuningFile
 	}
-	if cmdLinePath == "" {
-		cmdLinePath = CmdLineFile
-	}
 	// Read and parse the file
 	file, err := os.Open(tuningFilePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// It's ok if the file doesn't exist
-			return addArguments, deleteArguments, nil
+			return changes, status, nil
 		}
-		return addArguments, deleteArguments, fmt.Errorf("reading %s: %w", tuningFilePath, err)
+		return changes, status, fmt.Errorf("reading %s: %w", tuningFilePath, err)
 	}
 	// Clean up
 	defer file.Close()
 
+	current, err := readCmdLineArgs(cmdLinePath)
+	if err != nil {
+		return changes, status, err
+	}
+
 	// Parse the tuning lines
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := scanner.Text()
-		switch {
-		case strings.HasPrefix(line, "ADD "):
-			// NOTE: Today only specific bare kernel arguments are allowed so
-			// there is not a need to split on =.
-			key := strings.TrimSpace(line[len("ADD "):])
-			tuneableKarg, err := isArgTunable(key)
-			if err != nil {
-				return addArguments, deleteArguments, err
-			}
-			if tuneableKarg {
-				// Find out if the argument is in use
-				inUse, err := isArgInUse(key, cmdLinePath)
-				if err != nil {
-					return addArguments, deleteArguments, err
-				}
-				if !inUse {
-					addArguments = append(addArguments, types.TuneArgument{Key: key, Bare: true})
-				} else {
-					klog.Infof(`skipping "%s" as it is already in use`, key)
-				}
-			} else {
-				klog.Infof("%s not an allowlisted kernel argument", key)
-			}
-		case strings.HasPrefix(line, "DELETE "):
-			// NOTE: Today only specific bare kernel arguments are allowed so
-			// there is not a need to split on =.
-			key := strings.TrimSpace(line[len("DELETE "):])
-			tuneableKarg, err := isArgTunable(key)
-			if err != nil {
-				return addArguments, deleteArguments, err
-			}
-			if tuneableKarg {
-				inUse, err := isArgInUse(key, cmdLinePath)
-				if err != nil {
-					return addArguments, deleteArguments, err
-				}
-				if inUse {
-					deleteArguments = append(deleteArguments, types.TuneArgument{Key: key, Bare: true})
-				} else {
-					klog.Infof(`skipping "%s" as it is not present in the current argument list`, key)
-				}
-			} else {
-				klog.Infof("%s not an allowlisted kernel argument", key)
-			}
-		default:
+		operation, arg, ok := strings.Cut(line, " ")
+		if !ok || (operation != "ADD" && operation != "DELETE") {
 			klog.V(2).Infof(`skipping malformed line in %s: "%s"`, tuningFilePath, line)
+			continue
+		}
+		requested := kernelTuningArg{Operation: operation, Argument: strings.TrimSpace(arg)}
+
+		tuneArg, err := parseTuneArgument(requested.Argument)
+		if err == nil {
+			err = policy.check(tuneArg)
+		}
+		if err != nil {
+			klog.Infof("Rejecting kernel tuning argument: %v", err)
+			requested.Reason = err.Error()
+			status.Rejected = append(status.Rejected, requested)
+			continue
+		}
+
+		if reason := planTuneArgument(operation, tuneArg, current, changes); reason != "" {
+			klog.Infof("Not applying kernel tuning argument %s: %s", requested, reason)
+			requested.Reason = reason
+			status.NotApplied = append(status.NotApplied, requested)
+			continue
 		}
+		status.Applied = append(status.Applied, requested)
 	}
-	return addArguments, deleteArguments, nil
+	return changes, status, nil
 }
 
-// UpdateTuningArgs executes additions and removals of kernel tuning arguments
-func UpdateTuningArgs(tuningFilePath, cmdLinePath string) error {
-	if cmdLinePath == "" {
-		cmdLinePath = CmdLineFile
-	}
-	changed := false
-	additions, deletions, err := parseTuningFile(tuningFilePath, cmdLinePath)
+// updateTuningArgs executes additions, replacements and removals of kernel
+// tuning arguments
+func updateTuningArgs(runner CommandRunner, tuningFilePath, cmdLinePath string, policy *kernelTuningPolicy) (*kernelTuningStatus, error) {
+	changes, status, err := parseTuningFile(tuningFilePath, cmdLinePath, policy)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Execute additions
-	for _, toAdd := range additions {
-		if toAdd.Bare {
-			changed = true
-			err := exec.Command("rpm-ostree", "kargs", fmt.Sprintf("--append=%s", toAdd.Key)).Run()
-			if err != nil {
-				return fmt.Errorf("failed adding karg: %w", err)
-			}
-		} else {
-			panic("Not supported")
+	for _, toAdd := range changes.additions {
+		if _, err := runner.RunGetOut("rpm-ostree", "kargs", fmt.Sprintf("--append=%s", tuneArgumentString(toAdd))); err != nil {
+			return nil, fmt.Errorf("failed adding karg: %w", err)
+		}
+	}
+	// Execute replacements
+	for _, toReplace := range changes.replacements {
+		if _, err := runner.RunGetOut("rpm-ostree", "kargs", fmt.Sprintf("--replace=%s", tuneArgumentString(toReplace))); err != nil {
+			return nil, fmt.Errorf("failed replacing karg: %w", err)
 		}
 	}
 	// Execute deletions
-	for _, toDelete := range deletions {
-		if toDelete.Bare {
-			changed = true
-			err := exec.Command("rpm-ostree", "kargs", fmt.Sprintf("--delete=%s", toDelete.Key)).Run()
-			if err != nil {
-				return fmt.Errorf("failed deleting karg: %w", err)
-			}
-		} else {
-			panic("Not supported")
+	for _, toDelete := range changes.deletions {
+		if _, err := runner.RunGetOut("rpm-ostree", "kargs", fmt.Sprintf("--delete=%s", tuneArgumentString(toDelete))); err != nil {
+			return nil, fmt.Errorf("failed deleting karg: %w", err)
 		}
 	}
 
-	if changed {
+	if len(changes.additions) > 0 || len(changes.replacements) > 0 || len(changes.deletions) > 0 {
 		klog.Info("Updated kernel tuning arguments")
 	}
+	return status, nil
+}
+
+// getKernelTuningPolicy returns the kernel tuning policy of this node,
+// extended by the tunable kernel arguments file if present.
+func (dn *Daemon) getKernelTuningPolicy() *kernelTuningPolicy {
+	extraArgs, err := os.ReadFile(TunableKernelArgsFile)
+	if err != nil && !os.IsNotExist(err) {
+		klog.Warningf("Could not read tunable kernel arguments: %v", err)
+	}
+	return newKernelTuningPolicy(dn.os, string(extraArgs))
+}
+
+// updateTuningArgs applies the kernel tuning file and reports the result on
+// the MachineConfigNode of this node.
+func (dn *Daemon) updateTuningArgs() error {
+	status, err := updateTuningArgs(dn.cmdRunner, KernelTuningFile, CmdLineFile, dn.getKernelTuningPolicy())
+	if err != nil {
+		return err
+	}
+	if err := dn.reportKernelTuningStatus(status); err != nil {
+		klog.Warningf("Failed to report kernel tuning status: %v", err)
+	}
 	return nil
 }
+
+// reportKernelTuningStatus sets the KernelTuningDegraded condition of the
+// MachineConfigNode of this node. Nodes without a tuning file only clear a
+// condition left by a previous tuning file, so that they don't update their
+// MachineConfigNode on every update.
+func (dn *Daemon) reportKernelTuningStatus(status *kernelTuningStatus) error {
+	if dn.mcfgClient == nil || dn.node == nil {
+		return nil
+	}
+
+	if !status.requested() {
+		isSet, err := dn.isMachineConfigNodeConditionTrue(upgrademonitor.MachineConfigNodeKernelTuningDegraded)
+		if err != nil || !isSet {
+			return err
+		}
+	}
+
+	condition := &upgrademonitor.Condition{
+		State:   upgrademonitor.MachineConfigNodeKernelTuningDegraded,
+		Reason:  "KernelTuningApplied",
+		Message: "All requested kernel tuning arguments are applied",
+	}
+	conditionStatus := metav1.ConditionFalse
+	if failed := append(slices.Clone(status.Rejected), status.NotApplied...); len(failed) > 0 {
+		messages := []string{}
+		for _, arg := range failed {
+			messages = append(messages, arg.String())
+		}
+		condition.Reason = "KernelTuningArgumentsNotApplied"
+		condition.Message = "Kernel tuning arguments not applied: " + strings.Join(messages, "; ")
+		conditionStatus = metav1.ConditionTrue
+	} else if !status.requested() {
+		condition.Message = "No kernel tuning arguments requested"
+	}
+
+	pool, err := helpers.GetPrimaryPoolNameForMCN(dn.mcpLister, dn.node)
+	if err != nil {
+		return err
+	}
+	return upgrademonitor.GenerateAndApplyMachineConfigNodes(
+		condition,
+		nil,
+		conditionStatus,
+		metav1.ConditionFalse,
+		dn.node,
+		dn.mcfgClient,
+		dn.fgHandler,
+		pool,
+	)
+}
+
+// isMachineConfigNodeConditionTrue returns whether the condition is true on
+// the MachineConfigNode of this node.
+func (dn *Daemon) isMachineConfigNodeConditionTrue(state mcfgv1.StateProgress) (bool, error) {
+	mcn, err := dn.mcfgClient.MachineconfigurationV1().MachineConfigNodes().Get(context.TODO(), dn.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return meta.IsStatusConditionTrue(mcn.Status.Conditions, string(state)), nil
+}
+
+// pendingKernelArgs returns the kernel arguments of a MachineConfig which are
+// missing from the booted command line, and the ones it deletes which are
+// still in it. They are staged for the next boot, as kernel argument changes
+// applied live or under an OS node disruption policy don't reboot the node.
+func pendingKernelArgs(cmdline string, kernelArguments []string, ignKargs ign3types.KernelArguments) (missing, unexpected []string) {
+	booted := sets.New(strings.Fields(cmdline)...)
+	for _, arg := range machineConfigKernelArgs(kernelArguments, ignKargs) {
+		if !booted.Has(arg) {
+			missing = append(missing, arg)
+		}
+	}
+	for _, arg := range ignKargs.ShouldNotExist {
+		if booted.Has(string(arg)) {
+			unexpected = append(unexpected, string(arg))
+		}
+	}
+	return missing, unexpected
+}
+
+// reportPendingKernelArgs sets the KernelArgumentsPendingReboot condition of
+// the MachineConfigNode of this node if kernel arguments of the config are
+// staged but not booted. As for kernel tuning, nodes without pending kernel
+// arguments only clear a condition left by a previous update.
+func (dn *Daemon) reportPendingKernelArgs(config *mcfgv1.MachineConfig) error {
+	if dn.mcfgClient == nil || dn.node == nil {
+		return nil
+	}
+
+	cmdline, err := os.ReadFile(CmdLineFile)
+	if err != nil {
+		return err
+	}
+	ignConfig, err := ctrlcommon.ParseAndConvertConfig(config.Spec.Config.Raw)
+	if err != nil {
+		return fmt.Errorf("parsing Ignition config failed with error: %w", err)
+	}
+	missing, unexpected := pendingKernelArgs(string(cmdline), config.Spec.KernelArguments, ignConfig.KernelArguments)
+
+	condition := &upgrademonitor.Condition{
+		State:   upgrademonitor.MachineConfigNodeKernelArgumentsPendingReboot,
+		Reason:  "KernelArgumentsBooted",
+		Message: fmt.Sprintf("The booted kernel arguments match config %s", config.Name),
+	}
+	conditionStatus := metav1.ConditionFalse
+	if len(missing) > 0 || len(unexpected) > 0 {
+		pending := []string{}
+		if len(missing) > 0 {
+			pending = append(pending, "added "+strings.Join(missing, " "))
+		}
+		if len(unexpected) > 0 {
+			pending = append(pending, "deleted "+strings.Join(unexpected, " "))
+		}
+		klog.Infof("Kernel arguments of config %s pending a reboot: %s", config.Name, strings.Join(pending, "; "))
+		condition.Reason = "KernelArgumentsStaged"
+		condition.Message = fmt.Sprintf("Kernel arguments of config %s staged for the next boot: %s", config.Name, strings.Join(pending, "; "))
+		conditionStatus = metav1.ConditionTrue
+	} else {
+		isSet, err := dn.isMachineConfigNodeConditionTrue(upgrademonitor.MachineConfigNodeKernelArgumentsPendingReboot)
+		if err != nil || !isSet {
+			return err
+		}
+	}
+
+	pool, err := helpers.GetPrimaryPoolNameForMCN(dn.mcpLister, dn.node)
+	if err != nil {
+		return err
+	}
+	return upgrademonitor.GenerateAndApplyMachineConfigNodes(
+		condition,
+		nil,
+		conditionStatus,
+		metav1.ConditionFalse,
+		dn.node,
+		dn.mcfgClient,
+		dn.fgHandler,
+		pool,
+	)
+}
@@ -0,0 +1,207 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+)
+
+// liveKernelArg maps a kernel argument to the sysfs or procfs file holding
+// its runtime equivalent.
+type liveKernelArg struct {
+	key  string
+	path string
+	// values maps the argument values to the runtime values, a bare argument
+	// has the empty value. If nil, any value is written as is.
+	values map[string]string
+	// defaultValue is written when the argument is removed. Removing an
+	// argument without a default requires a reboot.
+	defaultValue string
+}
+
+// liveKernelArgsRegistry contains the kernel arguments which can be applied
+// without a reboot when the LiveApplyKernelArgumentsAnnotationKey annotation
+// is set on the MachineConfiguration.
+var liveKernelArgsRegistry = []liveKernelArg{
+	{
+		key:    "transparent_hugepage",
+		path:   "/sys/kernel/mm/transparent_hugepage/enabled",
+		values: map[string]string{"always": "always", "madvise": "madvise", "never": "never"},
+	},
+	{
+		key:          "nosmt",
+		path:         "/sys/devices/system/cpu/smt/control",
+		values:       map[string]string{"": "off"},
+		defaultValue: "on",
+	},
+	{
+		key:          "nmi_watchdog",
+		path:         "/proc/sys/kernel/nmi_watchdog",
+		values:       map[string]string{"0": "0", "1": "1"},
+		defaultValue: "1",
+	},
+	{
+		key:          "panic",
+		path:         "/proc/sys/kernel/panic",
+		defaultValue: "0",
+	},
+}
+
+// liveKernelArgChange is a runtime setting to write.
+type liveKernelArgChange struct {
+	path  string
+	value string
+}
+
+// isLiveKernelArgsEnabled returns whether the cluster opted in to applying
+// kernel arguments without a reboot.
+func isLiveKernelArgsEnabled(mcop *opv1.MachineConfiguration) bool {
+	return mcop != nil && mcop.Annotations[constants.LiveApplyKernelArgumentsAnnotationKey] == "true"
+}
+
+// hasOSNodeDisruptionPolicyAnnotations returns whether any of the TechPreview
+// annotations gated by the OSNodeDisruptionPolicy feature gate is set on the
+// MachineConfiguration.
+func hasOSNodeDisruptionPolicyAnnotations(mcop *opv1.MachineConfiguration) bool {
+	if mcop == nil {
+		return false
+	}
+	_, policy := mcop.Annotations[constants.OSNodeDisruptionPolicyAnnotationKey]
+	_, live := mcop.Annotations[constants.LiveApplyKernelArgumentsAnnotationKey]
+	return policy || live
+}
+
+// machineConfigKernelArgs returns the kernel arguments a MachineConfig adds.
+func machineConfigKernelArgs(kernelArguments []string, ignKargs ign3types.KernelArguments) []string {
+	args := parseKernelArguments(kernelArguments)
+	for _, arg := range ignKargs.ShouldExist {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+// kernelArgKey returns the name of a kernel argument, i.e. the part before "=".
+func kernelArgKey(arg string) string {
+	key, _, _ := strings.Cut(arg, "=")
+	return key
+}
+
+// changedKernelArgKeys returns the sorted names of the kernel arguments which
+// are added, removed or changed between two configs.
+func changedKernelArgKeys(oldKernelArguments, newKernelArguments []string, oldIgnKargs, newIgnKargs ign3types.KernelArguments) []string {
+	oldArgs := machineConfigKernelArgs(oldKernelArguments, oldIgnKargs)
+	newArgs := machineConfigKernelArgs(newKernelArguments, newIgnKargs)
+	for _, arg := range oldIgnKargs.ShouldNotExist {
+		oldArgs = append(oldArgs, "-"+string(arg))
+	}
+	for _, arg := range newIgnKargs.ShouldNotExist {
+		newArgs = append(newArgs, "-"+string(arg))
+	}
+
+	changedKeys := []string{}
+	for _, arg := range oldArgs {
+		if !slices.Contains(newArgs, arg) {
+			changedKeys = append(changedKeys, kernelArgKey(strings.TrimPrefix(arg, "-")))
+		}
+	}
+	for _, arg := range newArgs {
+		if !slices.Contains(oldArgs, arg) {
+			changedKeys = append(changedKeys, kernelArgKey(strings.TrimPrefix(arg, "-")))
+		}
+	}
+	slices.Sort(changedKeys)
+	return slices.Compact(changedKeys)
+}
+
+// planLiveKernelArgs returns the runtime changes equivalent to the kernel
+// argument changes between two configs. It returns false if any change has
+// no runtime equivalent in the registry and so requires a reboot.
+func planLiveKernelArgs(oldKernelArguments, newKernelArguments []string, oldIgnKargs, newIgnKargs ign3types.KernelArguments) ([]liveKernelArgChange, bool) {
+	// Deleting arguments shipped by the base OS is never applied live
+	if !slices.Equal(oldIgnKargs.ShouldNotExist, newIgnKargs.ShouldNotExist) {
+		return nil, false
+	}
+
+	newArgs := machineConfigKernelArgs(newKernelArguments, newIgnKargs)
+	changedKeys := changedKernelArgKeys(oldKernelArguments, newKernelArguments, oldIgnKargs, newIgnKargs)
+
+	changes := []liveKernelArgChange{}
+	for _, key := range changedKeys {
+		idx := slices.IndexFunc(liveKernelArgsRegistry, func(l liveKernelArg) bool { return l.key == key })
+		if idx < 0 {
+			klog.V(4).Infof("Kernel argument %q has no runtime equivalent", key)
+			return nil, false
+		}
+		liveArg := liveKernelArgsRegistry[idx]
+
+		var values []string
+		for _, arg := range newArgs {
+			if kernelArgKey(arg) == key {
+				_, value, _ := strings.Cut(arg, "=")
+				values = append(values, value)
+			}
+		}
+
+		var runtimeValue string
+		switch {
+		case len(values) > 1:
+			return nil, false
+		case len(values) == 0 && liveArg.defaultValue == "":
+			return nil, false
+		case len(values) == 0:
+			runtimeValue = liveArg.defaultValue
+		case liveArg.values == nil:
+			runtimeValue = values[0]
+		default:
+			var ok bool
+			if runtimeValue, ok = liveArg.values[values[0]]; !ok {
+				return nil, false
+			}
+		}
+		changes = append(changes, liveKernelArgChange{path: liveArg.path, value: runtimeValue})
+	}
+	return changes, true
+}
+
+// readRuntimeValue returns the current value of a runtime setting. Settings
+// offering a choice, e.g. "always [madvise] never", return the selected one.
+func readRuntimeValue(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(content))
+	if _, selected, ok := strings.Cut(value, "["); ok {
+		value, _, _ = strings.Cut(selected, "]")
+	}
+	return value, nil
+}
+
+// applyLiveKernelArgs writes the runtime settings, and returns the changes
+// restoring their previous values. If a setting can't be written, the ones
+// written before are restored.
+func applyLiveKernelArgs(changes []liveKernelArgChange) ([]liveKernelArgChange, error) {
+	previous := []liveKernelArgChange{}
+	for _, change := range changes {
+		value, err := readRuntimeValue(change.path)
+		if err == nil && value != change.value {
+			klog.Infof("Applying kernel argument runtime setting %s=%s", change.path, change.value)
+			err = os.WriteFile(change.path, []byte(change.value), 0o644)
+		}
+		if err != nil {
+			if _, restoreErr := applyLiveKernelArgs(previous); restoreErr != nil {
+				klog.Errorf("Failed to restore kernel argument runtime settings: %v", restoreErr)
+			}
+			return nil, fmt.Errorf("failed to apply kernel argument runtime setting %s: %w", change.path, err)
+		}
+		previous = append(previous, liveKernelArgChange{path: change.path, value: value})
+	}
+	return previous, nil
+}
@@ -0,0 +1,126 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanLiveKernelArgs(t *testing.T) {
+	testCases := []struct {
+		name            string
+		oldKargs        []string
+		newKargs        []string
+		oldIgnKargs     ign3types.KernelArguments
+		newIgnKargs     ign3types.KernelArguments
+		expectedChanges []liveKernelArgChange
+		expectedLive    bool
+	}{
+		{
+			name:            "added argument",
+			newKargs:        []string{"transparent_hugepage=never"},
+			expectedChanges: []liveKernelArgChange{{path: "/sys/kernel/mm/transparent_hugepage/enabled", value: "never"}},
+			expectedLive:    true,
+		},
+		{
+			name:            "changed value",
+			oldKargs:        []string{"nmi_watchdog=0 panic=10"},
+			newKargs:        []string{"nmi_watchdog=1 panic=10"},
+			expectedChanges: []liveKernelArgChange{{path: "/proc/sys/kernel/nmi_watchdog", value: "1"}},
+			expectedLive:    true,
+		},
+		{
+			name:            "removed argument with a default",
+			oldKargs:        []string{"nosmt"},
+			expectedChanges: []liveKernelArgChange{{path: "/sys/devices/system/cpu/smt/control", value: "on"}},
+			expectedLive:    true,
+		},
+		{
+			name:         "removed argument without a default",
+			oldKargs:     []string{"transparent_hugepage=never"},
+			expectedLive: false,
+		},
+		{
+			name:            "Ignition shouldExist argument",
+			newIgnKargs:     ign3types.KernelArguments{ShouldExist: []ign3types.KernelArgument{"nosmt"}},
+			expectedChanges: []liveKernelArgChange{{path: "/sys/devices/system/cpu/smt/control", value: "off"}},
+			expectedLive:    true,
+		},
+		{
+			name:         "Ignition shouldNotExist argument",
+			newIgnKargs:  ign3types.KernelArguments{ShouldNotExist: []ign3types.KernelArgument{"nosmt"}},
+			expectedLive: false,
+		},
+		{
+			name:         "argument without runtime equivalent",
+			newKargs:     []string{"nosmt hugepages=4"},
+			expectedLive: false,
+		},
+		{
+			name:         "value without runtime equivalent",
+			newKargs:     []string{"nosmt=force"},
+			expectedLive: false,
+		},
+		{
+			name:         "repeated argument",
+			newKargs:     []string{"panic=10 panic=20"},
+			expectedLive: false,
+		},
+		{
+			name:            "reordered arguments",
+			oldKargs:        []string{"nosmt panic=10"},
+			newKargs:        []string{"panic=10 nosmt"},
+			expectedChanges: []liveKernelArgChange{},
+			expectedLive:    true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			changes, live := planLiveKernelArgs(testCase.oldKargs, testCase.newKargs, testCase.oldIgnKargs, testCase.newIgnKargs)
+			assert.Equal(t, testCase.expectedLive, live)
+			if testCase.expectedLive {
+				assert.Equal(t, testCase.expectedChanges, changes)
+			}
+		})
+	}
+}
+
+func TestApplyLiveKernelArgs(t *testing.T) {
+	testDir := t.TempDir()
+	thpPath := filepath.Join(testDir, "enabled")
+	panicPath := filepath.Join(testDir, "panic")
+	require.NoError(t, os.WriteFile(thpPath, []byte("always [madvise] never\n"), 0o644))
+	require.NoError(t, os.WriteFile(panicPath, []byte("0\n"), 0o644))
+
+	previous, err := applyLiveKernelArgs([]liveKernelArgChange{{path: thpPath, value: "never"}, {path: panicPath, value: "10"}})
+	require.NoError(t, err)
+	assert.Equal(t, []liveKernelArgChange{{path: thpPath, value: "madvise"}, {path: panicPath, value: "0"}}, previous)
+	for path, expected := range map[string]string{thpPath: "never", panicPath: "10"} {
+		actual, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(actual))
+	}
+
+	// Settings written before a failure are restored
+	_, err = applyLiveKernelArgs([]liveKernelArgChange{{path: panicPath, value: "20"}, {path: filepath.Join(testDir, "missing"), value: "1"}})
+	assert.Error(t, err)
+	actual, err := os.ReadFile(panicPath)
+	require.NoError(t, err)
+	assert.Equal(t, "10", string(actual))
+}
+
+func TestLiveKernelArgsNodeDisruptionAction(t *testing.T) {
+	diff := &machineConfigDiff{kargs: true, liveKargs: true}
+	actions := calculatePostConfigChangeNodeDisruptionActionFromDiff(diff, nil, nil, opv1.NodeDisruptionPolicyClusterStatus{}, nil)
+	assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.NoneStatusAction}}, actions)
+
+	diff.liveKargs = false
+	actions = calculatePostConfigChangeNodeDisruptionActionFromDiff(diff, nil, nil, opv1.NodeDisruptionPolicyClusterStatus{}, nil)
+	assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RebootStatusAction}}, actions)
+}
@@ -0,0 +1,170 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/machine-config-operator/pkg/daemon/osrelease"
+	"github.com/openshift/machine-config-operator/pkg/daemon/pivot/types"
+)
+
+func TestKernelTuningPolicy(t *testing.T) {
+	rhcos, err := osrelease.LoadOSRelease("ID=\"rhcos\"\nVERSION_ID=\"9.6\"\n", "")
+	require.NoError(t, err)
+	fcos, err := osrelease.LoadOSRelease("ID=fedora\nVARIANT_ID=coreos\nVERSION_ID=42\n", "")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		os          osrelease.OperatingSystem
+		extraArgs   string
+		arg         string
+		expectedErr string
+	}{
+		{
+			name: "default bare argument",
+			os:   rhcos,
+			arg:  "nosmt",
+		},
+		{
+			name: "default argument with allowed value",
+			os:   rhcos,
+			arg:  "mitigations=auto,nosmt",
+		},
+		{
+			name:        "default argument with other value",
+			os:          rhcos,
+			arg:         "mitigations=on",
+			expectedErr: "mitigations=on is not an allowlisted kernel argument",
+		},
+		{
+			name:        "argument not in the allowlist",
+			os:          rhcos,
+			arg:         "isolcpus=1-3",
+			expectedErr: "isolcpus=1-3 is not an allowlisted kernel argument",
+		},
+		{
+			name:      "argument allowed with any value by the admin",
+			os:        rhcos,
+			extraArgs: "isolcpus=* rcu_nocbs=1-3|2-3",
+			arg:       "isolcpus=1-3",
+		},
+		{
+			name:      "argument allowed with listed values by the admin",
+			os:        rhcos,
+			extraArgs: "isolcpus=* rcu_nocbs=1-3|2-3",
+			arg:       "rcu_nocbs=2-3",
+		},
+		{
+			name:        "bare argument allowed with values only",
+			os:          rhcos,
+			extraArgs:   "rcu_nocbs=1-3",
+			arg:         "rcu_nocbs",
+			expectedErr: "rcu_nocbs is not an allowlisted kernel argument",
+		},
+		{
+			name:        "invalid admin entries are skipped",
+			os:          rhcos,
+			extraArgs:   "-foo bar=\"baz\"",
+			arg:         "bar=\"baz\"",
+			expectedErr: "invalid value",
+		},
+		{
+			name: "any argument on FCOS",
+			os:   fcos,
+			arg:  "isolcpus=1-3",
+		},
+		{
+			name:        "invalid argument syntax",
+			os:          fcos,
+			arg:         "foo bar",
+			expectedErr: `invalid kernel argument name "foo bar"`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			policy := newKernelTuningPolicy(testCase.os, testCase.extraArgs)
+			arg, err := parseTuneArgument(testCase.arg)
+			if err == nil {
+				err = policy.check(arg)
+			}
+			if testCase.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, testCase.expectedErr)
+			}
+		})
+	}
+
+	assert.Len(t, parseTunableKernelArgs("-foo bar=\"baz\" nosmt"), 1)
+}
+
+func TestUpdateTuningArgs(t *testing.T) {
+	testDir := t.TempDir()
+	tuningFile := filepath.Join(testDir, "kernel-args")
+	cmdLineFile := filepath.Join(testDir, "cmdline")
+
+	require.NoError(t, os.WriteFile(cmdLineFile, []byte("root=UUID=1234 audit=1 nosmt tsx=auto nohz=on\n"), 0o644))
+	require.NoError(t, os.WriteFile(tuningFile, []byte(`ADD mitigations=off
+ADD nosmt
+DELETE audit=1
+DELETE skew_tick=1
+ADD isolcpus=1-3
+REPLACE nosmt
+ADD tsx=off
+DELETE nohz=off
+`), 0o644))
+
+	runner := &MockCommandRunner{outputs: map[string][]byte{
+		"rpm-ostree kargs --append=mitigations=off": nil,
+		"rpm-ostree kargs --delete=audit=1":         nil,
+		"rpm-ostree kargs --replace=tsx=off":        nil,
+	}}
+	policy := &kernelTuningPolicy{allowlist: tuneableRHCOSArgsAllowlist}
+
+	status, err := updateTuningArgs(runner, tuningFile, cmdLineFile, policy)
+	require.NoError(t, err)
+	assert.Equal(t, []kernelTuningArg{
+		{Operation: "ADD", Argument: "mitigations=off"},
+		{Operation: "ADD", Argument: "nosmt"},
+		{Operation: "DELETE", Argument: "audit=1"},
+		{Operation: "DELETE", Argument: "skew_tick=1"},
+		{Operation: "ADD", Argument: "tsx=off"},
+	}, status.Applied)
+	assert.Equal(t, []kernelTuningArg{
+		{Operation: "ADD", Argument: "isolcpus=1-3", Reason: "isolcpus=1-3 is not an allowlisted kernel argument"},
+	}, status.Rejected)
+	// Deleting another value of an argument would leave that value in effect
+	assert.Equal(t, []kernelTuningArg{
+		{Operation: "DELETE", Argument: "nohz=off", Reason: "the current argument is nohz=on"},
+	}, status.NotApplied)
+
+	// A missing tuning file requests nothing
+	status, err = updateTuningArgs(runner, filepath.Join(testDir, "missing"), cmdLineFile, policy)
+	require.NoError(t, err)
+	assert.False(t, status.requested())
+
+	assert.Equal(t, "nosmt", tuneArgumentString(types.TuneArgument{Key: "nosmt", Bare: true}))
+}
+
+func TestPendingKernelArgs(t *testing.T) {
+	cmdline := "BOOT_IMAGE=(hd0,gpt3)/ostree/rhcos-abc/vmlinuz-5.14 rw ostree=/ostree/boot.1/rhcos/abc/0 nosmt quiet\n"
+	ignKargs := ign3types.KernelArguments{
+		ShouldExist:    []ign3types.KernelArgument{"quiet"},
+		ShouldNotExist: []ign3types.KernelArgument{"rw", "mitigations=off"},
+	}
+
+	missing, unexpected := pendingKernelArgs(cmdline, []string{"nosmt debug"}, ignKargs)
+	assert.Equal(t, []string{"debug"}, missing)
+	assert.Equal(t, []string{"rw"}, unexpected)
+
+	missing, unexpected = pendingKernelArgs(cmdline, []string{"nosmt"}, ign3types.KernelArguments{ShouldExist: []ign3types.KernelArgument{"quiet"}})
+	assert.Empty(t, missing)
+	assert.Empty(t, unexpected)
+}
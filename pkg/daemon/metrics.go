@@ -65,6 +65,34 @@ var (
 			Help: "Total number of locally layered unsupported packages installed on the node",
 		},
 		[]string{"node"})
+
+	// mcdPinnedImages counts the pinned images on the node by prefetch state
+	mcdPinnedImages = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "mcd_pinned_images",
+			Help: "Number of pinned images on the node by prefetch state",
+		}, []string{"state"})
+
+	// mcdPinnedImagePullFailures counts failed pinned image pulls by error class
+	mcdPinnedImagePullFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcd_pinned_image_pull_failures_total",
+			Help: "Total number of pinned image pulls that failed, by error class",
+		}, []string{"error_class"})
+
+	// mcdPinnedImagePullRetries counts retried pinned image pulls
+	mcdPinnedImagePullRetries = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mcd_pinned_image_pull_retries_total",
+			Help: "Total number of pinned image pull retries",
+		})
+
+	// mcdPinnedImagePulledBytes counts the bytes of pinned images pulled by source
+	mcdPinnedImagePulledBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcd_pinned_image_pulled_bytes_total",
+			Help: "Total size of pinned images pulled, by source",
+		}, []string{"source"})
 )
 
 // Updates metric with new labels & timestamp, deletes any existing
@@ -87,6 +115,10 @@ func RegisterMCDMetrics() error {
 		mcdUpdateState,
 		mcdConfigDrift,
 		unsupportedPackages,
+		mcdPinnedImages,
+		mcdPinnedImagePullFailures,
+		mcdPinnedImagePullRetries,
+		mcdPinnedImagePulledBytes,
 	})
 
 	if err != nil {
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	ign2types "github.com/coreos/ignition/config/v2_2/types"
 	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
@@ -30,9 +31,18 @@ func validateOnDiskState(currentConfig *mcfgv1.MachineConfig, systemdPath string
 		if err := checkV3Files(ignconfigi.(ign3types.Config).Storage.Files); err != nil {
 			return &fileConfigDriftErr{err}
 		}
+		if err := checkV3Directories(ignconfigi.(ign3types.Config).Storage.Directories); err != nil {
+			return &fileConfigDriftErr{err}
+		}
+		if err := checkV3Links(ignconfigi.(ign3types.Config).Storage.Links); err != nil {
+			return &fileConfigDriftErr{err}
+		}
 		if err := checkV3Units(ignconfigi.(ign3types.Config).Systemd.Units, systemdPath); err != nil {
 			return &unitConfigDriftErr{err}
 		}
+		if err := checkV3Passwd(&CommandRunnerOS{}, ignconfigi.(ign3types.Config).Passwd); err != nil {
+			return err
+		}
 		return nil
 	case ign2types.Config:
 		if err := checkV2Files(ignconfigi.(ign2types.Config).Storage.Files); err != nil {
@@ -80,12 +90,18 @@ func checkV3Unit(unit ign3types.Unit, systemdPath string) error {
 		}
 	}
 
-	if unit.Contents == nil || *unit.Contents == "" {
+	path := getIgn3SystemdUnitPath(systemdPath, unit)
+	if unit.Mask != nil && !*unit.Mask && isUnitPathMasked(path) {
+		return fmt.Errorf("state validation: unit %q expected unmasked, but %q is a symlink to %s", unit.Name, path, pathDevNull)
+	}
+
+	// Masked units are validated regardless of their contents, which are not
+	// written
+	if (unit.Mask == nil || !*unit.Mask) && (unit.Contents == nil || *unit.Contents == "") {
 		// Return early if the contents are empty.
 		return nil
 	}
 
-	path := getIgn3SystemdUnitPath(systemdPath, unit)
 	if unit.Mask != nil && *unit.Mask {
 		link, err := filepath.EvalSymlinks(path)
 		if err != nil {
@@ -190,16 +206,13 @@ func checkV3Files(files []ign3types.File) error {
 			klog.V(4).Infof("Skipping file %s during checkV3Files", f.Path)
 			continue
 		}
-		if len(f.Append) > 0 {
-			return fmt.Errorf("found an append section when checking files. Append is not supported")
-		}
 		mode := defaultFilePermissions
 		if f.Mode != nil {
 			mode = os.FileMode(*f.Mode) //nolint:gosec
 		}
-		contents, err := ctrlcommon.DecodeIgnitionFileContents(f.Contents.Source, f.Contents.Compression)
+		contents, err := fileContentsWithAppends(f)
 		if err != nil {
-			return fmt.Errorf("couldn't decode file %q: %w", f.Path, err)
+			return fmt.Errorf("couldn't get contents of file %q: %w", f.Path, err)
 		}
 		if err := checkFileContentsAndMode(f.Path, contents, mode); err != nil {
 			return err
@@ -208,6 +221,81 @@ func checkV3Files(files []ign3types.File) error {
 	return nil
 }
 
+// checkV3Directories validates the type, mode and ownership of all the
+// directories in the target config.
+func checkV3Directories(dirs []ign3types.Directory) error {
+	for _, d := range dirs {
+		mode := defaultDirectoryPermissions
+		if d.Mode != nil {
+			mode = os.FileMode(*d.Mode) //nolint:gosec
+		}
+		fi, err := os.Lstat(d.Path)
+		if err != nil {
+			return fmt.Errorf("could not stat directory %q: %w", d.Path, err)
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("%q is not a directory", d.Path)
+		}
+		if fi.Mode().Perm() != mode.Perm() {
+			return fmt.Errorf("mode mismatch for directory: %q; expected: %#o; received: %#o", d.Path, mode.Perm(), fi.Mode().Perm())
+		}
+		if err := checkNodeOwnership(d.Node, fi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkV3Links validates the targets and ownership of all the symbolic links
+// in the target config. Hard links cannot be reconciled, so they are skipped.
+func checkV3Links(links []ign3types.Link) error {
+	for _, l := range links {
+		if l.Hard != nil && *l.Hard {
+			klog.V(4).Infof("Skipping hard link %s during checkV3Links", l.Path)
+			continue
+		}
+		fi, err := os.Lstat(l.Path)
+		if err != nil {
+			return fmt.Errorf("could not stat link %q: %w", l.Path, err)
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("%q is not a symbolic link", l.Path)
+		}
+		target, err := os.Readlink(l.Path)
+		if err != nil {
+			return fmt.Errorf("could not read link %q: %w", l.Path, err)
+		}
+		if l.Target != nil && target != *l.Target {
+			return fmt.Errorf("target mismatch for link: %q; expected: %q; received: %q", l.Path, *l.Target, target)
+		}
+		if err := checkNodeOwnership(l.Node, fi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkNodeOwnership validates the ownership of a directory or link, if the
+// config specifies it.
+func checkNodeOwnership(node ign3types.Node, fi os.FileInfo) error {
+	if node.User == (ign3types.NodeUser{}) && node.Group == (ign3types.NodeGroup{}) {
+		return nil
+	}
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	uid, gid, err := getNodeOwnership(node)
+	if err != nil {
+		return fmt.Errorf("could not get the expected ownership of %q: %w", node.Path, err)
+	}
+	// -1 leaves the owner unchanged, like for chown
+	if (uid != -1 && int(stat.Uid) != uid) || (gid != -1 && int(stat.Gid) != gid) {
+		return fmt.Errorf("ownership mismatch for %q; expected: %d:%d; received: %d:%d", node.Path, uid, gid, stat.Uid, stat.Gid)
+	}
+	return nil
+}
+
 // checkV2Files validates the contents of all the files in the target config.
 func checkV2Files(files []ign2types.File) error {
 	checkedFiles := make(map[string]bool)
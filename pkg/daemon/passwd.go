@@ -0,0 +1,746 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+)
+
+// The core user is managed by the OS, the MCD only writes its SSH keys and
+// password hash. All other users and groups of the Ignition passwd section are
+// created, modified and removed by the MCD.
+//
+// Users and groups that already existed on the node before they were added to
+// a MachineConfig are never deleted, the MCD only clears the password of such
+// users once they are removed from the config. System users and groups, i.e.
+// with an ID up to SYS_UID_MAX or SYS_GID_MAX, which already existed are not
+// modified either, apart from users joining the wheel group as a supplementary
+// group. To tell them apart, the MCD stamps each user and group it
+// creates below passwdStampParentDirPath.
+var passwdStampParentDirPath = filepath.Join("/etc", "machine-config-daemon", "passwd")
+
+// loginDefsPath holds the SYS_UID_MAX and SYS_GID_MAX of the node.
+var loginDefsPath = "/etc/login.defs"
+
+// defaultSysIDMax is the SYS_UID_MAX and SYS_GID_MAX of RHEL if they are not
+// set in loginDefsPath.
+const defaultSysIDMax = 999
+
+func userStampName(name string) string {
+	return filepath.Join(passwdStampParentDirPath, "users", name)
+}
+
+func groupStampName(name string) string {
+	return filepath.Join(passwdStampParentDirPath, "groups", name)
+}
+
+// passwdEntry is an entry of the passwd database, see passwd(5).
+type passwdEntry struct {
+	Name    string
+	UID     int
+	GID     int
+	Gecos   string
+	HomeDir string
+	Shell   string
+}
+
+// groupEntry is an entry of the group database, see group(5).
+type groupEntry struct {
+	Name    string
+	GID     int
+	Members []string
+}
+
+// listPasswdEntries returns the entries of the passwd database by name.
+func listPasswdEntries(runner CommandRunner) (map[string]passwdEntry, error) {
+	out, err := runner.RunGetOut("getent", "passwd")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	entries := map[string]passwdEntry{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) != 7 {
+			continue
+		}
+		uid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid UID of user %s: %w", fields[0], err)
+		}
+		gid, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid GID of user %s: %w", fields[0], err)
+		}
+		entries[fields[0]] = passwdEntry{
+			Name:    fields[0],
+			UID:     uid,
+			GID:     gid,
+			Gecos:   fields[4],
+			HomeDir: fields[5],
+			Shell:   fields[6],
+		}
+	}
+	return entries, nil
+}
+
+// listGroupEntries returns the entries of the group database by name.
+func listGroupEntries(runner CommandRunner) (map[string]groupEntry, error) {
+	out, err := runner.RunGetOut("getent", "group")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list groups: %w", err)
+	}
+
+	entries := map[string]groupEntry{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) != 4 {
+			continue
+		}
+		gid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid GID of group %s: %w", fields[0], err)
+		}
+		entry := groupEntry{Name: fields[0], GID: gid}
+		if fields[3] != "" {
+			entry.Members = strings.Split(fields[3], ",")
+		}
+		entries[fields[0]] = entry
+	}
+	return entries, nil
+}
+
+// groupName returns the name of the group with the given GID, or the GID if
+// there is no such group.
+func groupName(groups map[string]groupEntry, gid int) string {
+	for _, g := range groups {
+		if g.GID == gid {
+			return g.Name
+		}
+	}
+	return strconv.Itoa(gid)
+}
+
+// supplementaryGroups returns the sorted names of the groups that list name as
+// a member.
+func supplementaryGroups(groups map[string]groupEntry, name string) []string {
+	var names []string
+	for _, g := range groups {
+		if slices.Contains(g.Members, name) {
+			names = append(names, g.Name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// primaryGroupMatches returns whether the primary group of the Ignition user,
+// which is either a name or a GID, is the group with the given GID.
+func primaryGroupMatches(groups map[string]groupEntry, primaryGroup string, gid int) bool {
+	if primaryGroup == strconv.Itoa(gid) {
+		return true
+	}
+	return groupName(groups, gid) == primaryGroup
+}
+
+func shouldExist(b *bool) bool {
+	return b == nil || *b
+}
+
+func isManagedUser(u ign3types.PasswdUser) bool {
+	return u.Name != constants.CoreUserName
+}
+
+func hasManagedPasswd(passwd ign3types.Passwd) bool {
+	return len(passwd.Groups) != 0 || slices.ContainsFunc(passwd.Users, isManagedUser)
+}
+
+// sysIDMax returns the SYS_UID_MAX or SYS_GID_MAX setting of login.defs(5).
+func sysIDMax(key string) int {
+	contents, err := os.ReadFile(loginDefsPath)
+	if err != nil {
+		return defaultSysIDMax
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != key {
+			continue
+		}
+		if value, err := strconv.Atoi(fields[1]); err == nil {
+			return value
+		}
+	}
+	return defaultSysIDMax
+}
+
+func writeStamp(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), defaultDirectoryPermissions); err != nil {
+		return fmt.Errorf("failed to create stamp directory for %q: %w", path, err)
+	}
+	if err := os.WriteFile(path, nil, defaultFilePermissions); err != nil {
+		return fmt.Errorf("failed to write stamp %q: %w", path, err)
+	}
+	return nil
+}
+
+func removeStamp(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove stamp %q: %w", path, err)
+	}
+	return nil
+}
+
+// updateUsersAndGroups creates, modifies and removes the groups and the users
+// other than core so that they match newPasswd. Users and groups present in
+// oldPasswd but not in newPasswd are removed if the MCD created them.
+func (dn *Daemon) updateUsersAndGroups(newPasswd, oldPasswd ign3types.Passwd) error {
+	if dn.mock {
+		return nil
+	}
+	if !hasManagedPasswd(newPasswd) && !hasManagedPasswd(oldPasswd) {
+		return nil
+	}
+	klog.Info("updating users and groups")
+	return applyPasswd(dn.cmdRunner, newPasswd, oldPasswd)
+}
+
+// applyPasswd updates the groups before the users, as users may reference the
+// new groups, and removes absent users before absent groups, as groups cannot
+// be removed while they are the primary group of a user.
+func applyPasswd(runner CommandRunner, newPasswd, oldPasswd ign3types.Passwd) error {
+	groups, err := listGroupEntries(runner)
+	if err != nil {
+		return err
+	}
+	users, err := listPasswdEntries(runner)
+	if err != nil {
+		return err
+	}
+	// Nothing is changed if any user or group can't be
+	if err := checkPasswdOwnership(newPasswd, users, groups); err != nil {
+		return err
+	}
+
+	for _, g := range newPasswd.Groups {
+		if err := updateGroup(runner, g, groups); err != nil {
+			return err
+		}
+	}
+
+	// Group updates change the GIDs and the names of the primary groups.
+	if groups, err = listGroupEntries(runner); err != nil {
+		return err
+	}
+	if users, err = listPasswdEntries(runner); err != nil {
+		return err
+	}
+	for _, u := range newPasswd.Users {
+		if !isManagedUser(u) {
+			continue
+		}
+		if err := updateUser(runner, u, users, groups); err != nil {
+			return err
+		}
+	}
+
+	for _, u := range oldPasswd.Users {
+		if !isManagedUser(u) || isUserPresent(u, newPasswd.Users) {
+			continue
+		}
+		if _, ok := users[u.Name]; !ok {
+			continue
+		}
+		if err := removeUser(runner, u.Name); err != nil {
+			return err
+		}
+	}
+
+	for _, g := range oldPasswd.Groups {
+		if slices.ContainsFunc(newPasswd.Groups, func(n ign3types.PasswdGroup) bool { return n.Name == g.Name }) {
+			continue
+		}
+		if _, ok := groups[g.Name]; !ok {
+			continue
+		}
+		if err := removeGroup(runner, g.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func updateGroup(runner CommandRunner, g ign3types.PasswdGroup, groups map[string]groupEntry) error {
+	current, exists := groups[g.Name]
+
+	if !shouldExist(g.ShouldExist) {
+		if !exists {
+			return nil
+		}
+		klog.Infof("Deleting group %s", g.Name)
+		if _, err := runner.RunGetOut("groupdel", g.Name); err != nil {
+			return fmt.Errorf("failed to delete group %s: %w", g.Name, err)
+		}
+		return removeStamp(groupStampName(g.Name))
+	}
+
+	if !exists {
+		args := []string{}
+		if g.Gid != nil {
+			args = append(args, "--gid", strconv.Itoa(*g.Gid))
+		}
+		if g.PasswordHash != nil {
+			args = append(args, "--password", *g.PasswordHash)
+		}
+		if g.System != nil && *g.System {
+			args = append(args, "--system")
+		}
+		args = append(args, g.Name)
+
+		klog.Infof("Creating group %s", g.Name)
+		if _, err := runner.RunGetOut("groupadd", args...); err != nil {
+			return fmt.Errorf("failed to create group %s: %w", g.Name, err)
+		}
+		return writeStamp(groupStampName(g.Name))
+	}
+
+	args := groupmodArgs(g, current)
+	if len(args) == 0 {
+		return nil
+	}
+	args = append(args, g.Name)
+
+	klog.Infof("Modifying group %s", g.Name)
+	if _, err := runner.RunGetOut("groupmod", args...); err != nil {
+		return fmt.Errorf("failed to modify group %s: %w", g.Name, err)
+	}
+	return nil
+}
+
+// groupmodArgs returns the groupmod options changing the existing group to
+// match the Ignition group.
+func groupmodArgs(g ign3types.PasswdGroup, current groupEntry) []string {
+	args := []string{}
+	if g.Gid != nil && *g.Gid != current.GID {
+		args = append(args, "--gid", strconv.Itoa(*g.Gid))
+	}
+	if g.PasswordHash != nil {
+		args = append(args, "--password", *g.PasswordHash)
+	}
+	return args
+}
+
+func removeGroup(runner CommandRunner, name string) error {
+	stampExists, err := fileExists(groupStampName(name))
+	if err != nil {
+		return err
+	}
+	if !stampExists {
+		klog.Infof("Group %s was not created by the MCD, leaving it in place", name)
+		return nil
+	}
+
+	klog.Infof("Deleting absent group %s", name)
+	if _, err := runner.RunGetOut("groupdel", name); err != nil {
+		return fmt.Errorf("failed to delete group %s: %w", name, err)
+	}
+	return removeStamp(groupStampName(name))
+}
+
+// updateUser creates or modifies the user. The password hash is set by
+// SetPasswordHash for existing users and the SSH keys are written by
+// updateSSHKeys.
+func updateUser(runner CommandRunner, u ign3types.PasswdUser, users map[string]passwdEntry, groups map[string]groupEntry) error {
+	current, exists := users[u.Name]
+
+	if !shouldExist(u.ShouldExist) {
+		if !exists {
+			return nil
+		}
+		// The home directory is kept, as it may hold data of the user.
+		klog.Infof("Deleting user %s", u.Name)
+		if _, err := runner.RunGetOut("userdel", u.Name); err != nil {
+			return fmt.Errorf("failed to delete user %s: %w", u.Name, err)
+		}
+		return removeStamp(userStampName(u.Name))
+	}
+
+	if !exists {
+		// These are the same options Ignition passes to useradd.
+		args := []string{}
+		if u.NoCreateHome != nil && *u.NoCreateHome {
+			args = append(args, "--no-create-home")
+		} else {
+			args = append(args, "--create-home")
+		}
+		if u.NoUserGroup != nil && *u.NoUserGroup {
+			args = append(args, "--no-user-group")
+		}
+		if u.System != nil && *u.System {
+			args = append(args, "--system")
+		}
+		if u.NoLogInit != nil && *u.NoLogInit {
+			args = append(args, "--no-log-init")
+		}
+		if u.UID != nil {
+			args = append(args, "--uid", strconv.Itoa(*u.UID))
+		}
+		if u.Gecos != nil {
+			args = append(args, "--comment", *u.Gecos)
+		}
+		if u.HomeDir != nil {
+			args = append(args, "--home-dir", *u.HomeDir)
+		}
+		if u.PrimaryGroup != nil {
+			args = append(args, "--gid", *u.PrimaryGroup)
+		}
+		if len(u.Groups) != 0 {
+			args = append(args, "--groups", joinGroups(u.Groups))
+		}
+		if u.Shell != nil {
+			args = append(args, "--shell", *u.Shell)
+		}
+		if u.PasswordHash != nil {
+			args = append(args, "--password", *u.PasswordHash)
+		}
+		args = append(args, u.Name)
+
+		klog.Infof("Creating user %s", u.Name)
+		if _, err := runner.RunGetOut("useradd", args...); err != nil {
+			return fmt.Errorf("failed to create user %s: %w", u.Name, err)
+		}
+		return writeStamp(userStampName(u.Name))
+	}
+
+	args := usermodArgs(u, current, groups)
+	if len(args) == 0 {
+		return nil
+	}
+	args = append(args, u.Name)
+
+	klog.Infof("Modifying user %s", u.Name)
+	if _, err := runner.RunGetOut("usermod", args...); err != nil {
+		return fmt.Errorf("failed to modify user %s: %w", u.Name, err)
+	}
+	return nil
+}
+
+// usermodArgs returns the usermod options changing the existing user to match
+// the Ignition user.
+func usermodArgs(u ign3types.PasswdUser, current passwdEntry, groups map[string]groupEntry) []string {
+	args := []string{}
+	if u.UID != nil && *u.UID != current.UID {
+		args = append(args, "--uid", strconv.Itoa(*u.UID))
+	}
+	if u.Gecos != nil && *u.Gecos != current.Gecos {
+		args = append(args, "--comment", *u.Gecos)
+	}
+	if u.HomeDir != nil && *u.HomeDir != current.HomeDir {
+		// The contents of the old home directory are not moved.
+		args = append(args, "--home", *u.HomeDir)
+	}
+	if u.PrimaryGroup != nil && !primaryGroupMatches(groups, *u.PrimaryGroup, current.GID) {
+		args = append(args, "--gid", *u.PrimaryGroup)
+	}
+	if u.Groups != nil && !slices.Equal(sortedGroups(u.Groups), supplementaryGroups(groups, u.Name)) {
+		args = append(args, "--groups", joinGroups(u.Groups))
+	}
+	if u.Shell != nil && *u.Shell != current.Shell {
+		args = append(args, "--shell", *u.Shell)
+	}
+	return args
+}
+
+// checkPasswdOwnership verifies that the MCD only deletes the users and groups
+// it created, and does not modify the system users and groups it did not
+// create, including by adding members to such groups. Users may only join the
+// wheel group as a supplementary group.
+func checkPasswdOwnership(passwd ign3types.Passwd, users map[string]passwdEntry, groups map[string]groupEntry) error {
+	sysUIDMax, sysGIDMax := sysIDMax("SYS_UID_MAX"), sysIDMax("SYS_GID_MAX")
+
+	// isForeignSystemGroup returns whether the group exists, is a system
+	// group and was not created by the MCD.
+	isForeignSystemGroup := func(name string) (bool, error) {
+		// Primary groups may be given by GID
+		if gid, err := strconv.Atoi(name); err == nil {
+			name = groupName(groups, gid)
+		}
+		current, exists := groups[name]
+		if !exists || current.GID > sysGIDMax {
+			return false, nil
+		}
+		stamped, err := fileExists(groupStampName(name))
+		return !stamped, err
+	}
+
+	for _, g := range passwd.Groups {
+		current, exists := groups[g.Name]
+		if !exists {
+			continue
+		}
+		stamped, err := fileExists(groupStampName(g.Name))
+		if err != nil {
+			return err
+		}
+		if stamped {
+			continue
+		}
+		if !shouldExist(g.ShouldExist) {
+			return fmt.Errorf("group %s was not created by the MCD and cannot be deleted", g.Name)
+		}
+		if current.GID <= sysGIDMax && len(groupmodArgs(g, current)) != 0 {
+			return fmt.Errorf("system group %s was not created by the MCD and cannot be modified", g.Name)
+		}
+	}
+
+	for _, u := range passwd.Users {
+		if !isManagedUser(u) {
+			continue
+		}
+		current, exists := users[u.Name]
+		if exists {
+			stamped, err := fileExists(userStampName(u.Name))
+			if err != nil {
+				return err
+			}
+			if !stamped && !shouldExist(u.ShouldExist) {
+				return fmt.Errorf("user %s was not created by the MCD and cannot be deleted", u.Name)
+			}
+			if !stamped && current.UID <= sysUIDMax && len(usermodArgs(u, current, groups)) != 0 {
+				return fmt.Errorf("system user %s was not created by the MCD and cannot be modified", u.Name)
+			}
+		}
+		if !shouldExist(u.ShouldExist) {
+			continue
+		}
+
+		// Joining a group modifies the group
+		var joined []string
+		if u.PrimaryGroup != nil && (!exists || !primaryGroupMatches(groups, *u.PrimaryGroup, current.GID)) {
+			joined = append(joined, *u.PrimaryGroup)
+		}
+		for _, g := range sortedGroups(u.Groups) {
+			if g != constants.WheelGroupName && !slices.Contains(supplementaryGroups(groups, u.Name), g) {
+				joined = append(joined, g)
+			}
+		}
+		for _, g := range joined {
+			foreign, err := isForeignSystemGroup(g)
+			if err != nil {
+				return err
+			}
+			if foreign {
+				return fmt.Errorf("user %s cannot join system group %s, which was not created by the MCD", u.Name, g)
+			}
+		}
+	}
+
+	return nil
+}
+
+func removeUser(runner CommandRunner, name string) error {
+	stampExists, err := fileExists(userStampName(name))
+	if err != nil {
+		return err
+	}
+	if !stampExists {
+		// deconfigureAbsentUsers clears the password of the user.
+		klog.Infof("User %s was not created by the MCD, leaving it in place", name)
+		return nil
+	}
+
+	klog.Infof("Deleting absent user %s", name)
+	if _, err := runner.RunGetOut("userdel", name); err != nil {
+		return fmt.Errorf("failed to delete user %s: %w", name, err)
+	}
+	return removeStamp(userStampName(name))
+}
+
+func sortedGroups(groups []ign3types.Group) []string {
+	names := make([]string, 0, len(groups))
+	for _, g := range groups {
+		names = append(names, string(g))
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+func joinGroups(groups []ign3types.Group) string {
+	return strings.Join(sortedGroups(groups), ",")
+}
+
+// userSSHKeyPath returns the path of the authorized keys file the MCD writes
+// for a user other than core, following the same layout as for core.
+func userSSHKeyPath(homeDir string, useNewSSHKeyPath bool) string {
+	if useNewSSHKeyPath {
+		return filepath.Join(homeDir, ".ssh", "authorized_keys.d", "ignition")
+	}
+	return filepath.Join(homeDir, ".ssh", "authorized_keys")
+}
+
+// writeUserSSHKeys writes the SSH keys of a user other than core to the
+// user's home directory, or removes the keys file if the user has no keys.
+func writeUserSSHKeys(u ign3types.PasswdUser, entry passwdEntry, useNewSSHKeyPath bool) error {
+	authKeyPath := userSSHKeyPath(entry.HomeDir, useNewSSHKeyPath)
+
+	if len(u.SSHAuthorizedKeys) == 0 {
+		if err := os.Remove(authKeyPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove SSH keys of user %s: %w", u.Name, err)
+		}
+		return nil
+	}
+
+	if _, err := os.Stat(entry.HomeDir); err != nil {
+		return fmt.Errorf("cannot write SSH keys of user %s: %w", u.Name, err)
+	}
+
+	// The directories below the home directory are created by hand so that
+	// they are owned by the user rather than by root.
+	sshDir := filepath.Join(entry.HomeDir, ".ssh")
+	for _, dir := range []string{sshDir, filepath.Dir(authKeyPath)} {
+		if err := ensureUserDirectory(dir, entry.UID, entry.GID); err != nil {
+			return err
+		}
+	}
+
+	var keys strings.Builder
+	for _, k := range u.SSHAuthorizedKeys {
+		keys.WriteString(string(k) + "\n")
+	}
+
+	klog.Infof("Writing SSH keys of user %s to %q", u.Name, authKeyPath)
+	return writeFileAtomically(authKeyPath, []byte(keys.String()), os.FileMode(0o700), os.FileMode(0o600), entry.UID, entry.GID)
+}
+
+func ensureUserDirectory(dir string, uid, gid int) error {
+	info, err := os.Lstat(dir)
+	if err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("%q exists and is not a directory", dir)
+		}
+		return nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create directory %q: %w", dir, err)
+	}
+	if err := os.Chown(dir, uid, gid); err != nil {
+		return fmt.Errorf("failed to change owner of %q: %w", dir, err)
+	}
+	return nil
+}
+
+// checkV3Passwd verifies that the groups and the users other than core match
+// the Ignition passwd section.
+func checkV3Passwd(runner CommandRunner, passwd ign3types.Passwd) error {
+	if !hasManagedPasswd(passwd) {
+		return nil
+	}
+
+	groups, err := listGroupEntries(runner)
+	if err != nil {
+		return err
+	}
+	for _, g := range passwd.Groups {
+		current, exists := groups[g.Name]
+		if !shouldExist(g.ShouldExist) {
+			if exists {
+				return fmt.Errorf("group %q should not exist", g.Name)
+			}
+			continue
+		}
+		if !exists {
+			return fmt.Errorf("group %q does not exist", g.Name)
+		}
+		if g.Gid != nil && *g.Gid != current.GID {
+			return fmt.Errorf("group %q has GID %d, expected %d", g.Name, current.GID, *g.Gid)
+		}
+	}
+
+	users, err := listPasswdEntries(runner)
+	if err != nil {
+		return err
+	}
+	for _, u := range passwd.Users {
+		if !isManagedUser(u) {
+			continue
+		}
+		current, exists := users[u.Name]
+		if !shouldExist(u.ShouldExist) {
+			if exists {
+				return fmt.Errorf("user %q should not exist", u.Name)
+			}
+			continue
+		}
+		if !exists {
+			return fmt.Errorf("user %q does not exist", u.Name)
+		}
+		if u.UID != nil && *u.UID != current.UID {
+			return fmt.Errorf("user %q has UID %d, expected %d", u.Name, current.UID, *u.UID)
+		}
+		if u.Gecos != nil && *u.Gecos != current.Gecos {
+			return fmt.Errorf("user %q has comment %q, expected %q", u.Name, current.Gecos, *u.Gecos)
+		}
+		if u.HomeDir != nil && *u.HomeDir != current.HomeDir {
+			return fmt.Errorf("user %q has home directory %q, expected %q", u.Name, current.HomeDir, *u.HomeDir)
+		}
+		if u.Shell != nil && *u.Shell != current.Shell {
+			return fmt.Errorf("user %q has shell %q, expected %q", u.Name, current.Shell, *u.Shell)
+		}
+		if u.PrimaryGroup != nil && !primaryGroupMatches(groups, *u.PrimaryGroup, current.GID) {
+			return fmt.Errorf("user %q has primary group %q, expected %q", u.Name, groupName(groups, current.GID), *u.PrimaryGroup)
+		}
+		if u.Groups != nil {
+			if expected, actual := sortedGroups(u.Groups), supplementaryGroups(groups, u.Name); !slices.Equal(expected, actual) {
+				return fmt.Errorf("user %q has supplementary groups %v, expected %v", u.Name, actual, expected)
+			}
+		}
+		if err := checkUserSSHKeys(u, current); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkUserSSHKeys verifies the SSH keys file of a user other than core.
+// Which path is used depends on the OS, so the keys are looked up at the
+// new path first and at the legacy path otherwise.
+func checkUserSSHKeys(u ign3types.PasswdUser, entry passwdEntry) error {
+	if len(u.SSHAuthorizedKeys) == 0 {
+		return nil
+	}
+
+	var expected strings.Builder
+	for _, k := range u.SSHAuthorizedKeys {
+		expected.WriteString(string(k) + "\n")
+	}
+
+	for _, path := range []string{userSSHKeyPath(entry.HomeDir, true), userSSHKeyPath(entry.HomeDir, false)} {
+		contents, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not read SSH keys of user %q: %w", u.Name, err)
+		}
+		if string(contents) != expected.String() {
+			return fmt.Errorf("SSH keys of user %q in %q do not match", u.Name, path)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("SSH keys of user %q not found", u.Name)
+}
@@ -0,0 +1,328 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+// passwdCommandRunner serves the passwd and group databases and records all
+// other commands instead of running them.
+type passwdCommandRunner struct {
+	MockCommandRunner
+	commands []string
+}
+
+func newPasswdCommandRunner(passwd, group string) *passwdCommandRunner {
+	return &passwdCommandRunner{
+		MockCommandRunner: MockCommandRunner{
+			outputs: map[string][]byte{
+				"getent passwd": []byte(passwd),
+				"getent group":  []byte(group),
+			},
+		},
+	}
+}
+
+func (r *passwdCommandRunner) RunGetOut(command string, args ...string) ([]byte, error) {
+	if command == "getent" {
+		return r.MockCommandRunner.RunGetOut(command, args...)
+	}
+	r.commands = append(r.commands, command+" "+strings.Join(args, " "))
+	return nil, nil
+}
+
+func TestApplyPasswd(t *testing.T) {
+	const (
+		basePasswd = "core:x:1000:1000:CoreOS Admin:/var/home/core:/bin/bash\nsshd:x:74:74:Privilege-separated SSH:/usr/share/empty.sshd:/usr/sbin/nologin\n"
+		baseGroup  = "wheel:x:10:core\ncore:x:1000:\nsshd:x:74:\nops:x:2100:\n"
+
+		builderPasswd = basePasswd + "builder:x:2001:2000::/var/home/builder:/bin/sh\n"
+		builderGroup  = "wheel:x:10:core\ncore:x:1000:\nsshd:x:74:\nops:x:2100:builder\nbuilders:x:2000:\n"
+	)
+
+	builders := ign3types.PasswdGroup{Name: "builders", Gid: helpers.IntToPtr(2000)}
+	builder := ign3types.PasswdUser{
+		Name:         "builder",
+		UID:          helpers.IntToPtr(2001),
+		HomeDir:      helpers.StrToPtr("/var/home/builder"),
+		PrimaryGroup: helpers.StrToPtr("builders"),
+		Groups:       []ign3types.Group{"ops"},
+	}
+	core := ign3types.PasswdUser{Name: "core", SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"1234"}}
+
+	withShell := builder
+	withShell.Shell = helpers.StrToPtr("/bin/bash")
+
+	absent := builder
+	absent.ShouldExist = helpers.BoolToPtr(false)
+
+	admin := builder
+	admin.Groups = []ign3types.Group{"ops", "wheel"}
+
+	sshdMember := builder
+	sshdMember.Groups = []ign3types.Group{"ops", "sshd"}
+
+	wheelPrimary := builder
+	wheelPrimary.PrimaryGroup = helpers.StrToPtr("wheel")
+
+	testCases := []struct {
+		name             string
+		passwd           string
+		group            string
+		stamped          bool
+		oldPasswd        ign3types.Passwd
+		newPasswd        ign3types.Passwd
+		expectedCommands []string
+		expectStamps     bool
+		expectedErr      string
+	}{
+		{
+			name:      "creates groups and users",
+			passwd:    basePasswd,
+			group:     baseGroup,
+			newPasswd: ign3types.Passwd{Groups: []ign3types.PasswdGroup{builders}, Users: []ign3types.PasswdUser{core, builder}},
+			expectedCommands: []string{
+				"groupadd --gid 2000 builders",
+				"useradd --create-home --uid 2001 --home-dir /var/home/builder --gid builders --groups ops builder",
+			},
+			expectStamps: true,
+		},
+		{
+			name:         "does not change users and groups that match",
+			passwd:       builderPasswd,
+			group:        builderGroup,
+			stamped:      true,
+			oldPasswd:    ign3types.Passwd{Groups: []ign3types.PasswdGroup{builders}, Users: []ign3types.PasswdUser{builder}},
+			newPasswd:    ign3types.Passwd{Groups: []ign3types.PasswdGroup{builders}, Users: []ign3types.PasswdUser{builder}},
+			expectStamps: true,
+		},
+		{
+			name:             "modifies changed fields only",
+			passwd:           builderPasswd,
+			group:            builderGroup,
+			stamped:          true,
+			oldPasswd:        ign3types.Passwd{Groups: []ign3types.PasswdGroup{builders}, Users: []ign3types.PasswdUser{builder}},
+			newPasswd:        ign3types.Passwd{Groups: []ign3types.PasswdGroup{{Name: "builders", Gid: helpers.IntToPtr(3000)}}, Users: []ign3types.PasswdUser{withShell}},
+			expectedCommands: []string{"groupmod --gid 3000 builders", "usermod --shell /bin/bash builder"},
+			expectStamps:     true,
+		},
+		{
+			name:             "removes absent users and groups created by the MCD",
+			passwd:           builderPasswd,
+			group:            builderGroup,
+			stamped:          true,
+			oldPasswd:        ign3types.Passwd{Groups: []ign3types.PasswdGroup{builders}, Users: []ign3types.PasswdUser{core, builder}},
+			newPasswd:        ign3types.Passwd{Users: []ign3types.PasswdUser{core}},
+			expectedCommands: []string{"userdel builder", "groupdel builders"},
+		},
+		{
+			name:      "keeps absent users and groups not created by the MCD",
+			passwd:    builderPasswd,
+			group:     builderGroup,
+			oldPasswd: ign3types.Passwd{Groups: []ign3types.PasswdGroup{builders}, Users: []ign3types.PasswdUser{builder}},
+		},
+		{
+			name:             "removes users that should not exist",
+			passwd:           builderPasswd,
+			group:            builderGroup,
+			stamped:          true,
+			oldPasswd:        ign3types.Passwd{Groups: []ign3types.PasswdGroup{builders}, Users: []ign3types.PasswdUser{builder}},
+			newPasswd:        ign3types.Passwd{Users: []ign3types.PasswdUser{absent}},
+			expectedCommands: []string{"userdel builder", "groupdel builders"},
+		},
+		{
+			name:        "does not remove users that should not exist not created by the MCD",
+			passwd:      builderPasswd,
+			group:       builderGroup,
+			newPasswd:   ign3types.Passwd{Users: []ign3types.PasswdUser{absent}},
+			expectedErr: "user builder was not created by the MCD and cannot be deleted",
+		},
+		{
+			name:        "does not remove system users",
+			passwd:      basePasswd,
+			group:       baseGroup,
+			newPasswd:   ign3types.Passwd{Users: []ign3types.PasswdUser{{Name: "sshd", ShouldExist: helpers.BoolToPtr(false)}}},
+			expectedErr: "user sshd was not created by the MCD and cannot be deleted",
+		},
+		{
+			name:        "does not remove system groups",
+			passwd:      basePasswd,
+			group:       baseGroup,
+			newPasswd:   ign3types.Passwd{Groups: []ign3types.PasswdGroup{{Name: "wheel", ShouldExist: helpers.BoolToPtr(false)}}},
+			expectedErr: "group wheel was not created by the MCD and cannot be deleted",
+		},
+		{
+			name:        "does not modify system users",
+			passwd:      basePasswd,
+			group:       baseGroup,
+			newPasswd:   ign3types.Passwd{Users: []ign3types.PasswdUser{{Name: "sshd", Shell: helpers.StrToPtr("/bin/bash")}}},
+			expectedErr: "system user sshd was not created by the MCD and cannot be modified",
+		},
+		{
+			name:        "does not modify system groups",
+			passwd:      basePasswd,
+			group:       baseGroup,
+			newPasswd:   ign3types.Passwd{Groups: []ign3types.PasswdGroup{{Name: "wheel", Gid: helpers.IntToPtr(2010)}}},
+			expectedErr: "system group wheel was not created by the MCD and cannot be modified",
+		},
+		{
+			name:             "lists unchanged system accounts",
+			passwd:           basePasswd,
+			group:            baseGroup,
+			newPasswd:        ign3types.Passwd{Groups: []ign3types.PasswdGroup{{Name: "wheel", Gid: helpers.IntToPtr(10)}}, Users: []ign3types.PasswdUser{{Name: "sshd", UID: helpers.IntToPtr(74)}}},
+			expectedCommands: nil,
+		},
+		{
+			name:             "adds users to the wheel group",
+			passwd:           builderPasswd,
+			group:            builderGroup,
+			stamped:          true,
+			oldPasswd:        ign3types.Passwd{Groups: []ign3types.PasswdGroup{builders}, Users: []ign3types.PasswdUser{builder}},
+			newPasswd:        ign3types.Passwd{Groups: []ign3types.PasswdGroup{builders}, Users: []ign3types.PasswdUser{admin}},
+			expectedCommands: []string{"usermod --groups ops,wheel builder"},
+			expectStamps:     true,
+		},
+		{
+			name:        "does not add users to system groups",
+			passwd:      builderPasswd,
+			group:       builderGroup,
+			stamped:     true,
+			oldPasswd:   ign3types.Passwd{Groups: []ign3types.PasswdGroup{builders}, Users: []ign3types.PasswdUser{builder}},
+			newPasswd:   ign3types.Passwd{Groups: []ign3types.PasswdGroup{builders}, Users: []ign3types.PasswdUser{sshdMember}},
+			expectedErr: "user builder cannot join system group sshd, which was not created by the MCD",
+		},
+		{
+			name:        "does not set the wheel group as primary group",
+			passwd:      builderPasswd,
+			group:       builderGroup,
+			stamped:     true,
+			oldPasswd:   ign3types.Passwd{Groups: []ign3types.PasswdGroup{builders}, Users: []ign3types.PasswdUser{builder}},
+			newPasswd:   ign3types.Passwd{Groups: []ign3types.PasswdGroup{builders}, Users: []ign3types.PasswdUser{wheelPrimary}},
+			expectedErr: "user builder cannot join system group wheel, which was not created by the MCD",
+		},
+		{
+			name:        "does not create users in system groups",
+			passwd:      basePasswd,
+			group:       baseGroup,
+			newPasswd:   ign3types.Passwd{Users: []ign3types.PasswdUser{{Name: "builder", PrimaryGroup: helpers.StrToPtr("74")}}},
+			expectedErr: "user builder cannot join system group 74, which was not created by the MCD",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Cleanup(helpers.OverrideGlobalPathVar(t, "passwdStampParentDirPath", &passwdStampParentDirPath))
+			t.Cleanup(helpers.OverrideGlobalPathVar(t, "loginDefsPath", &loginDefsPath))
+
+			if testCase.stamped {
+				require.NoError(t, writeStamp(userStampName("builder")))
+				require.NoError(t, writeStamp(groupStampName("builders")))
+			}
+
+			runner := newPasswdCommandRunner(testCase.passwd, testCase.group)
+			err := applyPasswd(runner, testCase.newPasswd, testCase.oldPasswd)
+			if testCase.expectedErr != "" {
+				assert.EqualError(t, err, testCase.expectedErr)
+				assert.Empty(t, runner.commands)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectedCommands, runner.commands)
+
+			for _, stamp := range []string{userStampName("builder"), groupStampName("builders")} {
+				exists, err := fileExists(stamp)
+				require.NoError(t, err)
+				assert.Equal(t, testCase.expectStamps, exists, stamp)
+			}
+		})
+	}
+}
+
+func TestSysIDMax(t *testing.T) {
+	t.Cleanup(helpers.OverrideGlobalPathVar(t, "loginDefsPath", &loginDefsPath))
+	assert.Equal(t, defaultSysIDMax, sysIDMax("SYS_UID_MAX"))
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(loginDefsPath), 0o755))
+	require.NoError(t, os.WriteFile(loginDefsPath, []byte("# SYS_UID_MAX 100\nSYS_UID_MAX\t499\nSYS_GID_MAX   invalid\n"), 0o644))
+	assert.Equal(t, 499, sysIDMax("SYS_UID_MAX"))
+	assert.Equal(t, defaultSysIDMax, sysIDMax("SYS_GID_MAX"))
+}
+
+func TestCheckV3Passwd(t *testing.T) {
+	home := t.TempDir()
+	keyPath := userSSHKeyPath(home, true)
+	require.NoError(t, os.MkdirAll(filepath.Dir(keyPath), 0o700))
+	require.NoError(t, os.WriteFile(keyPath, []byte("1234\n"), 0o600))
+
+	runner := newPasswdCommandRunner(
+		"core:x:1000:1000::/var/home/core:/bin/bash\nbuilder:x:2001:2000::"+home+":/bin/bash\n",
+		"builders:x:2000:\nwheel:x:10:builder\n",
+	)
+
+	builder := ign3types.PasswdUser{
+		Name:              "builder",
+		UID:               helpers.IntToPtr(2001),
+		PrimaryGroup:      helpers.StrToPtr("2000"),
+		Groups:            []ign3types.Group{"wheel"},
+		SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"1234"},
+	}
+
+	testCases := []struct {
+		name        string
+		passwd      ign3types.Passwd
+		expectedErr string
+	}{
+		{
+			name:   "core only",
+			passwd: ign3types.Passwd{Users: []ign3types.PasswdUser{{Name: "core", SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"5678"}}}},
+		},
+		{
+			name:   "matching users and groups",
+			passwd: ign3types.Passwd{Groups: []ign3types.PasswdGroup{{Name: "builders", Gid: helpers.IntToPtr(2000)}}, Users: []ign3types.PasswdUser{builder}},
+		},
+		{
+			name:        "missing group",
+			passwd:      ign3types.Passwd{Groups: []ign3types.PasswdGroup{{Name: "testers"}}},
+			expectedErr: `group "testers" does not exist`,
+		},
+		{
+			name:        "group that should not exist",
+			passwd:      ign3types.Passwd{Groups: []ign3types.PasswdGroup{{Name: "builders", ShouldExist: helpers.BoolToPtr(false)}}},
+			expectedErr: `group "builders" should not exist`,
+		},
+		{
+			name:        "changed shell",
+			passwd:      ign3types.Passwd{Users: []ign3types.PasswdUser{{Name: "builder", Shell: helpers.StrToPtr("/bin/sh")}}},
+			expectedErr: `user "builder" has shell "/bin/bash", expected "/bin/sh"`,
+		},
+		{
+			name:        "changed supplementary groups",
+			passwd:      ign3types.Passwd{Users: []ign3types.PasswdUser{{Name: "builder", Groups: []ign3types.Group{"wheel", "builders"}}}},
+			expectedErr: `user "builder" has supplementary groups [wheel], expected [builders wheel]`,
+		},
+		{
+			name:        "changed SSH keys",
+			passwd:      ign3types.Passwd{Users: []ign3types.PasswdUser{{Name: "builder", SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"5678"}}}},
+			expectedErr: `SSH keys of user "builder"`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := checkV3Passwd(runner, testCase.passwd)
+			if testCase.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, testCase.expectedErr)
+			}
+		})
+	}
+}
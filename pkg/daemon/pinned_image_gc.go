@@ -0,0 +1,321 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+	"k8s.io/klog/v2"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/daemon/cri"
+)
+
+// retry interval for unpinned images which could not be removed
+const pinnedImageGCRetryInterval = 10 * time.Minute
+
+// pinnedImageGCPolicy controls whether images which are no longer pinned are
+// removed from the node.
+type pinnedImageGCPolicy string
+
+const (
+	// images which are no longer pinned are left for the kubelet image
+	// garbage collection
+	pinnedImageGCPolicyNever pinnedImageGCPolicy = "Never"
+	// images which are no longer pinned are removed unless a running
+	// container uses them
+	pinnedImageGCPolicyRemoveUnused pinnedImageGCPolicy = "RemoveUnused"
+)
+
+// pinnedImageGCConfig configures the garbage collection of unpinned images.
+// A nil config means that garbage collection is disabled.
+type pinnedImageGCConfig struct {
+	// how long to keep an image after it is unpinned
+	gracePeriod time.Duration
+	// pool to requeue when images are waiting for the grace period to pass
+	poolName string
+}
+
+func hasPinnedImageGC(pool *mcfgv1.MachineConfigPool) bool {
+	return pinnedImageGCPolicy(pool.Annotations[constants.PinnedImageGCPolicyAnnotationKey]) == pinnedImageGCPolicyRemoveUnused
+}
+
+// getPinnedImageGCConfig combines the garbage collection policies of the
+// given pools. Garbage collection is enabled if any pool enables it and the
+// longest grace period wins. Returns nil if garbage collection is disabled.
+func getPinnedImageGCConfig(pools []*mcfgv1.MachineConfigPool) (*pinnedImageGCConfig, error) {
+	var cfg *pinnedImageGCConfig
+	for _, pool := range pools {
+		policy, ok := pool.Annotations[constants.PinnedImageGCPolicyAnnotationKey]
+		if !ok {
+			continue
+		}
+
+		switch pinnedImageGCPolicy(policy) {
+		case pinnedImageGCPolicyNever:
+			continue
+		case pinnedImageGCPolicyRemoveUnused:
+		default:
+			return nil, fmt.Errorf("MachineConfigPool %q: unknown pinned image garbage collection policy %q, must be one of %s or %s",
+				pool.Name, policy, pinnedImageGCPolicyNever, pinnedImageGCPolicyRemoveUnused)
+		}
+
+		gracePeriod := time.Duration(0)
+		if value, ok := pool.Annotations[constants.PinnedImageGCGracePeriodAnnotationKey]; ok {
+			d, err := time.ParseDuration(strings.TrimSpace(value))
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("MachineConfigPool %q: annotation %s must be a non-negative duration, got %q",
+					pool.Name, constants.PinnedImageGCGracePeriodAnnotationKey, value)
+			}
+			gracePeriod = d
+		}
+
+		if cfg == nil {
+			cfg = &pinnedImageGCConfig{gracePeriod: gracePeriod, poolName: pool.Name}
+		} else if gracePeriod > cfg.gracePeriod {
+			cfg.gracePeriod = gracePeriod
+			cfg.poolName = pool.Name
+		}
+	}
+
+	return cfg, nil
+}
+
+// pinnedImageGCState is persisted on the host so that images which were
+// unpinned before the daemon restarted are still removed.
+type pinnedImageGCState struct {
+	// images waiting to be removed and when they were unpinned
+	Unpinned map[string]metav1.Time `json:"unpinned,omitempty"`
+	// total bytes reclaimed on the node
+	ReclaimedBytes int64 `json:"reclaimedBytes"`
+}
+
+// pinnedImageGC removes images which are no longer pinned.
+type pinnedImageGC struct {
+	criClient *cri.Client
+	statePath string
+	now       func() time.Time
+
+	// mu protects reclaimedBytes
+	mu sync.Mutex
+	// total bytes reclaimed on the node, as of the last collection
+	reclaimedBytes int64
+}
+
+func newPinnedImageGC(criClient *cri.Client) *pinnedImageGC {
+	return &pinnedImageGC{
+		criClient: criClient,
+		statePath: constants.PinnedImageGCStateFile,
+		now:       time.Now,
+	}
+}
+
+func (g *pinnedImageGC) getReclaimedBytes() int64 {
+	if g == nil {
+		return 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.reclaimedBytes
+}
+
+func (g *pinnedImageGC) loadState() (*pinnedImageGCState, error) {
+	state := &pinnedImageGCState{}
+
+	data, err := os.ReadFile(g.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pinned image garbage collection state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		// the state only delays removal, so start over instead of failing
+		klog.Warningf("Ignoring invalid pinned image garbage collection state %s: %v", g.statePath, err)
+		return &pinnedImageGCState{}, nil
+	}
+
+	g.mu.Lock()
+	g.reclaimedBytes = state.ReclaimedBytes
+	g.mu.Unlock()
+
+	return state, nil
+}
+
+func (g *pinnedImageGC) saveState(state *pinnedImageGCState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pinned image garbage collection state: %w", err)
+	}
+
+	if err := writeFileAtomicallyWithDefaults(g.statePath, data); err != nil {
+		return fmt.Errorf("failed to write pinned image garbage collection state: %w", err)
+	}
+
+	g.mu.Lock()
+	g.reclaimedBytes = state.ReclaimedBytes
+	g.mu.Unlock()
+
+	return nil
+}
+
+// collect records the images which were unpinned and removes those whose grace
+// period has passed, unless a running container uses them. Images which cannot
+// be removed are retried on the next collection. Returns how long until the
+// next image is due for removal, or zero if no images are waiting.
+//
+// This must be called after CRI-O stopped pinning the unpinned images.
+func (g *pinnedImageGC) collect(ctx context.Context, cfg *pinnedImageGCConfig, previouslyPinned, pinned []string) (time.Duration, error) {
+	state, err := g.loadState()
+	if err != nil {
+		return 0, err
+	}
+
+	if state.Unpinned == nil {
+		state.Unpinned = map[string]metav1.Time{}
+	}
+
+	now := g.now()
+	pinnedSet := sets.New(pinned...)
+	for _, image := range previouslyPinned {
+		if _, ok := state.Unpinned[image]; !ok && !pinnedSet.Has(image) {
+			klog.V(4).Infof("Image %q was unpinned", image)
+			state.Unpinned[image] = metav1.NewTime(now)
+		}
+	}
+
+	// an image may be pinned again before it was removed
+	for image := range state.Unpinned {
+		if pinnedSet.Has(image) {
+			delete(state.Unpinned, image)
+		}
+	}
+
+	// garbage collection is disabled, forget about the unpinned images so
+	// they are not removed if it is enabled later
+	if cfg == nil {
+		if len(state.Unpinned) == 0 {
+			return 0, nil
+		}
+		state.Unpinned = nil
+		return 0, g.saveState(state)
+	}
+
+	due := []string{}
+	nextDue := time.Duration(0)
+	for image, unpinned := range state.Unpinned {
+		wait := unpinned.Add(cfg.gracePeriod).Sub(now)
+		if wait <= 0 {
+			due = append(due, image)
+		} else if nextDue == 0 || wait < nextDue {
+			nextDue = wait
+		}
+	}
+	sort.Strings(due)
+
+	if len(due) > 0 {
+		inUse, err := g.getImagesInUse(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		retry := false
+		for _, image := range due {
+			reclaimed, removed, err := g.removeImage(ctx, image, inUse)
+			if err != nil {
+				klog.Warningf("Failed to remove unpinned image %q: %v", image, err)
+				retry = true
+				continue
+			}
+			if !removed {
+				klog.V(4).Infof("Unpinned image %q is in use by a running container, will retry", image)
+				retry = true
+				continue
+			}
+
+			klog.Infof("Removed unpinned image %q, reclaimed %d bytes", image, reclaimed)
+			state.ReclaimedBytes += reclaimed
+			delete(state.Unpinned, image)
+		}
+
+		if retry && (nextDue == 0 || nextDue > pinnedImageGCRetryInterval) {
+			nextDue = pinnedImageGCRetryInterval
+		}
+	}
+
+	return nextDue, g.saveState(state)
+}
+
+// getImagesInUse returns the image names and IDs used by running containers.
+func (g *pinnedImageGC) getImagesInUse(ctx context.Context) (sets.Set[string], error) {
+	containers, err := g.criClient.ListContainers(ctx, &runtimeapi.ContainerFilter{
+		State: &runtimeapi.ContainerStateValue{State: runtimeapi.ContainerState_CONTAINER_RUNNING},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running containers: %w", err)
+	}
+
+	inUse := sets.New[string]()
+	for _, container := range containers {
+		inUse.Insert(container.ImageRef, container.ImageId)
+		if container.Image != nil {
+			inUse.Insert(container.Image.Image)
+		}
+	}
+	inUse.Delete("")
+
+	return inUse, nil
+}
+
+// removeImage removes the image unless it is in use. Returns the bytes
+// reclaimed and whether the image is gone.
+func (g *pinnedImageGC) removeImage(ctx context.Context, image string, inUse sets.Set[string]) (int64, bool, error) {
+	images, err := g.criClient.ListImages(ctx, image)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	// already removed, e.g. by the kubelet image garbage collection
+	if len(images) == 0 {
+		return 0, true, nil
+	}
+
+	reclaimed := int64(0)
+	for _, img := range images {
+		if inUse.Has(img.Id) || inUse.HasAny(img.RepoTags...) || inUse.HasAny(img.RepoDigests...) {
+			return 0, false, nil
+		}
+		reclaimed += int64(img.Size) //nolint:gosec
+	}
+
+	if err := g.criClient.RemoveImage(ctx, image); err != nil {
+		return 0, false, err
+	}
+
+	return reclaimed, true, nil
+}
+
+// getCrioPinnedImages returns the images pinned in the CRI-O config file.
+func getCrioPinnedImages(path string) ([]string, error) {
+	cfg := crioPinnedImagesConfig{}
+	if _, err := toml.DecodeFile(path, &cfg); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read CRI-O pinned images from %s: %w", path, err)
+	}
+
+	return cfg.Crio.Image.PinnedImages, nil
+}
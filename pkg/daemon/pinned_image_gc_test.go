@@ -0,0 +1,235 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/daemon/cri"
+)
+
+// fakeRuntimeService is a fake container runtime service which only lists
+// containers.
+type fakeRuntimeService struct {
+	runtimeapi.UnimplementedRuntimeServiceServer
+	containers []*runtimeapi.Container
+}
+
+// ListContainers implements v1.RuntimeServiceServer.
+func (f *fakeRuntimeService) ListContainers(_ context.Context, req *runtimeapi.ListContainersRequest) (*runtimeapi.ListContainersResponse, error) {
+	resp := &runtimeapi.ListContainersResponse{}
+	for _, container := range f.containers {
+		if req.Filter != nil && req.Filter.State != nil && req.Filter.State.State != container.State {
+			continue
+		}
+		resp.Containers = append(resp.Containers, container)
+	}
+	return resp, nil
+}
+
+func TestPinnedImageGCCollect(t *testing.T) {
+	const (
+		unusedImage  = "quay.io/org/unused@sha256:0000000000000000000000000000000000000000000000000000000000000000"
+		inUseImage   = "quay.io/org/in-use@sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		pinnedImage  = "quay.io/org/pinned@sha256:2222222222222222222222222222222222222222222222222222222222222222"
+		stoppedImage = "quay.io/org/stopped@sha256:3333333333333333333333333333333333333333333333333333333333333333"
+	)
+
+	newGC := func(t *testing.T) (*pinnedImageGC, *FakeRuntime) {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		runtime := newFakeRuntime([]string{unusedImage, inUseImage, pinnedImage, stoppedImage}, nil)
+		runtimeapi.RegisterRuntimeServiceServer(runtime.server, &fakeRuntimeService{
+			containers: []*runtimeapi.Container{
+				{Image: &runtimeapi.ImageSpec{Image: inUseImage}, State: runtimeapi.ContainerState_CONTAINER_RUNNING},
+				{Image: &runtimeapi.ImageSpec{Image: stoppedImage}, State: runtimeapi.ContainerState_CONTAINER_EXITED},
+			},
+		})
+		listener, err := newTestListener()
+		require.NoError(t, err)
+		require.NoError(t, runtime.Start(listener))
+		t.Cleanup(runtime.Stop)
+
+		criClient, err := cri.NewClient(ctx, listener.Addr().String())
+		require.NoError(t, err)
+
+		gc := newPinnedImageGC(criClient)
+		gc.statePath = filepath.Join(t.TempDir(), "state.json")
+		return gc, runtime
+	}
+
+	previouslyPinned := []string{unusedImage, inUseImage, pinnedImage, stoppedImage}
+	pinned := []string{pinnedImage}
+
+	t.Run("removes unpinned images not used by running containers", func(t *testing.T) {
+		gc, runtime := newGC(t)
+
+		nextRun, err := gc.collect(context.Background(), &pinnedImageGCConfig{}, previouslyPinned, pinned)
+		require.NoError(t, err)
+
+		assert.ElementsMatch(t, []string{unusedImage, stoppedImage}, runtime.removedImages)
+		assert.Equal(t, int64(2*fakeImageSize), gc.getReclaimedBytes())
+		// the image in use is retried later
+		assert.Equal(t, pinnedImageGCRetryInterval, nextRun)
+
+		state, err := gc.loadState()
+		require.NoError(t, err)
+		assert.Contains(t, state.Unpinned, inUseImage)
+		assert.Len(t, state.Unpinned, 1)
+	})
+
+	t.Run("waits for the grace period", func(t *testing.T) {
+		gc, runtime := newGC(t)
+		now := time.Now()
+		gc.now = func() time.Time { return now }
+		cfg := &pinnedImageGCConfig{gracePeriod: time.Hour}
+
+		nextRun, err := gc.collect(context.Background(), cfg, previouslyPinned, pinned)
+		require.NoError(t, err)
+		assert.Empty(t, runtime.removedImages)
+		assert.Equal(t, time.Hour, nextRun)
+
+		// the images are remembered even though they are no longer in the
+		// CRI-O config
+		now = now.Add(time.Hour)
+		_, err = gc.collect(context.Background(), cfg, pinned, pinned)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{unusedImage, stoppedImage}, runtime.removedImages)
+	})
+
+	t.Run("does not remove images which are pinned again", func(t *testing.T) {
+		gc, runtime := newGC(t)
+		cfg := &pinnedImageGCConfig{gracePeriod: time.Hour}
+
+		_, err := gc.collect(context.Background(), cfg, previouslyPinned, pinned)
+		require.NoError(t, err)
+
+		gc.now = func() time.Time { return time.Now().Add(time.Hour) }
+		_, err = gc.collect(context.Background(), cfg, pinned, previouslyPinned)
+		require.NoError(t, err)
+		assert.Empty(t, runtime.removedImages)
+
+		state, err := gc.loadState()
+		require.NoError(t, err)
+		assert.Empty(t, state.Unpinned)
+	})
+
+	t.Run("forgets unpinned images when disabled", func(t *testing.T) {
+		gc, runtime := newGC(t)
+
+		_, err := gc.collect(context.Background(), &pinnedImageGCConfig{gracePeriod: time.Hour}, previouslyPinned, pinned)
+		require.NoError(t, err)
+
+		_, err = gc.collect(context.Background(), nil, pinned, pinned)
+		require.NoError(t, err)
+		assert.Empty(t, runtime.removedImages)
+
+		state, err := gc.loadState()
+		require.NoError(t, err)
+		assert.Empty(t, state.Unpinned)
+	})
+
+	t.Run("does not write state when disabled", func(t *testing.T) {
+		gc, _ := newGC(t)
+
+		_, err := gc.collect(context.Background(), nil, pinned, pinned)
+		require.NoError(t, err)
+		assert.NoFileExists(t, gc.statePath)
+	})
+}
+
+func TestGetPinnedImageGCConfig(t *testing.T) {
+	newPool := func(name string, annotations map[string]string) *mcfgv1.MachineConfigPool {
+		return &mcfgv1.MachineConfigPool{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+	}
+
+	tests := []struct {
+		name    string
+		pools   []*mcfgv1.MachineConfigPool
+		wantCfg *pinnedImageGCConfig
+		wantErr bool
+	}{
+		{
+			name:  "disabled by default",
+			pools: []*mcfgv1.MachineConfigPool{newPool("worker", nil)},
+		},
+		{
+			name: "disabled explicitly",
+			pools: []*mcfgv1.MachineConfigPool{
+				newPool("worker", map[string]string{constants.PinnedImageGCPolicyAnnotationKey: "Never"}),
+			},
+		},
+		{
+			name: "longest grace period wins",
+			pools: []*mcfgv1.MachineConfigPool{
+				newPool("infra", map[string]string{
+					constants.PinnedImageGCPolicyAnnotationKey:      "RemoveUnused",
+					constants.PinnedImageGCGracePeriodAnnotationKey: "1h",
+				}),
+				newPool("worker", map[string]string{
+					constants.PinnedImageGCPolicyAnnotationKey:      "RemoveUnused",
+					constants.PinnedImageGCGracePeriodAnnotationKey: "24h",
+				}),
+			},
+			wantCfg: &pinnedImageGCConfig{gracePeriod: 24 * time.Hour, poolName: "worker"},
+		},
+		{
+			name: "unknown policy",
+			pools: []*mcfgv1.MachineConfigPool{
+				newPool("worker", map[string]string{constants.PinnedImageGCPolicyAnnotationKey: "Always"}),
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid grace period",
+			pools: []*mcfgv1.MachineConfigPool{
+				newPool("worker", map[string]string{
+					constants.PinnedImageGCPolicyAnnotationKey:      "RemoveUnused",
+					constants.PinnedImageGCGracePeriodAnnotationKey: "a day",
+				}),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg, err := getPinnedImageGCConfig(tt.pools)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCfg, cfg)
+		})
+	}
+}
+
+func TestGetCrioPinnedImages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "50-pinned-images")
+
+	images, err := getCrioPinnedImages(path)
+	require.NoError(t, err)
+	assert.Empty(t, images)
+
+	want := []string{"quay.io/org/a@sha256:abc", "quay.io/org/b@sha256:def"}
+	cfg, err := createCrioConfigFileBytes(want)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, cfg, 0o644))
+
+	images, err = getCrioPinnedImages(path)
+	require.NoError(t, err)
+	assert.Equal(t, want, images)
+}
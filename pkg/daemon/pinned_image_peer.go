@@ -0,0 +1,828 @@
+package daemon
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/docker/reference"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+)
+
+const (
+	// port the node-local peer registry listens on. It is within the 9000-9999
+	// range which the installer opens between the nodes of the cluster for
+	// host level services, so peers can reach it without firewall changes.
+	pinnedImagePeerRegistryPort = 9645
+
+	// credentials of the node, issued and rotated by the kubelet, which
+	// authenticate the peer registry to its clients and the clients to the
+	// peer registry.
+	kubeletServingCertPath = "/var/lib/kubelet/pki/kubelet-server-current.pem"
+	kubeletClientCertPath  = "/var/lib/kubelet/pki/kubelet-client-current.pem"
+
+	// config maps in the openshift-config-managed namespace holding the CA
+	// bundles which sign the kubelet serving and client certificates. They
+	// are kept up to date with the rotation of the signers.
+	kubeletServingCAConfigMap = "kubelet-serving-ca"
+	kubeletClientCAConfigMap  = "kube-apiserver-client-ca"
+	caBundleConfigMapKey      = "ca-bundle.crt"
+
+	// certificate directory passed to skopeo to fetch images from peers
+	pinnedImagePeerCertDir = "/run/machine-config-daemon/pinned-image-peer-certs"
+
+	// prefix of the common name of the client certificate of a node
+	nodeCommonNamePrefix = "system:node:"
+
+	// time allowed for in-flight requests to complete when the peer registry
+	// stops
+	peerShutdownTimeout = 30 * time.Second
+
+	// maximum number of peers to try for a single image before falling back
+	// to the upstream registry
+	maxPeerAttempts = 3
+
+	// timeout for fetching a single image from a single peer
+	peerFetchTimeout = 10 * time.Minute
+
+	// maximum size of the peer cache once it is pruned, beyond which the
+	// images staged first are evicted
+	pinnedImagePeerCacheMaxBytes = 10 * 1024 * 1024 * 1024
+)
+
+// pinnedImageSource describes where a pinned image was obtained from.
+type pinnedImageSource string
+
+const (
+	// the image was already present in the container runtime storage
+	pinnedImageSourceLocal pinnedImageSource = "Local"
+	// the image was fetched from a peer node
+	pinnedImageSourcePeer pinnedImageSource = "Peer"
+	// the image was fetched from the upstream registry (or its mirrors)
+	pinnedImageSourceUpstream pinnedImageSource = "Upstream"
+)
+
+// peerPrefetchConfig holds the peers to fetch images from for a single
+// prefetch. A nil config means that peer distribution is disabled.
+type peerPrefetchConfig struct {
+	// host:port of each peer registry
+	peers []string
+	// directory holding the client certificate and CA bundle to fetch images
+	// from the peers
+	certDir string
+}
+
+// peerDistributor fetches pinned images from peer nodes and serves the images
+// this node fetched to its peers. Images are staged in an OCI layout on the
+// host which is shared between all images so that a layer is only stored (and
+// transferred) once. The layout is then imported into the container runtime
+// storage, once the image is allowed by the signature policy of the node.
+//
+// Images which were already present in the container runtime storage are not
+// staged, and the layout is bounded by maxCacheBytes, so peers fall back to the
+// upstream registry for the images this node does not hold in its cache.
+// Since images are only served by digest, a manifest list digest is only
+// served if the full list was staged. In practice, peers will fall back to the
+// upstream registry for manifest lists.
+//
+// The registry is served over TLS with the kubelet serving certificate and
+// only answers nodes of the same pool presenting their kubelet client
+// certificate from their own address.
+type peerDistributor struct {
+	nodeName      string
+	kubeClient    clientset.Interface
+	cacheDir      string
+	maxCacheBytes int64
+	port          int
+
+	servingCertPath string
+	clientCertPath  string
+	certDir         string
+
+	// verifier checks fetched images against the signature policy before
+	// they are imported into the container runtime storage
+	verifier *pinnedImageVerifier
+
+	// copyImage runs "skopeo copy" with the given arguments.
+	copyImage func(ctx context.Context, args ...string) error
+
+	// mu protects allowedPeers, clientCAs and server
+	mu sync.RWMutex
+	// names of the nodes which may fetch images from this node, by IP
+	allowedPeers map[string]string
+	// CAs which sign the kubelet client certificates of the peers, nil until
+	// they are loaded
+	clientCAs *x509.CertPool
+	// the peer registry, nil when it is not serving
+	server *http.Server
+}
+
+func newPeerDistributor(nodeName string, kubeClient clientset.Interface, verifier *pinnedImageVerifier) *peerDistributor {
+	return &peerDistributor{
+		nodeName:        nodeName,
+		kubeClient:      kubeClient,
+		cacheDir:        constants.PinnedImagePeerCacheDir,
+		maxCacheBytes:   pinnedImagePeerCacheMaxBytes,
+		port:            pinnedImagePeerRegistryPort,
+		servingCertPath: kubeletServingCertPath,
+		clientCertPath:  kubeletClientCertPath,
+		certDir:         pinnedImagePeerCertDir,
+		verifier:        verifier,
+		copyImage:       skopeoCopy,
+		allowedPeers:    map[string]string{},
+	}
+}
+
+// isPinnedImagePeerDistributionEnabled determines if the nodes in the pool have
+// opted into peer distribution of pinned images.
+func isPinnedImagePeerDistributionEnabled(pool *mcfgv1.MachineConfigPool) bool {
+	return pool.Annotations[constants.PinnedImagePeerDistributionAnnotationKey] == "true"
+}
+
+// getPeerPrefetchConfig finds the peers within the pool to fetch images from.
+// Only those peers are allowed to fetch images from this node.
+func (d *peerDistributor) getPeerPrefetchConfig(ctx context.Context, pool *mcfgv1.MachineConfigPool) (*peerPrefetchConfig, error) {
+	selector, err := metav1.LabelSelectorAsSelector(pool.Spec.NodeSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node selector for MachineConfigPool %q: %w", pool.Name, err)
+	}
+
+	nodes, err := d.kubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes for MachineConfigPool %q: %w", pool.Name, err)
+	}
+
+	allowedPeers := map[string]string{}
+	peers := []string{}
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if node.Name == d.nodeName || checkNodeReady(node) != nil {
+			continue
+		}
+
+		ip := getNodeInternalIP(node)
+		if ip == "" {
+			continue
+		}
+
+		allowedPeers[ip] = node.Name
+		peers = append(peers, net.JoinHostPort(ip, strconv.Itoa(d.port)))
+	}
+
+	sort.Strings(peers)
+
+	// the client certificate and the CA bundles are rotated, so they are
+	// refreshed for every prefetch
+	servingCABundle, err := d.getCABundle(ctx, kubeletServingCAConfigMap)
+	if err != nil {
+		return nil, err
+	}
+	clientCABundle, err := d.getCABundle(ctx, kubeletClientCAConfigMap)
+	if err != nil {
+		return nil, err
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(clientCABundle) {
+		return nil, fmt.Errorf("no certificates found in the %s/%s CA bundle", ctrlcommon.OpenshiftConfigManagedNamespace, kubeletClientCAConfigMap)
+	}
+
+	if err := d.writeCertDir(servingCABundle); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	d.allowedPeers = allowedPeers
+	d.clientCAs = clientCAs
+	d.mu.Unlock()
+
+	return &peerPrefetchConfig{peers: peers, certDir: d.certDir}, nil
+}
+
+// getCABundle returns the CA bundle held by the given config map in the
+// openshift-config-managed namespace.
+func (d *peerDistributor) getCABundle(ctx context.Context, name string) ([]byte, error) {
+	cm, err := d.kubeClient.CoreV1().ConfigMaps(ctrlcommon.OpenshiftConfigManagedNamespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CA bundle %s/%s: %w", ctrlcommon.OpenshiftConfigManagedNamespace, name, err)
+	}
+
+	caBundle := cm.Data[caBundleConfigMapKey]
+	if caBundle == "" {
+		return nil, fmt.Errorf("CA bundle %s/%s has no %s", ctrlcommon.OpenshiftConfigManagedNamespace, name, caBundleConfigMapKey)
+	}
+
+	return []byte(caBundle), nil
+}
+
+// writeCertDir writes the client certificate of the node and the CA bundle
+// which signs the kubelet serving certificates of the peers in the layout
+// skopeo expects for --src-cert-dir. The kubelet keeps the certificate and its
+// key in a single file, while skopeo expects them in separate files.
+func (d *peerDistributor) writeCertDir(servingCABundle []byte) error {
+	data, err := os.ReadFile(d.clientCertPath)
+	if err != nil {
+		return fmt.Errorf("failed to read node client certificate: %w", err)
+	}
+
+	var certs, keys []byte
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch {
+		case block.Type == "CERTIFICATE":
+			certs = append(certs, pem.EncodeToMemory(block)...)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			keys = append(keys, pem.EncodeToMemory(block)...)
+		}
+	}
+	if len(certs) == 0 || len(keys) == 0 {
+		return fmt.Errorf("node client certificate %s does not hold a certificate and a key", d.clientCertPath)
+	}
+
+	files := []struct {
+		name string
+		data []byte
+		mode os.FileMode
+	}{
+		{name: "client.cert", data: certs, mode: defaultFilePermissions},
+		{name: "client.key", data: keys, mode: 0o600},
+		{name: "ca.crt", data: servingCABundle, mode: defaultFilePermissions},
+	}
+	for _, f := range files {
+		if err := writeFileAtomically(filepath.Join(d.certDir, f.name), f.data, 0o700, f.mode, -1, -1); err != nil {
+			return fmt.Errorf("failed to write peer certificate directory: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func getNodeInternalIP(node *corev1.Node) string {
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP {
+			return addr.Address
+		}
+	}
+	return ""
+}
+
+// orderPeersForImage returns the peers to try for an image. Peers are ordered
+// by rendezvous hashing on this node, the image, and the peer so that the load
+// of serving a popular image is spread across peers instead of every node
+// trying the same peer first.
+func (d *peerDistributor) orderPeersForImage(image string, peers []string) []string {
+	score := func(peer string) uint64 {
+		h := fnv.New64a()
+		h.Write([]byte(d.nodeName + "\x00" + image + "\x00" + peer))
+		return h.Sum64()
+	}
+
+	ordered := append([]string{}, peers...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return score(ordered[i]) > score(ordered[j])
+	})
+
+	if len(ordered) > maxPeerAttempts {
+		ordered = ordered[:maxPeerAttempts]
+	}
+
+	return ordered
+}
+
+// fetch stages the image in the peer cache, trying peers before the upstream
+// registry, and then imports it into the container runtime storage. Since the
+// import bypasses the container runtime, and the signature policy it enforces
+// when pulling, the image is only imported once the verifier accepts it. The
+// image is staged by digest, so the staged manifest is the one verified.
+func (d *peerDistributor) fetch(ctx context.Context, image string, cfg *peerPrefetchConfig, authFilePath string) (pinnedImageSource, error) {
+	source, ociRef, err := d.stage(ctx, image, cfg, authFilePath)
+	if err != nil {
+		return "", err
+	}
+
+	if err := d.verifier.verify(ctx, image, false); err != nil {
+		return "", fmt.Errorf("refusing to import image %q from the peer cache: %w", image, err)
+	}
+
+	if err := d.copyImage(ctx, "--preserve-digests", ociRef, "containers-storage:"+image); err != nil {
+		return "", fmt.Errorf("failed to import image %q into container storage: %w", image, err)
+	}
+
+	return source, nil
+}
+
+// stage copies the image into the peer cache, trying peers before the upstream
+// registry, and returns where it was obtained from along with its reference in
+// the cache.
+func (d *peerDistributor) stage(ctx context.Context, image string, cfg *peerPrefetchConfig, authFilePath string) (pinnedImageSource, string, error) {
+	canonical, err := parseCanonicalImage(image)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Layouts are keyed by the encoded digest since it is a valid OCI
+	// reference name.
+	ociRef := fmt.Sprintf("oci:%s:%s", d.cacheDir, canonical.Digest().Encoded())
+
+	for _, peer := range d.orderPeersForImage(image, cfg.peers) {
+		peerImage := fmt.Sprintf("docker://%s/%s@%s", peer, reference.Path(canonical), canonical.Digest())
+
+		// peers are authenticated with the node certificates, registry
+		// credentials are never sent to them
+		peerCtx, cancel := context.WithTimeout(ctx, peerFetchTimeout)
+		err := d.copyImage(peerCtx, "--preserve-digests", "--src-cert-dir", cfg.certDir, "--src-no-creds", peerImage, ociRef)
+		cancel()
+		if err == nil {
+			klog.V(4).Infof("Fetched image %q from peer %s", image, peer)
+			return pinnedImageSourcePeer, ociRef, nil
+		}
+
+		klog.V(2).Infof("Failed to fetch image %q from peer %s: %v", image, peer, err)
+	}
+
+	if err := d.copyImage(ctx, "--preserve-digests", "--src-authfile", authFilePath, "docker://"+image, ociRef); err != nil {
+		return "", "", fmt.Errorf("failed to fetch image %q from upstream: %w", image, err)
+	}
+
+	return pinnedImageSourceUpstream, ociRef, nil
+}
+
+func parseCanonicalImage(image string) (reference.Canonical, error) {
+	named, err := reference.ParseNamed(image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse image %q: %w", image, err)
+	}
+
+	canonical, ok := named.(reference.Canonical)
+	if !ok {
+		return nil, fmt.Errorf("peer distribution requires a digested image, got %q", image)
+	}
+
+	return canonical, nil
+}
+
+// isStaged returns whether the image is in the peer cache.
+func (d *peerDistributor) isStaged(image string) bool {
+	canonical, err := parseCanonicalImage(image)
+	if err != nil {
+		return false
+	}
+
+	index, err := d.readCacheIndex()
+	if err != nil {
+		klog.Warningf("Failed to read pinned image peer cache: %v", err)
+		return false
+	}
+
+	for _, desc := range index.Manifests {
+		if desc.Annotations[ocispec.AnnotationRefName] == canonical.Digest().Encoded() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readCacheIndex reads the index of the peer cache, which is empty if nothing
+// was staged.
+func (d *peerDistributor) readCacheIndex() (*ocispec.Index, error) {
+	index := &ocispec.Index{}
+	data, err := os.ReadFile(filepath.Join(d.cacheDir, ocispec.ImageIndexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("invalid peer cache index: %w", err)
+	}
+
+	return index, nil
+}
+
+// pruneCache removes the images which are no longer pinned from the peer
+// cache, along with the blobs no remaining image references, and evicts the
+// images staged first until the cache fits within maxCacheBytes. Peers fetch
+// evicted images from the upstream registry instead. It returns the number of
+// bytes reclaimed.
+func (d *peerDistributor) pruneCache(pinned []string) (int64, error) {
+	index, err := d.readCacheIndex()
+	if err != nil {
+		return 0, err
+	}
+
+	keep := sets.New[string]()
+	for _, image := range pinned {
+		if canonical, err := parseCanonicalImage(image); err == nil {
+			keep.Insert(canonical.Digest().Encoded())
+		}
+	}
+
+	manifests := []ocispec.Descriptor{}
+	for _, desc := range index.Manifests {
+		if keep.Has(desc.Annotations[ocispec.AnnotationRefName]) {
+			manifests = append(manifests, desc)
+		}
+	}
+
+	reclaimed, err := d.setCacheManifests(index, manifests)
+	if err != nil {
+		return reclaimed, err
+	}
+
+	// images are appended to the index as they are staged
+	for len(manifests) > 0 {
+		size, err := d.cacheSize()
+		if err != nil {
+			return reclaimed, err
+		}
+		if size <= d.maxCacheBytes {
+			break
+		}
+
+		klog.Infof("Evicting %s from the pinned image peer cache which exceeds %d bytes", manifests[0].Digest, d.maxCacheBytes)
+		manifests = manifests[1:]
+		evicted, err := d.setCacheManifests(index, manifests)
+		reclaimed += evicted
+		if err != nil {
+			return reclaimed, err
+		}
+	}
+
+	return reclaimed, nil
+}
+
+// setCacheManifests updates the index of the peer cache to the given
+// manifests and removes the blobs none of them references, returning the
+// number of bytes reclaimed.
+func (d *peerDistributor) setCacheManifests(index *ocispec.Index, manifests []ocispec.Descriptor) (int64, error) {
+	if len(manifests) != len(index.Manifests) {
+		index.Manifests = manifests
+		data, err := json.Marshal(index)
+		if err != nil {
+			return 0, err
+		}
+		if err := writeFileAtomicallyWithDefaults(filepath.Join(d.cacheDir, ocispec.ImageIndexFile), data); err != nil {
+			return 0, fmt.Errorf("failed to write peer cache index: %w", err)
+		}
+	}
+
+	referenced := sets.New[digest.Digest]()
+	for _, desc := range manifests {
+		d.markReferenced(desc.Digest, referenced)
+	}
+
+	// blobs left over by interrupted fetches are removed as well
+	reclaimed := int64(0)
+	blobsDir := filepath.Join(d.cacheDir, ocispec.ImageBlobsDir)
+	err := filepath.WalkDir(blobsDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		dgst := digest.NewDigestFromEncoded(digest.Algorithm(filepath.Base(filepath.Dir(path))), entry.Name())
+		if referenced.Has(dgst) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		reclaimed += info.Size()
+		return nil
+	})
+	if err != nil {
+		return reclaimed, fmt.Errorf("failed to prune peer cache: %w", err)
+	}
+
+	return reclaimed, nil
+}
+
+// markReferenced marks the manifest or index blob and all the blobs it
+// references, recursively.
+func (d *peerDistributor) markReferenced(dgst digest.Digest, referenced sets.Set[digest.Digest]) {
+	if referenced.Has(dgst) {
+		return
+	}
+	referenced.Insert(dgst)
+
+	data, err := os.ReadFile(filepath.Join(d.cacheDir, ocispec.ImageBlobsDir, dgst.Algorithm().String(), dgst.Encoded()))
+	if err != nil {
+		return
+	}
+
+	var manifest struct {
+		Config    *ocispec.Descriptor  `json:"config,omitempty"`
+		Layers    []ocispec.Descriptor `json:"layers,omitempty"`
+		Manifests []ocispec.Descriptor `json:"manifests,omitempty"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return
+	}
+
+	if manifest.Config != nil {
+		referenced.Insert(manifest.Config.Digest)
+	}
+	for _, layer := range manifest.Layers {
+		referenced.Insert(layer.Digest)
+	}
+	for _, desc := range manifest.Manifests {
+		d.markReferenced(desc.Digest, referenced)
+	}
+}
+
+// cacheSize returns the number of bytes used by the peer cache.
+func (d *peerDistributor) cacheSize() (int64, error) {
+	size := int64(0)
+	err := filepath.WalkDir(d.cacheDir, func(_ string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+func skopeoCopy(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "skopeo", append([]string{"copy", "--quiet"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("skopeo copy failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ensureServing starts the peer registry if it is not serving yet.
+func (d *peerDistributor) ensureServing() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.server != nil {
+		return nil
+	}
+
+	tlsConfig := d.newTLSConfig()
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(d.port)))
+	if err != nil {
+		return fmt.Errorf("failed to listen for pinned image peers: %w", err)
+	}
+
+	server := &http.Server{
+		Handler:           d,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	d.server = server
+
+	go func() {
+		klog.Infof("Serving pinned images to peers on port %d", d.port)
+		// the certificates are provided by the TLS config
+		if err := server.ServeTLS(listener, "", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			klog.Errorf("Pinned image peer registry stopped: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// newTLSConfig returns the TLS config of the peer registry, which requires
+// clients to present a certificate signed by the kubelet client CA.
+func (d *peerDistributor) newTLSConfig() *tls.Config {
+	// the serving certificate is rotated by the kubelet, so it is loaded for
+	// every connection
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, err := tls.LoadX509KeyPair(d.servingCertPath, d.servingCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kubelet serving certificate: %w", err)
+		}
+		return &cert, nil
+	}
+
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: getCertificate,
+		// the client CAs are refreshed for every prefetch, so the config is
+		// built for every connection
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			d.mu.RLock()
+			clientCAs := d.clientCAs
+			d.mu.RUnlock()
+			if clientCAs == nil {
+				return nil, errors.New("kubelet client CA bundle is not loaded")
+			}
+
+			return &tls.Config{
+				MinVersion:     tls.VersionTLS12,
+				ClientAuth:     tls.RequireAndVerifyClientCert,
+				ClientCAs:      clientCAs,
+				GetCertificate: getCertificate,
+			}, nil
+		},
+	}
+}
+
+// stopServing stops the peer registry, if it is serving, and forgets the
+// allowed peers and their CAs.
+func (d *peerDistributor) stopServing() {
+	d.mu.Lock()
+	server := d.server
+	d.server = nil
+	d.allowedPeers = map[string]string{}
+	d.clientCAs = nil
+	d.mu.Unlock()
+
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), peerShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		klog.Warningf("Failed to gracefully stop the pinned image peer registry: %v", err)
+		if err := server.Close(); err != nil {
+			klog.Warningf("Failed to stop the pinned image peer registry: %v", err)
+		}
+	}
+	klog.Infof("Stopped serving pinned images to peers")
+}
+
+// disable stops the peer registry and removes the peer cache and
+// certificates once the node is no longer in a pool which opted into peer
+// distribution.
+func (d *peerDistributor) disable() {
+	d.stopServing()
+
+	for _, dir := range []string{d.cacheDir, d.certDir} {
+		if err := os.RemoveAll(dir); err != nil {
+			klog.Warningf("Failed to remove %s: %v", dir, err)
+		}
+	}
+}
+
+// ServeHTTP implements the read-only subset of the OCI distribution API needed
+// to pull an image by digest from the peer cache. Repository names are ignored
+// since all content is addressed by digest.
+func (d *peerDistributor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !d.isAllowedPeer(r.RemoteAddr, r.TLS) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if r.URL.Path == "/v2/" || r.URL.Path == "/v2" {
+		w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	path, ok := strings.CutPrefix(r.URL.Path, "/v2/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	for _, kind := range []string{"/manifests/", "/blobs/"} {
+		idx := strings.LastIndex(path, kind)
+		if idx <= 0 {
+			continue
+		}
+
+		// Tags are not supported, only digests.
+		dgst, err := digest.Parse(path[idx+len(kind):])
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		d.serveBlob(w, r, dgst, kind == "/manifests/")
+		return
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (d *peerDistributor) serveBlob(w http.ResponseWriter, r *http.Request, dgst digest.Digest, isManifest bool) {
+	// The digest was validated, so it is safe to use in a path.
+	blobPath := filepath.Join(d.cacheDir, "blobs", dgst.Algorithm().String(), dgst.Encoded())
+
+	f, err := os.Open(blobPath)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	contentType := "application/octet-stream"
+	if isManifest {
+		contentType, err = getManifestMediaType(blobPath)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	http.ServeContent(w, r, "", stat.ModTime(), f)
+}
+
+// getManifestMediaType reads the media type of a manifest blob. Manifests
+// without a media type are assumed to be OCI image manifests.
+func getManifestMediaType(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var manifest struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", fmt.Errorf("blob is not a manifest: %w", err)
+	}
+
+	if manifest.MediaType == "" {
+		return ocispec.MediaTypeImageManifest, nil
+	}
+
+	return manifest.MediaType, nil
+}
+
+// isAllowedPeer returns whether the request comes from a peer node, from its
+// own address and with its own client certificate, which was verified during
+// the handshake.
+func (d *peerDistributor) isAllowedPeer(remoteAddr string, state *tls.ConnectionState) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return false
+	}
+	nodeName, ok := strings.CutPrefix(state.PeerCertificates[0].Subject.CommonName, nodeCommonNamePrefix)
+	if !ok {
+		return false
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	peer, ok := d.allowedPeers[host]
+	return ok && peer == nodeName
+}
@@ -0,0 +1,647 @@
+package daemon
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/fake"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/imageutils"
+)
+
+// testCA is a self-signed CA issuing certificates for the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a certificate for 127.0.0.1 signed by the CA, followed by its
+// key, as the kubelet stores them.
+func (ca *testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})...)
+}
+
+func newCABundleConfigMap(name string, ca *testCA) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ctrlcommon.OpenshiftConfigManagedNamespace},
+		Data:       map[string]string{caBundleConfigMapKey: string(ca.pem)},
+	}
+}
+
+func newPeerTestNode(name, ip string, labels map[string]string, ready bool) *corev1.Node {
+	status := corev1.ConditionTrue
+	if !ready {
+		status = corev1.ConditionFalse
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Status: corev1.NodeStatus{
+			Addresses:  []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: ip}},
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+		},
+	}
+}
+
+func newPeerTestPool() *mcfgv1.MachineConfigPool {
+	return &mcfgv1.MachineConfigPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker"},
+		Spec: mcfgv1.MachineConfigPoolSpec{
+			NodeSelector: metav1.AddLabelToSelector(&metav1.LabelSelector{}, "node-role.kubernetes.io/worker", ""),
+		},
+	}
+}
+
+func TestPeerDistributorServeHTTP(t *testing.T) {
+	cacheDir := t.TempDir()
+	blobsDir := filepath.Join(cacheDir, "blobs", "sha256")
+	require.NoError(t, os.MkdirAll(blobsDir, 0o755))
+
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q}`, ocispec.MediaTypeImageManifest))
+	manifestDigest := digest.FromBytes(manifest)
+	require.NoError(t, os.WriteFile(filepath.Join(blobsDir, manifestDigest.Encoded()), manifest, 0o644))
+
+	layer := []byte("layer contents")
+	layerDigest := digest.FromBytes(layer)
+	require.NoError(t, os.WriteFile(filepath.Join(blobsDir, layerDigest.Encoded()), layer, 0o644))
+
+	d := &peerDistributor{
+		cacheDir:     cacheDir,
+		allowedPeers: map[string]string{"10.0.0.2": "peer"},
+	}
+
+	tests := []struct {
+		name            string
+		method          string
+		path            string
+		remoteAddr      string
+		commonName      string
+		noCertificate   bool
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:       "api version check",
+			method:     http.MethodGet,
+			path:       "/v2/",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:            "manifest by digest",
+			method:          http.MethodGet,
+			path:            "/v2/org/repo/manifests/" + manifestDigest.String(),
+			wantStatus:      http.StatusOK,
+			wantContentType: ocispec.MediaTypeImageManifest,
+			wantBody:        string(manifest),
+		},
+		{
+			name:            "blob by digest",
+			method:          http.MethodGet,
+			path:            "/v2/org/repo/blobs/" + layerDigest.String(),
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/octet-stream",
+			wantBody:        string(layer),
+		},
+		{
+			name:       "blob head",
+			method:     http.MethodHead,
+			path:       "/v2/org/repo/blobs/" + layerDigest.String(),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "manifest by tag is not supported",
+			method:     http.MethodGet,
+			path:       "/v2/org/repo/manifests/latest",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "missing blob",
+			method:     http.MethodGet,
+			path:       "/v2/org/repo/blobs/" + digest.FromString("missing").String(),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid digest cannot escape cache",
+			method:     http.MethodGet,
+			path:       "/v2/org/repo/blobs/sha256:../../../etc/passwd",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "unknown peer",
+			method:     http.MethodGet,
+			path:       "/v2/org/repo/blobs/" + layerDigest.String(),
+			remoteAddr: "10.0.0.3:40000",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:          "no client certificate",
+			method:        http.MethodGet,
+			path:          "/v2/org/repo/blobs/" + layerDigest.String(),
+			noCertificate: true,
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:       "certificate of another node",
+			method:     http.MethodGet,
+			path:       "/v2/org/repo/blobs/" + layerDigest.String(),
+			commonName: "system:node:other",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "certificate of a user",
+			method:     http.MethodGet,
+			path:       "/v2/org/repo/blobs/" + layerDigest.String(),
+			commonName: "peer",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "writes are not allowed",
+			method:     http.MethodPost,
+			path:       "/v2/org/repo/blobs/uploads/",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.RemoteAddr = "10.0.0.2:40000"
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			if !tt.noCertificate {
+				commonName := "system:node:peer"
+				if tt.commonName != "" {
+					commonName = tt.commonName
+				}
+				req.TLS = &tls.ConnectionState{
+					PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: commonName}}},
+				}
+			}
+
+			rec := httptest.NewRecorder()
+			d.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantContentType != "" {
+				assert.Equal(t, tt.wantContentType, rec.Header().Get("Content-Type"))
+			}
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestPeerDistributorFetch(t *testing.T) {
+	image := "quay.io/org/repo@sha256:" + strings.Repeat("a", 64)
+	errCopy := errors.New("copy failed")
+
+	tests := []struct {
+		name       string
+		image      string
+		peers      []string
+		failPeers  bool
+		wantSource pinnedImageSource
+		wantErr    bool
+		wantCopies int
+	}{
+		{
+			name:       "fetched from first peer",
+			image:      image,
+			peers:      []string{"10.0.0.2:9645", "10.0.0.3:9645"},
+			wantSource: pinnedImageSourcePeer,
+			wantCopies: 2,
+		},
+		{
+			name:       "falls back to upstream when peers fail",
+			image:      image,
+			peers:      []string{"10.0.0.2:9645", "10.0.0.3:9645"},
+			failPeers:  true,
+			wantSource: pinnedImageSourceUpstream,
+			wantCopies: 4,
+		},
+		{
+			name:       "upstream when there are no peers",
+			image:      image,
+			wantSource: pinnedImageSourceUpstream,
+			wantCopies: 2,
+		},
+		{
+			name:    "tagged images are not supported",
+			image:   "quay.io/org/repo:latest",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			copies := [][]string{}
+			d := &peerDistributor{
+				nodeName: "node-a",
+				cacheDir: "/cache",
+				copyImage: func(_ context.Context, args ...string) error {
+					copies = append(copies, args)
+					src := args[len(args)-2]
+					if tt.failPeers && strings.HasPrefix(src, "docker://10.0.0.") {
+						return errCopy
+					}
+					return nil
+				},
+			}
+
+			source, err := d.fetch(context.Background(), tt.image, &peerPrefetchConfig{peers: tt.peers, certDir: "/certs"}, "/auth.json")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSource, source)
+			require.Len(t, copies, tt.wantCopies)
+
+			ociRef := "oci:/cache:" + strings.Repeat("a", 64)
+			if tt.wantSource == pinnedImageSourcePeer {
+				peerImage := copies[0][len(copies[0])-2]
+				assert.Regexp(t, `^docker://10\.0\.0\.[23]:9645/org/repo@sha256:a{64}$`, peerImage)
+				assert.Contains(t, copies[0], "--src-no-creds")
+				assert.Subset(t, copies[0], []string{"--src-cert-dir", "/certs"})
+				assert.NotContains(t, copies[0], "--src-tls-verify=false")
+			}
+			// every fetch ends by importing the staged image into the container storage
+			assert.Equal(t, []string{"--preserve-digests", ociRef, "containers-storage:" + tt.image}, copies[len(copies)-1])
+		})
+	}
+}
+
+func TestPeerDistributorFetchVerifiesImage(t *testing.T) {
+	manifest := []byte(`{"schemaVersion": 2}`)
+	image := "quay.io/org/repo@" + digest.FromBytes(manifest).String()
+
+	tests := []struct {
+		name         string
+		policy       string
+		wantImported bool
+	}{
+		{
+			name:         "accepted by the signature policy",
+			policy:       acceptAnythingPolicy,
+			wantImported: true,
+		},
+		{
+			name:   "rejected by the signature policy",
+			policy: rejectPolicy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			policyPath := filepath.Join(dir, "policy.json")
+			require.NoError(t, os.WriteFile(policyPath, []byte(tt.policy), 0o644))
+
+			verifier := newPinnedImageVerifier(policyPath, filepath.Join(dir, "registries.d"), "", "")
+			verifier.openImage = func(_ context.Context, _ *types.SystemContext, name string, _, _ bool) (types.UnparsedImage, func() error, error) {
+				ref, err := imageutils.ParseImageName(name)
+				if err != nil {
+					return nil, nil, err
+				}
+				return &fakeUnparsedImage{ref: ref, manifest: manifest}, func() error { return nil }, nil
+			}
+
+			imported := false
+			d := &peerDistributor{
+				nodeName: "node-a",
+				cacheDir: "/cache",
+				verifier: verifier,
+				copyImage: func(_ context.Context, args ...string) error {
+					if strings.HasPrefix(args[len(args)-1], "containers-storage:") {
+						imported = true
+					}
+					return nil
+				},
+			}
+
+			source, err := d.fetch(context.Background(), image, &peerPrefetchConfig{peers: []string{"10.0.0.2:9645"}, certDir: "/certs"}, "/auth.json")
+			assert.Equal(t, tt.wantImported, imported)
+			if !tt.wantImported {
+				assert.ErrorIs(t, err, errImageRejectedByPolicy)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, pinnedImageSourcePeer, source)
+		})
+	}
+}
+
+func TestOrderPeersForImage(t *testing.T) {
+	peers := []string{"10.0.0.1:9645", "10.0.0.2:9645", "10.0.0.3:9645", "10.0.0.4:9645", "10.0.0.5:9645"}
+
+	d := &peerDistributor{nodeName: "node-a"}
+
+	ordered := d.orderPeersForImage("quay.io/org/repo@sha256:abc", peers)
+	assert.Len(t, ordered, maxPeerAttempts)
+	assert.Equal(t, ordered, d.orderPeersForImage("quay.io/org/repo@sha256:abc", peers), "ordering should be stable")
+	assert.Subset(t, peers, ordered)
+
+	// Different nodes should not all try the same peer first.
+	firstChoices := sets.New[string]()
+	for i := 0; i < 20; i++ {
+		other := &peerDistributor{nodeName: fmt.Sprintf("node-%d", i)}
+		firstChoices.Insert(other.orderPeersForImage("quay.io/org/repo@sha256:abc", peers)[0])
+	}
+	assert.Greater(t, firstChoices.Len(), 1)
+}
+
+func TestGetPeerPrefetchConfig(t *testing.T) {
+	worker := map[string]string{"node-role.kubernetes.io/worker": ""}
+	master := map[string]string{"node-role.kubernetes.io/master": ""}
+
+	kubeClient := fake.NewSimpleClientset(
+		newPeerTestNode("self", "10.0.0.1", worker, true),
+		newPeerTestNode("peer", "10.0.0.2", worker, true),
+		newPeerTestNode("not-ready", "10.0.0.3", worker, false),
+		newPeerTestNode("master", "10.0.0.4", master, true),
+	)
+	pool := newPeerTestPool()
+
+	tmpDir := t.TempDir()
+	d := newPeerDistributor("self", kubeClient, nil)
+	d.clientCertPath = filepath.Join(tmpDir, "kubelet-client-current.pem")
+	d.certDir = filepath.Join(tmpDir, "certs")
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("cert")})
+	key := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte("key")})
+	require.NoError(t, os.WriteFile(d.clientCertPath, append(append([]byte{}, cert...), key...), 0o600))
+
+	// the CA bundles are required to authenticate peers
+	_, err := d.getPeerPrefetchConfig(context.Background(), pool)
+	require.Error(t, err)
+
+	servingCA := newTestCA(t, "kubelet-signer")
+	clientCA := newTestCA(t, "kube-csr-signer")
+	for name, ca := range map[string]*testCA{kubeletServingCAConfigMap: servingCA, kubeletClientCAConfigMap: clientCA} {
+		_, err := kubeClient.CoreV1().ConfigMaps(ctrlcommon.OpenshiftConfigManagedNamespace).Create(context.Background(), newCABundleConfigMap(name, ca), metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	cfg, err := d.getPeerPrefetchConfig(context.Background(), pool)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.2:9645"}, cfg.peers)
+	assert.Equal(t, d.certDir, cfg.certDir)
+
+	peerCert := func(nodeName string) *tls.ConnectionState {
+		return &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "system:node:" + nodeName}}}}
+	}
+	assert.True(t, d.isAllowedPeer("10.0.0.2:40000", peerCert("peer")))
+	assert.False(t, d.isAllowedPeer("10.0.0.2:40000", peerCert("master")))
+	assert.False(t, d.isAllowedPeer("10.0.0.4:40000", peerCert("master")))
+
+	// the client certificate and its key are split for skopeo, which
+	// verifies the peers with the CA signing their serving certificates
+	for name, want := range map[string][]byte{"client.cert": cert, "client.key": key, "ca.crt": servingCA.pem} {
+		got, err := os.ReadFile(filepath.Join(d.certDir, name))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, name)
+	}
+	info, err := os.Stat(filepath.Join(d.certDir, "client.key"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// opting out forgets the peers and removes the certificates
+	d.disable()
+	assert.False(t, d.isAllowedPeer("10.0.0.2:40000", peerCert("peer")))
+	assert.NoDirExists(t, d.certDir)
+}
+
+func TestPeerDistributorMutualTLS(t *testing.T) {
+	// the kubelet serving and client certificates are signed by distinct CAs,
+	// neither of which is in the kubelet CA bundle of the node
+	servingCA := newTestCA(t, "kubelet-signer")
+	clientCA := newTestCA(t, "kube-csr-signer")
+
+	worker := map[string]string{"node-role.kubernetes.io/worker": ""}
+	kubeClient := fake.NewSimpleClientset(
+		newPeerTestNode("self", "127.0.0.1", worker, true),
+		newPeerTestNode("peer", "127.0.0.1", worker, true),
+		newCABundleConfigMap(kubeletServingCAConfigMap, servingCA),
+		newCABundleConfigMap(kubeletClientCAConfigMap, clientCA),
+	)
+	pool := newPeerTestPool()
+
+	newNodeDistributor := func(nodeName string) *peerDistributor {
+		dir := t.TempDir()
+		d := newPeerDistributor(nodeName, kubeClient, nil)
+		d.cacheDir = filepath.Join(dir, "cache")
+		d.certDir = filepath.Join(dir, "certs")
+		d.servingCertPath = filepath.Join(dir, "kubelet-server-current.pem")
+		d.clientCertPath = filepath.Join(dir, "kubelet-client-current.pem")
+		require.NoError(t, os.WriteFile(d.servingCertPath, servingCA.issue(t, "system:node:"+nodeName, x509.ExtKeyUsageServerAuth), 0o600))
+		require.NoError(t, os.WriteFile(d.clientCertPath, clientCA.issue(t, "system:node:"+nodeName, x509.ExtKeyUsageClientAuth), 0o600))
+		return d
+	}
+
+	server := newNodeDistributor("self")
+	client := newNodeDistributor("peer")
+
+	srv := httptest.NewUnstartedServer(server)
+	srv.TLS = server.newTLSConfig()
+	srv.StartTLS()
+	defer srv.Close()
+
+	// configures the client as skopeo does from the certificate directory
+	get := func(t *testing.T, certDir string) (*http.Response, error) {
+		cert, err := tls.LoadX509KeyPair(filepath.Join(certDir, "client.cert"), filepath.Join(certDir, "client.key"))
+		require.NoError(t, err)
+		caBundle, err := os.ReadFile(filepath.Join(certDir, "ca.crt"))
+		require.NoError(t, err)
+		rootCAs := x509.NewCertPool()
+		require.True(t, rootCAs.AppendCertsFromPEM(caBundle))
+
+		httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			RootCAs:      rootCAs,
+			Certificates: []tls.Certificate{cert},
+		}}}
+		return httpClient.Get(srv.URL + "/v2/")
+	}
+
+	// peers are refused until the client CAs are loaded
+	cfg, err := client.getPeerPrefetchConfig(context.Background(), pool)
+	require.NoError(t, err)
+	resp, err := get(t, cfg.certDir)
+	if err == nil {
+		resp.Body.Close()
+	}
+	assert.Error(t, err)
+
+	_, err = server.getPeerPrefetchConfig(context.Background(), pool)
+	require.NoError(t, err)
+	resp, err = get(t, cfg.certDir)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// a client certificate which is not signed by the client CA is refused
+	require.NoError(t, os.WriteFile(client.clientCertPath, servingCA.issue(t, "system:node:peer", x509.ExtKeyUsageClientAuth), 0o600))
+	cfg, err = client.getPeerPrefetchConfig(context.Background(), pool)
+	require.NoError(t, err)
+	resp, err = get(t, cfg.certDir)
+	if err == nil {
+		resp.Body.Close()
+	}
+	assert.Error(t, err)
+}
+
+func TestPeerDistributorPruneCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	blobsDir := filepath.Join(cacheDir, "blobs", "sha256")
+	require.NoError(t, os.MkdirAll(blobsDir, 0o755))
+
+	writeBlob := func(data []byte) ocispec.Descriptor {
+		dgst := digest.FromBytes(data)
+		require.NoError(t, os.WriteFile(filepath.Join(blobsDir, dgst.Encoded()), data, 0o644))
+		return ocispec.Descriptor{Digest: dgst, Size: int64(len(data))}
+	}
+	writeManifest := func(config ocispec.Descriptor, layers ...ocispec.Descriptor) ocispec.Descriptor {
+		data, err := json.Marshal(ocispec.Manifest{MediaType: ocispec.MediaTypeImageManifest, Config: config, Layers: layers})
+		require.NoError(t, err)
+		return writeBlob(data)
+	}
+
+	shared := writeBlob([]byte("shared layer"))
+	keptManifest := writeManifest(writeBlob([]byte("kept config")), shared, writeBlob([]byte("kept layer")))
+	removedConfig := writeBlob([]byte("removed config"))
+	removedLayer := writeBlob([]byte("removed layer"))
+	removedManifest := writeManifest(removedConfig, shared, removedLayer)
+	leftover := writeBlob([]byte("interrupted fetch"))
+
+	keptImage := "quay.io/org/kept@" + keptManifest.Digest.String()
+	removedImage := "quay.io/org/removed@" + removedManifest.Digest.String()
+
+	index := ocispec.Index{}
+	for _, desc := range []ocispec.Descriptor{keptManifest, removedManifest} {
+		desc.Annotations = map[string]string{ocispec.AnnotationRefName: desc.Digest.Encoded()}
+		index.Manifests = append(index.Manifests, desc)
+	}
+	data, err := json.Marshal(index)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "index.json"), data, 0o644))
+
+	d := &peerDistributor{cacheDir: cacheDir, maxCacheBytes: math.MaxInt64}
+	assert.True(t, d.isStaged(keptImage))
+	assert.True(t, d.isStaged(removedImage))
+
+	reclaimed, err := d.pruneCache([]string{keptImage})
+	require.NoError(t, err)
+	assert.Equal(t, removedManifest.Size+removedConfig.Size+removedLayer.Size+leftover.Size, reclaimed)
+
+	assert.True(t, d.isStaged(keptImage))
+	assert.False(t, d.isStaged(removedImage))
+	assert.FileExists(t, filepath.Join(blobsDir, shared.Digest.Encoded()))
+	assert.NoFileExists(t, filepath.Join(blobsDir, removedLayer.Digest.Encoded()))
+	assert.NoFileExists(t, filepath.Join(blobsDir, leftover.Digest.Encoded()))
+
+	size, err := d.cacheSize()
+	require.NoError(t, err)
+	assert.Positive(t, size)
+
+	// nothing left to reclaim
+	reclaimed, err = d.pruneCache([]string{keptImage})
+	require.NoError(t, err)
+	assert.Zero(t, reclaimed)
+
+	// the images staged first are evicted once the cache exceeds its limit
+	newerConfig := writeBlob([]byte("newer config"))
+	newerManifest := writeManifest(newerConfig, shared)
+	newerImage := "quay.io/org/newer@" + newerManifest.Digest.String()
+	newerManifest.Annotations = map[string]string{ocispec.AnnotationRefName: newerManifest.Digest.Encoded()}
+	index.Manifests = []ocispec.Descriptor{index.Manifests[0], newerManifest}
+	data, err = json.Marshal(index)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "index.json"), data, 0o644))
+
+	size, err = d.cacheSize()
+	require.NoError(t, err)
+	d.maxCacheBytes = size - 1
+
+	reclaimed, err = d.pruneCache([]string{keptImage, newerImage})
+	require.NoError(t, err)
+	assert.Positive(t, reclaimed)
+	assert.False(t, d.isStaged(keptImage))
+	assert.True(t, d.isStaged(newerImage))
+	assert.FileExists(t, filepath.Join(blobsDir, shared.Digest.Encoded()))
+	assert.FileExists(t, filepath.Join(blobsDir, newerConfig.Digest.Encoded()))
+	assert.NoFileExists(t, filepath.Join(blobsDir, keptManifest.Digest.Encoded()))
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"reflect"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -28,8 +29,10 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	clientset "k8s.io/client-go/kubernetes"
 	corev1lister "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
@@ -67,6 +70,9 @@ const (
 	// controller configuration
 	maxRetriesController = 15
 
+	// timeout for unpinning the images of a deleted pool
+	pinnedImageUnpinTimeout = time.Minute
+
 	// mcn looks for conditions with this prefix if seen will degrade the pool
 	degradeMessagePrefix = "Error:"
 )
@@ -92,6 +98,9 @@ type PinnedImageSetManager struct {
 	mcpLister mcfglistersv1.MachineConfigPoolLister
 	mcpSynced cache.InformerSynced
 
+	mcnLister mcfglistersv1.MachineConfigNodeLister
+	mcnSynced cache.InformerSynced
+
 	mcfgClient mcfgclientset.Interface
 
 	prefetchCh chan prefetch
@@ -114,6 +123,18 @@ type PinnedImageSetManager struct {
 	// cache for reusable image information
 	cache *imageCache
 
+	// fetches images from and serves images to peer nodes when enabled
+	peers *peerDistributor
+
+	// removes images which are no longer pinned when enabled
+	gc *pinnedImageGC
+
+	// tracks the prefetch state of each image for the status
+	imageStatus *pinnedImageStatusTracker
+
+	// checks images against the signature policy before they are pinned
+	verifier *pinnedImageVerifier
+
 	syncHandler              func(string) error
 	enqueueMachineConfigPool func(*mcfgv1.MachineConfigPool)
 	queue                    workqueue.TypedRateLimitingInterface[string]
@@ -131,10 +152,12 @@ type PinnedImageSetManager struct {
 func NewPinnedImageSetManager(
 	nodeName string,
 	criClient *cri.Client,
+	kubeClient clientset.Interface,
 	mcfgClient mcfgclientset.Interface,
 	imageSetInformer mcfginformersv1.PinnedImageSetInformer,
 	nodeInformer coreinformersv1.NodeInformer,
 	mcpInformer mcfginformersv1.MachineConfigPoolInformer,
+	mcnInformer mcfginformersv1.MachineConfigNodeInformer,
 	minStorageAvailableBytes resource.Quantity,
 	runtimeEndpoint,
 	authFilePath,
@@ -142,6 +165,7 @@ func NewPinnedImageSetManager(
 	prefetchTimeout time.Duration,
 	fgHandler ctrlcommon.FeatureGatesHandler,
 ) *PinnedImageSetManager {
+	verifier := newPinnedImageVerifier(constants.ContainerRegistryPolicyPath, constants.SigstoreRegistriesConfigDir, registryCfgPath, authFilePath)
 	p := &PinnedImageSetManager{
 		nodeName:                 nodeName,
 		mcfgClient:               mcfgClient,
@@ -156,6 +180,11 @@ func NewPinnedImageSetManager(
 			workqueue.TypedRateLimitingQueueConfig[string]{Name: "pinned-image-set-manager"}),
 		prefetchCh: make(chan prefetch, defaultPrefetchWorkers*2),
 		criClient:  criClient,
+		peers:      newPeerDistributor(nodeName, kubeClient, verifier),
+		gc:         newPinnedImageGC(criClient),
+
+		imageStatus: newPinnedImageStatusTracker(),
+		verifier:    verifier,
 		backoff: wait.Backoff{
 			Steps:    maxRetries,
 			Duration: retryDuration,
@@ -176,6 +205,9 @@ func NewPinnedImageSetManager(
 	p.mcpLister = mcpInformer.Lister()
 	p.mcpSynced = mcpInformer.Informer().HasSynced
 
+	p.mcnLister = mcnInformer.Lister()
+	p.mcnSynced = mcnInformer.Informer().HasSynced
+
 	// this must be done after the enqueueMachineConfigPool is configured to
 	// avoid panics when the event handler is called.
 	mcpInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -226,11 +258,44 @@ func (p *PinnedImageSetManager) sync(key string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), p.prefetchTimeout)
 	// cancel any currently running tasks in the worker pool
 	p.resetWorkload(cancel)
-	if err := p.updateStatusProgressing([]*mcfgv1.MachineConfigPool{primaryPool}); err != nil {
+	if err := p.updateStatusProgressing([]*mcfgv1.MachineConfigPool{primaryPool}, fmt.Sprintf("node is prefetching images: %s", node.Name)); err != nil {
 		klog.Errorf("failed to update status: %v", err)
 	}
 
-	if err := p.syncMachineConfigPools(ctx, pools); err != nil {
+	policy, err := getPrefetchPolicy(pools)
+	if err != nil {
+		klog.Error(err)
+		if err := p.updateStatusError([]*mcfgv1.MachineConfigPool{primaryPool}, err); err != nil {
+			klog.Errorf("failed to update status: %v", err)
+		}
+		return err
+	}
+	throttle := p.newPrefetchThrottle(policy, pools)
+
+	err = p.syncMachineConfigPools(ctx, pools, throttle)
+	// the progress is reported at a limited rate while pulling
+	throttle.reportProgress(true)
+
+	imageNames, namesErr := p.getPinnedImageNames(pools)
+	if namesErr != nil {
+		klog.Warningf("failed to get pinned images: %v", namesErr)
+	} else {
+		p.imageStatus.prune(imageNames)
+		updatePinnedImageMetrics(p.imageStatus.getEntries(imageNames))
+	}
+
+	if err != nil {
+		// being held back by the prefetch policy is expected and not an error
+		if throttled, retryAfter := throttle.throttled(); throttled {
+			message := fmt.Sprintf("node is waiting to prefetch images: %v", err)
+			klog.Info(message)
+			if err := p.updateStatusProgressing([]*mcfgv1.MachineConfigPool{primaryPool}, message); err != nil {
+				klog.Errorf("failed to update status: %v", err)
+			}
+			p.queue.AddAfter(key, retryAfter)
+			return nil
+		}
+
 		if errors.Is(err, context.DeadlineExceeded) {
 			ctxErr := fmt.Errorf("%w: %v", errRequeueAfterTimeout, p.prefetchTimeout)
 			if err := p.updateStatusError([]*mcfgv1.MachineConfigPool{primaryPool}, ctxErr); err != nil {
@@ -247,54 +312,164 @@ func (p *PinnedImageSetManager) sync(key string) error {
 		return err
 	}
 
-	return p.updateStatusProgressingComplete([]*mcfgv1.MachineConfigPool{primaryPool}, "All pinned image sets complete")
+	message := "All pinned image sets complete"
+	if namesErr == nil {
+		message = fmt.Sprintf("%s: %s", message, p.getPinnedImageSummary(imageNames))
+	}
+	return p.updateStatusProgressingComplete([]*mcfgv1.MachineConfigPool{primaryPool}, message)
 }
 
-func (p *PinnedImageSetManager) syncMachineConfigPools(ctx context.Context, pools []*mcfgv1.MachineConfigPool) error {
-	images := make([]mcfgv1.PinnedImageRef, 0, 100)
+func (p *PinnedImageSetManager) syncMachineConfigPools(ctx context.Context, pools []*mcfgv1.MachineConfigPool, throttle *prefetchThrottle) error {
+	peerDistribution := slices.ContainsFunc(pools, isPinnedImagePeerDistributionEnabled)
+	if !peerDistribution && p.peers != nil {
+		p.peers.disable()
+	}
+
 	for _, pool := range pools {
-		if err := p.syncMachineConfigPool(ctx, pool); err != nil {
+		if err := p.syncMachineConfigPool(ctx, pool, throttle); err != nil {
 			return err
 		}
+	}
 
-		// collect all unique images from all pools
-		for _, image := range pool.Spec.PinnedImageSets {
-			imageSet, err := p.imageSetLister.Get(image.Name)
-			if err != nil {
-				if apierrors.IsNotFound(err) {
-					klog.Warningf("PinnedImageSet %q not found", image.Name)
-					continue
-				}
-				return fmt.Errorf("failed to get PinnedImageSet %q: %w", image.Name, err)
-			}
-			images = append(images, imageSet.Spec.PinnedImages...)
-		}
+	imageNames, err := p.getPinnedImageNames(pools)
+	if err != nil {
+		return err
 	}
 
-	// verify all images available if not clear the cache and requeue
-	imageNames := uniqueSortedImageNames(images)
+	// verify all images available if not clear the cache and requeue. images
+	// refused by the signature policy are not pinned, even if they were
+	// pinned before the policy changed.
+	pinned := make([]string, 0, len(imageNames))
+	var rejected []error
+	unverified := false
 	for _, image := range imageNames {
 		exists, err := p.criClient.ImageStatus(ctx, image)
 		if err != nil {
 			return err
 		}
+		if err := p.verifier.verify(ctx, image, exists); err != nil {
+			if errors.Is(err, errImageRejectedByPolicy) {
+				p.imageStatus.setFailed(image, err, 0)
+				rejected = append(rejected, err)
+				continue
+			}
+			// the image stays pinned until it can be verified, so that an
+			// unreachable registry does not unpin it
+			klog.Warningf("failed to verify image %q, retrying in %v: %v", image, pinnedImageVerifyRetryInterval, err)
+			unverified = true
+		}
 		if !exists {
 			p.cache.Clear()
 			return fmt.Errorf("%w: image removed during sync: %s", errFailedToPullImage, image)
 		}
+		p.imageStatus.setPresent(image)
+		pinned = append(pinned, image)
+	}
+
+	previouslyPinned, err := getCrioPinnedImages(crioPinnedImagesDropInFilePath)
+	if err != nil {
+		klog.Warningf("failed to get previously pinned images: %v", err)
 	}
 
 	// write config and reload crio last to allow a window for kubelet to gc
 	// images in an emergency
-	if err := ensureCrioPinnedImagesConfigFile(crioPinnedImagesDropInFilePath, imageNames); err != nil {
+	if err := ensureCrioPinnedImagesConfigFile(crioPinnedImagesDropInFilePath, pinned); err != nil {
 		klog.Errorf("failed to write crio config file: %v", err)
 		return err
 	}
 
-	return nil
+	// images can only be removed once crio no longer pins them
+	p.garbageCollectUnpinnedImages(ctx, pools, previouslyPinned, pinned)
+
+	if unverified {
+		p.queue.AddAfter(pools[0].Name, pinnedImageVerifyRetryInterval)
+	}
+
+	// the peer cache only holds images which are still pinned, within its limit
+	if peerDistribution && p.peers != nil {
+		reclaimed, err := p.peers.pruneCache(pinned)
+		if err != nil {
+			klog.Warningf("failed to prune pinned image peer cache: %v", err)
+		} else if reclaimed > 0 {
+			klog.Infof("Reclaimed %d bytes from the pinned image peer cache", reclaimed)
+		}
+	}
+
+	return errors.Join(rejected...)
+}
+
+// garbageCollectUnpinnedImages removes images which are no longer pinned
+// according to the garbage collection policy of the pools. Failing to remove
+// images does not fail the sync since the kubelet image garbage collection
+// will eventually reclaim them.
+func (p *PinnedImageSetManager) garbageCollectUnpinnedImages(ctx context.Context, pools []*mcfgv1.MachineConfigPool, previouslyPinned, pinned []string) {
+	if p.gc == nil {
+		return
+	}
+
+	cfg, err := getPinnedImageGCConfig(pools)
+	if err != nil {
+		klog.Errorf("failed to get pinned image garbage collection policy: %v", err)
+		return
+	}
+
+	nextRun, err := p.gc.collect(ctx, cfg, previouslyPinned, pinned)
+	if err != nil {
+		klog.Errorf("failed to garbage collect unpinned images: %v", err)
+		return
+	}
+
+	// wait for the grace period of the remaining images
+	if nextRun > 0 {
+		p.queue.AddAfter(cfg.poolName, nextRun)
+	}
 }
 
-func (p *PinnedImageSetManager) syncMachineConfigPool(ctx context.Context, pool *mcfgv1.MachineConfigPool) error {
+// getPinnedImageNames returns the unique sorted names of the images in all
+// image sets of the pools.
+func (p *PinnedImageSetManager) getPinnedImageNames(pools []*mcfgv1.MachineConfigPool) ([]string, error) {
+	images := make([]mcfgv1.PinnedImageRef, 0, 100)
+	for _, pool := range pools {
+		for _, image := range pool.Spec.PinnedImageSets {
+			imageSet, err := p.imageSetLister.Get(image.Name)
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					klog.Warningf("PinnedImageSet %q not found", image.Name)
+					continue
+				}
+				return nil, fmt.Errorf("failed to get PinnedImageSet %q: %w", image.Name, err)
+			}
+			images = append(images, imageSet.Spec.PinnedImages...)
+		}
+	}
+
+	return uniqueSortedImageNames(images), nil
+}
+
+// newPrefetchThrottle creates the throttle enforcing the prefetch policy of
+// the pools. The throttle reports progress in the PinnedImagesPulling
+// condition of the MachineConfigNode. Returns nil if there is no policy.
+func (p *PinnedImageSetManager) newPrefetchThrottle(policy *prefetchPolicy, pools []*mcfgv1.MachineConfigPool) *prefetchThrottle {
+	if policy == nil {
+		return nil
+	}
+
+	poolPulling := func() (int, error) {
+		// a prefetch is cancelled after the prefetch timeout, so any progress
+		// older than that is left over from a node which stopped pulling
+		return p.getPoolPulling(policy.poolName, 2*p.prefetchTimeout)
+	}
+
+	report := func(progress prefetchProgress) {
+		if err := p.updateStatusPrefetchProgress(pools, progress); err != nil {
+			klog.Warningf("failed to update pinned image prefetch progress: %v", err)
+		}
+	}
+
+	return newPrefetchThrottle(policy, poolPulling, report)
+}
+
+func (p *PinnedImageSetManager) syncMachineConfigPool(ctx context.Context, pool *mcfgv1.MachineConfigPool, throttle *prefetchThrottle) error {
 	if pool.Spec.PinnedImageSets == nil {
 		return nil
 	}
@@ -307,7 +482,7 @@ func (p *PinnedImageSetManager) syncMachineConfigPool(ctx context.Context, pool
 		klog.Infof("Reconciling pinned image set: %s: generation: %d", ref.Name, imageSet.GetGeneration())
 
 		// verify storage per image set
-		if err := p.checkNodeAllocatableStorage(ctx, imageSet); err != nil {
+		if err := p.checkNodeAllocatableStorage(ctx, imageSet, isPinnedImagePeerDistributionEnabled(pool)); err != nil {
 			return err
 		}
 		imageSets = append(imageSets, imageSet)
@@ -315,10 +490,27 @@ func (p *PinnedImageSetManager) syncMachineConfigPool(ctx context.Context, pool
 	// images are cached with size information
 	p.cache.ClearDigests()
 
-	return p.prefetchImageSets(ctx, imageSets...)
+	opts := prefetchOptions{throttle: throttle}
+	if isPinnedImagePeerDistributionEnabled(pool) {
+		// the peers and their CAs are loaded before serving them
+		cfg, err := p.peers.getPeerPrefetchConfig(ctx, pool)
+		if err != nil {
+			return err
+		}
+		if err := p.peers.ensureServing(); err != nil {
+			return err
+		}
+		klog.Infof("Peer distribution enabled for pool %q with %d peer(s)", pool.Name, len(cfg.peers))
+		opts.peerCfg = cfg
+	}
+
+	return p.prefetchImageSets(ctx, opts, imageSets...)
 }
 
-func (p *PinnedImageSetManager) checkNodeAllocatableStorage(ctx context.Context, imageSet *mcfgv1.PinnedImageSet) error {
+// checkNodeAllocatableStorage verifies the node has enough storage for the
+// images of the image set, including their copy in the peer cache when the
+// images are staged for peers.
+func (p *PinnedImageSetManager) checkNodeAllocatableStorage(ctx context.Context, imageSet *mcfgv1.PinnedImageSet, peerCache bool) error {
 	node, err := p.nodeLister.Get(p.nodeName)
 	if err != nil {
 		return fmt.Errorf("failed to get node %q: %w", p.nodeName, err)
@@ -339,11 +531,19 @@ func (p *PinnedImageSetManager) checkNodeAllocatableStorage(ctx context.Context,
 		return fmt.Errorf("%w capacity: %d, required: %d", errInsufficientStorage, capacity, p.minStorageAvailableBytes.Value())
 	}
 
-	return p.checkImagePayloadStorage(ctx, imageSet.Spec.PinnedImages, capacity)
+	if peerCache {
+		cacheSize, err := p.peers.cacheSize()
+		if err != nil {
+			return fmt.Errorf("failed to get the size of the pinned image peer cache: %w", err)
+		}
+		capacity -= cacheSize
+	}
+
+	return p.checkImagePayloadStorage(ctx, imageSet.Spec.PinnedImages, capacity, peerCache)
 }
 
 // prefetchImageSets schedules the prefetching of images for the given image sets and waits for completion.
-func (p *PinnedImageSetManager) prefetchImageSets(ctx context.Context, imageSets ...*mcfgv1.PinnedImageSet) error {
+func (p *PinnedImageSetManager) prefetchImageSets(ctx context.Context, opts prefetchOptions, imageSets ...*mcfgv1.PinnedImageSet) error {
 	registryAuth, err := newRegistryAuth(p.authFilePath, p.registryCfgPath)
 	if err != nil {
 		return err
@@ -365,7 +565,7 @@ func (p *PinnedImageSetManager) prefetchImageSets(ctx context.Context, imageSets
 				continue
 			}
 		}
-		if err := p.scheduleWork(ctx, p.prefetchCh, registryAuth, imageSet.Spec.PinnedImages, monitor); err != nil {
+		if err := p.scheduleWork(ctx, p.prefetchCh, registryAuth, imageSet.Spec.PinnedImages, monitor, opts); err != nil {
 			return err
 		}
 	}
@@ -386,7 +586,7 @@ func (p *PinnedImageSetManager) prefetchImageSets(ctx context.Context, imageSets
 }
 
 // scheduleWork schedules the prefetch work for the images and collects the first error encountered.
-func (p *PinnedImageSetManager) scheduleWork(ctx context.Context, prefetchCh chan prefetch, registryAuth *registryAuth, prefetchImages []mcfgv1.PinnedImageRef, monitor *prefetchMonitor) error {
+func (p *PinnedImageSetManager) scheduleWork(ctx context.Context, prefetchCh chan prefetch, registryAuth *registryAuth, prefetchImages []mcfgv1.PinnedImageRef, monitor *prefetchMonitor, opts prefetchOptions) error {
 	totalImages := len(prefetchImages)
 	updateIncrement := totalImages / 4
 	if updateIncrement == 0 {
@@ -409,6 +609,7 @@ func (p *PinnedImageSetManager) scheduleWork(ctx context.Context, prefetchCh cha
 				imageInfo, ok := value.(imageInfo)
 				if ok {
 					if imageInfo.Pulled {
+						opts.throttle.markDone()
 						scheduledImages++
 						continue
 					}
@@ -420,10 +621,12 @@ func (p *PinnedImageSetManager) scheduleWork(ctx context.Context, prefetchCh cha
 				return fmt.Errorf("failed to get auth config for image %s: %w", image, err)
 			}
 			monitor.Add(1)
+			opts.throttle.enqueue()
 			prefetchCh <- prefetch{
 				image:   image,
 				auth:    authConfig,
 				monitor: monitor,
+				opts:    opts,
 			}
 
 			scheduledImages++
@@ -436,28 +639,38 @@ func (p *PinnedImageSetManager) scheduleWork(ctx context.Context, prefetchCh cha
 	return nil
 }
 
-func (p *PinnedImageSetManager) checkImagePayloadStorage(ctx context.Context, images []mcfgv1.PinnedImageRef, capacity int64) error {
+func (p *PinnedImageSetManager) checkImagePayloadStorage(ctx context.Context, images []mcfgv1.PinnedImageRef, capacity int64, peerCache bool) error {
 	// calculate total required storage for all images.
 	requiredStorage := int64(0)
 	for _, image := range images {
 		imageName := strings.TrimSpace(string(image.Name))
 
+		// account for decompression
+		factor := int64(2)
+		// fetched images are also staged, compressed, in the peer cache
+		if peerCache && !p.peers.isStaged(imageName) {
+			factor++
+		}
+
 		// check cache if image is pulled
+		pulled := false
 		if value, found := p.cache.Get(imageName); found {
 			imageInfo, ok := value.(imageInfo)
 			if ok {
-				if imageInfo.Pulled {
-					continue
-				}
+				pulled = imageInfo.Pulled
 			}
 		}
 
-		exists, err := p.criClient.ImageStatus(ctx, imageName)
-		if err != nil {
-			return err
+		if !pulled {
+			exists, err := p.criClient.ImageStatus(ctx, imageName)
+			if err != nil {
+				return err
+			}
+			pulled = exists
 		}
-		if exists {
-			// do not calculate storage if the image already exists.
+
+		if pulled {
+			// do not calculate storage if the image already exists
 			continue
 		}
 
@@ -465,7 +678,7 @@ func (p *PinnedImageSetManager) checkImagePayloadStorage(ctx context.Context, im
 		if value, found := p.cache.Get(imageName); found {
 			imageInfo, ok := value.(imageInfo)
 			if ok {
-				requiredStorage += imageInfo.Size * 2
+				requiredStorage += imageInfo.Size * factor
 				continue
 			}
 			// cache is corrupted, delete the key
@@ -480,8 +693,7 @@ func (p *PinnedImageSetManager) checkImagePayloadStorage(ctx context.Context, im
 		// cache miss
 		p.cache.Add(imageName, imageInfo{Name: imageName, Size: size})
 
-		// account for decompression
-		requiredStorage += size * 2
+		requiredStorage += size * factor
 	}
 
 	minimumStorage := p.minStorageAvailableBytes.Value()
@@ -525,7 +737,7 @@ func ensureCrioPinnedImagesConfigFile(path string, imageNames []string) error {
 	// if the existing config is the same as the new config, do nothing
 	if !bytes.Equal(bytes.TrimSpace(existingCfgBytes), bytes.TrimSpace(newCfgBytes)) {
 		ignFile := ctrlcommon.NewIgnFileBytes(path, newCfgBytes)
-		if err := writeFiles([]ign3types.File{ignFile}, true); err != nil {
+		if err := writeFiles([]ign3types.File{ignFile}, true, false); err != nil {
 			return fmt.Errorf("failed to write CRIO config file: %w", err)
 		}
 		return crioReload()
@@ -535,7 +747,7 @@ func ensureCrioPinnedImagesConfigFile(path string, imageNames []string) error {
 	return nil
 }
 
-func (p *PinnedImageSetManager) updateStatusProgressing(pools []*mcfgv1.MachineConfigPool) error {
+func (p *PinnedImageSetManager) updateStatusProgressing(pools []*mcfgv1.MachineConfigPool, message string) error {
 	node, err := p.nodeLister.Get(p.nodeName)
 	if err != nil {
 		return fmt.Errorf("failed to get node %q: %w", p.nodeName, err)
@@ -557,7 +769,7 @@ func (p *PinnedImageSetManager) updateStatusProgressing(pools []*mcfgv1.MachineC
 		&upgrademonitor.Condition{
 			State:   mcfgv1.MachineConfigNodePinnedImageSetsProgressing,
 			Reason:  "ImagePrefetch",
-			Message: fmt.Sprintf("node is prefetching images: %s", node.Name),
+			Message: message,
 		},
 		nil,
 		metav1.ConditionTrue,
@@ -625,6 +837,42 @@ func (p *PinnedImageSetManager) updateStatusProgressingComplete(pools []*mcfgv1.
 	)
 }
 
+// updateStatusPrefetchProgress reports the progress of a throttled prefetch in
+// the PinnedImagesPulling condition.
+func (p *PinnedImageSetManager) updateStatusPrefetchProgress(pools []*mcfgv1.MachineConfigPool, progress prefetchProgress) error {
+	node, err := p.nodeLister.Get(p.nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to get node %q: %w", p.nodeName, err)
+	}
+
+	// Get MCP associated with node
+	pool, err := helpers.GetPrimaryPoolNameForMCN(p.mcpLister, node)
+	if err != nil {
+		return err
+	}
+
+	status := metav1.ConditionFalse
+	if progress.Pulling > 0 {
+		status = metav1.ConditionTrue
+	}
+
+	return upgrademonitor.UpdateMachineConfigNodeStatus(
+		&upgrademonitor.Condition{
+			State:   upgrademonitor.MachineConfigNodePinnedImagesPulling,
+			Reason:  "ImagePrefetch",
+			Message: progress.String(),
+		},
+		nil,
+		status,
+		metav1.ConditionUnknown,
+		node,
+		p.mcfgClient,
+		nil,
+		p.fgHandler,
+		pool,
+	)
+}
+
 func (p *PinnedImageSetManager) updateStatusError(pools []*mcfgv1.MachineConfigPool, statusErr error) error {
 	node, err := p.nodeLister.Get(p.nodeName)
 	if err != nil {
@@ -697,7 +945,7 @@ func (p *PinnedImageSetManager) createApplyConfigForImageSet(imageSet *mcfgv1.Pi
 
 	if statusErr != nil {
 		imageSetConfig.LastFailedGeneration = ptr.To(int32(imageSet.GetGeneration()))
-		imageSetConfig.LastFailedGenerationError = ptr.To(statusErr.Error())
+		imageSetConfig.LastFailedGenerationError = ptr.To(p.getPinnedImageSetError(imageSet, statusErr))
 	} else if isCompleted {
 		// only set the current generation if prefetch is complete
 		imageSetConfig.CurrentGeneration = ptr.To(int32(imageSet.GetGeneration()))
@@ -765,12 +1013,26 @@ func (p *PinnedImageSetManager) prefetchWorker(ctx context.Context) {
 			task.monitor.Done()
 			continue
 		}
-		if err := ensurePullImage(ctx, p.criClient, p.backoff, task.image, task.auth); err != nil {
-			task.monitor.Error(err)
+		source, retries, err := p.pullImage(ctx, task)
+		if err != nil {
+			p.imageStatus.setFailed(task.image, err, retries)
+			// refused images are left out when the images are pinned, so
+			// they do not stop the prefetch of the other images
+			if !errors.Is(err, errImageRejectedByPolicy) {
+				task.monitor.Error(err)
+			}
 			klog.Warningf("failed to prefetch image %q: %v", task.image, err)
 		}
 		task.monitor.Done()
 
+		if err == nil {
+			bytes := int64(0)
+			if source != pinnedImageSourceLocal {
+				bytes = p.getPulledImageSize(ctx, task.image)
+			}
+			p.imageStatus.setPulled(task.image, source, bytes, retries)
+		}
+
 		cachedImage, ok := p.cache.Get(strings.TrimSpace(task.image))
 		if ok {
 			imageInfo, ok := cachedImage.(imageInfo)
@@ -779,9 +1041,10 @@ func (p *PinnedImageSetManager) prefetchWorker(ctx context.Context) {
 				p.cache.Remove(strings.TrimSpace(task.image))
 			}
 			imageInfo.Pulled = true
+			imageInfo.Source = source
 			p.cache.Add(strings.TrimSpace(task.image), imageInfo)
 		} else {
-			p.cache.Add(strings.TrimSpace(task.image), imageInfo{Name: task.image, Pulled: true})
+			p.cache.Add(strings.TrimSpace(task.image), imageInfo{Name: task.image, Pulled: true, Source: source})
 		}
 
 		// throttle prefetching to avoid overloading the file system
@@ -800,6 +1063,7 @@ func (p *PinnedImageSetManager) Run(workers int, stopCh <-chan struct{}) {
 		utilruntime.HandleCrash()
 		p.queue.ShutDown()
 		close(p.prefetchCh)
+		p.peers.stopServing()
 	}()
 
 	if !cache.WaitForCacheSync(
@@ -807,6 +1071,7 @@ func (p *PinnedImageSetManager) Run(workers int, stopCh <-chan struct{}) {
 		p.imageSetSynced,
 		p.nodeListerSynced,
 		p.mcpSynced,
+		p.mcnSynced,
 	) {
 		klog.Errorf("failed to sync initial listers cache")
 		return
@@ -1077,12 +1342,52 @@ func (p *PinnedImageSetManager) deleteMachineConfigPool(obj interface{}) {
 		return
 	}
 
-	if err := deleteCrioConfigFile(); err != nil {
-		klog.Errorf("failed to delete crio config file: %v", err)
-		return
+	ctx, cancel := context.WithTimeout(context.Background(), pinnedImageUnpinTimeout)
+	defer cancel()
+	if err := p.unpinDeletedPool(ctx, pool); err != nil {
+		klog.Errorf("failed to unpin images of deleted MachineConfigPool %q: %v", pool.Name, err)
+	}
+}
+
+// unpinDeletedPool stops pinning the images which were only pinned through a
+// deleted pool the node was in, and garbage collects them according to the
+// policies of the deleted pool and the remaining pools of the node. Images
+// still waiting for their grace period afterwards are collected by the sync
+// of the remaining pools.
+func (p *PinnedImageSetManager) unpinDeletedPool(ctx context.Context, deleted *mcfgv1.MachineConfigPool) error {
+	node, err := p.nodeLister.Get(p.nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to get node %q: %w", p.nodeName, err)
+	}
+
+	// the deleted pool is no longer listed
+	pools, _, err := helpers.GetPoolsForNode(p.mcpLister, node)
+	if err != nil {
+		return err
+	}
+
+	imageNames, err := p.getPinnedImageNames(pools)
+	if err != nil {
+		return err
+	}
+
+	previouslyPinned, err := getCrioPinnedImages(crioPinnedImagesDropInFilePath)
+	if err != nil {
+		return err
+	}
+
+	// images of the remaining pools which are not pinned yet are pinned by
+	// their next sync
+	pinned := sets.New(previouslyPinned...).Intersection(sets.New(imageNames...)).UnsortedList()
+	sort.Strings(pinned)
+
+	if err := ensureCrioPinnedImagesConfigFile(crioPinnedImagesDropInFilePath, pinned); err != nil {
+		return err
 	}
 
-	crioReload()
+	p.garbageCollectUnpinnedImages(ctx, append(pools, deleted), previouslyPinned, pinned)
+
+	return nil
 }
 
 func (p *PinnedImageSetManager) enqueue(pool *mcfgv1.MachineConfigPool) {
@@ -1167,15 +1472,16 @@ func (p *PinnedImageSetManager) getImageSize(ctx context.Context, imageName, aut
 }
 
 // ensurePullImage first checks if the image exists locally and then will attempt to pull
-// the image from the container runtime with a retry/backoff.
-func ensurePullImage(ctx context.Context, client *cri.Client, backoff wait.Backoff, image string, authConfig *runtimeapi.AuthConfig) error {
+// the image from the container runtime with a retry/backoff. Returns the number of
+// pull attempts.
+func ensurePullImage(ctx context.Context, client *cri.Client, backoff wait.Backoff, image string, authConfig *runtimeapi.AuthConfig) (int, error) {
 	exists, err := client.ImageStatus(ctx, image)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if exists {
 		klog.V(4).Infof("image %q already exists", image)
-		return nil
+		return 0, nil
 	}
 
 	var lastErr error
@@ -1195,12 +1501,68 @@ func ensurePullImage(ctx context.Context, client *cri.Client, backoff wait.Backo
 	})
 	// this is only an error if ctx has error or backoff limits are exceeded
 	if err != nil {
-		return fmt.Errorf("%w %q (%d tries): %w: %w", errFailedToPullImage, image, tries, err, lastErr)
+		return tries, fmt.Errorf("%w %q (%d tries): %w: %w", errFailedToPullImage, image, tries, err, lastErr)
 	}
 
 	// successful pull
 	klog.V(4).Infof("image %q pulled", image)
-	return nil
+	return tries, nil
+}
+
+// pullImage ensures the image is present in the container runtime and reports
+// where it was obtained from and how many times the pull was retried. When peer
+// distribution is enabled, a failure to fetch through the peer cache falls back
+// to pulling through the container runtime so that peer distribution never
+// makes prefetching less reliable.
+func (p *PinnedImageSetManager) pullImage(ctx context.Context, task prefetch) (_ pinnedImageSource, _ int, err error) {
+	exists, err := p.criClient.ImageStatus(ctx, task.image)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// refuse images the signature policy does not allow before pulling them
+	verified := true
+	if err := p.verifier.verify(ctx, task.image, exists); err != nil {
+		if errors.Is(err, errImageRejectedByPolicy) {
+			task.opts.throttle.skip()
+			return "", 0, err
+		}
+		// the container runtime enforces the signature policy when pulling
+		// and the image is verified again before it is pinned
+		klog.Warningf("failed to verify image %q before pulling it: %v", task.image, err)
+		verified = false
+	}
+	if exists {
+		klog.V(4).Infof("image %q already exists", task.image)
+		task.opts.throttle.skip()
+		return pinnedImageSourceLocal, 0, nil
+	}
+
+	release, err := task.opts.throttle.acquire(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { release(err) }()
+
+	p.imageStatus.setPulling(task.image)
+
+	// images which could not be verified are left to the container runtime,
+	// which enforces the signature policy when pulling
+	if task.opts.peerCfg != nil && verified {
+		source, err := p.peers.fetch(ctx, task.image, task.opts.peerCfg, p.authFilePath)
+		if err == nil {
+			return source, 0, nil
+		}
+		klog.Warningf("failed to fetch image %q through peer cache, falling back to container runtime: %v", task.image, err)
+	}
+
+	tries, err := ensurePullImage(ctx, p.criClient, p.backoff, task.image, task.auth)
+	retries := max(tries-1, 0)
+	if err != nil {
+		return "", retries, err
+	}
+
+	return pinnedImageSourceUpstream, retries, nil
 }
 
 func isErrNoSpace(err error) bool {
@@ -1264,17 +1626,18 @@ func hasConfigFile(path string) (bool, error) {
 	return true, nil
 }
 
+// crioPinnedImagesConfig is the CRI-O config file with the pinned images.
+type crioPinnedImagesConfig struct {
+	Crio struct {
+		Image struct {
+			PinnedImages []string `toml:"pinned_images,omitempty"`
+		} `toml:"image"`
+	} `toml:"crio"`
+}
+
 // createCrioConfigFileBytes creates a crio config file with the pinned images.
 func createCrioConfigFileBytes(images []string) ([]byte, error) {
-	type tomlConfig struct {
-		Crio struct {
-			Image struct {
-				PinnedImages []string `toml:"pinned_images,omitempty"`
-			} `toml:"image"`
-		} `toml:"crio"`
-	}
-
-	tomlConf := tomlConfig{}
+	tomlConf := crioPinnedImagesConfig{}
 	tomlConf.Crio.Image.PinnedImages = images
 
 	var buf bytes.Buffer
@@ -1369,6 +1732,8 @@ type imageInfo struct {
 	Name   string
 	Size   int64
 	Pulled bool
+	// where the image was obtained from, empty if unknown
+	Source pinnedImageSource
 }
 
 func triggerPinnedImageSetChange(old, newPinnedImageSet *mcfgv1.PinnedImageSet) bool {
@@ -1545,6 +1910,15 @@ type prefetch struct {
 	image   string
 	auth    *runtimeapi.AuthConfig
 	monitor *prefetchMonitor
+	opts    prefetchOptions
+}
+
+// prefetchOptions configures how the images of a prefetch are pulled.
+type prefetchOptions struct {
+	// peers to fetch the images from, nil if peer distribution is disabled
+	peerCfg *peerPrefetchConfig
+	// limits the pulls, nil if there is no prefetch policy
+	throttle *prefetchThrottle
 }
 
 // prefetchMonitor is used to monitor the status of prefetch operations.
@@ -1573,7 +1947,8 @@ func (m *prefetchMonitor) Add(i int) {
 // Error is called when an error occurs during prefetching.
 func (m *prefetchMonitor) Error(err error) {
 	m.mu.Lock()
-	if isErrNoSpace(err) {
+	// nothing else can be pulled until the window opens again
+	if isErrNoSpace(err) || errors.Is(err, errOutsidePrefetchWindow) {
 		m.drain = true
 	}
 	m.err = err
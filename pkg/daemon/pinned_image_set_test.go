@@ -384,7 +384,7 @@ func TestPrefetchImageSets(t *testing.T) {
 				p.prefetchWorker(ctx)
 			}()
 
-			err = p.prefetchImageSets(ctx, imageSets...)
+			err = p.prefetchImageSets(ctx, prefetchOptions{}, imageSets...)
 			if tt.wantErr != nil {
 				require.ErrorIs(err, tt.wantErr)
 				return
@@ -547,7 +547,7 @@ func TestCheckNodeAllocatableStorage(t *testing.T) {
 			// populate the cache with the pinned image set image size
 			p.cache.Add("image1", imageInfo{Name: "image1", Size: size})
 
-			err = p.checkNodeAllocatableStorage(ctx, tt.pinnedImageSet)
+			err = p.checkNodeAllocatableStorage(ctx, tt.pinnedImageSet, false)
 			if tt.wantErr != nil {
 				require.ErrorIs(err, tt.wantErr)
 				return
@@ -667,6 +667,9 @@ func fakeMachineConfigPool(role string, pinnedImageSets []mcfgv1.PinnedImageSetR
 
 var _ runtimeapi.ImageServiceServer = (*FakeRuntime)(nil)
 
+// size of the images stored in the fake runtime.
+const fakeImageSize = 1024
+
 // FakeRuntime represents a fake remote container runtime.
 type FakeRuntime struct {
 	runtimeapi.UnimplementedImageServiceServer
@@ -680,6 +683,8 @@ type FakeRuntime struct {
 	availableImages []runtimeapi.Image
 	// number of images pulled.
 	pulledImages int
+	// images removed.
+	removedImages []string
 }
 
 // newFakeRuntime creates a new FakeRuntime.
@@ -690,6 +695,9 @@ func newFakeRuntime(localImages, availableImages []string) *FakeRuntime {
 	}
 	for _, image := range localImages {
 		f.localImages = append(f.localImages, runtimeapi.Image{
+			Id:       image,
+			RepoTags: []string{image},
+			Size:     fakeImageSize,
 			Spec: &runtimeapi.ImageSpec{
 				Image: image,
 			},
@@ -730,8 +738,16 @@ func (r *FakeRuntime) ImageStatus(_ context.Context, req *runtimeapi.ImageStatus
 }
 
 // ListImages implements v1.ImageServiceServer.
-func (*FakeRuntime) ListImages(context.Context, *runtimeapi.ListImagesRequest) (*runtimeapi.ListImagesResponse, error) {
-	panic("unimplemented")
+func (r *FakeRuntime) ListImages(_ context.Context, req *runtimeapi.ListImagesRequest) (*runtimeapi.ListImagesResponse, error) {
+	resp := &runtimeapi.ListImagesResponse{}
+	for i := range r.localImages {
+		img := &r.localImages[i]
+		if req.Filter != nil && req.Filter.Image != nil && req.Filter.Image.Image != "" && req.Filter.Image.Image != img.Spec.Image {
+			continue
+		}
+		resp.Images = append(resp.Images, img)
+	}
+	return resp, nil
 }
 
 // PullImage implements v1.ImageServiceServer.
@@ -760,8 +776,15 @@ func (r *FakeRuntime) PullImage(ctx context.Context, req *runtimeapi.PullImageRe
 }
 
 // RemoveImage implements v1.ImageServiceServer.
-func (*FakeRuntime) RemoveImage(context.Context, *runtimeapi.RemoveImageRequest) (*runtimeapi.RemoveImageResponse, error) {
-	panic("unimplemented")
+func (r *FakeRuntime) RemoveImage(_ context.Context, req *runtimeapi.RemoveImageRequest) (*runtimeapi.RemoveImageResponse, error) {
+	for i, img := range r.localImages {
+		if img.Spec.Image == req.Image.Image {
+			r.localImages = append(r.localImages[:i], r.localImages[i+1:]...)
+			r.removedImages = append(r.removedImages, req.Image.Image)
+			break
+		}
+	}
+	return &runtimeapi.RemoveImageResponse{}, nil
 }
 
 // Start starts the fake remote runtime.
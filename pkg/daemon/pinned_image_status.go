@@ -0,0 +1,323 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+)
+
+const (
+	// maximum number of failed images detailed in the error of an image set.
+	// An image set may contain hundreds of images and the error is limited
+	// in size.
+	maxPinnedImageSetFailures = 20
+
+	// maximum length of the error reported for a single image
+	maxPinnedImageErrorLength = 256
+)
+
+// pinnedImageState is the prefetch state of a single pinned image.
+type pinnedImageState string
+
+const (
+	pinnedImageStatePending pinnedImageState = "Pending"
+	pinnedImageStatePulling pinnedImageState = "Pulling"
+	pinnedImageStatePulled  pinnedImageState = "Pulled"
+	pinnedImageStateFailed  pinnedImageState = "Failed"
+)
+
+// pinnedImageErrorClass is a coarse classification of why a pinned image
+// failed to be pulled.
+type pinnedImageErrorClass string
+
+const (
+	pinnedImageErrorClassAuth            pinnedImageErrorClass = "Auth"
+	pinnedImageErrorClassManifestUnknown pinnedImageErrorClass = "ManifestUnknown"
+	pinnedImageErrorClassNoSpace         pinnedImageErrorClass = "NoSpace"
+	pinnedImageErrorClassTimeout         pinnedImageErrorClass = "Timeout"
+	pinnedImageErrorClassThrottled       pinnedImageErrorClass = "Throttled"
+	pinnedImageErrorClassPolicyRejected  pinnedImageErrorClass = "PolicyRejected"
+	pinnedImageErrorClassUnknown         pinnedImageErrorClass = "Unknown"
+)
+
+// classifyPullError determines the class of an image pull error. The
+// container runtime returns most errors as plain messages so the
+// classification is based on the messages the registry clients use.
+func classifyPullError(err error) pinnedImageErrorClass {
+	if err == nil {
+		return ""
+	}
+
+	msg := strings.ToLower(err.Error())
+	contains := func(substrs ...string) bool {
+		for _, substr := range substrs {
+			if strings.Contains(msg, substr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case isErrNoSpace(err):
+		return pinnedImageErrorClassNoSpace
+	case errors.Is(err, errOutsidePrefetchWindow):
+		return pinnedImageErrorClassThrottled
+	case errors.Is(err, errImageRejectedByPolicy):
+		return pinnedImageErrorClassPolicyRejected
+	case status.Code(err) == codes.Unauthenticated, status.Code(err) == codes.PermissionDenied,
+		contains("unauthorized", "authentication required", "access denied", "requested access to the resource is denied", "invalid username/password"):
+		return pinnedImageErrorClassAuth
+	case contains("manifest unknown", "name unknown", "not found"):
+		return pinnedImageErrorClassManifestUnknown
+	case errors.Is(err, context.DeadlineExceeded), contains("deadline exceeded", "i/o timeout", "tls handshake timeout"):
+		return pinnedImageErrorClassTimeout
+	default:
+		return pinnedImageErrorClassUnknown
+	}
+}
+
+// pinnedImageStatusEntry is the prefetch status of a single pinned image,
+// reported in the MachineConfigNode status of the image sets it belongs to.
+type pinnedImageStatusEntry struct {
+	Name  string
+	State pinnedImageState
+	// bytes transferred to pull the image, zero if it was already present
+	Bytes int64
+	// number of times the pull was retried during the last attempt
+	Retries    int
+	ErrorClass pinnedImageErrorClass
+	LastError  string
+}
+
+// pinnedImageStatusTracker tracks the prefetch state of each pinned image.
+type pinnedImageStatusTracker struct {
+	mu     sync.Mutex
+	images map[string]pinnedImageStatusEntry
+}
+
+func newPinnedImageStatusTracker() *pinnedImageStatusTracker {
+	return &pinnedImageStatusTracker{images: map[string]pinnedImageStatusEntry{}}
+}
+
+func (t *pinnedImageStatusTracker) update(image string, fn func(*pinnedImageStatusEntry)) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.images[image]
+	if !ok {
+		entry = pinnedImageStatusEntry{Name: image}
+	}
+	fn(&entry)
+	t.images[image] = entry
+}
+
+func (t *pinnedImageStatusTracker) setPulling(image string) {
+	t.update(image, func(e *pinnedImageStatusEntry) {
+		e.State = pinnedImageStatePulling
+	})
+}
+
+func (t *pinnedImageStatusTracker) setPulled(image string, source pinnedImageSource, bytes int64, retries int) {
+	t.update(image, func(e *pinnedImageStatusEntry) {
+		*e = pinnedImageStatusEntry{
+			Name:    image,
+			State:   pinnedImageStatePulled,
+			Bytes:   bytes,
+			Retries: retries,
+		}
+	})
+
+	mcdPinnedImagePullRetries.Add(float64(retries))
+	if bytes > 0 {
+		mcdPinnedImagePulledBytes.WithLabelValues(string(source)).Add(float64(bytes))
+	}
+}
+
+func (t *pinnedImageStatusTracker) setFailed(image string, err error, retries int) {
+	class := classifyPullError(err)
+	t.update(image, func(e *pinnedImageStatusEntry) {
+		e.State = pinnedImageStateFailed
+		e.ErrorClass = class
+		e.LastError = truncateString(err.Error(), maxPinnedImageErrorLength)
+		e.Retries = retries
+	})
+
+	mcdPinnedImagePullRetries.Add(float64(retries))
+	// waiting for the prefetch window is not a failure
+	if class != pinnedImageErrorClassThrottled {
+		mcdPinnedImagePullFailures.WithLabelValues(string(class)).Inc()
+	}
+}
+
+// setPresent records that the image is present in the container runtime,
+// keeping the details of its pull if it was pulled.
+func (t *pinnedImageStatusTracker) setPresent(image string) {
+	t.update(image, func(e *pinnedImageStatusEntry) {
+		if e.State == pinnedImageStatePulled {
+			return
+		}
+		*e = pinnedImageStatusEntry{Name: image, State: pinnedImageStatePulled}
+	})
+}
+
+// getEntries returns the status of the images. Images which have not been
+// attempted yet are Pending.
+func (t *pinnedImageStatusTracker) getEntries(imageNames []string) []pinnedImageStatusEntry {
+	entries := make([]pinnedImageStatusEntry, 0, len(imageNames))
+	if t == nil {
+		for _, image := range imageNames {
+			entries = append(entries, pinnedImageStatusEntry{Name: image, State: pinnedImageStatePending})
+		}
+		return entries
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, image := range imageNames {
+		entry, ok := t.images[image]
+		if !ok {
+			entry = pinnedImageStatusEntry{Name: image, State: pinnedImageStatePending}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// prune forgets the images which are no longer pinned.
+func (t *pinnedImageStatusTracker) prune(imageNames []string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	keep := sets.New(imageNames...)
+	for image := range t.images {
+		if !keep.Has(image) {
+			delete(t.images, image)
+		}
+	}
+}
+
+var pinnedImageStates = []pinnedImageState{
+	pinnedImageStatePending,
+	pinnedImageStatePulling,
+	pinnedImageStatePulled,
+	pinnedImageStateFailed,
+}
+
+// updatePinnedImageMetrics sets the number of pinned images in each state.
+func updatePinnedImageMetrics(entries []pinnedImageStatusEntry) {
+	counts := map[pinnedImageState]int{}
+	for _, entry := range entries {
+		counts[entry.State]++
+	}
+
+	for _, state := range pinnedImageStates {
+		mcdPinnedImages.WithLabelValues(string(state)).Set(float64(counts[state]))
+	}
+}
+
+func truncateString(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen-3] + "..."
+}
+
+// formatPinnedImageFailures describes the failed images among the entries,
+// detailing at most maxFailures of them. Returns an empty string if no image
+// failed.
+func formatPinnedImageFailures(entries []pinnedImageStatusEntry, maxFailures int) string {
+	var failures []string
+	failed := 0
+	for _, entry := range entries {
+		if entry.State != pinnedImageStateFailed {
+			continue
+		}
+		failed++
+		if len(failures) == maxFailures {
+			continue
+		}
+		detail := string(entry.ErrorClass)
+		if entry.Retries > 0 {
+			detail = fmt.Sprintf("%s, %d retries", detail, entry.Retries)
+		}
+		failures = append(failures, fmt.Sprintf("%s (%s): %s", entry.Name, detail, entry.LastError))
+	}
+	if failed == 0 {
+		return ""
+	}
+	if failed > len(failures) {
+		failures = append(failures, fmt.Sprintf("and %d more", failed-len(failures)))
+	}
+	return fmt.Sprintf("%d of %d images failed: %s", failed, len(entries), strings.Join(failures, "; "))
+}
+
+// getPinnedImageSetError returns the error reported for the image set,
+// detailing which of its images failed and why.
+func (p *PinnedImageSetManager) getPinnedImageSetError(imageSet *mcfgv1.PinnedImageSet, statusErr error) string {
+	entries := p.imageStatus.getEntries(uniqueSortedImageNames(imageSet.Spec.PinnedImages))
+	failures := formatPinnedImageFailures(entries, maxPinnedImageSetFailures)
+	if failures == "" {
+		return statusErr.Error()
+	}
+	return fmt.Sprintf("%v: %s", statusErr, failures)
+}
+
+// getPinnedImageSummary describes where the pinned images were obtained
+// from and how much data was transferred and reclaimed.
+func (p *PinnedImageSetManager) getPinnedImageSummary(imageNames []string) string {
+	counts := map[pinnedImageSource]int{}
+	for _, image := range imageNames {
+		source := pinnedImageSourceLocal
+		if cached, ok := p.cache.Get(strings.TrimSpace(image)); ok {
+			if info, ok := cached.(imageInfo); ok && info.Source != "" {
+				source = info.Source
+			}
+		}
+		counts[source]++
+	}
+
+	transferred := int64(0)
+	for _, entry := range p.imageStatus.getEntries(imageNames) {
+		transferred += entry.Bytes
+	}
+
+	summary := fmt.Sprintf("%d images pulled from peers, %d from upstream, %d already present; %d bytes transferred",
+		counts[pinnedImageSourcePeer], counts[pinnedImageSourceUpstream], counts[pinnedImageSourceLocal], transferred)
+	if reclaimed := p.gc.getReclaimedBytes(); reclaimed > 0 {
+		summary += fmt.Sprintf(", %d bytes reclaimed from unpinned images", reclaimed)
+	}
+	return summary
+}
+
+// getPulledImageSize returns the size of the image in the container runtime.
+func (p *PinnedImageSetManager) getPulledImageSize(ctx context.Context, image string) int64 {
+	images, err := p.criClient.ListImages(ctx, image)
+	if err != nil {
+		klog.V(4).Infof("Failed to get size of image %q: %v", image, err)
+		return 0
+	}
+
+	size := int64(0)
+	for _, img := range images {
+		size += int64(img.Size) //nolint:gosec
+	}
+	return size
+}
@@ -0,0 +1,135 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"testing"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyPullError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want pinnedImageErrorClass
+	}{
+		{
+			name: "no error",
+		},
+		{
+			name: "no space left",
+			err:  fmt.Errorf("%w: %w", errFailedToPullImage, syscall.ENOSPC),
+			want: pinnedImageErrorClassNoSpace,
+		},
+		{
+			name: "outside prefetch window",
+			err:  errOutsidePrefetchWindow,
+			want: pinnedImageErrorClassThrottled,
+		},
+		{
+			name: "rejected by signature policy",
+			err:  fmt.Errorf("%w: %q: signature required", errImageRejectedByPolicy, "quay.io/org/image@sha256:abc"),
+			want: pinnedImageErrorClassPolicyRejected,
+		},
+		{
+			name: "grpc unauthenticated",
+			err:  fmt.Errorf("%w: %w", errFailedToPullImage, status.Error(codes.Unauthenticated, "denied")),
+			want: pinnedImageErrorClassAuth,
+		},
+		{
+			name: "registry unauthorized",
+			err:  errors.New("reading manifest: unauthorized: authentication required"),
+			want: pinnedImageErrorClassAuth,
+		},
+		{
+			name: "manifest unknown",
+			err:  errors.New("reading manifest sha256:abc: manifest unknown"),
+			want: pinnedImageErrorClassManifestUnknown,
+		},
+		{
+			name: "deadline exceeded",
+			err:  fmt.Errorf("%w: %w", errFailedToPullImage, context.DeadlineExceeded),
+			want: pinnedImageErrorClassTimeout,
+		},
+		{
+			name: "unknown",
+			err:  errors.New("something went wrong"),
+			want: pinnedImageErrorClassUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, classifyPullError(tt.err))
+		})
+	}
+}
+
+func TestFormatPinnedImageFailures(t *testing.T) {
+	entries := []pinnedImageStatusEntry{
+		{Name: "a", State: pinnedImageStatePulled},
+		{Name: "b", State: pinnedImageStateFailed, ErrorClass: pinnedImageErrorClassAuth, LastError: "unauthorized", Retries: 2},
+		{Name: "c", State: pinnedImageStatePending},
+		{Name: "d", State: pinnedImageStateFailed, ErrorClass: pinnedImageErrorClassTimeout, LastError: "deadline exceeded"},
+		{Name: "e", State: pinnedImageStateFailed, ErrorClass: pinnedImageErrorClassManifestUnknown, LastError: "manifest unknown"},
+	}
+
+	assert.Empty(t, formatPinnedImageFailures(entries[:1], 2))
+	assert.Equal(t, "3 of 5 images failed: b (Auth, 2 retries): unauthorized; d (Timeout): deadline exceeded; and 1 more",
+		formatPinnedImageFailures(entries, 2))
+}
+
+func TestGetPinnedImageSetError(t *testing.T) {
+	p := &PinnedImageSetManager{imageStatus: newPinnedImageStatusTracker()}
+	p.imageStatus.setFailed("quay.io/org/a@sha256:aaa", errors.New("reading manifest: manifest unknown"), 0)
+	p.imageStatus.setPulled("quay.io/org/b@sha256:bbb", pinnedImageSourceUpstream, 10, 0)
+	p.imageStatus.setFailed("quay.io/org/other@sha256:ccc", errors.New("unauthorized"), 0)
+
+	imageSet := &mcfgv1.PinnedImageSet{
+		Spec: mcfgv1.PinnedImageSetSpec{
+			PinnedImages: []mcfgv1.PinnedImageRef{
+				{Name: "quay.io/org/a@sha256:aaa"},
+				{Name: "quay.io/org/b@sha256:bbb"},
+			},
+		},
+	}
+
+	// only the failed images of the image set are detailed
+	assert.Equal(t, "failed to pull image: 1 of 2 images failed: quay.io/org/a@sha256:aaa (ManifestUnknown): reading manifest: manifest unknown",
+		p.getPinnedImageSetError(imageSet, errFailedToPullImage))
+}
+
+func TestPinnedImageStatusTracker(t *testing.T) {
+	tracker := newPinnedImageStatusTracker()
+	tracker.setPulling("pulling")
+	tracker.setPulled("pulled", pinnedImageSourcePeer, 10, 1)
+	tracker.setFailed("failed", errors.New(strings.Repeat("x", 1000)), 2)
+	tracker.setPresent("present")
+	tracker.setPresent("pulled")
+	tracker.setPulled("unpinned", pinnedImageSourceUpstream, 10, 0)
+
+	images := []string{"pulling", "pulled", "failed", "present", "new"}
+	tracker.prune(images)
+	entries := tracker.getEntries(images)
+	assert.Len(t, entries, 5)
+	assert.Equal(t, pinnedImageStatusEntry{Name: "pulling", State: pinnedImageStatePulling}, entries[0])
+	// the details of a pulled image are kept when it is seen again
+	assert.Equal(t, pinnedImageStatusEntry{Name: "pulled", State: pinnedImageStatePulled, Bytes: 10, Retries: 1}, entries[1])
+	assert.Equal(t, pinnedImageStateFailed, entries[2].State)
+	assert.Equal(t, pinnedImageErrorClassUnknown, entries[2].ErrorClass)
+	assert.Len(t, entries[2].LastError, maxPinnedImageErrorLength)
+	assert.Equal(t, 2, entries[2].Retries)
+	assert.Equal(t, pinnedImageStatusEntry{Name: "present", State: pinnedImageStatePulled}, entries[3])
+	assert.Equal(t, pinnedImageStatusEntry{Name: "new", State: pinnedImageStatePending}, entries[4])
+
+	// images which are no longer pinned are forgotten
+	assert.NotContains(t, tracker.images, "unpinned")
+}
@@ -0,0 +1,465 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/upgrademonitor"
+)
+
+const (
+	// how often a node waiting for pool capacity checks if other nodes in the
+	// pool are done pulling
+	poolPullCapacityPollInterval = 10 * time.Second
+
+	// minimum interval between prefetch progress reports when the progress is
+	// not needed by other nodes to enforce the per-pool limit
+	prefetchProgressReportInterval = 10 * time.Second
+)
+
+var errOutsidePrefetchWindow = errors.New("outside of the prefetch window")
+
+// prefetchWindow is a daily window, in UTC, during which pinned images may be
+// pulled. A window which ends before it starts spans midnight.
+type prefetchWindow struct {
+	// offsets from midnight
+	start time.Duration
+	end   time.Duration
+}
+
+// parsePrefetchWindow parses a window in the form "HH:MM-HH:MM".
+func parsePrefetchWindow(s string) (*prefetchWindow, error) {
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid prefetch window %q: expected HH:MM-HH:MM", s)
+	}
+
+	start, err := parseTimeOfDay(startStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prefetch window %q: %w", s, err)
+	}
+
+	end, err := parseTimeOfDay(endStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prefetch window %q: %w", s, err)
+	}
+
+	if start == end {
+		return nil, fmt.Errorf("invalid prefetch window %q: start and end must differ", s)
+	}
+
+	return &prefetchWindow{start: start, end: end}, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q: expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (w *prefetchWindow) String() string {
+	format := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%s-%s UTC", format(w.start), format(w.end))
+}
+
+// untilOpen returns how long until the window opens, or zero if it is open. A
+// nil window is always open.
+func (w *prefetchWindow) untilOpen(now time.Time) time.Duration {
+	if w == nil {
+		return 0
+	}
+
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	offset := now.Sub(midnight)
+
+	isOpen := offset >= w.start && offset < w.end
+	if w.end < w.start {
+		isOpen = offset >= w.start || offset < w.end
+	}
+	if isOpen {
+		return 0
+	}
+
+	if offset < w.start {
+		return w.start - offset
+	}
+	return 24*time.Hour - offset + w.start
+}
+
+// prefetchPolicy limits how pinned images are pulled. It is configured with
+// annotations on the MachineConfigPools the node belongs to.
+type prefetchPolicy struct {
+	// maximum number of images this node pulls at the same time, zero if unlimited
+	maxPullsPerNode int
+	// maximum number of images pulled at the same time by all nodes in the
+	// pool, zero if unlimited
+	maxPullsPerPool int
+	// pool the per-pool limit applies to
+	poolName string
+	// window during which images may be pulled, nil if images may always be pulled
+	window *prefetchWindow
+}
+
+func hasPrefetchPolicy(pool *mcfgv1.MachineConfigPool) bool {
+	for _, key := range []string{
+		constants.PinnedImageMaxPullsPerNodeAnnotationKey,
+		constants.PinnedImageMaxPullsPerPoolAnnotationKey,
+		constants.PinnedImagePrefetchWindowAnnotationKey,
+	} {
+		if _, ok := pool.Annotations[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// getPrefetchPolicy combines the prefetch policies of the given pools. The
+// strictest limits win. Returns nil if none of the pools configure a policy.
+func getPrefetchPolicy(pools []*mcfgv1.MachineConfigPool) (*prefetchPolicy, error) {
+	var policy *prefetchPolicy
+	for _, pool := range pools {
+		if !hasPrefetchPolicy(pool) {
+			continue
+		}
+		if policy == nil {
+			policy = &prefetchPolicy{}
+		}
+
+		perNode, err := getPositiveIntAnnotation(pool, constants.PinnedImageMaxPullsPerNodeAnnotationKey)
+		if err != nil {
+			return nil, err
+		}
+		if perNode > 0 && (policy.maxPullsPerNode == 0 || perNode < policy.maxPullsPerNode) {
+			policy.maxPullsPerNode = perNode
+		}
+
+		perPool, err := getPositiveIntAnnotation(pool, constants.PinnedImageMaxPullsPerPoolAnnotationKey)
+		if err != nil {
+			return nil, err
+		}
+		if perPool > 0 && (policy.maxPullsPerPool == 0 || perPool < policy.maxPullsPerPool) {
+			policy.maxPullsPerPool = perPool
+			policy.poolName = pool.Name
+		}
+
+		windowStr, ok := pool.Annotations[constants.PinnedImagePrefetchWindowAnnotationKey]
+		if !ok {
+			continue
+		}
+		window, err := parsePrefetchWindow(windowStr)
+		if err != nil {
+			return nil, fmt.Errorf("MachineConfigPool %q: %w", pool.Name, err)
+		}
+		if policy.window != nil && *policy.window != *window {
+			return nil, fmt.Errorf("MachineConfigPool %q: prefetch window %s conflicts with %s", pool.Name, window, policy.window)
+		}
+		policy.window = window
+	}
+
+	return policy, nil
+}
+
+func getPositiveIntAnnotation(pool *mcfgv1.MachineConfigPool, key string) (int, error) {
+	value, ok := pool.Annotations[key]
+	if !ok {
+		return 0, nil
+	}
+
+	i, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || i < 1 {
+		return 0, fmt.Errorf("MachineConfigPool %q: annotation %s must be a positive integer, got %q", pool.Name, key, value)
+	}
+
+	return i, nil
+}
+
+// prefetchProgress counts the images of a single prefetch.
+type prefetchProgress struct {
+	Queued  int
+	Pulling int
+	Done    int
+}
+
+// prefetchProgressFormat is the message of the PinnedImagesPulling condition.
+// Other nodes in the pool parse it to enforce the per-pool limit.
+const prefetchProgressFormat = "%d images pulling, %d queued, %d done"
+
+func (p prefetchProgress) String() string {
+	return fmt.Sprintf(prefetchProgressFormat, p.Pulling, p.Queued, p.Done)
+}
+
+func parsePrefetchProgress(s string) (prefetchProgress, error) {
+	progress := prefetchProgress{}
+	if _, err := fmt.Sscanf(s, prefetchProgressFormat, &progress.Pulling, &progress.Queued, &progress.Done); err != nil {
+		return prefetchProgress{}, fmt.Errorf("invalid prefetch progress %q: %w", s, err)
+	}
+	return progress, nil
+}
+
+// prefetchThrottle enforces a prefetchPolicy for a single prefetch and tracks
+// its progress. A nil throttle does not limit anything.
+//
+// The per-pool limit is best effort: each node publishes how many images it is
+// pulling and waits until the images being pulled across the pool are below
+// the limit. Two nodes may start pulling at the same time and briefly exceed
+// the limit.
+type prefetchThrottle struct {
+	policy *prefetchPolicy
+
+	// per-node pull slots, nil if unlimited
+	slots chan struct{}
+	// returns how many images other nodes in the pool are pulling
+	poolPulling func() (int, error)
+	// publishes the progress
+	report func(prefetchProgress)
+
+	now          func() time.Time
+	pollInterval time.Duration
+
+	// mu protects the fields below
+	mu       sync.Mutex
+	progress prefetchProgress
+	// time of the last progress report
+	lastReport time.Time
+	// the window was closed when an image needed to be pulled
+	windowClosed bool
+	// a pull was still waiting for pool capacity when the prefetch timed out
+	poolWaitTimedOut bool
+}
+
+func newPrefetchThrottle(policy *prefetchPolicy, poolPulling func() (int, error), report func(prefetchProgress)) *prefetchThrottle {
+	t := &prefetchThrottle{
+		policy:       policy,
+		poolPulling:  poolPulling,
+		report:       report,
+		now:          time.Now,
+		pollInterval: poolPullCapacityPollInterval,
+	}
+
+	if policy.maxPullsPerNode > 0 {
+		t.slots = make(chan struct{}, policy.maxPullsPerNode)
+	}
+
+	return t
+}
+
+// enqueue records an image which was scheduled for prefetching.
+func (t *prefetchThrottle) enqueue() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.progress.Queued++
+	t.mu.Unlock()
+}
+
+// skip records a queued image which did not need to be pulled.
+func (t *prefetchThrottle) skip() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.progress.Queued--
+	t.progress.Done++
+	t.mu.Unlock()
+	t.reportProgress(false)
+}
+
+// markDone records an image which was already pulled by a previous prefetch.
+func (t *prefetchThrottle) markDone() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.progress.Done++
+	t.mu.Unlock()
+}
+
+// acquire blocks until the image may be pulled. The returned function must be
+// called with the result of the pull once it completes.
+func (t *prefetchThrottle) acquire(ctx context.Context) (func(error), error) {
+	if t == nil {
+		return func(error) {}, nil
+	}
+
+	if wait := t.policy.window.untilOpen(t.now()); wait > 0 {
+		t.mu.Lock()
+		t.windowClosed = true
+		t.mu.Unlock()
+		return nil, fmt.Errorf("%w %s, opens in %s", errOutsidePrefetchWindow, t.policy.window, wait.Round(time.Minute))
+	}
+
+	if t.slots != nil {
+		select {
+		case t.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if err := t.waitForPoolCapacity(ctx); err != nil {
+		if t.slots != nil {
+			<-t.slots
+		}
+		return nil, err
+	}
+
+	t.reportProgress(t.policy.maxPullsPerPool > 0)
+
+	return func(err error) {
+		t.mu.Lock()
+		t.progress.Pulling--
+		if err == nil {
+			t.progress.Done++
+		}
+		t.mu.Unlock()
+
+		if t.slots != nil {
+			<-t.slots
+		}
+
+		t.reportProgress(t.policy.maxPullsPerPool > 0)
+	}, nil
+}
+
+// waitForPoolCapacity waits until the images being pulled across the pool are
+// below the per-pool limit and then records the pull as started.
+func (t *prefetchThrottle) waitForPoolCapacity(ctx context.Context) error {
+	start := func() {
+		t.progress.Queued--
+		t.progress.Pulling++
+	}
+
+	if t.policy.maxPullsPerPool == 0 {
+		t.mu.Lock()
+		start()
+		t.mu.Unlock()
+		return nil
+	}
+
+	for {
+		others, err := t.poolPulling()
+		if err != nil {
+			klog.Warningf("Failed to get pinned image pulls in pool %q: %v", t.policy.poolName, err)
+		} else {
+			t.mu.Lock()
+			if others+t.progress.Pulling < t.policy.maxPullsPerPool {
+				start()
+				t.mu.Unlock()
+				return nil
+			}
+			t.mu.Unlock()
+			klog.V(4).Infof("Waiting for pinned image pulls in pool %q to drop below %d", t.policy.poolName, t.policy.maxPullsPerPool)
+		}
+
+		select {
+		case <-time.After(t.pollInterval):
+		case <-ctx.Done():
+			t.mu.Lock()
+			t.poolWaitTimedOut = true
+			t.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+}
+
+// throttled reports whether the prefetch was held back by the policy and how
+// long to wait before trying again.
+func (t *prefetchThrottle) throttled() (bool, time.Duration) {
+	if t == nil {
+		return false, 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.windowClosed {
+		return true, t.policy.window.untilOpen(t.now())
+	}
+	if t.poolWaitTimedOut {
+		return true, t.pollInterval
+	}
+	return false, 0
+}
+
+func (t *prefetchThrottle) getProgress() prefetchProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.progress
+}
+
+// reportProgress publishes the progress. Unless forced, reports are rate
+// limited.
+func (t *prefetchThrottle) reportProgress(force bool) {
+	if t == nil || t.report == nil {
+		return
+	}
+
+	t.mu.Lock()
+	now := t.now()
+	if !force && now.Sub(t.lastReport) < prefetchProgressReportInterval {
+		t.mu.Unlock()
+		return
+	}
+	t.lastReport = now
+	progress := t.progress
+	t.mu.Unlock()
+
+	t.report(progress)
+}
+
+// getPoolPulling returns the number of images being pulled by the other nodes
+// in the pool, as reported in the PinnedImagesPulling condition of their
+// MachineConfigNodes. A condition which did not change within staleAfter is
+// ignored so that a node which stopped while pulling does not hold back the
+// pool.
+func (p *PinnedImageSetManager) getPoolPulling(poolName string, staleAfter time.Duration) (int, error) {
+	mcns, err := p.mcnLister.List(labels.Everything())
+	if err != nil {
+		return 0, fmt.Errorf("failed to list MachineConfigNodes: %w", err)
+	}
+
+	pulling := 0
+	for _, mcn := range mcns {
+		if mcn.Name == p.nodeName || mcn.Spec.Pool.Name != poolName {
+			continue
+		}
+
+		condition := meta.FindStatusCondition(mcn.Status.Conditions, string(upgrademonitor.MachineConfigNodePinnedImagesPulling))
+		if condition == nil || condition.Status != metav1.ConditionTrue {
+			continue
+		}
+
+		// the condition is replaced on every progress report
+		if time.Since(condition.LastTransitionTime.Time) > staleAfter {
+			continue
+		}
+
+		progress, err := parsePrefetchProgress(condition.Message)
+		if err != nil {
+			klog.V(4).Infof("Ignoring invalid pinned image progress on MachineConfigNode %q: %v", mcn.Name, err)
+			continue
+		}
+
+		pulling += progress.Pulling
+	}
+
+	return pulling, nil
+}
@@ -0,0 +1,308 @@
+package daemon
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	mcfglistersv1 "github.com/openshift/client-go/machineconfiguration/listers/machineconfiguration/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/upgrademonitor"
+)
+
+func TestPrefetchWindow(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, time.January, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name          string
+		window        string
+		now           time.Time
+		wantUntilOpen time.Duration
+		wantErr       bool
+	}{
+		{
+			name:   "inside window",
+			window: "01:00-05:00",
+			now:    at(2, 0),
+		},
+		{
+			name:          "before window",
+			window:        "01:00-05:00",
+			now:           at(0, 30),
+			wantUntilOpen: 30 * time.Minute,
+		},
+		{
+			name:          "after window",
+			window:        "01:00-05:00",
+			now:           at(5, 0),
+			wantUntilOpen: 20 * time.Hour,
+		},
+		{
+			name:   "window spanning midnight, before midnight",
+			window: "22:00-06:00",
+			now:    at(23, 0),
+		},
+		{
+			name:   "window spanning midnight, after midnight",
+			window: "22:00-06:00",
+			now:    at(3, 0),
+		},
+		{
+			name:          "outside window spanning midnight",
+			window:        "22:00-06:00",
+			now:           at(12, 0),
+			wantUntilOpen: 10 * time.Hour,
+		},
+		{
+			name:    "missing end",
+			window:  "22:00",
+			wantErr: true,
+		},
+		{
+			name:    "invalid time",
+			window:  "25:00-06:00",
+			wantErr: true,
+		},
+		{
+			name:    "empty window",
+			window:  "06:00-06:00",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			window, err := parsePrefetchWindow(tt.window)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantUntilOpen, window.untilOpen(tt.now))
+		})
+	}
+}
+
+func TestGetPrefetchPolicy(t *testing.T) {
+	newPool := func(name string, annotations map[string]string) *mcfgv1.MachineConfigPool {
+		return &mcfgv1.MachineConfigPool{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+	}
+
+	tests := []struct {
+		name       string
+		pools      []*mcfgv1.MachineConfigPool
+		wantPolicy *prefetchPolicy
+		wantErr    bool
+	}{
+		{
+			name:  "no policy",
+			pools: []*mcfgv1.MachineConfigPool{newPool("worker", nil)},
+		},
+		{
+			name: "strictest limits win",
+			pools: []*mcfgv1.MachineConfigPool{
+				newPool("infra", map[string]string{
+					constants.PinnedImageMaxPullsPerNodeAnnotationKey: "3",
+					constants.PinnedImageMaxPullsPerPoolAnnotationKey: "2",
+				}),
+				newPool("worker", map[string]string{
+					constants.PinnedImageMaxPullsPerNodeAnnotationKey: "1",
+					constants.PinnedImageMaxPullsPerPoolAnnotationKey: "4",
+					constants.PinnedImagePrefetchWindowAnnotationKey:  "22:00-06:00",
+				}),
+			},
+			wantPolicy: &prefetchPolicy{
+				maxPullsPerNode: 1,
+				maxPullsPerPool: 2,
+				poolName:        "infra",
+				window:          &prefetchWindow{start: 22 * time.Hour, end: 6 * time.Hour},
+			},
+		},
+		{
+			name: "invalid limit",
+			pools: []*mcfgv1.MachineConfigPool{
+				newPool("worker", map[string]string{constants.PinnedImageMaxPullsPerNodeAnnotationKey: "0"}),
+			},
+			wantErr: true,
+		},
+		{
+			name: "conflicting windows",
+			pools: []*mcfgv1.MachineConfigPool{
+				newPool("infra", map[string]string{constants.PinnedImagePrefetchWindowAnnotationKey: "01:00-02:00"}),
+				newPool("worker", map[string]string{constants.PinnedImagePrefetchWindowAnnotationKey: "22:00-06:00"}),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			policy, err := getPrefetchPolicy(tt.pools)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPolicy, policy)
+		})
+	}
+}
+
+func TestPrefetchThrottle(t *testing.T) {
+	t.Run("nil throttle does not limit", func(t *testing.T) {
+		t.Parallel()
+
+		var throttle *prefetchThrottle
+		throttle.enqueue()
+		release, err := throttle.acquire(context.Background())
+		require.NoError(t, err)
+		release(nil)
+
+		throttled, _ := throttle.throttled()
+		assert.False(t, throttled)
+	})
+
+	t.Run("limits pulls per node", func(t *testing.T) {
+		t.Parallel()
+
+		throttle := newPrefetchThrottle(&prefetchPolicy{maxPullsPerNode: 1}, nil, nil)
+		throttle.enqueue()
+		throttle.enqueue()
+
+		release, err := throttle.acquire(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, prefetchProgress{Queued: 1, Pulling: 1}, throttle.getProgress())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = throttle.acquire(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		release(nil)
+		assert.Equal(t, prefetchProgress{Queued: 1, Done: 1}, throttle.getProgress())
+
+		release, err = throttle.acquire(context.Background())
+		require.NoError(t, err)
+		release(nil)
+		assert.Equal(t, prefetchProgress{Done: 2}, throttle.getProgress())
+	})
+
+	t.Run("waits for pool capacity", func(t *testing.T) {
+		t.Parallel()
+
+		othersPulling := atomic.Int32{}
+		othersPulling.Store(2)
+		reports := atomic.Int32{}
+
+		throttle := newPrefetchThrottle(
+			&prefetchPolicy{maxPullsPerPool: 2, poolName: "worker"},
+			func() (int, error) { return int(othersPulling.Load()), nil },
+			func(prefetchProgress) { reports.Add(1) },
+		)
+		throttle.pollInterval = 10 * time.Millisecond
+		throttle.enqueue()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := throttle.acquire(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		throttled, retryAfter := throttle.throttled()
+		assert.True(t, throttled)
+		assert.Equal(t, throttle.pollInterval, retryAfter)
+
+		othersPulling.Store(1)
+		release, err := throttle.acquire(context.Background())
+		require.NoError(t, err)
+		release(nil)
+
+		// every change is reported so that other nodes can see it
+		assert.Equal(t, int32(2), reports.Load())
+	})
+
+	t.Run("only pulls within the window", func(t *testing.T) {
+		t.Parallel()
+
+		window, err := parsePrefetchWindow("22:00-06:00")
+		require.NoError(t, err)
+
+		throttle := newPrefetchThrottle(&prefetchPolicy{window: window}, nil, nil)
+		throttle.now = func() time.Time { return time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC) }
+		throttle.enqueue()
+
+		_, err = throttle.acquire(context.Background())
+		assert.ErrorIs(t, err, errOutsidePrefetchWindow)
+
+		throttled, retryAfter := throttle.throttled()
+		assert.True(t, throttled)
+		assert.Equal(t, 10*time.Hour, retryAfter)
+		assert.Equal(t, prefetchProgress{Queued: 1}, throttle.getProgress())
+	})
+}
+
+func TestGetPoolPulling(t *testing.T) {
+	newMCN := func(name, pool string, progress prefetchProgress, lastTransition time.Time) *mcfgv1.MachineConfigNode {
+		status := metav1.ConditionFalse
+		if progress.Pulling > 0 {
+			status = metav1.ConditionTrue
+		}
+
+		return &mcfgv1.MachineConfigNode{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: mcfgv1.MachineConfigNodeSpec{
+				Pool: mcfgv1.MCOObjectReference{Name: pool},
+			},
+			Status: mcfgv1.MachineConfigNodeStatus{
+				Conditions: []metav1.Condition{{
+					Type:               string(upgrademonitor.MachineConfigNodePinnedImagesPulling),
+					Status:             status,
+					Reason:             "ImagePrefetch",
+					Message:            progress.String(),
+					LastTransitionTime: metav1.NewTime(lastTransition),
+				}},
+			},
+		}
+	}
+
+	now := time.Now()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, mcn := range []*mcfgv1.MachineConfigNode{
+		newMCN("self", "worker", prefetchProgress{Pulling: 5}, now),
+		newMCN("worker-1", "worker", prefetchProgress{Pulling: 1, Queued: 3}, now),
+		newMCN("worker-2", "worker", prefetchProgress{Pulling: 2, Done: 1}, now),
+		newMCN("worker-3", "worker", prefetchProgress{Done: 4}, now),
+		newMCN("stale", "worker", prefetchProgress{Pulling: 3}, now.Add(-time.Hour)),
+		newMCN("master-0", "master", prefetchProgress{Pulling: 4}, now),
+	} {
+		require.NoError(t, indexer.Add(mcn))
+	}
+
+	p := &PinnedImageSetManager{
+		nodeName:  "self",
+		mcnLister: mcfglistersv1.NewMachineConfigNodeLister(indexer),
+	}
+
+	pulling, err := p.getPoolPulling("worker", 10*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 3, pulling)
+
+	progress, err := parsePrefetchProgress(prefetchProgress{Queued: 1, Pulling: 2, Done: 3}.String())
+	require.NoError(t, err)
+	assert.Equal(t, prefetchProgress{Queued: 1, Pulling: 2, Done: 3}, progress)
+
+	_, err = parsePrefetchProgress("node is prefetching images")
+	assert.Error(t, err)
+}
@@ -0,0 +1,379 @@
+package daemon
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/image"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	"github.com/golang/groupcache/lru"
+	"github.com/opencontainers/go-digest"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/machine-config-operator/pkg/imageutils"
+)
+
+var errImageRejectedByPolicy = errors.New("image rejected by signature policy")
+
+const (
+	// maximum number of verdicts kept in the cache
+	maxPinnedImageVerdicts = 1000
+
+	// interval after which images which could not be verified are verified
+	// again
+	pinnedImageVerifyRetryInterval = time.Minute
+)
+
+// pinnedImageVerifier checks pinned images against the signature policy
+// (policy.json and registries.d) rendered by the container runtime config
+// controller before they are pinned. The verdicts are cached per image digest
+// and the policy requirements which apply to the image, so that a policy
+// change only invalidates the verdicts of the images it applies to.
+type pinnedImageVerifier struct {
+	policyPath        string
+	registriesDirPath string
+	registryCfgPath   string
+	authFilePath      string
+
+	// openImage opens the image for verification, from the container runtime
+	// storage if it is present there and otherwise from its registry. The
+	// signatures of the image are only looked up if withSignatures is set.
+	// The returned func must be called to release the image.
+	openImage func(ctx context.Context, sysCtx *types.SystemContext, image string, present, withSignatures bool) (types.UnparsedImage, func() error, error)
+
+	// mu protects verdicts
+	mu sync.Mutex
+	// verdicts for verified images by verdictKey, nil if the image was
+	// accepted
+	verdicts *lru.Cache
+}
+
+// verdictKey identifies the verdict for an image under the policy
+// requirements which apply to it.
+type verdictKey struct {
+	image        string
+	requirements string
+}
+
+func newPinnedImageVerifier(policyPath, registriesDirPath, registryCfgPath, authFilePath string) *pinnedImageVerifier {
+	return &pinnedImageVerifier{
+		policyPath:        policyPath,
+		registriesDirPath: registriesDirPath,
+		registryCfgPath:   registryCfgPath,
+		authFilePath:      authFilePath,
+		openImage:         openPinnedImage,
+		verdicts:          lru.New(maxPinnedImageVerdicts),
+	}
+}
+
+// verify returns an error wrapping errImageRejectedByPolicy if the image is
+// not referenced by digest, its manifest does not match the digest, or the
+// signature policy of the node does not allow it. Images present in the
+// container runtime storage are verified against the stored manifest. Other
+// errors mean that the image could not be verified, e.g. because its registry
+// is unreachable, and should be retried. Verification is skipped if the node
+// has no signature policy.
+func (v *pinnedImageVerifier) verify(ctx context.Context, image string, present bool) error {
+	if v == nil {
+		return nil
+	}
+
+	expectedDigest := imageutils.DigestFromPullspec(image)
+	if expectedDigest == "" {
+		return fmt.Errorf("%w: %q is not referenced by digest", errImageRejectedByPolicy, image)
+	}
+
+	policy, err := v.loadPolicy()
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		klog.V(4).Infof("No signature policy at %s, skipping verification of image %q", v.policyPath, image)
+		return nil
+	}
+
+	ref, err := imageutils.ParseImageName(image)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errImageRejectedByPolicy, err)
+	}
+	requirements := policyRequirementsForImage(policy, ref)
+	fingerprint, err := v.getRequirementsFingerprint(requirements)
+	if err != nil {
+		return err
+	}
+	key := verdictKey{image: image, requirements: fingerprint}
+
+	v.mu.Lock()
+	cached, ok := v.verdicts.Get(key)
+	v.mu.Unlock()
+	if ok {
+		verdict, _ := cached.(error)
+		return verdict
+	}
+
+	withSignatures, err := requirementsNeedSignatures(requirements)
+	if err != nil {
+		return err
+	}
+
+	verdict, err := v.verifyImage(ctx, policy, image, present, withSignatures, digest.Digest(expectedDigest))
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	v.verdicts.Add(key, verdict)
+	v.mu.Unlock()
+
+	if verdict != nil {
+		klog.Warningf("Refusing to pin image: %v", verdict)
+	} else {
+		klog.V(4).Infof("Image %q verified against signature policy", image)
+	}
+
+	return verdict
+}
+
+// loadPolicy loads the signature policy, or returns nil if there is none.
+func (v *pinnedImageVerifier) loadPolicy() (*signature.Policy, error) {
+	if _, err := os.Stat(v.policyPath); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	policy, err := signature.NewPolicyFromFile(v.policyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signature policy %s: %w", v.policyPath, err)
+	}
+
+	return policy, nil
+}
+
+// verifyImage returns the verdict for the image, or an error if the image
+// could not be verified.
+func (v *pinnedImageVerifier) verifyImage(ctx context.Context, policy *signature.Policy, image string, present, withSignatures bool, expectedDigest digest.Digest) (verdict, err error) {
+	policyCtx, err := signature.NewPolicyContext(policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signature policy context: %w", err)
+	}
+	defer func() {
+		if destroyErr := policyCtx.Destroy(); destroyErr != nil {
+			klog.Warningf("failed to destroy signature policy context: %v", destroyErr)
+		}
+	}()
+
+	sysCtx := &types.SystemContext{
+		AuthFilePath:             v.authFilePath,
+		RegistriesDirPath:        v.registriesDirPath,
+		SignaturePolicyPath:      v.policyPath,
+		SystemRegistriesConfPath: v.registryCfgPath,
+	}
+
+	unparsed, closeImage, err := v.openImage(ctx, sysCtx, image, present, withSignatures)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image %q for verification: %w", image, err)
+	}
+	defer func() {
+		if closeErr := closeImage(); closeErr != nil {
+			klog.Warningf("failed to close image %q: %v", image, closeErr)
+		}
+	}()
+
+	rawManifest, _, err := unparsed.Manifest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest of image %q: %w", image, err)
+	}
+	matches, err := manifest.MatchesDigest(rawManifest, expectedDigest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute digest of image %q: %w", image, err)
+	}
+	if !matches {
+		return fmt.Errorf("%w: manifest of %q does not match its digest", errImageRejectedByPolicy, image), nil
+	}
+
+	allowed, err := policyCtx.IsRunningImageAllowed(ctx, unparsed)
+	if allowed {
+		return nil, nil
+	}
+
+	if err == nil {
+		return fmt.Errorf("%w: %q is not allowed", errImageRejectedByPolicy, image), nil
+	}
+	var reqErr signature.PolicyRequirementError
+	if errors.As(err, &reqErr) {
+		return fmt.Errorf("%w: %q: %w", errImageRejectedByPolicy, image, err), nil
+	}
+	return nil, fmt.Errorf("failed to verify image %q: %w", image, err)
+}
+
+// getRequirementsFingerprint returns a hash of the policy requirements which
+// apply to the image and of the registries.d config, which locates the
+// signatures of the image.
+func (v *pinnedImageVerifier) getRequirementsFingerprint(requirements signature.PolicyRequirements) (string, error) {
+	data, err := json.Marshal(requirements)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode signature policy requirements: %w", err)
+	}
+
+	h := sha256.New()
+	h.Write(data)
+
+	entries, err := os.ReadDir(v.registriesDirPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read registries.d: %w", err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(v.registriesDirPath, name))
+		if err != nil {
+			return "", fmt.Errorf("failed to read registries.d: %w", err)
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(data))
+		h.Write(data)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// policyRequirementsForImage returns the requirements of the most specific
+// scope of the policy matching the image, following the same lookup as the
+// signature policy evaluation.
+func policyRequirementsForImage(policy *signature.Policy, ref types.ImageReference) signature.PolicyRequirements {
+	if scopes, ok := policy.Transports[ref.Transport().Name()]; ok {
+		if requirements, ok := scopes[ref.PolicyConfigurationIdentity()]; ok {
+			return requirements
+		}
+		for _, namespace := range ref.PolicyConfigurationNamespaces() {
+			if requirements, ok := scopes[namespace]; ok {
+				return requirements
+			}
+		}
+		if requirements, ok := scopes[""]; ok {
+			return requirements
+		}
+	}
+	return policy.Default
+}
+
+// requirementsNeedSignatures returns whether evaluating the policy
+// requirements needs the signatures of the image.
+func requirementsNeedSignatures(requirements signature.PolicyRequirements) (bool, error) {
+	data, err := json.Marshal(requirements)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode signature policy requirements: %w", err)
+	}
+
+	var decoded []struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return false, fmt.Errorf("failed to decode signature policy requirements: %w", err)
+	}
+
+	for _, requirement := range decoded {
+		if requirement.Type != "insecureAcceptAnything" && requirement.Type != "reject" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// openPinnedImage opens the image from the container runtime storage if it is
+// present there, and otherwise from its registry. The storage does not expose
+// the signatures of the image, so those are looked up from the registry when
+// they are needed.
+func openPinnedImage(ctx context.Context, sysCtx *types.SystemContext, imageName string, present, withSignatures bool) (types.UnparsedImage, func() error, error) {
+	if !present {
+		return openRegistryImage(ctx, sysCtx, imageName)
+	}
+
+	ref, err := imageutils.ParseImageName(imageName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rawManifest, err := readLocalManifest(ctx, imageName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !withSignatures {
+		return &localImage{ref: ref, manifest: rawManifest}, func() error { return nil }, nil
+	}
+
+	src, err := ref.NewImageSource(ctx, sysCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &signedLocalImage{UnparsedImage: image.UnparsedInstance(src, nil), manifest: rawManifest}, src.Close, nil
+}
+
+// readLocalManifest reads the manifest of the image from the container
+// runtime storage.
+func readLocalManifest(ctx context.Context, imageName string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, "skopeo", "inspect", "--raw", "containers-storage:"+imageName).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest of image %q from container storage: %w", imageName, err)
+	}
+	return out, nil
+}
+
+// localImage is an image present in the container runtime storage, verified
+// without its signatures.
+type localImage struct {
+	ref      types.ImageReference
+	manifest []byte
+}
+
+func (i *localImage) Reference() types.ImageReference { return i.ref }
+
+func (i *localImage) Manifest(context.Context) ([]byte, string, error) {
+	return i.manifest, manifest.GuessMIMEType(i.manifest), nil
+}
+
+func (i *localImage) Signatures(context.Context) ([][]byte, error) { return nil, nil }
+
+// signedLocalImage is an image present in the container runtime storage,
+// verified with its signatures from the registry.
+type signedLocalImage struct {
+	*image.UnparsedImage
+	manifest []byte
+}
+
+func (i *signedLocalImage) Manifest(context.Context) ([]byte, string, error) {
+	return i.manifest, manifest.GuessMIMEType(i.manifest), nil
+}
+
+// openRegistryImage opens the image in its registry, honoring the mirrors in
+// the registries config.
+func openRegistryImage(ctx context.Context, sysCtx *types.SystemContext, imageName string) (types.UnparsedImage, func() error, error) {
+	ref, err := imageutils.ParseImageName(imageName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	src, err := ref.NewImageSource(ctx, sysCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return image.UnparsedInstance(src, nil), src.Close, nil
+}
@@ -0,0 +1,206 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/machine-config-operator/pkg/imageutils"
+)
+
+const (
+	acceptAnythingPolicy = `{"default": [{"type": "insecureAcceptAnything"}]}`
+	rejectPolicy         = `{"default": [{"type": "reject"}]}`
+	signedByPolicy       = `{"default": [{"type": "insecureAcceptAnything"}], "transports": {"docker": {"quay.io/org": [{"type": "signedBy", "keyType": "GPGKeys", "keyPath": "/etc/pki/key.gpg"}]}}}`
+)
+
+// fakeUnparsedImage is an unsigned image with the given manifest.
+type fakeUnparsedImage struct {
+	ref      types.ImageReference
+	manifest []byte
+}
+
+func (f *fakeUnparsedImage) Reference() types.ImageReference { return f.ref }
+
+func (f *fakeUnparsedImage) Manifest(context.Context) ([]byte, string, error) {
+	return f.manifest, "application/vnd.oci.image.manifest.v1+json", nil
+}
+
+func (f *fakeUnparsedImage) Signatures(context.Context) ([][]byte, error) { return nil, nil }
+
+func TestPinnedImageVerifier(t *testing.T) {
+	imageManifest := []byte(`{"schemaVersion": 2}`)
+	image := "quay.io/org/image@" + digest.FromBytes(imageManifest).String()
+	otherImage := "quay.io/other/image@" + digest.FromBytes(imageManifest).String()
+
+	type openCall struct {
+		present        bool
+		withSignatures bool
+	}
+
+	newVerifier := func(t *testing.T, policy string) (*pinnedImageVerifier, *[]openCall) {
+		dir := t.TempDir()
+		policyPath := filepath.Join(dir, "policy.json")
+		if policy != "" {
+			require.NoError(t, os.WriteFile(policyPath, []byte(policy), 0o644))
+		}
+
+		opened := []openCall{}
+		v := newPinnedImageVerifier(policyPath, filepath.Join(dir, "registries.d"), "", "")
+		v.openImage = func(_ context.Context, _ *types.SystemContext, name string, present, withSignatures bool) (types.UnparsedImage, func() error, error) {
+			opened = append(opened, openCall{present: present, withSignatures: withSignatures})
+			if name == "quay.io/org/unreachable@"+digest.FromBytes(imageManifest).String() {
+				return nil, nil, errors.New("registry unreachable")
+			}
+			ref, err := imageutils.ParseImageName(name)
+			if err != nil {
+				return nil, nil, err
+			}
+			m := imageManifest
+			if name == "quay.io/org/tampered@"+digest.FromBytes(imageManifest).String() {
+				m = []byte(`{"schemaVersion": 2, "tampered": true}`)
+			}
+			return &fakeUnparsedImage{ref: ref, manifest: m}, func() error { return nil }, nil
+		}
+		return v, &opened
+	}
+
+	tests := []struct {
+		name               string
+		policy             string
+		image              string
+		present            bool
+		wantReject         bool
+		wantOpened         int
+		wantWithSignatures bool
+	}{
+		{
+			name:   "no policy",
+			image:  image,
+			policy: "",
+		},
+		{
+			name:       "accepted",
+			policy:     acceptAnythingPolicy,
+			image:      image,
+			wantOpened: 1,
+		},
+		{
+			name:       "rejected",
+			policy:     rejectPolicy,
+			image:      image,
+			wantReject: true,
+			wantOpened: 1,
+		},
+		{
+			name:               "unsigned image in signed scope",
+			policy:             signedByPolicy,
+			image:              image,
+			wantReject:         true,
+			wantOpened:         1,
+			wantWithSignatures: true,
+		},
+		{
+			name:               "unsigned local image in signed scope",
+			policy:             signedByPolicy,
+			image:              image,
+			present:            true,
+			wantReject:         true,
+			wantOpened:         1,
+			wantWithSignatures: true,
+		},
+		{
+			name:       "local image",
+			policy:     acceptAnythingPolicy,
+			image:      image,
+			present:    true,
+			wantOpened: 1,
+		},
+		{
+			name:       "unsigned image outside signed scope",
+			policy:     signedByPolicy,
+			image:      otherImage,
+			wantOpened: 1,
+		},
+		{
+			name:       "manifest does not match digest",
+			policy:     acceptAnythingPolicy,
+			image:      "quay.io/org/tampered@" + digest.FromBytes(imageManifest).String(),
+			wantReject: true,
+			wantOpened: 1,
+		},
+		{
+			name:       "not referenced by digest",
+			policy:     acceptAnythingPolicy,
+			image:      "quay.io/org/image:latest",
+			wantReject: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			v, opened := newVerifier(t, tt.policy)
+			for range 2 {
+				err := v.verify(context.Background(), tt.image, tt.present)
+				if tt.wantReject {
+					assert.ErrorIs(t, err, errImageRejectedByPolicy)
+				} else {
+					assert.NoError(t, err)
+				}
+			}
+			// the verdict is cached
+			require.Len(t, *opened, tt.wantOpened)
+			for _, call := range *opened {
+				assert.Equal(t, openCall{present: tt.present, withSignatures: tt.wantWithSignatures}, call)
+			}
+		})
+	}
+
+	t.Run("policy change only invalidates verdicts of the images it applies to", func(t *testing.T) {
+		t.Parallel()
+
+		v, opened := newVerifier(t, acceptAnythingPolicy)
+		require.NoError(t, v.verify(context.Background(), image, true))
+		require.NoError(t, v.verify(context.Background(), otherImage, true))
+
+		// the requirements of quay.io/other/image do not change
+		require.NoError(t, os.WriteFile(v.policyPath, []byte(`{"default": [{"type": "insecureAcceptAnything"}], "transports": {"docker": {"quay.io/org": [{"type": "reject"}]}}}`), 0o644))
+		assert.ErrorIs(t, v.verify(context.Background(), image, true), errImageRejectedByPolicy)
+		assert.NoError(t, v.verify(context.Background(), otherImage, true))
+		assert.Len(t, *opened, 3)
+
+		require.NoError(t, os.MkdirAll(v.registriesDirPath, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(v.registriesDirPath, "sigstore.yaml"), []byte("docker: {}"), 0o644))
+		assert.ErrorIs(t, v.verify(context.Background(), image, true), errImageRejectedByPolicy)
+		assert.Len(t, *opened, 4)
+	})
+
+	t.Run("registry errors are retried", func(t *testing.T) {
+		t.Parallel()
+
+		unreachable := "quay.io/org/unreachable@" + digest.FromBytes(imageManifest).String()
+		v, opened := newVerifier(t, acceptAnythingPolicy)
+		for range 2 {
+			err := v.verify(context.Background(), unreachable, false)
+			assert.Error(t, err)
+			assert.NotErrorIs(t, err, errImageRejectedByPolicy)
+		}
+		assert.Len(t, *opened, 2)
+	})
+
+	t.Run("nil verifier accepts everything", func(t *testing.T) {
+		t.Parallel()
+
+		var v *pinnedImageVerifier
+		assert.NoError(t, v.verify(context.Background(), "quay.io/org/image:latest", false))
+	})
+}
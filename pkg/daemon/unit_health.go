@@ -0,0 +1,264 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	systemddbus "github.com/coreos/go-systemd/v22/dbus"
+	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeErrs "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/helpers"
+	"github.com/openshift/machine-config-operator/pkg/upgrademonitor"
+)
+
+// unitHealthCheckInterval is how often the units are polled during the unit
+// health check window.
+const unitHealthCheckInterval = 5 * time.Second
+
+// unitStatusLister returns the status of the named units.
+type unitStatusLister func(ctx context.Context, names []string) ([]systemddbus.UnitStatus, error)
+
+// unitHealthWatch is the health check of the units changed by an update
+// applied without a reboot.
+type unitHealthWatch struct {
+	apihelpers.UnitHealthCheck
+	units []string
+	// actions are the node disruption actions of the update, re-run after a
+	// rollback
+	actions []opv1.NodeDisruptionPolicyStatusAction
+	// previousConfig is the config the node was updated from
+	previousConfig string
+	// drained is set if the node was drained for the update, it is then
+	// uncordoned if the update fails the check
+	drained bool
+	// rollback restores the files of the previous config and restarts the
+	// units, it is called before failing the update with
+	// UnitHealthCheckRollback
+	rollback func() error
+}
+
+// newUnitHealthWatch returns the health check of the changed units and of the
+// services reloaded or restarted by the node disruption actions, or nil if
+// the units are not checked or there is nothing to watch.
+func newUnitHealthWatch(check *apihelpers.UnitHealthCheck, changedUnits []ign3types.Unit, actions []opv1.NodeDisruptionPolicyStatusAction) *unitHealthWatch {
+	if check == nil {
+		return nil
+	}
+	units := []string{}
+	addUnit := func(name string) {
+		if !slices.Contains(units, name) {
+			units = append(units, name)
+		}
+	}
+	for _, u := range changedUnits {
+		// Masked units are not expected to run
+		if u.Mask != nil && *u.Mask {
+			continue
+		}
+		addUnit(u.Name)
+	}
+	for _, action := range actions {
+		switch action.Type {
+		case opv1.RestartStatusAction:
+			addUnit(string(action.Restart.ServiceName))
+		case opv1.ReloadStatusAction:
+			addUnit(string(action.Reload.ServiceName))
+		case opv1.SpecialStatusAction:
+			// Units are looked up by their full name
+			addUnit(constants.CRIOServiceName + ".service")
+		}
+	}
+	if len(units) == 0 {
+		return nil
+	}
+	return &unitHealthWatch{UnitHealthCheck: *check, units: units, actions: actions}
+}
+
+// isUnitFailed returns whether a unit failed, failed to load or is waiting to
+// be restarted after exiting.
+func isUnitFailed(status systemddbus.UnitStatus) bool {
+	switch {
+	case status.LoadState == "error" || status.LoadState == "bad-setting":
+		return true
+	case status.ActiveState == "failed":
+		return true
+	case status.ActiveState == "activating" && status.SubState == "auto-restart":
+		return true
+	}
+	return false
+}
+
+// watchUnitHealth polls the units until one of them fails or the window ends,
+// and returns the failed units. With an empty window the units are checked once.
+func watchUnitHealth(ctx context.Context, list unitStatusLister, units []string, window, interval time.Duration) ([]string, error) {
+	deadline := time.Now().Add(window)
+	for {
+		statuses, err := list(ctx, units)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the status of units %v: %w", units, err)
+		}
+		failedUnits := []string{}
+		for _, status := range statuses {
+			if isUnitFailed(status) {
+				failedUnits = append(failedUnits, status.Name)
+			}
+		}
+		if len(failedUnits) > 0 {
+			return failedUnits, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(min(interval, remaining)):
+		}
+	}
+}
+
+// unitHealthCheckError fails an update whose units failed the health check.
+type unitHealthCheckError struct {
+	watch       *unitHealthWatch
+	failedUnits []string
+}
+
+func (e *unitHealthCheckError) Error() string {
+	return fmt.Sprintf("systemd units %v failed after applying the update", e.failedUnits)
+}
+
+// checkUnitHealth watches the units of an update applied without a reboot. The
+// check is skipped if systemd can't be reached, as the update itself succeeded.
+func (dn *Daemon) checkUnitHealth(watch *unitHealthWatch, configName string) error {
+	if watch == nil {
+		return nil
+	}
+	ctx := context.Background()
+	conn, err := systemddbus.NewSystemdConnectionContext(ctx)
+	if err != nil {
+		klog.Warningf("Skipping unit health check, failed to connect to systemd: %v", err)
+		return nil
+	}
+	defer conn.Close()
+
+	return dn.checkUnitHealthWithLister(ctx, watch, configName, conn.ListUnitsByNamesContext)
+}
+
+// checkUnitHealthWithLister watches the units with list. The failed units are
+// reported in the MachineConfigNode and, unless the check only reports them,
+// fail the update: with UnitHealthCheckRollback, the update is rolled back
+// first. A drained node is uncordoned when the update fails.
+func (dn *Daemon) checkUnitHealthWithLister(ctx context.Context, watch *unitHealthWatch, configName string, list unitStatusLister) (retErr error) {
+	window := time.Duration(watch.WindowSeconds) * time.Second
+	klog.Infof("Checking the health of units %v for %v", watch.units, window)
+	failedUnits, err := watchUnitHealth(ctx, list, watch.units, window, unitHealthCheckInterval)
+	if err != nil {
+		klog.Warningf("Skipping unit health check: %v", err)
+		return nil
+	}
+	if len(failedUnits) == 0 {
+		logSystem("Units %v are healthy after applying config %s", watch.units, configName)
+		return nil
+	}
+
+	defer func() {
+		if retErr != nil {
+			dn.uncordonAfterFailedUpdate(watch)
+		}
+	}()
+
+	message := fmt.Sprintf("Systemd units %v failed after applying config %s", failedUnits, configName)
+	klog.Warning(message)
+	dn.maybeEventf(corev1.EventTypeWarning, "UnitHealthCheckFailed", "%s", message)
+
+	status := metav1.ConditionTrue
+	if watch.OnFailure != apihelpers.UnitHealthCheckReport {
+		status = metav1.ConditionFalse
+	}
+	pool, err := helpers.GetPrimaryPoolNameForMCN(dn.mcpLister, dn.node)
+	if err != nil {
+		klog.Errorf("Error getting the pool of the node for the failed unit health check: %v", err)
+	} else {
+		err = upgrademonitor.GenerateAndApplyMachineConfigNodes(
+			&upgrademonitor.Condition{State: mcfgv1.MachineConfigNodeUpdatePostActionComplete, Reason: "UnitHealthCheckFailed", Message: message},
+			nil,
+			status,
+			metav1.ConditionFalse,
+			dn.node,
+			dn.mcfgClient,
+			dn.fgHandler,
+			pool,
+		)
+		if err != nil {
+			klog.Errorf("Error making MCN for failed unit health check: %v", err)
+		}
+	}
+
+	healthErr := &unitHealthCheckError{watch: watch, failedUnits: failedUnits}
+	switch watch.OnFailure {
+	case apihelpers.UnitHealthCheckReport:
+		return nil
+	case apihelpers.UnitHealthCheckRollback:
+		if watch.rollback != nil {
+			if err := watch.rollback(); err != nil {
+				errs := kubeErrs.NewAggregate([]error{err, healthErr})
+				return fmt.Errorf("error rolling back the update: %w", errs)
+			}
+		}
+	}
+	return healthErr
+}
+
+// uncordonAfterFailedUpdate requests the uncordon of a node drained for an
+// update which failed its unit health check, as the node is left in the
+// previous config. Errors are logged, as the update already failed.
+func (dn *Daemon) uncordonAfterFailedUpdate(watch *unitHealthWatch) {
+	if !watch.drained || dn.nodeWriter == nil {
+		return
+	}
+	if err := dn.nodeWriter.SetDesiredDrainer(fmt.Sprintf("%s-%s", constants.DrainerStateUncordon, watch.previousConfig)); err != nil {
+		klog.Errorf("Failed to uncordon the node after the failed update: %v", err)
+		return
+	}
+	logSystem("Requested uncordon of the node after the failed update")
+}
+
+// restartUnitsAfterRollback reloads systemd and the services of an update
+// whose files were rolled back after failing its unit health check, so that
+// they run the previous configuration. Errors are logged, as the update
+// already failed.
+func restartUnitsAfterRollback(watch *unitHealthWatch) {
+	if err := reloadDaemon(); err != nil {
+		klog.Errorf("Failed to reload systemd after rolling back the update: %v", err)
+	}
+	for _, action := range watch.actions {
+		var err error
+		switch action.Type {
+		case opv1.ReloadStatusAction:
+			err = reloadService(string(action.Reload.ServiceName))
+		case opv1.SpecialStatusAction:
+			err = reloadService(constants.CRIOServiceName)
+		}
+		if err != nil {
+			klog.Errorf("Failed to reload services after rolling back the update: %v", err)
+		}
+	}
+	// Restarted services are restarted along with the changed units
+	args := append([]string{"try-restart"}, watch.units...)
+	if err := runCmdSync("systemctl", args...); err != nil {
+		klog.Errorf("Failed to restart units %v after rolling back the update: %v", watch.units, err)
+	}
+	logSystem("Restarted units %v with the previous configuration", watch.units)
+}
@@ -0,0 +1,241 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	systemddbus "github.com/coreos/go-systemd/v22/dbus"
+	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestNewUnitHealthWatch(t *testing.T) {
+	check := &apihelpers.UnitHealthCheck{WindowSeconds: 30, OnFailure: apihelpers.UnitHealthCheckDegrade}
+	units := []ign3types.Unit{
+		{Name: "foo.service", Contents: helpers.StrToPtr("[Service]\nExecStart=/bin/true\n")},
+		{Name: "bar.service", Mask: helpers.BoolToPtr(true)},
+		{Name: "kubelet.service", Dropins: []ign3types.Dropin{{Name: "10-foo.conf"}}},
+	}
+	actions := []opv1.NodeDisruptionPolicyStatusAction{
+		{Type: opv1.RestartStatusAction, Restart: &opv1.RestartService{ServiceName: "foo.service"}},
+		{Type: opv1.ReloadStatusAction, Reload: &opv1.ReloadService{ServiceName: "baz.service"}},
+		{Type: opv1.SpecialStatusAction},
+		{Type: opv1.DaemonReloadStatusAction},
+	}
+
+	watch := newUnitHealthWatch(check, units, actions)
+	require.NotNil(t, watch)
+	assert.Equal(t, []string{"foo.service", "kubelet.service", "baz.service", "crio.service"}, watch.units)
+	assert.Equal(t, *check, watch.UnitHealthCheck)
+
+	assert.Nil(t, newUnitHealthWatch(check, nil, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.NoneStatusAction}}))
+	assert.Nil(t, newUnitHealthWatch(nil, units, actions), "units are only checked if the policy opts in")
+}
+
+func TestWatchUnitHealth(t *testing.T) {
+	active := systemddbus.UnitStatus{Name: "foo.service", LoadState: "loaded", ActiveState: "active", SubState: "running"}
+	inactive := systemddbus.UnitStatus{Name: "bar.service", LoadState: "loaded", ActiveState: "inactive", SubState: "dead"}
+	missing := systemddbus.UnitStatus{Name: "bar.service", LoadState: "not-found", ActiveState: "inactive", SubState: "dead"}
+	failed := systemddbus.UnitStatus{Name: "foo.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed"}
+	restarting := systemddbus.UnitStatus{Name: "foo.service", LoadState: "loaded", ActiveState: "activating", SubState: "auto-restart"}
+	badSetting := systemddbus.UnitStatus{Name: "foo.service", LoadState: "bad-setting", ActiveState: "inactive", SubState: "dead"}
+
+	testCases := []struct {
+		name          string
+		polls         [][]systemddbus.UnitStatus
+		window        time.Duration
+		expectedPolls int
+		expected      []string
+		expectedErr   bool
+	}{
+		{
+			name:          "checked once without a window",
+			polls:         [][]systemddbus.UnitStatus{{active, inactive}},
+			expectedPolls: 1,
+		},
+		{
+			name:          "missing unit",
+			polls:         [][]systemddbus.UnitStatus{{active, missing}},
+			expectedPolls: 1,
+		},
+		{
+			name:          "failed unit",
+			polls:         [][]systemddbus.UnitStatus{{failed, inactive}},
+			expectedPolls: 1,
+			expected:      []string{"foo.service"},
+		},
+		{
+			name:          "unit with invalid settings",
+			polls:         [][]systemddbus.UnitStatus{{badSetting}},
+			expectedPolls: 1,
+			expected:      []string{"foo.service"},
+		},
+		{
+			name:          "unit failing within the window",
+			polls:         [][]systemddbus.UnitStatus{{active}, {active}, {restarting}},
+			window:        time.Minute,
+			expectedPolls: 3,
+			expected:      []string{"foo.service"},
+		},
+		{
+			name:        "status error",
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			polls := 0
+			list := func(_ context.Context, names []string) ([]systemddbus.UnitStatus, error) {
+				assert.Equal(t, []string{"foo.service", "bar.service"}, names)
+				if polls >= len(testCase.polls) {
+					return nil, fmt.Errorf("no status")
+				}
+				polls++
+				return testCase.polls[polls-1], nil
+			}
+
+			failedUnits, err := watchUnitHealth(context.Background(), list, []string{"foo.service", "bar.service"}, testCase.window, time.Millisecond)
+			if testCase.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, failedUnits)
+			assert.Equal(t, testCase.expectedPolls, polls)
+		})
+	}
+}
+
+func TestWatchUnitHealthWindow(t *testing.T) {
+	polls := 0
+	list := func(_ context.Context, names []string) ([]systemddbus.UnitStatus, error) {
+		polls++
+		return []systemddbus.UnitStatus{{Name: names[0], LoadState: "loaded", ActiveState: "active"}}, nil
+	}
+
+	start := time.Now()
+	failedUnits, err := watchUnitHealth(context.Background(), list, []string{"foo.service"}, 50*time.Millisecond, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Empty(t, failedUnits)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Greater(t, polls, 1)
+}
+
+// drainerNodeWriter records the drain requests of the daemon.
+type drainerNodeWriter struct {
+	NodeWriter
+	drainer string
+}
+
+func (w *drainerNodeWriter) SetDesiredDrainer(value string) error {
+	w.drainer = value
+	return nil
+}
+
+func (w *drainerNodeWriter) Eventf(_, _, _ string, _ ...interface{}) {}
+
+func TestCheckUnitHealthWithLister(t *testing.T) {
+	failed := systemddbus.UnitStatus{Name: "foo.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed"}
+	active := systemddbus.UnitStatus{Name: "foo.service", LoadState: "loaded", ActiveState: "active", SubState: "running"}
+
+	testCases := []struct {
+		name             string
+		status           systemddbus.UnitStatus
+		onFailure        apihelpers.UnitHealthCheckFailureAction
+		drained          bool
+		rollbackErr      error
+		expectedErr      bool
+		expectedRollback bool
+		expectedDrainer  string
+	}{
+		{
+			name:      "healthy units",
+			status:    active,
+			onFailure: apihelpers.UnitHealthCheckRollback,
+			drained:   true,
+		},
+		{
+			name:      "failed units are reported",
+			status:    failed,
+			onFailure: apihelpers.UnitHealthCheckReport,
+			drained:   true,
+		},
+		{
+			name:            "failed units degrade the node",
+			status:          failed,
+			onFailure:       apihelpers.UnitHealthCheckDegrade,
+			drained:         true,
+			expectedErr:     true,
+			expectedDrainer: "uncordon-rendered-old",
+		},
+		{
+			name:             "failed units roll back the update",
+			status:           failed,
+			onFailure:        apihelpers.UnitHealthCheckRollback,
+			drained:          true,
+			expectedErr:      true,
+			expectedRollback: true,
+			expectedDrainer:  "uncordon-rendered-old",
+		},
+		{
+			name:             "failed rollback",
+			status:           failed,
+			onFailure:        apihelpers.UnitHealthCheckRollback,
+			drained:          true,
+			rollbackErr:      fmt.Errorf("rollback failed"),
+			expectedErr:      true,
+			expectedRollback: true,
+			expectedDrainer:  "uncordon-rendered-old",
+		},
+		{
+			name:             "undrained node is not uncordoned",
+			status:           failed,
+			onFailure:        apihelpers.UnitHealthCheckRollback,
+			expectedErr:      true,
+			expectedRollback: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			writer := &drainerNodeWriter{}
+			dn := &Daemon{nodeWriter: writer}
+
+			rolledBack := false
+			watch := newUnitHealthWatch(&apihelpers.UnitHealthCheck{OnFailure: testCase.onFailure}, []ign3types.Unit{{Name: "foo.service"}}, nil)
+			require.NotNil(t, watch)
+			watch.previousConfig = "rendered-old"
+			watch.drained = testCase.drained
+			watch.rollback = func() error {
+				rolledBack = true
+				return testCase.rollbackErr
+			}
+
+			list := func(_ context.Context, _ []string) ([]systemddbus.UnitStatus, error) {
+				// The update is only rolled back once the units were checked
+				assert.False(t, rolledBack)
+				return []systemddbus.UnitStatus{testCase.status}, nil
+			}
+
+			err := dn.checkUnitHealthWithLister(context.Background(), watch, "rendered-new", list)
+			if testCase.expectedErr {
+				require.Error(t, err)
+				var healthErr *unitHealthCheckError
+				assert.Equal(t, testCase.rollbackErr == nil, errors.As(err, &healthErr))
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, testCase.expectedRollback, rolledBack)
+			assert.Equal(t, testCase.expectedDrainer, writer.drainer)
+		})
+	}
+}
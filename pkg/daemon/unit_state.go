@@ -0,0 +1,115 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+// unitState is the state of a systemd unit as reported by systemctl show.
+type unitState struct {
+	LoadState        string
+	ActiveState      string
+	SubState         string
+	UnitFileState    string
+	NeedDaemonReload bool
+}
+
+// getUnitState returns the load, active and enablement state of a unit.
+// Unlike systemctl is-enabled, it tells whether the unit is running or failed.
+func getUnitState(runner CommandRunner, name string) (*unitState, error) {
+	out, err := runner.RunGetOut("systemctl", "show", "--property=LoadState,ActiveState,SubState,UnitFileState,NeedDaemonReload", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the state of unit %q: %w", name, err)
+	}
+	state := &unitState{}
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "LoadState":
+			state.LoadState = value
+		case "ActiveState":
+			state.ActiveState = value
+		case "SubState":
+			state.SubState = value
+		case "UnitFileState":
+			state.UnitFileState = value
+		case "NeedDaemonReload":
+			state.NeedDaemonReload = value == "yes"
+		}
+	}
+	return state, nil
+}
+
+// isUnitPathMasked returns whether a unit file is a mask, i.e. a symlink to /dev/null.
+func isUnitPathMasked(path string) bool {
+	target, err := os.Readlink(path)
+	return err == nil && target == pathDevNull
+}
+
+// verifyUnitStates checks that systemd applied the units of the config. It
+// returns an error if a unit is not masked or unmasked as expected, and the
+// names of the enabled units which failed.
+func verifyUnitStates(runner CommandRunner, units []ign3types.Unit) ([]string, error) {
+	var errs []error
+	var failedUnits []string
+	for _, u := range units {
+		masked := u.Mask != nil && *u.Mask
+		unmasked := u.Mask != nil && !*u.Mask
+		enabled := u.Enabled != nil && *u.Enabled
+		if !masked && !unmasked && !enabled {
+			// Nothing is expected of the unit
+			continue
+		}
+		state, err := getUnitState(runner, u.Name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// The on-disk state was validated, systemd picks it up on reload
+		if state.NeedDaemonReload {
+			klog.Infof("Skipping state verification of unit %q pending a daemon-reload", u.Name)
+			continue
+		}
+		switch {
+		case masked && state.LoadState != "masked":
+			errs = append(errs, fmt.Errorf("unit %q expected masked, but systemd reports load state %q", u.Name, state.LoadState))
+		case !masked && state.LoadState == "masked":
+			errs = append(errs, fmt.Errorf("unit %q expected unmasked, but systemd reports it masked", u.Name))
+		case enabled && state.ActiveState == "failed":
+			failedUnits = append(failedUnits, u.Name)
+		}
+	}
+	return failedUnits, errors.Join(errs...)
+}
+
+// reportUnitStates checks that systemd applied the units of the config after
+// an update. Mismatches are only reported with events, as the on-disk state
+// was already validated and units may fail for reasons outside of the config.
+func (dn *Daemon) reportUnitStates(config *mcfgv1.MachineConfig) {
+	if dn.cmdRunner == nil {
+		return
+	}
+	ignConfig, err := ctrlcommon.ParseAndConvertConfig(config.Spec.Config.Raw)
+	if err != nil {
+		klog.Warningf("Failed to parse Ignition for config %s: %v", config.Name, err)
+		return
+	}
+	failedUnits, err := verifyUnitStates(dn.cmdRunner, ignConfig.Systemd.Units)
+	if err != nil {
+		klog.Warningf("Systemd unit states do not match config %s: %v", config.Name, err)
+		dn.maybeEventf(corev1.EventTypeWarning, "UnitStateMismatch", "Systemd unit states do not match config %s: %v", config.Name, err)
+	}
+	if len(failedUnits) > 0 {
+		klog.Warningf("Enabled systemd units failed after applying config %s: %v", config.Name, failedUnits)
+		dn.maybeEventf(corev1.EventTypeWarning, "FailedUnits", "Enabled systemd units failed after applying config %s: %v", config.Name, failedUnits)
+	}
+}
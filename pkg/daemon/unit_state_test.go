@@ -0,0 +1,116 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func unitStateOutput(load, active string) []byte {
+	return []byte(fmt.Sprintf("LoadState=%s\nActiveState=%s\nSubState=dead\nUnitFileState=enabled\nNeedDaemonReload=no\n", load, active))
+}
+
+func unitStateKey(name string) string {
+	return "systemctl show --property=LoadState,ActiveState,SubState,UnitFileState,NeedDaemonReload " + name
+}
+
+func TestGetUnitState(t *testing.T) {
+	runner := &MockCommandRunner{
+		outputs: map[string][]byte{
+			unitStateKey("foo.service"): []byte("LoadState=loaded\nActiveState=failed\nSubState=failed\nUnitFileState=enabled\nNeedDaemonReload=yes\n"),
+		},
+	}
+	state, err := getUnitState(runner, "foo.service")
+	require.NoError(t, err)
+	assert.Equal(t, &unitState{LoadState: "loaded", ActiveState: "failed", SubState: "failed", UnitFileState: "enabled", NeedDaemonReload: true}, state)
+
+	_, err = getUnitState(runner, "bar.service")
+	assert.Error(t, err)
+}
+
+func TestVerifyUnitStates(t *testing.T) {
+	testCases := []struct {
+		name          string
+		unit          ign3types.Unit
+		output        []byte
+		expectedErr   bool
+		expectedFails []string
+	}{
+		{
+			name:   "masked unit",
+			unit:   ign3types.Unit{Name: "foo.service", Mask: helpers.BoolToPtr(true)},
+			output: unitStateOutput("masked", "inactive"),
+		},
+		{
+			name:        "masked unit not masked by systemd",
+			unit:        ign3types.Unit{Name: "foo.service", Mask: helpers.BoolToPtr(true)},
+			output:      unitStateOutput("loaded", "active"),
+			expectedErr: true,
+		},
+		{
+			name:        "unmasked unit still masked by systemd",
+			unit:        ign3types.Unit{Name: "foo.service", Mask: helpers.BoolToPtr(false)},
+			output:      unitStateOutput("masked", "inactive"),
+			expectedErr: true,
+		},
+		{
+			name:   "enabled unit active",
+			unit:   ign3types.Unit{Name: "foo.service", Enabled: helpers.BoolToPtr(true)},
+			output: unitStateOutput("loaded", "active"),
+		},
+		{
+			name:          "enabled unit failed",
+			unit:          ign3types.Unit{Name: "foo.service", Enabled: helpers.BoolToPtr(true)},
+			output:        unitStateOutput("loaded", "failed"),
+			expectedFails: []string{"foo.service"},
+		},
+		{
+			name:   "pending daemon-reload",
+			unit:   ign3types.Unit{Name: "foo.service", Mask: helpers.BoolToPtr(true)},
+			output: []byte("LoadState=loaded\nActiveState=active\nNeedDaemonReload=yes\n"),
+		},
+		{
+			// No state is queried, the mock would fail otherwise
+			name: "unit without expectations",
+			unit: ign3types.Unit{Name: "foo.service", Contents: helpers.StrToPtr("[Service]\nExecStart=/bin/true\n")},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			runner := &MockCommandRunner{outputs: map[string][]byte{}}
+			if testCase.output != nil {
+				runner.outputs[unitStateKey(testCase.unit.Name)] = testCase.output
+			}
+			failedUnits, err := verifyUnitStates(runner, []ign3types.Unit{testCase.unit})
+			if testCase.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, testCase.expectedFails, failedUnits)
+		})
+	}
+}
+
+func TestCheckV3UnitMask(t *testing.T) {
+	systemdPath := t.TempDir()
+	require.NoError(t, os.Symlink(pathDevNull, filepath.Join(systemdPath, "masked.service")))
+	require.NoError(t, os.WriteFile(filepath.Join(systemdPath, "plain.service"), []byte("[Service]\n"), 0o644))
+
+	assert.True(t, isUnitPathMasked(filepath.Join(systemdPath, "masked.service")))
+	assert.False(t, isUnitPathMasked(filepath.Join(systemdPath, "plain.service")))
+	assert.False(t, isUnitPathMasked(filepath.Join(systemdPath, "missing.service")))
+
+	assert.NoError(t, checkV3Unit(ign3types.Unit{Name: "masked.service", Mask: helpers.BoolToPtr(true)}, systemdPath))
+	assert.Error(t, checkV3Unit(ign3types.Unit{Name: "plain.service", Mask: helpers.BoolToPtr(true)}, systemdPath))
+	assert.Error(t, checkV3Unit(ign3types.Unit{Name: "masked.service", Mask: helpers.BoolToPtr(false)}, systemdPath))
+	assert.NoError(t, checkV3Unit(ign3types.Unit{Name: "plain.service", Mask: helpers.BoolToPtr(false)}, systemdPath))
+}
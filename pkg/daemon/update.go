@@ -23,6 +23,8 @@ import (
 	"github.com/coreos/go-semver/semver"
 	systemddbus "github.com/coreos/go-systemd/v22/dbus"
 	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -162,7 +164,9 @@ func (dn *Daemon) executeReloadServiceNodeDisruptionAction(serviceName string, r
 // For non-reboot action, it applies configuration, updates node's config and state.
 // In the end uncordon node to schedule workload.
 // If at any point an error occurs, we reboot the node so that node has correct configuration.
-func (dn *Daemon) performPostConfigChangeNodeDisruptionAction(postConfigChangeActions []opv1.NodeDisruptionPolicyStatusAction, configName string) error {
+// A reboot follows the rebootPlan if set. Otherwise, the units in unitWatch are
+// checked before the update completes.
+func (dn *Daemon) performPostConfigChangeNodeDisruptionAction(postConfigChangeActions []opv1.NodeDisruptionPolicyStatusAction, configName string, rebootPlan *fastRebootPlan, unitWatch *unitHealthWatch) error {
 	for _, action := range postConfigChangeActions {
 
 		// Drain is already completed at this stage and essentially a no-op for this loop, so no need to log that.
@@ -194,7 +198,7 @@ func (dn *Daemon) performPostConfigChangeNodeDisruptionAction(postConfigChangeAc
 				klog.Errorf("Error making MCN for rebooting: %v", err)
 			}
 			logSystem("Rebooting node")
-			return dn.reboot(fmt.Sprintf("Node will reboot into config %s", configName))
+			return dn.rebootWithPlan(fmt.Sprintf("Node will reboot into config %s", configName), rebootPlan)
 
 		case opv1.NoneStatusAction:
 			if dn.nodeWriter != nil {
@@ -268,6 +272,9 @@ func (dn *Daemon) performPostConfigChangeNodeDisruptionAction(postConfigChangeAc
 	}
 
 	// We are here, which means a reboot was not needed to apply the configuration.
+	if err := dn.checkUnitHealth(unitWatch, configName); err != nil {
+		return err
+	}
 	return dn.finishRebootlessUpdate()
 }
 
@@ -652,8 +659,9 @@ func calculatePostConfigChangeActionFromMCDiffs(diffFileSet []string) (actions [
 }
 
 // calculatePostConfigChangeNodeDisruptionActionFromMCDiffs takes action based on the cluster's Node disruption policies.
-func calculatePostConfigChangeNodeDisruptionActionFromMCDiffs(diffSSH bool, diffFileSet, diffUnitSet []string, clusterPolicies opv1.NodeDisruptionPolicyClusterStatus) []opv1.NodeDisruptionPolicyStatusAction {
-	actions := []opv1.NodeDisruptionPolicyStatusAction{}
+// The osActions of kernel argument and extension changes are merged with the actions of the files, units and SSH keys.
+func calculatePostConfigChangeNodeDisruptionActionFromMCDiffs(diffSSH bool, diffFileSet, diffUnitSet []string, clusterPolicies opv1.NodeDisruptionPolicyClusterStatus, osActions ...opv1.NodeDisruptionPolicyStatusAction) []opv1.NodeDisruptionPolicyStatusAction {
+	actions := slices.Clone(osActions)
 
 	// Step through all file based policies, and build out the actions object
 	for _, diffPath := range diffFileSet {
@@ -737,13 +745,19 @@ func calculatePostConfigChangeAction(diff *machineConfigDiff, diffFileSet []stri
 		return []string{postConfigChangeActionReboot}, nil
 	}
 
+	return calculatePostConfigChangeActionFromDiff(diff, diffFileSet), nil
+}
+
+// calculatePostConfigChangeActionFromDiff calculates the actions of a diff, not
+// considering the force file.
+func calculatePostConfigChangeActionFromDiff(diff *machineConfigDiff, diffFileSet []string) []string {
 	if diff.osUpdate || diff.kargs || diff.fips || diff.units || diff.kernelType || diff.extensions {
 		// must reboot
-		return []string{postConfigChangeActionReboot}, nil
+		return []string{postConfigChangeActionReboot}
 	}
 
 	// Calculate actions based on file, unit and ssh diffs
-	return calculatePostConfigChangeActionFromMCDiffs(diffFileSet), nil
+	return calculatePostConfigChangeActionFromMCDiffs(diffFileSet)
 }
 
 // calculatePostConfigChangeNodeDisruptionAction takes action based on the cluster's Node disruption policies.
@@ -788,22 +802,9 @@ func (dn *Daemon) calculatePostConfigChangeNodeDisruptionAction(diff *machineCon
 		}}, nil
 	}
 
-	if diff.osUpdate || diff.kargs || diff.fips || diff.kernelType || diff.extensions {
-		// must reboot
-		return []opv1.NodeDisruptionPolicyStatusAction{{
-			Type: opv1.RebootStatusAction,
-		}}, nil
-	}
-	if !diff.files && !diff.units && !diff.passwd {
-		// This is a diff which requires no actions
-		klog.Infof("No changes in files, units or SSH keys, no NodeDisruptionPolicies are in effect")
-		return []opv1.NodeDisruptionPolicyStatusAction{{
-			Type: opv1.NoneStatusAction,
-		}}, nil
-	}
+	osPolicy := applyMachineConfigurationToDiff(diff, mcop, dn.fgHandler)
 
-	// Calculate actions based on file, unit and ssh diffs
-	nodeDisruptionActions := calculatePostConfigChangeNodeDisruptionActionFromMCDiffs(diff.passwd, diffFileSet, diffUnitSet, mcop.Status.NodeDisruptionPolicyStatus.ClusterPolicies)
+	nodeDisruptionActions := calculatePostConfigChangeNodeDisruptionActionFromDiff(diff, diffFileSet, diffUnitSet, mcop.Status.NodeDisruptionPolicyStatus.ClusterPolicies, osPolicy)
 
 	// Print out node disruption actions for debug purposes
 	klog.Infof("Calculated node disruption actions:")
@@ -822,6 +823,101 @@ func (dn *Daemon) calculatePostConfigChangeNodeDisruptionAction(diff *machineCon
 
 }
 
+// applyMachineConfigurationToDiff applies the settings of the
+// MachineConfiguration which change how a diff is applied: whether kernel
+// arguments may be applied live, and the OS node disruption policy, which is
+// returned. Both are TechPreview and only applied if the OSNodeDisruptionPolicy
+// feature gate is enabled. The MachineConfiguration and the feature gates
+// handler may be nil.
+func applyMachineConfigurationToDiff(diff *machineConfigDiff, mcop *opv1.MachineConfiguration, fgHandler ctrlcommon.FeatureGatesHandler) *apihelpers.OSNodeDisruptionPolicy {
+	if fgHandler == nil || !fgHandler.Enabled(apihelpers.OSNodeDisruptionPolicyFeatureGate) {
+		if hasOSNodeDisruptionPolicyAnnotations(mcop) {
+			klog.Warningf("Ignoring the %s and %s annotations of the MachineConfiguration: the %s feature gate is disabled",
+				constants.OSNodeDisruptionPolicyAnnotationKey, constants.LiveApplyKernelArgumentsAnnotationKey, apihelpers.OSNodeDisruptionPolicyFeatureGate)
+		}
+		diff.liveKargs = false
+		diff.unitHealthCheck = nil
+		return nil
+	}
+
+	// Kernel arguments are only applied live if the cluster opted in
+	if diff.liveKargs && !isLiveKernelArgsEnabled(mcop) {
+		diff.liveKargs = false
+	}
+
+	// Invalid OS node disruption policies are reported by the operator in
+	// the MachineConfiguration status
+	osPolicy, err := apihelpers.GetOSNodeDisruptionPolicy(mcop)
+	if err != nil {
+		klog.Warningf("Ignoring OS node disruption policy: %v", err)
+	}
+	if osPolicy != nil {
+		diff.rebootMethods = osPolicy.RebootMethods
+	}
+	diff.unitHealthCheck = osPolicy.GetUnitHealthCheck()
+	return osPolicy
+}
+
+// calculatePostConfigChangeNodeDisruptionActionFromDiff calculates the node
+// disruption actions of a diff with the given cluster policies, not considering
+// the force file.
+func calculatePostConfigChangeNodeDisruptionActionFromDiff(diff *machineConfigDiff, diffFileSet, diffUnitSet []string, clusterPolicies opv1.NodeDisruptionPolicyClusterStatus, osPolicy *apihelpers.OSNodeDisruptionPolicy) []opv1.NodeDisruptionPolicyStatusAction {
+	osActions, osPolicyFound := calculateOSNodeDisruptionActions(diff, osPolicy)
+	if diff.osUpdate || diff.fips || diff.kernelType || !osPolicyFound {
+		// must reboot
+		return []opv1.NodeDisruptionPolicyStatusAction{{
+			Type: opv1.RebootStatusAction,
+		}}
+	}
+	if !diff.files && !diff.units && !diff.passwd && len(osActions) == 0 {
+		// This is a diff which requires no actions
+		klog.Infof("No changes in files, units or SSH keys, no NodeDisruptionPolicies are in effect")
+		return []opv1.NodeDisruptionPolicyStatusAction{{
+			Type: opv1.NoneStatusAction,
+		}}
+	}
+
+	// Calculate actions based on file, unit and ssh diffs
+	return calculatePostConfigChangeNodeDisruptionActionFromMCDiffs(diff.passwd, diffFileSet, diffUnitSet, clusterPolicies, osActions...)
+}
+
+// calculateOSNodeDisruptionActions returns the actions of the kargs and
+// extensions changes of a diff, and false if any of them has no policy and
+// requires a reboot. Removed extensions always require a reboot, as removing
+// packages can't be applied live safely.
+func calculateOSNodeDisruptionActions(diff *machineConfigDiff, osPolicy *apihelpers.OSNodeDisruptionPolicy) ([]opv1.NodeDisruptionPolicyStatusAction, bool) {
+	actions := []opv1.NodeDisruptionPolicyStatusAction{}
+	if diff.kargs && !diff.liveKargs {
+		if osPolicy == nil {
+			return nil, false
+		}
+		for _, karg := range diff.changedKargs {
+			kargActions, found := osPolicy.KernelArgumentActions(karg)
+			if !found {
+				klog.V(4).Infof("no policy found for diff karg %s", karg)
+				return nil, false
+			}
+			klog.Infof("OS node disruption policy %v found for diff karg %s", kargActions, karg)
+			actions = append(actions, kargActions...)
+		}
+	}
+	if diff.extensions {
+		if osPolicy == nil || len(diff.removedExtensions) > 0 {
+			return nil, false
+		}
+		for _, extension := range diff.addedExtensions {
+			extensionActions, found := osPolicy.ExtensionActions(extension)
+			if !found {
+				klog.V(4).Infof("no policy found for diff extension %s", extension)
+				return nil, false
+			}
+			klog.Infof("OS node disruption policy %v found for diff extension %s", extensionActions, extension)
+			actions = append(actions, extensionActions...)
+		}
+	}
+	return actions, true
+}
+
 // Finalizes the revert process by enabling a special systemd unit prior to
 // rebooting the node.
 //
@@ -901,7 +997,10 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertifi
 
 	if mcDiff.oclEnabled {
 		// Check if any OCL-specific changes are present
-		oclChange := ctrlcommon.RequiresRebuild(oldConfig, newConfig)
+		oclChange, err := ctrlcommon.RequiresRebuild(oldConfig, newConfig)
+		if err != nil {
+			return fmt.Errorf("could not determine whether the update requires an image rebuild: %w", err)
+		}
 		if !oclChange {
 			klog.Info("OCL enabled but no OCL-specific changes detected - applying non-OCL update")
 			mcDiff.oclEnabled = false
@@ -1181,7 +1280,7 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertifi
 	}
 
 	// update files on disk that need updating
-	if err := dn.updateFiles(oldIgnConfig, newIgnConfig, addedOrChangedUnits, skipCertificateWrite, forceFilePresent); err != nil {
+	if err := dn.updateFiles(oldIgnConfig, newIgnConfig, addedOrChangedUnits, skipCertificateWrite, forceFilePresent, firstBoot); err != nil {
 		// When ImageModeStatusReporting is enabled, update the `MachineConfigNodeUpdateFiles` condition to report the experienced error
 		if imageModeStatusReportingEnabled {
 			mcnErr := upgrademonitor.GenerateAndApplyMachineConfigNodes(
@@ -1201,10 +1300,16 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertifi
 		return err
 	}
 
+	rollbackFiles := func() error {
+		rollbackUnitDiff := ctrlcommon.GetChangedConfigUnitsByType(&newIgnConfig, &oldIgnConfig)
+		return dn.updateFiles(newIgnConfig, oldIgnConfig, slices.Concat(rollbackUnitDiff.Added, rollbackUnitDiff.Updated), skipCertificateWrite, false, false)
+	}
+	// Set once the files were restored by the rollback of a failed unit
+	// health check
+	filesRolledBack := false
 	defer func() {
-		if retErr != nil {
-			rollbackUnitDiff := ctrlcommon.GetChangedConfigUnitsByType(&newIgnConfig, &oldIgnConfig)
-			if err := dn.updateFiles(newIgnConfig, oldIgnConfig, slices.Concat(rollbackUnitDiff.Added, rollbackUnitDiff.Updated), skipCertificateWrite, false); err != nil {
+		if retErr != nil && !filesRolledBack {
+			if err := rollbackFiles(); err != nil {
 				errs := kubeErrs.NewAggregate([]error{err, retErr})
 				retErr = fmt.Errorf("error rolling back files writes: %w", errs)
 				return
@@ -1220,6 +1325,22 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertifi
 	// only update passwd if it has changed (do not nullify)
 	// we do not need to include SetPasswordHash in this, since only updateSSHKeys has issues on firstboot.
 	if diff.passwd {
+		// Users and groups are created before their SSH keys and password
+		// hashes are written.
+		if err := dn.updateUsersAndGroups(newIgnConfig.Passwd, oldIgnConfig.Passwd); err != nil {
+			return err
+		}
+
+		defer func() {
+			if retErr != nil {
+				if err := dn.updateUsersAndGroups(oldIgnConfig.Passwd, newIgnConfig.Passwd); err != nil {
+					errs := kubeErrs.NewAggregate([]error{err, retErr})
+					retErr = fmt.Errorf("error rolling back users and groups updates: %w", errs)
+					return
+				}
+			}
+		}()
+
 		if err := dn.updateSSHKeys(newIgnConfig.Passwd.Users, oldIgnConfig.Passwd.Users); err != nil {
 			// When ImageModeStatusReporting is enabled, update the `MachineConfigNodeUpdateFiles` condition to report the experienced error
 			if imageModeStatusReportingEnabled {
@@ -1302,13 +1423,45 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertifi
 				}
 			}
 		}()
+
+		// Extensions added under an OS node disruption policy are applied to
+		// the running system, falling back to a reboot if that fails.
+		if !firstBoot && diff.extensions && !apihelpers.CheckNodeDisruptionActionsForTargetActions(nodeDisruptionActions, opv1.RebootStatusAction) {
+			if err := runRpmOstree("apply-live"); err != nil {
+				klog.Warningf("Failed to apply extensions live, falling back to a reboot: %v", err)
+				if !drain {
+					if err := dn.performDrain(); err != nil {
+						return err
+					}
+				}
+				nodeDisruptionActions = []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RebootStatusAction}}
+			}
+		}
 	} else {
 		klog.Info("updating the OS on non-CoreOS nodes is not supported")
 	}
 
+	// The kargs are staged for the next boot, apply their runtime equivalents now
+	if !firstBoot && diff.liveKargs {
+		previousSettings, err := applyLiveKernelArgs(diff.liveKargChanges)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if retErr != nil {
+				if _, err := applyLiveKernelArgs(previousSettings); err != nil {
+					errs := kubeErrs.NewAggregate([]error{err, retErr})
+					retErr = fmt.Errorf("error rolling back kernel argument runtime settings: %w", errs)
+					return
+				}
+			}
+		}()
+	}
+
 	// Ideally we would want to update kernelArguments only via MachineConfigs.
 	// We are keeping this to maintain compatibility and OKD requirement.
-	if err := UpdateTuningArgs(KernelTuningFile, CmdLineFile); err != nil {
+	if err := dn.updateTuningArgs(); err != nil {
 		return err
 	}
 
@@ -1391,7 +1544,27 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertifi
 
 	// Node Disruption Policies cannot be used during firstboot as API is not accessible.
 	if !firstBoot {
-		return dn.performPostConfigChangeNodeDisruptionAction(nodeDisruptionActions, newConfig.GetName())
+		var rebootPlan *fastRebootPlan
+		if dn.os.IsCoreOSVariant() && apihelpers.CheckNodeDisruptionActionsForTargetActions(nodeDisruptionActions, opv1.RebootStatusAction) {
+			rebootPlan = dn.planFastReboot(diff)
+		}
+		var unitWatch *unitHealthWatch
+		if !apihelpers.CheckNodeDisruptionActionsForTargetActions(nodeDisruptionActions, opv1.RebootStatusAction) {
+			unitWatch = newUnitHealthWatch(diff.unitHealthCheck, addedOrChangedUnits, nodeDisruptionActions)
+		}
+		if unitWatch != nil {
+			unitWatch.previousConfig = oldConfigName
+			unitWatch.drained = drain
+			unitWatch.rollback = func() error {
+				if err := rollbackFiles(); err != nil {
+					return err
+				}
+				filesRolledBack = true
+				restartUnitsAfterRollback(unitWatch)
+				return nil
+			}
+		}
+		return dn.performPostConfigChangeNodeDisruptionAction(nodeDisruptionActions, newConfig.GetName(), rebootPlan, unitWatch)
 	}
 	// If we're here, node disruption policies can't be used, so perform legacy action
 	return dn.performPostConfigChangeAction(actions, newConfig.GetName())
@@ -1418,14 +1591,14 @@ func (dn *Daemon) updateHypershift(oldConfig, newConfig *mcfgv1.MachineConfig, d
 
 	// update files on disk that need updating
 	// We should't skip the certificate write in HyperShift since it does not run the extra daemon process
-	if err := dn.updateFiles(oldIgnConfig, newIgnConfig, addedOrChangedUnits, false, forceFilePresent); err != nil {
+	if err := dn.updateFiles(oldIgnConfig, newIgnConfig, addedOrChangedUnits, false, forceFilePresent, false); err != nil {
 		return err
 	}
 
 	defer func() {
 		if retErr != nil {
 			rollbackUnitDiff := ctrlcommon.GetChangedConfigUnitsByType(&newIgnConfig, &oldIgnConfig)
-			if err := dn.updateFiles(newIgnConfig, oldIgnConfig, slices.Concat(rollbackUnitDiff.Added, rollbackUnitDiff.Updated), false, false); err != nil {
+			if err := dn.updateFiles(newIgnConfig, oldIgnConfig, slices.Concat(rollbackUnitDiff.Added, rollbackUnitDiff.Updated), false, false, false); err != nil {
 				errs := kubeErrs.NewAggregate([]error{err, retErr})
 				retErr = fmt.Errorf("error rolling back files writes: %w", errs)
 				return
@@ -1433,6 +1606,20 @@ func (dn *Daemon) updateHypershift(oldConfig, newConfig *mcfgv1.MachineConfig, d
 		}
 	}()
 
+	if err := dn.updateUsersAndGroups(newIgnConfig.Passwd, oldIgnConfig.Passwd); err != nil {
+		return err
+	}
+
+	defer func() {
+		if retErr != nil {
+			if err := dn.updateUsersAndGroups(oldIgnConfig.Passwd, newIgnConfig.Passwd); err != nil {
+				errs := kubeErrs.NewAggregate([]error{err, retErr})
+				retErr = fmt.Errorf("error rolling back users and groups updates: %w", errs)
+				return
+			}
+		}
+	}()
+
 	if err := dn.updateSSHKeys(newIgnConfig.Passwd.Users, oldIgnConfig.Passwd.Users); err != nil {
 		return err
 	}
@@ -1466,7 +1653,7 @@ func (dn *Daemon) updateHypershift(oldConfig, newConfig *mcfgv1.MachineConfig, d
 		klog.Info("updating the OS on non-CoreOS nodes is not supported")
 	}
 
-	if err := UpdateTuningArgs(KernelTuningFile, CmdLineFile); err != nil {
+	if err := dn.updateTuningArgs(); err != nil {
 		return err
 	}
 
@@ -1493,16 +1680,31 @@ func (dn *Daemon) removeRollback() error {
 // and the MCO would just operate on that.  For now we're just doing this to get
 // improved logging.
 type machineConfigDiff struct {
-	osUpdate      bool
-	kargs         bool
-	fips          bool
-	passwd        bool
-	files         bool
-	units         bool
-	kernelType    bool
-	extensions    bool
-	oclEnabled    bool
-	revertFromOCL bool
+	osUpdate bool
+	kargs    bool
+	// liveKargs is set if all the kargs changes have runtime equivalents,
+	// which are listed in liveKargChanges
+	liveKargs       bool
+	liveKargChanges []liveKernelArgChange
+	// changedKargs are the names of the changed kargs
+	changedKargs []string
+	// addedExtensions and removedExtensions are set if extensions changed
+	addedExtensions   []string
+	removedExtensions []string
+	// rebootMethods are the alternatives to a full reboot allowed by the OS
+	// node disruption policy
+	rebootMethods []apihelpers.RebootMethod
+	// unitHealthCheck is how the units are checked after an update applied
+	// without a reboot, nil if they are not checked
+	unitHealthCheck *apihelpers.UnitHealthCheck
+	fips            bool
+	passwd          bool
+	files           bool
+	units           bool
+	kernelType      bool
+	extensions      bool
+	oclEnabled      bool
+	revertFromOCL   bool
 }
 
 // isEmpty returns true if the machineConfigDiff has no changes, or
@@ -1534,8 +1736,13 @@ func (mcDiff *machineConfigDiff) osChangesString() string {
 	return strings.Join(changes, "; ")
 }
 
-// newMachineConfigDiff compares two MachineConfig objects.
+// newMachineConfigDiff compares two MachineConfig objects. The presence of the
+// force file is reported as an OS update.
 func newMachineConfigDiff(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDiff, error) {
+	return diffMachineConfigs(oldConfig, newConfig, forceFileExists())
+}
+
+func diffMachineConfigs(oldConfig, newConfig *mcfgv1.MachineConfig, force bool) (*machineConfigDiff, error) {
 	oldIgn, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
 	if err != nil {
 		return nil, fmt.Errorf("parsing old Ignition config failed with error: %w", err)
@@ -1550,23 +1757,37 @@ func newMachineConfigDiff(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineC
 	kargsEmpty := len(oldConfig.Spec.KernelArguments) == 0 && len(newConfig.Spec.KernelArguments) == 0
 	extensionsEmpty := len(oldConfig.Spec.Extensions) == 0 && len(newConfig.Spec.Extensions) == 0
 
-	force := forceFileExists()
+	// Directories and links are written along with the files
+	filesChanged := !reflect.DeepEqual(oldIgn.Storage.Files, newIgn.Storage.Files) ||
+		!reflect.DeepEqual(oldIgn.Storage.Directories, newIgn.Storage.Directories) ||
+		!reflect.DeepEqual(oldIgn.Storage.Links, newIgn.Storage.Links)
 
 	_, oldOCLImage := extractOCLImageFromMachineConfig(oldConfig)
 	_, newOCLImage := extractOCLImageFromMachineConfig(newConfig)
 
 	diff := &machineConfigDiff{
 		osUpdate:   oldConfig.Spec.OSImageURL != newConfig.Spec.OSImageURL || force,
-		kargs:      !(kargsEmpty || reflect.DeepEqual(oldConfig.Spec.KernelArguments, newConfig.Spec.KernelArguments)),
+		kargs:      !(kargsEmpty || reflect.DeepEqual(oldConfig.Spec.KernelArguments, newConfig.Spec.KernelArguments)) || ignKargsChanged(oldIgn.KernelArguments, newIgn.KernelArguments),
 		fips:       oldConfig.Spec.FIPS != newConfig.Spec.FIPS,
 		passwd:     !reflect.DeepEqual(oldIgn.Passwd, newIgn.Passwd),
-		files:      !reflect.DeepEqual(oldIgn.Storage.Files, newIgn.Storage.Files),
+		files:      filesChanged,
 		units:      !reflect.DeepEqual(oldIgn.Systemd.Units, newIgn.Systemd.Units),
 		kernelType: helpers.CanonicalizeKernelType(oldConfig.Spec.KernelType) != helpers.CanonicalizeKernelType(newConfig.Spec.KernelType),
 		extensions: !(extensionsEmpty || reflect.DeepEqual(oldConfig.Spec.Extensions, newConfig.Spec.Extensions)),
 		oclEnabled: (oldOCLImage != "" || newOCLImage != "") || (oldOCLImage != "" && newOCLImage != ""),
 	}
 
+	if diff.kargs {
+		diff.changedKargs = changedKernelArgKeys(oldConfig.Spec.KernelArguments, newConfig.Spec.KernelArguments, oldIgn.KernelArguments, newIgn.KernelArguments)
+		diff.liveKargChanges, diff.liveKargs = planLiveKernelArgs(oldConfig.Spec.KernelArguments, newConfig.Spec.KernelArguments, oldIgn.KernelArguments, newIgn.KernelArguments)
+	}
+	if diff.extensions {
+		oldExtensions := sets.New(oldConfig.Spec.Extensions...)
+		newExtensions := sets.New(newConfig.Spec.Extensions...)
+		diff.addedExtensions = sets.List(newExtensions.Difference(oldExtensions))
+		diff.removedExtensions = sets.List(oldExtensions.Difference(newExtensions))
+	}
+
 	if !diff.oclEnabled {
 		return diff, nil
 	}
@@ -1719,12 +1940,15 @@ func parseKernelArguments(kargs []string) []string {
 	return parsed
 }
 
-// generateKargs performs a diff between the old/new MC kernelArguments,
-// and generates the command line arguments suitable for `rpm-ostree kargs`.
+// generateKargs performs a diff between the old/new MC kernelArguments and
+// Ignition kernelArguments sections, and generates the command line arguments
+// suitable for `rpm-ostree kargs`.
 // Note what we really should be doing though is also looking at the *current*
 // kernel arguments in case there was drift.  But doing that requires us knowing
 // what the "base" arguments are. See https://github.com/ostreedev/ostree/issues/479
-func generateKargs(oldKernelArguments, newKernelArguments []string) []string {
+// Dropped Ignition shouldExist arguments are only deleted if they are listed in
+// appendedIgnKargs, i.e. the MCD appended them.
+func generateKargs(oldKernelArguments, newKernelArguments []string, oldIgnKargs, newIgnKargs ign3types.KernelArguments, appendedIgnKargs []string) []string {
 	oldKargs := parseKernelArguments(oldKernelArguments)
 	newKargs := parseKernelArguments(newKernelArguments)
 	cmdArgs := []string{}
@@ -1739,22 +1963,111 @@ func generateKargs(oldKernelArguments, newKernelArguments []string) []string {
 	for _, arg := range oldKargs {
 		cmdArgs = append(cmdArgs, "--delete-if-present="+arg)
 	}
+	// Ignition shouldExist arguments which are no longer listed are deleted
+	// as well if the MCD appended them. Arguments which are still listed, or
+	// which were already present, are kept, as they may be shipped by the
+	// base OS.
+	for _, arg := range oldIgnKargs.ShouldExist {
+		if !slices.Contains(newIgnKargs.ShouldExist, arg) && slices.Contains(appendedIgnKargs, string(arg)) {
+			cmdArgs = append(cmdArgs, "--delete-if-present="+string(arg))
+		}
+	}
 	for _, arg := range newKargs {
 		cmdArgs = append(cmdArgs, "--append="+arg)
 	}
+	// Following Ignition semantics, shouldExist arguments are only appended if
+	// missing, and shouldNotExist arguments are deleted even if the base OS
+	// ships them. Arguments which are no longer listed in shouldNotExist are
+	// not restored.
+	for _, arg := range newIgnKargs.ShouldExist {
+		cmdArgs = append(cmdArgs, "--append-if-missing="+string(arg))
+	}
+	for _, arg := range newIgnKargs.ShouldNotExist {
+		cmdArgs = append(cmdArgs, "--delete-if-present="+string(arg))
+	}
 	return cmdArgs
 }
 
+// ignKargsChanged returns whether the Ignition kernelArguments sections
+// differ, considering nil and empty lists as equal.
+func ignKargsChanged(oldIgnKargs, newIgnKargs ign3types.KernelArguments) bool {
+	return !slices.Equal(oldIgnKargs.ShouldExist, newIgnKargs.ShouldExist) ||
+		!slices.Equal(oldIgnKargs.ShouldNotExist, newIgnKargs.ShouldNotExist)
+}
+
 // updateKernelArguments adjusts the kernel args
-func (dn *CoreOSDaemon) updateKernelArguments(oldKernelArguments, newKernelArguments []string) error {
-	kargs := generateKargs(oldKernelArguments, newKernelArguments)
-	if len(kargs) == 0 {
-		return nil
+func (dn *CoreOSDaemon) updateKernelArguments(oldConfig, newConfig *mcfgv1.MachineConfig) error {
+	oldIgn, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
+	if err != nil {
+		return fmt.Errorf("parsing old Ignition config failed with error: %w", err)
 	}
+	newIgn, err := ctrlcommon.ParseAndConvertConfig(newConfig.Spec.Config.Raw)
+	if err != nil {
+		return fmt.Errorf("parsing new Ignition config failed with error: %w", err)
+	}
+
+	appended, err := readAppendedKargs(appendedKargsPath)
+	if err != nil {
+		return err
+	}
+	currentKargs, err := dn.cmdRunner.RunGetOut("rpm-ostree", "kargs")
+	if err != nil {
+		return err
+	}
+	newAppended := appendedShouldExistKargs(strings.Fields(string(currentKargs)), parseKernelArguments(oldConfig.Spec.KernelArguments), appended, newIgn.KernelArguments.ShouldExist)
 
-	args := append([]string{"kargs"}, kargs...)
-	logSystem("Running rpm-ostree %v", args)
-	return runRpmOstree(args...)
+	kargs := generateKargs(oldConfig.Spec.KernelArguments, newConfig.Spec.KernelArguments, oldIgn.KernelArguments, newIgn.KernelArguments, appended)
+	if len(kargs) > 0 {
+		args := append([]string{"kargs"}, kargs...)
+		logSystem("Running rpm-ostree %v", args)
+		if err := runRpmOstree(args...); err != nil {
+			return err
+		}
+	}
+	return writeAppendedKargs(appendedKargsPath, newAppended)
+}
+
+// appendedShouldExistKargs returns the Ignition shouldExist kernel arguments
+// appended by the MCD: the ones it appended before, and the ones missing from
+// the current kernel arguments, not counting the MachineConfig kernelArguments
+// it deletes and appends again. Arguments appended by Ignition on firstboot
+// can't be told apart from the ones of the base OS, so they are never deleted.
+func appendedShouldExistKargs(currentKargs, oldKernelArguments, appended []string, shouldExist []ign3types.KernelArgument) []string {
+	newAppended := []string{}
+	for _, arg := range shouldExist {
+		if slices.Contains(appended, string(arg)) || !slices.Contains(currentKargs, string(arg)) || slices.Contains(oldKernelArguments, string(arg)) {
+			newAppended = append(newAppended, string(arg))
+		}
+	}
+	return newAppended
+}
+
+// readAppendedKargs reads the record of the Ignition shouldExist kernel
+// arguments appended by the MCD, which is empty if there is none.
+func readAppendedKargs(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading appended kernel arguments: %w", err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n"), nil
+}
+
+// writeAppendedKargs records the Ignition shouldExist kernel arguments
+// appended by the MCD, one per line.
+func writeAppendedKargs(path string, appended []string) error {
+	if len(appended) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing appended kernel arguments: %w", err)
+		}
+		return nil
+	}
+	if err := writeFileAtomicallyWithDefaults(path, []byte(strings.Join(appended, "\n")+"\n")); err != nil {
+		return fmt.Errorf("writing appended kernel arguments: %w", err)
+	}
+	return nil
 }
 
 // getCurrentlyInstalledPackages returns the list of currently installed extension packages
@@ -2084,9 +2397,15 @@ func (dn *CoreOSDaemon) getKernelPackagesForTargetRelease() releaseKernelPackage
 // whatever has been written is picked up by the appropriate daemons, if
 // required. in particular, a daemon-reload and restart for any unit files
 // touched.
-func (dn *Daemon) updateFiles(oldIgnConfig, newIgnConfig ign3types.Config, addedOrChangedUnits []ign3types.Unit, skipCertificateWrite, forceFilePresent bool) error {
+func (dn *Daemon) updateFiles(oldIgnConfig, newIgnConfig ign3types.Config, addedOrChangedUnits []ign3types.Unit, skipCertificateWrite, forceFilePresent, firstBoot bool) error {
 	klog.Info("Updating files")
-	if err := dn.writeFiles(newIgnConfig.Storage.Files, skipCertificateWrite); err != nil {
+	if err := writeDirectories(newIgnConfig.Storage.Directories); err != nil {
+		return err
+	}
+	if err := dn.writeFiles(newIgnConfig.Storage.Files, skipCertificateWrite, firstBoot); err != nil {
+		return err
+	}
+	if err := writeLinks(newIgnConfig.Storage.Links); err != nil {
 		return err
 	}
 
@@ -2156,7 +2475,8 @@ func (dn *Daemon) isPathInDropins(path string, systemd *ign3types.Systemd) bool
 }
 
 // deleteStaleData performs a diff of the new and the old Ignition config. It then deletes
-// all the files, units that are present in the old config but not in the new one.
+// all the files, links, directories and units that are present in the old config but not
+// in the new one.
 // this function will error out if it fails to delete a file (with the exception
 // of simply warning if the error is ENOENT since that's the desired state).
 //
@@ -2164,10 +2484,25 @@ func (dn *Daemon) isPathInDropins(path string, systemd *ign3types.Systemd) bool
 func (dn *Daemon) deleteStaleData(oldIgnConfig, newIgnConfig ign3types.Config) error {
 	klog.Info("Deleting stale data")
 
+	// Links are restored or deleted like files. A path is only stale if no
+	// file, link or directory of the new config replaced it.
 	newFileSet := make(map[string]struct{})
 	for _, f := range newIgnConfig.Storage.Files {
 		newFileSet[f.Path] = struct{}{}
 	}
+	for _, l := range newIgnConfig.Storage.Links {
+		newFileSet[l.Path] = struct{}{}
+	}
+	for _, d := range newIgnConfig.Storage.Directories {
+		newFileSet[d.Path] = struct{}{}
+	}
+	var oldFilePaths []string
+	for _, f := range oldIgnConfig.Storage.Files {
+		oldFilePaths = append(oldFilePaths, f.Path)
+	}
+	for _, l := range oldIgnConfig.Storage.Links {
+		oldFilePaths = append(oldFilePaths, l.Path)
+	}
 
 	// need to skip these on upgrade if they are in a MC, or else we will remove all certs!
 	certsToSkip := []string{
@@ -2175,42 +2510,56 @@ func (dn *Daemon) deleteStaleData(oldIgnConfig, newIgnConfig ign3types.Config) e
 		caBundleFilePath,
 		cloudCABundleFilePath,
 	}
-	for _, f := range oldIgnConfig.Storage.Files {
-		if _, ok := newFileSet[f.Path]; ok {
+	for _, fPath := range oldFilePaths {
+		if _, ok := newFileSet[fPath]; ok {
 			continue
 		}
 		skipBecauseCert := false
 		for _, cert := range certsToSkip {
-			if cert == f.Path {
+			if cert == fPath {
 				skipBecauseCert = true
 				break
 			}
 		}
-		if strings.Contains(filepath.Dir(f.Path), imageCAFilePath) {
+		if strings.Contains(filepath.Dir(fPath), imageCAFilePath) {
 			skipBecauseCert = true
 		}
 		if skipBecauseCert {
 			continue
 		}
-		if _, err := os.Stat(noOrigFileStampName(f.Path)); err == nil {
-			if delErr := os.Remove(noOrigFileStampName(f.Path)); delErr != nil {
-				return fmt.Errorf("deleting noorig file stamp %q: %w", noOrigFileStampName(f.Path), delErr)
+		// Files the MCD appended to go back to the contents they had before
+		restored, err := restoreAppendBaseFile(fPath)
+		if err != nil {
+			return err
+		}
+		if restored {
+			for _, record := range []string{noOrigFileStampName(fPath), origFileName(fPath)} {
+				if err := os.Remove(record); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("deleting %q: %w", record, err)
+				}
+			}
+			klog.Infof("Restored appended file %q", fPath)
+			continue
+		}
+		if _, err := os.Stat(noOrigFileStampName(fPath)); err == nil {
+			if delErr := os.Remove(noOrigFileStampName(fPath)); delErr != nil {
+				return fmt.Errorf("deleting noorig file stamp %q: %w", noOrigFileStampName(fPath), delErr)
 			}
-			klog.V(2).Infof("Removing file %q completely", f.Path)
-		} else if _, err := os.Stat(origFileName(f.Path)); err == nil {
+			klog.V(2).Infof("Removing file %q completely", fPath)
+		} else if _, err := os.Stat(origFileName(fPath)); err == nil {
 			// Add a check for backwards compatibility: basically if the file doesn't exist in /usr/etc (on FCOS/RHCOS)
 			// and no rpm is claiming it, we assume that the orig file came from a wrongful backup of a MachineConfig
 			// file instead of a file originally on disk. See https://bugzilla.redhat.com/show_bug.cgi?id=1814397
 			restore := false
-			rpmNotFound, isOwned, err := isFileOwnedByRPMPkg(f.Path)
+			rpmNotFound, isOwned, err := isFileOwnedByRPMPkg(fPath)
 			switch {
 			case isOwned:
 				// File is owned by an rpm
 				restore = true
 			case !isOwned && err == nil:
 				// Run on Fedora/RHEL - check whether the file exists in /usr/etc (on FCOS/RHCOS)
-				if strings.HasPrefix(f.Path, "/etc") {
-					if _, err := os.Stat(withUsrPath(f.Path)); err != nil {
+				if strings.HasPrefix(fPath, "/etc") {
+					if _, err := os.Stat(withUsrPath(fPath)); err != nil {
 						if !os.IsNotExist(err) {
 							return err
 						}
@@ -2226,38 +2575,49 @@ func (dn *Daemon) deleteStaleData(oldIgnConfig, newIgnConfig ign3types.Config) e
 			}
 
 			if restore {
-				if err := restorePath(f.Path); err != nil {
+				// cp would write the orig file through a stale link
+				if fi, err := os.Lstat(fPath); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+					if err := os.Remove(fPath); err != nil {
+						return fmt.Errorf("unable to delete link %s: %w", fPath, err)
+					}
+				}
+				if err := restorePath(fPath); err != nil {
 					return err
 				}
-				klog.V(2).Infof("Restored file %q", f.Path)
+				klog.V(2).Infof("Restored file %q", fPath)
 				continue
 			}
 
-			if delErr := os.Remove(origFileName(f.Path)); delErr != nil {
-				return fmt.Errorf("deleting orig file %q: %w", origFileName(f.Path), delErr)
+			if delErr := os.Remove(origFileName(fPath)); delErr != nil {
+				return fmt.Errorf("deleting orig file %q: %w", origFileName(fPath), delErr)
 			}
 		}
 
 		// Check Systemd.Units.Dropins - don't remove the file if configuration has been converted into a dropin
-		if dn.isPathInDropins(f.Path, &newIgnConfig.Systemd) {
-			klog.Infof("Not removing file %q: replaced with systemd dropin", f.Path)
+		if dn.isPathInDropins(fPath, &newIgnConfig.Systemd) {
+			klog.Infof("Not removing file %q: replaced with systemd dropin", fPath)
 			continue
 		}
 
-		klog.V(2).Infof("Deleting stale config file: %s", f.Path)
-		if err := os.Remove(f.Path); err != nil {
-			newErr := fmt.Errorf("unable to delete %s: %w", f.Path, err)
+		klog.V(2).Infof("Deleting stale config file: %s", fPath)
+		if err := os.Remove(fPath); err != nil {
+			newErr := fmt.Errorf("unable to delete %s: %w", fPath, err)
 			if !os.IsNotExist(err) {
 				return newErr
 			}
 			// otherwise, just warn
 			klog.Warningf("%v", newErr)
 		}
-		klog.Infof("Removed stale file %q", f.Path)
+		klog.Infof("Removed stale file %q", fPath)
+	}
+
+	if err := deleteStaleDirectories(oldIgnConfig.Storage.Directories, newFileSet); err != nil {
+		return err
 	}
 
 	newUnitSet := make(map[string]struct{})
 	newDropinSet := make(map[string]struct{})
+	newUnits := make(map[string]ign3types.Unit)
 	for _, u := range newIgnConfig.Systemd.Units {
 		for j := range u.Dropins {
 			path := filepath.Join(pathSystemd, u.Name+".d", u.Dropins[j].Name)
@@ -2265,6 +2625,7 @@ func (dn *Daemon) deleteStaleData(oldIgnConfig, newIgnConfig ign3types.Config) e
 		}
 		path := filepath.Join(pathSystemd, u.Name)
 		newUnitSet[path] = struct{}{}
+		newUnits[u.Name] = u
 	}
 
 	for _, u := range oldIgnConfig.Systemd.Units {
@@ -2296,6 +2657,14 @@ func (dn *Daemon) deleteStaleData(oldIgnConfig, newIgnConfig ign3types.Config) e
 			}
 		}
 		path := filepath.Join(pathSystemd, u.Name)
+		// writeUnits() leaves the file of units without contents alone, so
+		// remove the mask of units which are no longer masked
+		if newUnit, ok := newUnits[u.Name]; ok && u.Mask != nil && *u.Mask && newUnit.Mask == nil && !unitHasContent(newUnit) && isUnitPathMasked(path) {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("unable to unmask %s: %w", u.Name, err)
+			}
+			klog.Infof("Unmasked systemd unit %q", u.Name)
+		}
 		if _, ok := newUnitSet[path]; !ok {
 			// since the unit doesn't exist anymore within the MachineConfig,
 			// look to restore defaults here, so that symlinks are removed first
@@ -2337,6 +2706,52 @@ func (dn *Daemon) deleteStaleData(oldIgnConfig, newIgnConfig ign3types.Config) e
 	return nil
 }
 
+// deleteStaleDirectories deletes the old directories whose paths are not in
+// newPathSet, deepest first. Only the directories the MCD created are deleted,
+// and only if they are empty, so that no file written by something else is
+// lost.
+func deleteStaleDirectories(oldDirs []ign3types.Directory, newPathSet map[string]struct{}) error {
+	var staleDirs []string
+	for _, d := range oldDirs {
+		if _, ok := newPathSet[d.Path]; !ok {
+			staleDirs = append(staleDirs, d.Path)
+		}
+	}
+	slices.SortFunc(staleDirs, func(a, b string) int { return strings.Compare(b, a) })
+
+	for _, path := range staleDirs {
+		if _, err := os.Stat(noOrigFileStampName(path)); err != nil {
+			if _, err := os.Stat(origFileName(path)); err == nil {
+				// The directory replaced a file, put the file back
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					klog.Warningf("Not restoring %q: unable to delete directory: %v", path, err)
+					continue
+				}
+				if err := restorePath(path); err != nil {
+					return err
+				}
+				klog.V(2).Infof("Restored file %q", path)
+				continue
+			}
+			klog.Infof("Not removing directory %q: it was not created by the MCD", path)
+			continue
+		}
+
+		klog.V(2).Infof("Deleting stale directory: %s", path)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			// Keep the stamp, so that the directory is deleted once emptied
+			// if it is added and removed again
+			klog.Warningf("Not removing directory %q: %v", path, err)
+			continue
+		}
+		if err := os.Remove(noOrigFileStampName(path)); err != nil {
+			return fmt.Errorf("deleting noorig file stamp %q: %w", noOrigFileStampName(path), err)
+		}
+		klog.Infof("Removed stale directory %q", path)
+	}
+	return nil
+}
+
 // Previous versions of the MCD leaked some enablement symlinks. We clean a
 // known problematic subset of those here. See also:
 // https://issues.redhat.com/browse/OCPBUGS-33694?focusedId=24917003#comment-24917003
@@ -2516,8 +2931,8 @@ func (dn *Daemon) listSystemdUnits() (result map[string]systemddbus.UnitFile, er
 
 // writeFiles writes the given files to disk.
 // it doesn't fetch remote files and expects a flattened config file.
-func (dn *Daemon) writeFiles(files []ign3types.File, skipCertificateWrite bool) error {
-	return writeFiles(files, skipCertificateWrite)
+func (dn *Daemon) writeFiles(files []ign3types.File, skipCertificateWrite, firstBoot bool) error {
+	return writeFiles(files, skipCertificateWrite, firstBoot)
 }
 
 // Ensures that both the SSH root directory (/home/core/.ssh) as well as any
@@ -2619,18 +3034,21 @@ func (dn *Daemon) SetPasswordHash(newUsers, oldUsers []ign3types.PasswdUser) err
 	// checking if old users need to be deconfigured
 	deconfigureAbsentUsers(newUsers, oldUsers)
 
-	var uErr user.UnknownUserError
-	switch _, err := user.Lookup(constants.CoreUserName); {
-	case err == nil:
-	case errors.As(err, &uErr):
-		klog.Info("core user does not exist, and creating users is not supported, so ignoring configuration specified for core user")
-		return nil
-	default:
-		return fmt.Errorf("failed to check if user core exists: %w", err)
-	}
-
 	// SetPasswordHash sets the password hash of the specified user.
+	var uErr user.UnknownUserError
 	for _, u := range newUsers {
+		if !shouldExist(u.ShouldExist) {
+			continue
+		}
+		switch _, err := user.Lookup(u.Name); {
+		case err == nil:
+		case errors.As(err, &uErr):
+			klog.Infof("user %s does not exist, so ignoring its password hash", u.Name)
+			continue
+		default:
+			return fmt.Errorf("failed to check if user %s exists: %w", u.Name, err)
+		}
+
 		pwhash := "*"
 		if u.PasswordHash != nil && *u.PasswordHash != "" {
 			pwhash = *u.PasswordHash
@@ -2685,21 +3103,27 @@ func (dn *Daemon) updateSSHKeys(newUsers, oldUsers []ign3types.PasswdUser) error
 	// Checking to see if absent users need to be deconfigured
 	deconfigureAbsentUsers(newUsers, oldUsers)
 
+	if err := dn.updateUserSSHKeys(newUsers, oldUsers); err != nil {
+		return err
+	}
+
 	var uErr user.UnknownUserError
 	switch _, err := user.Lookup(constants.CoreUserName); {
 	case err == nil:
 	case errors.As(err, &uErr):
-		klog.Info("core user does not exist, and creating users is not supported, so ignoring configuration specified for core user")
+		klog.Info("core user does not exist, so ignoring configuration specified for core user")
 		return nil
 	default:
 		return fmt.Errorf("failed to check if user core exists: %w", err)
 	}
 
-	// we're also appending all keys for any user to core, so for now
-	// we pass this to atomicallyWriteSSHKeys to write.
-	// we know these users are "core" ones also cause this slice went through Reconcilable
+	// The keys of the other users are written to their own home directories by
+	// updateUserSSHKeys, so only the keys of core are written here.
 	var concatSSHKeys string
 	for _, u := range newUsers {
+		if u.Name != constants.CoreUserName {
+			continue
+		}
 		for _, k := range u.SSHAuthorizedKeys {
 			concatSSHKeys = concatSSHKeys + string(k) + "\n"
 		}
@@ -2725,18 +3149,73 @@ func (dn *Daemon) updateSSHKeys(newUsers, oldUsers []ign3types.PasswdUser) error
 			}
 		}
 
-		// Note we write keys only for the core user and so this ignores the user list
 		return dn.atomicallyWriteSSHKey(authKeyPath, concatSSHKeys)
 	}
 
 	return nil
 }
 
+// updateUserSSHKeys writes the SSH keys of the users other than core, and
+// removes the keys of the users that are no longer part of the config.
+func (dn *Daemon) updateUserSSHKeys(newUsers, oldUsers []ign3types.PasswdUser) error {
+	if dn.mock {
+		return nil
+	}
+
+	hasUsers := func(users []ign3types.PasswdUser) bool {
+		return slices.ContainsFunc(users, isManagedUser)
+	}
+	if !hasUsers(newUsers) && !hasUsers(oldUsers) {
+		return nil
+	}
+
+	entries, err := listPasswdEntries(dn.cmdRunner)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range newUsers {
+		if !isManagedUser(u) || !shouldExist(u.ShouldExist) {
+			continue
+		}
+		entry, ok := entries[u.Name]
+		if !ok {
+			klog.Infof("user %s does not exist, skipping its SSH keys", u.Name)
+			continue
+		}
+		if err := writeUserSSHKeys(u, entry, dn.useNewSSHKeyPath()); err != nil {
+			return err
+		}
+	}
+
+	for _, u := range oldUsers {
+		if !isManagedUser(u) || isUserPresent(u, newUsers) {
+			continue
+		}
+		entry, ok := entries[u.Name]
+		if !ok {
+			continue
+		}
+		if err := writeUserSSHKeys(ign3types.PasswdUser{Name: u.Name}, entry, dn.useNewSSHKeyPath()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func deconfigureAbsentUsers(newUsers, oldUsers []ign3types.PasswdUser) {
 	for _, oldUser := range oldUsers {
-		if !isUserPresent(oldUser, newUsers) {
-			klog.Infof("Absent user detected, deconfiguring the password for user %s", oldUser.Name)
-			deconfigureUser(oldUser)
+		if isUserPresent(oldUser, newUsers) {
+			continue
+		}
+		// Users created by the MCD are deleted by updateUsersAndGroups.
+		if _, err := user.Lookup(oldUser.Name); err != nil {
+			continue
+		}
+		klog.Infof("Absent user detected, deconfiguring the password for user %s", oldUser.Name)
+		if err := deconfigureUser(oldUser); err != nil {
+			klog.Warningf("Failed to deconfigure user %s: %v", oldUser.Name, err)
 		}
 	}
 }
@@ -2937,6 +3416,23 @@ func (dn *Daemon) updateLayeredOS(config *mcfgv1.MachineConfig) error {
 	newURL := config.Spec.OSImageURL
 	klog.Infof("Updating OS to layered image %q", newURL)
 
+	// Images built by on-cluster layering for a pool with nodes of more than one
+	// architecture are published as a manifest list, so update to the image for
+	// this node's architecture. The controller records it on the node, and the
+	// manifest list is only inspected for builds which predate that.
+	if archURL := dn.getDesiredArchImage(newURL); archURL != "" {
+		klog.Infof("Using image %q for architecture %s of multi-arch image %q", archURL, goruntime.GOARCH, newURL)
+		newURL = archURL
+	} else if dn.node != nil && dn.node.Annotations[constants.DesiredImageAnnotationKey] == newURL {
+		archURL, err := resolveArchSpecificImage(newURL)
+		if err != nil {
+			klog.Warningf("Could not resolve image %q for architecture %s, using it as-is: %v", newURL, goruntime.GOARCH, err)
+		} else if archURL != newURL {
+			klog.Infof("Resolved multi-arch image %q to %q for architecture %s", newURL, archURL, goruntime.GOARCH)
+			newURL = archURL
+		}
+	}
+
 	if err := dn.runBootloaderUpdate(newURL); err != nil {
 		klog.Warningf("bootloader update failed: %s", err)
 	}
@@ -3146,6 +3642,30 @@ func skopeoSupportsMultiArchSigstore(imageURL string) bool {
 }
 
 func isMultiArchImage(imageURL string) (bool, error) {
+	out, err := inspectRawManifest(imageURL)
+	if err != nil {
+		return false, err
+	}
+	return isMultiArchManifest(imageURL, out)
+}
+
+// isMultiArchManifest determines whether the raw manifest for the given image
+// is a manifest list or OCI image index
+func isMultiArchManifest(imageURL string, raw []byte) (bool, error) {
+	var manifest struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return false, fmt.Errorf("failed to parse manifest for %s: %w", imageURL, err)
+	}
+	multiArch := manifest.MediaType == "application/vnd.docker.distribution.manifest.list.v2+json" ||
+		manifest.MediaType == "application/vnd.oci.image.index.v1+json"
+	klog.Infof("Image %s mediaType: %s, multi-arch: %v", imageURL, manifest.MediaType, multiArch)
+	return multiArch, nil
+}
+
+// inspectRawManifest fetches the raw manifest for the given image using skopeo
+func inspectRawManifest(imageURL string) ([]byte, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	args := []string{"inspect", "--raw"}
@@ -3156,18 +3676,86 @@ func isMultiArchImage(imageURL string) (bool, error) {
 	cmd := exec.CommandContext(ctx, "skopeo", args...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return false, fmt.Errorf("skopeo inspect failed for %s: %w", imageURL, err)
+		return nil, fmt.Errorf("skopeo inspect failed for %s: %w", imageURL, err)
 	}
-	var manifest struct {
-		MediaType string `json:"mediaType"`
+	return out, nil
+}
+
+// archSpecificImages caches the results of resolveArchSpecificImage for
+// digested pullspecs. Since those are immutable, the result never changes.
+var archSpecificImages sync.Map
+
+// resolveArchSpecificImage resolves a multi-arch image, such as one built by
+// on-cluster layering for a pool of mixed-architecture nodes, to the digested
+// pullspec of the image for this node's architecture. Images which are not
+// multi-arch are returned unchanged.
+func resolveArchSpecificImage(imageURL string) (string, error) {
+	if cached, ok := archSpecificImages.Load(imageURL); ok {
+		return cached.(string), nil
 	}
-	if err := json.Unmarshal(out, &manifest); err != nil {
-		return false, fmt.Errorf("failed to parse manifest for %s: %w", imageURL, err)
+
+	raw, err := inspectRawManifest(imageURL)
+	if err != nil {
+		return "", err
 	}
-	multiArch := manifest.MediaType == "application/vnd.docker.distribution.manifest.list.v2+json" ||
-		manifest.MediaType == "application/vnd.oci.image.index.v1+json"
-	klog.Infof("Image %s mediaType: %s, multi-arch: %v", imageURL, manifest.MediaType, multiArch)
-	return multiArch, nil
+
+	multiArch, err := isMultiArchManifest(imageURL, raw)
+	if err != nil {
+		return "", err
+	}
+
+	resolved := imageURL
+
+	if multiArch {
+		dgst, err := selectManifestForArch(raw, goruntime.GOARCH)
+		if err != nil {
+			return "", fmt.Errorf("could not resolve %s: %w", imageURL, err)
+		}
+
+		named, err := reference.ParseNamed(imageURL)
+		if err != nil {
+			return "", fmt.Errorf("could not parse %s: %w", imageURL, err)
+		}
+
+		archRef, err := reference.WithDigest(reference.TrimNamed(named), dgst)
+		if err != nil {
+			return "", fmt.Errorf("could not construct pullspec for %s: %w", imageURL, err)
+		}
+
+		resolved = archRef.String()
+	}
+
+	if strings.Contains(imageURL, "@sha256:") {
+		archSpecificImages.Store(imageURL, resolved)
+	}
+
+	return resolved, nil
+}
+
+// selectManifestForArch returns the digest of the linux image for the given
+// architecture from a raw manifest list or OCI image index
+func selectManifestForArch(raw []byte, arch string) (digest.Digest, error) {
+	var list struct {
+		Manifests []struct {
+			Digest   digest.Digest `json:"digest"`
+			Platform *struct {
+				Architecture string `json:"architecture"`
+				OS           string `json:"os"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return "", fmt.Errorf("failed to parse manifest list: %w", err)
+	}
+	for _, manifest := range list.Manifests {
+		if manifest.Platform == nil {
+			continue
+		}
+		if manifest.Platform.Architecture == arch && manifest.Platform.OS == "linux" {
+			return manifest.Digest, nil
+		}
+	}
+	return "", fmt.Errorf("manifest list has no image for linux/%s", arch)
 }
 
 // Log a message to the systemd journal as well as our stdout
@@ -3215,6 +3803,14 @@ func (dn *Daemon) CancelSIGTERM() {
 // cleans up the agent's connections
 // on failure to reboot, it throws an error and waits for the operator to try again
 func (dn *Daemon) reboot(rationale string) error {
+	return dn.rebootWithPlan(rationale, nil)
+}
+
+// rebootWithPlan reboots like reboot, but soft reboots or kexecs into the
+// staged deployment if planned. If that can't be prepared or doesn't complete
+// within fastRebootTimeout, it falls back to a full reboot, as it does on the
+// next start if another deployment was booted.
+func (dn *Daemon) rebootWithPlan(rationale string, plan *fastRebootPlan) error {
 	// Now that everything is done, avoid delaying shutdown.
 	dn.CancelSIGTERM()
 	dn.Close()
@@ -3243,7 +3839,38 @@ func (dn *Daemon) reboot(rationale string) error {
 	// We're not returning the error from the reboot command as it can be terminated by
 	// the system itself with signal: terminated. We can't catch the subprocess termination signal
 	// either, we just have one for the MCD itself.
-	rebootCmd := rebootCommand(rationale, dn.os.IsCoreOSVariant())
+	if plan != nil {
+		verb, err := plan.prepare(dn.cmdRunner)
+		if err == nil {
+			// The deployment is checked on the next start of the daemon
+			err = writeFastRebootState(constants.FastRebootStateFile, plan)
+		}
+		if err == nil {
+			logSystem("initiating %s into kernel %s", plan.method, plan.kernelVersion)
+			err = rebootCommand(rationale, verb, dn.os.IsCoreOSVariant()).Run()
+		}
+		if err == nil {
+			dn.rebootQueued = true
+			logSystem("%s successful", plan.method)
+			// If the soft reboot or kexec fails or hangs after being queued,
+			// the daemon is not stopped; fully reboot then.
+			time.AfterFunc(fastRebootTimeout, func() {
+				logSystem("%s did not complete within %v, falling back to a full reboot", plan.method, fastRebootTimeout)
+				mcdRebootErr.Inc()
+				if err := rebootCommand(rationale, "reboot", dn.os.IsCoreOSVariant()).Run(); err != nil {
+					logSystem("failed to run reboot: %v", err)
+				}
+			})
+			return nil
+		}
+		logSystem("failed to run %s, falling back to a full reboot: %v", plan.method, err)
+		mcdRebootErr.Inc()
+		if err := os.Remove(constants.FastRebootStateFile); err != nil && !os.IsNotExist(err) {
+			klog.Warningf("Failed to remove %s: %v", constants.FastRebootStateFile, err)
+		}
+	}
+
+	rebootCmd := rebootCommand(rationale, "reboot", dn.os.IsCoreOSVariant())
 	if err := rebootCmd.Run(); err != nil {
 		logSystem("failed to run reboot: %v", err)
 		mcdRebootErr.Inc()
@@ -3261,7 +3888,7 @@ func (dn *Daemon) reboot(rationale string) error {
 func (dn *CoreOSDaemon) applyLayeredOSChanges(mcDiff machineConfigDiff, oldConfig, newConfig *mcfgv1.MachineConfig) (retErr error) {
 	// Override the computed diff if the booted state differs from the oldConfig
 	// https://issues.redhat.com/browse/OCPBUGS-2757
-	if mcDiff.osUpdate && dn.bootedOSImageURL == newConfig.Spec.OSImageURL {
+	if mcDiff.osUpdate && dn.isBootedIntoImage(newConfig.Spec.OSImageURL) {
 		klog.Infof("Already in desired image %s", newConfig.Spec.OSImageURL)
 		mcDiff.osUpdate = false
 	}
@@ -3412,7 +4039,7 @@ func (dn *CoreOSDaemon) applyLayeredOSChanges(mcDiff machineConfigDiff, oldConfi
 	mcdPivotErr.Set(0)
 
 	if mcDiff.kargs {
-		if err := dn.updateKernelArguments(oldConfig.Spec.KernelArguments, newConfig.Spec.KernelArguments); err != nil {
+		if err := dn.updateKernelArguments(oldConfig, newConfig); err != nil {
 			return err
 		}
 	}
@@ -3455,7 +4082,7 @@ func (dn *Daemon) enableRevertSystemdUnit(newConfig *mcfgv1.MachineConfig) error
 		return fmt.Errorf("could not create %s: %w", runtimeassets.RevertServiceName, err)
 	}
 
-	if err := dn.writeFiles(revertIgn.Storage.Files, false); err != nil {
+	if err := dn.writeFiles(revertIgn.Storage.Files, false, false); err != nil {
 		return fmt.Errorf("could not write files for %s: %w", runtimeassets.RevertServiceName, err)
 	}
 
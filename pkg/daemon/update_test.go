@@ -17,6 +17,7 @@ import (
 	ign3types "github.com/coreos/ignition/v2/config/v3_5/types"
 	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
 	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	"github.com/openshift/machine-config-operator/pkg/daemon/osrelease"
@@ -24,6 +25,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/vincent-petithory/dataurl"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
@@ -289,9 +291,12 @@ func TestReconcilableDiff(t *testing.T) {
 
 func TestKernelAguments(t *testing.T) {
 	tests := []struct {
-		oldKargs []string
-		newKargs []string
-		out      []string
+		oldKargs         []string
+		newKargs         []string
+		oldIgnKargs      ign3types.KernelArguments
+		newIgnKargs      ign3types.KernelArguments
+		appendedIgnKargs []string
+		out              []string
 	}{
 		{
 			oldKargs: nil,
@@ -326,12 +331,39 @@ func TestKernelAguments(t *testing.T) {
 			out: []string{"--delete-if-present=hugepagesz=1G", "--delete-if-present=hugepages=4", "--delete-if-present=hugepagesz=2M", "--delete-if-present=hugepages=4",
 				"--append=hugepagesz=1G", "--append=hugepages=4", "--append=hugepagesz=2M", "--append=hugepages=6"},
 		},
+		{
+			newIgnKargs: ign3types.KernelArguments{
+				ShouldExist:    []ign3types.KernelArgument{"nosmt"},
+				ShouldNotExist: []ign3types.KernelArgument{"mitigations=auto"},
+			},
+			out: []string{"--append-if-missing=nosmt", "--delete-if-present=mitigations=auto"},
+		},
+		{
+			oldKargs: []string{"foo"},
+			newKargs: []string{"foo"},
+			oldIgnKargs: ign3types.KernelArguments{
+				ShouldExist:    []ign3types.KernelArgument{"nosmt", "quiet"},
+				ShouldNotExist: []ign3types.KernelArgument{"mitigations=auto"},
+			},
+			newIgnKargs: ign3types.KernelArguments{
+				ShouldExist: []ign3types.KernelArgument{"quiet"},
+			},
+			appendedIgnKargs: []string{"nosmt"},
+			out:              []string{"--delete-if-present=foo", "--delete-if-present=nosmt", "--append=foo", "--append-if-missing=quiet"},
+		},
+		{
+			// Shipped by the base OS, not appended by the MCD
+			oldIgnKargs: ign3types.KernelArguments{
+				ShouldExist: []ign3types.KernelArgument{"nosmt"},
+			},
+			out: []string{},
+		},
 	}
 
 	rand.Seed(time.Now().UnixNano())
 	for idx, test := range tests {
 		t.Run(fmt.Sprintf("case#%d", idx), func(t *testing.T) {
-			res := generateKargs(test.oldKargs, test.newKargs)
+			res := generateKargs(test.oldKargs, test.newKargs, test.oldIgnKargs, test.newIgnKargs, test.appendedIgnKargs)
 
 			if !reflect.DeepEqual(test.out, res) {
 				t.Errorf("Failed kernel arguments processing: expected: %v but result is: %v", test.out, res)
@@ -340,6 +372,27 @@ func TestKernelAguments(t *testing.T) {
 	}
 }
 
+func TestAppendedKargs(t *testing.T) {
+	shouldExist := []ign3types.KernelArgument{"nosmt", "quiet", "debug", "foo"}
+	// quiet is shipped by the base OS, debug was appended before and foo is
+	// deleted and appended again as a MachineConfig kernel argument
+	appended := appendedShouldExistKargs([]string{"rw", "quiet", "debug", "foo"}, []string{"foo"}, []string{"debug"}, shouldExist)
+	assert.Equal(t, []string{"nosmt", "debug", "foo"}, appended)
+
+	path := filepath.Join(t.TempDir(), "appended-kargs")
+	read, err := readAppendedKargs(path)
+	require.NoError(t, err)
+	assert.Empty(t, read)
+
+	require.NoError(t, writeAppendedKargs(path, appended))
+	read, err = readAppendedKargs(path)
+	require.NoError(t, err)
+	assert.Equal(t, appended, read)
+
+	require.NoError(t, writeAppendedKargs(path, nil))
+	assert.NoFileExists(t, path)
+}
+
 func TestWriteFiles(t *testing.T) {
 	testDir, cleanup := setupTempDirWithEtc(t)
 	defer cleanup()
@@ -409,7 +462,7 @@ func TestWriteFiles(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			err := d.writeFiles(test.files, true)
+			err := d.writeFiles(test.files, true, false)
 			assert.Equal(t, test.expectedErr, err)
 			if test.expectedContents != nil {
 				fileContents, err := os.ReadFile(filePath)
@@ -421,6 +474,284 @@ func TestWriteFiles(t *testing.T) {
 	}
 }
 
+func TestWriteFilesWithAppends(t *testing.T) {
+	testDir, cleanup := setupTempDirWithEtc(t)
+	defer cleanup()
+
+	// Keep the current owner, the test may not run as root
+	file := func(path string, appends ...string) ign3types.File {
+		f := ign3types.File{Node: ign3types.Node{
+			Path:  filepath.Join(testDir, path),
+			User:  ign3types.NodeUser{ID: helpers.IntToPtr(-1)},
+			Group: ign3types.NodeGroup{ID: helpers.IntToPtr(-1)},
+		}}
+		for _, a := range appends {
+			f.Append = append(f.Append, ign3types.Resource{Source: helpers.StrToPtr(dataurl.EncodeBytes([]byte(a)))})
+		}
+		return f
+	}
+
+	existing := file("/etc/existing", "foo\n", "bar\n")
+	require.NoError(t, os.WriteFile(existing.Path, []byte("base\nfoo\nbar\n"), 0o644))
+	absent := file("/etc/absent", "foo\n")
+	withContents := file("/etc/contents", "foo\n")
+	withContents.Contents.Source = helpers.StrToPtr(dataurl.EncodeBytes([]byte("contents\n")))
+
+	files := []ign3types.File{existing, absent, withContents}
+	expected := map[string]string{
+		// The current contents are the base, even if they end with the appends
+		existing.Path:     "base\nfoo\nbar\nfoo\nbar\n",
+		absent.Path:       "foo\n",
+		withContents.Path: "contents\nfoo\n",
+	}
+
+	// Writing the files again must not append twice
+	for i := 0; i < 2; i++ {
+		require.NoError(t, writeFiles(files, false, false))
+		require.NoError(t, checkV3Files(files))
+		for path, contents := range expected {
+			actual, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, contents, string(actual), path)
+		}
+	}
+
+	// Changed appends replace the previous ones
+	files[0] = file("/etc/existing", "baz\n")
+	assert.Error(t, checkV3Files(files))
+	require.NoError(t, writeFiles(files, false, false))
+	require.NoError(t, checkV3Files(files))
+	actual, err := os.ReadFile(existing.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "base\nfoo\nbar\nbaz\n", string(actual))
+
+	// Removed files go back to their base contents
+	restored, err := restoreAppendBaseFile(existing.Path)
+	require.NoError(t, err)
+	assert.True(t, restored)
+	actual, err = os.ReadFile(existing.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "base\nfoo\nbar\n", string(actual))
+	assert.NoFileExists(t, appendBaseFileName(existing.Path))
+
+	restored, err = restoreAppendBaseFile(absent.Path)
+	require.NoError(t, err)
+	assert.True(t, restored)
+	assert.NoFileExists(t, absent.Path)
+	assert.NoFileExists(t, noAppendBaseStampName(absent.Path))
+
+	restored, err = restoreAppendBaseFile(withContents.Path)
+	require.NoError(t, err)
+	assert.False(t, restored)
+
+	// Files written with their full contents by a previous MachineConfig use
+	// the contents recorded before the MCD took over as their base
+	withOrig := file("/etc/with-orig", "foo\n")
+	require.NoError(t, os.WriteFile(withOrig.Path, []byte("previous machineconfig\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Dir(origFileName(withOrig.Path)), 0o755))
+	require.NoError(t, os.WriteFile(origFileName(withOrig.Path), []byte("original\n"), 0o644))
+	withNoOrig := file("/etc/with-noorig", "foo\n")
+	require.NoError(t, os.WriteFile(withNoOrig.Path, []byte("previous machineconfig\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Dir(noOrigFileStampName(withNoOrig.Path)), 0o755))
+	require.NoError(t, os.WriteFile(noOrigFileStampName(withNoOrig.Path), nil, 0o644))
+
+	require.NoError(t, writeFiles([]ign3types.File{withOrig, withNoOrig}, false, false))
+	for path, contents := range map[string]string{withOrig.Path: "original\nfoo\n", withNoOrig.Path: "foo\n"} {
+		actual, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, contents, string(actual), path)
+	}
+	assert.FileExists(t, noAppendBaseStampName(withNoOrig.Path))
+}
+
+func TestWriteFilesWithAppendsFirstBoot(t *testing.T) {
+	testDir, cleanup := setupTempDirWithEtc(t)
+	defer cleanup()
+
+	file := func(path string, appends ...string) ign3types.File {
+		f := ign3types.File{Node: ign3types.Node{
+			Path:  filepath.Join(testDir, path),
+			User:  ign3types.NodeUser{ID: helpers.IntToPtr(-1)},
+			Group: ign3types.NodeGroup{ID: helpers.IntToPtr(-1)},
+		}}
+		for _, a := range appends {
+			f.Append = append(f.Append, ign3types.Resource{Source: helpers.StrToPtr(dataurl.EncodeBytes([]byte(a)))})
+		}
+		return f
+	}
+
+	// Ignition already appended on firstboot. The appended text also
+	// appears earlier in the file, so its base can't be told from its
+	// contents.
+	withOrig := file("/etc/with-orig", "foo\n")
+	require.NoError(t, os.WriteFile(withOrig.Path, []byte("foo\nbase\nfoo\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Dir(origFileName(withOrig.Path)), 0o755))
+	require.NoError(t, os.WriteFile(origFileName(withOrig.Path), []byte("foo\nbase\n"), 0o644))
+	created := file("/etc/created", "foo\n")
+	require.NoError(t, os.WriteFile(created.Path, []byte("foo\n"), 0o644))
+
+	files := []ign3types.File{withOrig, created}
+	require.NoError(t, writeFiles(files, false, true))
+	for i := 0; i < 2; i++ {
+		require.NoError(t, checkV3Files(files))
+		for path, contents := range map[string]string{withOrig.Path: "foo\nbase\nfoo\n", created.Path: "foo\n"} {
+			actual, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, contents, string(actual), path)
+		}
+		require.NoError(t, writeFiles(files, false, false))
+	}
+
+	// The base is only seeded from the orig file
+	base, err := os.ReadFile(appendBaseFileName(withOrig.Path))
+	require.NoError(t, err)
+	assert.Equal(t, "foo\nbase\n", string(base))
+	assert.FileExists(t, noAppendBaseStampName(created.Path))
+}
+
+func TestWriteFilesAppendsToContentsToAppends(t *testing.T) {
+	testDir, cleanup := setupTempDirWithEtc(t)
+	defer cleanup()
+
+	file := func(path, contents string, appends ...string) ign3types.File {
+		f := ign3types.File{Node: ign3types.Node{
+			Path:  filepath.Join(testDir, path),
+			User:  ign3types.NodeUser{ID: helpers.IntToPtr(-1)},
+			Group: ign3types.NodeGroup{ID: helpers.IntToPtr(-1)},
+		}}
+		if contents != "" {
+			f.Contents.Source = helpers.StrToPtr(dataurl.EncodeBytes([]byte(contents)))
+		}
+		for _, a := range appends {
+			f.Append = append(f.Append, ign3types.Resource{Source: helpers.StrToPtr(dataurl.EncodeBytes([]byte(a)))})
+		}
+		return f
+	}
+
+	// The file did not ship with the OS, so the MCD records it with a noorig
+	// stamp on the first write. Once its contents were written, the base
+	// recorded for the first appends is stale.
+	existingPath := filepath.Join(testDir, "/etc/existing")
+	require.NoError(t, os.WriteFile(existingPath, []byte("base\n"), 0o644))
+	absentPath := filepath.Join(testDir, "/etc/absent")
+
+	steps := []struct {
+		name     string
+		files    []ign3types.File
+		expected map[string]string
+		appended bool
+	}{
+		{
+			name:     "appends",
+			files:    []ign3types.File{file("/etc/existing", "", "foo\n"), file("/etc/absent", "", "foo\n")},
+			expected: map[string]string{existingPath: "base\nfoo\n", absentPath: "foo\n"},
+			appended: true,
+		},
+		{
+			name:     "contents",
+			files:    []ign3types.File{file("/etc/existing", "contents\n"), file("/etc/absent", "contents\n")},
+			expected: map[string]string{existingPath: "contents\n", absentPath: "contents\n"},
+		},
+		{
+			name:     "appends again",
+			files:    []ign3types.File{file("/etc/existing", "", "bar\n"), file("/etc/absent", "", "bar\n")},
+			expected: map[string]string{existingPath: "bar\n", absentPath: "bar\n"},
+			appended: true,
+		},
+	}
+
+	for _, step := range steps {
+		require.NoError(t, writeFiles(step.files, false, false), step.name)
+		require.NoError(t, checkV3Files(step.files), step.name)
+		for path, contents := range step.expected {
+			actual, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, contents, string(actual), "%s: %s", step.name, path)
+		}
+
+		// The base is only recorded while the files are appended to
+		baseExists, err := fileExists(appendBaseFileName(existingPath))
+		require.NoError(t, err)
+		assert.Equal(t, step.name == "appends", baseExists, step.name)
+		stampExists, err := fileExists(noAppendBaseStampName(existingPath))
+		require.NoError(t, err)
+		assert.Equal(t, step.name == "appends again", stampExists, step.name)
+		stampExists, err = fileExists(noAppendBaseStampName(absentPath))
+		require.NoError(t, err)
+		assert.Equal(t, step.appended, stampExists, step.name)
+	}
+}
+
+func TestWriteDirectoriesAndLinks(t *testing.T) {
+	testDir, cleanup := setupTempDirWithEtc(t)
+	defer cleanup()
+
+	// Keep the current owner, the test may not run as root
+	node := func(path string) ign3types.Node {
+		return ign3types.Node{
+			Path:  filepath.Join(testDir, path),
+			User:  ign3types.NodeUser{ID: helpers.IntToPtr(-1)},
+			Group: ign3types.NodeGroup{ID: helpers.IntToPtr(-1)},
+		}
+	}
+	dir := func(path string, mode int) ign3types.Directory {
+		return ign3types.Directory{Node: node(path), DirectoryEmbedded1: ign3types.DirectoryEmbedded1{Mode: &mode}}
+	}
+	link := func(path, target string) ign3types.Link {
+		return ign3types.Link{Node: node(path), LinkEmbedded1: ign3types.LinkEmbedded1{Target: &target}}
+	}
+
+	// Create the directories, parents first whatever their order
+	dirs := []ign3types.Directory{dir("/etc/foo/bar", 0o700), dir("/etc/foo", 0o750)}
+	require.NoError(t, writeDirectories(dirs))
+	require.NoError(t, checkV3Directories(dirs))
+
+	// Update the mode in place
+	dirs[1] = dir("/etc/foo", 0o755)
+	require.NoError(t, checkV3Directories(dirs[:1]))
+	assert.Error(t, checkV3Directories(dirs))
+	require.NoError(t, writeDirectories(dirs))
+	require.NoError(t, checkV3Directories(dirs))
+
+	// Create and retarget a link
+	links := []ign3types.Link{link("/etc/foo/link", "/etc/target-1")}
+	require.NoError(t, writeLinks(links))
+	require.NoError(t, checkV3Links(links))
+	links[0] = link("/etc/foo/link", "/etc/target-2")
+	assert.Error(t, checkV3Links(links))
+	require.NoError(t, writeLinks(links))
+	require.NoError(t, checkV3Links(links))
+
+	// Regular files are only replaced if overwrite is set
+	regularFile := filepath.Join(testDir, "/etc/regular-file")
+	require.NoError(t, os.WriteFile(regularFile, []byte("contents"), 0o644))
+	overwritingLink := link("/etc/regular-file", "/etc/target-1")
+	assert.Error(t, writeLinks([]ign3types.Link{overwritingLink}))
+	overwritingLink.Overwrite = helpers.BoolToPtr(true)
+	require.NoError(t, writeLinks([]ign3types.Link{overwritingLink}))
+	require.NoError(t, checkV3Links([]ign3types.Link{overwritingLink}))
+
+	// Hard links are not supported
+	hardLink := link("/etc/hard-link", regularFile)
+	hardLink.Hard = helpers.BoolToPtr(true)
+	assert.Error(t, writeLinks([]ign3types.Link{hardLink}))
+
+	// Directories that existed before are kept, as are those that are not empty
+	preexistingDir := dir("/etc", 0o755)
+	require.NoError(t, writeDirectories([]ign3types.Directory{preexistingDir}))
+	dirs = append(dirs, preexistingDir)
+	require.NoError(t, deleteStaleDirectories(dirs, map[string]struct{}{}))
+	assert.DirExists(t, filepath.Join(testDir, "/etc/foo"))
+	assert.NoDirExists(t, filepath.Join(testDir, "/etc/foo/bar"))
+	assert.DirExists(t, filepath.Join(testDir, "/etc"))
+
+	// Once emptied, the directory is removed
+	require.NoError(t, os.Remove(links[0].Path))
+	require.NoError(t, deleteStaleDirectories(dirs, map[string]struct{}{}))
+	assert.NoDirExists(t, filepath.Join(testDir, "/etc/foo"))
+	assert.NoFileExists(t, noOrigFileStampName(filepath.Join(testDir, "/etc/foo")))
+}
+
 // This test provides a false sense of security. Given the combination of the
 // mock mode in the MCD coupled with the inputs into this test, it effectively
 // no-ops and does not test what we think it tests.
@@ -1045,3 +1376,200 @@ func TestLegacyExtensionPackageUpgradeScenario(t *testing.T) {
 
 	require.True(t, foundNewMatch, "Should find a valid package set matching the new OS image state (current packages)")
 }
+
+func TestSelectManifestForArch(t *testing.T) {
+	manifestList := []byte(`{
+	"schemaVersion": 2,
+	"mediaType": "application/vnd.oci.image.index.v1+json",
+	"manifests": [
+		{
+			"mediaType": "application/vnd.oci.image.manifest.v1+json",
+			"digest": "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+			"size": 1000,
+			"platform": {"architecture": "amd64", "os": "linux"}
+		},
+		{
+			"mediaType": "application/vnd.oci.image.manifest.v1+json",
+			"digest": "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+			"size": 1000,
+			"platform": {"architecture": "arm64", "os": "linux"}
+		},
+		{
+			"mediaType": "application/vnd.oci.image.manifest.v1+json",
+			"digest": "sha256:3333333333333333333333333333333333333333333333333333333333333333",
+			"size": 1000
+		}
+	]
+}`)
+
+	dgst, err := selectManifestForArch(manifestList, "arm64")
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:2222222222222222222222222222222222222222222222222222222222222222", dgst.String())
+
+	dgst, err = selectManifestForArch(manifestList, "amd64")
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:1111111111111111111111111111111111111111111111111111111111111111", dgst.String())
+
+	_, err = selectManifestForArch(manifestList, "s390x")
+	assert.Error(t, err)
+}
+
+func TestIsSameImageRepository(t *testing.T) {
+	assert.True(t, isSameImageRepository(
+		"registry.host/org/os@sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		"registry.host/org/os@sha256:2222222222222222222222222222222222222222222222222222222222222222",
+	))
+	assert.False(t, isSameImageRepository(
+		"registry.host/org/os@sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		"registry.host/org/other@sha256:1111111111111111111111111111111111111111111111111111111111111111",
+	))
+	assert.False(t, isSameImageRepository("", "registry.host/org/os:latest"))
+}
+
+func TestOSNodeDisruptionPolicyActions(t *testing.T) {
+	mcop := &opv1.MachineConfiguration{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		constants.OSNodeDisruptionPolicyAnnotationKey: `{"kernelArguments":[{"name":"nosmt","actions":[{"type":"Restart","restart":{"serviceName":"foo.service"}}]},{"name":"audit","actions":[{"type":"None"}]}],` +
+			`"extensions":[{"name":"usbguard","actions":[{"type":"Reload","reload":{"serviceName":"usbguard.service"}}]}]}`,
+	}}}
+	osPolicy, err := apihelpers.GetOSNodeDisruptionPolicy(mcop)
+	require.NoError(t, err)
+
+	reboot := []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RebootStatusAction}}
+	testCases := []struct {
+		name     string
+		diff     *machineConfigDiff
+		osPolicy *apihelpers.OSNodeDisruptionPolicy
+		expected []opv1.NodeDisruptionPolicyStatusAction
+	}{
+		{
+			name:     "kargs with a policy",
+			diff:     &machineConfigDiff{kargs: true, changedKargs: []string{"nosmt"}},
+			osPolicy: osPolicy,
+			expected: []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RestartStatusAction, Restart: &opv1.RestartService{ServiceName: "foo.service"}}},
+		},
+		{
+			name:     "kargs with a None policy",
+			diff:     &machineConfigDiff{kargs: true, changedKargs: []string{"audit"}},
+			osPolicy: osPolicy,
+			expected: []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.NoneStatusAction}},
+		},
+		{
+			name:     "kargs partially covered by the policy",
+			diff:     &machineConfigDiff{kargs: true, changedKargs: []string{"nosmt", "isolcpus"}},
+			osPolicy: osPolicy,
+			expected: reboot,
+		},
+		{
+			name:     "kargs without a policy",
+			diff:     &machineConfigDiff{kargs: true, changedKargs: []string{"nosmt"}},
+			expected: reboot,
+		},
+		{
+			name:     "added extension with a policy",
+			diff:     &machineConfigDiff{extensions: true, addedExtensions: []string{"usbguard"}},
+			osPolicy: osPolicy,
+			expected: []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.ReloadStatusAction, Reload: &opv1.ReloadService{ServiceName: "usbguard.service"}}},
+		},
+		{
+			name:     "removed extension",
+			diff:     &machineConfigDiff{extensions: true, removedExtensions: []string{"usbguard"}},
+			osPolicy: osPolicy,
+			expected: reboot,
+		},
+		{
+			name:     "OS image update",
+			diff:     &machineConfigDiff{osUpdate: true, kargs: true, changedKargs: []string{"nosmt"}},
+			osPolicy: osPolicy,
+			expected: reboot,
+		},
+		{
+			name:     "kernel type change",
+			diff:     &machineConfigDiff{kernelType: true},
+			osPolicy: osPolicy,
+			expected: reboot,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actions := calculatePostConfigChangeNodeDisruptionActionFromDiff(testCase.diff, nil, nil, opv1.NodeDisruptionPolicyClusterStatus{}, testCase.osPolicy)
+			assert.Equal(t, testCase.expected, actions)
+		})
+	}
+
+	for _, invalid := range []string{
+		`{"kernelArguments":[{"name":"nosmt"}]}`,
+		`{"kernelArguments":[{"name":"nosmt","actions":[{"type":"Restart"}]}]}`,
+		`{"extensions":[{"name":"usbguard","actions":[{"type":"None"}]},{"name":"usbguard","actions":[{"type":"None"}]}]}`,
+		`{"packages":[]}`,
+		`{"rebootMethods":["Kexec","Kexec"]}`,
+		`{"rebootMethods":["Hibernate"]}`,
+		`{"unitHealthCheck":{"windowSeconds":-1}}`,
+		`{"unitHealthCheck":{"windowSeconds":3600}}`,
+		`{"unitHealthCheck":{"onFailure":"Reboot"}}`,
+	} {
+		mcop.Annotations[constants.OSNodeDisruptionPolicyAnnotationKey] = invalid
+		_, err := apihelpers.GetOSNodeDisruptionPolicy(mcop)
+		assert.Error(t, err, invalid)
+	}
+
+	mcop.Annotations[constants.OSNodeDisruptionPolicyAnnotationKey] = `{"unitHealthCheck":{"windowSeconds":120}}`
+	policy, err := apihelpers.GetOSNodeDisruptionPolicy(mcop)
+	require.NoError(t, err)
+	assert.Equal(t, &apihelpers.UnitHealthCheck{WindowSeconds: 120, OnFailure: apihelpers.UnitHealthCheckReport}, policy.GetUnitHealthCheck())
+	// The units are only checked if the policy opts in
+	assert.Nil(t, (&apihelpers.OSNodeDisruptionPolicy{}).GetUnitHealthCheck())
+	assert.Nil(t, (*apihelpers.OSNodeDisruptionPolicy)(nil).GetUnitHealthCheck())
+}
+
+func TestIsMultiArchManifest(t *testing.T) {
+	image := "registry.host/org/os@sha256:1111111111111111111111111111111111111111111111111111111111111111"
+
+	multiArch, err := isMultiArchManifest(image, []byte(`{"mediaType": "application/vnd.oci.image.index.v1+json"}`))
+	require.NoError(t, err)
+	assert.True(t, multiArch)
+
+	multiArch, err = isMultiArchManifest(image, []byte(`{"mediaType": "application/vnd.docker.distribution.manifest.list.v2+json"}`))
+	require.NoError(t, err)
+	assert.True(t, multiArch)
+
+	multiArch, err = isMultiArchManifest(image, []byte(`{"mediaType": "application/vnd.oci.image.manifest.v1+json"}`))
+	require.NoError(t, err)
+	assert.False(t, multiArch)
+
+	_, err = isMultiArchManifest(image, []byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestIsBootedIntoImage(t *testing.T) {
+	manifestList := "registry.host/org/os@sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	archImage := "registry.host/org/os@sha256:2222222222222222222222222222222222222222222222222222222222222222"
+
+	newDaemon := func(booted string, annotations map[string]string) *Daemon {
+		return &Daemon{
+			bootedOSImageURL: booted,
+			node:             &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}},
+		}
+	}
+
+	desiredAnnotations := map[string]string{
+		constants.DesiredImageAnnotationKey:     manifestList,
+		constants.DesiredArchImageAnnotationKey: archImage,
+	}
+
+	assert.True(t, newDaemon(manifestList, nil).isBootedIntoImage(manifestList))
+
+	// The image for the node's architecture is taken from the node, the
+	// registry is never consulted
+	assert.True(t, newDaemon(archImage, desiredAnnotations).isBootedIntoImage(manifestList))
+
+	// During an update the booted image differs from the desired image
+	assert.False(t, newDaemon("registry.host/org/os@sha256:3333333333333333333333333333333333333333333333333333333333333333", desiredAnnotations).isBootedIntoImage(manifestList))
+	assert.False(t, newDaemon(archImage, map[string]string{constants.DesiredImageAnnotationKey: manifestList}).isBootedIntoImage(manifestList))
+
+	// The recorded image only applies to the desired image
+	assert.False(t, newDaemon(archImage, map[string]string{
+		constants.DesiredImageAnnotationKey:     "registry.host/org/os@sha256:4444444444444444444444444444444444444444444444444444444444444444",
+		constants.DesiredArchImageAnnotationKey: archImage,
+	}).isBootedIntoImage(manifestList))
+}
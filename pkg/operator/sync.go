@@ -13,6 +13,7 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -24,6 +25,7 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
@@ -144,6 +146,8 @@ const (
 	mcdKubeRbacProxyPrometheusRoleBindingPath       = "manifests/machineconfigdaemon/prometheus-rolebinding-target.yaml"
 	mcdRolePath                                     = "manifests/machineconfigdaemon/role.yaml"
 	mcdRoleBindingPath                              = "manifests/machineconfigdaemon/rolebinding.yaml"
+	mcdCABundlesRolePath                            = "manifests/machineconfigdaemon/ca-bundles-role.yaml"
+	mcdCABundlesRoleBindingPath                     = "manifests/machineconfigdaemon/ca-bundles-rolebinding.yaml"
 	mcdMCNGuardValidatingAdmissionPolicyPath        = "manifests/machineconfigdaemon/mcn-guards-validatingadmissionpolicy.yaml"
 	mcdMCNGuardValidatingAdmissionPolicyBindingPath = "manifests/machineconfigdaemon/mcn-guards-validatingadmissionpolicybinding.yaml"
 
@@ -1653,12 +1657,14 @@ func (optr *Operator) syncMachineConfigDaemon(config *renderConfig, _ *configv1.
 		roles: []string{
 			mcdKubeRbacProxyPrometheusRolePath,
 			mcdRolePath,
+			mcdCABundlesRolePath,
 		},
 		roleBindings: []string{
 			mcdEventsRoleBindingDefaultManifestPath,
 			mcdEventsRoleBindingTargetManifestPath,
 			mcdKubeRbacProxyPrometheusRoleBindingPath,
 			mcdRoleBindingPath,
+			mcdCABundlesRoleBindingPath,
 		},
 		clusterRoleBindings: []string{
 			mcdClusterRoleBindingManifestPath,
@@ -2418,6 +2424,15 @@ func (optr *Operator) syncMachineConfiguration(_ *renderConfig, _ *configv1.Clus
 	// Update skew enforcement status if needed
 	optr.syncBootImageSkewEnforcementStatus(mcop, newMachineConfigurationStatus, infra, supportsBootImageUpdates)
 
+	// Report whether the kernel argument and extension policies are in effect,
+	// and the nodes whose kernel argument changes are not booted yet
+	syncOSNodeDisruptionPolicyStatus(mcop, newMachineConfigurationStatus, optr.fgHandler.Enabled(apihelpers.OSNodeDisruptionPolicyFeatureGate))
+	if mcns, err := optr.client.MachineconfigurationV1().MachineConfigNodes().List(context.TODO(), metav1.ListOptions{}); err != nil {
+		klog.Warningf("Could not list MachineConfigNodes for the pending kernel arguments: %v", err)
+	} else {
+		syncPendingKernelArgumentsStatus(mcns.Items, newMachineConfigurationStatus)
+	}
+
 	newMachineConfigurationStatus.ObservedGeneration = mcop.GetGeneration()
 	// Check if any changes are required in the Status before making the API call.
 	if !reflect.DeepEqual(mcop.Status, *newMachineConfigurationStatus) {
@@ -2580,6 +2595,86 @@ func (optr *Operator) syncPreBuiltImageMachineConfigs() error {
 	return nil
 }
 
+// syncOSNodeDisruptionPolicyStatus sets the OSNodeDisruptionPolicyValid condition
+// if an OS node disruption policy is set on the MachineConfiguration. The
+// daemons ignore invalid policies, so kernel argument and extension changes
+// then require a reboot. They also ignore the TechPreview OS node disruption
+// policy and live kernel arguments annotations unless the feature gate is
+// enabled, which the condition reports as well.
+func syncOSNodeDisruptionPolicyStatus(mcop *opv1.MachineConfiguration, newMachineConfigurationStatus *opv1.MachineConfigurationStatus, featureGateEnabled bool) {
+	if !featureGateEnabled {
+		_, policySet := mcop.Annotations[daemonconsts.OSNodeDisruptionPolicyAnnotationKey]
+		_, liveKargsSet := mcop.Annotations[daemonconsts.LiveApplyKernelArgumentsAnnotationKey]
+		if !policySet && !liveKargsSet {
+			meta.RemoveStatusCondition(&newMachineConfigurationStatus.Conditions, apihelpers.OSNodeDisruptionPolicyValidConditionType)
+			return
+		}
+		meta.SetStatusCondition(&newMachineConfigurationStatus.Conditions, metav1.Condition{
+			Type:   apihelpers.OSNodeDisruptionPolicyValidConditionType,
+			Status: metav1.ConditionFalse,
+			Reason: "FeatureGateDisabled",
+			Message: fmt.Sprintf("The %s and %s annotations are TechPreview and ignored unless the %s feature gate is enabled; kernel argument and extension changes require a reboot",
+				daemonconsts.OSNodeDisruptionPolicyAnnotationKey, daemonconsts.LiveApplyKernelArgumentsAnnotationKey, apihelpers.OSNodeDisruptionPolicyFeatureGate),
+		})
+		return
+	}
+
+	policy, err := apihelpers.GetOSNodeDisruptionPolicy(mcop)
+	switch {
+	case err != nil:
+		meta.SetStatusCondition(&newMachineConfigurationStatus.Conditions, metav1.Condition{
+			Type:    apihelpers.OSNodeDisruptionPolicyValidConditionType,
+			Status:  metav1.ConditionFalse,
+			Reason:  "InvalidPolicy",
+			Message: fmt.Sprintf("%v; kernel argument and extension changes require a reboot", err),
+		})
+	case policy == nil:
+		meta.RemoveStatusCondition(&newMachineConfigurationStatus.Conditions, apihelpers.OSNodeDisruptionPolicyValidConditionType)
+	default:
+		meta.SetStatusCondition(&newMachineConfigurationStatus.Conditions, metav1.Condition{
+			Type:    apihelpers.OSNodeDisruptionPolicyValidConditionType,
+			Status:  metav1.ConditionTrue,
+			Reason:  "PolicyApplied",
+			Message: fmt.Sprintf("Node disruption policies for %d kernel arguments and %d extensions are in effect", len(policy.KernelArguments), len(policy.Extensions)),
+		})
+	}
+}
+
+// maxPendingKernelArgumentsNodes bounds the nodes listed in the
+// KernelArgumentsPendingReboot condition message.
+const maxPendingKernelArgumentsNodes = 10
+
+// syncPendingKernelArgumentsStatus sets the KernelArgumentsPendingReboot
+// condition to the nodes reporting kernel arguments staged for their next
+// boot on their MachineConfigNode, and removes it if there are none.
+func syncPendingKernelArgumentsStatus(mcns []mcfgv1.MachineConfigNode, newMachineConfigurationStatus *opv1.MachineConfigurationStatus) {
+	pending := []string{}
+	for _, mcn := range mcns {
+		condition := meta.FindStatusCondition(mcn.Status.Conditions, string(upgrademonitor.MachineConfigNodeKernelArgumentsPendingReboot))
+		if condition != nil && condition.Status == metav1.ConditionTrue {
+			pending = append(pending, fmt.Sprintf("%s: %s", mcn.Name, condition.Message))
+		}
+	}
+	if len(pending) == 0 {
+		meta.RemoveStatusCondition(&newMachineConfigurationStatus.Conditions, apihelpers.KernelArgumentsPendingRebootConditionType)
+		return
+	}
+
+	sort.Strings(pending)
+	message := fmt.Sprintf("%d nodes have kernel arguments staged for their next boot: ", len(pending))
+	if len(pending) > maxPendingKernelArgumentsNodes {
+		message += fmt.Sprintf("%s; and %d more", strings.Join(pending[:maxPendingKernelArgumentsNodes], "; "), len(pending)-maxPendingKernelArgumentsNodes)
+	} else {
+		message += strings.Join(pending, "; ")
+	}
+	meta.SetStatusCondition(&newMachineConfigurationStatus.Conditions, metav1.Condition{
+		Type:    apihelpers.KernelArgumentsPendingRebootConditionType,
+		Status:  metav1.ConditionTrue,
+		Reason:  "KernelArgumentsStaged",
+		Message: message,
+	})
+}
+
 // syncBootImageSkewEnforcementStatus determines the appropriate BootImageSkewEnforcementStatus based on
 // the MachineConfiguration spec, platform defaults, and cluster version information.
 func (optr *Operator) syncBootImageSkewEnforcementStatus(mcop *opv1.MachineConfiguration, newMachineConfigurationStatus *opv1.MachineConfigurationStatus, infra *configv1.Infrastructure, supportsBootImageUpdates bool) {
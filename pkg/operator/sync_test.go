@@ -8,9 +8,12 @@ import (
 	features "github.com/openshift/api/features"
 	"github.com/openshift/machine-config-operator/pkg/apihelpers"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/upgrademonitor"
 	"github.com/openshift/machine-config-operator/test/helpers"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
@@ -824,6 +827,7 @@ func TestSyncMachineConfiguration(t *testing.T) {
 				infraLister:          configlistersv1.NewInfrastructureLister(infraIndexer),
 				mcopLister:           mcoplistersv1.NewMachineConfigurationLister(mcopIndexer),
 				mcopClient:           fakemcopclientset.NewSimpleClientset(tc.mcop),
+				client:               fakeclientmachineconfigv1.NewSimpleClientset(),
 				mcpLister:            mcplister.NewMachineConfigPoolLister(mcpIndexer),
 				clusterVersionLister: configlistersv1.NewClusterVersionLister(clusterVersionIndexer),
 				provisioningLister:   dynamiclister.New(provisioningIndexer, provisioningGVR),
@@ -1052,3 +1056,74 @@ func buildMachineConfigurationWithBootImageDisabledAndNoSkewEnforcement() *opv1.
 		},
 	}
 }
+
+func TestSyncOSNodeDisruptionPolicyStatus(t *testing.T) {
+	mcop := &opv1.MachineConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Annotations: map[string]string{
+		constants.OSNodeDisruptionPolicyAnnotationKey: `{"kernelArguments":[{"name":"nosmt","actions":[{"type":"None"}]}]}`,
+	}}}
+	status := &opv1.MachineConfigurationStatus{}
+
+	syncOSNodeDisruptionPolicyStatus(mcop, status, true)
+	condition := meta.FindStatusCondition(status.Conditions, apihelpers.OSNodeDisruptionPolicyValidConditionType)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	}
+
+	mcop.Annotations[constants.OSNodeDisruptionPolicyAnnotationKey] = `{"kernelArguments":[{"name":"nosmt","actions":[{"type":"Restart"}]}]}`
+	syncOSNodeDisruptionPolicyStatus(mcop, status, true)
+	condition = meta.FindStatusCondition(status.Conditions, apihelpers.OSNodeDisruptionPolicyValidConditionType)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, metav1.ConditionFalse, condition.Status)
+		assert.Equal(t, "InvalidPolicy", condition.Reason)
+	}
+
+	// the annotations are TechPreview and ignored without the feature gate
+	mcop.Annotations[constants.OSNodeDisruptionPolicyAnnotationKey] = `{"kernelArguments":[{"name":"nosmt","actions":[{"type":"None"}]}]}`
+	syncOSNodeDisruptionPolicyStatus(mcop, status, false)
+	condition = meta.FindStatusCondition(status.Conditions, apihelpers.OSNodeDisruptionPolicyValidConditionType)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, metav1.ConditionFalse, condition.Status)
+		assert.Equal(t, "FeatureGateDisabled", condition.Reason)
+	}
+
+	delete(mcop.Annotations, constants.OSNodeDisruptionPolicyAnnotationKey)
+	mcop.Annotations[constants.LiveApplyKernelArgumentsAnnotationKey] = "true"
+	syncOSNodeDisruptionPolicyStatus(mcop, status, false)
+	condition = meta.FindStatusCondition(status.Conditions, apihelpers.OSNodeDisruptionPolicyValidConditionType)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, "FeatureGateDisabled", condition.Reason)
+	}
+
+	delete(mcop.Annotations, constants.LiveApplyKernelArgumentsAnnotationKey)
+	syncOSNodeDisruptionPolicyStatus(mcop, status, false)
+	assert.Nil(t, meta.FindStatusCondition(status.Conditions, apihelpers.OSNodeDisruptionPolicyValidConditionType))
+}
+
+func TestSyncPendingKernelArgumentsStatus(t *testing.T) {
+	newMCN := func(name string, status metav1.ConditionStatus, message string) mcfgv1.MachineConfigNode {
+		return mcfgv1.MachineConfigNode{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: mcfgv1.MachineConfigNodeStatus{Conditions: []metav1.Condition{{
+				Type:    string(upgrademonitor.MachineConfigNodeKernelArgumentsPendingReboot),
+				Status:  status,
+				Message: message,
+			}}},
+		}
+	}
+	status := &opv1.MachineConfigurationStatus{}
+
+	syncPendingKernelArgumentsStatus([]mcfgv1.MachineConfigNode{
+		newMCN("worker-b", metav1.ConditionTrue, "added debug"),
+		newMCN("worker-a", metav1.ConditionTrue, "added nosmt"),
+		newMCN("worker-c", metav1.ConditionFalse, "booted"),
+		{ObjectMeta: metav1.ObjectMeta{Name: "worker-d"}},
+	}, status)
+	condition := meta.FindStatusCondition(status.Conditions, apihelpers.KernelArgumentsPendingRebootConditionType)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, metav1.ConditionTrue, condition.Status)
+		assert.Equal(t, "2 nodes have kernel arguments staged for their next boot: worker-a: added nosmt; worker-b: added debug", condition.Message)
+	}
+
+	syncPendingKernelArgumentsStatus([]mcfgv1.MachineConfigNode{newMCN("worker-a", metav1.ConditionFalse, "booted")}, status)
+	assert.Nil(t, meta.FindStatusCondition(status.Conditions, apihelpers.KernelArgumentsPendingRebootConditionType))
+}
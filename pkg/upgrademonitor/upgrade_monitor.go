@@ -24,6 +24,23 @@ import (
 
 const NotYetSet = "not-yet-set"
 
+// MachineConfigNodeKernelTuningDegraded is set by the daemon when kernel
+// arguments requested in the kernel tuning file were rejected or could not be
+// applied. It does not track the progress of an update, so it is left as is
+// when an update completes.
+const MachineConfigNodeKernelTuningDegraded mcfgv1.StateProgress = "KernelTuningDegraded"
+
+// MachineConfigNodeKernelArgumentsPendingReboot is set by the daemon when
+// kernel arguments of its current config are staged but not booted, as the
+// update that changed them did not reboot the node. It is cleared once the
+// node boots them.
+const MachineConfigNodeKernelArgumentsPendingReboot mcfgv1.StateProgress = "KernelArgumentsPendingReboot"
+
+// MachineConfigNodePinnedImagesPulling is set by the daemon while it pulls
+// pinned images under a prefetch policy. Its message holds the progress of the
+// prefetch, which other nodes in the pool use to enforce the per-pool limit.
+const MachineConfigNodePinnedImagesPulling mcfgv1.StateProgress = "PinnedImagesPulling"
+
 type Condition struct {
 	State   mcfgv1.StateProgress
 	Reason  string
@@ -130,6 +147,14 @@ func generateAndApplyMachineConfigNodes(
 		mcfgv1.MachineConfigNodePinnedImageSetsProgressing,
 	}
 
+	// independent conditions are reported outside of updates and are not reset
+	// when an update completes.
+	independentConditionTypes := []mcfgv1.StateProgress{
+		MachineConfigNodeKernelTuningDegraded,
+		MachineConfigNodeKernelArgumentsPendingReboot,
+		MachineConfigNodePinnedImagesPulling,
+	}
+
 	// we use this array to see if the MCN has all of its conditions set
 	// if not we set a sane default
 	// TODO (MCO-1775): Once ImageModeStatusReporting is GA, clean up the below logic. The `if`
@@ -241,7 +266,7 @@ func generateAndApplyMachineConfigNodes(
 				}
 				newParentCondition.DeepCopyInto(&condition)
 
-			case condition.Status != metav1.ConditionFalse && reset:
+			case condition.Status != metav1.ConditionFalse && reset && !isSingletonCondition(independentConditionTypes, condition.Type):
 				condition.Status = metav1.ConditionFalse
 				condition.LastTransitionTime = metav1.Now()
 
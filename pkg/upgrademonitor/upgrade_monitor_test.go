@@ -1,6 +1,7 @@
 package upgrademonitor
 
 import (
+	"encoding/json"
 	"testing"
 
 	apicfgv1 "github.com/openshift/api/config/v1"
@@ -9,6 +10,7 @@ import (
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -281,3 +283,89 @@ func TestStatusNoDiffGuardAppliesWhenObservedGenerationStale(t *testing.T) {
 		t.Errorf("expected 1 SSA ApplyStatus action when ObservedGeneration is stale, got %d", n)
 	}
 }
+
+// TestIndependentConditionKeepsUpdated verifies that reporting the
+// KernelTuningDegraded condition does not flip an Updated node to not updated,
+// and that completing an update does not reset the KernelTuningDegraded
+// condition.
+func TestIndependentConditionKeepsUpdated(t *testing.T) {
+	const nodeName = "worker-1"
+	const poolName = "worker"
+	const desiredConfig = "rendered-worker-abc123"
+
+	existingMCN := &mcfgv1.MachineConfigNode{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeName},
+		Spec: mcfgv1.MachineConfigNodeSpec{
+			Node:          mcfgv1.MCOObjectReference{Name: nodeName},
+			Pool:          mcfgv1.MCOObjectReference{Name: poolName},
+			ConfigVersion: mcfgv1.MachineConfigNodeSpecMachineConfigVersion{Desired: desiredConfig},
+		},
+		Status: mcfgv1.MachineConfigNodeStatus{
+			Conditions: []metav1.Condition{{
+				Type:               string(mcfgv1.MachineConfigNodeUpdated),
+				Status:             metav1.ConditionTrue,
+				Reason:             "Updated",
+				Message:            "node is updated",
+				LastTransitionTime: metav1.Now(),
+			}},
+			ConfigVersion: &mcfgv1.MachineConfigNodeStatusMachineConfigVersion{
+				Desired: desiredConfig,
+			},
+		},
+	}
+	fakeClient := fake.NewClientset([]runtime.Object{existingMCN}...)
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: nodeName,
+			Annotations: map[string]string{
+				daemonconsts.DesiredMachineConfigAnnotationKey: desiredConfig,
+			},
+		},
+	}
+
+	// getCondition returns a condition of the last applied status
+	getCondition := func(conditionType mcfgv1.StateProgress) *metav1.Condition {
+		t.Helper()
+		actions := fakeClient.Actions()
+		for i := len(actions) - 1; i >= 0; i-- {
+			pa, ok := actions[i].(k8stesting.PatchAction)
+			if !ok || pa.GetPatchType() != types.ApplyPatchType || pa.GetSubresource() != "status" {
+				continue
+			}
+			applied := &mcfgv1.MachineConfigNode{}
+			if err := json.Unmarshal(pa.GetPatch(), applied); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return meta.FindStatusCondition(applied.Status.Conditions, string(conditionType))
+		}
+		t.Fatalf("no status applied")
+		return nil
+	}
+
+	tuningCondition := &Condition{
+		State:   MachineConfigNodeKernelTuningDegraded,
+		Reason:  "KernelTuningArgumentsNotApplied",
+		Message: "Kernel tuning arguments not applied: DELETE nohz=off",
+	}
+	err := GenerateAndApplyMachineConfigNodes(tuningCondition, nil, metav1.ConditionTrue, metav1.ConditionFalse, node, fakeClient, newFakeHandler(), poolName)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c := getCondition(mcfgv1.MachineConfigNodeUpdated); c == nil || c.Status != metav1.ConditionTrue {
+		t.Errorf("expected Updated to stay True, got %v", c)
+	}
+
+	updatedCondition := &Condition{
+		State:   mcfgv1.MachineConfigNodeUpdated,
+		Reason:  "Updated",
+		Message: "node is updated again",
+	}
+	err = GenerateAndApplyMachineConfigNodes(updatedCondition, nil, metav1.ConditionTrue, metav1.ConditionFalse, node, fakeClient, newFakeHandler(), poolName)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c := getCondition(MachineConfigNodeKernelTuningDegraded); c == nil || c.Status != metav1.ConditionTrue {
+		t.Errorf("expected KernelTuningDegraded to stay True, got %v", c)
+	}
+}